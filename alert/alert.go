@@ -0,0 +1,135 @@
+// Package alert сообщает администратору о неполадках самого notephee: деградации канала,
+// отозванном токене бота, переполненной очереди отправки.
+//
+// Модули notephee вызывают Alert; оповещения уходят только после того, как приложение
+// задаст администратора через SetDefault. Без этого Alert ничего не делает.
+package alert
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/epheer/notephee/notify"
+)
+
+// Level — важность оповещения.
+type Level string
+
+const (
+	Info     Level = "info"     // Для сведения: канал восстановился и т.д.
+	Warning  Level = "warning"  // Работа продолжается с ограничениями
+	Critical Level = "critical" // Канал не работает без вмешательства администратора
+)
+
+// DefaultInterval — интервал, в течение которого одинаковое оповещение не повторяется.
+const DefaultInterval = 5 * time.Minute
+
+// sendTimeout — предел фоновой отправки одного оповещения всем адресатам.
+const sendTimeout = 30 * time.Second
+
+// Target — адрес администратора в одном канале.
+type Target struct {
+	Channel string // Канал: telegram, email и т.д.
+	To      string // Чат или адрес в канале
+}
+
+// Alerter отправляет оповещения администраторам через каналы реестра.
+type Alerter struct {
+	registry *notify.Registry // Каналы отправки
+	targets  []Target         // Адреса администраторов
+	logger   *slog.Logger     // Логгер
+
+	// Interval — сколько не повторять оповещение с тем же уровнем и текстом; по умолчанию DefaultInterval.
+	// Отрицательное значение отключает подавление повторов.
+	Interval time.Duration
+
+	mu   sync.Mutex
+	last map[string]time.Time // Время последней отправки по уровню и тексту
+	wg   sync.WaitGroup
+}
+
+// New создаёт Alerter, который отправляет оповещения на адреса targets.
+func New(registry *notify.Registry, logger *slog.Logger, targets ...Target) *Alerter {
+	return &Alerter{registry: registry, targets: targets, logger: logger, last: make(map[string]time.Time)}
+}
+
+// Alert отправляет оповещение всем адресатам в фоне и сразу возвращается, чтобы не задерживать
+// модуль, обнаруживший неполадку. Повтор того же оповещения в течение Interval пропускается.
+func (a *Alerter) Alert(level Level, msg string) {
+	if !a.due(level, msg) {
+		return
+	}
+	a.wg.Add(1)
+	go func() {
+		defer a.wg.Done()
+		ctx, cancel := context.WithTimeout(context.Background(), sendTimeout)
+		defer cancel()
+		if err := a.Send(ctx, level, msg); err != nil {
+			a.logger.Warn("не удалось отправить оповещение администратору", "level", level, "alert", msg, "error", err)
+		}
+	}()
+}
+
+// Send синхронно отправляет оповещение всем адресатам без подавления повторов.
+// Ошибки отправки по адресатам объединяются.
+func (a *Alerter) Send(ctx context.Context, level Level, msg string) error {
+	message := notify.Message{
+		Subject:  fmt.Sprintf("notephee: %s", level),
+		Text:     msg,
+		Priority: notify.PriorityHigh,
+	}
+	var errs []error
+	for _, t := range a.targets {
+		m := message
+		m.To = t.To
+		if err := a.registry.Send(ctx, t.Channel, m); err != nil {
+			errs = append(errs, fmt.Errorf("%s %s: %w", t.Channel, t.To, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Wait ждёт завершения фоновых отправок, начатых Alert.
+func (a *Alerter) Wait() {
+	a.wg.Wait()
+}
+
+// due отмечает отправку оповещения и сообщает, пора ли его отправлять.
+func (a *Alerter) due(level Level, msg string) bool {
+	interval := a.Interval
+	if interval == 0 {
+		interval = DefaultInterval
+	}
+	if interval < 0 {
+		return true
+	}
+	key := string(level) + "\x00" + msg
+	now := time.Now()
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if last, ok := a.last[key]; ok && now.Sub(last) < interval {
+		return false
+	}
+	a.last[key] = now
+	return true
+}
+
+var defaultAlerter atomic.Pointer[Alerter]
+
+// SetDefault делает a получателем оповещений модулей notephee; nil отключает оповещения.
+func SetDefault(a *Alerter) {
+	defaultAlerter.Store(a)
+}
+
+// Alert отправляет оповещение через Alerter, заданный SetDefault. Без него ничего не делает.
+func Alert(level Level, msg string) {
+	if a := defaultAlerter.Load(); a != nil {
+		a.Alert(level, msg)
+	}
+}
@@ -0,0 +1,70 @@
+package alert_test
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"testing"
+
+	"github.com/epheer/notephee/alert"
+	"github.com/epheer/notephee/notify"
+)
+
+// recordingSender запоминает отправленные сообщения и возвращает err.
+type recordingSender struct {
+	channel string
+	err     error
+
+	mu   sync.Mutex
+	sent []notify.Message
+}
+
+func (s *recordingSender) Channel() string { return s.channel }
+
+func (s *recordingSender) Send(_ context.Context, msg notify.Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sent = append(s.sent, msg)
+	return s.err
+}
+
+func TestAlert(t *testing.T) {
+	tg := &recordingSender{channel: "telegram"}
+	mail := &recordingSender{channel: "email", err: errors.New("smtp недоступен")}
+	registry := notify.NewRegistry()
+	registry.Register(tg)
+	registry.Register(mail)
+	a := alert.New(registry, slog.Default(),
+		alert.Target{Channel: "telegram", To: "42"},
+		alert.Target{Channel: "email", To: "ops@example.com"},
+	)
+
+	alert.SetDefault(a)
+	defer alert.SetDefault(nil)
+	alert.Alert(alert.Critical, "токен отозван")
+	alert.Alert(alert.Critical, "токен отозван")
+	alert.Alert(alert.Warning, "очередь заполнена")
+	a.Wait()
+
+	if len(tg.sent) != 2 || len(mail.sent) != 2 {
+		t.Fatalf("повтор оповещения должен подавляться: telegram %d, email %d", len(tg.sent), len(mail.sent))
+	}
+	for _, msg := range tg.sent {
+		if msg.To != "42" || msg.Priority != notify.PriorityHigh || msg.Subject == "" {
+			t.Fatalf("неверное оповещение: %+v", msg)
+		}
+	}
+
+	err := a.Send(context.Background(), alert.Info, "канал восстановился")
+	if err == nil || len(tg.sent) != 3 {
+		t.Fatalf("Send должен отправить без подавления и вернуть ошибку почты, получено %v", err)
+	}
+
+	alert.SetDefault(nil)
+	alert.Alert(alert.Critical, "без администратора")
+	a.Wait()
+	if len(tg.sent) != 3 {
+		t.Fatalf("без SetDefault оповещения не отправляются")
+	}
+}
@@ -0,0 +1,124 @@
+// Package attachment описывает вложения сообщений и кэширует их закодированное представление.
+//
+// Когда одно и то же вложение уходит десяткам тысяч получателей, кодировать его в base64
+// для каждого письма заново — пустая трата CPU. Encode делает это один раз, а Cache
+// переиспользует результат между вызовами по хэшу содержимого.
+package attachment
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"sync"
+)
+
+// File — вложение сообщения.
+type File struct {
+	Name        string // Имя файла, которое увидит получатель
+	ContentType string // MIME-тип; пустой — application/octet-stream
+	Data        []byte // Содержимое
+}
+
+// Hash возвращает SHA-256 содержимого в hex — ключ для переиспользования вложения.
+func (f File) Hash() string {
+	sum := sha256.Sum256(f.Data)
+	return hex.EncodeToString(sum[:])
+}
+
+// MediaType возвращает MIME-тип вложения.
+func (f File) MediaType() string {
+	if f.ContentType == "" {
+		return "application/octet-stream"
+	}
+	return f.ContentType
+}
+
+// Encoded — вложение, уже закодированное в base64 для MIME. Неизменяемо и безопасно
+// для одновременного использования в нескольких письмах.
+type Encoded struct {
+	File
+	hash   string
+	base64 []byte
+}
+
+// lineLen — максимальная длина строки base64 в MIME (RFC 2045).
+const lineLen = 76
+
+// Encode кодирует вложение в base64 со строками по 76 символов, разделёнными CRLF.
+func Encode(f File) *Encoded {
+	return encode(f, f.Hash())
+}
+
+func encode(f File, hash string) *Encoded {
+	raw := base64.StdEncoding.EncodeToString(f.Data)
+
+	out := make([]byte, 0, len(raw)+len(raw)/lineLen*2+2)
+	for len(raw) > lineLen {
+		out = append(out, raw[:lineLen]...)
+		out = append(out, '\r', '\n')
+		raw = raw[lineLen:]
+	}
+	out = append(out, raw...)
+	out = append(out, '\r', '\n')
+
+	return &Encoded{File: f, hash: hash, base64: out}
+}
+
+// Hash возвращает хэш содержимого вложения.
+func (e *Encoded) Hash() string {
+	return e.hash
+}
+
+// Base64 возвращает закодированное содержимое. Срез нельзя изменять.
+func (e *Encoded) Base64() []byte {
+	return e.base64
+}
+
+// Cache хранит закодированные вложения по хэшу содержимого.
+//
+// Размер ограничен числом записей: при переполнении вытесняется самая давняя.
+type Cache struct {
+	mu      sync.Mutex
+	max     int
+	entries map[string]*Encoded
+	order   []string // Хэши в порядке добавления
+}
+
+// NewCache создаёт кэш на max вложений.
+func NewCache(max int) *Cache {
+	return &Cache{max: max, entries: make(map[string]*Encoded)}
+}
+
+// Encode возвращает закодированное вложение из кэша или кодирует его и кладёт в кэш.
+func (c *Cache) Encode(f File) *Encoded {
+	hash := f.Hash()
+
+	c.mu.Lock()
+	if e, ok := c.entries[hash]; ok && e.Name == f.Name && e.ContentType == f.ContentType {
+		c.mu.Unlock()
+		return e
+	}
+	c.mu.Unlock()
+
+	// Кодируем вне блокировки: большие вложения не должны задерживать остальных
+	e := encode(f, hash)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.entries[hash]; !ok {
+		c.order = append(c.order, hash)
+	}
+	c.entries[hash] = e
+	for len(c.order) > c.max {
+		delete(c.entries, c.order[0])
+		c.order = c.order[1:]
+	}
+	return e
+}
+
+// Len возвращает число вложений в кэше.
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.entries)
+}
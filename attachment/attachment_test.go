@@ -0,0 +1,94 @@
+package attachment_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/epheer/notephee/attachment"
+)
+
+func TestEncodeWrapsLines(t *testing.T) {
+	data := bytes.Repeat([]byte("x"), 200)
+	e := attachment.Encode(attachment.File{Name: "a.txt", Data: data})
+
+	lines := bytes.Split(bytes.TrimSuffix(e.Base64(), []byte("\r\n")), []byte("\r\n"))
+	for _, line := range lines {
+		if len(line) > 76 {
+			t.Fatalf("строка base64 длиннее 76 символов: %d", len(line))
+		}
+	}
+	decoded, err := base64.StdEncoding.DecodeString(string(bytes.Join(lines, nil)))
+	if err != nil || !bytes.Equal(decoded, data) {
+		t.Fatal("закодированное вложение не совпадает с исходным")
+	}
+}
+
+func TestCacheReusesEncoding(t *testing.T) {
+	c := attachment.NewCache(2)
+	f := attachment.File{Name: "logo.png", ContentType: "image/png", Data: []byte("png")}
+
+	if c.Encode(f) != c.Encode(f) {
+		t.Fatal("одинаковое вложение должно кодироваться один раз")
+	}
+
+	c.Encode(attachment.File{Name: "b", Data: []byte("b")})
+	c.Encode(attachment.File{Name: "c", Data: []byte("c")})
+	if c.Len() != 2 {
+		t.Fatalf("кэш должен быть ограничен 2 записями, получено %d", c.Len())
+	}
+}
+
+func TestSniff(t *testing.T) {
+	cases := map[string]attachment.File{
+		"image/png":                 {Name: "logo", Data: []byte("\x89PNG\r\n\x1a\n....")},
+		"application/pdf":           {Name: "report.bin", Data: []byte("%PDF-1.4")},
+		"text/csv; charset=utf-8":   {Name: "users.csv", Data: []byte("id,name\n1,Анна\n")},
+		"text/plain; charset=utf-8": {Name: "notes", Data: []byte("заметки")},
+		"image/jpeg":                {Name: "photo.png", ContentType: "image/jpeg", Data: []byte("\x89PNG")},
+	}
+	for want, f := range cases {
+		if got := f.Sniff().ContentType; got != want {
+			t.Errorf("%s: ожидался тип %s, получено %s", f.Name, want, got)
+		}
+	}
+}
+
+func TestPolicyApply(t *testing.T) {
+	f := attachment.File{Name: "log.txt", Data: bytes.Repeat([]byte("строка журнала\n"), 100)}
+	size := int64(len(f.Data))
+
+	if files, err := (attachment.Policy{MaxSize: size}).Apply(f); err != nil || len(files) != 1 || files[0].ContentType == "" {
+		t.Fatalf("файл в пределах лимита должен пройти с определённым типом: %v %v", files, err)
+	}
+
+	_, err := attachment.Policy{MaxSize: 100}.Apply(f)
+	var sizeErr *attachment.SizeError
+	if !errors.Is(err, attachment.ErrTooLarge) || !errors.As(err, &sizeErr) || sizeErr.Size != size {
+		t.Fatalf("ожидалась ошибка размера, получено %v", err)
+	}
+
+	files, err := attachment.Policy{MaxSize: 100, Oversize: attachment.Compress}.Apply(f)
+	if err != nil || len(files) != 1 || files[0].Name != "log.txt.gz" {
+		t.Fatalf("ожидался сжатый файл, получено %v %v", files, err)
+	}
+	zr, _ := gzip.NewReader(bytes.NewReader(files[0].Data))
+	if data, _ := io.ReadAll(zr); !bytes.Equal(data, f.Data) {
+		t.Fatal("сжатый файл не совпадает с исходным")
+	}
+
+	files, err = attachment.Policy{MaxSize: 1000, Oversize: attachment.Split}.Apply(f)
+	if err != nil || len(files) != int((size+999)/1000) || files[1].Name != "log.txt.002" {
+		t.Fatalf("неверное разбиение на части: %d %v", len(files), err)
+	}
+	var joined []byte
+	for _, p := range files {
+		joined = append(joined, p.Data...)
+	}
+	if !bytes.Equal(joined, f.Data) {
+		t.Fatal("части не складываются в исходный файл")
+	}
+}
@@ -0,0 +1,118 @@
+package attachment
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+)
+
+// ErrTooLarge — файл или письмо больше предела канала. Конкретный размер и предел — в *SizeError.
+var ErrTooLarge = errors.New("превышен допустимый размер")
+
+// SizeError — файл Name размером Size больше предела Limit. errors.Is(err, ErrTooLarge) истинно.
+type SizeError struct {
+	Name  string // Имя файла
+	Size  int64  // Размер, байт
+	Limit int64  // Предел, байт
+}
+
+// Error возвращает текст ошибки с размером и пределом.
+func (e *SizeError) Error() string {
+	return fmt.Sprintf("%s: %s занимает %d байт при пределе %d", ErrTooLarge, e.Name, e.Size, e.Limit)
+}
+
+// Is сопоставляет ошибку с ErrTooLarge.
+func (e *SizeError) Is(target error) bool {
+	return target == ErrTooLarge
+}
+
+// Sniff возвращает файл с заполненным ContentType, если он пуст. Тип определяется по содержимому
+// (http.DetectContentType), а для общих типов вроде zip-архива или текста уточняется по расширению имени:
+// документ .docx — тоже zip-архив, а .csv — текст.
+func (f File) Sniff() File {
+	if f.ContentType != "" {
+		return f
+	}
+	sniffed := http.DetectContentType(f.Data)
+	byExt := mime.TypeByExtension(strings.ToLower(filepath.Ext(f.Name)))
+	switch base, _, _ := strings.Cut(sniffed, ";"); base {
+	case "application/octet-stream", "application/zip", "text/plain", "text/xml", "application/x-gzip":
+		if byExt != "" {
+			sniffed = byExt
+		}
+	}
+	f.ContentType = sniffed
+	return f
+}
+
+// Action — что делать с файлом больше предела.
+type Action string
+
+const (
+	Reject   Action = "reject"   // Вернуть *SizeError
+	Compress Action = "compress" // Сжать gzip в имя.gz; если и так не влезает — *SizeError
+	Split    Action = "split"    // Разбить на части имя.001, имя.002 и т.д. не больше предела
+)
+
+// ParseAction разбирает имя действия. Пустая строка даёт пустое действие (Reject).
+func ParseAction(s string) (Action, error) {
+	switch a := Action(strings.ToLower(strings.TrimSpace(s))); a {
+	case "", Reject, Compress, Split:
+		return a, nil
+	default:
+		return "", fmt.Errorf("неизвестное действие для больших вложений: %q", s)
+	}
+}
+
+// Policy — предел размера вложения и действие при его превышении.
+type Policy struct {
+	MaxSize  int64  // Предел размера файла, байт; 0 — без ограничения
+	Oversize Action // Действие для файлов больше MaxSize; по умолчанию Reject
+}
+
+// Apply определяет тип файла (Sniff) и проверяет его размер. Файл в пределах MaxSize возвращается
+// как есть, больший — сжатым, разбитым на части или с ошибкой *SizeError по Oversize.
+func (p Policy) Apply(f File) ([]File, error) {
+	f = f.Sniff()
+	size := int64(len(f.Data))
+	if p.MaxSize <= 0 || size <= p.MaxSize {
+		return []File{f}, nil
+	}
+
+	switch p.Oversize {
+	case Compress:
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		zw.Name = f.Name
+		if _, err := zw.Write(f.Data); err != nil {
+			return nil, err
+		}
+		if err := zw.Close(); err != nil {
+			return nil, err
+		}
+		gz := File{Name: f.Name + ".gz", ContentType: "application/gzip", Data: buf.Bytes()}
+		if int64(len(gz.Data)) > p.MaxSize {
+			return nil, &SizeError{Name: gz.Name, Size: int64(len(gz.Data)), Limit: p.MaxSize}
+		}
+		return []File{gz}, nil
+	case Split:
+		parts := make([]File, 0, (size+p.MaxSize-1)/p.MaxSize)
+		for i, data := 1, f.Data; len(data) > 0; i++ {
+			n := min(int64(len(data)), p.MaxSize)
+			parts = append(parts, File{
+				Name:        fmt.Sprintf("%s.%03d", f.Name, i),
+				ContentType: "application/octet-stream",
+				Data:        data[:n],
+			})
+			data = data[n:]
+		}
+		return parts, nil
+	default:
+		return nil, &SizeError{Name: f.Name, Size: size, Limit: p.MaxSize}
+	}
+}
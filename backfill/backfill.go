@@ -0,0 +1,394 @@
+// Package backfill загружает историю отправок из прежней системы рассылок в журнал доставки
+// и список подавления, чтобы после перехода на notephee история пользователей, отчёты и подавление
+// отписавшихся и недоставляемых адресов продолжались без разрыва.
+//
+// Источник — CSV с заголовком или JSON (массив объектов либо объекты подряд, по одному в строке).
+// Повторный импорт того же файла не создаёт дублей: ID записи выводится из ID или содержимого строки.
+package backfill
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/epheer/notephee/delivery"
+	"github.com/epheer/notephee/suppression"
+)
+
+// Format — формат файла с историей.
+type Format string
+
+const (
+	CSV  Format = "csv"  // CSV с заголовком
+	JSON Format = "json" // Массив объектов или объекты по одному в строке
+)
+
+// ParseFormat разбирает название формата.
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(strings.ToLower(s)); f {
+	case CSV, JSON:
+		return f, nil
+	default:
+		return "", fmt.Errorf("неизвестный формат истории: %q", s)
+	}
+}
+
+// Поля записи, которые можно сопоставить колонкам источника через Options.Columns.
+const (
+	FieldID          = "id"           // ID отправки в прежней системе
+	FieldChannel     = "channel"      // Канал: telegram, email и т.д.
+	FieldUserID      = "user_id"      // Внутренний ID пользователя
+	FieldRecipient   = "recipient"    // Адрес получателя
+	FieldSubject     = "subject"      // Тема; используется для хэша, если нет message_hash
+	FieldText        = "text"         // Текст; используется для хэша, если нет message_hash
+	FieldMessageHash = "message_hash" // Готовый хэш сообщения
+	FieldStatus      = "status"       // Статус в прежней системе
+	FieldError       = "error"        // Текст ошибки
+	FieldCreatedAt   = "created_at"   // Время отправки
+	FieldCompletedAt = "completed_at" // Время ответа провайдера
+)
+
+// DefaultStatuses сопоставляет распространённые статусы прежних систем статусам журнала доставки.
+var DefaultStatuses = map[string]delivery.Status{
+	"sent":          delivery.StatusSent,
+	"delivered":     delivery.StatusSent,
+	"ok":            delivery.StatusSent,
+	"success":       delivery.StatusSent,
+	"opened":        delivery.StatusSent,
+	"complained":    delivery.StatusSent,
+	"complaint":     delivery.StatusSent,
+	"spam":          delivery.StatusSent,
+	"unsubscribed":  delivery.StatusSent,
+	"failed":        delivery.StatusFailed,
+	"error":         delivery.StatusFailed,
+	"bounced":       delivery.StatusFailed,
+	"rejected":      delivery.StatusFailed,
+	"indeterminate": delivery.StatusIndeterminate,
+	"timeout":       delivery.StatusIndeterminate,
+	"unknown":       delivery.StatusIndeterminate,
+}
+
+// DefaultSuppress — статусы прежних систем, после которых адрес попадает в список подавления.
+var DefaultSuppress = map[string]suppression.Reason{
+	"bounced":      suppression.ReasonBounce,
+	"complained":   suppression.ReasonComplaint,
+	"complaint":    suppression.ReasonComplaint,
+	"spam":         suppression.ReasonComplaint,
+	"unsubscribed": suppression.ReasonUnsubscribe,
+}
+
+// namespace — пространство имён UUID для ID импортированных записей.
+var namespace = uuid.MustParse("5b0c1f0e-2a57-4c43-9d0e-8a3b6f1d9e21")
+
+// Options — настройки импорта. Нулевые значения заменяются значениями по умолчанию.
+type Options struct {
+	Format     Format                        // Формат источника; по умолчанию CSV
+	Columns    map[string]string             // Поле (Field*) → имя колонки источника, если они различаются
+	Statuses   map[string]delivery.Status    // Статус источника → статус журнала; по умолчанию DefaultStatuses
+	Suppress   map[string]suppression.Reason // Статус источника → причина подавления; по умолчанию DefaultSuppress
+	Channel    string                        // Канал для строк без колонки channel (необязательно)
+	TimeLayout string                        // Формат времени; по умолчанию RFC 3339, также принимается Unix-время в секундах
+	DryRun     bool                          // Только проверить файл, ничего не записывая
+}
+
+// RowError — ошибка в строке источника. Такие строки пропускаются, импорт продолжается.
+type RowError struct {
+	Row int   // Номер записи в источнике, начиная с 1 (без строки заголовка CSV)
+	Err error // Причина
+}
+
+func (e RowError) Error() string {
+	return fmt.Sprintf("запись %d: %v", e.Row, e.Err)
+}
+
+func (e RowError) Unwrap() error {
+	return e.Err
+}
+
+// Result — итог импорта.
+type Result struct {
+	Read       int        // Прочитано записей
+	Imported   int        // Записано в журнал доставки
+	Skipped    int        // Уже были в журнале после предыдущего импорта
+	Suppressed int        // Адресов добавлено в список подавления
+	Errors     []RowError // Пропущенные из-за ошибок записи
+}
+
+// Importer загружает историю в журнал доставки и, если подключён, в список подавления.
+type Importer struct {
+	log      delivery.DeliveryLog // Журнал доставки
+	suppress suppression.Store    // Список подавления (необязательно)
+	logger   *slog.Logger         // Логгер
+}
+
+// New создаёт Importer, записывающий историю в log.
+func New(log delivery.DeliveryLog, logger *slog.Logger) *Importer {
+	return &Importer{log: log, logger: logger}
+}
+
+// SetSuppression подключает список подавления: адреса со статусами из Options.Suppress
+// (отписки, жалобы, недоставляемые адреса) добавляются в него с временем исходной отправки.
+func (im *Importer) SetSuppression(store suppression.Store) {
+	im.suppress = store
+}
+
+// Import читает историю из r и записывает её. Ошибки в отдельных записях не прерывают импорт
+// и возвращаются в Result.Errors; ошибка возвращается, если источник не читается или журнал
+// доставки недоступен.
+func (im *Importer) Import(ctx context.Context, r io.Reader, opts Options) (Result, error) {
+	opts.defaults()
+
+	var res Result
+	err := read(r, opts.Format, func(row map[string]string) error {
+		res.Read++
+		rec, reason, err := opts.record(row)
+		if err != nil {
+			res.Errors = append(res.Errors, RowError{Row: res.Read, Err: err})
+			return nil
+		}
+		if opts.DryRun {
+			res.Imported++
+			return nil
+		}
+		return im.save(ctx, rec, reason, &res)
+	})
+	if err != nil {
+		return res, err
+	}
+
+	im.logger.Info("история отправок импортирована", "read", res.Read, "imported", res.Imported,
+		"skipped", res.Skipped, "suppressed", res.Suppressed, "errors", len(res.Errors), "dry_run", opts.DryRun)
+	return res, nil
+}
+
+// save записывает запись, если её ещё нет в журнале, и при необходимости подавляет адрес.
+func (im *Importer) save(ctx context.Context, rec delivery.Record, reason suppression.Reason, res *Result) error {
+	_, err := im.log.Get(ctx, rec.ID)
+	switch {
+	case err == nil:
+		// Адрес тоже уже обработан: повторное подавление отменило бы снятую после импорта блокировку
+		res.Skipped++
+		return nil
+	case errors.Is(err, delivery.ErrNotFound):
+		if err := im.log.Save(ctx, rec); err != nil {
+			return fmt.Errorf("не удалось сохранить запись %s: %w", rec.ID, err)
+		}
+		res.Imported++
+	default:
+		return fmt.Errorf("не удалось проверить запись %s: %w", rec.ID, err)
+	}
+
+	if reason == "" || im.suppress == nil {
+		return nil
+	}
+	if err := im.suppress.Suppress(ctx, suppression.Entry{
+		Channel:   rec.Channel,
+		Address:   rec.Recipient,
+		Reason:    reason,
+		CreatedAt: rec.CreatedAt,
+	}); err != nil {
+		return fmt.Errorf("не удалось добавить %s в список подавления: %w", rec.Recipient, err)
+	}
+	res.Suppressed++
+	return nil
+}
+
+func (o *Options) defaults() {
+	if o.Format == "" {
+		o.Format = CSV
+	}
+	if o.Statuses == nil {
+		o.Statuses = DefaultStatuses
+	}
+	if o.Suppress == nil {
+		o.Suppress = DefaultSuppress
+	}
+	if o.TimeLayout == "" {
+		o.TimeLayout = time.RFC3339
+	}
+}
+
+// field возвращает значение поля name из строки источника с учётом Columns.
+func (o *Options) field(row map[string]string, name string) string {
+	if col, ok := o.Columns[name]; ok {
+		name = col
+	}
+	return strings.TrimSpace(row[name])
+}
+
+// record собирает запись журнала из строки источника.
+func (o *Options) record(row map[string]string) (delivery.Record, suppression.Reason, error) {
+	rec := delivery.Record{
+		Channel:     o.field(row, FieldChannel),
+		UserID:      o.field(row, FieldUserID),
+		Recipient:   o.field(row, FieldRecipient),
+		MessageHash: o.field(row, FieldMessageHash),
+		Error:       o.field(row, FieldError),
+	}
+	if rec.Channel == "" {
+		rec.Channel = o.Channel
+	}
+	if rec.Channel == "" || rec.Recipient == "" {
+		return rec, "", errors.New("не заданы канал или получатель")
+	}
+
+	raw := strings.ToLower(o.field(row, FieldStatus))
+	status, ok := o.Statuses[raw]
+	if !ok {
+		return rec, "", fmt.Errorf("неизвестный статус %q", raw)
+	}
+	rec.Status = status
+
+	var err error
+	if rec.CreatedAt, err = o.parseTime(o.field(row, FieldCreatedAt)); err != nil || rec.CreatedAt.IsZero() {
+		return rec, "", fmt.Errorf("некорректное время отправки %q", o.field(row, FieldCreatedAt))
+	}
+	if rec.CompletedAt, err = o.parseTime(o.field(row, FieldCompletedAt)); err != nil {
+		return rec, "", fmt.Errorf("некорректное время ответа %q", o.field(row, FieldCompletedAt))
+	}
+	if rec.CompletedAt.IsZero() {
+		rec.CompletedAt = rec.CreatedAt
+	}
+
+	if rec.MessageHash == "" {
+		subject, text := o.field(row, FieldSubject), o.field(row, FieldText)
+		switch {
+		case subject != "":
+			rec.MessageHash = delivery.Hash(subject, text)
+		case text != "":
+			rec.MessageHash = delivery.Hash(text)
+		}
+	}
+
+	// ID источника может быть длиннее ID журнала или совпасть с ID другой системы,
+	// поэтому он, как и содержимое строки без ID, превращается в UUID
+	key := o.field(row, FieldID)
+	if key == "" {
+		key = delivery.Hash(rec.Channel, rec.Recipient, rec.CreatedAt.UTC().Format(time.RFC3339Nano), rec.MessageHash, raw)
+	}
+	rec.ID = uuid.NewSHA1(namespace, []byte(key)).String()
+
+	return rec, o.Suppress[raw], nil
+}
+
+// parseTime разбирает время в формате TimeLayout или Unix-время в секундах. Пустая строка — нулевое время.
+func (o *Options) parseTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(o.TimeLayout, s); err == nil {
+		return t, nil
+	}
+	sec, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("некорректное время %q", s)
+	}
+	return time.Unix(sec, 0).UTC(), nil
+}
+
+// read вызывает fn для каждой записи источника. Ошибка fn прерывает чтение.
+func read(r io.Reader, format Format, fn func(map[string]string) error) error {
+	switch format {
+	case CSV:
+		return readCSV(r, fn)
+	case JSON:
+		return readJSON(r, fn)
+	default:
+		return fmt.Errorf("неизвестный формат истории: %q", format)
+	}
+}
+
+func readCSV(r io.Reader, fn func(map[string]string) error) error {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	header, err := cr.Read()
+	if errors.Is(err, io.EOF) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("не удалось прочитать заголовок CSV: %w", err)
+	}
+	for i := range header {
+		header[i] = strings.TrimSpace(strings.TrimPrefix(header[i], "\ufeff"))
+	}
+
+	for {
+		cols, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("не удалось прочитать CSV: %w", err)
+		}
+		row := make(map[string]string, len(header))
+		for i, name := range header {
+			if i < len(cols) {
+				row[name] = cols[i]
+			}
+		}
+		if err := fn(row); err != nil {
+			return err
+		}
+	}
+}
+
+func readJSON(r io.Reader, fn func(map[string]string) error) error {
+	br := bufio.NewReader(r)
+	dec := json.NewDecoder(br)
+	dec.UseNumber()
+
+	// Массив читается поэлементно, чтобы не держать в памяти весь файл
+	array := false
+	if b, err := peekNonSpace(br); err == nil && b == '[' {
+		if _, err := dec.Token(); err != nil {
+			return fmt.Errorf("не удалось прочитать JSON: %w", err)
+		}
+		array = true
+	}
+
+	for array && dec.More() || !array {
+		var obj map[string]any
+		if err := dec.Decode(&obj); err != nil {
+			if !array && errors.Is(err, io.EOF) {
+				return nil
+			}
+			return fmt.Errorf("не удалось прочитать JSON: %w", err)
+		}
+		row := make(map[string]string, len(obj))
+		for k, v := range obj {
+			if v != nil {
+				row[k] = fmt.Sprint(v)
+			}
+		}
+		if err := fn(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// peekNonSpace возвращает первый непробельный байт, не извлекая его из r.
+func peekNonSpace(r *bufio.Reader) (byte, error) {
+	for {
+		b, err := r.Peek(1)
+		if err != nil {
+			return 0, err
+		}
+		switch b[0] {
+		case ' ', '\t', '\r', '\n':
+			_, _ = r.ReadByte()
+		default:
+			return b[0], nil
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package backfill_test
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/epheer/notephee/backfill"
+	"github.com/epheer/notephee/delivery"
+	"github.com/epheer/notephee/suppression"
+)
+
+const history = `msg_id,channel,user_id,email,status,error,sent_at
+old-1,email,u1,a@example.com,delivered,,2024-05-01T10:00:00Z
+old-2,email,u1,b@example.com,bounced,550 user unknown,2024-05-02T10:00:00Z
+old-3,email,u2,c@example.com,queued,,2024-05-03T10:00:00Z
+`
+
+func TestImportCSV(t *testing.T) {
+	log := delivery.NewMemoryLog()
+	suppressed := suppression.NewMemoryStore()
+	im := backfill.New(log, slog.Default())
+	im.SetSuppression(suppressed)
+
+	opts := backfill.Options{Columns: map[string]string{
+		backfill.FieldID:        "msg_id",
+		backfill.FieldRecipient: "email",
+		backfill.FieldCreatedAt: "sent_at",
+	}}
+	res, err := im.Import(context.Background(), strings.NewReader(history), opts)
+	if err != nil {
+		t.Fatalf("Ошибка Import: %v", err)
+	}
+	if res.Read != 3 || res.Imported != 2 || res.Suppressed != 1 || len(res.Errors) != 1 || res.Errors[0].Row != 3 {
+		t.Fatalf("неожиданный итог импорта: %+v", res)
+	}
+
+	recs, _ := log.History(context.Background(), "u1")
+	if len(recs) != 2 || recs[1].Status != delivery.StatusFailed || recs[1].Error != "550 user unknown" {
+		t.Fatalf("неожиданная история: %+v", recs)
+	}
+	if want := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC); !recs[0].CreatedAt.Equal(want) {
+		t.Fatalf("неверное время отправки: %v", recs[0].CreatedAt)
+	}
+	if ok, _ := suppressed.IsSuppressed(context.Background(), "email", "b@example.com", ""); !ok {
+		t.Fatal("недоставляемый адрес должен попасть в список подавления")
+	}
+
+	// Повторный импорт того же файла не создаёт дублей
+	res, err = im.Import(context.Background(), strings.NewReader(history), opts)
+	if err != nil {
+		t.Fatalf("Ошибка Import: %v", err)
+	}
+	if res.Imported != 0 || res.Skipped != 2 {
+		t.Fatalf("повторный импорт должен пропустить записи: %+v", res)
+	}
+}
+
+func TestImportJSON(t *testing.T) {
+	log := delivery.NewMemoryLog()
+	im := backfill.New(log, slog.Default())
+
+	for _, src := range []string{
+		`[{"recipient":123,"user_id":"u1","status":"sent","created_at":1714557600,"text":"привет"}]`,
+		`{"recipient":123,"user_id":"u1","status":"sent","created_at":1714557600,"text":"привет"}
+{"recipient":456,"user_id":"u1","status":"failed","created_at":1714557600}`,
+	} {
+		if _, err := im.Import(context.Background(), strings.NewReader(src), backfill.Options{Format: backfill.JSON, Channel: "telegram"}); err != nil {
+			t.Fatalf("Ошибка Import: %v", err)
+		}
+	}
+
+	recs, _ := log.History(context.Background(), "u1")
+	if len(recs) != 2 {
+		t.Fatalf("одинаковые записи без ID не должны дублироваться: %+v", recs)
+	}
+	if recs[0].Recipient != "123" || recs[0].Channel != "telegram" || recs[0].MessageHash != delivery.Hash("привет") {
+		t.Fatalf("неожиданная запись: %+v", recs[0])
+	}
+	if !recs[0].CreatedAt.Equal(time.Unix(1714557600, 0)) {
+		t.Fatalf("неверное время отправки: %v", recs[0].CreatedAt)
+	}
+}
@@ -0,0 +1,168 @@
+package broadcast_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/epheer/notephee/broadcast"
+	"github.com/epheer/notephee/notify"
+)
+
+// countingSender запоминает получателей и отменяет контекст после stopAfter отправок.
+type countingSender struct {
+	sent      []string
+	stopAfter int
+	cancel    context.CancelFunc
+}
+
+func (s *countingSender) Channel() string { return "fake" }
+
+func (s *countingSender) Send(_ context.Context, msg notify.Message) error {
+	s.sent = append(s.sent, msg.To)
+	if len(s.sent) == s.stopAfter && s.cancel != nil {
+		s.cancel()
+	}
+	return nil
+}
+
+func TestResumeAfterInterruption(t *testing.T) {
+	store, err := broadcast.NewFileJobStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("Ошибка NewFileJobStore: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	sender := &countingSender{stopAfter: 2, cancel: cancel}
+	registry := notify.NewRegistry()
+	registry.Register(sender)
+	runner := broadcast.NewRunner(registry, store, slog.Default())
+
+	job := broadcast.NewJob("fake", []string{"a", "b", "c", "d", "e"}, "", "привет", "")
+	if err := runner.Start(ctx, job); !errors.Is(err, context.Canceled) {
+		t.Fatalf("ожидалась ошибка отмены, получено %v", err)
+	}
+
+	saved, _ := store.Load(context.Background(), job.ID)
+	if saved.Cursor != 2 || saved.Status != broadcast.StatusPaused {
+		t.Fatalf("неверный сохранённый курсор: %+v", saved)
+	}
+
+	if err := runner.ResumeAll(context.Background()); err != nil {
+		t.Fatalf("Ошибка ResumeAll: %v", err)
+	}
+	if len(sender.sent) != 5 || sender.sent[2] != "c" {
+		t.Fatalf("получатели обработаны с повторами или пропусками: %v", sender.sent)
+	}
+
+	unfinished, _ := store.Unfinished(context.Background())
+	if len(unfinished) != 0 {
+		t.Fatalf("остались незавершённые задания: %d", len(unfinished))
+	}
+}
+
+func TestFileJobStoreFailures(t *testing.T) {
+	ctx := context.Background()
+	store, err := broadcast.NewFileJobStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("Ошибка NewFileJobStore: %v", err)
+	}
+	job := broadcast.NewJob("fake", []string{"a", "b", "c", "d"}, "", "привет", "")
+	if err := store.Checkpoint(ctx, job, nil); !errors.Is(err, broadcast.ErrJobNotFound) {
+		t.Fatalf("ожидалась ErrJobNotFound, получено %v", err)
+	}
+	if err := store.Save(ctx, job); err != nil {
+		t.Fatalf("Ошибка Save: %v", err)
+	}
+
+	job.Cursor = 2
+	_ = store.Checkpoint(ctx, job, []broadcast.Failure{{Position: 0, Recipient: "a"}, {Position: 1, Recipient: "b"}})
+	// Ошибка за курсором — как после падения между дописыванием ошибок и сохранением курсора
+	_ = store.Checkpoint(ctx, job, []broadcast.Failure{{Position: 2, Recipient: "c", Error: "первая"}})
+	saved, _ := store.Load(ctx, job.ID)
+	if len(saved.Recipients) != 4 || len(saved.Failures) != 2 {
+		t.Fatalf("ошибка за курсором не отброшена: %+v", saved)
+	}
+
+	job.Cursor = 3
+	_ = store.Checkpoint(ctx, job, []broadcast.Failure{{Position: 2, Recipient: "c", Error: "вторая"}})
+	saved, _ = store.Load(ctx, job.ID)
+	if len(saved.Failures) != 3 || saved.Failures[2].Error != "вторая" {
+		t.Fatalf("повтор ошибки получателя не заменил прежнюю: %+v", saved.Failures)
+	}
+}
+
+// failingSender возвращает ошибку для получателей из errs и запоминает остальные сообщения.
+type failingSender struct {
+	channel string
+	errs    map[string]error
+	sent    []notify.Message
+}
+
+func (s *failingSender) Channel() string { return s.channel }
+
+func (s *failingSender) Send(_ context.Context, msg notify.Message) error {
+	if err := s.errs[msg.To]; err != nil {
+		return err
+	}
+	s.sent = append(s.sent, msg)
+	return nil
+}
+
+func TestReport(t *testing.T) {
+	sender := &failingSender{channel: "fake", errs: map[string]error{
+		"b": notify.ErrMuted,
+		"d": context.DeadlineExceeded,
+		"e": errors.New("неизвестная ошибка"),
+	}}
+	admin := &failingSender{channel: "admin"}
+	registry := notify.NewRegistry()
+	registry.Register(sender)
+	registry.Register(admin)
+	runner := broadcast.NewRunner(registry, broadcast.NewMemoryJobStore(), slog.Default())
+	runner.SetReportTarget("admin", "42")
+
+	job := broadcast.NewJob("fake", []string{"a", "b", "c", "d", "e"}, "", "привет", "весна")
+	if err := runner.Start(context.Background(), job); err != nil {
+		t.Fatalf("Ошибка Start: %v", err)
+	}
+
+	report := job.Report()
+	if report.Sent != 2 || report.Failed != 3 || report.Pending != 0 || report.Status != broadcast.StatusCompleted {
+		t.Fatalf("неверные итоги: %+v", report)
+	}
+	want := map[string]int{broadcast.KindMuted: 1, broadcast.KindTimeout: 1, broadcast.KindOther: 1}
+	for kind, n := range want {
+		if report.Errors[kind] != n {
+			t.Fatalf("неверная разбивка ошибок: %v", report.Errors)
+		}
+	}
+	if len(report.Failures) != 3 || report.Failures[0].Recipient != "b" || report.Failures[2].Recipient != "e" {
+		t.Fatalf("неверный список ошибок: %+v", report.Failures)
+	}
+
+	var buf bytes.Buffer
+	if err := report.WriteCSV(&buf); err != nil {
+		t.Fatalf("Ошибка WriteCSV: %v", err)
+	}
+	if lines := strings.Split(strings.TrimSpace(buf.String()), "\n"); len(lines) != 4 || lines[1] != "b,muted,"+notify.ErrMuted.Error() {
+		t.Fatalf("неверный CSV: %q", buf.String())
+	}
+
+	buf.Reset()
+	if err := report.WriteJSON(&buf); err != nil {
+		t.Fatalf("Ошибка WriteJSON: %v", err)
+	}
+	var decoded broadcast.Report
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil || decoded.Failed != 3 || decoded.Failures[1].Kind != broadcast.KindTimeout {
+		t.Fatalf("неверный JSON: %v %s", err, buf.String())
+	}
+
+	if len(admin.sent) != 1 || admin.sent[0].To != "42" || !strings.Contains(admin.sent[0].Text, "Отправлено 2 из 5, ошибок 3") {
+		t.Fatalf("отчёт администратору не отправлен: %+v", admin.sent)
+	}
+}
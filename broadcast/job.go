@@ -0,0 +1,76 @@
+package broadcast
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Status — состояние задания рассылки.
+type Status string
+
+// Состояния задания
+const (
+	StatusPending   Status = "pending"   // Создано, отправка не начиналась
+	StatusRunning   Status = "running"   // Идёт отправка (или процесс упал во время неё)
+	StatusPaused    Status = "paused"    // Остановлено штатно, можно продолжить
+	StatusCompleted Status = "completed" // Все получатели обработаны
+)
+
+// Job — задание массовой рассылки с сохраняемым курсором.
+//
+// Cursor указывает на первого необработанного получателя: после сбоя отправка
+// продолжается с него, а не с начала списка.
+type Job struct {
+	ID         string    // Идентификатор задания
+	Channel    string    // Канал отправки в notify.Registry
+	Recipients []string  // Адреса получателей в канале
+	Subject    string    // Тема (для каналов, в которых она есть)
+	Text       string    // Текст сообщения
+	Campaign   string    // Идентификатор рассылки
+	Cursor     int       // Число уже обработанных получателей с начала списка
+	Sent       int       // Успешно отправлено
+	Failed     int       // Завершилось ошибкой
+	Status     Status    // Состояние задания
+	CreatedAt  time.Time // Время создания
+	UpdatedAt  time.Time // Время последнего сохранения курсора
+
+	Failures []Failure      // Получатели, отправка которым завершилась ошибкой, по порядку
+	Errors   map[string]int // Число ошибок по видам (см. Kind)
+	Elapsed  time.Duration  // Время отправки без пауз между запусками
+}
+
+// Failure — неудачная отправка одному получателю.
+type Failure struct {
+	Position  int    `json:"position"`  // Номер получателя в Job.Recipients
+	Recipient string `json:"recipient"` // Адрес получателя
+	Kind      string `json:"kind"`      // Вид ошибки (см. Kind)
+	Error     string `json:"error"`     // Текст ошибки
+}
+
+// Done сообщает, обработаны ли все получатели.
+func (j *Job) Done() bool {
+	return j.Cursor >= len(j.Recipients)
+}
+
+// ErrJobNotFound возвращается, если задание отсутствует в хранилище.
+var ErrJobNotFound = errors.New("задание рассылки не найдено")
+
+// JobStore сохраняет задания рассылок между перезапусками процесса.
+//
+// Список получателей записывается один раз при Save, а чекпойнты сохраняют только курсор, счётчики
+// и новые ошибки, поэтому их стоимость не растёт с размером рассылки.
+//
+// Реализации должны быть безопасны для конкурентного использования.
+type JobStore interface {
+	// Save создаёт задание или заменяет его целиком, вместе с получателями и ошибками.
+	Save(ctx context.Context, job *Job) error
+	// Checkpoint сохраняет курсор, счётчики и состояние задания и дописывает ошибки failures,
+	// добавленные в job.Failures после предыдущего сохранения. Получатели не перезаписываются.
+	// Для отсутствующего задания возвращает ErrJobNotFound.
+	Checkpoint(ctx context.Context, job *Job, failures []Failure) error
+	// Load возвращает задание по идентификатору или ErrJobNotFound.
+	Load(ctx context.Context, id string) (*Job, error)
+	// Unfinished возвращает задания в состояниях pending, running и paused.
+	Unfinished(ctx context.Context) ([]*Job, error)
+}
@@ -0,0 +1,158 @@
+package broadcast
+
+import (
+	"cmp"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/epheer/notephee/delivery"
+	"github.com/epheer/notephee/notify"
+)
+
+// Виды ошибок, которые различает Kind.
+const (
+	KindTimeout       = "timeout"       // Истёк таймаут запроса
+	KindIndeterminate = "indeterminate" // Исход отправки неизвестен (delivery.ErrIndeterminate)
+	KindClosed        = "closed"        // Клиент канала закрыт
+	KindMuted         = "muted"         // Получатель отключил уведомления
+	KindNoAddress     = "no_address"    // У получателя нет адреса в канале
+	KindTemporary     = "temporary"     // Временная ошибка провайдера: повтор может пройти
+	KindPermanent     = "permanent"     // Постоянная ошибка провайдера
+	KindOther         = "other"         // Остальные ошибки
+)
+
+// Kind возвращает вид ошибки отправки для разбивки в отчёте. Ошибки каналов с методом Temporary
+// (например, email.SMTPError) делятся на временные и постоянные.
+func Kind(err error) string {
+	var netErr net.Error
+	var temp interface{ Temporary() bool }
+	switch {
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return KindTimeout
+	case delivery.IsIndeterminate(err):
+		return KindIndeterminate
+	case errors.Is(err, notify.ErrClosed):
+		return KindClosed
+	case errors.Is(err, notify.ErrMuted):
+		return KindMuted
+	case errors.Is(err, notify.ErrNoAddress):
+		return KindNoAddress
+	case errors.As(err, &temp):
+		if temp.Temporary() {
+			return KindTemporary
+		}
+		return KindPermanent
+	default:
+		return KindOther
+	}
+}
+
+// Report — итоги рассылки.
+type Report struct {
+	JobID      string         `json:"job_id"`
+	Channel    string         `json:"channel"`
+	Campaign   string         `json:"campaign,omitempty"`
+	Status     Status         `json:"status"`
+	Total      int            `json:"total"`      // Всего получателей
+	Sent       int            `json:"sent"`       // Успешно отправлено
+	Failed     int            `json:"failed"`     // Завершилось ошибкой
+	Pending    int            `json:"pending"`    // Ещё не обработано (у незавершённой рассылки)
+	Errors     map[string]int `json:"errors"`     // Ошибки по видам (см. Kind)
+	Duration   time.Duration  `json:"duration"`   // Время отправки без пауз, в наносекундах
+	Throughput float64        `json:"throughput"` // Обработано получателей в секунду
+	Failures   []Failure      `json:"failures"`   // Получатели с ошибками
+}
+
+// Report возвращает итоги рассылки на текущий момент.
+func (j *Job) Report() Report {
+	r := Report{
+		JobID:    j.ID,
+		Channel:  j.Channel,
+		Campaign: j.Campaign,
+		Status:   j.Status,
+		Total:    len(j.Recipients),
+		Sent:     j.Sent,
+		Failed:   j.Failed,
+		Pending:  len(j.Recipients) - j.Cursor,
+		Errors:   make(map[string]int, len(j.Errors)),
+		Duration: j.Elapsed,
+		Failures: append([]Failure{}, j.Failures...),
+	}
+	for kind, n := range j.Errors {
+		r.Errors[kind] = n
+	}
+	if j.Elapsed > 0 {
+		r.Throughput = float64(j.Sent+j.Failed) / j.Elapsed.Seconds()
+	}
+	return r
+}
+
+// WriteJSON записывает отчёт в w в формате JSON.
+func (r Report) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// WriteCSV записывает в w получателей с ошибками: столбцы recipient, kind и error с заголовком.
+// Итоги рассылки в CSV не попадают — они есть в WriteJSON и String.
+func (r Report) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	_ = cw.Write([]string{"recipient", "kind", "error"})
+	for _, f := range r.Failures {
+		_ = cw.Write([]string{f.Recipient, f.Kind, f.Error})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// String возвращает краткую сводку для отправки администратору.
+func (r Report) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Рассылка %s (%s): %s\n", r.JobID, r.Channel, r.Status)
+	if r.Campaign != "" {
+		fmt.Fprintf(&b, "Кампания: %s\n", r.Campaign)
+	}
+	fmt.Fprintf(&b, "Отправлено %d из %d, ошибок %d", r.Sent, r.Total, r.Failed)
+	if r.Pending > 0 {
+		fmt.Fprintf(&b, ", не обработано %d", r.Pending)
+	}
+	fmt.Fprintf(&b, "\nДлительность %s, %.1f в секунду\n", r.Duration.Round(time.Second), r.Throughput)
+	for _, kind := range sortedKinds(r.Errors) {
+		fmt.Fprintf(&b, "%s: %d\n", kind, r.Errors[kind])
+	}
+
+	// Список ошибок в сводке ограничен: полный — в WriteCSV
+	const maxListed = 20
+	for i, f := range r.Failures {
+		if i == maxListed {
+			fmt.Fprintf(&b, "… и ещё %d\n", len(r.Failures)-maxListed)
+			break
+		}
+		fmt.Fprintf(&b, "%s — %s\n", f.Recipient, f.Error)
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// sortedKinds возвращает виды ошибок по убыванию числа, при равенстве — по имени.
+func sortedKinds(errs map[string]int) []string {
+	kinds := make([]string, 0, len(errs))
+	for kind := range errs {
+		kinds = append(kinds, kind)
+	}
+	slices.SortFunc(kinds, func(a, b string) int {
+		if c := cmp.Compare(errs[b], errs[a]); c != 0 {
+			return c
+		}
+		return cmp.Compare(a, b)
+	})
+	return kinds
+}
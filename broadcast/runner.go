@@ -0,0 +1,214 @@
+package broadcast
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/epheer/notephee/notify"
+)
+
+// Runner выполняет задания рассылок, сохраняя курсор после каждой порции отправок.
+type Runner struct {
+	registry *notify.Registry // Каналы отправки
+	store    JobStore         // Хранилище заданий
+	logger   *slog.Logger     // Логгер
+
+	// CheckpointEvery — через сколько отправленных сообщений сохранять курсор.
+	// 1 (по умолчанию) означает, что после сбоя повторно отправится не более одного сообщения.
+	CheckpointEvery int
+
+	// Classify определяет вид ошибки для отчёта; nil — Kind.
+	Classify func(error) string
+
+	reportChannel string // Канал для отчётов о завершённых рассылках
+	reportTo      string // Адрес администратора в канале reportChannel
+}
+
+// NewRunner создаёт исполнителя заданий рассылки.
+func NewRunner(registry *notify.Registry, store JobStore, logger *slog.Logger) *Runner {
+	return &Runner{registry: registry, store: store, logger: logger, CheckpointEvery: 1}
+}
+
+// SetReportTarget включает отправку отчёта (Report.String) о каждой завершённой рассылке
+// на адрес to в канале channel: чат администратора в Telegram, почту и т.д. Пустой channel отключает отчёты.
+func (r *Runner) SetReportTarget(channel, to string) {
+	r.reportChannel = channel
+	r.reportTo = to
+}
+
+// NewJob создаёт задание рассылки со сгенерированным идентификатором.
+func NewJob(channel string, recipients []string, subject, text, campaign string) *Job {
+	now := time.Now()
+	return &Job{
+		ID:         uuid.New().String(),
+		Channel:    channel,
+		Recipients: recipients,
+		Subject:    subject,
+		Text:       text,
+		Campaign:   campaign,
+		Status:     StatusPending,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+}
+
+// Start сохраняет новое задание и выполняет его.
+func (r *Runner) Start(ctx context.Context, job *Job) error {
+	if err := r.store.Save(ctx, job); err != nil {
+		return err
+	}
+	return r.Run(ctx, job)
+}
+
+// Resume загружает задание по идентификатору и продолжает его с сохранённого курсора.
+func (r *Runner) Resume(ctx context.Context, id string) error {
+	job, err := r.store.Load(ctx, id)
+	if err != nil {
+		return err
+	}
+	return r.Run(ctx, job)
+}
+
+// ResumeAll продолжает все незавершённые задания по очереди. Удобно вызывать при старте процесса.
+func (r *Runner) ResumeAll(ctx context.Context) error {
+	jobs, err := r.store.Unfinished(ctx)
+	if err != nil {
+		return err
+	}
+	for _, job := range jobs {
+		r.logger.Info("возобновление рассылки", "job_id", job.ID, "cursor", job.Cursor, "total", len(job.Recipients))
+		if err := r.Run(ctx, job); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Run отправляет сообщения получателям начиная с job.Cursor.
+//
+// При отмене ctx курсор сохраняется, задание переводится в StatusPaused, возвращается ошибка контекста.
+func (r *Runner) Run(ctx context.Context, job *Job) error {
+	sender, err := r.registry.Get(job.Channel)
+	if err != nil {
+		return err
+	}
+
+	every := max(r.CheckpointEvery, 1)
+	// saved — сколько ошибок из job.Failures уже в хранилище; чекпойнт дописывает только новые
+	saved := len(job.Failures)
+	job.Status = StatusRunning
+	err = r.checkpoint(ctx, job, &saved)
+	if errors.Is(err, ErrJobNotFound) {
+		// Задание передано в Run без Start: сохраняем его целиком
+		job.UpdatedAt = time.Now()
+		err = r.store.Save(ctx, job)
+	}
+	if err != nil {
+		return err
+	}
+
+	// Elapsed копит время отправки между чекпойнтами, чтобы паузы между запусками не входили в отчёт
+	last := time.Now()
+	elapse := func() {
+		now := time.Now()
+		job.Elapsed += now.Sub(last)
+		last = now
+	}
+
+	sinceCheckpoint := 0
+	for !job.Done() {
+		if err := ctx.Err(); err != nil {
+			elapse()
+			job.Status = StatusPaused
+			if saveErr := r.checkpoint(context.WithoutCancel(ctx), job, &saved); saveErr != nil {
+				return saveErr
+			}
+			r.logger.Info("рассылка приостановлена", "job_id", job.ID, "cursor", job.Cursor)
+			return err
+		}
+
+		msg := notify.Message{
+			// Детерминированный ID позволяет опознать повтор после сбоя в журнале доставки
+			ID:       fmt.Sprintf("%s-%d", job.ID, job.Cursor),
+			To:       job.Recipients[job.Cursor],
+			Subject:  job.Subject,
+			Text:     job.Text,
+			Campaign: job.Campaign,
+		}
+		if err := sender.Send(ctx, msg); err != nil {
+			job.Failed++
+			r.recordFailure(job, msg.To, err)
+			r.logger.Warn("не удалось отправить сообщение рассылки", "job_id", job.ID, "to", msg.To, "error", err)
+		} else {
+			job.Sent++
+		}
+		job.Cursor++
+
+		sinceCheckpoint++
+		if sinceCheckpoint >= every {
+			sinceCheckpoint = 0
+			elapse()
+			if err := r.checkpoint(ctx, job, &saved); err != nil {
+				return err
+			}
+		}
+	}
+
+	elapse()
+	job.Status = StatusCompleted
+	if err := r.checkpoint(ctx, job, &saved); err != nil {
+		return err
+	}
+	r.logger.Info("рассылка завершена", "job_id", job.ID, "sent", job.Sent, "failed", job.Failed)
+	r.sendReport(ctx, job)
+	return nil
+}
+
+// recordFailure добавляет ошибку отправки получателю to в итоги задания.
+func (r *Runner) recordFailure(job *Job, to string, err error) {
+	classify := r.Classify
+	if classify == nil {
+		classify = Kind
+	}
+	kind := classify(err)
+	if job.Errors == nil {
+		job.Errors = make(map[string]int)
+	}
+	job.Errors[kind]++
+	job.Failures = append(job.Failures, Failure{Position: job.Cursor, Recipient: to, Kind: kind, Error: err.Error()})
+}
+
+// sendReport отправляет отчёт о завершённой рассылке администратору, если задан адрес.
+// Ошибка отправки отчёта только записывается в лог: рассылка уже выполнена.
+func (r *Runner) sendReport(ctx context.Context, job *Job) {
+	if r.reportChannel == "" {
+		return
+	}
+	sender, err := r.registry.Get(r.reportChannel)
+	if err == nil {
+		err = sender.Send(ctx, notify.Message{
+			ID:      job.ID + "-report",
+			To:      r.reportTo,
+			Subject: "Отчёт о рассылке " + job.ID,
+			Text:    job.Report().String(),
+		})
+	}
+	if err != nil {
+		r.logger.Warn("не удалось отправить отчёт о рассылке", "job_id", job.ID, "channel", r.reportChannel, "error", err)
+	}
+}
+
+// checkpoint сохраняет курсор задания и ошибки job.Failures начиная с *saved.
+func (r *Runner) checkpoint(ctx context.Context, job *Job, saved *int) error {
+	job.UpdatedAt = time.Now()
+	if err := r.store.Checkpoint(ctx, job, job.Failures[*saved:]); err != nil {
+		return fmt.Errorf("не удалось сохранить курсор рассылки %s: %w", job.ID, err)
+	}
+	*saved = len(job.Failures)
+	return nil
+}
@@ -0,0 +1,299 @@
+package broadcast
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// MemoryJobStore — JobStore в памяти процесса. Подходит для тестов:
+// после перезапуска задания теряются.
+type MemoryJobStore struct {
+	mu   sync.RWMutex
+	jobs map[string]Job
+}
+
+// NewMemoryJobStore создаёт пустое хранилище заданий в памяти.
+func NewMemoryJobStore() *MemoryJobStore {
+	return &MemoryJobStore{jobs: make(map[string]Job)}
+}
+
+// Save создаёт или заменяет задание.
+func (s *MemoryJobStore) Save(_ context.Context, job *Job) error {
+	stored := *job
+	stored.Failures = slices.Clone(job.Failures)
+	stored.Errors = maps.Clone(job.Errors)
+	s.mu.Lock()
+	s.jobs[job.ID] = stored
+	s.mu.Unlock()
+	return nil
+}
+
+// Checkpoint обновляет курсор и счётчики задания и дописывает ошибки.
+func (s *MemoryJobStore) Checkpoint(_ context.Context, job *Job, failures []Failure) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stored, ok := s.jobs[job.ID]
+	if !ok {
+		return ErrJobNotFound
+	}
+	recipients, saved := stored.Recipients, stored.Failures
+	stored = *job
+	stored.Recipients = recipients
+	stored.Failures = append(saved, failures...)
+	stored.Errors = maps.Clone(job.Errors)
+	s.jobs[job.ID] = stored
+	return nil
+}
+
+// Load возвращает копию задания.
+func (s *MemoryJobStore) Load(_ context.Context, id string) (*Job, error) {
+	s.mu.RLock()
+	job, ok := s.jobs[id]
+	s.mu.RUnlock()
+	if !ok {
+		return nil, ErrJobNotFound
+	}
+	job.Failures = slices.Clone(job.Failures)
+	job.Errors = maps.Clone(job.Errors)
+	return &job, nil
+}
+
+// Unfinished возвращает незавершённые задания в порядке создания.
+func (s *MemoryJobStore) Unfinished(_ context.Context) ([]*Job, error) {
+	s.mu.RLock()
+	var out []*Job
+	for _, job := range s.jobs {
+		if job.Status != StatusCompleted {
+			j := job
+			j.Failures = slices.Clone(job.Failures)
+			j.Errors = maps.Clone(job.Errors)
+			out = append(out, &j)
+		}
+	}
+	s.mu.RUnlock()
+
+	sortJobs(out)
+	return out, nil
+}
+
+// FileJobStore хранит задание в трёх файлах каталога: <id>.json — курсор, счётчики и текст,
+// <id>.recipients — список получателей, записанный один раз при Save, и <id>.failures — ошибки
+// отправки по одной JSON-строке, которые чекпойнты только дописывают.
+//
+// Файл задания записывается атомарно (через временный файл и rename), поэтому курсор не теряется
+// при падении процесса посреди сохранения. Ошибки, дописанные после последнего сохранённого курсора,
+// при чтении отбрасываются: эти получатели будут обработаны повторно.
+type FileJobStore struct {
+	dir string
+	mu  sync.Mutex
+}
+
+// NewFileJobStore создаёт хранилище в каталоге dir, создавая его при необходимости.
+func NewFileJobStore(dir string) (*FileJobStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("не удалось создать каталог заданий %s: %w", dir, err)
+	}
+	return &FileJobStore{dir: dir}, nil
+}
+
+// Расширения файлов задания
+const (
+	jobExt        = ".json"
+	recipientsExt = ".recipients"
+	failuresExt   = ".failures"
+)
+
+func (s *FileJobStore) path(id, ext string) string {
+	return filepath.Join(s.dir, filepath.Base(id)+ext)
+}
+
+// Save записывает задание на диск целиком, заменяя получателей и ошибки.
+func (s *FileJobStore) Save(_ context.Context, job *Job) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.save(job)
+}
+
+func (s *FileJobStore) save(job *Job) error {
+	recipients, err := json.Marshal(job.Recipients)
+	if err != nil {
+		return err
+	}
+	var failures bytes.Buffer
+	enc := json.NewEncoder(&failures)
+	for _, f := range job.Failures {
+		if err := enc.Encode(f); err != nil {
+			return err
+		}
+	}
+
+	if err := s.writeFile(job.ID, recipientsExt, recipients); err != nil {
+		return err
+	}
+	if err := s.writeFile(job.ID, failuresExt, failures.Bytes()); err != nil {
+		return err
+	}
+	return s.writeHeader(job)
+}
+
+// Checkpoint дописывает ошибки в файл ошибок и атомарно перезаписывает файл задания.
+func (s *FileJobStore) Checkpoint(_ context.Context, job *Job, failures []Failure) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := os.Stat(s.path(job.ID, jobExt)); os.IsNotExist(err) {
+		return ErrJobNotFound
+	}
+	if _, err := os.Stat(s.path(job.ID, recipientsExt)); os.IsNotExist(err) {
+		// Задание сохранено прежней версией одним файлом: переводим его в новый формат
+		return s.save(job)
+	}
+	if len(failures) > 0 {
+		f, err := os.OpenFile(s.path(job.ID, failuresExt), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+		if err != nil {
+			return fmt.Errorf("не удалось сохранить ошибки задания %s: %w", job.ID, err)
+		}
+		enc := json.NewEncoder(f)
+		for _, failure := range failures {
+			if err := enc.Encode(failure); err != nil {
+				_ = f.Close()
+				return fmt.Errorf("не удалось сохранить ошибки задания %s: %w", job.ID, err)
+			}
+		}
+		if err := f.Close(); err != nil {
+			return fmt.Errorf("не удалось сохранить ошибки задания %s: %w", job.ID, err)
+		}
+	}
+	// Файл задания пишется последним: курсор не опережает сохранённые ошибки
+	return s.writeHeader(job)
+}
+
+// writeHeader записывает файл задания без получателей и ошибок.
+func (s *FileJobStore) writeHeader(job *Job) error {
+	header := *job
+	header.Recipients, header.Failures = nil, nil
+	data, err := json.Marshal(header)
+	if err != nil {
+		return err
+	}
+	return s.writeFile(job.ID, jobExt, data)
+}
+
+// writeFile атомарно заменяет файл задания id с расширением ext.
+func (s *FileJobStore) writeFile(id, ext string, data []byte) error {
+	tmp, err := os.CreateTemp(s.dir, id+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("не удалось сохранить задание %s: %w", id, err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("не удалось сохранить задание %s: %w", id, err)
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("не удалось сохранить задание %s: %w", id, err)
+	}
+	return os.Rename(tmp.Name(), s.path(id, ext))
+}
+
+// Load читает задание с диска. Задания, сохранённые одним файлом, читаются как есть.
+func (s *FileJobStore) Load(_ context.Context, id string) (*Job, error) {
+	data, err := os.ReadFile(s.path(id, jobExt))
+	if os.IsNotExist(err) {
+		return nil, ErrJobNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("не удалось прочитать задание %s: %w", id, err)
+	}
+
+	var job Job
+	if err := json.Unmarshal(data, &job); err != nil {
+		return nil, fmt.Errorf("повреждённый файл задания %s: %w", id, err)
+	}
+
+	data, err = os.ReadFile(s.path(id, recipientsExt))
+	if os.IsNotExist(err) {
+		return &job, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("не удалось прочитать получателей задания %s: %w", id, err)
+	}
+	if err := json.Unmarshal(data, &job.Recipients); err != nil {
+		return nil, fmt.Errorf("повреждённый файл получателей задания %s: %w", id, err)
+	}
+
+	data, err = os.ReadFile(s.path(id, failuresExt))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("не удалось прочитать ошибки задания %s: %w", id, err)
+	}
+	failures, err := decodeFailures(data, job.Cursor)
+	if err != nil {
+		return nil, fmt.Errorf("повреждённый файл ошибок задания %s: %w", id, err)
+	}
+	job.Failures = failures
+	return &job, nil
+}
+
+// decodeFailures читает ошибки по одной JSON-строке. Ошибки получателей с номером cursor и дальше
+// отбрасываются, а из повторов для одного получателя остаётся последний.
+func decodeFailures(data []byte, cursor int) ([]Failure, error) {
+	var out []Failure
+	index := make(map[int]int) // номер получателя → индекс в out
+	dec := json.NewDecoder(bytes.NewReader(data))
+	for dec.More() {
+		var f Failure
+		if err := dec.Decode(&f); err != nil {
+			return nil, err
+		}
+		if f.Position >= cursor {
+			continue
+		}
+		if i, ok := index[f.Position]; ok {
+			out[i] = f
+			continue
+		}
+		index[f.Position] = len(out)
+		out = append(out, f)
+	}
+	return out, nil
+}
+
+// Unfinished возвращает незавершённые задания в порядке создания.
+func (s *FileJobStore) Unfinished(ctx context.Context) ([]*Job, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось прочитать каталог заданий: %w", err)
+	}
+
+	var out []*Job
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasSuffix(name, jobExt) {
+			continue
+		}
+		job, err := s.Load(ctx, strings.TrimSuffix(name, jobExt))
+		if err != nil {
+			return nil, err
+		}
+		if job.Status != StatusCompleted {
+			out = append(out, job)
+		}
+	}
+
+	sortJobs(out)
+	return out, nil
+}
+
+func sortJobs(jobs []*Job) {
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].CreatedAt.Before(jobs[j].CreatedAt) })
+}
@@ -0,0 +1,329 @@
+// Package campaign описывает рассылку целиком — шаблон, аудиторию, расписание, каналы и лимиты —
+// и проверяет описание до начала отправки.
+package campaign
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/epheer/notephee/notify"
+	"github.com/epheer/notephee/templates"
+)
+
+// Ошибки проверки кампании. Validate возвращает их объединёнными через errors.Join,
+// поэтому каждую можно проверить через errors.Is.
+var (
+	ErrNoName        = errors.New("не задано имя кампании")
+	ErrBadTemplate   = errors.New("шаблон не исполняется")
+	ErrEmptyAudience = errors.New("аудитория пуста")
+	ErrNoAddress     = errors.New("у получателя нет адреса ни в одном из каналов кампании")
+	ErrNoChannels    = errors.New("не заданы каналы кампании")
+	ErrBadSchedule   = errors.New("некорректное расписание")
+	ErrOverQuota     = errors.New("аудитория превышает квоту")
+	ErrNotPinned     = errors.New("версия шаблона не закреплена")
+)
+
+// Recipient — получатель кампании.
+type Recipient struct {
+	UserID    string            // Внутренний идентификатор пользователя
+	Addresses map[string]string // Адреса по каналам: telegram → chatID, email → адрес
+	Data      map[string]any    // Персональные данные для шаблона
+	Timezone  string            // Часовой пояс IANA для Schedule.LocalTime (пусто — Schedule.Timezone)
+}
+
+// RecipientFrom собирает получателя кампании из записи каталога получателей:
+// адреса и часовой пояс берутся из каталога, data — персональные данные для шаблона.
+func RecipientFrom(r notify.Recipient, data map[string]any) Recipient {
+	return Recipient{UserID: r.ID, Addresses: r.Addresses, Data: data, Timezone: r.Timezone}
+}
+
+// Schedule задаёт окно, в которое кампания может отправляться.
+type Schedule struct {
+	Start time.Time // Не раньше этого времени; нулевое — сразу
+	End   time.Time // Не позже этого времени; нулевое — без ограничения
+
+	// LocalTime — время доставки по местному времени получателя в формате 15:04, например 09:00.
+	// Рассылка тогда уходит волнами по часовым поясам (см. Plan). Пусто — всем сразу.
+	LocalTime string
+	Timezone  string // Часовой пояс получателей без Recipient.Timezone (пусто — UTC)
+}
+
+// Wave — получатели, которым кампания отправляется в один момент.
+type Wave struct {
+	At         time.Time   // Время отправки
+	Recipients []Recipient // Получатели в порядке аудитории
+}
+
+// ChannelPolicy определяет, через какие каналы отправлять кампанию.
+type ChannelPolicy struct {
+	Channels []string // Каналы в порядке предпочтения
+	Fallback bool     // Пробовать следующий канал, если в текущем отправка не удалась
+}
+
+// Limits — ограничения объёма и скорости кампании.
+type Limits struct {
+	MaxRecipients int     // Квота на число получателей; 0 — без ограничения
+	PerSecond     float64 // Скорость отправки, сообщений в секунду; 0 — лимиты канала
+}
+
+// Campaign — полное описание рассылки.
+//
+// Шаблон задаётся либо напрямую в Subject и Template, либо именем TemplateName из хранилища
+// templates.Store — тогда перед отправкой его версию нужно закрепить через Pin.
+type Campaign struct {
+	Name            string         // Имя кампании, используется как notify.Message.Campaign
+	TemplateName    string         // Имя шаблона в templates.Store (необязательно)
+	TemplateVersion int            // Закреплённая версия шаблона; заполняется Pin
+	Subject         string         // Шаблон темы (для каналов, где она есть)
+	Template        string         // Шаблон текста в синтаксисе text/template
+	SampleData      map[string]any // Пример данных для проверки шаблона
+	Audience        []Recipient    // Получатели
+	Schedule        Schedule       // Окно отправки
+	Channels        ChannelPolicy  // Политика выбора каналов
+	Limits          Limits         // Квоты и скорость
+}
+
+// Pin закрепляет за кампанией версию шаблона TemplateName: копирует её тему и текст в кампанию
+// и запоминает номер версии. Если TemplateVersion уже задан, закрепляется именно он, иначе — последняя.
+//
+// После Pin публикация новых версий шаблона не меняет текст кампании.
+func (c *Campaign) Pin(ctx context.Context, store templates.Store) error {
+	if c.TemplateName == "" {
+		return fmt.Errorf("%w: не задано имя шаблона", ErrNotPinned)
+	}
+
+	var (
+		v   *templates.Version
+		err error
+	)
+	if c.TemplateVersion > 0 {
+		v, err = store.Get(ctx, c.TemplateName, c.TemplateVersion)
+	} else {
+		v, err = store.Latest(ctx, c.TemplateName)
+	}
+	if err != nil {
+		return fmt.Errorf("не удалось закрепить шаблон кампании %s: %w", c.Name, err)
+	}
+
+	c.TemplateVersion = v.Version
+	c.Subject = v.Subject
+	c.Template = v.Body
+	return nil
+}
+
+// Validate проверяет кампанию целиком до начала отправки: шаблон закреплён и исполняется с SampleData,
+// аудитория не пуста и укладывается в квоту, у каждого получателя есть адрес хотя бы
+// в одном из каналов, а окно расписания ещё не закончилось.
+//
+// Возвращает все найденные ошибки сразу, объединённые через errors.Join.
+func (c *Campaign) Validate() error {
+	var errs []error
+
+	if strings.TrimSpace(c.Name) == "" {
+		errs = append(errs, ErrNoName)
+	}
+
+	if c.TemplateName != "" && c.TemplateVersion == 0 {
+		errs = append(errs, fmt.Errorf("%w: %s", ErrNotPinned, c.TemplateName))
+	} else if _, _, err := c.Render(c.SampleData); err != nil {
+		errs = append(errs, err)
+	}
+
+	if len(c.Channels.Channels) == 0 {
+		errs = append(errs, ErrNoChannels)
+	}
+
+	if len(c.Audience) == 0 {
+		errs = append(errs, ErrEmptyAudience)
+	}
+	if c.Limits.MaxRecipients > 0 && len(c.Audience) > c.Limits.MaxRecipients {
+		errs = append(errs, fmt.Errorf("%w: %d из %d", ErrOverQuota, len(c.Audience), c.Limits.MaxRecipients))
+	}
+	for i, r := range c.Audience {
+		if _, _, ok := c.Address(r); !ok && len(c.Channels.Channels) > 0 {
+			errs = append(errs, fmt.Errorf("%w: #%d (%s)", ErrNoAddress, i, r.UserID))
+		}
+	}
+
+	s := c.Schedule
+	if s.LocalTime != "" {
+		if _, err := notify.ParseLocalTime(s.LocalTime); err != nil {
+			errs = append(errs, fmt.Errorf("%w: %v", ErrBadSchedule, err))
+		}
+	}
+	for _, tz := range append([]string{s.Timezone}, timezones(c.Audience)...) {
+		if _, err := time.LoadLocation(tz); err != nil {
+			errs = append(errs, fmt.Errorf("%w: неизвестный часовой пояс %q", ErrBadSchedule, tz))
+		}
+	}
+	if !s.End.IsZero() {
+		if !s.Start.IsZero() && !s.End.After(s.Start) {
+			errs = append(errs, fmt.Errorf("%w: окончание не позже начала", ErrBadSchedule))
+		} else if s.End.Before(time.Now()) {
+			errs = append(errs, fmt.Errorf("%w: окно отправки уже закончилось", ErrBadSchedule))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Render исполняет шаблоны темы и текста с данными data.
+//
+// Отсутствующие в data ключи считаются ошибкой, чтобы опечатка в шаблоне не ушла
+// получателям как «<no value>».
+func (c *Campaign) Render(data map[string]any) (subject, text string, err error) {
+	if subject, err = execute("subject", c.Subject, data); err != nil {
+		return "", "", err
+	}
+	if text, err = execute("text", c.Template, data); err != nil {
+		return "", "", err
+	}
+	return subject, text, nil
+}
+
+// Message собирает сообщение кампании для получателя r: выбирает канал по политике
+// и исполняет шаблоны с персональными данными получателя.
+//
+// ID сообщения генерируется заранее, чтобы по нему можно было связать запись
+// журнала доставки с данными, из которых сообщение было собрано. Закреплённая версия
+// шаблона передаётся в notify.Message.Template и попадает в журнал доставки.
+func (c *Campaign) Message(r Recipient) (string, notify.Message, error) {
+	channel, address, ok := c.Address(r)
+	if !ok {
+		return "", notify.Message{}, fmt.Errorf("%w: %s", ErrNoAddress, r.UserID)
+	}
+
+	subject, text, err := c.Render(r.Data)
+	if err != nil {
+		return "", notify.Message{}, err
+	}
+
+	msg := notify.Message{
+		ID:       uuid.New().String(),
+		To:       address,
+		UserID:   r.UserID,
+		Subject:  subject,
+		Text:     text,
+		Campaign: c.Name,
+	}
+	if c.TemplateName != "" {
+		msg.Template = templates.Version{Name: c.TemplateName, Version: c.TemplateVersion}.String()
+	}
+	return channel, msg, nil
+}
+
+// Address возвращает первый по политике канал, в котором у получателя есть адрес.
+func (c *Campaign) Address(r Recipient) (channel, address string, ok bool) {
+	for _, ch := range c.Channels.Channels {
+		if addr := r.Addresses[ch]; addr != "" {
+			return ch, addr, true
+		}
+	}
+	return "", "", false
+}
+
+// SendAt возвращает время отправки получателю r не раньше now: начало окна расписания или,
+// если задан Schedule.LocalTime, ближайшее наступление этого времени в часовом поясе получателя.
+func (c *Campaign) SendAt(r Recipient, now time.Time) (time.Time, error) {
+	from := now
+	if c.Schedule.Start.After(from) {
+		from = c.Schedule.Start
+	}
+	if c.Schedule.LocalTime == "" {
+		return from, nil
+	}
+
+	clock, err := notify.ParseLocalTime(c.Schedule.LocalTime)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: %v", ErrBadSchedule, err)
+	}
+	tz := r.Timezone
+	if tz == "" {
+		tz = c.Schedule.Timezone
+	}
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: неизвестный часовой пояс %q", ErrBadSchedule, tz)
+	}
+	return clock.Next(from, loc), nil
+}
+
+// Plan распределяет аудиторию по волнам отправки в порядке времени, чтобы при заданном
+// Schedule.LocalTime каждый получатель получил кампанию в своё местное время, а не все одновременно.
+// Без LocalTime возвращается одна волна. Получатели, чьё время выпадает за конец окна расписания,
+// в план не попадают и перечисляются в ошибке ErrBadSchedule вместе с волнами остальных.
+func (c *Campaign) Plan(now time.Time) ([]Wave, error) {
+	// Ключ — момент времени: у поясов с одинаковым смещением волна общая
+	byTime := make(map[int64]*Wave)
+	var waves []*Wave
+	var errs []error
+	for _, r := range c.Audience {
+		at, err := c.SendAt(r, now)
+		if err != nil {
+			return nil, err
+		}
+		if !c.Schedule.InWindow(at) {
+			errs = append(errs, fmt.Errorf("%w: %s получит кампанию в %s, после окончания окна", ErrBadSchedule, r.UserID, at.Format(time.RFC3339)))
+			continue
+		}
+		w, ok := byTime[at.UnixNano()]
+		if !ok {
+			w = &Wave{At: at}
+			byTime[at.UnixNano()] = w
+			waves = append(waves, w)
+		}
+		w.Recipients = append(w.Recipients, r)
+	}
+
+	sort.SliceStable(waves, func(i, j int) bool { return waves[i].At.Before(waves[j].At) })
+	out := make([]Wave, len(waves))
+	for i, w := range waves {
+		out[i] = *w
+	}
+	return out, errors.Join(errs...)
+}
+
+// timezones возвращает различные часовые пояса, заданные у получателей.
+func timezones(audience []Recipient) []string {
+	seen := make(map[string]bool)
+	var out []string
+	for _, r := range audience {
+		if r.Timezone != "" && !seen[r.Timezone] {
+			seen[r.Timezone] = true
+			out = append(out, r.Timezone)
+		}
+	}
+	return out
+}
+
+// InWindow сообщает, попадает ли момент t в окно расписания.
+func (s Schedule) InWindow(t time.Time) bool {
+	if !s.Start.IsZero() && t.Before(s.Start) {
+		return false
+	}
+	if !s.End.IsZero() && t.After(s.End) {
+		return false
+	}
+	return true
+}
+
+func execute(name, text string, data map[string]any) (string, error) {
+	tmpl, err := template.New(name).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("%w: %s: %v", ErrBadTemplate, name, err)
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("%w: %s: %v", ErrBadTemplate, name, err)
+	}
+	return buf.String(), nil
+}
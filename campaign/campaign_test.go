@@ -0,0 +1,146 @@
+package campaign_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/epheer/notephee/campaign"
+	"github.com/epheer/notephee/notify"
+	"github.com/epheer/notephee/templates"
+)
+
+func validCampaign() campaign.Campaign {
+	return campaign.Campaign{
+		Name:       "spring-sale",
+		Subject:    "Скидки для {{.Name}}",
+		Template:   "Здравствуйте, {{.Name}}! Скидка {{.Discount}}%.",
+		SampleData: map[string]any{"Name": "Анна", "Discount": 10},
+		Audience: []campaign.Recipient{
+			{UserID: "u1", Addresses: map[string]string{"telegram": "101"}},
+			{UserID: "u2", Addresses: map[string]string{"email": "u2@example.com"}},
+		},
+		Channels: campaign.ChannelPolicy{Channels: []string{"telegram", "email"}, Fallback: true},
+		Limits:   campaign.Limits{MaxRecipients: 10},
+	}
+}
+
+func TestValidCampaign(t *testing.T) {
+	c := validCampaign()
+	if err := c.Validate(); err != nil {
+		t.Fatalf("кампания должна быть корректной: %v", err)
+	}
+
+	ch, addr, ok := c.Address(c.Audience[1])
+	if !ok || ch != "email" || addr != "u2@example.com" {
+		t.Fatalf("неожиданный адрес: %s %s", ch, addr)
+	}
+}
+
+func TestValidateReportsAllErrors(t *testing.T) {
+	c := validCampaign()
+	c.Template = "Скидка {{.Percent}}%"
+	c.Limits.MaxRecipients = 1
+	c.Audience = append(c.Audience, campaign.Recipient{UserID: "u3"})
+	c.Schedule = campaign.Schedule{End: time.Now().Add(-time.Hour)}
+
+	err := c.Validate()
+	for _, want := range []error{campaign.ErrBadTemplate, campaign.ErrOverQuota, campaign.ErrNoAddress, campaign.ErrBadSchedule} {
+		if !errors.Is(err, want) {
+			t.Errorf("ожидалась ошибка %q, получено: %v", want, err)
+		}
+	}
+
+	c = validCampaign()
+	c.Name = ""
+	c.Audience = nil
+	err = c.Validate()
+	if !errors.Is(err, campaign.ErrNoName) || !errors.Is(err, campaign.ErrEmptyAudience) {
+		t.Fatalf("ожидались ошибки имени и аудитории, получено: %v", err)
+	}
+}
+
+func TestPinKeepsVersionAfterEdit(t *testing.T) {
+	ctx := context.Background()
+	store := templates.NewMemoryStore()
+	_, _ = store.Publish(ctx, "welcome", "Привет", "Здравствуйте, {{.Name}}!")
+
+	c := validCampaign()
+	c.TemplateName = "welcome"
+	if err := c.Validate(); !errors.Is(err, campaign.ErrNotPinned) {
+		t.Fatalf("ожидалась ошибка незакреплённого шаблона, получено: %v", err)
+	}
+	if err := c.Pin(ctx, store); err != nil {
+		t.Fatalf("Ошибка Pin: %v", err)
+	}
+
+	// Правка шаблона посреди рассылки
+	v2, _ := store.Publish(ctx, "welcome", "Привет", "Добрый день, {{.Name}}!")
+
+	_, msg, err := c.Message(campaign.Recipient{
+		Addresses: map[string]string{"email": "a@example.com"},
+		Data:      map[string]any{"Name": "Анна"},
+	})
+	if err != nil {
+		t.Fatalf("Ошибка Message: %v", err)
+	}
+	if c.TemplateVersion != 1 || msg.Text != "Здравствуйте, Анна!" {
+		t.Fatalf("кампания должна остаться на версии 1, получено v%d: %q", c.TemplateVersion, msg.Text)
+	}
+	if msg.Template != "welcome@v1" {
+		t.Fatalf("сообщение должно ссылаться на закреплённую версию, получено %q", msg.Template)
+	}
+	if v2.String() != "welcome@v2" {
+		t.Fatalf("неожиданное обозначение версии: %s", v2)
+	}
+}
+
+func TestPlanByLocalTime(t *testing.T) {
+	c := validCampaign()
+	c.Schedule = campaign.Schedule{LocalTime: "09:00"}
+	c.Audience = []campaign.Recipient{
+		campaign.RecipientFrom(notify.Recipient{ID: "msk", Addresses: map[string]string{"email": "a@example.com"}, Timezone: "Europe/Moscow"}, nil),
+		{UserID: "utc", Addresses: map[string]string{"email": "b@example.com"}},
+		{UserID: "vlad", Addresses: map[string]string{"email": "c@example.com"}, Timezone: "Asia/Vladivostok"},
+		{UserID: "ist", Addresses: map[string]string{"email": "d@example.com"}, Timezone: "Europe/Istanbul"},
+	}
+	if err := c.Validate(); err != nil {
+		t.Fatalf("кампания должна быть корректной: %v", err)
+	}
+
+	// 05:00 UTC: во Владивостоке 15:00, в Москве и Стамбуле 08:00
+	now := time.Date(2024, 3, 1, 5, 0, 0, 0, time.UTC)
+	waves, err := c.Plan(now)
+	if err != nil {
+		t.Fatalf("Ошибка Plan: %v", err)
+	}
+	want := []struct {
+		at    time.Time
+		users []string
+	}{
+		{time.Date(2024, 3, 1, 6, 0, 0, 0, time.UTC), []string{"msk", "ist"}},
+		{time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC), []string{"utc"}},
+		{time.Date(2024, 3, 1, 23, 0, 0, 0, time.UTC), []string{"vlad"}},
+	}
+	if len(waves) != len(want) {
+		t.Fatalf("ожидалось %d волны, получено %+v", len(want), waves)
+	}
+	for i, w := range want {
+		if !waves[i].At.Equal(w.at) || len(waves[i].Recipients) != len(w.users) || waves[i].Recipients[0].UserID != w.users[0] {
+			t.Fatalf("волна %d: %v %+v, ожидалось %v %v", i, waves[i].At, waves[i].Recipients, w.at, w.users)
+		}
+	}
+
+	// Волна после окончания окна не планируется
+	c.Schedule.End = time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	waves, err = c.Plan(now)
+	if !errors.Is(err, campaign.ErrBadSchedule) || len(waves) != 2 {
+		t.Fatalf("ожидались 2 волны и ErrBadSchedule, получено %d и %v", len(waves), err)
+	}
+
+	c.Schedule = campaign.Schedule{LocalTime: "25:00", Timezone: "Mars/Olympus"}
+	if err := c.Validate(); !errors.Is(err, campaign.ErrBadSchedule) {
+		t.Fatalf("ожидалась ошибка расписания, получено %v", err)
+	}
+}
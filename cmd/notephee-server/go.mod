@@ -0,0 +1,79 @@
+module github.com/epheer/notephee/cmd/notephee-server
+
+go 1.24.3
+
+require (
+	github.com/epheer/notephee v0.0.0-00010101000000-000000000000
+	github.com/epheer/notephee/grpcapi v0.0.0-00010101000000-000000000000
+	github.com/epheer/notephee/ingest/amqp v0.0.0-00010101000000-000000000000
+	github.com/epheer/notephee/ingest/kafka v0.0.0-00010101000000-000000000000
+	github.com/epheer/notephee/ingest/nats v0.0.0-00010101000000-000000000000
+	github.com/epheer/notephee/store/postgres v0.0.0-00010101000000-000000000000
+	github.com/epheer/notephee/store/redis v0.0.0-00010101000000-000000000000
+	github.com/epheer/notephee/store/sqlite v0.0.0-00010101000000-000000000000
+	github.com/redis/go-redis/v9 v9.7.0
+	google.golang.org/grpc v1.80.0
+)
+
+require (
+	github.com/BurntSushi/toml v1.6.0 // indirect
+	github.com/cespare/xxhash/v2 v2.3.0 // indirect
+	github.com/dgryski/go-rendezvous v0.0.0-20200823014737-9f7001d12a5f // indirect
+	github.com/dustin/go-humanize v1.0.1 // indirect
+	github.com/go-logr/logr v1.4.3 // indirect
+	github.com/go-logr/stdr v1.2.2 // indirect
+	github.com/google/uuid v1.6.0 // indirect
+	github.com/hamba/avro/v2 v2.28.0 // indirect
+	github.com/hashicorp/golang-lru/v2 v2.0.7 // indirect
+	github.com/jackc/pgpassfile v1.0.0 // indirect
+	github.com/jackc/pgservicefile v0.0.0-20240606120523-5a60cdf6a761 // indirect
+	github.com/jackc/pgx/v5 v5.7.1 // indirect
+	github.com/jackc/puddle/v2 v2.2.2 // indirect
+	github.com/joho/godotenv v1.5.1 // indirect
+	github.com/json-iterator/go v1.1.12 // indirect
+	github.com/klauspost/compress v1.17.11 // indirect
+	github.com/mattn/go-isatty v0.0.20 // indirect
+	github.com/mitchellh/mapstructure v1.5.0 // indirect
+	github.com/modern-go/concurrent v0.0.0-20180306012644-bacd9c7ef1dd // indirect
+	github.com/modern-go/reflect2 v1.0.2 // indirect
+	github.com/nats-io/nats.go v1.37.0 // indirect
+	github.com/nats-io/nkeys v0.4.7 // indirect
+	github.com/nats-io/nuid v1.0.1 // indirect
+	github.com/ncruces/go-strftime v0.1.9 // indirect
+	github.com/pierrec/lz4/v4 v4.1.15 // indirect
+	github.com/rabbitmq/amqp091-go v1.10.0 // indirect
+	github.com/remyoudompheng/bigfft v0.0.0-20230129092748-24d4a6f8daec // indirect
+	github.com/segmentio/kafka-go v0.4.49 // indirect
+	github.com/skip2/go-qrcode v0.0.0-20200617195104-da1b6568686e // indirect
+	go.opentelemetry.io/auto/sdk v1.2.1 // indirect
+	go.opentelemetry.io/otel v1.39.0 // indirect
+	go.opentelemetry.io/otel/metric v1.39.0 // indirect
+	go.opentelemetry.io/otel/trace v1.39.0 // indirect
+	golang.org/x/crypto v0.47.0 // indirect
+	golang.org/x/net v0.49.0 // indirect
+	golang.org/x/sync v0.19.0 // indirect
+	golang.org/x/sys v0.40.0 // indirect
+	golang.org/x/text v0.33.0 // indirect
+	golang.org/x/time v0.11.0 // indirect
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20260120221211-b8f7ae30c516 // indirect
+	google.golang.org/protobuf v1.36.11 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+	modernc.org/gc/v3 v3.0.0-20240107210532-573471604cb6 // indirect
+	modernc.org/libc v1.55.3 // indirect
+	modernc.org/mathutil v1.6.0 // indirect
+	modernc.org/memory v1.8.0 // indirect
+	modernc.org/sqlite v1.33.1 // indirect
+	modernc.org/strutil v1.2.0 // indirect
+	modernc.org/token v1.1.0 // indirect
+)
+
+replace (
+	github.com/epheer/notephee => ../../
+	github.com/epheer/notephee/grpcapi => ../../grpcapi
+	github.com/epheer/notephee/ingest/amqp => ../../ingest/amqp
+	github.com/epheer/notephee/ingest/kafka => ../../ingest/kafka
+	github.com/epheer/notephee/ingest/nats => ../../ingest/nats
+	github.com/epheer/notephee/store/postgres => ../../store/postgres
+	github.com/epheer/notephee/store/redis => ../../store/redis
+	github.com/epheer/notephee/store/sqlite => ../../store/sqlite
+)
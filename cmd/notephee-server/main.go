@@ -0,0 +1,620 @@
+// Команда notephee-server запускает notephee как отдельный сервис уведомлений с HTTP и gRPC API.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	goredis "github.com/redis/go-redis/v9"
+	"google.golang.org/grpc"
+
+	"github.com/epheer/notephee/alert"
+	"github.com/epheer/notephee/backfill"
+	"github.com/epheer/notephee/config"
+	"github.com/epheer/notephee/config/secrets"
+	"github.com/epheer/notephee/dedup"
+	"github.com/epheer/notephee/degrade"
+	"github.com/epheer/notephee/delivery"
+	"github.com/epheer/notephee/digest"
+	"github.com/epheer/notephee/email"
+	"github.com/epheer/notephee/email/tracking"
+	"github.com/epheer/notephee/email/unsubscribe"
+	"github.com/epheer/notephee/envclient"
+	"github.com/epheer/notephee/grpcapi"
+	"github.com/epheer/notephee/ingest/amqp"
+	"github.com/epheer/notephee/ingest/kafka"
+	"github.com/epheer/notephee/ingest/nats"
+	"github.com/epheer/notephee/latency"
+	"github.com/epheer/notephee/matrix"
+	"github.com/epheer/notephee/notify"
+	"github.com/epheer/notephee/overflow"
+	"github.com/epheer/notephee/preferences"
+	"github.com/epheer/notephee/queue"
+	"github.com/epheer/notephee/redelivery"
+	"github.com/epheer/notephee/reload"
+	"github.com/epheer/notephee/replay"
+	"github.com/epheer/notephee/server"
+	"github.com/epheer/notephee/slack"
+	"github.com/epheer/notephee/spool"
+	"github.com/epheer/notephee/store/postgres"
+	redisstore "github.com/epheer/notephee/store/redis"
+	"github.com/epheer/notephee/store/sqlite"
+	"github.com/epheer/notephee/suppression"
+	"github.com/epheer/notephee/teamchat"
+	"github.com/epheer/notephee/telegram"
+	"github.com/epheer/notephee/templates"
+	"github.com/epheer/notephee/viber"
+	"github.com/epheer/notephee/vk"
+)
+
+func main() {
+	envPath := flag.String("env", ".env", "путь к env-файлу с настройками NOTEPHEE_*")
+	configPath := flag.String("config", "", "файл настроек .yaml, .json или .toml")
+	settings := config.RegisterFlags(flag.CommandLine)
+	flag.Parse()
+
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	if _, err := os.Stat(*envPath); err == nil {
+		_ = config.LoadEnv(*envPath)
+	}
+	src := config.Sources{File: *configPath, Flags: settings.Values()}
+	secretStore, err := secrets.FromSources(src)
+	if err != nil {
+		logger.Error("сервер не запущен: некорректная настройка хранилища секретов", "error", err)
+		os.Exit(1)
+	}
+	src.Secrets = secretStore
+	cfg, err := config.LoadFrom(context.Background(), src, logger)
+	if err != nil {
+		logger.Error("сервер не запущен: не удалось прочитать настройки", "error", err)
+		os.Exit(1)
+	}
+	if err := cfg.Validate(); err != nil {
+		// Каждая ошибка уже записана в лог при загрузке конфигурации
+		logger.Error("сервер не запущен: некорректная конфигурация", "error", err)
+		os.Exit(1)
+	}
+
+	// PostgreSQL или SQLite хранит журнал доставки, подписки получателей и список подавления
+	// между перезапусками и экземплярами
+	var log deliveryLog = delivery.NewMemoryLog()
+	var subscriptions preferences.Store = preferences.NewMemoryStore()
+	var suppressed suppression.Store = suppression.NewMemoryStore()
+	if cfg.PostgresURL != "" {
+		db, err := postgres.Open(cfg.PostgresURL)
+		if err == nil {
+			err = postgres.Migrate(context.Background(), db)
+		}
+		if err != nil {
+			logger.Error("не удалось подготовить базу PostgreSQL", "error", err)
+			os.Exit(1)
+		}
+		defer db.Close()
+		log = postgres.NewDeliveryLog(db)
+		subscriptions = postgres.NewSubscriptionStore(db)
+		suppressed = postgres.NewSuppressionStore(db)
+	}
+	if cfg.SQLitePath != "" {
+		db, err := sqlite.Open(cfg.SQLitePath)
+		if err == nil {
+			err = sqlite.Migrate(context.Background(), db)
+		}
+		if err != nil {
+			logger.Error("не удалось подготовить базу SQLite", "error", err)
+			os.Exit(1)
+		}
+		defer db.Close()
+		log = sqlite.NewDeliveryLog(db)
+		subscriptions = sqlite.NewSubscriptionStore(db)
+		suppressed = sqlite.NewSuppressionStore(db)
+	}
+	registry := notify.NewRegistry()
+	var unsubscribeSigner *unsubscribe.Signer
+	if cfg.UnsubscribeKey != "" && cfg.UnsubscribeURL != "" {
+		signer, err := unsubscribe.NewSigner([]byte(cfg.UnsubscribeKey), cfg.UnsubscribeURL, 0)
+		if err != nil {
+			logger.Error("некорректная настройка ссылок отписки", "error", err)
+			os.Exit(1)
+		}
+		unsubscribeSigner = signer
+	}
+	var mailTracker *tracking.Tracker
+	if cfg.TrackingKey != "" && cfg.TrackingURL != "" {
+		t, err := tracking.NewTracker([]byte(cfg.TrackingKey), cfg.TrackingURL)
+		if err != nil {
+			logger.Error("некорректная настройка отслеживания писем", "error", err)
+			os.Exit(1)
+		}
+		mailTracker = t
+	}
+
+	// Redis делит окно дедупликации и лимит Telegram между экземплярами сервиса
+	var redisClient goredis.UniversalClient
+	if cfg.RedisURL != "" {
+		opts, err := goredis.ParseURL(cfg.RedisURL)
+		if err != nil {
+			logger.Error("некорректный адрес Redis", "error", err)
+			os.Exit(1)
+		}
+		redisClient = goredis.NewClient(opts)
+		defer redisClient.Close()
+	}
+
+	var senders []notify.Sender
+	tg := envclient.Telegram(cfg, logger)
+	if tg.Enabled {
+		tg.SetDeliveryLog(log)
+		tg.SetSuppressionStore(suppressed)
+		if redisClient != nil {
+			tg.SetLimiter(redisstore.NewLimiter(redisClient, "{notephee}:limit:telegram:"+cfg.TelegramBotName, 30, 1))
+		}
+		senders = append(senders, tg)
+	}
+	mail := envclient.Email(cfg, logger)
+	if mail.Enabled {
+		if err := setTransport(mail, cfg); err != nil {
+			logger.Error("некорректная настройка почтового провайдера", "error", err)
+			os.Exit(1)
+		}
+		mail.SetDeliveryLog(log)
+		mail.SetSuppressionStore(suppressed)
+		if unsubscribeSigner != nil {
+			mail.SetUnsubscribeSigner(unsubscribeSigner)
+		}
+		mail.SetTracker(mailTracker)
+		senders = append(senders, mail)
+	}
+	sl := slack.NewClient(cfg, logger)
+	if sl.Enabled {
+		sl.SetDeliveryLog(log)
+		senders = append(senders, sl)
+	}
+	mx := matrix.NewClient(cfg, logger)
+	if mx.Enabled {
+		mx.SetDeliveryLog(log)
+		senders = append(senders, mx)
+	}
+	vkc := vk.NewClient(cfg, logger)
+	if vkc.Enabled {
+		vkc.SetDeliveryLog(log)
+		senders = append(senders, vkc)
+	}
+	vb := viber.NewClient(cfg, logger)
+	if vb.Enabled {
+		vb.SetDeliveryLog(log)
+		senders = append(senders, vb)
+	}
+	if cfg.TeamChatTargets != "" {
+		targets, err := teamchat.ParseTargets(cfg.TeamChatTargets)
+		if err != nil {
+			logger.Error("некорректное значение NOTEPHEE_TEAMCHAT_TARGETS", "error", err)
+			os.Exit(1)
+		}
+		tc := teamchat.NewClient(targets, logger)
+		tc.SetDeliveryLog(log)
+		senders = append(senders, tc)
+	}
+
+	// Клиенты личностей отправителя регистрируются отдельно от основных, через RegisterIdentity
+	identityOf := make(map[notify.Sender]string)
+	if cfg.Identities != "" {
+		identities, err := config.ParseIdentities(cfg.Identities)
+		if err != nil {
+			logger.Error("некорректное значение NOTEPHEE_IDENTITIES", "error", err)
+			os.Exit(1)
+		}
+		for _, id := range identities {
+			icfg := cfg.WithIdentity(id)
+			if id.TelegramToken != "" {
+				itg := envclient.Telegram(icfg, logger)
+				itg.SetDeliveryLog(log)
+				itg.SetSuppressionStore(suppressed)
+				senders = append(senders, itg)
+				identityOf[itg] = id.Name
+			}
+			if id.SMTPUser != "" {
+				imail := envclient.Email(icfg, logger)
+				if !imail.Enabled {
+					logger.Error("личность отправителя: конфигурация email не заполнена", "identity", id.Name)
+					os.Exit(1)
+				}
+				if err := setTransport(imail, icfg); err != nil {
+					logger.Error("некорректная настройка почтового провайдера", "identity", id.Name, "error", err)
+					os.Exit(1)
+				}
+				imail.SetDeliveryLog(log)
+				imail.SetSuppressionStore(suppressed)
+				if unsubscribeSigner != nil {
+					imail.SetUnsubscribeSigner(unsubscribeSigner)
+				}
+				imail.SetTracker(mailTracker)
+				senders = append(senders, imail)
+				identityOf[imail] = id.Name
+			}
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if src.Secrets != nil && cfg.SecretsRefresh > 0 {
+		// Ротация применяется к основному боту и SMTP-паролю; личности отправителя читаются при запуске
+		go secrets.Watch(ctx, src, cfg.SecretsRefresh, cfg, logger, func(next *config.Config) {
+			if tg.Enabled && next.TelegramToken != "" {
+				tg.SetToken(next.TelegramToken)
+			}
+			if mail.Enabled && next.EmailHost != "" && next.EmailPassword != "" {
+				if err := mail.SetAuth(next.EmailUser, next.EmailPassword); err != nil {
+					logger.Warn("не удалось применить новый пароль SMTP", "error", err)
+				}
+			}
+		})
+	}
+
+	policy := degrade.Policy{Threshold: cfg.DegradeLatency}
+	if cfg.DegradeLatency > 0 {
+		if policy.Low, err = degrade.ParseAction(cfg.DegradeLow); err != nil {
+			logger.Error("некорректное значение NOTEPHEE_DEGRADE_LOW", "error", err)
+			os.Exit(1)
+		}
+		if policy.Normal, err = degrade.ParseAction(cfg.DegradeNormal); err != nil {
+			logger.Error("некорректное значение NOTEPHEE_DEGRADE_NORMAL", "error", err)
+			os.Exit(1)
+		}
+	}
+
+	var redeliveryPolicy redelivery.Policy
+	if cfg.IndeterminatePolicy != "" {
+		var err error
+		if redeliveryPolicy, err = redelivery.ParsePolicy(cfg.IndeterminatePolicy); err != nil {
+			logger.Error("некорректное значение NOTEPHEE_INDETERMINATE_POLICY", "error", err)
+			os.Exit(1)
+		}
+	}
+
+	overflowStrategy, err := overflow.ParseStrategy(cfg.OverflowStrategy)
+	if err != nil {
+		logger.Error("некорректное значение NOTEPHEE_OVERFLOW_STRATEGY", "error", err)
+		os.Exit(1)
+	}
+	overflowCategories, err := overflow.ParseCategories(cfg.OverflowCategories)
+	if err != nil {
+		logger.Error("некорректное значение NOTEPHEE_OVERFLOW_CATEGORIES", "error", err)
+		os.Exit(1)
+	}
+	queueRates, err := queue.ParseRates(cfg.QueueRates)
+	if err != nil {
+		logger.Error("некорректное значение NOTEPHEE_QUEUE_RATES", "error", err)
+		os.Exit(1)
+	}
+	overflowPolicy := overflow.Policy{
+		Default:    overflowStrategy,
+		Categories: overflowCategories,
+		MoreURL:    overflow.MoreURLTemplate(cfg.OverflowMoreURL),
+	}
+
+	// Политики можно перезагрузить через /v1/admin/reload; начальная конфигурация берётся из окружения
+	initial := reload.Config{
+		Overflow: &reload.Overflow{
+			Strategy:   cfg.OverflowStrategy,
+			Categories: make(map[string]string, len(overflowCategories)),
+			MoreURL:    cfg.OverflowMoreURL,
+		},
+		Indeterminate: cfg.IndeterminatePolicy,
+		Preferences:   &reload.Preferences{OptIn: preferences.ParseCategories(cfg.OptInCategories)},
+	}
+	for category, strategy := range overflowCategories {
+		initial.Overflow.Categories[category] = string(strategy)
+	}
+	if cfg.DegradeLatency > 0 {
+		initial.Degrade = &reload.Degrade{Threshold: cfg.DegradeLatency.String(), Low: cfg.DegradeLow, Normal: cfg.DegradeNormal}
+	}
+	reloader := reload.New(initial, logger)
+	// Шаблоны из /v1/admin/reload публикуются новыми версиями; закреплённые за кампаниями версии не меняются
+	reloader.SetTemplates(templates.NewMemoryStore())
+
+	srv := server.New(registry, log, cfg.ServerToken, logger)
+	srv.SetReloader(reloader, cfg.AdminToken)
+	// История прежней системы рассылок загружается в тот же журнал и список подавления, что и отправки
+	importer := backfill.New(log, logger)
+	importer.SetSuppression(suppressed)
+	srv.SetImporter(importer)
+	tracker := latency.NewTracker(0)
+	srv.SetLatency(tracker)
+	var spoolStore spool.Store
+	if cfg.SpoolDir != "" {
+		store, err := spool.NewFileStore(cfg.SpoolDir)
+		if err != nil {
+			logger.Error("не удалось открыть каталог неотправленных сообщений", "error", err)
+			os.Exit(1)
+		}
+		store.SetLogger(logger)
+		spoolStore = store
+		srv.SetSpool(store)
+	}
+
+	// Фоновые циклы отправляют сводки и сохраняют отложенное при остановке,
+	// поэтому клиенты закрываются только после их завершения
+	var background sync.WaitGroup
+	var dedupStore dedup.Store = dedup.NewMemoryStore()
+	if redisClient != nil {
+		dedupStore = redisstore.NewDedupStore(redisClient, "{notephee}:dedup")
+	}
+	prefs := preferences.NewPolicy(subscriptions, preferences.ParseCategories(cfg.OptInCategories)...)
+	srv.SetPreferences(prefs)
+	reloader.SetPreferences(prefs)
+	if unsubscribeSigner != nil {
+		srv.SetUnsubscribe(unsubscribe.TopicHandler(unsubscribeSigner, suppressed, prefs, logger))
+	}
+	if mailTracker != nil {
+		srv.SetTracking(tracking.Handler(mailTracker, log, logger))
+	}
+	var snapshots *replay.FileStore
+	if cfg.ReplayDir != "" {
+		if snapshots, err = replay.NewFileStore(cfg.ReplayDir); err != nil {
+			logger.Error("не удалось открыть каталог снимков сообщений", "error", err)
+			os.Exit(1)
+		}
+	}
+	var queues []*queue.Dispatcher
+	for _, s := range senders {
+		identity := identityOf[s]
+		if snapshots != nil {
+			// Снимок снимается с того, что ушло провайдеру, — после разбиения длинного сообщения на части
+			s = replay.Wrap(s, snapshots, cfg, logger)
+		}
+		// Длинные сообщения подгоняются под предел канала до повторов, чтобы повтор шёл теми же частями
+		o := overflow.Wrap(s, overflowPolicy, logger)
+		reloader.AddOverflow(o)
+		// Задержка меряется вокруг overflow, чтобы разбитое на части сообщение учитывалось один раз
+		s = latency.Wrap(o, tracker, logger)
+		if redeliveryPolicy != "" {
+			r := redelivery.Wrap(s, redeliveryPolicy, logger)
+			reloader.AddRedelivery(r)
+			s = r
+		}
+		if cfg.DegradeLatency > 0 {
+			// Деградация оборачивает канал напрямую, чтобы мерить задержку провайдера, а не буферов
+			d := degrade.Wrap(s, policy, logger)
+			reloader.AddDegrade(d)
+			if spoolStore != nil {
+				d.SetSpool(spoolStore)
+			}
+			background.Add(1)
+			go func() {
+				defer background.Done()
+				d.Run(ctx)
+			}()
+			srv.AddHealth(d)
+			s = d
+		}
+		if cfg.DigestInterval > 0 {
+			d := digest.Wrap(s, cfg.DigestInterval, nil, logger)
+			if spoolStore != nil {
+				d.SetSpool(spoolStore)
+			}
+			background.Add(1)
+			go func() {
+				defer background.Done()
+				d.Run(ctx)
+			}()
+			s = d
+		}
+		if cfg.DedupWindow > 0 {
+			s = dedup.Wrap(s, dedupStore, cfg.DedupWindow, logger)
+		}
+		// Согласия проверяются первыми: отклонённое сообщение не должно попасть ни в сводку, ни в дедупликацию
+		s = preferences.Wrap(s, prefs, logger)
+		if identity != "" {
+			registry.RegisterIdentity(identity, s)
+		} else {
+			registry.Register(s)
+		}
+		if rate, ok := queueRates[s.Channel()]; ok {
+			// Рассылки канала отправляет пул воркеров, число которых подбирается под лимит и задержку канала
+			d := queue.NewDispatcher(s, queue.Options{Rate: rate}, logger)
+			if spoolStore != nil {
+				d.SetSpool(spoolStore)
+			}
+			// Очередь останавливается не сигналом, а closeQueues: сначала она дошлёт накопленное
+			d.Start(context.Background())
+			srv.SetQueue(s, d)
+			queues = append(queues, d)
+		}
+	}
+
+	// Оповещения о неполадках notephee идут администратору через зарегистрированные каналы
+	var alertTargets []alert.Target
+	if cfg.AlertTelegramChat != "" && tg.Enabled {
+		alertTargets = append(alertTargets, alert.Target{Channel: telegram.Channel, To: cfg.AlertTelegramChat})
+	}
+	if cfg.AlertEmail != "" && mail.Enabled {
+		alertTargets = append(alertTargets, alert.Target{Channel: email.Channel, To: cfg.AlertEmail})
+	}
+	if len(alertTargets) > 0 {
+		alert.SetDefault(alert.New(registry, logger, alertTargets...))
+	}
+
+	if spoolStore != nil {
+		background.Add(1)
+		go func() {
+			defer background.Done()
+			if err := spool.Replay(ctx, spoolStore, registry, logger); err != nil {
+				logger.Error("не удалось отправить сохранённые сообщения", "error", err)
+			}
+		}()
+	}
+
+	if cfg.GRPCAddr != "" {
+		if err := serveGRPC(ctx, cfg.GRPCAddr, cfg.ServerToken, grpcapi.New(registry, log, logger), logger); err != nil {
+			logger.Error("не удалось запустить gRPC API", "error", err)
+			os.Exit(1)
+		}
+	}
+
+	if cfg.IsKafkaEnabled() {
+		consumer, err := kafka.New(kafka.Options{
+			Brokers:  cfg.KafkaBrokerList(),
+			Topic:    cfg.KafkaTopic,
+			GroupID:  cfg.KafkaGroup,
+			DLQTopic: cfg.KafkaDLQTopic,
+			Format:   cfg.KafkaFormat,
+		}, registry, logger)
+		if err != nil {
+			logger.Error("не удалось подключиться к Kafka", "error", err)
+			os.Exit(1)
+		}
+		background.Add(1)
+		go func() {
+			defer background.Done()
+			defer consumer.Close()
+			if err := consumer.Run(ctx); err != nil {
+				logger.Error("чтение уведомлений из Kafka остановлено", "error", err)
+				alert.Alert(alert.Critical, "Чтение уведомлений из Kafka остановлено: "+err.Error())
+			}
+		}()
+	}
+
+	if cfg.IsNATSEnabled() {
+		consumer, err := nats.New(ctx, nats.Options{
+			URL:        cfg.NATSURL,
+			Stream:     cfg.NATSStream,
+			Consumer:   cfg.NATSConsumer,
+			Subject:    cfg.NATSSubject,
+			DLQSubject: cfg.NATSDLQSubject,
+		}, registry, logger)
+		if err != nil {
+			logger.Error("не удалось подключиться к NATS", "error", err)
+			os.Exit(1)
+		}
+		background.Add(1)
+		go func() {
+			defer background.Done()
+			defer consumer.Close()
+			if err := consumer.Run(ctx); err != nil {
+				logger.Error("чтение уведомлений из NATS остановлено", "error", err)
+				alert.Alert(alert.Critical, "Чтение уведомлений из NATS остановлено: "+err.Error())
+			}
+		}()
+	}
+
+	if cfg.IsAMQPEnabled() {
+		consumer, err := amqp.New(amqp.Options{URL: cfg.AMQPURL, Queue: cfg.AMQPQueue}, registry, logger)
+		if err != nil {
+			logger.Error("не удалось подключиться к AMQP", "error", err)
+			os.Exit(1)
+		}
+		background.Add(1)
+		go func() {
+			defer background.Done()
+			defer consumer.Close()
+			if err := consumer.Run(ctx); err != nil {
+				logger.Error("чтение уведомлений из AMQP остановлено", "error", err)
+				alert.Alert(alert.Critical, "Чтение уведомлений из AMQP остановлено: "+err.Error())
+			}
+		}()
+	}
+
+	err = srv.ListenAndServe(ctx, cfg.ServerAddr)
+	stop()
+	background.Wait()
+	closeQueues(queues, cfg.ShutdownTimeout, logger)
+	closeSenders(senders, cfg.ShutdownTimeout, logger)
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		logger.Error("HTTP API остановлен с ошибкой", "error", err)
+		os.Exit(1)
+	}
+}
+
+// setTransport подключает к почтовому клиенту провайдера из cfg.
+func setTransport(mail *email.Client, cfg *config.Config) error {
+	transport, err := envclient.EmailTransport(cfg)
+	if err != nil {
+		return err
+	}
+	mail.SetTransport(transport)
+	return nil
+}
+
+// closeQueues дожидается отправки очередей рассылок не дольше timeout; оставшиеся сообщения
+// сохраняются в каталог NOTEPHEE_SPOOL_DIR.
+func closeQueues(queues []*queue.Dispatcher, timeout time.Duration, logger *slog.Logger) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	for _, d := range queues {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := d.Close(ctx); err != nil {
+				logger.Warn("очередь рассылок закрыта с неотправленными сообщениями", "channel", d.Channel(), "error", err)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// closeSenders закрывает клиентов каналов, дожидаясь начатых отправок не дольше timeout.
+func closeSenders(senders []notify.Sender, timeout time.Duration, logger *slog.Logger) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	for _, s := range senders {
+		c, ok := s.(notify.Closer)
+		if !ok {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := c.Close(ctx); err != nil {
+				logger.Warn("клиент канала закрыт с незавершёнными отправками", "channel", s.Channel(), "error", err)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// serveGRPC запускает gRPC API в фоне и останавливает его по завершении ctx. Вызовы принимаются
+// только с токеном token; без него API не запускается.
+func serveGRPC(ctx context.Context, addr, token string, svc *grpcapi.Service, logger *slog.Logger) error {
+	if token == "" {
+		return errors.New("gRPC API требует NOTEPHEE_SERVER_TOKEN")
+	}
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	srv := grpc.NewServer(grpcapi.TokenAuth(token)...)
+	svc.Register(srv)
+
+	go func() {
+		logger.Info("gRPC API notephee запущен", "addr", addr)
+		if err := srv.Serve(lis); err != nil {
+			logger.Error("gRPC API остановлен с ошибкой", "error", err)
+		}
+	}()
+	go func() {
+		<-ctx.Done()
+		srv.GracefulStop()
+	}()
+	return nil
+}
+
+// deliveryLog — журнал доставки, который хранит и открытия писем для отслеживания.
+type deliveryLog interface {
+	delivery.DeliveryLog
+	delivery.EngagementLog
+}
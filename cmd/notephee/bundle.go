@@ -0,0 +1,211 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+
+	"github.com/epheer/notephee/config"
+	"github.com/epheer/notephee/reload"
+	"github.com/epheer/notephee/templates"
+)
+
+// configExport реализует «notephee config export»: выгружает текущие настройки NOTEPHEE_* без секретов,
+// версии шаблонов и перезагружаемые политики сервера в подписанный пакет.
+func (c *cli) configExport(args []string) int {
+	fs := c.flags("config export")
+	out := fs.String("out", "", "файл пакета (по умолчанию stdout)")
+	server := fs.String("server", serverURL(c.cfg.ServerAddr), "адрес HTTP API notephee-server, с которого выгружаются шаблоны и политики")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if c.cfg.AdminToken == "" {
+		return c.fail("для выгрузки шаблонов и политик нужен токен административного API NOTEPHEE_ADMIN_TOKEN")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	var current reload.Config
+	if err := c.admin(ctx, http.MethodGet, *server, "/v1/admin/config", nil, &current); err != nil {
+		return c.fail("не удалось получить политики: %v", err)
+	}
+	// Тексты шаблонов переносятся публикацией, а в пакет попадают только их версии
+	current.Templates = nil
+	policies, err := json.Marshal(current)
+	if err != nil {
+		return c.fail("%v", err)
+	}
+	var versions []templates.Version
+	if err := c.admin(ctx, http.MethodGet, *server, "/v1/admin/templates", nil, &versions); err != nil {
+		return c.fail("не удалось получить версии шаблонов: %v", err)
+	}
+	rt := config.Runtime{Policies: policies}
+	for _, v := range versions {
+		rt.Templates = append(rt.Templates, config.TemplateRef{Name: v.Name, Version: v.Version})
+	}
+
+	data, err := config.ExportBundle(os.Environ(), rt, []byte(c.cfg.BundleKey))
+	if err != nil {
+		return c.fail("%v", err)
+	}
+	data = append(data, '\n')
+
+	if *out == "" {
+		_, _ = c.stdout.Write(data)
+		return 0
+	}
+	if err := os.WriteFile(*out, data, 0o644); err != nil {
+		return c.fail("не удалось записать %s: %v", *out, err)
+	}
+	_, _ = fmt.Fprintf(c.stdout, "пакет конфигурации записан: %s\n", *out)
+	return 0
+}
+
+// configImport реализует «notephee config import»: проверяет подпись пакета и записывает его настройки
+// в env-файл, сохраняя уже заданные в нём секреты. С --server сначала проверяет, что на сервере
+// опубликованы версии шаблонов из пакета, а после записи применяет к нему политики пакета.
+func (c *cli) configImport(args []string) int {
+	fs := c.flags("config import")
+	in := fs.String("in", "", "файл пакета")
+	out := fs.String("out", "", "env-файл окружения, в который импортируются настройки")
+	server := fs.String("server", "", "адрес HTTP API notephee-server, к которому применяются шаблоны и политики пакета")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *in == "" || *out == "" {
+		return c.fail("флаги --in и --out обязательны")
+	}
+
+	data, err := os.ReadFile(*in)
+	if err != nil {
+		return c.fail("не удалось прочитать %s: %v", *in, err)
+	}
+	bundle, err := config.ImportBundle(data, []byte(c.cfg.BundleKey))
+	if err != nil {
+		return c.fail("%s: %v", *in, err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	if *server != "" {
+		if c.cfg.AdminToken == "" {
+			return c.fail("для применения шаблонов и политик нужен токен административного API NOTEPHEE_ADMIN_TOKEN")
+		}
+		if err := c.checkTemplates(ctx, *server, bundle.Templates); err != nil {
+			return c.fail("%v", err)
+		}
+	}
+
+	current, err := godotenv.Read(*out)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return c.fail("не удалось прочитать %s: %v", *out, err)
+	}
+	env, err := godotenv.Marshal(bundle.Merge(current))
+	if err != nil {
+		return c.fail("%v", err)
+	}
+	// В env-файле остаются секреты окружения, поэтому он доступен только владельцу
+	if err := os.WriteFile(*out, []byte(env+"\n"), 0o600); err != nil {
+		return c.fail("не удалось записать %s: %v", *out, err)
+	}
+	_, _ = fmt.Fprintf(c.stdout, "импортировано настроек: %d в %s\n", len(bundle.Settings), *out)
+
+	if *server == "" || len(bundle.Policies) == 0 {
+		return 0
+	}
+	if err := c.admin(ctx, http.MethodPost, *server, "/v1/admin/reload", bundle.Policies, nil); err != nil {
+		return c.fail("не удалось применить политики: %v", err)
+	}
+	_, _ = fmt.Fprintf(c.stdout, "политики применены: %s\n", *server)
+	return 0
+}
+
+// checkTemplates проверяет, что на сервере опубликованы именно те версии шаблонов, на которые ссылается пакет.
+func (c *cli) checkTemplates(ctx context.Context, server string, refs []config.TemplateRef) error {
+	if len(refs) == 0 {
+		return nil
+	}
+	var versions []templates.Version
+	if err := c.admin(ctx, http.MethodGet, server, "/v1/admin/templates", nil, &versions); err != nil {
+		return fmt.Errorf("не удалось получить версии шаблонов: %w", err)
+	}
+	published := make(map[string]int, len(versions))
+	for _, v := range versions {
+		published[v.Name] = v.Version
+	}
+	var mismatched []string
+	for _, ref := range refs {
+		if published[ref.Name] != ref.Version {
+			mismatched = append(mismatched, fmt.Sprintf("%s@v%d", ref.Name, ref.Version))
+		}
+	}
+	if len(mismatched) > 0 {
+		return fmt.Errorf("на сервере не опубликованы версии шаблонов из пакета: %s", strings.Join(mismatched, ", "))
+	}
+	return nil
+}
+
+// admin выполняет запрос к административному API сервера и разбирает JSON-ответ в out, если он задан.
+func (c *cli) admin(ctx context.Context, method, server, path string, body []byte, out any) error {
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimSuffix(server, "/")+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.cfg.AdminToken)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("сервер недоступен: %w", err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		var e struct {
+			Error string `json:"error"`
+		}
+		_ = json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&e)
+		return fmt.Errorf("код %d: %s", resp.StatusCode, e.Error)
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("некорректный ответ сервера: %w", err)
+	}
+	return nil
+}
+
+// configCheck реализует «notephee config check»: проверяет настройки и выводит все ошибки сразу.
+func (c *cli) configCheck(args []string) int {
+	fs := c.flags("config check")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	err := c.cfg.Validate()
+	var verr *config.ValidationError
+	if errors.As(err, &verr) {
+		for _, fe := range verr.Errors {
+			_, _ = fmt.Fprintf(c.stdout, "%s: %s\n", fe.Field, fe.Message)
+		}
+		return c.fail("найдено ошибок конфигурации: %d", len(verr.Errors))
+	}
+	if err != nil {
+		return c.fail("%v", err)
+	}
+	_, _ = fmt.Fprintln(c.stdout, "конфигурация корректна")
+	return 0
+}
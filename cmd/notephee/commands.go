@@ -0,0 +1,170 @@
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/epheer/notephee/email"
+	"github.com/epheer/notephee/envclient"
+	"github.com/epheer/notephee/telegram"
+)
+
+func (c *cli) flags(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(c.stderr)
+	return fs
+}
+
+// tgSend реализует «notephee tg send».
+func (c *cli) tgSend(args []string) int {
+	fs := c.flags("tg send")
+	chat := fs.Int64("chat", 0, "chatID получателя")
+	textFlag := fs.String("text", "", "текст сообщения")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *chat == 0 || *textFlag == "" {
+		return c.fail("флаги --chat и --text обязательны")
+	}
+
+	text, err := c.text(*textFlag)
+	if err != nil {
+		return c.fail("%v", err)
+	}
+
+	client := envclient.Telegram(c.cfg, c.logger)
+	if _, err := client.SendText(telegram.MessageOptions{ChatID: *chat, Text: text}); err != nil {
+		return c.fail("ошибка отправки в Telegram: %v", err)
+	}
+	_, _ = fmt.Fprintf(c.stdout, "отправлено: telegram %d\n", *chat)
+	return 0
+}
+
+// emailSend реализует «notephee email send».
+func (c *cli) emailSend(args []string) int {
+	fs := c.flags("email send")
+	to := fs.String("to", "", "email получателя")
+	subject := fs.String("subject", "", "тема письма")
+	textFlag := fs.String("text", "", "текст письма")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *to == "" || *textFlag == "" {
+		return c.fail("флаги --to и --text обязательны")
+	}
+
+	text, err := c.text(*textFlag)
+	if err != nil {
+		return c.fail("%v", err)
+	}
+
+	client := envclient.Email(c.cfg, c.logger)
+	if err := client.SendText(email.MessageOptions{To: *to, Subject: *subject, Body: text}); err != nil {
+		return c.fail("ошибка отправки email: %v", err)
+	}
+	_, _ = fmt.Fprintf(c.stdout, "отправлено: email %s\n", *to)
+	return 0
+}
+
+// broadcast реализует «notephee broadcast»: рассылка по списку из CSV через все указанные в нём каналы.
+func (c *cli) broadcast(args []string) int {
+	fs := c.flags("broadcast")
+	file := fs.String("file", "", "CSV-файл с колонками channel,address")
+	subject := fs.String("subject", "", "тема письма (для email)")
+	textFlag := fs.String("text", "", "текст сообщения")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *file == "" || *textFlag == "" {
+		return c.fail("флаги --file и --text обязательны")
+	}
+
+	text, err := c.text(*textFlag)
+	if err != nil {
+		return c.fail("%v", err)
+	}
+
+	f, err := os.Open(*file)
+	if err != nil {
+		return c.fail("не удалось открыть %s: %v", *file, err)
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	chatIDs, emails, err := readRecipients(f)
+	if err != nil {
+		return c.fail("%v", err)
+	}
+
+	failed := 0
+	if len(chatIDs) > 0 {
+		client := envclient.Telegram(c.cfg, c.logger)
+		for _, res := range client.SendMessaging(telegram.SendingOptions{ChatIDs: chatIDs, Text: text}) {
+			failed += c.report(telegram.Channel, strconv.FormatInt(res.ChatID, 10), res.Error)
+		}
+	}
+	if len(emails) > 0 {
+		client := envclient.Email(c.cfg, c.logger)
+		for _, res := range client.SendMessaging(email.SendingOptions{Recipients: emails, Subject: *subject, Body: text}) {
+			failed += c.report(email.Channel, res.To, res.Error)
+		}
+	}
+
+	_, _ = fmt.Fprintf(c.stdout, "итого: %d, ошибок: %d\n", len(chatIDs)+len(emails), failed)
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+// report печатает результат одной отправки и возвращает 1 при ошибке.
+func (c *cli) report(channel, to string, err error) int {
+	if err != nil {
+		_, _ = fmt.Fprintf(c.stdout, "ошибка\t%s\t%s\t%v\n", channel, to, err)
+		return 1
+	}
+	_, _ = fmt.Fprintf(c.stdout, "ok\t%s\t%s\n", channel, to)
+	return 0
+}
+
+// readRecipients читает CSV с колонками channel,address.
+func readRecipients(r io.Reader) ([]int64, []string, error) {
+	rows, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return nil, nil, fmt.Errorf("некорректный CSV: %w", err)
+	}
+
+	var (
+		chatIDs []int64
+		emails  []string
+	)
+	for i, row := range rows {
+		if len(row) < 2 {
+			return nil, nil, fmt.Errorf("строка %d: ожидались колонки channel,address", i+1)
+		}
+		channel, address := strings.ToLower(strings.TrimSpace(row[0])), strings.TrimSpace(row[1])
+		if i == 0 && channel == "channel" {
+			continue
+		}
+
+		switch channel {
+		case telegram.Channel, "tg":
+			id, err := strconv.ParseInt(address, 10, 64)
+			if err != nil {
+				return nil, nil, fmt.Errorf("строка %d: некорректный chatID %q", i+1, address)
+			}
+			chatIDs = append(chatIDs, id)
+		case email.Channel:
+			emails = append(emails, address)
+		default:
+			return nil, nil, fmt.Errorf("строка %d: неизвестный канал %q", i+1, row[0])
+		}
+	}
+	return chatIDs, emails, nil
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/epheer/notephee/config"
+)
+
+func TestReadRecipients(t *testing.T) {
+	csv := "channel,address\ntelegram,123\nemail,a@b.c\ntg,-100500\n"
+	chatIDs, emails, err := readRecipients(strings.NewReader(csv))
+	if err != nil {
+		t.Fatalf("Ошибка readRecipients: %v", err)
+	}
+	if len(chatIDs) != 2 || chatIDs[1] != -100500 || len(emails) != 1 || emails[0] != "a@b.c" {
+		t.Fatalf("неверно разобран CSV: %v %v", chatIDs, emails)
+	}
+
+	if _, _, err := readRecipients(strings.NewReader("sms,+7900\n")); err == nil {
+		t.Fatal("неизвестный канал должен давать ошибку")
+	}
+}
+
+func TestImportHistory(t *testing.T) {
+	var query url.Values
+	var body string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/admin/import" || r.Header.Get("Authorization") != "Bearer admin" {
+			t.Errorf("неожиданный запрос: %s %s", r.URL.Path, r.Header.Get("Authorization"))
+		}
+		query = r.URL.Query()
+		data, _ := io.ReadAll(r.Body)
+		body = string(data)
+		_, _ = w.Write([]byte(`{"read":2,"imported":1,"skipped":0,"suppressed":0,"errors":[{"row":2,"error":"нет адреса"}]}`))
+	}))
+	defer srv.Close()
+
+	file := filepath.Join(t.TempDir(), "history.json")
+	if err := os.WriteFile(file, []byte(`[{"recipient":"a@example.com"},{}]`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	var stdout, stderr bytes.Buffer
+	c := &cli{cfg: &config.Config{AdminToken: "admin"}, logger: slog.Default(), stdout: &stdout, stderr: &stderr}
+	code := c.importHistory([]string{"--format", "json", "--column", "recipient=email", "--channel", "email", "--server", srv.URL, file})
+	if code != 1 {
+		t.Fatalf("при пропущенных записях ожидался код 1, получен %d: %s", code, stderr.String())
+	}
+	if query.Get("format") != "json" || query.Get("column") != "recipient=email" || query.Get("channel") != "email" || !strings.HasPrefix(body, "[") {
+		t.Fatalf("файл и параметры не переданы серверу: %v %q", query, body)
+	}
+	if !strings.Contains(stdout.String(), "запись 2") || !strings.Contains(stdout.String(), "записано: 1") {
+		t.Fatalf("итог импорта не выведен: %s", stdout.String())
+	}
+
+	if code := c.importHistory([]string{"--format", "xml", file}); code != 1 {
+		t.Fatalf("неизвестный формат должен давать ошибку, получен код %d", code)
+	}
+}
+
+func TestConfigBundle(t *testing.T) {
+	version := 2
+	var reloaded string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method + " " + r.URL.Path {
+		case "GET /v1/admin/config":
+			_, _ = w.Write([]byte(`{"indeterminate_policy":"never","templates":[{"name":"otp","body":"Код: {{.code}}"}]}`))
+		case "GET /v1/admin/templates":
+			_, _ = fmt.Fprintf(w, `[{"name":"otp","version":%d,"body":"Код: {{.code}}"}]`, version)
+		case "POST /v1/admin/reload":
+			data, _ := io.ReadAll(r.Body)
+			reloaded = string(data)
+			_, _ = w.Write([]byte(`{}`))
+		default:
+			t.Errorf("неожиданный запрос: %s %s", r.Method, r.URL.Path)
+		}
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	bundle, env := filepath.Join(dir, "bundle.json"), filepath.Join(dir, ".env.production")
+	var stdout, stderr bytes.Buffer
+	c := &cli{cfg: &config.Config{AdminToken: "admin", BundleKey: strings.Repeat("k", 32)}, logger: slog.Default(), stdout: &stdout, stderr: &stderr}
+	if code := c.configExport([]string{"--out", bundle, "--server", srv.URL}); code != 0 {
+		t.Fatalf("Ошибка config export: %s", stderr.String())
+	}
+	data, _ := os.ReadFile(bundle)
+	if !strings.Contains(string(data), `"name": "otp"`) || strings.Contains(string(data), "Код") {
+		t.Fatalf("в пакете должны быть версии шаблонов без текстов: %s", data)
+	}
+
+	version = 3
+	if code := c.configImport([]string{"--in", bundle, "--out", env, "--server", srv.URL}); code != 1 {
+		t.Fatalf("при другой версии шаблона ожидался код 1, получен %d", code)
+	}
+	if _, err := os.Stat(env); !os.IsNotExist(err) || reloaded != "" {
+		t.Fatal("при расхождении версий шаблонов импорт ничего не должен менять")
+	}
+
+	version = 2
+	if code := c.configImport([]string{"--in", bundle, "--out", env, "--server", srv.URL}); code != 0 {
+		t.Fatalf("Ошибка config import: %s", stderr.String())
+	}
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, []byte(reloaded)); err != nil || compact.String() != `{"indeterminate_policy":"never"}` {
+		t.Fatalf("к серверу должны применяться политики пакета, получено %q", reloaded)
+	}
+}
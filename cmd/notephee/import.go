@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/epheer/notephee/backfill"
+)
+
+// importResult — ответ POST /v1/admin/import.
+type importResult struct {
+	Read       int    `json:"read"`
+	Imported   int    `json:"imported"`
+	Skipped    int    `json:"skipped"`
+	Suppressed int    `json:"suppressed"`
+	Error      string `json:"error"`
+	Errors     []struct {
+		Row   int    `json:"row"`
+		Error string `json:"error"`
+	} `json:"errors"`
+}
+
+// importHistory реализует «notephee import»: загружает историю прежней системы рассылок в журнал
+// доставки и список подавления сервера. Файл отправляется на POST /v1/admin/import, потому что
+// базой данных владеет сервер.
+func (c *cli) importHistory(args []string) int {
+	fs := c.flags("import")
+	formatFlag := fs.String("format", "csv", "формат файла: csv или json")
+	channel := fs.String("channel", "", "канал для строк без колонки channel")
+	var columns columnFlags
+	fs.Var(&columns, "column", "колонка источника для поля записи: поле=колонка, например recipient=email (можно повторять)")
+	dryRun := fs.Bool("dry-run", false, "только проверить файл, ничего не записывая")
+	server := fs.String("server", serverURL(c.cfg.ServerAddr), "адрес HTTP API notephee-server")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 1 {
+		return c.fail("нужен один файл с историей")
+	}
+	format, err := backfill.ParseFormat(*formatFlag)
+	if err != nil {
+		return c.fail("%v", err)
+	}
+	if c.cfg.AdminToken == "" {
+		return c.fail("для импорта нужен токен административного API NOTEPHEE_ADMIN_TOKEN")
+	}
+
+	f, err := os.Open(fs.Arg(0))
+	if err != nil {
+		return c.fail("не удалось открыть %s: %v", fs.Arg(0), err)
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	q := url.Values{"format": {string(format)}, "column": columns}
+	if *channel != "" {
+		q.Set("channel", *channel)
+	}
+	if *dryRun {
+		q.Set("dry_run", "1")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Hour)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(*server, "/")+"/v1/admin/import?"+q.Encode(), f)
+	if err != nil {
+		return c.fail("%v", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+c.cfg.AdminToken)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return c.fail("сервер недоступен: %v", err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	var res importResult
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return c.fail("некорректный ответ сервера: код %d: %v", resp.StatusCode, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return c.fail("импорт не выполнен: код %d: %s", resp.StatusCode, res.Error)
+	}
+
+	for _, e := range res.Errors {
+		_, _ = fmt.Fprintf(c.stdout, "ошибка\tзапись %d\t%s\n", e.Row, e.Error)
+	}
+	_, _ = fmt.Fprintf(c.stdout, "прочитано: %d, записано: %d, уже были: %d, подавлено: %d, ошибок: %d\n",
+		res.Read, res.Imported, res.Skipped, res.Suppressed, len(res.Errors))
+	if len(res.Errors) > 0 {
+		return 1
+	}
+	return 0
+}
+
+// columnFlags собирает повторяющийся флаг --column.
+type columnFlags []string
+
+func (f *columnFlags) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *columnFlags) Set(v string) error {
+	if field, column, ok := strings.Cut(v, "="); !ok || field == "" || column == "" {
+		return fmt.Errorf("ожидается поле=колонка, получено %q", v)
+	}
+	*f = append(*f, v)
+	return nil
+}
+
+// serverURL возвращает адрес HTTP API по NOTEPHEE_SERVER_ADDR; адрес без хоста означает локальный сервер.
+func serverURL(addr string) string {
+	if strings.HasPrefix(addr, ":") {
+		addr = "127.0.0.1" + addr
+	}
+	return "http://" + addr
+}
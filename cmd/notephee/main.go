@@ -0,0 +1,140 @@
+// Команда notephee отправляет уведомления из командной строки: для скриптов эксплуатации и реагирования на инциденты.
+//
+// Использование:
+//
+//	notephee tg send --chat 123 --text "..."
+//	notephee email send --to a@b.c --subject "..." --text "..."
+//	notephee broadcast --file recipients.csv --subject "..." --text "..."
+//	notephee replay --dir snapshots --to a@b.c --date 2026-03-03
+//	notephee import --format csv history.csv
+//	notephee config export --out staging.bundle.json
+//	notephee config import --in staging.bundle.json --out .env.production --server http://prod:8080
+//
+// Настройки читаются из переменных окружения NOTEPHEE_* (и env-файла, указанного в --env), файла
+// настроек из --config и глобальных флагов вида --smtp-host: флаги важнее окружения, окружение — файла.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/epheer/notephee/config"
+	"github.com/epheer/notephee/config/secrets"
+)
+
+const usage = `Использование:
+  notephee [--env FILE] [--config FILE] [--<настройка> VALUE]... <команда>
+  notephee [--env FILE] tg send --chat ID --text TEXT
+  notephee [--env FILE] email send --to EMAIL --subject SUBJECT --text TEXT
+  notephee [--env FILE] broadcast --file recipients.csv [--subject SUBJECT] --text TEXT
+  notephee replay --dir DIR (--id DELIVERY_ID | --to ADDRESS [--date 2006-01-02])
+  notephee [--env FILE] import --format csv|json [--column FIELD=COLUMN]... [--channel CHANNEL] [--dry-run] [--server URL] FILE
+  notephee [--env FILE] config export [--out FILE] [--server URL]
+  notephee [--env FILE] config import --in FILE --out ENV_FILE [--server URL]
+  notephee [--env FILE] config check
+
+Значение "-" в --text читает текст из stdin.
+CSV для broadcast: channel,address (channel: telegram или email), строка заголовка необязательна.
+import отправляет историю прежней системы рассылок в notephee-server с токеном NOTEPHEE_ADMIN_TOKEN.
+Пакеты конфигурации подписываются ключом из NOTEPHEE_BUNDLE_KEY; шаблоны и политики сервера
+выгружаются и применяются с токеном NOTEPHEE_ADMIN_TOKEN.
+`
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	global := flag.NewFlagSet("notephee", flag.ContinueOnError)
+	global.SetOutput(stderr)
+	global.Usage = func() { _, _ = fmt.Fprint(stderr, usage) }
+	envPath := global.String("env", ".env", "путь к env-файлу с настройками NOTEPHEE_*")
+	configPath := global.String("config", "", "файл настроек .yaml, .json или .toml")
+	verbose := global.Bool("v", false, "подробный лог")
+	settings := config.RegisterFlags(global)
+	if err := global.Parse(args); err != nil {
+		return 2
+	}
+
+	level := slog.LevelWarn
+	if *verbose {
+		level = slog.LevelDebug
+	}
+	logger := slog.New(slog.NewTextHandler(stderr, &slog.HandlerOptions{Level: level}))
+
+	if _, err := os.Stat(*envPath); err == nil {
+		_ = config.LoadEnv(*envPath)
+	}
+
+	cfg, err := loadConfig(config.Sources{File: *configPath, Flags: settings.Values()}, logger)
+	if err != nil {
+		_, _ = fmt.Fprintf(stderr, "notephee: %v\n", err)
+		return 1
+	}
+
+	cli := &cli{cfg: cfg, logger: logger, stdin: stdin, stdout: stdout, stderr: stderr}
+
+	rest := global.Args()
+	switch {
+	case len(rest) >= 2 && rest[0] == "tg" && rest[1] == "send":
+		return cli.tgSend(rest[2:])
+	case len(rest) >= 2 && rest[0] == "email" && rest[1] == "send":
+		return cli.emailSend(rest[2:])
+	case len(rest) >= 1 && rest[0] == "broadcast":
+		return cli.broadcast(rest[1:])
+	case len(rest) >= 1 && rest[0] == "replay":
+		return cli.replay(rest[1:])
+	case len(rest) >= 1 && rest[0] == "import":
+		return cli.importHistory(rest[1:])
+	case len(rest) >= 2 && rest[0] == "config" && rest[1] == "export":
+		return cli.configExport(rest[2:])
+	case len(rest) >= 2 && rest[0] == "config" && rest[1] == "import":
+		return cli.configImport(rest[2:])
+	case len(rest) >= 2 && rest[0] == "config" && rest[1] == "check":
+		return cli.configCheck(rest[2:])
+	}
+
+	global.Usage()
+	return 2
+}
+
+// cli хранит общее окружение подкоманд.
+type cli struct {
+	cfg    *config.Config
+	logger *slog.Logger
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+}
+
+// text возвращает текст сообщения, читая stdin для значения "-".
+func (c *cli) text(value string) (string, error) {
+	if value != "-" {
+		return value, nil
+	}
+	data, err := io.ReadAll(c.stdin)
+	if err != nil {
+		return "", fmt.Errorf("не удалось прочитать stdin: %w", err)
+	}
+	return strings.TrimRight(string(data), "\n"), nil
+}
+
+// loadConfig загружает настройки из src с секретами из хранилища NOTEPHEE_SECRETS_PROVIDER, если оно задано.
+func loadConfig(src config.Sources, logger *slog.Logger) (*config.Config, error) {
+	store, err := secrets.FromSources(src)
+	if err != nil {
+		return nil, err
+	}
+	src.Secrets = store
+	return config.LoadFrom(context.Background(), src, logger)
+}
+
+func (c *cli) fail(format string, args ...any) int {
+	_, _ = fmt.Fprintf(c.stderr, "notephee: "+format+"\n", args...)
+	return 1
+}
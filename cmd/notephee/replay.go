@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/epheer/notephee/replay"
+)
+
+// replay реализует «notephee replay»: заново собирает исторические сообщения по сохранённым снимкам.
+func (c *cli) replay(args []string) int {
+	fs := c.flags("replay")
+	dir := fs.String("dir", "", "каталог снимков replay.FileStore")
+	id := fs.String("id", "", "ID доставки")
+	to := fs.String("to", "", "адрес получателя")
+	date := fs.String("date", "", "день отправки в формате 2006-01-02 (в UTC)")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *dir == "" || (*id == "" && *to == "") {
+		return c.fail("нужны флаг --dir и один из флагов --id или --to")
+	}
+
+	store, err := replay.NewFileStore(*dir)
+	if err != nil {
+		return c.fail("%v", err)
+	}
+	ctx := context.Background()
+
+	var snaps []replay.Snapshot
+	if *id != "" {
+		snap, err := store.Get(ctx, *id)
+		if err != nil {
+			return c.fail("%s: %v", *id, err)
+		}
+		snaps = append(snaps, *snap)
+	} else {
+		q := replay.Query{Recipient: *to}
+		if *date != "" {
+			day, err := time.Parse(time.DateOnly, *date)
+			if err != nil {
+				return c.fail("некорректная дата %q: %v", *date, err)
+			}
+			q.From, q.To = day, day.AddDate(0, 0, 1)
+		}
+		if snaps, err = store.Find(ctx, q); err != nil {
+			return c.fail("%v", err)
+		}
+	}
+	if len(snaps) == 0 {
+		return c.fail("снимки не найдены")
+	}
+
+	mismatched := 0
+	for _, snap := range snaps {
+		if !c.printReplay(snap) {
+			mismatched++
+		}
+	}
+	if mismatched > 0 {
+		return 1
+	}
+	return 0
+}
+
+// printReplay печатает снимок и собранное по нему сообщение. Возвращает false,
+// если сообщение не удалось собрать или оно не совпало с отправленным.
+func (c *cli) printReplay(snap replay.Snapshot) bool {
+	w := c.stdout
+	_, _ = fmt.Fprintf(w, "=== %s\n", snap.DeliveryID)
+	_, _ = fmt.Fprintf(w, "время:\t%s\nканал:\t%s\nполучатель:\t%s\n", snap.CreatedAt.Format(time.RFC3339), snap.Channel, snap.Recipient)
+	if snap.Campaign != "" {
+		_, _ = fmt.Fprintf(w, "кампания:\t%s\n", snap.Campaign)
+	}
+	if snap.Template != "" {
+		_, _ = fmt.Fprintf(w, "шаблон:\t%s v%d\n", snap.Template, snap.TemplateVersion)
+	}
+
+	keys := make([]string, 0, len(snap.Config))
+	for k := range snap.Config {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		_, _ = fmt.Fprintf(w, "config.%s:\t%s\n", k, snap.Config[k])
+	}
+
+	res, err := replay.Render(snap)
+	if err != nil {
+		_, _ = fmt.Fprintf(w, "ошибка сборки:\t%v\n\n", err)
+		return false
+	}
+	match := "да"
+	if !res.Matches {
+		match = "нет"
+	}
+	_, _ = fmt.Fprintf(w, "совпадает с отправленным:\t%s\n", match)
+	if res.Subject != "" {
+		_, _ = fmt.Fprintf(w, "\nТема: %s\n", res.Subject)
+	}
+	_, _ = fmt.Fprintf(w, "\n%s\n\n", res.Text)
+	return res.Matches
+}
@@ -0,0 +1,192 @@
+package config
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// BundleVersion — версия формата пакета конфигурации.
+const BundleVersion = 1
+
+// Bundle — подписанный пакет конфигурации для переноса проверенной конфигурации между окружениями,
+// например со staging в production: настройки NOTEPHEE_*, версии шаблонов и перезагружаемые политики.
+//
+// Секреты (токены, пароли, ключи API, вебхуки) в пакет не попадают: у каждого окружения они свои
+// и при импорте берутся из его env-файла.
+type Bundle struct {
+	Version   int               `json:"version"`             // Версия формата
+	Settings  map[string]string `json:"settings"`            // Переменные NOTEPHEE_* без секретов
+	Templates []TemplateRef     `json:"templates,omitempty"` // Версии шаблонов, упорядоченные по имени
+	Policies  json.RawMessage   `json:"policies,omitempty"`  // Политики в формате POST /v1/admin/reload без шаблонов
+	Signature string            `json:"signature,omitempty"` // HMAC-SHA256 всего пакета, кроме подписи
+}
+
+// TemplateRef — ссылка на опубликованную версию шаблона. Тексты шаблонов в пакет не входят:
+// при импорте проверяется, что в целевом окружении опубликованы те же версии.
+type TemplateRef struct {
+	Name    string `json:"name"`    // Имя шаблона
+	Version int    `json:"version"` // Номер версии
+}
+
+// Runtime — состояние сервера, которое переносится вместе с настройками: версии шаблонов
+// и перезагружаемые политики (ответ GET /v1/admin/config без раздела templates).
+type Runtime struct {
+	Templates []TemplateRef
+	Policies  json.RawMessage
+}
+
+// plainNames — настройки, которые не являются секретами и переносятся в пакете. Всё, чего здесь нет,
+// считается секретом: новая переменная не утечёт в пакет, пока её явно не добавят в список.
+var plainNames = map[string]bool{
+	"TELEGRAM_BOT_NAME": true, "TELEGRAM_API_URL": true,
+	"SMTP_HOST": true, "SMTP_PORT": true, "SMTP_USER": true, "SMTP_FROM_NAME": true,
+	"EMAIL_PROVIDER": true, "MAILGUN_DOMAIN": true, "MAILGUN_REGION": true, "SES_REGION": true, "EMAIL_DOMAIN_LIMITS": true,
+	"IMAP_ADDR": true, "IMAP_SENT_FOLDER": true,
+	"MATRIX_HOMESERVER": true, "VIBER_SENDER_NAME": true, "VIBER_SENDER_AVATAR": true,
+	"SERVER_ADDR": true, "GRPC_ADDR": true,
+	"KAFKA_BROKERS": true, "KAFKA_TOPIC": true, "KAFKA_GROUP": true, "KAFKA_DLQ_TOPIC": true, "KAFKA_FORMAT": true,
+	"NATS_STREAM": true, "NATS_CONSUMER": true, "NATS_SUBJECT": true, "NATS_DLQ_SUBJECT": true, "AMQP_QUEUE": true,
+	"SQLITE_PATH":  true,
+	"DEDUP_WINDOW": true, "DIGEST_INTERVAL": true, "OPT_IN_CATEGORIES": true, "UNSUBSCRIBE_URL": true, "TRACKING_URL": true,
+	"DEGRADE_LATENCY": true, "DEGRADE_LOW": true, "DEGRADE_NORMAL": true, "INDETERMINATE_POLICY": true,
+	"OVERFLOW_STRATEGY": true, "OVERFLOW_CATEGORIES": true, "OVERFLOW_MORE_URL": true, "QUEUE_RATES": true,
+	"SPOOL_DIR": true, "SHUTDOWN_TIMEOUT": true, "REPLAY_DIR": true, "ALERT_TELEGRAM_CHAT": true, "ALERT_EMAIL": true,
+	// Настройки подключения к хранилищу секретов сами секретом не являются
+	"SECRETS_PROVIDER": true, "SECRETS_PATH": true, "SECRETS_REFRESH": true,
+}
+
+// secretNames — секреты: токены, пароли, ключи подписи, вебхуки и адреса подключений, в которых
+// бывают пароли. Список нужен, чтобы каждая переменная из Names была классифицирована явно.
+var secretNames = map[string]bool{
+	"TELEGRAM_TOKEN": true, "TELEGRAM_PROXY": true, "SMTP_PASSWORD": true,
+	"SENDGRID_API_KEY": true, "MAILGUN_API_KEY": true, "SES_ACCESS_KEY_ID": true, "SES_SECRET_ACCESS_KEY": true,
+	"SLACK_WEBHOOK_URL": true, "SLACK_TOKEN": true, "TEAMCHAT_TARGETS": true, "IDENTITIES": true, "BUNDLE_KEY": true,
+	"MATRIX_TOKEN": true, "VK_TOKEN": true, "VIBER_TOKEN": true,
+	"SERVER_TOKEN": true, "ADMIN_TOKEN": true,
+	"NATS_URL": true, "AMQP_URL": true, "REDIS_URL": true, "POSTGRES_URL": true,
+	"UNSUBSCRIBE_KEY": true, "TRACKING_KEY": true,
+}
+
+// IsSecret сообщает, считается ли переменная name (с префиксом NOTEPHEE_ или без) секретом, который
+// не переносится в пакете и не задаётся флагом. Секретом считается всё, что не отмечено как обычная настройка.
+func IsSecret(name string) bool {
+	return !plainNames[strings.TrimPrefix(name, "NOTEPHEE_")]
+}
+
+// ExportBundle собирает из environ (в формате os.Environ) непустые переменные NOTEPHEE_*,
+// кроме секретов, добавляет версии шаблонов и политики из rt и подписывает пакет ключом key
+// (не короче 32 байт).
+//
+// Одинаковые настройки дают побайтно одинаковый пакет, поэтому его можно хранить в репозитории
+// и сравнивать diff-ом.
+func ExportBundle(environ []string, rt Runtime, key []byte) ([]byte, error) {
+	if err := checkKey(key); err != nil {
+		return nil, err
+	}
+
+	b := Bundle{Version: BundleVersion, Settings: make(map[string]string)}
+	if len(rt.Policies) > 0 {
+		if !json.Valid(rt.Policies) {
+			return nil, fmt.Errorf("некорректный JSON политик")
+		}
+		b.Policies = rt.Policies
+	}
+	if len(rt.Templates) > 0 {
+		b.Templates = append([]TemplateRef(nil), rt.Templates...)
+		sort.Slice(b.Templates, func(i, j int) bool { return b.Templates[i].Name < b.Templates[j].Name })
+	}
+	for _, kv := range environ {
+		name, value, ok := strings.Cut(kv, "=")
+		if !ok || !strings.HasPrefix(name, "NOTEPHEE_") || value == "" || IsSecret(name) {
+			continue
+		}
+		b.Settings[name] = value
+	}
+
+	sig, err := b.sign(key)
+	if err != nil {
+		return nil, err
+	}
+	b.Signature = sig
+	return json.MarshalIndent(b, "", "  ")
+}
+
+// ImportBundle проверяет ключ key (не короче 32 байт), версию и подпись пакета и возвращает его.
+func ImportBundle(data, key []byte) (*Bundle, error) {
+	if err := checkKey(key); err != nil {
+		return nil, err
+	}
+
+	var b Bundle
+	if err := json.Unmarshal(data, &b); err != nil {
+		return nil, fmt.Errorf("некорректный пакет конфигурации: %w", err)
+	}
+	if b.Version != BundleVersion {
+		return nil, fmt.Errorf("неподдерживаемая версия пакета конфигурации: %d", b.Version)
+	}
+
+	want, err := b.sign(key)
+	if err != nil {
+		return nil, err
+	}
+	if !hmac.Equal([]byte(b.Signature), []byte(want)) {
+		return nil, fmt.Errorf("неверная подпись пакета конфигурации")
+	}
+	for name := range b.Settings {
+		if !strings.HasPrefix(name, "NOTEPHEE_") || IsSecret(name) {
+			return nil, fmt.Errorf("пакет конфигурации содержит недопустимую переменную %s", name)
+		}
+	}
+	for _, t := range b.Templates {
+		if t.Name == "" || t.Version < 1 {
+			return nil, fmt.Errorf("пакет конфигурации содержит некорректную версию шаблона %s@v%d", t.Name, t.Version)
+		}
+	}
+	return &b, nil
+}
+
+// Merge возвращает переменные окружения после импорта: секреты из current и все настройки пакета.
+// Несекретные переменные current, которых нет в пакете, удаляются, чтобы результат
+// не зависел от прошлого состояния окружения.
+func (b *Bundle) Merge(current map[string]string) map[string]string {
+	out := make(map[string]string, len(current)+len(b.Settings))
+	for name, value := range current {
+		if IsSecret(name) || !strings.HasPrefix(name, "NOTEPHEE_") {
+			out[name] = value
+		}
+	}
+	for name, value := range b.Settings {
+		out[name] = value
+	}
+	return out
+}
+
+// checkKey проверяет длину ключа подписи: короткий ключ позволил бы подобрать подпись изменённого пакета.
+func checkKey(key []byte) error {
+	if len(key) < 32 {
+		return fmt.Errorf("ключ подписи должен быть не короче 32 байт")
+	}
+	return nil
+}
+
+// sign возвращает подпись пакета без поля Signature. Ключи настроек сериализуются в алфавитном порядке,
+// а политики — в компактном виде, поэтому подпись не зависит от порядка переменных и отступов.
+func (b *Bundle) sign(key []byte) (string, error) {
+	payload, err := json.Marshal(struct {
+		Version   int               `json:"version"`
+		Settings  map[string]string `json:"settings"`
+		Templates []TemplateRef     `json:"templates,omitempty"`
+		Policies  json.RawMessage   `json:"policies,omitempty"`
+	}{b.Version, b.Settings, b.Templates, b.Policies})
+	if err != nil {
+		return "", err
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write(payload)
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil)), nil
+}
@@ -0,0 +1,27 @@
+package config
+
+import "testing"
+
+func TestNamesClassified(t *testing.T) {
+	for _, name := range Names {
+		if plainNames[name] == secretNames[name] {
+			t.Errorf("%s должна быть либо обычной настройкой, либо секретом", name)
+		}
+	}
+	known := make(map[string]bool, len(Names))
+	for _, name := range Names {
+		known[name] = true
+	}
+	for _, list := range []map[string]bool{plainNames, secretNames} {
+		for name := range list {
+			if !known[name] {
+				t.Errorf("%s нет в Names", name)
+			}
+		}
+	}
+	for _, name := range []string{"UNSUBSCRIBE_KEY", "TRACKING_KEY", "POSTGRES_URL", "REDIS_URL", "AMQP_URL", "NATS_URL", "TELEGRAM_PROXY", "NOTEPHEE_UNKNOWN"} {
+		if !IsSecret(name) {
+			t.Errorf("%s должна считаться секретом", name)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package config_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/epheer/notephee/config"
+)
+
+var bundleKey = []byte(strings.Repeat("k", 32))
+
+func TestBundleRoundTrip(t *testing.T) {
+	environ := []string{
+		"NOTEPHEE_DEGRADE_LATENCY=2s",
+		"NOTEPHEE_TELEGRAM_TOKEN=staging-token",
+		"NOTEPHEE_OVERFLOW_CATEGORIES=alerts=split",
+		"HOME=/root",
+	}
+	data, err := config.ExportBundle(environ, config.Runtime{}, bundleKey)
+	if err != nil {
+		t.Fatalf("Ошибка ExportBundle: %v", err)
+	}
+	if strings.Contains(string(data), "staging-token") {
+		t.Fatal("секреты не должны попадать в пакет")
+	}
+	reversed := []string{environ[3], environ[2], environ[1], environ[0]}
+	if again, _ := config.ExportBundle(reversed, config.Runtime{}, bundleKey); !bytes.Equal(data, again) {
+		t.Fatal("пакет должен не зависеть от порядка переменных")
+	}
+
+	b, err := config.ImportBundle(data, bundleKey)
+	if err != nil {
+		t.Fatalf("Ошибка ImportBundle: %v", err)
+	}
+	env := b.Merge(map[string]string{
+		"NOTEPHEE_TELEGRAM_TOKEN":  "prod-token",
+		"NOTEPHEE_DIGEST_INTERVAL": "1h",
+	})
+	want := map[string]string{
+		"NOTEPHEE_TELEGRAM_TOKEN":      "prod-token",
+		"NOTEPHEE_DEGRADE_LATENCY":     "2s",
+		"NOTEPHEE_OVERFLOW_CATEGORIES": "alerts=split",
+	}
+	if len(env) != len(want) {
+		t.Fatalf("ожидалось %v, получено %v", want, env)
+	}
+	for name, value := range want {
+		if env[name] != value {
+			t.Fatalf("%s: ожидалось %q, получено %q", name, value, env[name])
+		}
+	}
+}
+
+func TestBundleRejectsTampering(t *testing.T) {
+	data, err := config.ExportBundle([]string{"NOTEPHEE_DEGRADE_LOW=defer"}, config.Runtime{}, bundleKey)
+	if err != nil {
+		t.Fatalf("Ошибка ExportBundle: %v", err)
+	}
+
+	tampered := bytes.Replace(data, []byte("defer"), []byte("shed"), 1)
+	if _, err := config.ImportBundle(tampered, bundleKey); err == nil {
+		t.Fatal("изменённый пакет должен отклоняться")
+	}
+	if _, err := config.ImportBundle(data, []byte(strings.Repeat("x", 32))); err == nil {
+		t.Fatal("пакет, подписанный другим ключом, должен отклоняться")
+	}
+}
+
+func TestBundleRuntime(t *testing.T) {
+	rt := config.Runtime{
+		Templates: []config.TemplateRef{{Name: "welcome", Version: 3}, {Name: "otp", Version: 1}},
+		Policies:  []byte(`{"degrade":{"threshold":"2s","low":"defer"},"indeterminate_policy":"never"}`),
+	}
+	data, err := config.ExportBundle(nil, rt, bundleKey)
+	if err != nil {
+		t.Fatalf("Ошибка ExportBundle: %v", err)
+	}
+
+	b, err := config.ImportBundle(data, bundleKey)
+	if err != nil {
+		t.Fatalf("Ошибка ImportBundle: %v", err)
+	}
+	if len(b.Templates) != 2 || b.Templates[0] != (config.TemplateRef{Name: "otp", Version: 1}) {
+		t.Fatalf("версии шаблонов должны переноситься по порядку имён: %+v", b.Templates)
+	}
+	if !strings.Contains(string(b.Policies), `"indeterminate_policy": "never"`) {
+		t.Fatalf("политики не перенесены: %s", b.Policies)
+	}
+
+	for _, tampered := range [][]byte{
+		bytes.Replace(data, []byte(`"version": 3`), []byte(`"version": 4`), 1),
+		bytes.Replace(data, []byte(`"never"`), []byte(`"always"`), 1),
+	} {
+		if _, err := config.ImportBundle(tampered, bundleKey); err == nil {
+			t.Fatal("пакет с изменёнными шаблонами или политиками должен отклоняться")
+		}
+	}
+}
+
+func TestBundleKeyLength(t *testing.T) {
+	short := []byte("short")
+	if _, err := config.ExportBundle(nil, config.Runtime{}, short); err == nil {
+		t.Fatal("ExportBundle должен отклонять короткий ключ")
+	}
+	data, _ := config.ExportBundle(nil, config.Runtime{}, bundleKey)
+	if _, err := config.ImportBundle(data, short); err == nil {
+		t.Fatal("ImportBundle должен отклонять короткий ключ")
+	}
+}
@@ -1,9 +1,13 @@
 package config
 
 import (
+	"errors"
 	"log"
 	"log/slog"
 	"os"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -11,6 +15,8 @@ import (
 type Config struct {
 	TelegramToken   string
 	TelegramBotName string
+	TelegramProxy   string
+	TelegramAPIURL  string
 
 	EmailHost     string
 	EmailPort     string
@@ -18,12 +24,116 @@ type Config struct {
 	EmailPassword string
 	EmailFromName string
 
+	EmailProvider      string
+	SendGridAPIKey     string
+	MailgunDomain      string
+	MailgunAPIKey      string
+	MailgunRegion      string
+	SESRegion          string
+	SESAccessKeyID     string
+	SESSecretAccessKey string
+
+	EmailDomainLimits string
+
+	IMAPAddr       string
+	IMAPSentFolder string
+
+	SlackWebhookURL string
+	SlackToken      string
+
+	TeamChatTargets string
+
+	Identities string
+
+	BundleKey string
+
+	MatrixHomeserver string
+	MatrixToken      string
+
+	VKToken string
+
+	ViberToken        string
+	ViberSenderName   string
+	ViberSenderAvatar string
+
+	ServerAddr  string
+	ServerToken string
+	AdminToken  string
+	GRPCAddr    string
+
+	KafkaBrokers  string
+	KafkaTopic    string
+	KafkaGroup    string
+	KafkaDLQTopic string
+	KafkaFormat   string
+
+	NATSURL        string
+	NATSStream     string
+	NATSConsumer   string
+	NATSSubject    string
+	NATSDLQSubject string
+
+	AMQPURL   string
+	AMQPQueue string
+
+	RedisURL    string
+	PostgresURL string
+	SQLitePath  string
+
+	DedupWindow    time.Duration
+	DigestInterval time.Duration
+
+	OptInCategories string
+
+	UnsubscribeKey string
+	UnsubscribeURL string
+
+	TrackingKey string
+	TrackingURL string
+
+	DegradeLatency time.Duration
+	DegradeLow     string
+	DegradeNormal  string
+
+	IndeterminatePolicy string
+
+	OverflowStrategy   string
+	OverflowCategories string
+	OverflowMoreURL    string
+
+	QueueRates string
+
+	SpoolDir        string
+	ShutdownTimeout time.Duration
+	ReplayDir       string
+
+	AlertTelegramChat string
+	AlertEmail        string
+
+	SecretsProvider string
+	SecretsPath     string
+	SecretsRefresh  time.Duration
+
 	IsTelegramValid bool
 	IsEmailValid    bool
+
+	loadErrs []FieldError // Значения, которые не удалось разобрать при загрузке; возвращаются из Validate
 }
 
+// Cfg — текущая конфигурация. Её заменяют LoadFrom и Set, в том числе из горутины secrets.Watch,
+// поэтому читать её нужно через Get.
 var Cfg *Config
 
+// cfgMu защищает Cfg.
+var cfgMu sync.RWMutex
+
+// Set делает cfg текущей конфигурацией для Get.
+func Set(cfg *Config) {
+	cfgMu.Lock()
+	Cfg = cfg
+	cfgMu.Unlock()
+}
+
 // LoadEnv загружает переменные из env-файла
 func LoadEnv(path string) error {
 	err := godotenv.Load(path)
@@ -39,39 +149,233 @@ func getEnv(name string) string {
 
 // load загружает конфигурацию из переменных окружения
 func load(logger *slog.Logger) {
-	Cfg = &Config{
-		TelegramToken:   getEnv("TELEGRAM_TOKEN"),
-		TelegramBotName: getEnv("TELEGRAM_BOT_NAME"),
-		EmailHost:       getEnv("SMTP_HOST"),
-		EmailPort:       getEnv("SMTP_PORT"),
-		EmailUser:       getEnv("SMTP_USER"),
-		EmailPassword:   getEnv("SMTP_PASSWORD"),
-		EmailFromName:   getEnv("SMTP_FROM_NAME"),
+	Cfg = build(getEnv, logger)
+}
+
+// build собирает конфигурацию из значений, которые get возвращает по имени переменной без префикса NOTEPHEE_.
+func build(get func(string) string, logger *slog.Logger) *Config {
+	cfg := &Config{
+		TelegramToken:       get("TELEGRAM_TOKEN"),
+		TelegramBotName:     get("TELEGRAM_BOT_NAME"),
+		TelegramProxy:       get("TELEGRAM_PROXY"),
+		TelegramAPIURL:      get("TELEGRAM_API_URL"),
+		EmailHost:           get("SMTP_HOST"),
+		EmailPort:           get("SMTP_PORT"),
+		EmailUser:           get("SMTP_USER"),
+		EmailPassword:       get("SMTP_PASSWORD"),
+		EmailFromName:       get("SMTP_FROM_NAME"),
+		EmailProvider:       get("EMAIL_PROVIDER"),
+		SendGridAPIKey:      get("SENDGRID_API_KEY"),
+		MailgunDomain:       get("MAILGUN_DOMAIN"),
+		MailgunAPIKey:       get("MAILGUN_API_KEY"),
+		MailgunRegion:       get("MAILGUN_REGION"),
+		SESRegion:           get("SES_REGION"),
+		SESAccessKeyID:      get("SES_ACCESS_KEY_ID"),
+		SESSecretAccessKey:  get("SES_SECRET_ACCESS_KEY"),
+		EmailDomainLimits:   get("EMAIL_DOMAIN_LIMITS"),
+		IMAPAddr:            get("IMAP_ADDR"),
+		IMAPSentFolder:      get("IMAP_SENT_FOLDER"),
+		SlackWebhookURL:     get("SLACK_WEBHOOK_URL"),
+		SlackToken:          get("SLACK_TOKEN"),
+		TeamChatTargets:     get("TEAMCHAT_TARGETS"),
+		Identities:          get("IDENTITIES"),
+		BundleKey:           get("BUNDLE_KEY"),
+		MatrixHomeserver:    get("MATRIX_HOMESERVER"),
+		MatrixToken:         get("MATRIX_TOKEN"),
+		VKToken:             get("VK_TOKEN"),
+		ViberToken:          get("VIBER_TOKEN"),
+		ViberSenderName:     get("VIBER_SENDER_NAME"),
+		ViberSenderAvatar:   get("VIBER_SENDER_AVATAR"),
+		ServerAddr:          get("SERVER_ADDR"),
+		ServerToken:         get("SERVER_TOKEN"),
+		AdminToken:          get("ADMIN_TOKEN"),
+		GRPCAddr:            get("GRPC_ADDR"),
+		KafkaBrokers:        get("KAFKA_BROKERS"),
+		KafkaTopic:          get("KAFKA_TOPIC"),
+		KafkaGroup:          get("KAFKA_GROUP"),
+		KafkaDLQTopic:       get("KAFKA_DLQ_TOPIC"),
+		KafkaFormat:         get("KAFKA_FORMAT"),
+		NATSURL:             get("NATS_URL"),
+		NATSStream:          get("NATS_STREAM"),
+		NATSConsumer:        get("NATS_CONSUMER"),
+		NATSSubject:         get("NATS_SUBJECT"),
+		NATSDLQSubject:      get("NATS_DLQ_SUBJECT"),
+		AMQPURL:             get("AMQP_URL"),
+		AMQPQueue:           get("AMQP_QUEUE"),
+		RedisURL:            get("REDIS_URL"),
+		PostgresURL:         get("POSTGRES_URL"),
+		SQLitePath:          get("SQLITE_PATH"),
+		DegradeLow:          get("DEGRADE_LOW"),
+		DegradeNormal:       get("DEGRADE_NORMAL"),
+		IndeterminatePolicy: get("INDETERMINATE_POLICY"),
+		OverflowStrategy:    get("OVERFLOW_STRATEGY"),
+		OverflowCategories:  get("OVERFLOW_CATEGORIES"),
+		OverflowMoreURL:     get("OVERFLOW_MORE_URL"),
+		QueueRates:          get("QUEUE_RATES"),
+		SpoolDir:            get("SPOOL_DIR"),
+		ReplayDir:           get("REPLAY_DIR"),
+		AlertTelegramChat:   get("ALERT_TELEGRAM_CHAT"),
+		AlertEmail:          get("ALERT_EMAIL"),
+		OptInCategories:     get("OPT_IN_CATEGORIES"),
+		UnsubscribeKey:      get("UNSUBSCRIBE_KEY"),
+		UnsubscribeURL:      get("UNSUBSCRIBE_URL"),
+		TrackingKey:         get("TRACKING_KEY"),
+		TrackingURL:         get("TRACKING_URL"),
+		SecretsProvider:     get("SECRETS_PROVIDER"),
+		SecretsPath:         get("SECRETS_PATH"),
+		ShutdownTimeout:     30 * time.Second,
+	}
+	// Без токена HTTP API открыт, поэтому по умолчанию он слушает только localhost
+	if cfg.ServerAddr == "" && cfg.ServerToken == "" {
+		cfg.ServerAddr = "127.0.0.1:8080"
+	}
+	if cfg.ServerAddr == "" {
+		cfg.ServerAddr = ":8080"
+	}
+	if cfg.KafkaGroup == "" {
+		cfg.KafkaGroup = "notephee"
+	}
+	if cfg.NATSConsumer == "" {
+		cfg.NATSConsumer = "notephee"
+	}
+	if v := get("DEDUP_WINDOW"); v != "" {
+		window, err := time.ParseDuration(v)
+		if err != nil {
+			logger.Warn("Некорректное значение NOTEPHEE_DEDUP_WINDOW, дедупликация отключена", "value", v, "error", err)
+			cfg.loadErrs = append(cfg.loadErrs, FieldError{Field: "NOTEPHEE_DEDUP_WINDOW", Message: "ожидается длительность, например 5m"})
+		}
+		cfg.DedupWindow = window
+	}
+	if v := get("DIGEST_INTERVAL"); v != "" {
+		interval, err := time.ParseDuration(v)
+		if err != nil {
+			logger.Warn("Некорректное значение NOTEPHEE_DIGEST_INTERVAL, сводки отключены", "value", v, "error", err)
+			cfg.loadErrs = append(cfg.loadErrs, FieldError{Field: "NOTEPHEE_DIGEST_INTERVAL", Message: "ожидается длительность, например 5m"})
+		}
+		cfg.DigestInterval = interval
+	}
+	if v := get("DEGRADE_LATENCY"); v != "" {
+		latency, err := time.ParseDuration(v)
+		if err != nil {
+			logger.Warn("Некорректное значение NOTEPHEE_DEGRADE_LATENCY, деградация отключена", "value", v, "error", err)
+			cfg.loadErrs = append(cfg.loadErrs, FieldError{Field: "NOTEPHEE_DEGRADE_LATENCY", Message: "ожидается длительность, например 5m"})
+		}
+		cfg.DegradeLatency = latency
+	}
+	if v := get("SHUTDOWN_TIMEOUT"); v != "" {
+		timeout, err := time.ParseDuration(v)
+		if err != nil {
+			logger.Warn("Некорректное значение NOTEPHEE_SHUTDOWN_TIMEOUT, используется 30s", "value", v, "error", err)
+			cfg.loadErrs = append(cfg.loadErrs, FieldError{Field: "NOTEPHEE_SHUTDOWN_TIMEOUT", Message: "ожидается длительность, например 30s"})
+		} else {
+			cfg.ShutdownTimeout = timeout
+		}
+	}
+	if v := get("SECRETS_REFRESH"); v != "" {
+		refresh, err := time.ParseDuration(v)
+		if err != nil {
+			logger.Warn("Некорректное значение NOTEPHEE_SECRETS_REFRESH, секреты не обновляются", "value", v, "error", err)
+			cfg.loadErrs = append(cfg.loadErrs, FieldError{Field: "NOTEPHEE_SECRETS_REFRESH", Message: "ожидается длительность, например 10m"})
+		}
+		cfg.SecretsRefresh = refresh
+	}
+
+	if err := cfg.Validate(); err != nil {
+		var verr *ValidationError
+		if errors.As(err, &verr) {
+			// Ошибки разбора значений идут первыми и уже записаны в лог выше
+			for _, fe := range verr.Errors[len(cfg.loadErrs):] {
+				logger.Warn("Некорректная конфигурация", "field", fe.Field, "error", fe.Message)
+			}
+		}
 	}
 
-	if !Cfg.IsTelegramEnabled() {
+	if !cfg.IsTelegramEnabled() {
 		logger.Info("Конфигурация Telegram-бота не заполнена или заполнена частично, функционал работы с этим сервисом ограничен")
 	}
-	if !Cfg.IsEmailEnabled() {
+	if !cfg.IsEmailEnabled() {
 		logger.Info("Конфигурация для email не заполнена или заполнена частично, функционал отправки электронных писем ограничен")
 	}
-	if !Cfg.IsTelegramEnabled() && !Cfg.IsEmailEnabled() {
+	if !cfg.IsTelegramEnabled() && !cfg.IsEmailEnabled() && !cfg.IsSlackEnabled() && !cfg.IsMatrixEnabled() &&
+		!cfg.IsVKEnabled() && !cfg.IsViberEnabled() {
 		logger.Error("Конфигурация Notephee не загружена, функционал недоступен")
 	}
+	return cfg
 }
 
 // Get возвращает текущий конфиг
 func Get(logger *slog.Logger) *Config {
+	cfgMu.RLock()
+	cfg := Cfg
+	cfgMu.RUnlock()
+	if cfg != nil {
+		return cfg
+	}
+
+	cfgMu.Lock()
+	defer cfgMu.Unlock()
 	if Cfg == nil {
 		load(logger)
 	}
 	return Cfg
 }
 
+// IsEmailEnabled сообщает, можно ли отправлять письма: через SMTP или через HTTP API провайдера
+// из EmailProvider. Адрес отправителя в обоих случаях берётся из EmailUser.
 func (c *Config) IsEmailEnabled() bool {
-	return c.EmailHost != "" && c.EmailPort != "" && c.EmailUser != "" && c.EmailPassword != ""
+	switch strings.ToLower(c.EmailProvider) {
+	case "", "smtp":
+		return c.EmailHost != "" && c.EmailPort != "" && c.EmailUser != "" && c.EmailPassword != ""
+	case "sendgrid":
+		return c.EmailUser != "" && c.SendGridAPIKey != ""
+	case "mailgun":
+		return c.EmailUser != "" && c.MailgunDomain != "" && c.MailgunAPIKey != ""
+	case "ses":
+		return c.EmailUser != "" && c.SESRegion != "" && c.SESAccessKeyID != "" && c.SESSecretAccessKey != ""
+	default:
+		return false
+	}
 }
 
 func (c *Config) IsTelegramEnabled() bool {
 	return c.TelegramToken != "" && c.TelegramBotName != ""
 }
+
+func (c *Config) IsSlackEnabled() bool {
+	return c.SlackWebhookURL != "" || c.SlackToken != ""
+}
+
+func (c *Config) IsMatrixEnabled() bool {
+	return c.MatrixHomeserver != "" && c.MatrixToken != ""
+}
+
+func (c *Config) IsVKEnabled() bool {
+	return c.VKToken != ""
+}
+
+func (c *Config) IsViberEnabled() bool {
+	return c.ViberToken != "" && c.ViberSenderName != ""
+}
+
+func (c *Config) IsKafkaEnabled() bool {
+	return c.KafkaBrokers != "" && c.KafkaTopic != ""
+}
+
+// KafkaBrokerList возвращает адреса брокеров из KafkaBrokers, перечисленные через запятую.
+func (c *Config) KafkaBrokerList() []string {
+	var brokers []string
+	for _, b := range strings.Split(c.KafkaBrokers, ",") {
+		if b = strings.TrimSpace(b); b != "" {
+			brokers = append(brokers, b)
+		}
+	}
+	return brokers
+}
+
+func (c *Config) IsNATSEnabled() bool {
+	return c.NATSURL != "" && c.NATSStream != ""
+}
+
+func (c *Config) IsAMQPEnabled() bool {
+	return c.AMQPURL != "" && c.AMQPQueue != ""
+}
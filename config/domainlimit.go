@@ -0,0 +1,42 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// DomainLimit — ограничение отправки писем на домены одного почтового провайдера, например gmail.com
+// и googlemail.com. Провайдеры ограничивают поток с одного отправителя на свои ящики и при превышении
+// временно отклоняют письма (graylisting) всему аккаунту.
+type DomainLimit struct {
+	Domains     []string `json:"domains"`               // Домены получателей с общим лимитом
+	Rate        float64  `json:"rate"`                  // Писем в секунду
+	Burst       int      `json:"burst,omitempty"`       // Всплеск; 0 — 1
+	Connections int      `json:"connections,omitempty"` // Одновременных отправок; 0 — без ограничения
+}
+
+// ParseDomainLimits разбирает лимиты по доменам в формате JSON-массива объектов DomainLimit.
+func ParseDomainLimits(data string) ([]DomainLimit, error) {
+	var limits []DomainLimit
+	if err := json.Unmarshal([]byte(data), &limits); err != nil {
+		return nil, fmt.Errorf("некорректный список лимитов по доменам: %w", err)
+	}
+	seen := make(map[string]bool)
+	for i, l := range limits {
+		if len(l.Domains) == 0 {
+			return nil, fmt.Errorf("лимит #%d: domains обязательно", i)
+		}
+		if l.Rate <= 0 || l.Burst < 0 || l.Connections < 0 {
+			return nil, fmt.Errorf("лимит #%d: rate должен быть положительным, burst и connections — неотрицательными", i)
+		}
+		for _, d := range l.Domains {
+			d = strings.ToLower(d)
+			if seen[d] {
+				return nil, fmt.Errorf("домен %s указан в нескольких лимитах", d)
+			}
+			seen[d] = true
+		}
+	}
+	return limits, nil
+}
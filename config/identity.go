@@ -0,0 +1,76 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Identity — отдельная личность отправителя: свой Telegram-бот и/или свой адрес отправителя писем.
+// Нужна white-label-клиентам, которые отправляют уведомления от своего бота и со своего домена.
+type Identity struct {
+	Name string `json:"name"` // Имя личности; передаётся в notify.Message.Identity
+
+	TelegramToken   string `json:"telegram_token,omitempty"`    // Токен бота (необязательно)
+	TelegramBotName string `json:"telegram_bot_name,omitempty"` // Имя бота; обязательно вместе с токеном
+	TelegramProxy   string `json:"telegram_proxy,omitempty"`    // Прокси для бота; пустой наследуется
+
+	SMTPUser     string `json:"smtp_user,omitempty"`      // Адрес отправителя писем (необязательно)
+	SMTPPassword string `json:"smtp_password,omitempty"`  // Пароль SMTP; пустой наследуется
+	SMTPHost     string `json:"smtp_host,omitempty"`      // Сервер SMTP; пустой наследуется
+	SMTPPort     string `json:"smtp_port,omitempty"`      // Порт SMTP; пустой наследуется
+	SMTPFromName string `json:"smtp_from_name,omitempty"` // Имя отправителя; пустое наследуется
+}
+
+// ParseIdentities разбирает список личностей в формате JSON-массива объектов Identity.
+func ParseIdentities(data string) ([]Identity, error) {
+	var identities []Identity
+	if err := json.Unmarshal([]byte(data), &identities); err != nil {
+		return nil, fmt.Errorf("некорректный список личностей отправителя: %w", err)
+	}
+	seen := make(map[string]bool, len(identities))
+	for i, id := range identities {
+		if id.Name == "" {
+			return nil, fmt.Errorf("личность #%d: name обязательно", i)
+		}
+		if seen[id.Name] {
+			return nil, fmt.Errorf("личность %s указана дважды", id.Name)
+		}
+		seen[id.Name] = true
+		if id.TelegramToken == "" && id.SMTPUser == "" {
+			return nil, fmt.Errorf("личность %s: нужен telegram_token или smtp_user", id.Name)
+		}
+		if id.TelegramToken != "" && id.TelegramBotName == "" {
+			return nil, fmt.Errorf("личность %s: telegram_bot_name обязательно вместе с telegram_token", id.Name)
+		}
+	}
+	return identities, nil
+}
+
+// WithIdentity возвращает копию конфигурации, в которой Telegram и email настроены на личность id.
+// Telegram или email, которые личность не переопределяет, в копии отключены, чтобы не дублировать основные.
+func (c *Config) WithIdentity(id Identity) *Config {
+	out := *c
+	out.TelegramToken, out.TelegramBotName = id.TelegramToken, id.TelegramBotName
+	if id.TelegramProxy != "" {
+		out.TelegramProxy = id.TelegramProxy
+	}
+
+	if id.SMTPUser == "" {
+		out.EmailUser = ""
+		return &out
+	}
+	out.EmailUser = id.SMTPUser
+	if id.SMTPPassword != "" {
+		out.EmailPassword = id.SMTPPassword
+	}
+	if id.SMTPHost != "" {
+		out.EmailHost = id.SMTPHost
+	}
+	if id.SMTPPort != "" {
+		out.EmailPort = id.SMTPPort
+	}
+	if id.SMTPFromName != "" {
+		out.EmailFromName = id.SMTPFromName
+	}
+	return &out
+}
@@ -0,0 +1,62 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrSecretNotFound возвращается SecretProvider, если секрета с таким именем в хранилище нет.
+// Тогда значение берётся из обычных источников: флагов, окружения или файла.
+var ErrSecretNotFound = errors.New("секрет не найден")
+
+// SecretProvider — внешнее хранилище секретов: HashiCorp Vault, AWS Secrets Manager, GCP Secret Manager
+// или каталог с файлами секретов. Реализации — в пакете config/secrets.
+type SecretProvider interface {
+	// Secret возвращает значение секрета name — имени переменной без префикса NOTEPHEE_,
+	// например TELEGRAM_TOKEN или SMTP_PASSWORD, — или ErrSecretNotFound.
+	Secret(ctx context.Context, name string) (string, error)
+}
+
+// SecretLister — SecretProvider, который отдаёт все секреты одним запросом, как путь KV в Vault.
+// fetchSecrets тогда обращается к хранилищу один раз за загрузку, а не по разу на переменную.
+type SecretLister interface {
+	SecretProvider
+	// Secrets возвращает все секреты по именам переменных без префикса в верхнем регистре.
+	Secrets(ctx context.Context) (map[string]string, error)
+}
+
+// fetchSecrets запрашивает у p все переменные из secretNames: токены, пароли, ключи подписи и адреса
+// подключений. Любая ошибка, кроме ErrSecretNotFound, прерывает загрузку: сервис не должен молча
+// запуститься с токеном из окружения, если хранилище недоступно.
+func fetchSecrets(ctx context.Context, p SecretProvider) (map[string]string, error) {
+	if l, ok := p.(SecretLister); ok {
+		all, err := l.Secrets(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("не удалось получить секреты: %w", err)
+		}
+		secrets := make(map[string]string)
+		for _, name := range Names {
+			if v, ok := all[name]; ok && secretNames[name] {
+				secrets[name] = v
+			}
+		}
+		return secrets, nil
+	}
+
+	secrets := make(map[string]string)
+	for _, name := range Names {
+		if !secretNames[name] {
+			continue
+		}
+		value, err := p.Secret(ctx, name)
+		if errors.Is(err, ErrSecretNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("не удалось получить секрет %s: %w", name, err)
+		}
+		secrets[name] = value
+	}
+	return secrets, nil
+}
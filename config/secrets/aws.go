@@ -0,0 +1,140 @@
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/epheer/notephee/config"
+)
+
+// AWSProvider читает секреты методом GetSecretValue AWS Secrets Manager. Каждой переменной соответствует
+// отдельный секрет с именем prefix + имя переменной, например notephee/TELEGRAM_TOKEN.
+type AWSProvider struct {
+	region       string
+	accessKey    string
+	secretKey    string
+	sessionToken string
+	prefix       string
+	uri          string
+	http         *http.Client
+	now          func() time.Time
+}
+
+// NewAWS создаёт хранилище Secrets Manager в регионе region. sessionToken нужен для временных ключей
+// роли (необязательно).
+func NewAWS(region, accessKey, secretKey, sessionToken, prefix string, client *http.Client) (*AWSProvider, error) {
+	if region == "" || accessKey == "" || secretKey == "" {
+		return nil, fmt.Errorf("для AWS Secrets Manager нужны AWS_REGION, AWS_ACCESS_KEY_ID и AWS_SECRET_ACCESS_KEY")
+	}
+	return &AWSProvider{
+		region:       region,
+		accessKey:    accessKey,
+		secretKey:    secretKey,
+		sessionToken: sessionToken,
+		prefix:       prefix,
+		uri:          fmt.Sprintf("https://secretsmanager.%s.amazonaws.com", region),
+		http:         client,
+		now:          time.Now,
+	}, nil
+}
+
+// SetBaseURL задаёт адрес API вместо регионального, например VPC-эндпоинт.
+func (p *AWSProvider) SetBaseURL(uri string) {
+	p.uri = strings.TrimRight(uri, "/")
+}
+
+// Secret возвращает строковое значение секрета prefix + name.
+func (p *AWSProvider) Secret(ctx context.Context, name string) (string, error) {
+	payload, err := json.Marshal(map[string]string{"SecretId": p.prefix + name})
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.uri+"/", bytes.NewReader(payload))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "secretsmanager.GetSecretValue")
+	p.sign(req, payload)
+
+	resp, err := p.http.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	body, err := readBody(resp)
+	if err != nil {
+		return "", err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		var apiErr struct {
+			Type string `json:"__type"`
+		}
+		_ = json.Unmarshal(body, &apiErr)
+		if strings.HasSuffix(apiErr.Type, "ResourceNotFoundException") {
+			return "", config.ErrSecretNotFound
+		}
+		return "", apiError("AWS Secrets Manager", resp, body)
+	}
+
+	var res struct {
+		SecretString string `json:"SecretString"`
+	}
+	if err := json.Unmarshal(body, &res); err != nil {
+		return "", fmt.Errorf("некорректный ответ AWS Secrets Manager: %w", err)
+	}
+	return res.SecretString, nil
+}
+
+// sign подписывает запрос по AWS Signature Version 4 для сервиса secretsmanager.
+func (p *AWSProvider) sign(req *http.Request, payload []byte) {
+	now := p.now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	day := now.Format("20060102")
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	headers := "content-type:" + req.Header.Get("Content-Type") + "\nhost:" + req.URL.Host + "\nx-amz-date:" + amzDate + "\n"
+	signed := "content-type;host;x-amz-date"
+	if p.sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", p.sessionToken)
+		headers += "x-amz-security-token:" + p.sessionToken + "\n"
+		signed += ";x-amz-security-token"
+	}
+	headers += "x-amz-target:" + req.Header.Get("X-Amz-Target") + "\n"
+	signed += ";x-amz-target"
+
+	canonical := strings.Join([]string{req.Method, "/", "", headers, signed, sha256Hex(payload)}, "\n")
+	scope := day + "/" + p.region + "/secretsmanager/aws4_request"
+	toSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonical))
+
+	key := hmacSHA256([]byte("AWS4"+p.secretKey), day)
+	key = hmacSHA256(key, p.region)
+	key = hmacSHA256(key, "secretsmanager")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, toSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		p.accessKey, scope, signed, signature,
+	))
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
@@ -0,0 +1,39 @@
+package secrets
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/epheer/notephee/config"
+)
+
+// DirProvider читает секреты из файлов каталога: секретов Docker и Kubernetes или файлов, которые
+// пишет Vault Agent. Имя файла — имя переменной без префикса в верхнем или нижнем регистре:
+// TELEGRAM_TOKEN или telegram_token. Завершающий перевод строки отбрасывается.
+type DirProvider struct {
+	dir string
+}
+
+// NewDir создаёт хранилище в каталоге dir.
+func NewDir(dir string) *DirProvider {
+	return &DirProvider{dir: dir}
+}
+
+// Secret возвращает содержимое файла секрета name.
+func (p *DirProvider) Secret(_ context.Context, name string) (string, error) {
+	for _, file := range []string{name, strings.ToLower(name)} {
+		data, err := os.ReadFile(filepath.Join(p.dir, file))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimRight(string(data), "\r\n"), nil
+	}
+	return "", config.ErrSecretNotFound
+}
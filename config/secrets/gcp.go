@@ -0,0 +1,138 @@
+package secrets
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/epheer/notephee/config"
+)
+
+// Адреса GCP по умолчанию.
+const (
+	GCPSecretManagerAPI = "https://secretmanager.googleapis.com"
+	// GCPMetadataToken — токен сервисного аккаунта на сервере метаданных GCE, GKE и Cloud Run.
+	GCPMetadataToken = "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token"
+)
+
+// GCPProvider читает последнюю версию секретов GCP Secret Manager. Каждой переменной соответствует
+// отдельный секрет с ресурсом prefix + имя переменной, например projects/my-project/secrets/notephee-TELEGRAM_TOKEN.
+//
+// Токен доступа берётся из GOOGLE_OAUTH_ACCESS_TOKEN, а без неё — у сервера метаданных и обновляется
+// по истечении срока.
+type GCPProvider struct {
+	prefix   string
+	uri      string
+	tokenURI string
+	http     *http.Client
+
+	mu      sync.Mutex
+	token   string
+	expires time.Time
+}
+
+// NewGCP создаёт хранилище Secret Manager с префиксом ресурса prefix.
+func NewGCP(prefix string, client *http.Client) (*GCPProvider, error) {
+	if !strings.HasPrefix(prefix, "projects/") {
+		return nil, fmt.Errorf("префикс GCP должен быть вида projects/<проект>/secrets/<префикс>, получено %q", prefix)
+	}
+	p := &GCPProvider{prefix: prefix, uri: GCPSecretManagerAPI, tokenURI: GCPMetadataToken, http: client}
+	if token := os.Getenv("GOOGLE_OAUTH_ACCESS_TOKEN"); token != "" {
+		p.token, p.expires = token, time.Now().AddDate(100, 0, 0)
+	}
+	return p, nil
+}
+
+// SetBaseURL задаёт адрес API Secret Manager и сервера метаданных вместо стандартных, например для тестов.
+func (p *GCPProvider) SetBaseURL(api, tokenURI string) {
+	p.uri, p.tokenURI = strings.TrimRight(api, "/"), tokenURI
+}
+
+// Secret возвращает значение последней версии секрета prefix + name.
+func (p *GCPProvider) Secret(ctx context.Context, name string) (string, error) {
+	token, err := p.accessToken(ctx)
+	if err != nil {
+		return "", err
+	}
+	uri := fmt.Sprintf("%s/v1/%s%s/versions/latest:access", p.uri, p.prefix, name)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, uri, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := p.http.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	body, err := readBody(resp)
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return "", config.ErrSecretNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", apiError("GCP Secret Manager", resp, body)
+	}
+
+	var res struct {
+		Payload struct {
+			Data string `json:"data"`
+		} `json:"payload"`
+	}
+	if err := json.Unmarshal(body, &res); err != nil {
+		return "", fmt.Errorf("некорректный ответ GCP Secret Manager: %w", err)
+	}
+	data, err := base64.StdEncoding.DecodeString(res.Payload.Data)
+	if err != nil {
+		return "", fmt.Errorf("некорректное значение секрета %s: %w", name, err)
+	}
+	return string(data), nil
+}
+
+// accessToken возвращает действующий токен, при необходимости запрашивая новый у сервера метаданных.
+func (p *GCPProvider) accessToken(ctx context.Context) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.token != "" && time.Now().Before(p.expires) {
+		return p.token, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.tokenURI, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+	resp, err := p.http.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("не удалось получить токен GCP: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	body, err := readBody(resp)
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", apiError("сервер метаданных GCP", resp, body)
+	}
+
+	var res struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.Unmarshal(body, &res); err != nil || res.AccessToken == "" {
+		return "", fmt.Errorf("некорректный ответ сервера метаданных GCP")
+	}
+	// Токен обновляется за минуту до истечения, чтобы не отправить запрос с просроченным
+	p.token = res.AccessToken
+	p.expires = time.Now().Add(time.Duration(res.ExpiresIn)*time.Second - time.Minute)
+	return p.token, nil
+}
@@ -0,0 +1,109 @@
+// Package secrets содержит хранилища секретов для config.SecretProvider: HashiCorp Vault (KV v2),
+// AWS Secrets Manager, GCP Secret Manager и каталог с файлами секретов.
+//
+// Хранилище выбирается переменными NOTEPHEE_SECRETS_PROVIDER и NOTEPHEE_SECRETS_PATH, а доступ к нему
+// настраивается стандартными переменными каждой платформы: VAULT_ADDR и VAULT_TOKEN, AWS_REGION
+// и ключами AWS_*, токеном сервисного аккаунта GCP.
+package secrets
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/epheer/notephee/config"
+)
+
+// Поддерживаемые хранилища — значения NOTEPHEE_SECRETS_PROVIDER.
+const (
+	Vault = "vault"
+	AWS   = "aws"
+	GCP   = "gcp"
+	File  = "file"
+)
+
+// New создаёт хранилище kind с расположением секретов path:
+//   - vault — путь KV v2 вида mount/path, например secret/notephee;
+//   - aws — префикс имён секретов, например notephee/ (секрет notephee/TELEGRAM_TOKEN);
+//   - gcp — префикс ресурса, например projects/my-project/secrets/notephee-;
+//   - file — каталог с файлами по именам переменных, например /run/secrets.
+//
+// Пустой kind — хранилище не используется, New возвращает nil. client может быть nil.
+func New(kind, path string, client *http.Client) (config.SecretProvider, error) {
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	switch kind {
+	case "":
+		return nil, nil
+	case Vault:
+		return NewVault(os.Getenv("VAULT_ADDR"), os.Getenv("VAULT_TOKEN"), path, client)
+	case AWS:
+		return NewAWS(os.Getenv("AWS_REGION"), os.Getenv("AWS_ACCESS_KEY_ID"), os.Getenv("AWS_SECRET_ACCESS_KEY"),
+			os.Getenv("AWS_SESSION_TOKEN"), path, client)
+	case GCP:
+		return NewGCP(path, client)
+	case File:
+		return NewDir(path), nil
+	default:
+		return nil, fmt.Errorf("неизвестное хранилище секретов %q: ожидается vault, aws, gcp или file", kind)
+	}
+}
+
+// FromSources создаёт хранилище по NOTEPHEE_SECRETS_PROVIDER и NOTEPHEE_SECRETS_PATH из флагов,
+// окружения и файла src. Результат передаётся в src.Secrets перед config.LoadFrom.
+func FromSources(src config.Sources) (config.SecretProvider, error) {
+	lookup, err := src.Lookup()
+	if err != nil {
+		return nil, err
+	}
+	return New(lookup("SECRETS_PROVIDER"), lookup("SECRETS_PATH"), nil)
+}
+
+// Watch каждые interval заново загружает конфигурацию из src и, если она изменилась, — например,
+// после ротации токена в хранилище, — делает её текущей через config.Set и вызывает onChange.
+// Ошибки хранилища записываются в лог, а действующая конфигурация остаётся прежней.
+// Watch блокируется до отмены ctx.
+func Watch(ctx context.Context, src config.Sources, interval time.Duration, current *config.Config, logger *slog.Logger, onChange func(*config.Config)) {
+	// Предупреждения о некорректных значениях уже записаны при первой загрузке
+	quiet := slog.New(slog.DiscardHandler)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		cfg, err := config.Build(ctx, src, quiet)
+		if err != nil {
+			logger.Warn("не удалось обновить секреты", "error", err)
+			continue
+		}
+		if reflect.DeepEqual(cfg, current) {
+			continue
+		}
+		current = cfg
+		config.Set(cfg)
+		logger.Info("конфигурация обновлена из хранилища секретов")
+		onChange(cfg)
+	}
+}
+
+// readBody читает тело ответа не больше 1 МиБ: секреты не бывают большими.
+func readBody(resp *http.Response) ([]byte, error) {
+	return io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+}
+
+// apiError формирует ошибку по неуспешному ответу хранилища.
+func apiError(name string, resp *http.Response, body []byte) error {
+	return fmt.Errorf("%s: HTTP %d: %s", name, resp.StatusCode, strings.TrimSpace(string(body)))
+}
@@ -0,0 +1,188 @@
+package secrets_test
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/epheer/notephee/config"
+	"github.com/epheer/notephee/config/secrets"
+)
+
+func TestVault(t *testing.T) {
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if r.URL.Path != "/v1/secret/data/notephee" || r.Header.Get("X-Vault-Token") != "s.test" {
+			http.Error(w, `{"errors":[]}`, http.StatusForbidden)
+			return
+		}
+		_, _ = w.Write([]byte(`{"data":{"data":{"telegram_token":"123:vault","SMTP_PASSWORD":"pw",` +
+			`"unsubscribe_key":"0123456789abcdef0123456789abcdef","POSTGRES_URL":"postgres://u:p@db/notephee"}}}`))
+	}))
+	defer srv.Close()
+
+	p, err := secrets.NewVault(srv.URL, "s.test", "secret/notephee", srv.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	if v, err := p.Secret(ctx, "TELEGRAM_TOKEN"); err != nil || v != "123:vault" {
+		t.Errorf("TELEGRAM_TOKEN: %q, %v", v, err)
+	}
+	if v, err := p.Secret(ctx, "SMTP_PASSWORD"); err != nil || v != "pw" {
+		t.Errorf("SMTP_PASSWORD: %q, %v", v, err)
+	}
+	if _, err := p.Secret(ctx, "SLACK_TOKEN"); !errors.Is(err, config.ErrSecretNotFound) {
+		t.Errorf("ожидалась ErrSecretNotFound, получено %v", err)
+	}
+
+	// Загрузка конфигурации читает путь один раз и берёт из него ключи подписи и адреса баз
+	requests.Store(0)
+	cfg, err := config.Build(ctx, config.Sources{Secrets: p}, slog.New(slog.DiscardHandler))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := requests.Load(); n != 1 {
+		t.Errorf("путь Vault должен читаться один раз за загрузку, запросов: %d", n)
+	}
+	if cfg.TelegramToken != "123:vault" || cfg.UnsubscribeKey == "" || cfg.PostgresURL != "postgres://u:p@db/notephee" {
+		t.Errorf("секреты из Vault не применены: %q %q %q", cfg.TelegramToken, cfg.UnsubscribeKey, cfg.PostgresURL)
+	}
+}
+
+func TestAWS(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth := r.Header.Get("Authorization")
+		if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=AKIDTEST/") || !strings.Contains(auth, "/eu-central-1/secretsmanager/aws4_request") ||
+			r.Header.Get("X-Amz-Security-Token") != "session" || r.Header.Get("X-Amz-Target") != "secretsmanager.GetSecretValue" {
+			http.Error(w, "bad signature", http.StatusForbidden)
+			return
+		}
+		var req struct{ SecretId string }
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		if req.SecretId != "notephee/TELEGRAM_TOKEN" {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"__type":"ResourceNotFoundException"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"SecretString":"123:aws"}`))
+	}))
+	defer srv.Close()
+
+	p, err := secrets.NewAWS("eu-central-1", "AKIDTEST", "secret", "session", "notephee/", srv.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.SetBaseURL(srv.URL)
+	if v, err := p.Secret(context.Background(), "TELEGRAM_TOKEN"); err != nil || v != "123:aws" {
+		t.Errorf("TELEGRAM_TOKEN: %q, %v", v, err)
+	}
+	if _, err := p.Secret(context.Background(), "SMTP_PASSWORD"); !errors.Is(err, config.ErrSecretNotFound) {
+		t.Errorf("ожидалась ErrSecretNotFound, получено %v", err)
+	}
+}
+
+func TestGCP(t *testing.T) {
+	t.Setenv("GOOGLE_OAUTH_ACCESS_TOKEN", "")
+	var tokenRequests atomic.Int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		tokenRequests.Add(1)
+		if r.Header.Get("Metadata-Flavor") != "Google" {
+			http.Error(w, "no flavor", http.StatusForbidden)
+			return
+		}
+		_, _ = w.Write([]byte(`{"access_token":"ya29.test","expires_in":3600}`))
+	})
+	mux.HandleFunc("/v1/projects/p/secrets/notephee-TELEGRAM_TOKEN/versions/latest:access", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer ya29.test" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		data := base64.StdEncoding.EncodeToString([]byte("123:gcp"))
+		_, _ = w.Write([]byte(`{"payload":{"data":"` + data + `"}}`))
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	p, err := secrets.NewGCP("projects/p/secrets/notephee-", srv.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.SetBaseURL(srv.URL, srv.URL+"/token")
+	for range 2 {
+		if v, err := p.Secret(context.Background(), "TELEGRAM_TOKEN"); err != nil || v != "123:gcp" {
+			t.Errorf("TELEGRAM_TOKEN: %q, %v", v, err)
+		}
+	}
+	if _, err := p.Secret(context.Background(), "SMTP_PASSWORD"); !errors.Is(err, config.ErrSecretNotFound) {
+		t.Errorf("ожидалась ErrSecretNotFound, получено %v", err)
+	}
+	if n := tokenRequests.Load(); n != 1 {
+		t.Errorf("токен должен запрашиваться один раз до истечения срока, запросов: %d", n)
+	}
+}
+
+func TestLoadFromSecrets(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, value string) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(value), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("TELEGRAM_TOKEN", "123:file\n")
+
+	t.Setenv("NOTEPHEE_TELEGRAM_TOKEN", "123:env")
+	t.Setenv("NOTEPHEE_TELEGRAM_BOT_NAME", "notephee_bot")
+	t.Setenv("NOTEPHEE_SMTP_PASSWORD", "env-password")
+	t.Setenv("NOTEPHEE_SECRETS_PROVIDER", secrets.File)
+	t.Setenv("NOTEPHEE_SECRETS_PATH", dir)
+
+	logger := slog.New(slog.DiscardHandler)
+	src := config.Sources{}
+	store, err := secrets.FromSources(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	src.Secrets = store
+	cfg, err := config.LoadFrom(context.Background(), src, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.TelegramToken != "123:file" {
+		t.Errorf("секрет из хранилища должен перекрывать окружение, получено %q", cfg.TelegramToken)
+	}
+	if cfg.EmailPassword != "env-password" {
+		t.Errorf("секрет, которого нет в хранилище, берётся из окружения, получено %q", cfg.EmailPassword)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	changed := make(chan *config.Config, 1)
+	go secrets.Watch(ctx, src, 10*time.Millisecond, cfg, logger, func(next *config.Config) {
+		changed <- next
+	})
+	write("TELEGRAM_TOKEN", "123:rotated")
+	select {
+	case next := <-changed:
+		if next.TelegramToken != "123:rotated" {
+			t.Errorf("ожидался новый токен, получено %q", next.TelegramToken)
+		}
+		if config.Get(logger) != next {
+			t.Error("новая конфигурация не стала текущей")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("ротация секрета не обнаружена")
+	}
+}
@@ -0,0 +1,113 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/epheer/notephee/config"
+)
+
+// VaultProvider читает секреты из одного пути хранилища KV v2 HashiCorp Vault. Ключи в пути —
+// имена переменных без префикса в любом регистре: telegram_token или TELEGRAM_TOKEN.
+//
+// VaultProvider реализует config.SecretLister: при загрузке конфигурации путь читается один раз.
+type VaultProvider struct {
+	addr      string
+	token     string
+	mount     string
+	path      string
+	namespace string
+	http      *http.Client
+}
+
+// NewVault создаёт хранилище Vault по адресу addr с токеном token. path — mount/path секрета KV v2,
+// например secret/notephee.
+func NewVault(addr, token, path string, client *http.Client) (*VaultProvider, error) {
+	if addr == "" || token == "" {
+		return nil, fmt.Errorf("для Vault нужны VAULT_ADDR и VAULT_TOKEN")
+	}
+	mount, rest, ok := strings.Cut(strings.Trim(path, "/"), "/")
+	if !ok || rest == "" {
+		return nil, fmt.Errorf("путь Vault должен быть вида mount/path, получено %q", path)
+	}
+	return &VaultProvider{
+		addr:  strings.TrimRight(addr, "/"),
+		token: token,
+		mount: mount,
+		path:  rest,
+		http:  client,
+	}, nil
+}
+
+// SetNamespace задаёт пространство имён Vault Enterprise (заголовок X-Vault-Namespace).
+func (p *VaultProvider) SetNamespace(namespace string) {
+	p.namespace = namespace
+}
+
+// Secret возвращает значение ключа name из пути хранилища.
+func (p *VaultProvider) Secret(ctx context.Context, name string) (string, error) {
+	all, err := p.Secrets(ctx)
+	if err != nil {
+		return "", err
+	}
+	value, ok := all[strings.ToUpper(name)]
+	if !ok {
+		return "", config.ErrSecretNotFound
+	}
+	return value, nil
+}
+
+// Secrets читает путь хранилища одним запросом и возвращает все ключи в верхнем регистре.
+// Отсутствующий путь — пустой результат без ошибки.
+func (p *VaultProvider) Secrets(ctx context.Context) (map[string]string, error) {
+	uri := fmt.Sprintf("%s/v1/%s/data/%s", p.addr, p.mount, p.path)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, uri, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Vault-Token", p.token)
+	if p.namespace != "" {
+		req.Header.Set("X-Vault-Namespace", p.namespace)
+	}
+
+	resp, err := p.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	body, err := readBody(resp)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return map[string]string{}, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, apiError("Vault", resp, body)
+	}
+
+	var res struct {
+		Data struct {
+			Data map[string]any `json:"data"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &res); err != nil {
+		return nil, fmt.Errorf("некорректный ответ Vault: %w", err)
+	}
+	out := make(map[string]string, len(res.Data.Data))
+	for key, value := range res.Data.Data {
+		if s, ok := value.(string); ok {
+			out[strings.ToUpper(key)] = s
+			continue
+		}
+		data, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		out[strings.ToUpper(key)] = string(data)
+	}
+	return out, nil
+}
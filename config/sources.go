@@ -0,0 +1,212 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Names — имена всех переменных конфигурации без префикса NOTEPHEE_.
+var Names = []string{
+	"TELEGRAM_TOKEN", "TELEGRAM_BOT_NAME", "TELEGRAM_PROXY", "TELEGRAM_API_URL",
+	"SMTP_HOST", "SMTP_PORT", "SMTP_USER", "SMTP_PASSWORD", "SMTP_FROM_NAME",
+	"EMAIL_PROVIDER", "SENDGRID_API_KEY", "MAILGUN_DOMAIN", "MAILGUN_API_KEY", "MAILGUN_REGION",
+	"SES_REGION", "SES_ACCESS_KEY_ID", "SES_SECRET_ACCESS_KEY", "EMAIL_DOMAIN_LIMITS",
+	"IMAP_ADDR", "IMAP_SENT_FOLDER",
+	"SLACK_WEBHOOK_URL", "SLACK_TOKEN", "TEAMCHAT_TARGETS", "IDENTITIES", "BUNDLE_KEY",
+	"MATRIX_HOMESERVER", "MATRIX_TOKEN", "VK_TOKEN",
+	"VIBER_TOKEN", "VIBER_SENDER_NAME", "VIBER_SENDER_AVATAR",
+	"SERVER_ADDR", "SERVER_TOKEN", "ADMIN_TOKEN", "GRPC_ADDR",
+	"KAFKA_BROKERS", "KAFKA_TOPIC", "KAFKA_GROUP", "KAFKA_DLQ_TOPIC", "KAFKA_FORMAT",
+	"NATS_URL", "NATS_STREAM", "NATS_CONSUMER", "NATS_SUBJECT", "NATS_DLQ_SUBJECT", "AMQP_URL", "AMQP_QUEUE",
+	"REDIS_URL", "POSTGRES_URL", "SQLITE_PATH",
+	"DEDUP_WINDOW", "DIGEST_INTERVAL", "OPT_IN_CATEGORIES", "UNSUBSCRIBE_KEY", "UNSUBSCRIBE_URL",
+	"TRACKING_KEY", "TRACKING_URL",
+	"DEGRADE_LATENCY", "DEGRADE_LOW", "DEGRADE_NORMAL", "INDETERMINATE_POLICY",
+	"OVERFLOW_STRATEGY", "OVERFLOW_CATEGORIES", "OVERFLOW_MORE_URL", "QUEUE_RATES",
+	"SPOOL_DIR", "SHUTDOWN_TIMEOUT", "REPLAY_DIR", "ALERT_TELEGRAM_CHAT", "ALERT_EMAIL",
+	"SECRETS_PROVIDER", "SECRETS_PATH", "SECRETS_REFRESH",
+}
+
+// Sources — источники настроек помимо переменных окружения. Приоритет: флаги, затем переменные
+// окружения, затем файл, затем значения по умолчанию. Секреты, найденные в хранилище Secrets,
+// важнее всех остальных источников.
+type Sources struct {
+	File    string            // Файл настроек .yaml, .yml, .json или .toml (необязательно)
+	Flags   map[string]string // Значения флагов по имени переменной без префикса (см. RegisterFlags)
+	Secrets SecretProvider    // Хранилище секретов (необязательно)
+}
+
+// LoadFrom загружает конфигурацию из src и переменных окружения и делает её текущей для Get.
+// Пустая переменная окружения не перекрывает значение из файла: так env-файл, скопированный
+// из .env.dist с пустыми значениями, не стирает настройки.
+func LoadFrom(ctx context.Context, src Sources, logger *slog.Logger) (*Config, error) {
+	cfg, err := Build(ctx, src, logger)
+	if err != nil {
+		return nil, err
+	}
+	Set(cfg)
+	return cfg, nil
+}
+
+// Build загружает конфигурацию так же, как LoadFrom, но не делает её текущей. Нужна, чтобы
+// перечитать настройки в фоне и заменить текущие через Set только после проверки.
+func Build(ctx context.Context, src Sources, logger *slog.Logger) (*Config, error) {
+	lookup, err := src.Lookup()
+	if err != nil {
+		return nil, err
+	}
+	if src.Secrets != nil {
+		secrets, err := fetchSecrets(ctx, src.Secrets)
+		if err != nil {
+			return nil, err
+		}
+		plain := lookup
+		lookup = func(name string) string {
+			if v, ok := secrets[name]; ok {
+				return v
+			}
+			return plain(name)
+		}
+	}
+
+	return build(lookup, logger), nil
+}
+
+// Lookup возвращает функцию, которая ищет значение по имени переменной без префикса во флагах,
+// окружении и файле src — без хранилища секретов. Нужна, чтобы прочитать настройки самого хранилища.
+func (src Sources) Lookup() (func(string) string, error) {
+	var file map[string]string
+	if src.File != "" {
+		var err error
+		if file, err = ReadFile(src.File); err != nil {
+			return nil, err
+		}
+	}
+
+	return func(name string) string {
+		if v, ok := src.Flags[name]; ok {
+			return v
+		}
+		if v := getEnv(name); v != "" {
+			return v
+		}
+		return file[name]
+	}, nil
+}
+
+// ReadFile читает файл настроек и возвращает значения по имени переменной без префикса.
+//
+// Формат определяется по расширению. Ключи пишутся как имена переменных в нижнем регистре
+// (smtp_host) или вложенными разделами (smtp: {host: ...}) — части соединяются через «_».
+// Списки и объекты в значениях (identities, teamchat_targets) передаются как JSON.
+// Неизвестные ключи — ошибка, чтобы опечатка не превращалась в молча отключённый канал.
+func ReadFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось прочитать файл настроек: %w", err)
+	}
+
+	var raw map[string]any
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &raw)
+	case ".json":
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.UseNumber()
+		err = dec.Decode(&raw)
+	case ".toml":
+		err = toml.Unmarshal(data, &raw)
+	default:
+		return nil, fmt.Errorf("неподдерживаемый формат файла настроек %q: ожидается .yaml, .json или .toml", ext)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("некорректный файл настроек %s: %w", path, err)
+	}
+
+	out := make(map[string]string)
+	if err := flatten(raw, "", out); err != nil {
+		return nil, fmt.Errorf("файл настроек %s: %w", path, err)
+	}
+	return out, nil
+}
+
+// flatten раскладывает вложенные разделы в имена переменных.
+func flatten(m map[string]any, prefix string, out map[string]string) error {
+	for key, value := range m {
+		name := prefix + strings.ToUpper(strings.ReplaceAll(key, "-", "_"))
+		if section, ok := value.(map[string]any); ok && !slices.Contains(Names, name) {
+			if err := flatten(section, name+"_", out); err != nil {
+				return err
+			}
+			continue
+		}
+		if !slices.Contains(Names, name) {
+			return fmt.Errorf("неизвестный параметр %s", strings.ToLower(name))
+		}
+
+		switch v := value.(type) {
+		case string:
+			out[name] = v
+		case bool:
+			out[name] = strconv.FormatBool(v)
+		case int, int64, uint64, float64, json.Number:
+			out[name] = fmt.Sprint(v)
+		default:
+			data, err := json.Marshal(v)
+			if err != nil {
+				return fmt.Errorf("параметр %s: %w", strings.ToLower(name), err)
+			}
+			out[name] = string(data)
+		}
+	}
+	return nil
+}
+
+// Flags — флаги командной строки для настроек, зарегистрированные RegisterFlags.
+type Flags struct {
+	fs     *flag.FlagSet
+	values map[string]*string
+}
+
+// RegisterFlags добавляет в fs флаг для каждой настройки, кроме секретов (см. IsSecret): имя переменной
+// в нижнем регистре через дефис, например --smtp-host или --server-addr. Секреты, включая ключи подписи
+// и адреса баз и брокеров, флагами не задаются — командная строка видна другим пользователям в списке процессов.
+func RegisterFlags(fs *flag.FlagSet) *Flags {
+	f := &Flags{fs: fs, values: make(map[string]*string)}
+	for _, name := range Names {
+		if IsSecret(name) {
+			continue
+		}
+		f.values[name] = fs.String(flagName(name), "", "переменная NOTEPHEE_"+name)
+	}
+	return f
+}
+
+// Values возвращает значения флагов, заданных явно, для Sources.Flags. Вызывается после fs.Parse.
+func (f *Flags) Values() map[string]string {
+	out := make(map[string]string)
+	f.fs.Visit(func(fl *flag.Flag) {
+		for name, v := range f.values {
+			if flagName(name) == fl.Name {
+				out[name] = *v
+			}
+		}
+	})
+	return out
+}
+
+func flagName(name string) string {
+	return strings.ToLower(strings.ReplaceAll(name, "_", "-"))
+}
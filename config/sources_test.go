@@ -0,0 +1,109 @@
+package config_test
+
+import (
+	"context"
+	"flag"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/epheer/notephee/config"
+)
+
+func TestLoadFrom(t *testing.T) {
+	dir := t.TempDir()
+	yamlPath := filepath.Join(dir, "notephee.yaml")
+	if err := os.WriteFile(yamlPath, []byte(`
+smtp:
+  host: smtp.file.example
+  port: 587
+  user: noreply@example.com
+server_addr: ":9000"
+grpc_addr: ":9001"
+identities:
+  - name: acme
+    smtp_user: acme@example.com
+`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	settings := config.RegisterFlags(fs)
+	for _, name := range []string{"smtp-password", "unsubscribe-key", "tracking-key", "postgres-url", "redis-url", "amqp-url", "nats-url", "telegram-proxy"} {
+		if fs.Lookup(name) != nil {
+			t.Fatalf("секрет --%s не должен задаваться флагом", name)
+		}
+	}
+	if fs.Lookup("sqlite-path") == nil {
+		t.Fatal("обычные настройки должны задаваться флагами")
+	}
+	if err := fs.Parse([]string{"--grpc-addr", ":7001"}); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("NOTEPHEE_SERVER_ADDR", ":8000")
+	t.Setenv("NOTEPHEE_GRPC_ADDR", ":8001")
+	t.Setenv("NOTEPHEE_SMTP_HOST", "")
+
+	cfg, err := config.LoadFrom(context.Background(), config.Sources{File: yamlPath, Flags: settings.Values()}, slog.New(slog.DiscardHandler))
+	if err != nil {
+		t.Fatalf("LoadFrom: %v", err)
+	}
+	if cfg.GRPCAddr != ":7001" {
+		t.Errorf("флаг должен перекрывать окружение и файл, получено %q", cfg.GRPCAddr)
+	}
+	if cfg.ServerAddr != ":8000" {
+		t.Errorf("окружение должно перекрывать файл, получено %q", cfg.ServerAddr)
+	}
+	if cfg.EmailHost != "smtp.file.example" || cfg.EmailPort != "587" {
+		t.Errorf("значения вложенного раздела smtp не прочитаны: %q, %q", cfg.EmailHost, cfg.EmailPort)
+	}
+	if !strings.Contains(cfg.Identities, `"smtp_user":"acme@example.com"`) {
+		t.Errorf("список identities должен передаваться как JSON, получено %q", cfg.Identities)
+	}
+
+	t.Setenv("NOTEPHEE_SERVER_ADDR", "")
+	cfg, err = config.LoadFrom(context.Background(), config.Sources{}, slog.New(slog.DiscardHandler))
+	if err != nil {
+		t.Fatalf("LoadFrom без файла: %v", err)
+	}
+	if cfg.ServerAddr != "127.0.0.1:8080" {
+		t.Errorf("без токена сервер по умолчанию должен слушать только localhost, получено %q", cfg.ServerAddr)
+	}
+}
+
+func TestReadFile(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, data string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	values, err := config.ReadFile(write("a.toml", "dedup_window = \"5m\"\n[telegram]\nbot_name = \"notephee_bot\"\n"))
+	if err != nil {
+		t.Fatalf("TOML: %v", err)
+	}
+	if values["DEDUP_WINDOW"] != "5m" || values["TELEGRAM_BOT_NAME"] != "notephee_bot" {
+		t.Errorf("неожиданные значения TOML: %v", values)
+	}
+
+	values, err = config.ReadFile(write("a.json", `{"smtp_port": 465, "opt_in_categories": "news"}`))
+	if err != nil {
+		t.Fatalf("JSON: %v", err)
+	}
+	if values["SMTP_PORT"] != "465" || values["OPT_IN_CATEGORIES"] != "news" {
+		t.Errorf("неожиданные значения JSON: %v", values)
+	}
+
+	if _, err := config.ReadFile(write("b.yaml", "smtp:\n  hots: x\n")); err == nil || !strings.Contains(err.Error(), "smtp_hots") {
+		t.Errorf("опечатка в ключе должна быть ошибкой, получено %v", err)
+	}
+	if _, err := config.ReadFile(write("a.ini", "x=1")); err == nil {
+		t.Error("неизвестный формат файла должен быть ошибкой")
+	}
+}
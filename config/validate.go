@@ -0,0 +1,293 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/mail"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// FieldError — ошибка в одной переменной конфигурации.
+type FieldError struct {
+	Field   string // Переменная окружения, например NOTEPHEE_SMTP_PORT
+	Message string // Что с ней не так
+}
+
+// Error возвращает текст ошибки с именем переменной.
+func (e FieldError) Error() string {
+	return e.Field + ": " + e.Message
+}
+
+// ValidationError перечисляет все ошибки конфигурации, найденные Validate.
+type ValidationError struct {
+	Errors []FieldError
+}
+
+// Error возвращает все ошибки одной строкой.
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Errors))
+	for i, fe := range e.Errors {
+		msgs[i] = fe.Error()
+	}
+	return fmt.Sprintf("некорректная конфигурация (%d): %s", len(e.Errors), strings.Join(msgs, "; "))
+}
+
+// Unwrap возвращает ошибки отдельных переменных для errors.As.
+func (e *ValidationError) Unwrap() []error {
+	errs := make([]error, len(e.Errors))
+	for i, fe := range e.Errors {
+		errs[i] = fe
+	}
+	return errs
+}
+
+var (
+	// telegramTokenRe — формат токена бота от @BotFather: <ID бота>:<ключ>.
+	telegramTokenRe = regexp.MustCompile(`^[0-9]+:[A-Za-z0-9_-]+$`)
+	// botNameRe — имя бота без @: латиница, цифры и подчёркивание, оканчивается на bot.
+	botNameRe = regexp.MustCompile(`(?i)^[a-z][a-z0-9_]{1,30}bot$`)
+)
+
+// Validate проверяет конфигурацию и возвращает *ValidationError со всеми найденными ошибками или nil.
+//
+// Незаполненный канал не считается ошибкой — он просто отключён. Ошибкой считаются частично заполненные
+// каналы, некорректные порты, адреса, токены и ссылки, а также значения, которые не удалось разобрать
+// при загрузке из окружения.
+func (c *Config) Validate() error {
+	v := &validator{}
+	v.errs = append(v.errs, c.loadErrs...)
+
+	if c.TelegramToken != "" || c.TelegramBotName != "" {
+		v.required("TELEGRAM_TOKEN", c.TelegramToken)
+		v.required("TELEGRAM_BOT_NAME", c.TelegramBotName)
+		if c.TelegramToken != "" && !telegramTokenRe.MatchString(c.TelegramToken) {
+			v.add("TELEGRAM_TOKEN", "ожидается токен вида 123456789:AAE… от @BotFather")
+		}
+		if c.TelegramBotName != "" && !botNameRe.MatchString(c.TelegramBotName) {
+			v.add("TELEGRAM_BOT_NAME", "ожидается имя бота без @, оканчивающееся на bot")
+		}
+	}
+	v.url("TELEGRAM_API_URL", c.TelegramAPIURL, "http", "https")
+	v.url("TELEGRAM_PROXY", c.TelegramProxy, "http", "https", "socks5", "socks5h")
+
+	c.validateEmail(v)
+	if c.EmailDomainLimits != "" {
+		if _, err := ParseDomainLimits(c.EmailDomainLimits); err != nil {
+			v.add("EMAIL_DOMAIN_LIMITS", err.Error())
+		}
+	}
+	v.addr("IMAP_ADDR", c.IMAPAddr)
+	if c.IMAPAddr != "" && c.EmailPassword == "" {
+		v.add("IMAP_ADDR", "копии писем сохраняются по логину и паролю SMTP, а SMTP_PASSWORD не задан")
+	}
+
+	v.url("SLACK_WEBHOOK_URL", c.SlackWebhookURL, "https")
+	if c.TeamChatTargets != "" && !json.Valid([]byte(c.TeamChatTargets)) {
+		v.add("TEAMCHAT_TARGETS", "ожидается JSON-массив целей")
+	}
+	if c.Identities != "" {
+		if _, err := ParseIdentities(c.Identities); err != nil {
+			v.add("IDENTITIES", err.Error())
+		}
+	}
+	if c.MatrixHomeserver != "" || c.MatrixToken != "" {
+		v.required("MATRIX_HOMESERVER", c.MatrixHomeserver)
+		v.required("MATRIX_TOKEN", c.MatrixToken)
+	}
+	v.url("MATRIX_HOMESERVER", c.MatrixHomeserver, "http", "https")
+	if c.ViberToken != "" || c.ViberSenderName != "" {
+		v.required("VIBER_TOKEN", c.ViberToken)
+		v.required("VIBER_SENDER_NAME", c.ViberSenderName)
+	}
+	v.url("VIBER_SENDER_AVATAR", c.ViberSenderAvatar, "http", "https")
+
+	v.addr("SERVER_ADDR", c.ServerAddr)
+	if c.ServerAddr != "" && c.ServerToken == "" && !isLoopback(c.ServerAddr) {
+		v.add("SERVER_ADDR", "без NOTEPHEE_SERVER_TOKEN HTTP API можно слушать только на localhost")
+	}
+	v.addr("GRPC_ADDR", c.GRPCAddr)
+	if c.GRPCAddr != "" && c.ServerToken == "" {
+		v.add("GRPC_ADDR", "gRPC API принимает вызовы только с токеном, а NOTEPHEE_SERVER_TOKEN не задан")
+	}
+	if c.KafkaBrokers != "" || c.KafkaTopic != "" {
+		v.required("KAFKA_BROKERS", c.KafkaBrokers)
+		v.required("KAFKA_TOPIC", c.KafkaTopic)
+	}
+	for _, broker := range c.KafkaBrokerList() {
+		v.addr("KAFKA_BROKERS", broker)
+	}
+	switch c.KafkaFormat {
+	case "", "json", "avro", "avro-confluent":
+	default:
+		v.add("KAFKA_FORMAT", fmt.Sprintf("неизвестный формат %q: ожидается json, avro или avro-confluent", c.KafkaFormat))
+	}
+	if c.NATSURL != "" || c.NATSStream != "" {
+		v.required("NATS_URL", c.NATSURL)
+		v.required("NATS_STREAM", c.NATSStream)
+	}
+	// nats.Connect принимает несколько адресов через запятую
+	for _, u := range strings.Split(c.NATSURL, ",") {
+		v.url("NATS_URL", strings.TrimSpace(u), "nats", "tls")
+	}
+	if c.AMQPURL != "" || c.AMQPQueue != "" {
+		v.required("AMQP_URL", c.AMQPURL)
+		v.required("AMQP_QUEUE", c.AMQPQueue)
+	}
+	v.url("AMQP_URL", c.AMQPURL, "amqp", "amqps")
+	v.url("REDIS_URL", c.RedisURL, "redis", "rediss")
+	v.url("POSTGRES_URL", c.PostgresURL, "postgres", "postgresql")
+	if c.PostgresURL != "" && c.SQLitePath != "" {
+		v.add("SQLITE_PATH", "задайте одну базу: NOTEPHEE_POSTGRES_URL или NOTEPHEE_SQLITE_PATH")
+	}
+	if c.UnsubscribeKey != "" || c.UnsubscribeURL != "" {
+		v.required("UNSUBSCRIBE_KEY", c.UnsubscribeKey)
+		v.required("UNSUBSCRIBE_URL", c.UnsubscribeURL)
+	}
+	v.key("UNSUBSCRIBE_KEY", c.UnsubscribeKey)
+	v.url("UNSUBSCRIBE_URL", c.UnsubscribeURL, "http", "https")
+	if c.TrackingKey != "" || c.TrackingURL != "" {
+		v.required("TRACKING_KEY", c.TrackingKey)
+		v.required("TRACKING_URL", c.TrackingURL)
+	}
+	v.key("TRACKING_KEY", c.TrackingKey)
+	v.url("TRACKING_URL", c.TrackingURL, "http", "https")
+	v.key("BUNDLE_KEY", c.BundleKey)
+	switch c.SecretsProvider {
+	case "":
+	case "vault", "aws", "gcp", "file":
+		v.required("SECRETS_PATH", c.SecretsPath)
+	default:
+		v.add("SECRETS_PROVIDER", fmt.Sprintf("неизвестное хранилище %q: ожидается vault, aws, gcp или file", c.SecretsProvider))
+	}
+	v.url("OVERFLOW_MORE_URL", strings.ReplaceAll(c.OverflowMoreURL, "{id}", "id"), "http", "https")
+
+	if c.AlertTelegramChat != "" && c.TelegramToken == "" {
+		v.add("ALERT_TELEGRAM_CHAT", "оповещения отправляются ботом, а TELEGRAM_TOKEN не задан")
+	}
+	if c.AlertEmail != "" {
+		if addr, err := mail.ParseAddress(c.AlertEmail); err != nil || addr.Address != c.AlertEmail {
+			v.add("ALERT_EMAIL", "ожидается адрес электронной почты, например ops@example.com")
+		} else if c.EmailUser == "" {
+			v.add("ALERT_EMAIL", "оповещения отправляются почтой, а SMTP_USER не задан")
+		}
+	}
+
+	if len(v.errs) == 0 {
+		return nil
+	}
+	return &ValidationError{Errors: v.errs}
+}
+
+// validateEmail проверяет настройки отправки писем через SMTP или HTTP API провайдера.
+func (c *Config) validateEmail(v *validator) {
+	switch strings.ToLower(c.EmailProvider) {
+	case "", "smtp":
+		if c.EmailHost == "" && c.EmailPort == "" && c.EmailUser == "" && c.EmailPassword == "" {
+			return
+		}
+		v.required("SMTP_HOST", c.EmailHost)
+		v.required("SMTP_PORT", c.EmailPort)
+		v.required("SMTP_PASSWORD", c.EmailPassword)
+	case "sendgrid":
+		v.required("SENDGRID_API_KEY", c.SendGridAPIKey)
+	case "mailgun":
+		v.required("MAILGUN_DOMAIN", c.MailgunDomain)
+		v.required("MAILGUN_API_KEY", c.MailgunAPIKey)
+		if r := strings.ToLower(c.MailgunRegion); r != "" && r != "us" && r != "eu" {
+			v.add("MAILGUN_REGION", "ожидается us или eu")
+		}
+	case "ses":
+		v.required("SES_REGION", c.SESRegion)
+		v.required("SES_ACCESS_KEY_ID", c.SESAccessKeyID)
+		v.required("SES_SECRET_ACCESS_KEY", c.SESSecretAccessKey)
+	default:
+		v.add("EMAIL_PROVIDER", fmt.Sprintf("неизвестный провайдер %q: ожидается smtp, sendgrid, mailgun или ses", c.EmailProvider))
+		return
+	}
+
+	// Адрес отправителя нужен и для SMTP, и для провайдеров
+	v.required("SMTP_USER", c.EmailUser)
+	if c.EmailUser != "" {
+		if addr, err := mail.ParseAddress(c.EmailUser); err != nil || addr.Address != c.EmailUser {
+			v.add("SMTP_USER", "ожидается адрес электронной почты, например noreply@example.com")
+		}
+	}
+	if c.EmailPort != "" {
+		if port, err := strconv.Atoi(c.EmailPort); err != nil || port < 1 || port > 65535 {
+			v.add("SMTP_PORT", "ожидается номер порта от 1 до 65535")
+		}
+	}
+}
+
+// validator собирает ошибки переменных.
+type validator struct {
+	errs []FieldError
+}
+
+func (v *validator) add(name, msg string) {
+	v.errs = append(v.errs, FieldError{Field: "NOTEPHEE_" + name, Message: msg})
+}
+
+// required отмечает пустое обязательное значение.
+func (v *validator) required(name, value string) {
+	if value == "" {
+		v.add(name, "значение обязательно")
+	}
+}
+
+// url проверяет, что непустое значение — абсолютная ссылка с одной из схем schemes.
+func (v *validator) url(name, value string, schemes ...string) {
+	if value == "" {
+		return
+	}
+	u, err := url.Parse(value)
+	if err != nil || u.Host == "" {
+		v.add(name, "ожидается абсолютная ссылка")
+		return
+	}
+	for _, s := range schemes {
+		if u.Scheme == s {
+			return
+		}
+	}
+	v.add(name, fmt.Sprintf("неподдерживаемая схема %q: ожидается %s", u.Scheme, strings.Join(schemes, ", ")))
+}
+
+// addr проверяет, что непустое значение — адрес для прослушивания вида host:port или :port.
+func (v *validator) addr(name, value string) {
+	if value == "" {
+		return
+	}
+	_, port, err := net.SplitHostPort(value)
+	if err != nil {
+		v.add(name, "ожидается адрес вида host:port или :port")
+		return
+	}
+	if n, err := strconv.Atoi(port); err != nil || n < 0 || n > 65535 {
+		v.add(name, "ожидается номер порта от 0 до 65535")
+	}
+}
+
+// key проверяет длину непустого ключа подписи.
+func (v *validator) key(name, value string) {
+	if value != "" && len(value) < 32 {
+		v.add(name, "ключ подписи должен быть не короче 32 байт")
+	}
+}
+
+// isLoopback сообщает, что адрес host:port слушает только локальные подключения.
+func isLoopback(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
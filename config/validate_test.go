@@ -0,0 +1,84 @@
+package config_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/epheer/notephee/config"
+)
+
+func TestValidate(t *testing.T) {
+	valid := config.Config{
+		TelegramToken:   "123456789:AAE-abc_DEF",
+		TelegramBotName: "notephee_bot",
+		EmailHost:       "smtp.example.com",
+		EmailPort:       "587",
+		EmailUser:       "noreply@example.com",
+		EmailPassword:   "secret",
+		ServerAddr:      ":8080",
+		ServerToken:     "token",
+	}
+	if err := valid.Validate(); err != nil {
+		t.Fatalf("корректная конфигурация не прошла проверку: %v", err)
+	}
+	if err := (&config.Config{ServerAddr: "127.0.0.1:8080"}).Validate(); err != nil {
+		t.Fatalf("пустая конфигурация — все каналы отключены, а не ошибка: %v", err)
+	}
+
+	bad := valid
+	bad.TelegramToken = "not-a-token"
+	bad.TelegramBotName = ""
+	bad.EmailPort = "99999"
+	bad.EmailUser = "noreply"
+	bad.UnsubscribeKey = "short"
+	bad.EmailDomainLimits = `[{"domains":["gmail.com"],"rate":0}]`
+	bad.AlertEmail = "ops"
+	bad.KafkaBrokers = "kafka-1"
+	bad.KafkaFormat = "protobuf"
+	bad.AMQPURL = "http://rabbit:5672"
+	bad.PostgresURL = "postgres://db:5432/notephee"
+	bad.SQLitePath = "notephee.db"
+	bad.GRPCAddr = ":9090"
+	bad.ServerToken = ""
+	err := bad.Validate()
+
+	var verr *config.ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("ожидалась ValidationError, получено %v", err)
+	}
+	want := map[string]bool{
+		"NOTEPHEE_TELEGRAM_TOKEN":      true,
+		"NOTEPHEE_TELEGRAM_BOT_NAME":   true,
+		"NOTEPHEE_SMTP_PORT":           true,
+		"NOTEPHEE_SMTP_USER":           true,
+		"NOTEPHEE_UNSUBSCRIBE_KEY":     true,
+		"NOTEPHEE_UNSUBSCRIBE_URL":     true,
+		"NOTEPHEE_EMAIL_DOMAIN_LIMITS": true,
+		"NOTEPHEE_ALERT_EMAIL":         true,
+		"NOTEPHEE_KAFKA_BROKERS":       true,
+		"NOTEPHEE_KAFKA_TOPIC":         true,
+		"NOTEPHEE_KAFKA_FORMAT":        true,
+		"NOTEPHEE_AMQP_URL":            true,
+		"NOTEPHEE_AMQP_QUEUE":          true,
+		"NOTEPHEE_SQLITE_PATH":         true,
+		"NOTEPHEE_GRPC_ADDR":           true,
+		"NOTEPHEE_SERVER_ADDR":         true,
+	}
+	got := make(map[string]bool)
+	for _, fe := range verr.Errors {
+		got[fe.Field] = true
+	}
+	for field := range want {
+		if !got[field] {
+			t.Errorf("нет ошибки для %s: %v", field, err)
+		}
+	}
+	if len(got) != len(want) {
+		t.Errorf("лишние ошибки: %v", err)
+	}
+
+	var fe config.FieldError
+	if !errors.As(err, &fe) {
+		t.Fatal("ошибки отдельных переменных должны извлекаться через errors.As")
+	}
+}
@@ -0,0 +1,138 @@
+package dedup
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/epheer/notephee/delivery"
+	"github.com/epheer/notephee/notify"
+)
+
+// Store хранит ключи уже отправленных сообщений в пределах окна дедупликации.
+//
+// Реализации должны быть безопасны для конкурентного использования, а Claim — атомарен,
+// чтобы два одновременных одинаковых сообщения не прошли оба.
+type Store interface {
+	// Claim занимает ключ на ttl. Возвращает false, если ключ уже занят.
+	Claim(ctx context.Context, key string, ttl time.Duration) (bool, error)
+	// Release освобождает ключ, например если отправка не удалась.
+	Release(ctx context.Context, key string) error
+}
+
+// MemoryStore — Store в памяти процесса.
+type MemoryStore struct {
+	mu    sync.Mutex
+	keys  map[string]time.Time // Ключ → время освобождения
+	purge time.Time            // Время следующей очистки просроченных ключей
+}
+
+// NewMemoryStore создаёт пустое хранилище ключей в памяти.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{keys: make(map[string]time.Time)}
+}
+
+// Claim занимает ключ на ttl.
+func (s *MemoryStore) Claim(_ context.Context, key string, ttl time.Duration) (bool, error) {
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if now.After(s.purge) {
+		for k, until := range s.keys {
+			if now.After(until) {
+				delete(s.keys, k)
+			}
+		}
+		s.purge = now.Add(time.Minute)
+	}
+
+	if until, ok := s.keys[key]; ok && now.Before(until) {
+		return false, nil
+	}
+	s.keys[key] = now.Add(ttl)
+	return true, nil
+}
+
+// Release освобождает ключ.
+func (s *MemoryStore) Release(_ context.Context, key string) error {
+	s.mu.Lock()
+	delete(s.keys, key)
+	s.mu.Unlock()
+	return nil
+}
+
+// Sender — обёртка над notify.Sender, схлопывающая повторные сообщения одному получателю
+// в пределах окна. Нужна для алертов, когда «мигающая» проверка шлёт одно и то же каждые секунды.
+type Sender struct {
+	next       notify.Sender // Обёрнутый канал
+	store      Store         // Хранилище ключей
+	window     time.Duration // Окно дедупликации
+	logger     *slog.Logger  // Логгер
+	suppressed atomic.Int64  // Число схлопнутых сообщений
+}
+
+// Wrap оборачивает канал next дедупликацией с окном window.
+func Wrap(next notify.Sender, store Store, window time.Duration, logger *slog.Logger) *Sender {
+	return &Sender{next: next, store: store, window: window, logger: logger}
+}
+
+// Channel возвращает имя обёрнутого канала.
+func (s *Sender) Channel() string {
+	return s.next.Channel()
+}
+
+// Unwrap возвращает обёрнутый канал.
+func (s *Sender) Unwrap() notify.Sender {
+	return s.next
+}
+
+// Send отправляет сообщение, если такое же не отправлялось получателю в пределах окна.
+//
+// Ключ — (канал, получатель, msg.DedupKey); при пустом DedupKey используется хэш темы и текста.
+// Схлопнутое сообщение не считается ошибкой: Send возвращает nil.
+// Если отправка не удалась, ключ освобождается, чтобы повтор смог пройти.
+func (s *Sender) Send(ctx context.Context, msg notify.Message) error {
+	key := Key(s.next.Channel(), msg)
+
+	ok, err := s.store.Claim(ctx, key, s.window)
+	if err != nil {
+		// Недоступное хранилище не должно блокировать уведомления: лучше дубль, чем пропуск
+		s.logger.Warn("хранилище дедупликации недоступно, отправляем без проверки", "error", err)
+		return s.next.Send(ctx, msg)
+	}
+	if !ok {
+		s.suppressed.Add(1)
+		s.logger.Debug("повторное сообщение схлопнуто", "channel", s.next.Channel(), "to", msg.To, "dedup_key", msg.DedupKey)
+		return nil
+	}
+
+	if err := s.next.Send(ctx, msg); err != nil {
+		if relErr := s.store.Release(context.WithoutCancel(ctx), key); relErr != nil {
+			s.logger.Warn("не удалось освободить ключ дедупликации", "error", relErr)
+		}
+		return err
+	}
+	return nil
+}
+
+// Suppressed возвращает число сообщений, схлопнутых с момента создания обёртки.
+func (s *Sender) Suppressed() int64 {
+	return s.suppressed.Load()
+}
+
+// Key возвращает ключ дедупликации сообщения в канале. Сообщения разных личностей отправителя
+// не схлопываются между собой.
+func Key(channel string, msg notify.Message) string {
+	k := msg.DedupKey
+	if k == "" {
+		k = delivery.Hash(msg.Subject, msg.Text)
+	}
+	if msg.Identity != "" {
+		channel += "@" + msg.Identity
+	}
+	return channel + ":" + msg.To + ":" + k
+}
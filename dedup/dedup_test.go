@@ -0,0 +1,67 @@
+package dedup_test
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/epheer/notephee/dedup"
+	"github.com/epheer/notephee/notify"
+)
+
+type recordingSender struct {
+	sent int
+	fail bool
+}
+
+func (s *recordingSender) Channel() string { return "fake" }
+
+func (s *recordingSender) Send(context.Context, notify.Message) error {
+	if s.fail {
+		return errors.New("канал недоступен")
+	}
+	s.sent++
+	return nil
+}
+
+func TestWindowCollapsesRepeats(t *testing.T) {
+	ctx := context.Background()
+	next := &recordingSender{}
+	s := dedup.Wrap(next, dedup.NewMemoryStore(), 50*time.Millisecond, slog.Default())
+
+	alert := notify.Message{To: "1", Text: "db down", DedupKey: "db-check"}
+	for range 5 {
+		if err := s.Send(ctx, alert); err != nil {
+			t.Fatalf("Ошибка Send: %v", err)
+		}
+	}
+	_ = s.Send(ctx, notify.Message{To: "2", Text: "db down", DedupKey: "db-check"})
+
+	if next.sent != 2 || s.Suppressed() != 4 {
+		t.Fatalf("ожидалось 2 отправки и 4 схлопнутых, получено %d и %d", next.sent, s.Suppressed())
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	_ = s.Send(ctx, alert)
+	if next.sent != 3 {
+		t.Fatal("после окончания окна сообщение должно отправляться снова")
+	}
+}
+
+func TestFailedSendReleasesKey(t *testing.T) {
+	ctx := context.Background()
+	next := &recordingSender{fail: true}
+	s := dedup.Wrap(next, dedup.NewMemoryStore(), time.Minute, slog.Default())
+
+	msg := notify.Message{To: "1", Text: "alert"}
+	if err := s.Send(ctx, msg); err == nil {
+		t.Fatal("ожидалась ошибка отправки")
+	}
+
+	next.fail = false
+	if err := s.Send(ctx, msg); err != nil || next.sent != 1 {
+		t.Fatal("повтор после ошибки должен пройти")
+	}
+}
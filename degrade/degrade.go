@@ -0,0 +1,325 @@
+// Package degrade реализует плавную деградацию канала при замедлении провайдера.
+//
+// Когда средняя задержка отправки превышает порог, низкоприоритетные сообщения откладываются
+// или отбрасываются (по политике), чтобы транзакционные уведомления укладывались в SLO.
+// Состояние доступно через Health и публикуется в events.Bus.
+package degrade
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/epheer/notephee/alert"
+	"github.com/epheer/notephee/events"
+	"github.com/epheer/notephee/notify"
+	"github.com/epheer/notephee/spool"
+)
+
+// ErrShed возвращается для сообщения, отброшенного из-за деградации канала.
+var ErrShed = errors.New("сообщение отброшено: канал в режиме деградации")
+
+// Action — что делать с сообщением, пока канал в режиме деградации.
+type Action string
+
+const (
+	Pass  Action = "pass"  // Отправлять как обычно
+	Defer Action = "defer" // Отложить до восстановления канала
+	Shed  Action = "shed"  // Отбросить с ошибкой ErrShed
+)
+
+// ParseAction разбирает название действия. Пустая строка допустима: в Policy она
+// заменяется действием по умолчанию.
+func ParseAction(s string) (Action, error) {
+	switch a := Action(s); a {
+	case "", Pass, Defer, Shed:
+		return a, nil
+	default:
+		return "", errors.New("неизвестное действие деградации: " + s)
+	}
+}
+
+// Policy — политика деградации канала. Нулевые значения заменяются значениями по умолчанию.
+type Policy struct {
+	Threshold   time.Duration // Средняя задержка, при превышении которой канал деградирует
+	Recover     time.Duration // Средняя задержка, ниже которой канал восстанавливается; по умолчанию Threshold/2
+	Low         Action        // Действие для PriorityLow; по умолчанию Defer
+	Normal      Action        // Действие для PriorityNormal; по умолчанию Pass
+	MaxDeferred int           // Предел отложенных сообщений, сверх него они отбрасываются; по умолчанию 10000
+	Retry       time.Duration // Период проверки и отправки отложенных сообщений в Run; по умолчанию 5s
+}
+
+func (p *Policy) defaults() {
+	if p.Recover <= 0 || p.Recover > p.Threshold {
+		p.Recover = p.Threshold / 2
+	}
+	if p.Low == "" {
+		p.Low = Defer
+	}
+	if p.Normal == "" {
+		p.Normal = Pass
+	}
+	if p.MaxDeferred <= 0 {
+		p.MaxDeferred = 10000
+	}
+	if p.Retry <= 0 {
+		p.Retry = 5 * time.Second
+	}
+}
+
+// State — состояние канала.
+type State string
+
+const (
+	StateOK       State = "ok"       // Задержка в норме
+	StateDegraded State = "degraded" // Низкоприоритетный трафик откладывается или отбрасывается
+)
+
+// Health — снимок состояния канала.
+type Health struct {
+	Channel  string        `json:"channel"`  // Имя канала
+	State    State         `json:"state"`    // Текущее состояние
+	Since    time.Time     `json:"since"`    // Время перехода в текущее состояние
+	Latency  time.Duration `json:"latency"`  // Средняя задержка отправки
+	Deferred int           `json:"deferred"` // Отложено сообщений сейчас
+	Shed     int64         `json:"shed"`     // Отброшено сообщений с момента создания обёртки
+}
+
+// ewmaAlpha — вес нового наблюдения в средней задержке.
+const ewmaAlpha = 0.2
+
+// Sender — обёртка над notify.Sender, снижающая нагрузку на медленный канал за счёт
+// низкоприоритетных сообщений.
+type Sender struct {
+	next   notify.Sender // Обёрнутый канал
+	logger *slog.Logger  // Логгер
+	bus    *events.Bus   // Шина событий (необязательно)
+	spool  spool.Store   // Хранилище отложенных сообщений при остановке (необязательно)
+
+	mu       sync.Mutex
+	policy   Policy           // Политика деградации
+	latency  float64          // Средняя задержка, наносекунды
+	measured bool             // Была ли хотя бы одна отправка
+	state    State            // Текущее состояние
+	since    time.Time        // Время перехода в состояние
+	deferred []notify.Message // Отложенные сообщения в порядке поступления
+	shed     int64            // Счётчик отброшенных сообщений
+}
+
+// Wrap оборачивает канал next политикой деградации. policy.Threshold обязателен.
+func Wrap(next notify.Sender, policy Policy, logger *slog.Logger) *Sender {
+	policy.defaults()
+	return &Sender{next: next, policy: policy, logger: logger, state: StateOK, since: time.Now()}
+}
+
+// SetPolicy заменяет политику деградации во время работы. Состояние канала и отложенные сообщения
+// сохраняются; новый Retry начинает действовать после перезапуска Run.
+func (s *Sender) SetPolicy(policy Policy) {
+	policy.defaults()
+	s.mu.Lock()
+	s.policy = policy
+	s.mu.Unlock()
+}
+
+// SetEvents подключает шину, в которую публикуются события events.Degraded и events.Recovered.
+func (s *Sender) SetEvents(bus *events.Bus) {
+	s.bus = bus
+}
+
+// SetSpool подключает хранилище, в которое Run при остановке сохраняет отложенные сообщения.
+func (s *Sender) SetSpool(store spool.Store) {
+	s.spool = store
+}
+
+// Channel возвращает имя обёрнутого канала.
+func (s *Sender) Channel() string {
+	return s.next.Channel()
+}
+
+// Unwrap возвращает обёрнутый канал.
+func (s *Sender) Unwrap() notify.Sender {
+	return s.next
+}
+
+// Send отправляет сообщение или, если канал деградировал, применяет к нему действие политики
+// для его приоритета. Отложенное сообщение не считается ошибкой: Send возвращает nil.
+// Сообщения PriorityHigh отправляются всегда.
+func (s *Sender) Send(ctx context.Context, msg notify.Message) error {
+	switch s.action(msg.Priority) {
+	case Shed:
+		return s.drop(msg)
+	case Defer:
+		s.mu.Lock()
+		if len(s.deferred) >= s.policy.MaxDeferred {
+			s.mu.Unlock()
+			return s.drop(msg)
+		}
+		s.deferred = append(s.deferred, msg)
+		s.mu.Unlock()
+		return nil
+	}
+	return s.send(ctx, msg)
+}
+
+// action возвращает действие для приоритета в текущем состоянии.
+func (s *Sender) action(p notify.Priority) Action {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.state != StateDegraded {
+		return Pass
+	}
+
+	switch p {
+	case notify.PriorityLow:
+		return s.policy.Low
+	case notify.PriorityHigh:
+		return Pass
+	default:
+		return s.policy.Normal
+	}
+}
+
+func (s *Sender) drop(msg notify.Message) error {
+	s.mu.Lock()
+	s.shed++
+	s.mu.Unlock()
+	s.logger.Debug("сообщение отброшено из-за деградации канала", "channel", s.next.Channel(), "to", msg.To, "priority", msg.Priority)
+	return ErrShed
+}
+
+// send отправляет сообщение через обёрнутый канал и учитывает задержку.
+func (s *Sender) send(ctx context.Context, msg notify.Message) error {
+	started := time.Now()
+	err := s.next.Send(ctx, msg)
+	if ctx.Err() == nil {
+		// Отменённая вызывающей стороной отправка ничего не говорит о скорости провайдера
+		s.observe(time.Since(started))
+	}
+	return err
+}
+
+// observe обновляет среднюю задержку и переключает состояние с гистерезисом.
+func (s *Sender) observe(d time.Duration) {
+	s.mu.Lock()
+	if s.measured {
+		s.latency = ewmaAlpha*float64(d) + (1-ewmaAlpha)*s.latency
+	} else {
+		s.latency, s.measured = float64(d), true
+	}
+	avg := time.Duration(s.latency)
+	threshold := s.policy.Threshold
+
+	var changed events.Type
+	switch {
+	case s.state == StateOK && avg > threshold:
+		s.state, s.since, changed = StateDegraded, time.Now(), events.Degraded
+	case s.state == StateDegraded && avg < s.policy.Recover:
+		s.state, s.since, changed = StateOK, time.Now(), events.Recovered
+	}
+	deferred := len(s.deferred)
+	s.mu.Unlock()
+
+	if changed == "" {
+		return
+	}
+	if changed == events.Degraded {
+		s.logger.Warn("канал замедлился, низкоприоритетные сообщения ограничены", "channel", s.next.Channel(), "latency", avg, "threshold", threshold)
+		alert.Alert(alert.Warning, fmt.Sprintf("Канал %s замедлился: средняя задержка %s выше порога %s, низкоприоритетные сообщения ограничены.",
+			s.next.Channel(), avg.Round(time.Millisecond), threshold))
+	} else {
+		s.logger.Info("канал восстановился", "channel", s.next.Channel(), "latency", avg, "deferred", deferred)
+		alert.Alert(alert.Info, fmt.Sprintf("Канал %s восстановился, отложено сообщений: %d.", s.next.Channel(), deferred))
+	}
+	s.bus.Publish(events.Event{
+		Type:    changed,
+		Channel: s.next.Channel(),
+		Data: map[string]string{
+			"latency":   avg.String(),
+			"threshold": threshold.String(),
+		},
+	})
+}
+
+// Health возвращает текущее состояние канала.
+func (s *Sender) Health() Health {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return Health{
+		Channel:  s.next.Channel(),
+		State:    s.state,
+		Since:    s.since,
+		Latency:  time.Duration(s.latency),
+		Deferred: len(s.deferred),
+		Shed:     s.shed,
+	}
+}
+
+// Drain отправляет отложенные сообщения, пока канал не в режиме деградации.
+//
+// В режиме деградации отправляется одно сообщение — как проба задержки: без неё канал,
+// через который идёт только низкоприоритетный трафик, не смог бы восстановиться.
+// Ошибки отправки объединяются; сообщения с ошибкой повторно не откладываются.
+func (s *Sender) Drain(ctx context.Context) error {
+	var errs []error
+	for ctx.Err() == nil {
+		s.mu.Lock()
+		if len(s.deferred) == 0 {
+			s.mu.Unlock()
+			break
+		}
+		msg := s.deferred[0]
+		s.deferred = s.deferred[1:]
+		probe := s.state == StateDegraded
+		s.mu.Unlock()
+
+		if err := s.send(ctx, msg); err != nil {
+			s.logger.Warn("не удалось отправить отложенное сообщение", "channel", s.next.Channel(), "to", msg.To, "error", err)
+			errs = append(errs, err)
+		}
+		if probe {
+			break
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Run периодически вызывает Drain до завершения ctx, после чего сохраняет
+// оставшиеся отложенные сообщения в хранилище из SetSpool.
+func (s *Sender) Run(ctx context.Context) {
+	s.mu.Lock()
+	retry := s.policy.Retry
+	s.mu.Unlock()
+
+	ticker := time.NewTicker(retry)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			s.spoolDeferred()
+			return
+		case <-ticker.C:
+			_ = s.Drain(ctx)
+		}
+	}
+}
+
+// spoolDeferred переносит отложенные сообщения в хранилище.
+func (s *Sender) spoolDeferred() {
+	if s.spool == nil {
+		return
+	}
+	s.mu.Lock()
+	left := s.deferred
+	s.deferred = nil
+	s.mu.Unlock()
+
+	if err := spool.Save(context.Background(), s.spool, s.next.Channel(), left); err != nil {
+		s.logger.Error("не удалось сохранить отложенные сообщения", "channel", s.next.Channel(), "count", len(left), "error", err)
+		s.mu.Lock()
+		s.deferred = append(left, s.deferred...)
+		s.mu.Unlock()
+	}
+}
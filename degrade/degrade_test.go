@@ -0,0 +1,108 @@
+package degrade_test
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/epheer/notephee/degrade"
+	"github.com/epheer/notephee/events"
+	"github.com/epheer/notephee/notify"
+	"github.com/epheer/notephee/spool"
+)
+
+type slowSender struct {
+	delay time.Duration
+	sent  []notify.Message
+}
+
+func (s *slowSender) Channel() string { return "fake" }
+
+func (s *slowSender) Send(_ context.Context, msg notify.Message) error {
+	time.Sleep(s.delay)
+	s.sent = append(s.sent, msg)
+	return nil
+}
+
+func TestDegradedDefersLowAndSheds(t *testing.T) {
+	ctx := context.Background()
+	next := &slowSender{delay: 20 * time.Millisecond}
+	s := degrade.Wrap(next, degrade.Policy{Threshold: 10 * time.Millisecond, Normal: degrade.Shed}, slog.Default())
+
+	bus := events.NewBus()
+	var got []events.Type
+	bus.Subscribe(func(e events.Event) { got = append(got, e.Type) })
+	s.SetEvents(bus)
+
+	if err := s.Send(ctx, notify.Message{To: "1", Text: "код 1234", Priority: notify.PriorityHigh}); err != nil {
+		t.Fatalf("Ошибка Send: %v", err)
+	}
+	if h := s.Health(); h.State != degrade.StateDegraded {
+		t.Fatalf("ожидалась деградация после медленной отправки, состояние %s", h.State)
+	}
+
+	if err := s.Send(ctx, notify.Message{To: "1", Text: "новости", Priority: notify.PriorityLow}); err != nil {
+		t.Fatalf("отложенное сообщение не должно давать ошибку: %v", err)
+	}
+	if err := s.Send(ctx, notify.Message{To: "1", Text: "счёт"}); !errors.Is(err, degrade.ErrShed) {
+		t.Fatalf("ожидалась ErrShed для normal по политике shed, получено: %v", err)
+	}
+	if err := s.Send(ctx, notify.Message{To: "1", Text: "код 5678", Priority: notify.PriorityHigh}); err != nil {
+		t.Fatalf("high должен отправляться всегда: %v", err)
+	}
+
+	h := s.Health()
+	if len(next.sent) != 2 || h.Deferred != 1 || h.Shed != 1 {
+		t.Fatalf("ожидалось 2 отправки, 1 отложенное и 1 отброшенное, получено %d, %d, %d", len(next.sent), h.Deferred, h.Shed)
+	}
+
+	// Провайдер ускорился: отложенные сообщения-пробы возвращают канал в норму
+	next.delay = 0
+	for range 20 {
+		_ = s.Send(ctx, notify.Message{To: "1", Text: "проба", Priority: notify.PriorityLow})
+		if err := s.Drain(ctx); err != nil {
+			t.Fatalf("Ошибка Drain: %v", err)
+		}
+	}
+
+	h = s.Health()
+	if h.State != degrade.StateOK || h.Deferred != 0 {
+		t.Fatalf("ожидалось восстановление без отложенных сообщений, получено %s и %d", h.State, h.Deferred)
+	}
+	if len(got) != 2 || got[0] != events.Degraded || got[1] != events.Recovered {
+		t.Fatalf("ожидались события degraded и recovered, получено %v", got)
+	}
+}
+
+func TestParseAction(t *testing.T) {
+	if _, err := degrade.ParseAction("drop"); err == nil {
+		t.Fatal("ожидалась ошибка для неизвестного действия")
+	}
+	if a, err := degrade.ParseAction("shed"); err != nil || a != degrade.Shed {
+		t.Fatalf("ожидалось shed, получено %q, %v", a, err)
+	}
+}
+
+func TestRunSpoolsDeferredOnStop(t *testing.T) {
+	next := &slowSender{delay: 20 * time.Millisecond}
+	s := degrade.Wrap(next, degrade.Policy{Threshold: 10 * time.Millisecond, Retry: time.Hour}, slog.Default())
+	store := spool.NewMemoryStore()
+	s.SetSpool(store)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	_ = s.Send(ctx, notify.Message{To: "1", Text: "код", Priority: notify.PriorityHigh})
+	_ = s.Send(ctx, notify.Message{To: "1", Text: "новости", Priority: notify.PriorityLow})
+
+	cancel()
+	s.Run(ctx)
+
+	entries, _ := store.Pending(context.Background())
+	if len(entries) != 1 || entries[0].Message.Text != "новости" || entries[0].Channel != "fake" {
+		t.Fatalf("отложенное сообщение должно сохраниться при остановке, получено %+v", entries)
+	}
+	if h := s.Health(); h.Deferred != 0 {
+		t.Fatalf("после сохранения отложенных сообщений не должно остаться, осталось %d", h.Deferred)
+	}
+}
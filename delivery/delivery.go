@@ -0,0 +1,151 @@
+package delivery
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Status описывает итог попытки отправки.
+type Status string
+
+// Возможные статусы попытки отправки
+const (
+	StatusSent   Status = "sent"   // Провайдер принял сообщение
+	StatusFailed Status = "failed" // Отправка завершилась ошибкой
+
+	// StatusIndeterminate — запрос мог дойти до провайдера, но ответ не получен
+	// (таймаут или обрыв соединения после отправки). Слепой повтор может дать дубль.
+	StatusIndeterminate Status = "indeterminate"
+)
+
+// Record описывает одну попытку отправки уведомления.
+type Record struct {
+	ID          string    // Уникальный идентификатор попытки
+	Channel     string    // Канал доставки (telegram, email)
+	UserID      string    // Внутренний идентификатор пользователя, если известен
+	Recipient   string    // Адрес получателя: chatID, email и т.д.
+	MessageHash string    // SHA-256 содержимого сообщения
+	Template    string    // Версия шаблона вида name@v3; пусто, если сообщение собрано без шаблона
+	Status      Status    // Итог попытки
+	Error       string    // Текст ошибки (если была)
+	CreatedAt   time.Time // Время начала попытки
+	CompletedAt time.Time // Время получения ответа от провайдера
+}
+
+// DeliveryLog хранит историю всех попыток отправки.
+//
+// Реализации должны быть безопасны для конкурентного использования.
+type DeliveryLog interface {
+	// Save сохраняет запись о попытке отправки.
+	Save(ctx context.Context, rec Record) error
+	// Get возвращает запись по идентификатору или ErrNotFound.
+	Get(ctx context.Context, id string) (Record, error)
+	// History возвращает все попытки отправки пользователю userID в порядке создания.
+	History(ctx context.Context, userID string) ([]Record, error)
+	// FailedSince возвращает неудачные попытки, начатые не раньше t.
+	FailedSince(ctx context.Context, t time.Time) ([]Record, error)
+}
+
+// ErrNotFound возвращается, если запись не найдена.
+var ErrNotFound = errors.New("запись о доставке не найдена")
+
+// Hash возвращает hex-представление SHA-256 от частей сообщения.
+//
+// Части разделяются нулевым байтом, чтобы ("ab", "c") и ("a", "bc") давали разные хэши.
+func Hash(parts ...string) string {
+	h := sha256.New()
+	for i, p := range parts {
+		if i > 0 {
+			h.Write([]byte{0})
+		}
+		h.Write([]byte(p))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// ErrIndeterminate помечает ошибку, после которой исход отправки неизвестен.
+var ErrIndeterminate = errors.New("исход отправки неизвестен")
+
+// IsIndeterminate сообщает, мог ли провайдер принять сообщение, несмотря на ошибку:
+// таймаут или обрыв соединения после установки. Ошибка установки соединения
+// означает, что запрос не ушёл, и исход считается известным.
+func IsIndeterminate(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, ErrIndeterminate) {
+		return true
+	}
+
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return false
+	}
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// StatusOf возвращает статус попытки по ошибке отправки.
+func StatusOf(err error) Status {
+	switch {
+	case err == nil:
+		return StatusSent
+	case IsIndeterminate(err):
+		return StatusIndeterminate
+	default:
+		return StatusFailed
+	}
+}
+
+type templateKey struct{}
+
+// WithTemplate помечает ctx версией шаблона ref (name@v3), из которой собрано отправляемое сообщение.
+// Клиенты каналов вызывают его в Send с notify.Message.Template, а LogAttempt переносит версию в запись.
+func WithTemplate(ctx context.Context, ref string) context.Context {
+	if ref == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, templateKey{}, ref)
+}
+
+// TemplateOf возвращает версию шаблона из ctx, заданную WithTemplate; пусто — сообщение без шаблона.
+func TemplateOf(ctx context.Context) string {
+	ref, _ := ctx.Value(templateKey{}).(string)
+	return ref
+}
+
+// LogAttempt дополняет запись rec итогом отправки sendErr и сохраняет её в журнал log, если он подключён.
+// Клиенты каналов заполняют в rec канал, получателя, хэш сообщения и CreatedAt — время начала попытки;
+// пустой ID генерируется, пустая версия шаблона берётся из ctx (WithTemplate). Ошибка журнала только
+// записывается в лог: сообщение уже отправлено или нет.
+func LogAttempt(ctx context.Context, log DeliveryLog, logger *slog.Logger, rec Record, sendErr error) {
+	if log == nil {
+		return
+	}
+	if rec.ID == "" {
+		rec.ID = uuid.New().String()
+	}
+	if rec.Template == "" {
+		rec.Template = TemplateOf(ctx)
+	}
+	rec.Status = StatusOf(sendErr)
+	if sendErr != nil {
+		rec.Error = sendErr.Error()
+	}
+	rec.CompletedAt = time.Now()
+
+	if err := log.Save(context.WithoutCancel(ctx), rec); err != nil {
+		logger.Error("не удалось записать попытку доставки", "channel", rec.Channel, "recipient", rec.Recipient, "error", err)
+	}
+}
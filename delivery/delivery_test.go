@@ -0,0 +1,98 @@
+package delivery_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/epheer/notephee/delivery"
+	"github.com/epheer/notephee/delivery/deliverytest"
+)
+
+func TestMemoryLog(t *testing.T) {
+	ctx := context.Background()
+	log := delivery.NewMemoryLog()
+	now := time.Now()
+
+	records := []delivery.Record{
+		{ID: "1", Channel: "telegram", UserID: "u1", Status: delivery.StatusSent, CreatedAt: now.Add(-2 * time.Hour)},
+		{ID: "2", Channel: "email", UserID: "u1", Status: delivery.StatusFailed, CreatedAt: now.Add(-time.Hour)},
+		{ID: "3", Channel: "email", UserID: "u2", Status: delivery.StatusFailed, CreatedAt: now},
+	}
+	for _, r := range records {
+		if err := log.Save(ctx, r); err != nil {
+			t.Fatalf("Ошибка Save: %v", err)
+		}
+	}
+
+	history, _ := log.History(ctx, "u1")
+	if len(history) != 2 || history[0].ID != "1" || history[1].ID != "2" {
+		t.Fatalf("неверная история u1: %+v", history)
+	}
+
+	failed, _ := log.FailedSince(ctx, now.Add(-30*time.Minute))
+	if len(failed) != 1 || failed[0].ID != "3" {
+		t.Fatalf("неверный список неудачных попыток: %+v", failed)
+	}
+}
+
+func TestMemoryLogConformance(t *testing.T) {
+	deliverytest.Run(t, func(*testing.T) delivery.DeliveryLog {
+		return delivery.NewMemoryLog()
+	})
+}
+
+func TestHashSeparatesParts(t *testing.T) {
+	if delivery.Hash("ab", "c") == delivery.Hash("a", "bc") {
+		t.Fatal("хэши разных сообщений совпали")
+	}
+}
+
+func TestStatusOf(t *testing.T) {
+	dial := &url.Error{Op: "Post", URL: "https://api.example.com", Err: &net.OpError{Op: "dial", Err: context.DeadlineExceeded}}
+	cases := []struct {
+		err  error
+		want delivery.Status
+	}{
+		{nil, delivery.StatusSent},
+		{errors.New("ошибка API: код 400"), delivery.StatusFailed},
+		{dial, delivery.StatusFailed},
+		{&url.Error{Op: "Post", URL: "https://api.example.com", Err: context.DeadlineExceeded}, delivery.StatusIndeterminate},
+		{fmt.Errorf("некорректный формат JSON: %w", io.ErrUnexpectedEOF), delivery.StatusIndeterminate},
+	}
+	for _, c := range cases {
+		if got := delivery.StatusOf(c.err); got != c.want {
+			t.Errorf("StatusOf(%v) = %s, ожидалось %s", c.err, got, c.want)
+		}
+	}
+}
+
+func TestLogAttempt(t *testing.T) {
+	ctx := context.Background()
+	log := delivery.NewMemoryLog()
+	started := time.Now()
+
+	delivery.LogAttempt(ctx, nil, slog.Default(), delivery.Record{Channel: "slack"}, nil)
+	delivery.LogAttempt(ctx, log, slog.Default(), delivery.Record{Channel: "slack", UserID: "u1", Recipient: "#ops", CreatedAt: started}, nil)
+	delivery.LogAttempt(delivery.WithTemplate(ctx, "welcome@v2"), log, slog.Default(),
+		delivery.Record{ID: "2", Channel: "vk", UserID: "u1", CreatedAt: started}, errors.New("отказ"))
+
+	history, err := log.History(ctx, "u1")
+	if err != nil || len(history) != 2 {
+		t.Fatalf("ожидались две записи: %+v %v", history, err)
+	}
+	for _, rec := range history {
+		if rec.ID == "" || rec.CompletedAt.Before(started) {
+			t.Fatalf("ID и время завершения должны заполняться: %+v", rec)
+		}
+	}
+	if rec, _ := log.Get(ctx, "2"); rec.Status != delivery.StatusFailed || rec.Error != "отказ" || rec.Template != "welcome@v2" {
+		t.Fatalf("неверная запись неудачной попытки: %+v", rec)
+	}
+}
@@ -0,0 +1,72 @@
+// Package deliverytest проверяет реализации delivery.DeliveryLog одним набором тестов, чтобы журнал
+// в памяти и журналы поверх баз данных вели себя одинаково.
+package deliverytest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/epheer/notephee/delivery"
+)
+
+// Run проверяет журнал, который возвращает newLog. Каждый вызов newLog должен возвращать пустой журнал.
+func Run(t *testing.T, newLog func(t *testing.T) delivery.DeliveryLog) {
+	t.Run("SaveAndQuery", func(t *testing.T) {
+		ctx := context.Background()
+		log := newLog(t)
+		now := time.Now().UTC().Truncate(time.Second)
+
+		records := []delivery.Record{
+			{ID: "00000000-0000-0000-0000-000000000001", Channel: "telegram", UserID: "u1", Status: delivery.StatusSent, CreatedAt: now.Add(-2 * time.Hour)},
+			{ID: "00000000-0000-0000-0000-000000000002", Channel: "email", UserID: "u1", Status: delivery.StatusFailed, Error: "отказ", CreatedAt: now.Add(-time.Hour)},
+			{ID: "00000000-0000-0000-0000-000000000003", Channel: "email", UserID: "u2", Status: delivery.StatusFailed, Template: "welcome@v2", CreatedAt: now},
+		}
+		for _, r := range records {
+			r.CompletedAt = r.CreatedAt
+			if err := log.Save(ctx, r); err != nil {
+				t.Fatalf("Ошибка Save: %v", err)
+			}
+		}
+
+		history, err := log.History(ctx, "u1")
+		if err != nil || len(history) != 2 || history[0].ID != records[0].ID || history[1].ID != records[1].ID {
+			t.Fatalf("неверная история u1: %+v %v", history, err)
+		}
+		failed, err := log.FailedSince(ctx, now.Add(-30*time.Minute))
+		if err != nil || len(failed) != 1 || failed[0].ID != records[2].ID || failed[0].Template != "welcome@v2" {
+			t.Fatalf("неверный список неудачных попыток: %+v %v", failed, err)
+		}
+		if _, err := log.Get(ctx, "00000000-0000-0000-0000-000000000009"); !errors.Is(err, delivery.ErrNotFound) {
+			t.Fatalf("ожидалась ErrNotFound, получено %v", err)
+		}
+	})
+
+	t.Run("SaveSameID", func(t *testing.T) {
+		ctx := context.Background()
+		log := newLog(t)
+		now := time.Now().UTC().Truncate(time.Second)
+
+		rec := delivery.Record{ID: "00000000-0000-0000-0000-000000000001", Channel: "email", UserID: "u1",
+			Status: delivery.StatusFailed, Error: "таймаут", CreatedAt: now, CompletedAt: now}
+		if err := log.Save(ctx, rec); err != nil {
+			t.Fatalf("Ошибка Save: %v", err)
+		}
+		// Повторная попытка с тем же ID обновляет итог, а не добавляет вторую запись
+		rec.Status, rec.Error, rec.CompletedAt = delivery.StatusSent, "", now.Add(time.Minute)
+		if err := log.Save(ctx, rec); err != nil {
+			t.Fatalf("повторная попытка не сохранена: %v", err)
+		}
+
+		if got, err := log.Get(ctx, rec.ID); err != nil || got.Status != delivery.StatusSent || got.Error != "" {
+			t.Fatalf("запись не обновлена: %+v %v", got, err)
+		}
+		if history, err := log.History(ctx, "u1"); err != nil || len(history) != 1 {
+			t.Fatalf("ожидалась одна запись в истории: %+v %v", history, err)
+		}
+		if failed, err := log.FailedSince(ctx, now.Add(-time.Minute)); err != nil || len(failed) != 0 {
+			t.Fatalf("исправленная попытка не должна считаться неудачной: %+v %v", failed, err)
+		}
+	})
+}
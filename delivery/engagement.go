@@ -0,0 +1,54 @@
+package delivery
+
+import (
+	"context"
+	"time"
+)
+
+// Action — действие получателя с доставленным сообщением.
+type Action string
+
+// Отслеживаемые действия получателя
+const (
+	ActionOpen  Action = "open"  // Письмо открыто: загружен пиксель отслеживания
+	ActionClick Action = "click" // Переход по ссылке из письма
+)
+
+// Engagement — одно действие получателя с сообщением, отправленным попыткой ID.
+type Engagement struct {
+	ID     string    // Идентификатор попытки в журнале доставки
+	Action Action    // Открытие или переход по ссылке
+	URL    string    // Ссылка, по которой перешёл получатель (для ActionClick)
+	Time   time.Time // Время действия
+}
+
+// EngagementLog — журнал доставки, который хранит открытия писем и переходы по ссылкам.
+// Реализуется журналами отдельно от DeliveryLog, потому что отслеживание необязательно.
+type EngagementLog interface {
+	// SaveEngagement сохраняет действие получателя.
+	SaveEngagement(ctx context.Context, e Engagement) error
+	// Engagements возвращает действия с сообщением попытки id в порядке времени.
+	Engagements(ctx context.Context, id string) ([]Engagement, error)
+}
+
+// SaveEngagement сохраняет действие получателя.
+func (l *MemoryLog) SaveEngagement(_ context.Context, e Engagement) error {
+	l.mu.Lock()
+	l.engagements = append(l.engagements, e)
+	l.mu.Unlock()
+	return nil
+}
+
+// Engagements возвращает действия с сообщением попытки id.
+func (l *MemoryLog) Engagements(_ context.Context, id string) ([]Engagement, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	var out []Engagement
+	for _, e := range l.engagements {
+		if e.ID == id {
+			out = append(out, e)
+		}
+	}
+	return out, nil
+}
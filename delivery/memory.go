@@ -0,0 +1,75 @@
+package delivery
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryLog — реализация DeliveryLog в памяти процесса.
+//
+// Подходит для тестов и небольших инсталляций; история теряется при перезапуске.
+type MemoryLog struct {
+	mu          sync.RWMutex
+	records     []Record
+	engagements []Engagement
+}
+
+// NewMemoryLog создаёт пустой журнал доставки в памяти.
+func NewMemoryLog() *MemoryLog {
+	return &MemoryLog{}
+}
+
+// Save сохраняет запись о попытке отправки. Повторное сохранение с тем же ID (например, переотправка
+// после неизвестного исхода) обновляет итог существующей записи, как SQLLog.
+func (l *MemoryLog) Save(_ context.Context, rec Record) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for i := range l.records {
+		if cur := &l.records[i]; cur.ID == rec.ID {
+			cur.Status, cur.Error, cur.CompletedAt = rec.Status, rec.Error, rec.CompletedAt
+			return nil
+		}
+	}
+	l.records = append(l.records, rec)
+	return nil
+}
+
+// Get возвращает запись по идентификатору.
+func (l *MemoryLog) Get(_ context.Context, id string) (Record, error) {
+	found := l.filter(func(r Record) bool {
+		return r.ID == id
+	})
+	if len(found) == 0 {
+		return Record{}, ErrNotFound
+	}
+	return found[0], nil
+}
+
+// History возвращает все попытки отправки пользователю userID.
+func (l *MemoryLog) History(_ context.Context, userID string) ([]Record, error) {
+	return l.filter(func(r Record) bool {
+		return r.UserID == userID
+	}), nil
+}
+
+// FailedSince возвращает неудачные попытки, начатые не раньше t.
+func (l *MemoryLog) FailedSince(_ context.Context, t time.Time) ([]Record, error) {
+	return l.filter(func(r Record) bool {
+		return r.Status == StatusFailed && !r.CreatedAt.Before(t)
+	}), nil
+}
+
+func (l *MemoryLog) filter(match func(Record) bool) []Record {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	var out []Record
+	for _, r := range l.records {
+		if match(r) {
+			out = append(out, r)
+		}
+	}
+	return out
+}
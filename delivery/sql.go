@@ -0,0 +1,224 @@
+package delivery
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Placeholder возвращает плейсхолдер параметра запроса с номером n (начиная с 1).
+type Placeholder func(n int) string
+
+// QuestionPlaceholder формирует плейсхолдеры вида ? (MySQL, SQLite).
+func QuestionPlaceholder(int) string { return "?" }
+
+// DollarPlaceholder формирует плейсхолдеры вида $1 (PostgreSQL).
+func DollarPlaceholder(n int) string { return "$" + strconv.Itoa(n) }
+
+// SQLLog — реализация DeliveryLog поверх database/sql.
+//
+// Драйвер базы данных подключается вызывающей стороной.
+type SQLLog struct {
+	db          *sql.DB     // Подключение к базе данных
+	table       string      // Имя таблицы с историей
+	placeholder Placeholder // Формат плейсхолдеров драйвера
+}
+
+const recordColumns = "id, channel, user_id, recipient, message_hash, template, status, error, created_at, completed_at"
+
+// NewSQLLog создаёт журнал доставки в таблице table.
+//
+// Если placeholder == nil, используется QuestionPlaceholder.
+func NewSQLLog(db *sql.DB, table string, placeholder Placeholder) *SQLLog {
+	if placeholder == nil {
+		placeholder = QuestionPlaceholder
+	}
+	return &SQLLog{db: db, table: table, placeholder: placeholder}
+}
+
+// Migrate создаёт таблицу истории и таблицу открытий и переходов (см. EngagementLog), если их ещё нет.
+func (l *SQLLog) Migrate(ctx context.Context) error {
+	query := fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	id VARCHAR(36) PRIMARY KEY,
+	channel VARCHAR(32) NOT NULL,
+	user_id VARCHAR(255) NOT NULL,
+	recipient VARCHAR(320) NOT NULL,
+	message_hash CHAR(64) NOT NULL,
+	template VARCHAR(255) NOT NULL DEFAULT '',
+	status VARCHAR(16) NOT NULL,
+	error TEXT NOT NULL,
+	created_at TIMESTAMP NOT NULL,
+	completed_at TIMESTAMP NOT NULL
+)`, l.table)
+	if _, err := l.db.ExecContext(ctx, query); err != nil {
+		return fmt.Errorf("не удалось создать таблицу %s: %w", l.table, err)
+	}
+	// В таблицах, созданных до появления версий шаблонов, колонки template нет
+	if _, err := l.db.ExecContext(ctx, fmt.Sprintf("SELECT template FROM %s WHERE 1 = 0", l.table)); err != nil {
+		query = fmt.Sprintf("ALTER TABLE %s ADD COLUMN template VARCHAR(255) NOT NULL DEFAULT ''", l.table)
+		if _, err := l.db.ExecContext(ctx, query); err != nil {
+			return fmt.Errorf("не удалось добавить колонку template в %s: %w", l.table, err)
+		}
+	}
+
+	query = fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	delivery_id VARCHAR(36) NOT NULL,
+	action VARCHAR(16) NOT NULL,
+	url TEXT NOT NULL,
+	created_at TIMESTAMP NOT NULL
+)`, l.engagementTable())
+	if _, err := l.db.ExecContext(ctx, query); err != nil {
+		return fmt.Errorf("не удалось создать таблицу %s: %w", l.engagementTable(), err)
+	}
+	return nil
+}
+
+// engagementTable возвращает имя таблицы открытий и переходов: имя таблицы истории с суффиксом _engagement.
+func (l *SQLLog) engagementTable() string {
+	return l.table + "_engagement"
+}
+
+// SaveEngagement сохраняет действие получателя.
+func (l *SQLLog) SaveEngagement(ctx context.Context, e Engagement) error {
+	query := fmt.Sprintf("INSERT INTO %s (delivery_id, action, url, created_at) VALUES (%s)",
+		l.engagementTable(), l.placeholders(4))
+	if _, err := l.db.ExecContext(ctx, query, e.ID, string(e.Action), e.URL, e.Time.UTC()); err != nil {
+		return fmt.Errorf("не удалось сохранить действие получателя: %w", err)
+	}
+	return nil
+}
+
+// Engagements возвращает действия с сообщением попытки id в порядке времени.
+func (l *SQLLog) Engagements(ctx context.Context, id string) ([]Engagement, error) {
+	query := fmt.Sprintf("SELECT delivery_id, action, url, created_at FROM %s WHERE delivery_id = %s ORDER BY created_at",
+		l.engagementTable(), l.placeholder(1))
+	rows, err := l.db.QueryContext(ctx, query, id)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка запроса действий получателя: %w", err)
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	var out []Engagement
+	for rows.Next() {
+		var (
+			e      Engagement
+			action string
+		)
+		if err := rows.Scan(&e.ID, &action, &e.URL, &e.Time); err != nil {
+			return nil, fmt.Errorf("ошибка чтения действий получателя: %w", err)
+		}
+		e.Action = Action(action)
+		out = append(out, e)
+	}
+	return out, rows.Err()
+}
+
+// Save сохраняет запись о попытке отправки.
+func (l *SQLLog) Save(ctx context.Context, rec Record) error {
+	query := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", l.table, recordColumns, l.placeholders(10))
+	_, err := l.db.ExecContext(ctx, query,
+		rec.ID, rec.Channel, rec.UserID, rec.Recipient, rec.MessageHash, rec.Template,
+		string(rec.Status), rec.Error, rec.CreatedAt.UTC(), rec.CompletedAt.UTC(),
+	)
+	if err != nil {
+		// Повторная попытка с тем же ID (например, переотправка после неизвестного исхода)
+		// обновляет запись вместо второй вставки. Остальные ошибки вставки возвращаются как есть
+		if isUniqueViolation(err) {
+			if updated, updErr := l.update(ctx, rec); updErr == nil && updated {
+				return nil
+			}
+		}
+		return fmt.Errorf("не удалось сохранить запись %s: %w", rec.ID, err)
+	}
+	return nil
+}
+
+// isUniqueViolation сообщает, что вставка отклонена из-за записи с тем же ключом. Драйверы
+// PostgreSQL сообщают SQLSTATE 23505; у остальных проверяется текст ошибки SQLite и MySQL.
+func isUniqueViolation(err error) bool {
+	var state interface{ SQLState() string }
+	if errors.As(err, &state) {
+		return state.SQLState() == "23505"
+	}
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "unique constraint") || strings.Contains(msg, "duplicate")
+}
+
+// update обновляет итог существующей попытки. Возвращает false, если записи с таким ID нет.
+func (l *SQLLog) update(ctx context.Context, rec Record) (bool, error) {
+	query := fmt.Sprintf("UPDATE %s SET status = %s, error = %s, completed_at = %s WHERE id = %s",
+		l.table, l.placeholder(1), l.placeholder(2), l.placeholder(3), l.placeholder(4))
+	res, err := l.db.ExecContext(ctx, query, string(rec.Status), rec.Error, rec.CompletedAt.UTC(), rec.ID)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// Get возвращает запись по идентификатору.
+func (l *SQLLog) Get(ctx context.Context, id string) (Record, error) {
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE id = %s", recordColumns, l.table, l.placeholder(1))
+	records, err := l.query(ctx, query, id)
+	if err != nil {
+		return Record{}, err
+	}
+	if len(records) == 0 {
+		return Record{}, ErrNotFound
+	}
+	return records[0], nil
+}
+
+// History возвращает все попытки отправки пользователю userID.
+func (l *SQLLog) History(ctx context.Context, userID string) ([]Record, error) {
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE user_id = %s ORDER BY created_at",
+		recordColumns, l.table, l.placeholder(1))
+	return l.query(ctx, query, userID)
+}
+
+// FailedSince возвращает неудачные попытки, начатые не раньше t.
+func (l *SQLLog) FailedSince(ctx context.Context, t time.Time) ([]Record, error) {
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE status = %s AND created_at >= %s ORDER BY created_at",
+		recordColumns, l.table, l.placeholder(1), l.placeholder(2))
+	return l.query(ctx, query, string(StatusFailed), t.UTC())
+}
+
+func (l *SQLLog) placeholders(n int) string {
+	ph := make([]string, n)
+	for i := range ph {
+		ph[i] = l.placeholder(i + 1)
+	}
+	return strings.Join(ph, ", ")
+}
+
+func (l *SQLLog) query(ctx context.Context, query string, args ...any) ([]Record, error) {
+	rows, err := l.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка запроса истории доставки: %w", err)
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	var out []Record
+	for rows.Next() {
+		var (
+			rec    Record
+			status string
+		)
+		err := rows.Scan(&rec.ID, &rec.Channel, &rec.UserID, &rec.Recipient, &rec.MessageHash, &rec.Template,
+			&status, &rec.Error, &rec.CreatedAt, &rec.CompletedAt)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка чтения истории доставки: %w", err)
+		}
+		rec.Status = Status(status)
+		out = append(out, rec)
+	}
+	return out, rows.Err()
+}
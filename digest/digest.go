@@ -0,0 +1,279 @@
+// Package digest объединяет низкоприоритетные уведомления в периодические сводки.
+//
+// Вместо десятков мелких сообщений в день получатель получает одно сообщение (в Telegram
+// или письмом) раз в интервал, например раз в час.
+package digest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/epheer/notephee/notify"
+	"github.com/epheer/notephee/spool"
+)
+
+// DefaultTemplate — шаблон текста сводки по умолчанию.
+var DefaultTemplate = template.Must(template.New("digest").Parse(
+	`Новых уведомлений: {{len .Messages}}
+{{range .Messages}}
+• {{if .Subject}}{{.Subject}}: {{end}}{{.Text}}{{end}}
+`))
+
+// Digest — данные, с которыми исполняется шаблон сводки.
+type Digest struct {
+	To       string           // Адрес получателя в канале
+	UserID   string           // Внутренний идентификатор пользователя (по первому сообщению)
+	Since    time.Time        // Время поступления первого сообщения сводки
+	Messages []notify.Message // Накопленные сообщения в порядке поступления
+}
+
+// pending — накопленные, но ещё не отправленные сообщения одного получателя.
+type pending struct {
+	since    time.Time
+	due      time.Time // Время отправки по местному времени получателя; нулевое — при ближайшем Flush
+	messages []notify.Message
+}
+
+// Sender — обёртка над notify.Sender, откладывающая сообщения с приоритетом notify.PriorityLow
+// и отправляющая их одной сводкой на получателя при вызове Flush или по таймеру Run.
+// Сообщения остальных приоритетов отправляются сразу.
+type Sender struct {
+	next     notify.Sender      // Обёрнутый канал
+	interval time.Duration      // Интервал отправки сводок
+	tmpl     *template.Template // Шаблон текста сводки
+	subject  string             // Тема сводки (для каналов, где она есть)
+	logger   *slog.Logger       // Логгер
+	spool    spool.Store        // Хранилище сводок, не отправленных при остановке (необязательно)
+
+	deliverAt  *notify.LocalTime     // Местное время доставки сводок (необязательно)
+	recipients notify.RecipientStore // Каталог получателей с часовыми поясами
+
+	mu      sync.Mutex
+	pending map[string]*pending // Получатель → накопленные сообщения
+}
+
+// Wrap оборачивает канал next сводками с интервалом interval.
+//
+// tmpl — шаблон текста сводки, исполняемый с Digest; nil — DefaultTemplate.
+func Wrap(next notify.Sender, interval time.Duration, tmpl *template.Template, logger *slog.Logger) *Sender {
+	if tmpl == nil {
+		tmpl = DefaultTemplate
+	}
+	return &Sender{
+		next:     next,
+		interval: interval,
+		tmpl:     tmpl,
+		subject:  "Сводка уведомлений",
+		logger:   logger,
+		pending:  make(map[string]*pending),
+	}
+}
+
+// SetSubject задаёт тему писем-сводок.
+func (s *Sender) SetSubject(subject string) {
+	s.subject = subject
+}
+
+// SetDeliveryTime включает доставку сводок в местное время получателя, например в 09:00:
+// Run отправляет сводку на первом тике после наступления at в часовом поясе получателя, поэтому
+// интервал Wrap задаёт точность, например 5 минут.
+// Пояс берётся из каталога recipients по UserID первого сообщения сводки; без записи в каталоге — UTC.
+// Flush по-прежнему отправляет все сводки сразу. Вызывается до Send.
+func (s *Sender) SetDeliveryTime(at notify.LocalTime, recipients notify.RecipientStore) {
+	s.deliverAt = &at
+	s.recipients = recipients
+}
+
+// SetSpool подключает хранилище, в которое Run при остановке сохраняет сообщения сводок,
+// которые не удалось отправить. После перезапуска spool.Replay отправляет их через этот же канал,
+// и они снова попадают в сводку.
+func (s *Sender) SetSpool(store spool.Store) {
+	s.spool = store
+}
+
+// Channel возвращает имя обёрнутого канала.
+func (s *Sender) Channel() string {
+	return s.next.Channel()
+}
+
+// Unwrap возвращает обёрнутый канал.
+func (s *Sender) Unwrap() notify.Sender {
+	return s.next
+}
+
+// Send откладывает сообщение до следующей сводки, если его приоритет notify.PriorityLow,
+// иначе сразу передаёт его обёрнутому каналу.
+//
+// Отложенное сообщение не считается ошибкой: Send возвращает nil.
+func (s *Sender) Send(ctx context.Context, msg notify.Message) error {
+	if msg.Priority != notify.PriorityLow {
+		return s.next.Send(ctx, msg)
+	}
+
+	now := time.Now()
+	var due time.Time
+	if s.deliverAt != nil {
+		// Каталог читается до блокировки, чтобы медленное хранилище не задерживало другие отправки
+		due = s.deliverAt.Next(now, s.location(ctx, msg.UserID))
+	}
+
+	s.mu.Lock()
+	p, ok := s.pending[msg.To]
+	if !ok {
+		p = &pending{since: now, due: due}
+		s.pending[msg.To] = p
+	}
+	p.messages = append(p.messages, msg)
+	s.mu.Unlock()
+	return nil
+}
+
+// Pending возвращает число сообщений, ожидающих отправки в сводке.
+func (s *Sender) Pending() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := 0
+	for _, p := range s.pending {
+		n += len(p.messages)
+	}
+	return n
+}
+
+// Flush немедленно отправляет накопленные сводки всем получателям.
+//
+// Сводки, которые не удалось отправить, возвращаются в очередь и уйдут при следующем Flush.
+// Возвращает объединённые ошибки отправки.
+func (s *Sender) Flush(ctx context.Context) error {
+	return s.flush(ctx, func(*pending) bool { return true })
+}
+
+// FlushDue отправляет сводки, время доставки которых по местному времени получателя наступило к now
+// (см. SetDeliveryTime). Без SetDeliveryTime отправляет все сводки, как Flush.
+func (s *Sender) FlushDue(ctx context.Context, now time.Time) error {
+	return s.flush(ctx, func(p *pending) bool { return !now.Before(p.due) })
+}
+
+// flush отправляет сводки получателей, для которых due возвращает true.
+func (s *Sender) flush(ctx context.Context, due func(*pending) bool) error {
+	s.mu.Lock()
+	batch := make(map[string]*pending)
+	for to, p := range s.pending {
+		if due(p) {
+			batch[to] = p
+			delete(s.pending, to)
+		}
+	}
+	s.mu.Unlock()
+
+	var errs []error
+	for to, p := range batch {
+		msg, err := s.render(to, p)
+		if err != nil {
+			// Шаблон не исполнился — повтор не поможет, сообщения отбрасываются
+			s.logger.Error("не удалось сформировать сводку", "to", to, "error", err)
+			errs = append(errs, err)
+			continue
+		}
+		if err := s.next.Send(ctx, msg); err != nil {
+			s.logger.Warn("не удалось отправить сводку, повтор при следующей отправке", "to", to, "error", err)
+			s.requeue(to, p)
+			errs = append(errs, fmt.Errorf("%s: %w", to, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// location возвращает часовой пояс пользователя userID из каталога получателей или UTC.
+func (s *Sender) location(ctx context.Context, userID string) *time.Location {
+	if s.recipients == nil || userID == "" {
+		return time.UTC
+	}
+	r, err := s.recipients.Get(ctx, userID)
+	if err != nil {
+		s.logger.Debug("часовой пояс получателя сводки неизвестен, используется UTC", "user_id", userID, "error", err)
+		return time.UTC
+	}
+	return r.Location()
+}
+
+// Run отправляет сводки каждые interval до завершения ctx, после чего отправляет оставшиеся.
+// Сводки, которые не удалось отправить при остановке, сохраняются в хранилище из SetSpool.
+func (s *Sender) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			if err := s.Flush(context.WithoutCancel(ctx)); err != nil {
+				s.spoolPending()
+			}
+			return
+		case now := <-ticker.C:
+			_ = s.FlushDue(ctx, now)
+		}
+	}
+}
+
+// spoolPending переносит накопленные сообщения в хранилище. Без хранилища они теряются.
+func (s *Sender) spoolPending() {
+	s.mu.Lock()
+	var left []notify.Message
+	for _, p := range s.pending {
+		left = append(left, p.messages...)
+	}
+	s.pending = make(map[string]*pending)
+	s.mu.Unlock()
+
+	if len(left) == 0 {
+		return
+	}
+	if s.spool == nil {
+		s.logger.Error("сводки не отправлены при остановке и потеряны", "channel", s.next.Channel(), "count", len(left))
+		return
+	}
+	if err := spool.Save(context.Background(), s.spool, s.next.Channel(), left); err != nil {
+		s.logger.Error("не удалось сохранить неотправленные сводки", "channel", s.next.Channel(), "count", len(left), "error", err)
+		return
+	}
+	s.logger.Info("неотправленные сводки сохранены", "channel", s.next.Channel(), "count", len(left))
+}
+
+// render собирает одно сообщение-сводку из накопленных сообщений получателя.
+func (s *Sender) render(to string, p *pending) (notify.Message, error) {
+	d := Digest{To: to, Since: p.since, Messages: p.messages}
+	if len(p.messages) > 0 {
+		d.UserID = p.messages[0].UserID
+	}
+
+	var buf bytes.Buffer
+	if err := s.tmpl.Execute(&buf, d); err != nil {
+		return notify.Message{}, fmt.Errorf("шаблон сводки: %w", err)
+	}
+
+	return notify.Message{
+		To:       to,
+		UserID:   d.UserID,
+		Subject:  s.subject,
+		Text:     buf.String(),
+		Priority: notify.PriorityNormal,
+	}, nil
+}
+
+// requeue возвращает неотправленные сообщения в начало очереди получателя.
+func (s *Sender) requeue(to string, p *pending) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if cur, ok := s.pending[to]; ok {
+		p.messages = append(p.messages, cur.messages...)
+	}
+	s.pending[to] = p
+}
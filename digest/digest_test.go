@@ -0,0 +1,136 @@
+package digest_test
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+	"text/template"
+	"time"
+
+	"github.com/epheer/notephee/digest"
+	"github.com/epheer/notephee/notify"
+	"github.com/epheer/notephee/spool"
+)
+
+type recordingSender struct {
+	sent []notify.Message
+	fail bool
+}
+
+func (s *recordingSender) Channel() string { return "fake" }
+
+func (s *recordingSender) Send(_ context.Context, msg notify.Message) error {
+	if s.fail {
+		return errors.New("канал недоступен")
+	}
+	s.sent = append(s.sent, msg)
+	return nil
+}
+
+func TestLowPriorityIsCombined(t *testing.T) {
+	ctx := context.Background()
+	next := &recordingSender{}
+	s := digest.Wrap(next, time.Hour, nil, slog.Default())
+
+	for _, text := range []string{"лайк", "комментарий", "подписка"} {
+		_ = s.Send(ctx, notify.Message{To: "1", Text: text, Priority: notify.PriorityLow})
+	}
+	_ = s.Send(ctx, notify.Message{To: "2", Text: "лайк", Priority: notify.PriorityLow})
+	_ = s.Send(ctx, notify.Message{To: "1", Text: "вход с нового устройства", Priority: notify.PriorityHigh})
+
+	if len(next.sent) != 1 || s.Pending() != 4 {
+		t.Fatalf("ожидалась 1 немедленная отправка и 4 отложенных, получено %d и %d", len(next.sent), s.Pending())
+	}
+
+	if err := s.Flush(ctx); err != nil {
+		t.Fatalf("Ошибка Flush: %v", err)
+	}
+	if len(next.sent) != 3 || s.Pending() != 0 {
+		t.Fatalf("ожидалось по одной сводке на получателя, отправлено %d", len(next.sent))
+	}
+	for _, msg := range next.sent[1:] {
+		if msg.To == "1" && !strings.Contains(msg.Text, "Новых уведомлений: 3") {
+			t.Fatalf("неожиданный текст сводки: %q", msg.Text)
+		}
+	}
+}
+
+func TestFailedFlushIsRetried(t *testing.T) {
+	ctx := context.Background()
+	next := &recordingSender{fail: true}
+	tmpl := template.Must(template.New("t").Parse(`{{range .Messages}}{{.Text}};{{end}}`))
+	s := digest.Wrap(next, time.Hour, tmpl, slog.Default())
+
+	_ = s.Send(ctx, notify.Message{To: "1", Text: "a", Priority: notify.PriorityLow})
+	if err := s.Flush(ctx); err == nil {
+		t.Fatal("ожидалась ошибка отправки сводки")
+	}
+	_ = s.Send(ctx, notify.Message{To: "1", Text: "b", Priority: notify.PriorityLow})
+
+	next.fail = false
+	if err := s.Flush(ctx); err != nil {
+		t.Fatalf("Ошибка Flush: %v", err)
+	}
+	if len(next.sent) != 1 || next.sent[0].Text != "a;b;" {
+		t.Fatalf("ожидалась одна сводка «a;b;», получено %+v", next.sent)
+	}
+}
+
+func TestRunSpoolsOnShutdown(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	next := &recordingSender{fail: true}
+	store := spool.NewMemoryStore()
+	s := digest.Wrap(next, time.Hour, nil, slog.Default())
+	s.SetSpool(store)
+
+	_ = s.Send(ctx, notify.Message{To: "1", Text: "a", Priority: notify.PriorityLow})
+	_ = s.Send(ctx, notify.Message{To: "1", Text: "b", Priority: notify.PriorityLow})
+	cancel()
+	s.Run(ctx)
+
+	// Сохраняются исходные сообщения: после перезапуска они снова соберутся в сводку
+	left, _ := store.Pending(context.Background())
+	if len(left) != 2 || left[0].Channel != "fake" || left[0].Message.Text != "a" || left[1].Message.Text != "b" {
+		t.Fatalf("ожидались два сохранённых сообщения сводки, получено %+v", left)
+	}
+	if s.Pending() != 0 {
+		t.Fatalf("сохранённые сообщения должны уйти из очереди сводок, осталось %d", s.Pending())
+	}
+}
+
+func TestDeliveryTimeByRecipientTimezone(t *testing.T) {
+	next := &recordingSender{}
+	d := digest.Wrap(next, time.Minute, nil, slog.Default())
+	recipients := notify.NewMemoryRecipientStore()
+	_ = recipients.Put(context.Background(), notify.Recipient{ID: "u1", Timezone: "Asia/Vladivostok"})
+	at, _ := notify.ParseLocalTime("09:00")
+	d.SetDeliveryTime(at, recipients)
+
+	ctx := context.Background()
+	_ = d.Send(ctx, notify.Message{To: "vlad", UserID: "u1", Text: "a", Priority: notify.PriorityLow})
+	_ = d.Send(ctx, notify.Message{To: "utc", UserID: "u2", Text: "b", Priority: notify.PriorityLow})
+
+	vlad, _ := time.LoadLocation("Asia/Vladivostok")
+	// Порядок сводок зависит от текущего времени: раньше уходит та, у которой 09:00 наступает раньше
+	first, second := "vlad", "utc"
+	firstDue, secondDue := at.Next(time.Now(), vlad), at.Next(time.Now(), time.UTC)
+	if secondDue.Before(firstDue) {
+		first, second = second, first
+		firstDue, secondDue = secondDue, firstDue
+	}
+
+	_ = d.FlushDue(ctx, firstDue.Add(-time.Second))
+	if len(next.sent) != 0 {
+		t.Fatalf("до наступления 09:00 сводки не отправляются, отправлено %d", len(next.sent))
+	}
+	_ = d.FlushDue(ctx, firstDue)
+	if len(next.sent) != 1 || next.sent[0].To != first {
+		t.Fatalf("ожидалась сводка для %s, отправлено %+v", first, next.sent)
+	}
+	_ = d.FlushDue(ctx, secondDue)
+	if len(next.sent) != 2 || next.sent[1].To != second {
+		t.Fatalf("ожидалась сводка для %s, отправлено %+v", second, next.sent)
+	}
+}
@@ -0,0 +1,114 @@
+package email
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/epheer/notephee/notify"
+)
+
+// ErrUnknownAccount возвращается, если учётная запись отправителя с таким именем не добавлена в Accounts.
+var ErrUnknownAccount = errors.New("учётная запись отправителя не зарегистрирована")
+
+// Accounts — несколько учётных записей отправителя в одном процессе, например noreply@, alerts@ и billing@,
+// каждая со своими данными SMTP или провайдера и своим лимитом отправок. Учётная запись выбирается
+// для каждого письма по имени; пустое имя — основная.
+type Accounts struct {
+	mu      sync.RWMutex
+	clients map[string]*Client
+}
+
+// NewAccounts создаёт пустой набор учётных записей.
+func NewAccounts() *Accounts {
+	return &Accounts{clients: make(map[string]*Client)}
+}
+
+// Add добавляет учётную запись name с клиентом client. Имя должно быть уникальным в наборе.
+func (a *Accounts) Add(name string, client *Client) error {
+	if client == nil || !client.Enabled {
+		return fmt.Errorf("учётная запись %q: конфигурация email не заполнена", name)
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if _, ok := a.clients[name]; ok {
+		return fmt.Errorf("учётная запись %q добавлена дважды", name)
+	}
+	a.clients[name] = client
+	return nil
+}
+
+// Account возвращает клиента учётной записи name; пустое имя — основная.
+func (a *Accounts) Account(name string) (*Client, error) {
+	a.mu.RLock()
+	client, ok := a.clients[name]
+	a.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%q: %w", name, ErrUnknownAccount)
+	}
+	return client, nil
+}
+
+// Names возвращает имена учётных записей в алфавитном порядке.
+func (a *Accounts) Names() []string {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	names := make([]string, 0, len(a.clients))
+	for name := range a.clients {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// SendText отправляет письмо от учётной записи account.
+func (a *Accounts) SendText(ctx context.Context, account string, options MessageOptions) error {
+	client, err := a.Account(account)
+	if err != nil {
+		return err
+	}
+	return client.sendText(ctx, options)
+}
+
+// Channel возвращает имя канала для notify.Registry.
+func (a *Accounts) Channel() string {
+	return Channel
+}
+
+// Send реализует notify.Sender: письмо уходит от учётной записи msg.Identity.
+func (a *Accounts) Send(ctx context.Context, msg notify.Message) error {
+	client, err := a.Account(msg.Identity)
+	if err != nil {
+		return err
+	}
+	return client.Send(ctx, msg)
+}
+
+// Register добавляет клиентов учётных записей в реестр каналов: основную — через Register,
+// остальные — как личности отправителя через RegisterIdentity.
+func (a *Accounts) Register(r *notify.Registry) {
+	for _, name := range a.Names() {
+		client, _ := a.Account(name)
+		if name == "" {
+			r.Register(client)
+		} else {
+			r.RegisterIdentity(name, client)
+		}
+	}
+}
+
+// Close закрывает клиентов всех учётных записей, дожидаясь начатых отправок до истечения ctx.
+func (a *Accounts) Close(ctx context.Context) error {
+	var errs []error
+	for _, name := range a.Names() {
+		client, _ := a.Account(name)
+		if err := client.Close(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("учётная запись %q: %w", name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,178 @@
+package email
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/mail"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/idna"
+
+	"github.com/epheer/notephee/notify"
+)
+
+// ErrInvalidAddress возвращается для адреса, на который письмо заведомо не дойдёт: с синтаксической
+// ошибкой или с доменом, не принимающим почту. Такие письма не отправляются и не попадают в журнал доставки.
+var ErrInvalidAddress = notify.ErrInvalidAddress
+
+// Resolver выполняет DNS-запросы для проверки домена получателя; подходит *net.Resolver.
+type Resolver interface {
+	LookupMX(ctx context.Context, name string) ([]*net.MX, error)
+	LookupHost(ctx context.Context, host string) ([]string, error)
+}
+
+// ValidateAddress проверяет синтаксис адреса по RFC 5322 и возвращает его в нормализованном виде:
+// домен приводится к нижнему регистру, интернационализированный домен — к punycode (пример.рф →
+// xn--e1afmkfd.xn--p1ai). Локальная часть не меняется: её регистр значим для почтового сервера.
+//
+// Принимается только адрес без отображаемого имени; домен должен содержать точку.
+// Ошибка оборачивает ErrInvalidAddress.
+func ValidateAddress(addr string) (string, error) {
+	invalid := func(reason string) (string, error) {
+		return "", fmt.Errorf("%q: %w: %s", addr, ErrInvalidAddress, reason)
+	}
+
+	parsed, err := mail.ParseAddress(addr)
+	if err != nil {
+		return invalid("синтаксическая ошибка")
+	}
+	if parsed.Name != "" || strings.ContainsAny(addr, "<>") {
+		return invalid("ожидается адрес без отображаемого имени")
+	}
+
+	// net/mail снимает кавычки с локальной части ("john doe"@), поэтому она берётся из исходного адреса
+	addrSpec := strings.TrimSpace(addr)
+	at := strings.LastIndexByte(addrSpec, '@')
+	local, domain := addrSpec[:at], addrSpec[at+1:]
+	if len(local) > 64 {
+		return invalid("локальная часть длиннее 64 символов")
+	}
+	if strings.HasPrefix(domain, "[") {
+		// Адрес-литерал ([192.0.2.1]) допустим, но не нормализуется
+		return addrSpec, nil
+	}
+
+	ascii, err := idna.Lookup.ToASCII(domain)
+	if err != nil {
+		return invalid("некорректный домен")
+	}
+	if !strings.Contains(ascii, ".") {
+		return invalid("домен без зоны")
+	}
+
+	normalized := local + "@" + ascii
+	if len(normalized) > 254 {
+		return invalid("адрес длиннее 254 символов")
+	}
+	return normalized, nil
+}
+
+// CheckMX проверяет, что домен адреса принимает почту: у него есть MX-записи, а без них — A или AAAA
+// (неявный MX по RFC 5321). Домен с «нулевым» MX (RFC 7505) почту не принимает.
+//
+// Адрес должен быть нормализован ValidateAddress. Ошибка оборачивает ErrInvalidAddress, только если
+// домен точно не существует или не принимает почту; сбой DNS возвращается как есть.
+func CheckMX(ctx context.Context, r Resolver, addr string) error {
+	domain := addr[strings.LastIndexByte(addr, '@')+1:]
+	if strings.HasPrefix(domain, "[") {
+		return nil
+	}
+
+	mxs, err := r.LookupMX(ctx, domain)
+	if err == nil && len(mxs) > 0 {
+		if len(mxs) == 1 && (mxs[0].Host == "." || mxs[0].Host == "") {
+			return fmt.Errorf("%q: %w: домен не принимает почту", addr, ErrInvalidAddress)
+		}
+		return nil
+	}
+	if err != nil && !isNotFound(err) {
+		return fmt.Errorf("проверка MX домена %s: %w", domain, err)
+	}
+
+	if _, err := r.LookupHost(ctx, domain); err != nil {
+		if isNotFound(err) {
+			return fmt.Errorf("%q: %w: у домена нет MX- и A-записей", addr, ErrInvalidAddress)
+		}
+		return fmt.Errorf("проверка домена %s: %w", domain, err)
+	}
+	return nil
+}
+
+func isNotFound(err error) bool {
+	var dnsErr *net.DNSError
+	return errors.As(err, &dnsErr) && dnsErr.IsNotFound
+}
+
+// mxCacheTTL — сколько хранится результат проверки домена: в рассылке у многих получателей один домен.
+const mxCacheTTL = time.Hour
+
+type mxResult struct {
+	err     error
+	checked time.Time
+}
+
+// mxChecker проверяет домены получателей с кэшем результатов.
+type mxChecker struct {
+	resolver Resolver
+	mu       sync.Mutex
+	cache    map[string]mxResult
+}
+
+// check возвращает ошибку ErrInvalidAddress, если домен addr не принимает почту. Сбои DNS
+// возвращаются как есть и не кэшируются.
+func (m *mxChecker) check(ctx context.Context, addr string) error {
+	domain := addr[strings.LastIndexByte(addr, '@')+1:]
+	m.mu.Lock()
+	res, ok := m.cache[domain]
+	m.mu.Unlock()
+	if ok && time.Since(res.checked) < mxCacheTTL {
+		if res.err != nil {
+			return fmt.Errorf("%q: %w", addr, res.err)
+		}
+		return nil
+	}
+
+	err := CheckMX(ctx, m.resolver, addr)
+	if err != nil && !errors.Is(err, ErrInvalidAddress) {
+		return err
+	}
+	cached := mxResult{checked: time.Now()}
+	if err != nil {
+		cached.err = fmt.Errorf("%w: домен %s не принимает почту", ErrInvalidAddress, domain)
+	}
+	m.mu.Lock()
+	m.cache[domain] = cached
+	m.mu.Unlock()
+	return err
+}
+
+// SetMXCheck включает проверку домена получателя перед отправкой (см. CheckMX); nil отключает её.
+// Результаты проверки домена кэшируются на час. Если DNS недоступен, письмо отправляется без проверки.
+func (c *Client) SetMXCheck(r Resolver) {
+	if r == nil {
+		c.mx = nil
+		return
+	}
+	c.mx = &mxChecker{resolver: r, cache: make(map[string]mxResult)}
+}
+
+// checkAddress проверяет и нормализует адрес получателя перед отправкой.
+func (c *Client) checkAddress(ctx context.Context, addr string) (string, error) {
+	normalized, err := ValidateAddress(addr)
+	if err != nil {
+		return "", err
+	}
+	if c.mx == nil {
+		return normalized, nil
+	}
+	if err := c.mx.check(ctx, normalized); errors.Is(err, ErrInvalidAddress) {
+		return "", err
+	} else if err != nil {
+		c.logger.Warn("не удалось проверить домен получателя, письмо отправляется без проверки", "to", normalized, "error", err)
+	}
+	return normalized, nil
+}
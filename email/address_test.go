@@ -0,0 +1,136 @@
+package email
+
+import (
+	"context"
+	"errors"
+	"net"
+	"slices"
+	"testing"
+)
+
+func TestValidateAddress(t *testing.T) {
+	valid := map[string]string{
+		"User@Example.COM":           "User@example.com",
+		"user+tag@пример.рф":         "user+tag@xn--e1afmkfd.xn--p1ai",
+		`"john doe"@example.com`:     `"john doe"@example.com`,
+		"user@[192.0.2.1]":           "user@[192.0.2.1]",
+		"first.last@sub.example.org": "first.last@sub.example.org",
+	}
+	for addr, want := range valid {
+		got, err := ValidateAddress(addr)
+		if err != nil || got != want {
+			t.Errorf("ValidateAddress(%q) = %q, %v; ожидалось %q", addr, got, err, want)
+		}
+	}
+
+	for _, addr := range []string{"", "user", "user@", "@example.com", "user@localhost", "a b@example.com",
+		"User <user@example.com>", "user@exa_mple..com", "user@example.com.", "user@example.com\r\nBcc: x@example.com"} {
+		if _, err := ValidateAddress(addr); !errors.Is(err, ErrInvalidAddress) {
+			t.Errorf("ValidateAddress(%q): ожидалась ErrInvalidAddress, получено %v", addr, err)
+		}
+	}
+}
+
+// fakeResolver отвечает на DNS-запросы из таблиц; домена нет в таблице — NXDOMAIN.
+type fakeResolver struct {
+	mx      map[string][]*net.MX
+	hosts   map[string][]string
+	lookups int
+}
+
+func (r *fakeResolver) LookupMX(_ context.Context, name string) ([]*net.MX, error) {
+	r.lookups++
+	if name == "timeout.example" {
+		return nil, &net.DNSError{Err: "i/o timeout", Name: name, IsTimeout: true}
+	}
+	if mx, ok := r.mx[name]; ok {
+		return mx, nil
+	}
+	return nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+}
+
+func (r *fakeResolver) LookupHost(_ context.Context, host string) ([]string, error) {
+	if addrs, ok := r.hosts[host]; ok {
+		return addrs, nil
+	}
+	return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+}
+
+func TestMXCheck(t *testing.T) {
+	c := newTestClient(t, "", Options{User: "noreply@example.com", Transport: &fakeTransport{}})
+	c.SetTransport(&fakeTransport{})
+	r := &fakeResolver{
+		mx:    map[string][]*net.MX{"example.com": {{Host: "mx.example.com.", Pref: 10}}, "nullmx.example": {{Host: "."}}},
+		hosts: map[string][]string{"a-only.example": {"192.0.2.1"}},
+	}
+	c.SetMXCheck(r)
+
+	for _, to := range []string{"user@example.com", "user@a-only.example", "user@timeout.example"} {
+		if err := c.SendText(MessageOptions{To: to, Body: "x"}); err != nil {
+			t.Errorf("%s: %v", to, err)
+		}
+	}
+	for _, to := range []string{"user@nullmx.example", "user@missing.example"} {
+		if err := c.SendText(MessageOptions{To: to, Body: "x"}); !errors.Is(err, ErrInvalidAddress) {
+			t.Errorf("%s: ожидалась ErrInvalidAddress, получено %v", to, err)
+		}
+	}
+
+	lookups := r.lookups
+	_ = c.SendText(MessageOptions{To: "other@example.com", Body: "x"})
+	_ = c.SendText(MessageOptions{To: "other@missing.example", Body: "x"})
+	if r.lookups != lookups {
+		t.Errorf("результат проверки домена должен кэшироваться, запросов: %d", r.lookups-lookups)
+	}
+}
+
+func TestBulkSkipsInvalid(t *testing.T) {
+	c := newTestClient(t, "", Options{User: "noreply@example.com", Transport: &fakeTransport{}})
+	tr := &fakeTransport{}
+	c.SetTransport(tr)
+
+	results := c.SendMessaging(SendingOptions{Recipients: []string{"not-an-address", "User@EXAMPLE.com"}, Subject: "s", Body: "b"})
+	var invalid, sent int
+	for _, res := range results {
+		switch {
+		case errors.Is(res.Error, ErrInvalidAddress):
+			invalid++
+		case res.Error == nil:
+			sent++
+		default:
+			t.Errorf("%s: %v", res.To, res.Error)
+		}
+	}
+	if invalid != 1 || sent != 1 {
+		t.Fatalf("ожидался один пропущенный и один отправленный адрес: %+v", results)
+	}
+	if results[0].To != "not-an-address" || results[1].Index != 1 {
+		t.Errorf("результаты должны идти в порядке получателей: %+v", results)
+	}
+	if s := Summary(results); s.Skipped != 1 || s.Sent != 1 || s.Failed != 0 || s.Done() != 2 {
+		t.Errorf("неверные итоги рассылки: %+v", s)
+	}
+	if tr.got.To != "User@example.com" {
+		t.Errorf("провайдер должен получить нормализованный адрес, получено %q", tr.got.To)
+	}
+
+	// Получатели потоком проверяются так же
+	results = c.SendMessaging(SendingOptions{RecipientSeq: slices.Values([]string{"bad@", "second@example.com"}), Subject: "s", Body: "b"})
+	if len(results) != 2 || tr.got.To != "second@example.com" {
+		t.Fatalf("ожидалась отправка получателю из RecipientSeq: %+v", results)
+	}
+}
+
+func TestSanitizeHTML(t *testing.T) {
+	in := `<p onclick="steal()">Привет, <b>мир</b><script>alert(1)</script>` +
+		`<a href="java&#09;script:alert(1)">ссылка</a><a href="https://example.com/?a=1">сайт</a>` +
+		`<img src="cid:logo" style="background:url(https://evil.example)"><iframe src="https://evil.example"></iframe><!-- x -->`
+	want := `<p>Привет, <b>мир</b><a>ссылка</a><a href="https://example.com/?a=1">сайт</a><img src="cid:logo"/></p>`
+	if got := SanitizeHTML(in); got != want {
+		t.Fatalf("неверная очистка HTML:\n%s\nожидалось\n%s", got, want)
+	}
+
+	if got := HTMLf("<p>%s</p>", "<b>жирный</b>\nвторая строка"); got != "<p>&lt;b&gt;жирный&lt;/b&gt;<br>вторая строка</p>" {
+		t.Fatalf("неверный HTMLf: %s", got)
+	}
+}
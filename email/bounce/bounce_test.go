@@ -0,0 +1,120 @@
+package bounce_test
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/epheer/notephee/email/bounce"
+	"github.com/epheer/notephee/suppression"
+)
+
+const dsn = "From: MAILER-DAEMON@mx.example\r\n" +
+	"Subject: Undelivered Mail Returned to Sender\r\n" +
+	"Content-Type: multipart/report; report-type=delivery-status; boundary=\"b\"\r\n" +
+	"\r\n" +
+	"--b\r\n" +
+	"Content-Type: text/plain\r\n" +
+	"\r\n" +
+	"I'm sorry to have to inform you...\r\n" +
+	"--b\r\n" +
+	"Content-Type: message/delivery-status\r\n" +
+	"\r\n" +
+	"Reporting-MTA: dns; mx.example\r\n" +
+	"\r\n" +
+	"Final-Recipient: rfc822; gone@mx.example\r\n" +
+	"Action: failed\r\n" +
+	"Status: 5.1.1\r\n" +
+	"Diagnostic-Code: smtp; 550 5.1.1 User unknown\r\n" +
+	"\r\n" +
+	"Final-Recipient: rfc822; full@mx.example\r\n" +
+	"Action: failed\r\n" +
+	"Status: 5.2.2\r\n" +
+	"Diagnostic-Code: smtp; 552 5.2.2 Mailbox full\r\n" +
+	"\r\n" +
+	"--b\r\n" +
+	"Content-Type: text/rfc822-headers\r\n" +
+	"\r\n" +
+	"Message-ID: <1@notephee.example>\r\n" +
+	"X-Notephee-Campaign: digest\r\n" +
+	"\r\n" +
+	"--b--\r\n"
+
+func TestClassify(t *testing.T) {
+	cases := []struct {
+		status, diag string
+		want         bounce.Class
+	}{
+		{"5.1.1", "550 5.1.1 User unknown", bounce.Hard},
+		{"4.4.1", "connection timed out", bounce.Soft},
+		{"5.7.1", "message rejected", bounce.Block},
+		{"", "550 Message blocked by Spamhaus", bounce.Block},
+		{"5.2.2", "mailbox full", bounce.Soft},
+		{"", "", bounce.Unknown},
+	}
+	for _, c := range cases {
+		if got := bounce.Classify(c.status, c.diag); got != c.want {
+			t.Errorf("Classify(%q, %q) = %s, ожидалось %s", c.status, c.diag, got, c.want)
+		}
+	}
+}
+
+func TestProcessDSN(t *testing.T) {
+	store := suppression.NewMemoryStore()
+	p := bounce.NewProcessor(store, nil, nil, slog.Default())
+
+	b, err := p.Process(context.Background(), strings.NewReader(dsn))
+	if err != nil {
+		t.Fatalf("Ошибка Process: %v", err)
+	}
+	if b.Class != bounce.Hard || len(b.Recipients) != 2 || b.Campaign != "digest" {
+		t.Fatalf("неверно разобран DSN: %+v", b)
+	}
+
+	ctx := context.Background()
+	if ok, _ := store.IsSuppressed(ctx, "email", "gone@mx.example", ""); !ok {
+		t.Fatal("адрес с жёстким отказом не заблокирован")
+	}
+	if ok, _ := store.IsSuppressed(ctx, "email", "full@mx.example", ""); ok {
+		t.Fatal("адрес с мягким отказом не должен блокироваться")
+	}
+}
+
+func TestParseAutoReply(t *testing.T) {
+	msg := "From: boss@example.com\r\nSubject: Automatic reply: Новости\r\nAuto-Submitted: auto-replied\r\n\r\nI'm away"
+	b, err := bounce.Parse(strings.NewReader(msg))
+	if err != nil {
+		t.Fatalf("Ошибка Parse: %v", err)
+	}
+	if b.Class != bounce.AutoReply {
+		t.Fatalf("ожидался автоответ, получено %s", b.Class)
+	}
+}
+
+func TestParsePlainTextBounce(t *testing.T) {
+	const head = "From: MAILER-DAEMON@mx.example\r\nSubject: Mail delivery failed\r\n"
+	const text = "550 5.1.1 User unknown\r\n\r\n------ This is a copy of the message, including all the headers.\r\n\r\n" +
+		"Message-ID: <2@notephee.example>\r\nTo: Иван <gone@mx.example>\r\nX-Notephee-Campaign: digest\r\n"
+
+	b, err := bounce.Parse(strings.NewReader(head + "\r\n" + text))
+	if err != nil {
+		t.Fatalf("Ошибка Parse: %v", err)
+	}
+	if len(b.Recipients) != 1 || b.Recipients[0].Address != "gone@mx.example" || b.Class != bounce.Hard {
+		t.Fatalf("получатель должен браться из исходного письма, а не из From: %+v", b)
+	}
+	if b.MessageID != "2@notephee.example" || b.Campaign != "digest" {
+		t.Fatalf("заголовки исходного письма не разобраны: %+v", b)
+	}
+
+	b, err = bounce.Parse(strings.NewReader(head + "X-Failed-Recipients: a@mx.example, b@mx.example\r\n\r\n550 5.1.1 User unknown"))
+	if err != nil || len(b.Recipients) != 2 || b.Recipients[1].Address != "b@mx.example" {
+		t.Fatalf("получатели из X-Failed-Recipients: %+v %v", b, err)
+	}
+
+	if _, err := bounce.Parse(strings.NewReader(head + "\r\n550 5.1.1 User unknown")); !errors.Is(err, bounce.ErrNoRecipient) {
+		t.Fatalf("ожидалась ErrNoRecipient, получено %v", err)
+	}
+}
@@ -0,0 +1,114 @@
+package bounce
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Class — класс отказа в доставке.
+type Class string
+
+// Классы отказов
+const (
+	Hard              Class = "hard"               // Адрес не существует или навсегда недоступен
+	Soft              Class = "soft"               // Временная ошибка: ящик переполнен, сервер недоступен
+	Block             Class = "block"              // Письмо отклонено политикой получателя (спам-фильтр, репутация)
+	AutoReply         Class = "autoreply"          // Автоответ («в отпуске» и т.п.), письмо доставлено
+	ChallengeResponse Class = "challenge-response" // Получатель просит подтвердить, что отправитель — человек
+	Unknown           Class = "unknown"            // Классифицировать не удалось
+)
+
+var (
+	statusRe = regexp.MustCompile(`\b([245])\.(\d{1,3})\.(\d{1,3})\b`)
+
+	blockHints = []string{
+		"blocked", "blacklist", "blocklist", "spam", "reputation", "policy", "rejected for",
+		"spamhaus", "denied", "not allowed",
+	}
+	hardHints = []string{
+		"user unknown", "no such user", "unknown user", "mailbox unavailable", "does not exist",
+		"address rejected", "invalid recipient", "recipient not found", "no mailbox",
+	}
+	softHints = []string{
+		"mailbox full", "over quota", "quota exceeded", "try again later", "temporarily",
+		"greylist", "graylist", "timeout", "too many connections",
+	}
+	autoReplySubjects = []string{
+		"out of office", "out of the office", "automatic reply", "auto reply", "autoreply",
+		"auto-reply", "vacation", "автоответ", "автоматический ответ", "в отпуске",
+	}
+	challengeHints = []string{
+		"verify you are human", "confirm your email", "challenge", "please verify",
+		"anti-spam verification", "sender verification", "подтвердите отправку",
+	}
+)
+
+// Status извлекает расширенный код статуса SMTP (например 5.1.1) из текста.
+func Status(text string) string {
+	return statusRe.FindString(text)
+}
+
+// Classify определяет класс отказа по расширенному коду статуса и диагностическому сообщению.
+func Classify(status, diagnostic string) Class {
+	if status == "" {
+		status = Status(diagnostic)
+	}
+	diag := strings.ToLower(diagnostic)
+
+	if containsAny(diag, blockHints) {
+		return Block
+	}
+
+	m := statusRe.FindStringSubmatch(status)
+	if m == nil {
+		switch {
+		case containsAny(diag, hardHints):
+			return Hard
+		case containsAny(diag, softHints):
+			return Soft
+		}
+		return Unknown
+	}
+
+	class, subject := m[1], m[2]
+	switch {
+	case class == "2":
+		return Unknown
+	case subject == "7":
+		return Block
+	case class == "4":
+		return Soft
+	case status == "5.2.2" || containsAny(diag, softHints):
+		// Переполненный ящик формально даёт 5.x.x, но обычно освобождается
+		return Soft
+	default:
+		return Hard
+	}
+}
+
+// isAutoReply распознаёт автоответы по заголовкам и теме письма.
+func isAutoReply(autoSubmitted, subject string, hasAutoreplyHeader bool) bool {
+	if hasAutoreplyHeader {
+		return true
+	}
+	as := strings.ToLower(strings.TrimSpace(autoSubmitted))
+	if as != "" && as != "no" {
+		return true
+	}
+	return containsAny(strings.ToLower(subject), autoReplySubjects)
+}
+
+// isChallenge распознаёт запросы challenge-response систем.
+func isChallenge(subject, body string) bool {
+	text := strings.ToLower(subject + "\n" + body)
+	return containsAny(text, challengeHints)
+}
+
+func containsAny(s string, hints []string) bool {
+	for _, h := range hints {
+		if strings.Contains(s, h) {
+			return true
+		}
+	}
+	return false
+}
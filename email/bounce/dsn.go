@@ -0,0 +1,247 @@
+package bounce
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"net/textproto"
+	"strings"
+
+	"github.com/epheer/notephee/email"
+)
+
+// ErrNoRecipient возвращается Parse для отказа простым текстом, в котором не удалось найти адрес получателя:
+// ни в заголовке X-Failed-Recipients, ни в приложенных заголовках исходного письма.
+var ErrNoRecipient = errors.New("в отказе без DSN не найден адрес получателя")
+
+// Recipient — результат доставки одному получателю из DSN.
+type Recipient struct {
+	Address    string // Адрес получателя (Final-Recipient)
+	Action     string // Действие MTA: failed, delayed, delivered, relayed, expanded
+	Status     string // Расширенный код статуса, например 5.1.1
+	Diagnostic string // Диагностическое сообщение удалённого сервера
+	Class      Class  // Класс отказа
+}
+
+// Bounce — разобранное уведомление об отказе или автоответ.
+type Bounce struct {
+	Class        Class       // Итоговый класс (самый серьёзный среди получателей)
+	Recipients   []Recipient // Получатели, к которым относится уведомление
+	ReportingMTA string      // Сервер, сформировавший DSN
+	MessageID    string      // Message-ID исходного письма (если приложено)
+	Campaign     string      // Рассылка исходного письма (заголовок X-Notephee-Campaign)
+}
+
+// Parse разбирает входящее письмо: DSN (RFC 3464), автоответ или challenge-response.
+//
+// Получатель автоответа и challenge-response — отправитель письма (From). Отказ простым текстом
+// присылает MAILER-DAEMON, поэтому получатель берётся из X-Failed-Recipients или из заголовков
+// исходного письма в тексте; если его нет, возвращается ErrNoRecipient.
+// Письма без признаков отказа получают класс Unknown.
+func Parse(r io.Reader) (*Bounce, error) {
+	msg, err := mail.ReadMessage(r)
+	if err != nil {
+		return nil, fmt.Errorf("некорректное письмо: %w", err)
+	}
+
+	mediaType, params, _ := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if mediaType == "multipart/report" && strings.EqualFold(params["report-type"], "delivery-status") {
+		return parseDSN(msg.Body, params["boundary"])
+	}
+
+	body, _ := io.ReadAll(io.LimitReader(msg.Body, 64<<10))
+	subject := decodeHeader(msg.Header.Get("Subject"))
+	from := ""
+	if addr, err := mail.ParseAddress(msg.Header.Get("From")); err == nil {
+		from = addr.Address
+	}
+
+	b := &Bounce{Class: Unknown}
+	switch {
+	case isAutoReply(msg.Header.Get("Auto-Submitted"), subject, msg.Header.Get("X-Autoreply") != ""):
+		b.Class = AutoReply
+	case isChallenge(subject, string(body)):
+		b.Class = ChallengeResponse
+	default:
+		// Некоторые MTA присылают отказы простым текстом без DSN
+		b.Class = Classify("", string(body))
+		if b.Class == Unknown {
+			return b, nil
+		}
+		b.parseOriginal(string(body))
+		addrs := b.failedRecipients(msg.Header.Get("X-Failed-Recipients"), string(body))
+		if len(addrs) == 0 {
+			return nil, ErrNoRecipient
+		}
+		for _, addr := range addrs {
+			b.Recipients = append(b.Recipients, Recipient{Address: addr, Action: "failed", Class: b.Class})
+		}
+		return b, nil
+	}
+	if from != "" {
+		b.Recipients = []Recipient{{Address: from, Class: b.Class}}
+	}
+	return b, nil
+}
+
+// failedRecipients возвращает адреса из заголовка X-Failed-Recipients (Exim и др.), а без него —
+// из заголовка To исходного письма, процитированного в тексте отказа.
+func (b *Bounce) failedRecipients(header, body string) []string {
+	if header == "" {
+		header = originalHeader(body, "To")
+	}
+	list, err := mail.ParseAddressList(header)
+	if err != nil {
+		return nil
+	}
+	out := make([]string, 0, len(list))
+	for _, addr := range list {
+		out = append(out, addr.Address)
+	}
+	return out
+}
+
+// parseOriginal заполняет Message-ID и рассылку из заголовков исходного письма в тексте отказа.
+func (b *Bounce) parseOriginal(body string) {
+	b.MessageID = strings.Trim(originalHeader(body, "Message-Id"), "<>")
+	b.Campaign = originalHeader(body, email.CampaignHeader)
+}
+
+// originalHeader ищет в тексте отказа строку заголовка name исходного письма и возвращает его значение.
+func originalHeader(body, name string) string {
+	prefix := strings.ToLower(name) + ":"
+	for _, line := range strings.Split(body, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(strings.ToLower(line), prefix) {
+			return strings.TrimSpace(line[len(prefix):])
+		}
+	}
+	return ""
+}
+
+func parseDSN(body io.Reader, boundary string) (*Bounce, error) {
+	b := &Bounce{Class: Unknown}
+	found := false
+
+	parts := multipart.NewReader(body, boundary)
+	for {
+		part, err := parts.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("ошибка чтения части DSN: %w", err)
+		}
+
+		var r io.Reader = part
+		if strings.EqualFold(part.Header.Get("Content-Transfer-Encoding"), "base64") {
+			r = base64.NewDecoder(base64.StdEncoding, part)
+		}
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка чтения части DSN: %w", err)
+		}
+
+		partType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+		switch partType {
+		case "message/delivery-status", "message/global-delivery-status":
+			if err := b.parseStatus(data); err != nil {
+				return nil, err
+			}
+			found = true
+		case "message/rfc822", "text/rfc822-headers":
+			if headers, err := readHeaderBlock(data); err == nil {
+				b.MessageID = strings.Trim(headers.Get("Message-Id"), "<>")
+				b.Campaign = headers.Get(email.CampaignHeader)
+			}
+		}
+	}
+
+	if !found {
+		return nil, fmt.Errorf("в DSN нет части message/delivery-status")
+	}
+	return b, nil
+}
+
+// parseStatus разбирает машиночитаемую часть DSN: поля сообщения и блоки по получателям.
+func (b *Bounce) parseStatus(data []byte) error {
+	tp := textproto.NewReader(bufio.NewReader(bytes.NewReader(data)))
+
+	perMessage, err := tp.ReadMIMEHeader()
+	if err != nil && err != io.EOF {
+		return fmt.Errorf("некорректная часть message/delivery-status: %w", err)
+	}
+	b.ReportingMTA = trimType(perMessage.Get("Reporting-Mta"))
+
+	for err != io.EOF {
+		var fields textproto.MIMEHeader
+		fields, err = tp.ReadMIMEHeader()
+		if err != nil && err != io.EOF {
+			return fmt.Errorf("некорректный блок получателя в DSN: %w", err)
+		}
+		addr := fields.Get("Final-Recipient")
+		if addr == "" {
+			addr = fields.Get("Original-Recipient")
+		}
+		if addr == "" {
+			continue
+		}
+
+		rcpt := Recipient{
+			Address:    trimType(addr),
+			Action:     strings.ToLower(fields.Get("Action")),
+			Status:     Status(fields.Get("Status")),
+			Diagnostic: trimType(fields.Get("Diagnostic-Code")),
+		}
+		rcpt.Class = Classify(rcpt.Status, rcpt.Diagnostic)
+		if rcpt.Action == "delivered" || rcpt.Action == "relayed" || rcpt.Action == "expanded" {
+			rcpt.Class = Unknown
+		}
+		b.Recipients = append(b.Recipients, rcpt)
+		b.Class = worse(b.Class, rcpt.Class)
+	}
+	return nil
+}
+
+// severity упорядочивает классы для выбора итогового класса уведомления.
+var severity = map[Class]int{
+	Unknown: 0, AutoReply: 1, ChallengeResponse: 2, Soft: 3, Block: 4, Hard: 5,
+}
+
+func worse(a, b Class) Class {
+	if severity[b] > severity[a] {
+		return b
+	}
+	return a
+}
+
+func readHeaderBlock(data []byte) (textproto.MIMEHeader, error) {
+	data = append(data, "\r\n\r\n"...)
+	headers, err := textproto.NewReader(bufio.NewReader(bytes.NewReader(data))).ReadMIMEHeader()
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	return headers, nil
+}
+
+// trimType убирает префикс типа (rfc822;, smtp;, dns;) из полей DSN.
+func trimType(v string) string {
+	if i := strings.Index(v, ";"); i >= 0 {
+		v = v[i+1:]
+	}
+	return strings.Trim(strings.TrimSpace(v), "<>")
+}
+
+func decodeHeader(v string) string {
+	decoded, err := new(mime.WordDecoder).DecodeHeader(v)
+	if err != nil {
+		return v
+	}
+	return decoded
+}
@@ -0,0 +1,107 @@
+package bounce
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"time"
+
+	"github.com/epheer/notephee/email"
+	"github.com/epheer/notephee/events"
+	"github.com/epheer/notephee/suppression"
+)
+
+// Action — действие, применяемое к получателю в зависимости от класса отказа.
+type Action string
+
+// Действия политики отказов
+const (
+	ActionSuppress Action = "suppress" // Сразу добавить адрес в список подавления
+	ActionRetry    Action = "retry"    // Повторить отправку позже
+	ActionIgnore   Action = "ignore"   // Ничего не делать
+)
+
+// Policy сопоставляет класс отказа с действием.
+type Policy map[Class]Action
+
+// DefaultPolicy — политика по умолчанию: недоставляемые адреса блокируются,
+// временные ошибки и блокировки по репутации отправляются повторно, автоответы игнорируются.
+var DefaultPolicy = Policy{
+	Hard:              ActionSuppress,
+	Soft:              ActionRetry,
+	Block:             ActionRetry,
+	AutoReply:         ActionIgnore,
+	ChallengeResponse: ActionIgnore,
+	Unknown:           ActionIgnore,
+}
+
+// Action возвращает действие для класса; неизвестные классы игнорируются.
+func (p Policy) Action(class Class) Action {
+	if a, ok := p[class]; ok {
+		return a
+	}
+	return ActionIgnore
+}
+
+// Processor классифицирует входящие отказы и применяет к получателям политику.
+type Processor struct {
+	store  suppression.Store // Список подавления
+	policy Policy            // Действия по классам отказов
+	bus    *events.Bus       // Шина событий (может быть nil)
+	logger *slog.Logger      // Логгер
+}
+
+// NewProcessor создаёт обработчик отказов. Если policy == nil, используется DefaultPolicy.
+func NewProcessor(store suppression.Store, policy Policy, bus *events.Bus, logger *slog.Logger) *Processor {
+	if policy == nil {
+		policy = DefaultPolicy
+	}
+	return &Processor{store: store, policy: policy, bus: bus, logger: logger}
+}
+
+// Process разбирает письмо и применяет политику к каждому получателю.
+//
+// Для каждого получателя с классом, отличным от Unknown, публикуется событие events.Bounce
+// с полями class и action, чтобы вызывающая сторона могла запланировать повтор.
+func (p *Processor) Process(ctx context.Context, r io.Reader) (*Bounce, error) {
+	b, err := Parse(r)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, rcpt := range b.Recipients {
+		if rcpt.Class == Unknown {
+			continue
+		}
+		action := p.policy.Action(rcpt.Class)
+
+		if action == ActionSuppress {
+			err := p.store.Suppress(ctx, suppression.Entry{
+				Channel:   email.Channel,
+				Address:   rcpt.Address,
+				Reason:    suppression.ReasonBounce,
+				CreatedAt: time.Now(),
+			})
+			if err != nil {
+				return b, fmt.Errorf("не удалось заблокировать %s: %w", rcpt.Address, err)
+			}
+		}
+
+		p.bus.Publish(events.Event{
+			Type:      events.Bounce,
+			Channel:   email.Channel,
+			Recipient: rcpt.Address,
+			Campaign:  b.Campaign,
+			Data: map[string]string{
+				"class":      string(rcpt.Class),
+				"action":     string(action),
+				"status":     rcpt.Status,
+				"diagnostic": rcpt.Diagnostic,
+				"message_id": b.MessageID,
+			},
+		})
+		p.logger.Info("получен отказ в доставке", "to", rcpt.Address, "class", rcpt.Class, "action", action)
+	}
+	return b, nil
+}
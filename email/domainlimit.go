@@ -0,0 +1,88 @@
+package email
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"golang.org/x/time/rate"
+)
+
+// DomainLimit — лимит отправки на домены одного почтового провайдера. Gmail, Mail.ru и другие
+// ограничивают поток с одного отправителя на свои ящики; без лимита рассылка на тысячи адресов
+// одного провайдера получает временные отказы, которые затрагивают весь аккаунт отправителя.
+type DomainLimit struct {
+	Domains     []string   // Домены получателей с общим лимитом: gmail.com, googlemail.com
+	Rate        rate.Limit // Писем в секунду
+	Burst       int        // Всплеск; 0 — 1
+	Connections int        // Одновременных отправок; 0 — без ограничения
+}
+
+// domainLimits хранит лимиты по доменам получателей. Неизменяем после создания.
+type domainLimits map[string]*domainBucket
+
+// domainBucket — общий лимит группы доменов.
+type domainBucket struct {
+	limiter *rate.Limiter
+	conns   chan struct{} // Слоты одновременных отправок; nil — без ограничения
+}
+
+func newDomainLimits(limits []DomainLimit) (domainLimits, error) {
+	out := make(domainLimits)
+	for _, l := range limits {
+		if len(l.Domains) == 0 || l.Rate <= 0 || l.Burst < 0 || l.Connections < 0 {
+			return nil, fmt.Errorf("некорректный лимит по доменам %v", l.Domains)
+		}
+		b := &domainBucket{limiter: rate.NewLimiter(l.Rate, max(l.Burst, 1))}
+		if l.Connections > 0 {
+			b.conns = make(chan struct{}, l.Connections)
+		}
+		for _, d := range l.Domains {
+			d = strings.ToLower(d)
+			if _, ok := out[d]; ok {
+				return nil, fmt.Errorf("домен %s указан в нескольких лимитах", d)
+			}
+			out[d] = b
+		}
+	}
+	return out, nil
+}
+
+// acquire ждёт лимита домена получателя to и свободного слота отправки. release освобождает слот
+// и вызывается после завершения отправки. Для доменов без лимита ждать нечего.
+func (d domainLimits) acquire(ctx context.Context, to string) (release func(), err error) {
+	b, ok := d[strings.ToLower(to[strings.LastIndexByte(to, '@')+1:])]
+	if !ok {
+		return func() {}, nil
+	}
+	if b.conns != nil {
+		select {
+		case b.conns <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	release = func() {
+		if b.conns != nil {
+			<-b.conns
+		}
+	}
+	if err := b.limiter.Wait(ctx); err != nil {
+		release()
+		return nil, err
+	}
+	return release, nil
+}
+
+// SetDomainLimits задаёт лимиты отправки по доменам получателей вместо прежних; без аргументов
+// лимиты снимаются. Лимит домена действует вместе с общим лимитом клиента: письмо ждёт сначала
+// свой домен, поэтому очередь на gmail.com не задерживает письма на другие домены.
+// Вызывается при настройке, до начала отправок.
+func (c *Client) SetDomainLimits(limits ...DomainLimit) error {
+	d, err := newDomainLimits(limits)
+	if err != nil {
+		return err
+	}
+	c.domains = d
+	return nil
+}
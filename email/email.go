@@ -1,148 +1,773 @@
 package email
 
 import (
+	"bytes"
+	"cmp"
 	"context"
+	"errors"
 	"fmt"
+	"iter"
 	"log/slog"
 	"mime"
+	"net"
+	"net/mail"
 	"net/smtp"
+	"slices"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/google/uuid"
 	"golang.org/x/time/rate"
 
-	"github.com/epheer/notephee/config"
+	"github.com/epheer/notephee/attachment"
+	"github.com/epheer/notephee/delivery"
+	"github.com/epheer/notephee/email/providers"
+	"github.com/epheer/notephee/email/tracking"
+	"github.com/epheer/notephee/email/unsubscribe"
+	"github.com/epheer/notephee/notify"
+	"github.com/epheer/notephee/suppression"
+	"github.com/epheer/notephee/tracing"
 )
 
 // MessageOptions содержит параметры для отправки одного письма.
 type MessageOptions struct {
-	To      string // Email получателя
-	Subject string // Тема письма
-	Body    string // Содержимое письма (в формате text/plain)
+	ID       string            // Идентификатор попытки в журнале доставки (генерируется, если пуст)
+	To       string            // Email получателя
+	Subject  string            // Тема письма
+	Body     string            // Содержимое письма (в формате text/plain)
+	HTML     string            // HTML-версия письма; Body остаётся текстовой альтернативой (необязательно)
+	UserID   string            // Внутренний ID пользователя для журнала доставки (необязательно)
+	Campaign string            // Идентификатор рассылки для атрибуции жалоб (необязательно)
+	List     string            // Список рассылки: отписка от него тоже блокирует письмо (необязательно)
+	Headers  map[string]string // Дополнительные заголовки письма (необязательно)
+
+	From     string // Адрес в заголовке From вместо адреса клиента, если релей это разрешает (необязательно)
+	FromName string // Отображаемое имя отправителя вместо имени клиента (необязательно)
+	ReplyTo  string // Адрес для ответов, заголовок Reply-To (необязательно)
+
+	Attachments []*attachment.Encoded          // Вложения, закодированные заранее (необязательно)
+	Inline      map[string]*attachment.Encoded // Картинки для HTML по Content-ID: <img src="cid:logo"> (необязательно)
 }
 
 // SendingOptions содержит данные для массовой рассылки.
 type SendingOptions struct {
-	Recipients []string // Список email-адресов
-	Subject    string   // Общая тема письма
-	Body       string   // Общий текст письма
+	Recipients   []string         // Список email-адресов
+	RecipientSeq iter.Seq[string] // Получатели потоком, например из курсора базы: читаются по мере отправки после Recipients (необязательно)
+	Subject      string           // Общая тема письма
+	Body         string           // Общий текст письма
+	HTML         string           // Общая HTML-версия письма (необязательно)
+	List         string           // Идентификатор списка рассылки для ссылок отписки (необязательно)
+	Campaign     string           // Идентификатор рассылки для атрибуции жалоб (необязательно)
+
+	Attachments []attachment.File          // Вложения: кодируются один раз на всю рассылку (необязательно)
+	Inline      map[string]attachment.File // Картинки для HTML по Content-ID, тоже кодируются один раз (необязательно)
+}
+
+// RawOptions содержит готовое письмо для SendRaw.
+type RawOptions struct {
+	ID         string   // Идентификатор попытки в журнале доставки (генерируется, если пуст; только для одного получателя)
+	From       string   // Адрес в конверте SMTP (MAIL FROM), например для возвратов; пусто — адрес клиента
+	To         string   // Email получателя в конверте SMTP
+	Recipients []string // Ещё получатели в конверте: письмо уходит каждому отдельно (необязательно)
+	UserID     string   // Внутренний ID пользователя для журнала доставки (необязательно)
+	Message    []byte   // Письмо в формате MIME с заголовками
 }
 
 // EmailResponse содержит результат одной отправки.
 type EmailResponse struct {
-	To    string // Адрес получателя
-	Error error  // Ошибка отправки (если была)
+	To        string     // Адрес получателя
+	Index     int        // Номер получателя в рассылке с 0: Recipients, затем RecipientSeq
+	MessageID string     // Message-ID письма без угловых скобок
+	Error     error      // Ошибка отправки (если была)
+	SMTP      *SMTPError // Разобранный ответ SMTP-сервера, если ошибку вернул он
+}
+
+// SendResult — сведения об отправленном письме для поиска в логах почтовых серверов.
+type SendResult struct {
+	ID         string    // Идентификатор попытки в журнале доставки
+	MessageID  string    // Значение заголовка Message-ID без угловых скобок
+	Date       time.Time // Значение заголовка Date
+	ProviderID string    // Идентификатор письма у провайдера HTTP API (если отправлено через него)
 }
 
 // Client инкапсулирует SMTP-клиент.
 type Client struct {
 	auth     smtp.Auth    // SMTP авторизация
+	authMu   sync.RWMutex // Защищает auth при смене пароля во время работы
 	url      string       // Полный адрес SMTP-сервера (host:port)
 	from     string       // От кого отправлять письма
 	fromName string       // Отображаемое имя
 	logger   *slog.Logger // Логгер
 	Enabled  bool         // Разрешена ли отправка
+
+	deliveryLog delivery.DeliveryLog     // Журнал попыток отправки (необязательно)
+	inflight    notify.InFlight          // Начатые отправки, которых ждёт Close
+	suppression suppression.Store        // Список подавления (необязательно)
+	unsubscribe *unsubscribe.Signer      // Подпись ссылок отписки для массовых рассылок (необязательно)
+	tracker     *tracking.Tracker        // Отслеживание открытий и переходов в HTML-письмах (необязательно)
+	attachments *attachment.Cache        // Закодированные вложения, общие для всех писем
+	transport   providers.EmailTransport // HTTP API провайдера вместо SMTP (необязательно)
+	rate        *rate.Limiter            // Лимит отправок по умолчанию, зависит от транспорта
+	limiter     notify.Limiter           // Внешний лимит вместо rate (необязательно)
+	mx          *mxChecker               // Проверка домена получателя (необязательно)
+	domains     domainLimits             // Лимиты по доменам получателей (необязательно)
+	sent        SentFolder               // Папка для копий отправленных писем (необязательно)
+	attachLimit attachment.Policy        // Предел размера вложений (необязательно)
+	retries     int                      // Повторы после временной ошибки (см. SetRetries)
+	retryDelay  time.Duration            // Пауза перед первым повтором; растёт с номером повтора
+}
+
+// Channel — имя email-канала в notify.Registry и журнале доставки.
+const Channel = "email"
+
+// CampaignHeader — заголовок, в котором передаётся идентификатор рассылки.
+//
+// Почтовые провайдеры прикладывают заголовки исходного письма к ARF-отчётам,
+// поэтому по нему жалобы связываются с рассылкой.
+const CampaignHeader = "X-Notephee-Campaign"
+
+// ErrSuppressed возвращается при попытке отправить письмо на адрес из списка подавления.
+var ErrSuppressed = notify.ErrSuppressed
+
+// DefaultRetryDelay — пауза перед первым повтором после временной ошибки (см. SetRetries).
+const DefaultRetryDelay = time.Second
+
+// ErrRawUnsupported возвращается SendRaw, если почтовый провайдер не принимает письма в формате MIME.
+var ErrRawUnsupported = errors.New("провайдер не поддерживает отправку готовых писем")
+
+// NewClient создаёт клиента, отправляющего письма через SMTP-сервер addr (host:port). Остальные
+// параметры задаются опциями:
+//
+//	mail, err := email.NewClient("smtp.example.com:587",
+//		email.WithAuth("noreply@example.com", password),
+//		email.WithFrom("noreply@example.com", "Example"))
+//
+// При отправке через HTTP API провайдера (WithTransport) addr может быть пустым.
+// Возвращает ошибку, если опция некорректна.
+func NewClient(addr string, opts ...Option) (*Client, error) {
+	c := &Client{
+		url:    addr,
+		logger: slog.Default(),
+
+		attachments: attachment.NewCache(32),
+		// SMTP-сервер принимает одно письмо раз в 2 секунды, HTTP API провайдеров — на порядки больше
+		rate:       rate.NewLimiter(rate.Every(2*time.Second), 1),
+		retryDelay: DefaultRetryDelay,
+	}
+	for _, opt := range opts {
+		if err := opt(c); err != nil {
+			return nil, err
+		}
+	}
+	c.Enabled = c.from != "" && (c.transport != nil || addr != "")
+	return c, nil
+}
+
+// SetRetries задаёт, сколько раз повторять отправку письма после временной ошибки: ответа SMTP 4xx
+// или HTTP 429 и 5xx от API провайдера (по умолчанию 0 — ошибка возвращается сразу). Пауза перед
+// повтором — DefaultRetryDelay, умноженная на номер повтора. Ошибки сети не повторяются: письмо
+// могло уйти.
+func (c *Client) SetRetries(n int) {
+	c.retries = max(n, 0)
+}
+
+// SetDeliveryLog подключает журнал, в который записывается каждая попытка отправки.
+func (c *Client) SetDeliveryLog(log delivery.DeliveryLog) {
+	c.deliveryLog = log
+}
+
+// Close перестаёт принимать новые отправки и ждёт завершения начатых до истечения ctx.
+// Отправки после Close возвращают notify.ErrClosed.
+func (c *Client) Close(ctx context.Context) error {
+	return c.inflight.Close(ctx)
+}
+
+// SetSuppressionStore подключает список подавления: письма на заблокированные адреса не отправляются.
+func (c *Client) SetSuppressionStore(store suppression.Store) {
+	c.suppression = store
+}
+
+// SetUnsubscribeSigner включает заголовки одношаговой отписки (RFC 8058) для массовых рассылок.
+func (c *Client) SetUnsubscribeSigner(signer *unsubscribe.Signer) {
+	c.unsubscribe = signer
+}
+
+// SetTracker включает отслеживание открытий и переходов по ссылкам в письмах с HTML-версией:
+// в HTML встраивается пиксель, а ссылки заменяются адресами tracking.Handler. Действия получателя
+// записываются в журнал доставки по идентификатору попытки. nil отключает отслеживание.
+func (c *Client) SetTracker(t *tracking.Tracker) {
+	c.tracker = t
+}
+
+// SetTransport переключает отправку с SMTP на HTTP API провайдера (см. providers.New).
+// nil возвращает отправку через SMTP.
+func (c *Client) SetTransport(t providers.EmailTransport) {
+	c.transport = t
+	if t != nil {
+		c.rate.SetLimit(rate.Limit(20))
+		c.rate.SetBurst(5)
+	} else {
+		c.rate.SetLimit(rate.Every(2 * time.Second))
+		c.rate.SetBurst(1)
+	}
+}
+
+// SetAuth задаёт логин и пароль SMTP (PLAIN). Можно вызывать во время работы — например, после ротации
+// пароля в хранилище секретов: новые соединения авторизуются с новым паролем.
+func (c *Client) SetAuth(user, password string) error {
+	host, _, err := net.SplitHostPort(c.url)
+	if err != nil {
+		return fmt.Errorf("некорректный адрес SMTP-сервера %q: %w", c.url, err)
+	}
+	c.authMu.Lock()
+	c.auth = smtp.PlainAuth("", user, password, host)
+	c.authMu.Unlock()
+	return nil
+}
+
+// SetLimiter заменяет лимит отправок клиента, зависящий от транспорта. Распределённый лимитер
+// нужен, если один почтовый ящик или аккаунт провайдера используют несколько экземпляров сервиса.
+func (c *Client) SetLimiter(l notify.Limiter) {
+	c.limiter = l
+}
+
+// Attach кодирует вложение для MessageOptions.Attachments, переиспользуя уже закодированное,
+// если такое же вложение отправлялось недавно. Пустой MIME-тип определяется по содержимому.
+// Предел SetAttachmentPolicy проверяется при отправке; сжатие и разбиение на части выполняет AttachFiles.
+func (c *Client) Attach(f attachment.File) *attachment.Encoded {
+	return c.attachments.Encode(f.Sniff())
 }
 
-// NewClient создаёт и возвращает Email клиента.
-func NewClient(cfg *config.Config, logger *slog.Logger) *Client {
-	url := fmt.Sprintf("%s:%s", cfg.EmailHost, cfg.EmailPort)
+// AttachFiles кодирует вложения как Attach, применяя политику SetAttachmentPolicy: файлы больше предела
+// отклоняются с *attachment.SizeError, сжимаются или разбиваются на части.
+func (c *Client) AttachFiles(files ...attachment.File) ([]*attachment.Encoded, error) {
+	out := make([]*attachment.Encoded, 0, len(files))
+	for _, f := range files {
+		parts, err := c.attachLimit.Apply(f)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range parts {
+			out = append(out, c.attachments.Encode(p))
+		}
+	}
+	return out, nil
+}
 
-	return &Client{
-		auth:     smtp.PlainAuth("", cfg.EmailUser, cfg.EmailPassword, cfg.EmailHost),
-		url:      url,
-		from:     cfg.EmailUser,
-		fromName: cfg.EmailFromName,
-		logger:   logger,
-		Enabled:  cfg.IsEmailEnabled(),
+// SetAttachmentPolicy задаёт предел размера одного вложения до кодирования в base64 и действие для больших:
+// отклонить, сжать или разбить на части. Без политики размер вложений не ограничен, но письмо больше
+// предела SMTP-сервера (расширение SIZE) отклоняется до отправки с *attachment.SizeError.
+// Вызывается при настройке, до начала отправок.
+func (c *Client) SetAttachmentPolicy(p attachment.Policy) {
+	c.attachLimit = p
+}
+
+// checkAttachments проверяет вложения письма по пределу SetAttachmentPolicy.
+func (c *Client) checkAttachments(options MessageOptions) error {
+	limit := c.attachLimit.MaxSize
+	if limit <= 0 {
+		return nil
+	}
+	for _, f := range options.Attachments {
+		if size := int64(len(f.Data)); size > limit {
+			return &attachment.SizeError{Name: f.Name, Size: size, Limit: limit}
+		}
 	}
+	return nil
 }
 
 func encodeSubject(subject string) string {
 	return mime.BEncoding.Encode("utf-8", subject)
 }
 
-// formatMessage формирует SMTP-сообщение из входных данных.
-func (c *Client) formatMessage(to, subject, body string) []byte {
-	encodedName := mime.BEncoding.Encode("utf-8", c.fromName)
-	fromHeader := fmt.Sprintf("%s <%s>", encodedName, c.from)
+// headerValue убирает переводы строк из значения заголовка, чтобы исключить подмену заголовков.
+func headerValue(v string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(v)
+}
 
-	subjectHeader := mime.BEncoding.Encode("utf-8", subject)
+// validate проверяет адреса, заданные для отдельного письма.
+func (o MessageOptions) validate() error {
+	for _, addr := range []struct{ name, value string }{{"From", o.From}, {"Reply-To", o.ReplyTo}} {
+		if addr.value == "" {
+			continue
+		}
+		if parsed, err := mail.ParseAddress(addr.value); err != nil || parsed.Address != addr.value {
+			return fmt.Errorf("некорректный адрес %s: %q", addr.name, addr.value)
+		}
+	}
+	if len(o.Inline) > 0 && o.HTML == "" {
+		return fmt.Errorf("встроенные картинки используются только в HTML-версии письма")
+	}
+	for cid := range o.Inline {
+		if cid == "" || strings.ContainsAny(cid, "<>\"\r\n\t ") {
+			return fmt.Errorf("некорректный Content-ID картинки: %q", cid)
+		}
+	}
+	return nil
+}
 
-	return []byte(fmt.Sprintf(
-		"From: %s\r\nTo: %s\r\nSubject: %s\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n%s",
-		fromHeader, to, subjectHeader, body,
-	))
+// sender возвращает адрес и имя отправителя письма: заданные в options или клиента.
+// Конверт SMTP (MAIL FROM) всегда остаётся от адреса клиента, под которым выполнен вход.
+func (c *Client) sender(options MessageOptions) (string, string) {
+	from, name := c.from, c.fromName
+	if options.From != "" {
+		from = options.From
+	}
+	if options.FromName != "" {
+		name = options.FromName
+	}
+	return from, name
 }
 
 // SendText отправляет одно текстовое сообщение на email.
 func (c *Client) SendText(options MessageOptions) error {
+	return c.sendText(context.Background(), options)
+}
+
+// Channel возвращает имя канала для notify.Registry.
+func (c *Client) Channel() string {
+	return Channel
+}
+
+// Capabilities возвращает возможности канала: тема и вложения без ограничения длины.
+// Через notify.Sender письма отправляются в text/plain, кроме уведомлений (notify.Notification);
+// HTML задаётся в MessageOptions.HTML.
+func (c *Client) Capabilities() notify.Capabilities {
+	return notify.Capabilities{Channel: Channel, Subject: true, Attachments: true}
+}
+
+// Send реализует notify.Sender: msg.To должен содержать email получателя.
+//
+// Если подключена подпись ссылок отписки, письма рассылок (с Campaign) и письма с категорией получают
+// заголовки List-Unsubscribe и List-Unsubscribe-Post по RFC 8058; ссылка отписывает от категории,
+// а без неё — от всех рассылок (см. unsubscribe.TopicHandler).
+//
+// Уведомление из msg.Notification отправляется письмом с HTML-версией по RenderNotification.
+func (c *Client) Send(ctx context.Context, msg notify.Message) error {
+	ctx = delivery.WithTemplate(ctx, msg.Template)
+	options := MessageOptions{Subject: msg.Subject, Body: msg.Text}
+	if msg.Notification != nil {
+		options = RenderNotification(*msg.Notification)
+	}
+	options.ID = msg.ID
+	options.To = msg.To
+	options.UserID = msg.UserID
+	options.Campaign = msg.Campaign
+	options.List = msg.Category
+	if c.unsubscribe != nil && (msg.Category != "" || msg.Campaign != "") {
+		options.Headers = c.unsubscribe.Headers(msg.To, msg.Category)
+	}
+	return c.sendText(ctx, options)
+}
+
+// SendMessage отправляет одно письмо и возвращает его Message-ID и Date — по ним письмо находится
+// в логах почтового сервера и в жалобах получателей.
+func (c *Client) SendMessage(ctx context.Context, options MessageOptions) (SendResult, error) {
+	if err := options.validate(); err != nil {
+		return SendResult{}, err
+	}
+	if err := c.checkAttachments(options); err != nil {
+		return SendResult{}, err
+	}
+	to, err := c.checkAddress(ctx, options.To)
+	if err != nil {
+		return SendResult{}, err
+	}
+	options.To = to
+	// Message-ID строится из идентификатора попытки, поэтому письмо связано с записью журнала доставки
+	if options.ID == "" {
+		options.ID = uuid.New().String()
+	}
+	if c.tracker != nil && options.HTML != "" {
+		options.HTML = c.tracker.Rewrite(options.HTML, options.ID)
+	}
+
+	msg := c.newMessage(options)
+	res := SendResult{ID: options.ID, MessageID: msg.id, Date: msg.date}
+	err = c.send(ctx, options, func(ctx context.Context) error {
+		if c.transport != nil {
+			providerID, err := c.sendAPI(ctx, options, msg)
+			res.ProviderID = providerID
+			return err
+		}
+		return c.deliver(ctx, c.from, options.To, msg)
+	})
+	if err == nil {
+		c.saveSent(ctx, options.ID, msg)
+	}
+	return res, err
+}
+
+// sendText отправляет письмо с учётом контекста запроса.
+func (c *Client) sendText(ctx context.Context, options MessageOptions) error {
+	_, err := c.SendMessage(ctx, options)
+	return err
+}
+
+// SendRaw отправляет письмо, уже собранное в формате MIME, без изменений — например, сформированное
+// другой системой шаблонов.
+//
+// Нужен для возможностей писем, которых нет в MessageOptions (свои MIME-части, подписи).
+// Проверка адреса и списка подавления, лимиты, Close, журнал доставки и папка отправленных работают
+// так же, как для SendText.
+// С несколькими получателями (To и Recipients) письмо уходит каждому отдельной попыткой со своей записью
+// в журнале, а ошибки по получателям объединяются; ID при этом генерируется для каждой попытки.
+// Через HTTP API письмо уходит, только если провайдер принимает MIME (providers.RawTransport).
+func (c *Client) SendRaw(ctx context.Context, options RawOptions) error {
+	from := c.from
+	if options.From != "" {
+		normalized, err := ValidateAddress(options.From)
+		if err != nil {
+			return fmt.Errorf("адрес конверта: %w", err)
+		}
+		from = normalized
+	}
+
+	recipients := options.Recipients
+	if options.To != "" {
+		recipients = append([]string{options.To}, recipients...)
+	}
+	if len(recipients) == 0 {
+		return fmt.Errorf("не указан получатель письма")
+	}
+	id := options.ID
+	if len(recipients) > 1 {
+		id = ""
+	}
+
+	var errs []error
+	for _, to := range recipients {
+		if err := c.sendRaw(ctx, id, from, to, options.UserID, options.Message); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	// Копия в папке отправленных одна на всех получателей
+	if len(errs) < len(recipients) {
+		c.saveSent(ctx, id, bytes.NewReader(options.Message))
+	}
+	return errors.Join(errs...)
+}
+
+// sendRaw отправляет готовое письмо одному получателю to от адреса конверта from.
+func (c *Client) sendRaw(ctx context.Context, id, from, to, userID string, message []byte) error {
+	to, err := c.checkAddress(ctx, to)
+	if err != nil {
+		return err
+	}
+	// В журнал попадает хэш исходного письма
+	logged := MessageOptions{ID: id, To: to, UserID: userID, Body: string(message)}
+	return c.send(ctx, logged, func(ctx context.Context) error {
+		if c.transport == nil {
+			return c.deliver(ctx, from, to, bytes.NewReader(message))
+		}
+		raw, ok := c.transport.(providers.RawTransport)
+		if !ok {
+			return fmt.Errorf("%s: %w", c.transport.Name(), ErrRawUnsupported)
+		}
+		res, err := raw.SendRaw(ctx, from, to, message)
+		if err != nil {
+			return err
+		}
+		c.logger.Debug("письмо принято провайдером", "provider", res.Provider, "message_id", res.MessageID, "to", to)
+		return nil
+	})
+}
+
+// send проверяет клиента и список подавления, выполняет отправку deliver и пишет попытку в журнал.
+func (c *Client) send(ctx context.Context, options MessageOptions, deliver func(context.Context) error) error {
 	if !c.Enabled {
 		return fmt.Errorf("email-отправка отключена: конфигурация недоступна")
 	}
+	// Подавленный адрес не должен занимать лимиты и слоты отправки
+	if c.suppression != nil {
+		suppressed, err := c.suppression.IsSuppressed(ctx, Channel, options.To, options.List)
+		if err != nil {
+			c.logger.Error("не удалось проверить список подавления", "to", options.To, "error", err)
+		}
+		if suppressed {
+			c.logger.Info("адрес в списке подавления, письмо не отправляется", "to", options.To, "list", options.List)
+			return fmt.Errorf("%s: %w", options.To, ErrSuppressed)
+		}
+	}
 
-	msg := c.formatMessage(options.To, options.Subject, options.Body)
-	err := smtp.SendMail(c.url, c.auth, c.from, []string{options.To}, msg)
+	release, err := c.domains.acquire(ctx, options.To)
 	if err != nil {
-		return fmt.Errorf("ошибка отправки на %s: %w", options.To, err)
+		c.logger.Error("лимит домена не пропустил", "to", options.To, "error", err)
+		return err
+	}
+	defer release()
+	if err := c.wait(ctx, options.To); err != nil {
+		return err
+	}
+	if err := c.inflight.Acquire(); err != nil {
+		return err
+	}
+	defer c.inflight.Release()
+
+	ctx, span := tracing.Start(ctx, Channel, options.To)
+	started := time.Now()
+	err = c.retry(ctx, options.To, deliver)
+	tracing.End(span, err)
+	if err != nil {
+		err = fmt.Errorf("ошибка отправки на %s: %w", options.To, err)
+	}
+	c.logDelivery(ctx, options, started, err)
+	return err
+}
+
+// retry выполняет deliver и повторяет её после временных ошибок (см. SetRetries).
+func (c *Client) retry(ctx context.Context, to string, deliver func(context.Context) error) error {
+	err := deliver(ctx)
+	for attempt := 1; attempt <= c.retries && temporary(err); attempt++ {
+		c.logger.Warn("временная ошибка отправки письма, повтор", "to", to, "attempt", attempt, "error", err)
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(time.Duration(attempt) * c.retryDelay):
+		}
+		err = deliver(ctx)
+	}
+	return err
+}
+
+// temporary сообщает, что ошибка err временная и письмо не было принято: SMTPError или
+// providers.APIError с Temporary() == true.
+func temporary(err error) bool {
+	var temp interface{ Temporary() bool }
+	return errors.As(err, &temp) && temp.Temporary()
+}
+
+// wait ждёт разрешения лимита клиента на отправку письма to.
+// Все вызовы клиента, включая параллельные рассылки, делят один бюджет.
+func (c *Client) wait(ctx context.Context, to string) error {
+	var limiter notify.Limiter = c.rate
+	if c.limiter != nil {
+		limiter = c.limiter
+	}
+	if err := limiter.Wait(ctx); err != nil {
+		c.logger.Error("лимитер не пропустил", "to", to, "error", err)
+		return err
 	}
 	return nil
 }
 
-// SendMessaging отправляет письмо нескольким получателям с rate limit.
+// sendAPI отправляет письмо через транспорт провайдера и возвращает идентификатор письма у провайдера.
+// Провайдеры собирают письмо сами, поэтому Message-ID передаётся им заголовком.
+func (c *Client) sendAPI(ctx context.Context, options MessageOptions, msg *message) (string, error) {
+	headers := make(map[string]string, len(options.Headers)+2)
+	for name, value := range options.Headers {
+		headers[name] = value
+	}
+	if options.Campaign != "" {
+		headers[CampaignHeader] = headerValue(options.Campaign)
+	}
+	headers["Message-ID"] = "<" + msg.id + ">"
+
+	from, fromName := c.sender(options)
+	res, err := c.transport.Send(ctx, providers.Message{
+		ID:          options.ID,
+		From:        from,
+		FromName:    fromName,
+		ReplyTo:     options.ReplyTo,
+		To:          options.To,
+		Subject:     options.Subject,
+		Text:        options.Body,
+		HTML:        options.HTML,
+		Headers:     headers,
+		Attachments: options.Attachments,
+		Inline:      options.Inline,
+		Raw:         msg,
+	})
+	if err != nil {
+		return "", err
+	}
+	c.logger.Debug("письмо принято провайдером", "provider", res.Provider, "message_id", res.MessageID, "to", options.To)
+	return res.MessageID, nil
+}
+
+// logDelivery записывает попытку отправки в журнал доставки, если он подключён.
+func (c *Client) logDelivery(ctx context.Context, options MessageOptions, started time.Time, sendErr error) {
+	delivery.LogAttempt(ctx, c.deliveryLog, c.logger, delivery.Record{
+		ID:          options.ID,
+		Channel:     Channel,
+		UserID:      options.UserID,
+		Recipient:   options.To,
+		MessageHash: delivery.Hash(options.Subject, options.Body),
+		CreatedAt:   started,
+	}, sendErr)
+}
+
+// SendMessaging отправляет письмо нескольким получателям с rate limit и возвращает результаты
+// в порядке получателей.
 func (c *Client) SendMessaging(options SendingOptions) []EmailResponse {
-	if !c.Enabled {
-		c.logger.Warn("отправка email отключена: возвращаем заглушку")
-		results := make([]EmailResponse, 0, len(options.Recipients))
-		for _, to := range options.Recipients {
-			results = append(results, EmailResponse{
-				To:    to,
-				Error: fmt.Errorf("email-отправка отключена"),
-			})
+	return c.SendMessagingWithProgress(context.Background(), options, nil)
+}
+
+// SendMessagingWithProgress работает как SendMessaging, но после каждой отправки вызывает onProgress
+// с её результатом и текущими счётчиками. onProgress вызывается последовательно из одной горутины.
+func (c *Client) SendMessagingWithProgress(ctx context.Context, options SendingOptions, onProgress func(EmailResponse, notify.Progress)) []EmailResponse {
+	results := make([]EmailResponse, 0, len(options.Recipients))
+	progress := notify.Progress{Total: len(options.Recipients)}
+	if options.RecipientSeq != nil {
+		progress.Total = 0
+	}
+
+	for res := range c.SendMessagingStream(ctx, options) {
+		count(&progress, res)
+		results = append(results, res)
+		if onProgress != nil {
+			onProgress(res, progress)
 		}
-		return results
 	}
+	// Результаты приходят по мере завершения отправок, а возвращаются в порядке получателей
+	slices.SortFunc(results, func(a, b EmailResponse) int { return cmp.Compare(a.Index, b.Index) })
+	return results
+}
 
-	limiter := rate.NewLimiter(rate.Every(2*time.Second), 1)
+// Skipped сообщает, что письмо не отправлялось: адрес некорректен или находится в списке подавления.
+func (r EmailResponse) Skipped() bool {
+	return errors.Is(r.Error, ErrInvalidAddress) || errors.Is(r.Error, ErrSuppressed)
+}
 
-	var (
-		results = make([]EmailResponse, 0, len(options.Recipients))
-		mu      sync.Mutex
-		wg      sync.WaitGroup
-	)
+// Summary возвращает итоги рассылки по её результатам: сколько писем отправлено, завершилось ошибкой
+// и пропущено.
+func Summary(results []EmailResponse) notify.Progress {
+	p := notify.Progress{Total: len(results)}
+	for _, res := range results {
+		count(&p, res)
+	}
+	return p
+}
 
-	for _, to := range options.Recipients {
-		wg.Add(1)
+// count учитывает результат res в счётчиках p.
+func count(p *notify.Progress, res EmailResponse) {
+	switch {
+	case res.Skipped():
+		p.Skipped++
+	case res.Error != nil:
+		p.Failed++
+	default:
+		p.Sent++
+	}
+}
 
-		go func(to string) {
-			defer wg.Done()
+// maxBulkInFlight — предел одновременных отправок рассылки. Остальные получатели ждут своей очереди
+// и не читаются из RecipientSeq раньше времени.
+const maxBulkInFlight = 64
 
-			if err := limiter.Wait(context.Background()); err != nil {
-				c.logger.Error("лимитер не пропустил", "to", to, "error", err)
-				mu.Lock()
-				results = append(results, EmailResponse{To: to, Error: err})
-				mu.Unlock()
+// recipients возвращает всех получателей рассылки: Recipients, затем RecipientSeq.
+func (o SendingOptions) recipients() iter.Seq[string] {
+	return func(yield func(string) bool) {
+		for _, to := range o.Recipients {
+			if !yield(to) {
 				return
 			}
+		}
+		if o.RecipientSeq != nil {
+			o.RecipientSeq(yield)
+		}
+	}
+}
+
+// SendMessagingStream запускает рассылку и возвращает канал, в который попадает результат
+// каждой отправки по мере её завершения. Канал закрывается после обработки всех получателей.
+//
+// Одновременно выполняется не больше maxBulkInFlight отправок. Получатели из RecipientSeq читаются
+// по мере освобождения мест, а если результаты не забираются из канала, чтение приостанавливается.
+// Отмена ctx прерывает ожидание лимитера: оставшиеся получатели получат ошибку контекста.
+// Заведомо некорректные адреса не отправляются: их результат сразу содержит ошибку ErrInvalidAddress.
+func (c *Client) SendMessagingStream(ctx context.Context, options SendingOptions) <-chan EmailResponse {
+	size := len(options.Recipients)
+	if options.RecipientSeq != nil {
+		size = maxBulkInFlight
+	}
+	out := make(chan EmailResponse, size)
 
-			msg := MessageOptions{
-				To:      to,
-				Subject: options.Subject,
-				Body:    options.Body,
+	if !c.Enabled {
+		c.logger.Warn("отправка email отключена: возвращаем заглушку")
+		go func() {
+			defer close(out)
+			i := 0
+			for to := range options.recipients() {
+				out <- EmailResponse{
+					To:    to,
+					Index: i,
+					Error: fmt.Errorf("email-отправка отключена"),
+				}
+				i++
 			}
+		}()
+		return out
+	}
 
-			err := c.SendText(msg)
+	// Вложения кодируются один раз и переиспользуются во всех письмах рассылки
+	files, filesErr := c.AttachFiles(options.Attachments...)
 
-			if err != nil {
-				c.logger.Error("не удалось отправить email", "to", to, "error", err)
-			}
+	var inline map[string]*attachment.Encoded
+	if len(options.Inline) > 0 {
+		inline = make(map[string]*attachment.Encoded, len(options.Inline))
+		for cid, f := range options.Inline {
+			inline[cid] = c.attachments.Encode(f.Sniff())
+		}
+	}
 
-			mu.Lock()
-			results = append(results, EmailResponse{To: to, Error: err})
-			mu.Unlock()
-		}(to)
+	send := func(i int, to string) EmailResponse {
+		if filesErr != nil {
+			return EmailResponse{To: to, Index: i, Error: filesErr}
+		}
+		msg := MessageOptions{
+			To:       to,
+			Subject:  options.Subject,
+			Body:     options.Body,
+			HTML:     options.HTML,
+			Campaign: options.Campaign,
+			List:     options.List,
+
+			Attachments: files,
+			Inline:      inline,
+		}
+		if c.unsubscribe != nil {
+			msg.Headers = c.unsubscribe.Headers(to, options.List)
+		}
+
+		res, err := c.SendMessage(ctx, msg)
+
+		switch {
+		case errors.Is(err, ErrSuppressed):
+			// О пропуске подавленного адреса уже сообщил send
+			c.logger.Debug("подавленный адрес пропущен", "to", to)
+		case err != nil:
+			c.logger.Error("не удалось отправить email", "to", to, "error", err)
+		}
+
+		resp := EmailResponse{To: to, Index: i, MessageID: res.MessageID, Error: err}
+		errors.As(err, &resp.SMTP)
+		return resp
 	}
 
-	wg.Wait()
-	return results
+	go func() {
+		var wg sync.WaitGroup
+		slots := make(chan struct{}, maxBulkInFlight)
+		i := -1
+		for to := range options.recipients() {
+			i++
+			// Заведомо некорректные адреса пропускаются без ожидания лимита и записи в журнал доставки
+			if _, err := ValidateAddress(to); err != nil {
+				c.logger.Warn("некорректный адрес пропущен", "to", to, "error", err)
+				out <- EmailResponse{To: to, Index: i, Error: err}
+				continue
+			}
+			slots <- struct{}{}
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				out <- send(i, to)
+				<-slots
+			}(i)
+		}
+		wg.Wait()
+		close(out)
+	}()
+	return out
 }
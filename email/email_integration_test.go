@@ -9,6 +9,7 @@ import (
 
 	"github.com/epheer/notephee/config"
 	"github.com/epheer/notephee/email"
+	"github.com/epheer/notephee/envclient"
 )
 
 func TestEmailIntegration(t *testing.T) {
@@ -20,7 +21,7 @@ func TestEmailIntegration(t *testing.T) {
 
 	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
 	cfg := config.Cfg
-	client := email.NewClient(cfg, logger)
+	client := envclient.Email(cfg, logger)
 	if !client.Enabled {
 		t.Skip("Email отправка отключена в конфиге, пропускаем тест")
 	}
@@ -0,0 +1,181 @@
+package feedback
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"time"
+
+	"github.com/epheer/notephee/email"
+)
+
+// Report — разобранный отчёт о жалобе в формате ARF (RFC 5965).
+type Report struct {
+	FeedbackType     string      // Тип отзыва: abuse, fraud, virus, not-spam, other
+	UserAgent        string      // Система, сформировавшая отчёт
+	Version          string      // Версия формата отчёта
+	OriginalMailFrom string      // Отправитель исходного письма
+	OriginalRcptTo   []string    // Получатели исходного письма, пожаловавшиеся на него
+	ArrivalDate      time.Time   // Время получения исходного письма
+	ReportedDomain   string      // Домен, на который поступила жалоба
+	SourceIP         string      // IP, с которого было отправлено письмо
+	MessageID        string      // Message-ID исходного письма
+	Campaign         string      // Рассылка, из которой было письмо (заголовок X-Notephee-Campaign)
+	OriginalHeaders  mail.Header // Заголовки исходного письма (если приложены)
+}
+
+// IsComplaint сообщает, является ли отчёт жалобой (а не, например, отметкой «не спам»).
+func (r *Report) IsComplaint() bool {
+	return r.FeedbackType != "not-spam"
+}
+
+// Recipients возвращает адреса пожаловавшихся получателей.
+//
+// Если Original-Rcpt-To отсутствует, используется заголовок To исходного письма.
+func (r *Report) Recipients() []string {
+	if len(r.OriginalRcptTo) > 0 {
+		return r.OriginalRcptTo
+	}
+	if r.OriginalHeaders == nil {
+		return nil
+	}
+
+	list, err := r.OriginalHeaders.AddressList("To")
+	if err != nil {
+		return nil
+	}
+	out := make([]string, 0, len(list))
+	for _, a := range list {
+		out = append(out, a.Address)
+	}
+	return out
+}
+
+// Parse разбирает письмо с ARF-отчётом.
+//
+// Возвращает ошибку, если письмо не является multipart/report с report-type=feedback-report.
+func Parse(r io.Reader) (*Report, error) {
+	msg, err := mail.ReadMessage(r)
+	if err != nil {
+		return nil, fmt.Errorf("некорректное письмо: %w", err)
+	}
+
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil {
+		return nil, fmt.Errorf("некорректный Content-Type: %w", err)
+	}
+	if mediaType != "multipart/report" || !strings.EqualFold(params["report-type"], "feedback-report") {
+		return nil, fmt.Errorf("письмо не является ARF-отчётом: %s", mediaType)
+	}
+
+	report := &Report{}
+	found := false
+
+	parts := multipart.NewReader(msg.Body, params["boundary"])
+	for {
+		part, err := parts.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("ошибка чтения части отчёта: %w", err)
+		}
+
+		body, err := readPart(part)
+		if err != nil {
+			return nil, err
+		}
+
+		partType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+		switch partType {
+		case "message/feedback-report":
+			if err := report.parseFields(body); err != nil {
+				return nil, err
+			}
+			found = true
+		case "message/rfc822", "text/rfc822-headers":
+			report.parseOriginal(body)
+		}
+	}
+
+	if !found {
+		return nil, fmt.Errorf("в отчёте нет части message/feedback-report")
+	}
+	return report, nil
+}
+
+// readPart читает тело части с учётом Content-Transfer-Encoding: base64
+// (quoted-printable декодируется multipart.Reader автоматически).
+func readPart(part *multipart.Part) ([]byte, error) {
+	var r io.Reader = part
+	if strings.EqualFold(part.Header.Get("Content-Transfer-Encoding"), "base64") {
+		r = base64.NewDecoder(base64.StdEncoding, part)
+	}
+	body, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения части отчёта: %w", err)
+	}
+	return body, nil
+}
+
+// parseFields разбирает машиночитаемую часть отчёта.
+func (r *Report) parseFields(body []byte) error {
+	fields, err := readHeaderBlock(body)
+	if err != nil {
+		return fmt.Errorf("некорректная часть message/feedback-report: %w", err)
+	}
+
+	r.FeedbackType = strings.ToLower(fields.Get("Feedback-Type"))
+	r.UserAgent = fields.Get("User-Agent")
+	r.Version = fields.Get("Version")
+	r.OriginalMailFrom = trimAddress(fields.Get("Original-Mail-From"))
+	r.ReportedDomain = fields.Get("Reported-Domain")
+	r.SourceIP = fields.Get("Source-IP")
+	for _, rcpt := range fields.Values("Original-Rcpt-To") {
+		r.OriginalRcptTo = append(r.OriginalRcptTo, trimAddress(rcpt))
+	}
+	if date := fields.Get("Arrival-Date"); date != "" {
+		if t, err := mail.ParseDate(date); err == nil {
+			r.ArrivalDate = t
+		}
+	}
+	return nil
+}
+
+// parseOriginal разбирает заголовки исходного письма для атрибуции рассылки.
+func (r *Report) parseOriginal(body []byte) {
+	headers, err := readHeaderBlock(body)
+	if err != nil {
+		return
+	}
+	r.OriginalHeaders = mail.Header(headers)
+	r.MessageID = strings.Trim(headers.Get("Message-Id"), "<>")
+	r.Campaign = headers.Get(email.CampaignHeader)
+}
+
+func readHeaderBlock(body []byte) (textproto.MIMEHeader, error) {
+	if !bytes.HasSuffix(body, []byte("\n\n")) && !bytes.HasSuffix(body, []byte("\r\n\r\n")) {
+		body = append(body, "\r\n\r\n"...)
+	}
+	headers, err := textproto.NewReader(bufio.NewReader(bytes.NewReader(body))).ReadMIMEHeader()
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	return headers, nil
+}
+
+// trimAddress убирает угловые скобки и префикс rfc822; у адреса.
+func trimAddress(addr string) string {
+	addr = strings.TrimSpace(addr)
+	if i := strings.Index(addr, ";"); i >= 0 {
+		addr = addr[i+1:]
+	}
+	return strings.Trim(strings.TrimSpace(addr), "<>")
+}
@@ -0,0 +1,73 @@
+package feedback_test
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/epheer/notephee/email/feedback"
+	"github.com/epheer/notephee/events"
+	"github.com/epheer/notephee/suppression"
+)
+
+const arfReport = "From: <abuse@mail.example>\r\n" +
+	"To: <fbl@notephee.example>\r\n" +
+	"Subject: FW: Новости\r\n" +
+	"MIME-Version: 1.0\r\n" +
+	"Content-Type: multipart/report; report-type=feedback-report; boundary=\"part\"\r\n" +
+	"\r\n" +
+	"--part\r\n" +
+	"Content-Type: text/plain; charset=utf-8\r\n" +
+	"\r\n" +
+	"This is an email abuse report.\r\n" +
+	"--part\r\n" +
+	"Content-Type: message/feedback-report\r\n" +
+	"\r\n" +
+	"Feedback-Type: abuse\r\n" +
+	"User-Agent: ExampleFBL/1.0\r\n" +
+	"Version: 1\r\n" +
+	"Original-Mail-From: <news@notephee.example>\r\n" +
+	"Original-Rcpt-To: <User@mail.example>\r\n" +
+	"Arrival-Date: Thu, 8 Mar 2005 14:00:00 EDT\r\n" +
+	"\r\n" +
+	"--part\r\n" +
+	"Content-Type: text/rfc822-headers\r\n" +
+	"\r\n" +
+	"From: news@notephee.example\r\n" +
+	"To: user@mail.example\r\n" +
+	"Message-ID: <42@notephee.example>\r\n" +
+	"X-Notephee-Campaign: spring-sale\r\n" +
+	"\r\n" +
+	"--part--\r\n"
+
+func TestProcessComplaint(t *testing.T) {
+	store := suppression.NewMemoryStore()
+	bus := events.NewBus()
+
+	var got []events.Event
+	bus.Subscribe(func(e events.Event) { got = append(got, e) })
+
+	p := feedback.NewProcessor(store, bus, slog.Default())
+	report, err := p.Process(context.Background(), strings.NewReader(arfReport))
+	if err != nil {
+		t.Fatalf("Ошибка Process: %v", err)
+	}
+
+	if report.FeedbackType != "abuse" || report.MessageID != "42@notephee.example" {
+		t.Fatalf("неверно разобран отчёт: %+v", report)
+	}
+	if ok, _ := store.IsSuppressed(context.Background(), "email", "user@mail.example", ""); !ok {
+		t.Fatal("пожаловавшийся получатель не заблокирован")
+	}
+	if len(got) != 1 || got[0].Type != events.Complaint || got[0].Campaign != "spring-sale" {
+		t.Fatalf("неверные события: %+v", got)
+	}
+}
+
+func TestParseRejectsNonARF(t *testing.T) {
+	msg := "Content-Type: text/plain\r\n\r\nhello"
+	if _, err := feedback.Parse(strings.NewReader(msg)); err == nil {
+		t.Fatal("обычное письмо разобрано как ARF-отчёт")
+	}
+}
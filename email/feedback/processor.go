@@ -0,0 +1,117 @@
+package feedback
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/epheer/notephee/email"
+	"github.com/epheer/notephee/events"
+	"github.com/epheer/notephee/suppression"
+)
+
+// Mailbox — источник писем, поступающих на адрес обратной связи (FBL).
+//
+// Реализация отвечает за получение (IMAP, POP3, Maildir и т.д.) и удаление уже выданных писем.
+type Mailbox interface {
+	// Fetch возвращает новые письма в сыром виде.
+	Fetch(ctx context.Context) ([][]byte, error)
+}
+
+// Processor разбирает ARF-отчёты, блокирует пожаловавшихся получателей и публикует события.
+type Processor struct {
+	store  suppression.Store // Список подавления
+	bus    *events.Bus       // Шина событий (может быть nil)
+	logger *slog.Logger      // Логгер
+}
+
+// NewProcessor создаёт обработчик жалоб.
+func NewProcessor(store suppression.Store, bus *events.Bus, logger *slog.Logger) *Processor {
+	return &Processor{store: store, bus: bus, logger: logger}
+}
+
+// Process разбирает один отчёт и применяет его.
+//
+// Для жалоб каждый получатель добавляется в список подавления и публикуется событие events.Complaint.
+func (p *Processor) Process(ctx context.Context, r io.Reader) (*Report, error) {
+	report, err := Parse(r)
+	if err != nil {
+		return nil, err
+	}
+	if !report.IsComplaint() {
+		p.logger.Info("получен отчёт без жалобы", "feedback_type", report.FeedbackType)
+		return report, nil
+	}
+
+	for _, rcpt := range report.Recipients() {
+		err := p.store.Suppress(ctx, suppression.Entry{
+			Channel:   email.Channel,
+			Address:   rcpt,
+			Reason:    suppression.ReasonComplaint,
+			CreatedAt: time.Now(),
+		})
+		if err != nil {
+			return report, fmt.Errorf("не удалось заблокировать %s: %w", rcpt, err)
+		}
+
+		p.bus.Publish(events.Event{
+			Type:      events.Complaint,
+			Channel:   email.Channel,
+			Recipient: rcpt,
+			Campaign:  report.Campaign,
+			Data: map[string]string{
+				"feedback_type": report.FeedbackType,
+				"message_id":    report.MessageID,
+				"user_agent":    report.UserAgent,
+			},
+		})
+		p.logger.Info("получатель пожаловался на письмо", "to", rcpt, "campaign", report.Campaign)
+	}
+	return report, nil
+}
+
+// Poll периодически забирает письма из mailbox и обрабатывает их, пока ctx не завершён.
+func (p *Processor) Poll(ctx context.Context, mailbox Mailbox, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		messages, err := mailbox.Fetch(ctx)
+		if err != nil {
+			p.logger.Error("ошибка получения писем обратной связи", "error", err)
+		}
+		for _, raw := range messages {
+			if _, err := p.Process(ctx, bytes.NewReader(raw)); err != nil {
+				p.logger.Warn("не удалось обработать письмо обратной связи", "error", err)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Handler возвращает HTTP-обработчик вебхука: тело POST-запроса — письмо с ARF-отчётом.
+func (p *Processor) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "метод не поддерживается", http.StatusMethodNotAllowed)
+			return
+		}
+
+		if _, err := p.Process(r.Context(), io.LimitReader(r.Body, 10<<20)); err != nil {
+			p.logger.Warn("не удалось обработать ARF-отчёт", "error", err)
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
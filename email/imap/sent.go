@@ -0,0 +1,151 @@
+// Package imap сохраняет копии отправленных писем в папку «Отправленные» почтового ящика по IMAP,
+// чтобы сотрудники видели в обычном почтовом клиенте, что ушло клиенту.
+//
+// Реализована только команда APPEND поверх IMAP4rev1 (RFC 3501) с TLS-соединением (порт 993).
+package imap
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// DefaultFolder — папка отправленных по умолчанию. У Gmail это "[Gmail]/Sent Mail",
+// у Яндекса и Mail.ru — "Sent" или "Отправленные" в зависимости от языка ящика.
+const DefaultFolder = "Sent"
+
+// SentFolder добавляет письма в папку почтового ящика. Каждое письмо — отдельное соединение.
+type SentFolder struct {
+	Addr      string      // Адрес IMAP-сервера вида host:port
+	User      string      // Логин ящика
+	Password  string      // Пароль ящика
+	Folder    string      // Папка отправленных (пусто — DefaultFolder); имена не в ASCII — в modified UTF-7
+	TLSConfig *tls.Config // Настройки TLS (nil — проверка сертификата по имени хоста из Addr)
+}
+
+// NewSentFolder создаёт SentFolder для ящика user на сервере addr.
+func NewSentFolder(addr, user, password, folder string) *SentFolder {
+	return &SentFolder{Addr: addr, User: user, Password: password, Folder: folder}
+}
+
+// Append добавляет письмо msg в формате MIME в папку с флагом \Seen. Одиночные LF в письме заменяются на CRLF,
+// которых требует IMAP. Отмена ctx прерывает соединение.
+func (f *SentFolder) Append(ctx context.Context, msg []byte) error {
+	host, _, err := net.SplitHostPort(f.Addr)
+	if err != nil {
+		return fmt.Errorf("некорректный адрес IMAP-сервера: %w", err)
+	}
+	cfg := f.TLSConfig
+	if cfg == nil {
+		cfg = &tls.Config{ServerName: host}
+	}
+	folder := f.Folder
+	if folder == "" {
+		folder = DefaultFolder
+	}
+	user, err := quote(f.User)
+	if err != nil {
+		return err
+	}
+	password, err := quote(f.Password)
+	if err != nil {
+		return err
+	}
+	mailbox, err := quote(folder)
+	if err != nil {
+		return err
+	}
+
+	d := tls.Dialer{Config: cfg}
+	conn, err := d.DialContext(ctx, "tcp", f.Addr)
+	if err != nil {
+		return err
+	}
+	stop := context.AfterFunc(ctx, func() {
+		_ = conn.Close()
+	})
+	defer stop()
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	s := &session{conn: conn, r: bufio.NewReader(conn)}
+	if line, err := s.r.ReadString('\n'); err != nil {
+		return err
+	} else if !strings.HasPrefix(line, "* OK") {
+		return fmt.Errorf("IMAP-сервер не готов: %s", strings.TrimSpace(line))
+	}
+	if _, err := s.command("LOGIN "+user+" "+password, nil); err != nil {
+		return err
+	}
+
+	msg = crlf(msg)
+	literal := func() error {
+		_, err := conn.Write(append(msg, '\r', '\n'))
+		return err
+	}
+	if _, err := s.command(fmt.Sprintf(`APPEND %s (\Seen) {%d}`, mailbox, len(msg)), literal); err != nil {
+		return err
+	}
+	_, _ = s.command("LOGOUT", nil)
+	return nil
+}
+
+// session — последовательность команд с метками a1, a2 и т.д. в одном соединении.
+type session struct {
+	conn net.Conn
+	r    *bufio.Reader
+	tag  int
+}
+
+// command отправляет команду и читает ответы до помеченного. Ответ «+» на литерал в команде
+// вызывает literal, который отправляет его содержимое.
+func (s *session) command(cmd string, literal func() error) (string, error) {
+	s.tag++
+	tag := fmt.Sprintf("a%d", s.tag)
+	if _, err := fmt.Fprintf(s.conn, "%s %s\r\n", tag, cmd); err != nil {
+		return "", err
+	}
+	for {
+		line, err := s.r.ReadString('\n')
+		if err != nil {
+			return "", err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		switch {
+		case strings.HasPrefix(line, "+") && literal != nil:
+			if err := literal(); err != nil {
+				return "", err
+			}
+			literal = nil
+		case strings.HasPrefix(line, tag+" "):
+			status := strings.TrimPrefix(line, tag+" ")
+			if !strings.HasPrefix(status, "OK") {
+				name, _, _ := strings.Cut(cmd, " ")
+				return "", fmt.Errorf("IMAP %s: %s", name, status)
+			}
+			return status, nil
+		}
+	}
+}
+
+// quote возвращает строку в кавычках IMAP. Переводы строк в строке недопустимы.
+func quote(s string) (string, error) {
+	if strings.ContainsAny(s, "\r\n\x00") {
+		return "", fmt.Errorf("значение IMAP содержит перевод строки")
+	}
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`, nil
+}
+
+// crlf заменяет одиночные LF на CRLF.
+func crlf(msg []byte) []byte {
+	if bytes.Count(msg, []byte("\n")) == bytes.Count(msg, []byte("\r\n")) {
+		return msg
+	}
+	msg = bytes.ReplaceAll(msg, []byte("\r\n"), []byte("\n"))
+	return bytes.ReplaceAll(msg, []byte("\n"), []byte("\r\n"))
+}
@@ -0,0 +1,99 @@
+package imap_test
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/epheer/notephee/email/imap"
+)
+
+// fakeIMAP запускает IMAP-сервер по TLS, который принимает одно соединение и отдаёт в канал
+// команду APPEND и содержимое литерала.
+func fakeIMAP(t *testing.T, login string) (string, *tls.Config, <-chan string) {
+	t.Helper()
+	srv := httptest.NewUnstartedServer(nil)
+	srv.StartTLS()
+	pool := x509.NewCertPool()
+	pool.AddCert(srv.Certificate())
+	serverTLS := srv.TLS.Clone()
+	srv.Close()
+
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", serverTLS)
+	if err != nil {
+		t.Fatalf("Ошибка Listen: %v", err)
+	}
+	t.Cleanup(func() { _ = ln.Close() })
+
+	got := make(chan string, 2)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer func() { _ = conn.Close() }()
+
+		r := bufio.NewReader(conn)
+		_, _ = fmt.Fprint(conn, "* OK IMAP4rev1 ready\r\n")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			tag, cmd, _ := strings.Cut(strings.TrimRight(line, "\r\n"), " ")
+			switch name, _, _ := strings.Cut(cmd, " "); name {
+			case "LOGIN":
+				_, _ = fmt.Fprintf(conn, "%s %s\r\n", tag, login)
+			case "APPEND":
+				size, _ := strconv.Atoi(cmd[strings.LastIndex(cmd, "{")+1 : len(cmd)-1])
+				_, _ = fmt.Fprint(conn, "+ Ready for literal data\r\n")
+				body := make([]byte, size+2)
+				if _, err := io.ReadFull(r, body); err != nil {
+					return
+				}
+				got <- cmd
+				got <- string(body[:size])
+				_, _ = fmt.Fprintf(conn, "* 3 EXISTS\r\n%s OK APPEND completed\r\n", tag)
+			case "LOGOUT":
+				_, _ = fmt.Fprintf(conn, "* BYE\r\n%s OK LOGOUT completed\r\n", tag)
+				return
+			}
+		}
+	}()
+	return ln.Addr().String(), &tls.Config{RootCAs: pool, ServerName: "127.0.0.1"}, got
+}
+
+func TestAppend(t *testing.T) {
+	addr, cfg, got := fakeIMAP(t, "OK LOGIN completed")
+	f := imap.NewSentFolder(addr, "support@example.com", `pa"ss`, "Sent Items")
+	f.TLSConfig = cfg
+
+	if err := f.Append(context.Background(), []byte("Subject: test\nTo: user@example.com\n\nпривет\n")); err != nil {
+		t.Fatalf("Ошибка Append: %v", err)
+	}
+	want := "Subject: test\r\nTo: user@example.com\r\n\r\nпривет\r\n"
+	if cmd := <-got; cmd != fmt.Sprintf(`APPEND "Sent Items" (\Seen) {%d}`, len(want)) {
+		t.Fatalf("неверная команда APPEND: %q", cmd)
+	}
+	if body := <-got; body != want {
+		t.Fatalf("письмо должно уйти с CRLF, получено %q", body)
+	}
+}
+
+func TestAppendLoginFailed(t *testing.T) {
+	addr, cfg, _ := fakeIMAP(t, "NO [AUTHENTICATIONFAILED] invalid credentials")
+	f := imap.NewSentFolder(addr, "support@example.com", "secret", "")
+	f.TLSConfig = cfg
+
+	err := f.Append(context.Background(), []byte("Subject: test\r\n\r\nпривет\r\n"))
+	if err == nil || !strings.Contains(err.Error(), "AUTHENTICATIONFAILED") || strings.Contains(err.Error(), "secret") {
+		t.Fatalf("ожидалась ошибка входа без пароля в тексте, получено %v", err)
+	}
+}
@@ -0,0 +1,205 @@
+package email
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/epheer/notephee/attachment"
+)
+
+// message — письмо, которое пишется в SMTP-поток по частям через WriteTo, без сборки в []byte.
+//
+// Вложения не копируются: в поток пишутся закодированные байты, общие для всех писем рассылки,
+// поэтому многомегабайтный файл не дублируется в памяти на каждого получателя.
+type message struct {
+	id     string                         // Message-ID без угловых скобок
+	date   time.Time                      // Время в заголовке Date
+	header string                         // Заголовки письма, кроме Content-Type
+	body   string                         // Текст письма
+	html   string                         // HTML-версия письма (необязательно)
+	inline map[string]*attachment.Encoded // Картинки для HTML по Content-ID
+	files  []*attachment.Encoded          // Вложения
+}
+
+// newMessage формирует письмо из входных данных.
+func (c *Client) newMessage(options MessageOptions) *message {
+	from, name := c.sender(options)
+	encodedName := mime.BEncoding.Encode("utf-8", name)
+	fromHeader := fmt.Sprintf("%s <%s>", encodedName, from)
+
+	subjectHeader := mime.BEncoding.Encode("utf-8", options.Subject)
+
+	m := &message{
+		id:     messageID(options.ID, from),
+		date:   time.Now(),
+		body:   options.Body,
+		html:   options.HTML,
+		inline: options.Inline,
+		files:  options.Attachments,
+	}
+
+	var header strings.Builder
+	fmt.Fprintf(&header, "Date: %s\r\nMessage-ID: <%s>\r\nMIME-Version: 1.0\r\n", m.date.Format(time.RFC1123Z), m.id)
+	fmt.Fprintf(&header, "From: %s\r\nTo: %s\r\nSubject: %s\r\n", fromHeader, options.To, subjectHeader)
+	if options.ReplyTo != "" {
+		fmt.Fprintf(&header, "Reply-To: %s\r\n", options.ReplyTo)
+	}
+	if options.Campaign != "" {
+		fmt.Fprintf(&header, "%s: %s\r\n", CampaignHeader, headerValue(options.Campaign))
+	}
+	for _, name := range sortedKeys(options.Headers) {
+		fmt.Fprintf(&header, "%s: %s\r\n", name, headerValue(options.Headers[name]))
+	}
+
+	m.header = header.String()
+	return m
+}
+
+// size возвращает оценку размера письма снизу: заголовки, текст и закодированные вложения.
+func (m *message) size() int64 {
+	n := int64(len(m.header) + len(m.body) + len(m.html))
+	for _, f := range m.files {
+		n += int64(len(f.Base64()))
+	}
+	for _, f := range m.inline {
+		n += int64(len(f.Base64()))
+	}
+	return n
+}
+
+// messageID возвращает Message-ID по RFC 5322 из идентификатора попытки id и домена отправителя from.
+func messageID(id, from string) string {
+	domain := "localhost"
+	if _, d, ok := strings.Cut(from, "@"); ok && d != "" {
+		domain = d
+	}
+	if id == "" || strings.ContainsFunc(id, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '.' || r == '_')
+	}) {
+		// Идентификатор, недопустимый в Message-ID, заменяется случайным
+		id = uuid.New().String()
+	}
+	return id + "@" + domain
+}
+
+// WriteTo пишет письмо в w. Без вложений это содержимое письма (см. writeContent), с вложениями —
+// multipart/mixed из содержимого и вложений.
+func (m *message) WriteTo(w io.Writer) (int64, error) {
+	sw := &stickyWriter{w: w}
+
+	sw.string(m.header)
+	if len(m.files) == 0 {
+		m.writeContent(sw)
+		return sw.n, sw.err
+	}
+
+	boundary := newBoundary()
+	sw.string("Content-Type: multipart/mixed; boundary=\"" + boundary + "\"\r\n\r\n")
+
+	sw.string("--" + boundary + "\r\n")
+	m.writeContent(sw)
+	sw.string("\r\n")
+	for _, f := range m.files {
+		sw.string("--" + boundary + "\r\n")
+		writeFile(sw, "attachment", f)
+		sw.string("\r\n")
+		sw.bytes(f.Base64())
+	}
+	sw.string("--" + boundary + "--\r\n")
+	return sw.n, sw.err
+}
+
+// writeContent пишет текст письма: text/plain, а при заданном HTML — multipart/alternative
+// из текстовой и HTML-версии, чтобы клиенты без HTML показали текст.
+func (m *message) writeContent(sw *stickyWriter) {
+	if m.html == "" {
+		sw.string("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+		sw.string(m.body)
+		return
+	}
+
+	boundary := newBoundary()
+	sw.string("Content-Type: multipart/alternative; boundary=\"" + boundary + "\"\r\n\r\n")
+	sw.string("--" + boundary + "\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n")
+	sw.string(m.body)
+	sw.string("\r\n--" + boundary + "\r\n")
+	m.writeHTML(sw)
+	sw.string("\r\n--" + boundary + "--\r\n")
+}
+
+// writeHTML пишет HTML-версию письма: text/html, а со встроенными картинками — multipart/related,
+// в котором картинки доступны HTML по ссылкам cid: и не блокируются как внешнее содержимое.
+func (m *message) writeHTML(sw *stickyWriter) {
+	if len(m.inline) == 0 {
+		sw.string("Content-Type: text/html; charset=utf-8\r\n\r\n")
+		sw.string(m.html)
+		return
+	}
+
+	boundary := newBoundary()
+	sw.string("Content-Type: multipart/related; type=\"text/html\"; boundary=\"" + boundary + "\"\r\n\r\n")
+	sw.string("--" + boundary + "\r\nContent-Type: text/html; charset=utf-8\r\n\r\n")
+	sw.string(m.html)
+	sw.string("\r\n")
+	for _, cid := range sortedKeys(m.inline) {
+		f := m.inline[cid]
+		sw.string("--" + boundary + "\r\n")
+		writeFile(sw, "inline", f)
+		sw.string("Content-ID: <" + cid + ">\r\n\r\n")
+		sw.bytes(f.Base64())
+	}
+	sw.string("--" + boundary + "--\r\n")
+}
+
+// writeFile пишет заголовки части с файлом f, кроме завершающей пустой строки.
+func writeFile(sw *stickyWriter, disposition string, f *attachment.Encoded) {
+	sw.string("Content-Type: " + mime.FormatMediaType(f.MediaType(), map[string]string{"name": f.Name}) + "\r\n")
+	sw.string("Content-Disposition: " + mime.FormatMediaType(disposition, map[string]string{"filename": f.Name}) + "\r\n")
+	sw.string("Content-Transfer-Encoding: base64\r\n")
+}
+
+func newBoundary() string {
+	return "notephee-" + uuid.New().String()
+}
+
+// sortedKeys возвращает ключи m по алфавиту, чтобы письмо собиралось одинаково при каждой отправке.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// stickyWriter считает записанные байты и запоминает первую ошибку, после которой
+// последующие записи пропускаются.
+type stickyWriter struct {
+	w   io.Writer
+	n   int64
+	err error
+}
+
+func (s *stickyWriter) string(v string) {
+	if s.err != nil {
+		return
+	}
+	n, err := io.WriteString(s.w, v)
+	s.n += int64(n)
+	s.err = err
+}
+
+func (s *stickyWriter) bytes(p []byte) {
+	if s.err != nil {
+		return
+	}
+	n, err := s.w.Write(p)
+	s.n += int64(n)
+	s.err = err
+}
@@ -0,0 +1,60 @@
+package email
+
+import (
+	"html"
+	"strings"
+
+	"github.com/epheer/notephee/notify"
+)
+
+// severityColors — цвет полосы над письмом по важности уведомления.
+var severityColors = map[notify.Severity]string{
+	notify.SeverityInfo:     "#2f80ed",
+	notify.SeverityWarning:  "#f2a900",
+	notify.SeverityCritical: "#d93025",
+}
+
+// RenderNotification отображает уведомление письмом: тема из заголовка (с важностью для предупреждений
+// и критичных), текстовая версия из notify.Notification.Text и HTML-версия с цветной полосой важности,
+// таблицей полей Metadata и действиями-кнопками. Весь текст уведомления экранируется.
+// Адрес получателя и поля журнала доставки заполняет вызывающий.
+func RenderNotification(n notify.Notification) MessageOptions {
+	subject := n.Title
+	if subject == "" {
+		subject = n.Severity.Label()
+	} else if n.Severity == notify.SeverityWarning || n.Severity == notify.SeverityCritical {
+		subject = "[" + n.Severity.Label() + "] " + subject
+	}
+
+	color, ok := severityColors[n.Severity]
+	if !ok {
+		color = severityColors[notify.SeverityInfo]
+	}
+	var b strings.Builder
+	b.WriteString(`<div style="font-family:Arial,sans-serif;max-width:600px;border-top:4px solid ` + color + `;padding:16px">`)
+	if n.Title != "" {
+		b.WriteString(`<h2 style="margin:0 0 12px">` + EscapeHTML(n.Title) + `</h2>`)
+	}
+	if n.Body != "" {
+		b.WriteString(`<p>` + EscapeHTML(n.Body) + `</p>`)
+	}
+	if len(n.Metadata) > 0 {
+		b.WriteString(`<table style="border-collapse:collapse;margin:12px 0">`)
+		for _, k := range n.MetadataKeys() {
+			b.WriteString(`<tr><td style="padding:2px 12px 2px 0;color:#666">` + EscapeHTML(k) +
+				`</td><td style="padding:2px 0">` + EscapeHTML(n.Metadata[k]) + `</td></tr>`)
+		}
+		b.WriteString(`</table>`)
+	}
+	if len(n.Actions) > 0 {
+		b.WriteString(`<p>`)
+		for _, a := range n.Actions {
+			b.WriteString(`<a href="` + html.EscapeString(a.URL) + `" style="display:inline-block;margin:4px 8px 4px 0;padding:8px 16px;` +
+				`background:` + color + `;color:#fff;text-decoration:none;border-radius:4px">` + EscapeHTML(a.Label) + `</a>`)
+		}
+		b.WriteString(`</p>`)
+	}
+	b.WriteString(`</div>`)
+
+	return MessageOptions{Subject: subject, Body: n.Text(), HTML: b.String()}
+}
@@ -0,0 +1,169 @@
+package email
+
+import (
+	"cmp"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"golang.org/x/time/rate"
+
+	"github.com/epheer/notephee/attachment"
+	"github.com/epheer/notephee/email/providers"
+	"github.com/epheer/notephee/notify"
+)
+
+// Options — параметры клиента для приложений со своей системой конфигурации; адрес SMTP-сервера
+// передаётся в NewClient.
+type Options struct {
+	User     string // Логин SMTP (пусто — без авторизации)
+	Password string // Пароль SMTP
+	From     string // Адрес отправителя (пусто — User)
+	FromName string // Отображаемое имя отправителя (необязательно)
+
+	Transport    providers.EmailTransport // HTTP API провайдера вместо SMTP (необязательно)
+	DomainLimits []DomainLimit            // Лимиты по доменам получателей (необязательно)
+	SentFolder   SentFolder               // Папка для копий отправленных писем (необязательно)
+}
+
+// WithOptions применяет параметры o:
+//
+//	mail, err := email.NewClient("smtp.example.com:587", email.WithOptions(email.Options{User: user, Password: password}))
+//
+// Авторизация SMTP включается, только если адрес сервера задан. Лимит по умолчанию зависит
+// от транспорта, поэтому WithRateLimit указывается после WithOptions.
+func WithOptions(o Options) Option {
+	return func(c *Client) error {
+		c.from, c.fromName = cmp.Or(o.From, o.User), o.FromName
+		if c.url != "" && o.User != "" {
+			if err := c.SetAuth(o.User, o.Password); err != nil {
+				return err
+			}
+		}
+		if o.Transport != nil {
+			c.SetTransport(o.Transport)
+		}
+		if len(o.DomainLimits) > 0 {
+			if err := c.SetDomainLimits(o.DomainLimits...); err != nil {
+				return err
+			}
+		}
+		if o.SentFolder != nil {
+			c.SetSentFolder(o.SentFolder)
+		}
+		return nil
+	}
+}
+
+// Option настраивает Client при создании через NewClient.
+type Option func(*Client) error
+
+// WithLogger задаёт логгер клиента (по умолчанию slog.Default()).
+func WithLogger(logger *slog.Logger) Option {
+	return func(c *Client) error {
+		c.logger = logger
+		return nil
+	}
+}
+
+// WithAuth задаёт логин и пароль SMTP (PLAIN). Без неё письма отправляются без авторизации,
+// что принимают только серверы во внутренней сети.
+func WithAuth(user, password string) Option {
+	return func(c *Client) error {
+		return c.SetAuth(user, password)
+	}
+}
+
+// WithFrom задаёт адрес и отображаемое имя отправителя. Без адреса отправителя клиент отключён.
+func WithFrom(address, name string) Option {
+	return func(c *Client) error {
+		c.from, c.fromName = address, name
+		return nil
+	}
+}
+
+// WithTransport отправляет письма через HTTP API провайдера вместо SMTP (см. SetTransport).
+func WithTransport(t providers.EmailTransport) Option {
+	return func(c *Client) error {
+		c.SetTransport(t)
+		return nil
+	}
+}
+
+// WithRateLimit задаёт встроенный лимит отправок: limit в секунду с всплеском burst. Лимит
+// по умолчанию зависит от транспорта, поэтому опция указывается после WithTransport.
+func WithRateLimit(limit rate.Limit, burst int) Option {
+	return func(c *Client) error {
+		if limit <= 0 || burst <= 0 {
+			return fmt.Errorf("лимит и всплеск отправок должны быть положительными")
+		}
+		c.rate.SetLimit(limit)
+		c.rate.SetBurst(burst)
+		return nil
+	}
+}
+
+// WithLimiter заменяет встроенный лимит внешним, например распределённым (см. SetLimiter).
+func WithLimiter(l notify.Limiter) Option {
+	return func(c *Client) error {
+		c.SetLimiter(l)
+		return nil
+	}
+}
+
+// WithAttachmentPolicy задаёт предел размера вложений и действие для больших (см. SetAttachmentPolicy).
+func WithAttachmentPolicy(p attachment.Policy) Option {
+	return func(c *Client) error {
+		c.SetAttachmentPolicy(p)
+		return nil
+	}
+}
+
+// WithRetry задаёт число повторов отправки после временной ошибки (см. SetRetries).
+func WithRetry(n int) Option {
+	return func(c *Client) error {
+		c.SetRetries(n)
+		return nil
+	}
+}
+
+// WithHTTPClient задаёт HTTP-клиент для запросов к API провайдера. Транспорт задаётся раньше —
+// WithTransport или WithOptions; для SMTP опция возвращает ошибку.
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) error {
+		if hc == nil {
+			return fmt.Errorf("HTTP-клиент не задан")
+		}
+		t, ok := c.transport.(interface{ SetHTTPClient(*http.Client) })
+		if !ok {
+			return fmt.Errorf("HTTP-клиент задаётся только для транспорта провайдера")
+		}
+		t.SetHTTPClient(hc)
+		return nil
+	}
+}
+
+// WithBaseURL задаёт адрес API провайдера вместо адреса по умолчанию, например providers.MailgunEU.
+// Транспорт задаётся раньше — WithTransport или WithOptions; для SMTP опция возвращает ошибку.
+func WithBaseURL(base string) Option {
+	return func(c *Client) error {
+		t, ok := c.transport.(interface{ SetBaseURL(string) })
+		if !ok {
+			return fmt.Errorf("адрес API задаётся только для транспорта провайдера")
+		}
+		if _, err := url.ParseRequestURI(base); err != nil {
+			return fmt.Errorf("некорректный адрес API провайдера %q: %w", base, err)
+		}
+		t.SetBaseURL(strings.TrimSuffix(base, "/"))
+		return nil
+	}
+}
+
+// WithDomainLimits задаёт лимиты отправки по доменам получателей (см. SetDomainLimits).
+func WithDomainLimits(limits ...DomainLimit) Option {
+	return func(c *Client) error {
+		return c.SetDomainLimits(limits...)
+	}
+}
@@ -0,0 +1,158 @@
+package providers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+
+	"github.com/epheer/notephee/tracing"
+)
+
+// Базовые URL Mailgun API по регионам.
+const (
+	MailgunUS = "https://api.mailgun.net"
+	MailgunEU = "https://api.eu.mailgun.net"
+)
+
+// MailgunTransport отправляет письма методом /v3/{domain}/messages.
+type MailgunTransport struct {
+	domain string
+	apiKey string
+	uri    string
+	http   *http.Client
+}
+
+// NewMailgun создаёт транспорт Mailgun для домена domain в регионе US. client == nil — http.DefaultClient.
+func NewMailgun(domain, apiKey string, client *http.Client) *MailgunTransport {
+	return &MailgunTransport{domain: domain, apiKey: apiKey, uri: MailgunUS, http: orDefault(client)}
+}
+
+// SetBaseURL задаёт базовый URL API, например MailgunEU для доменов в европейском регионе.
+func (t *MailgunTransport) SetBaseURL(uri string) {
+	t.uri = uri
+}
+
+// SetHTTPClient заменяет HTTP-клиент для запросов к API; nil — http.DefaultClient.
+func (t *MailgunTransport) SetHTTPClient(client *http.Client) {
+	t.http = orDefault(client)
+}
+
+// Name возвращает имя провайдера.
+func (t *MailgunTransport) Name() string {
+	return Mailgun
+}
+
+// Send отправляет письмо. Идентификатор попытки передаётся пользовательской переменной
+// notephee_id и приходит в вебхуках Mailgun.
+func (t *MailgunTransport) Send(ctx context.Context, msg Message) (Result, error) {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+
+	from := msg.From
+	if msg.FromName != "" {
+		from = fmt.Sprintf("%s <%s>", mime.BEncoding.Encode("utf-8", msg.FromName), msg.From)
+	}
+	fields := [][2]string{{"from", from}, {"to", msg.To}, {"subject", msg.Subject}, {"text", msg.Text}}
+	if msg.HTML != "" {
+		fields = append(fields, [2]string{"html", msg.HTML})
+	}
+	if msg.ReplyTo != "" {
+		fields = append(fields, [2]string{"h:Reply-To", msg.ReplyTo})
+	}
+	if msg.ID != "" {
+		fields = append(fields, [2]string{"v:notephee_id", msg.ID})
+	}
+	for _, name := range sortedKeys(msg.Headers) {
+		fields = append(fields, [2]string{"h:" + name, msg.Headers[name]})
+	}
+	for _, f := range fields {
+		if err := form.WriteField(f[0], f[1]); err != nil {
+			return Result{}, err
+		}
+	}
+	for _, f := range msg.Attachments {
+		if err := writeFormFile(form, "attachment", f.Name, f.Data); err != nil {
+			return Result{}, err
+		}
+	}
+	// Mailgun задаёт Content-ID встроенной картинки по имени файла, поэтому имя — это cid
+	for _, cid := range sortedKeys(msg.Inline) {
+		if err := writeFormFile(form, "inline", cid, msg.Inline[cid].Data); err != nil {
+			return Result{}, err
+		}
+	}
+	if err := form.Close(); err != nil {
+		return Result{}, err
+	}
+
+	return t.post(ctx, "messages", form.FormDataContentType(), &body)
+}
+
+// SendRaw отправляет готовое письмо в формате MIME методом /v3/{domain}/messages.mime.
+// Отправитель берётся из заголовка From письма.
+func (t *MailgunTransport) SendRaw(ctx context.Context, _, to string, raw []byte) (Result, error) {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	if err := form.WriteField("to", to); err != nil {
+		return Result{}, err
+	}
+	part, err := form.CreateFormFile("message", "message.mime")
+	if err != nil {
+		return Result{}, err
+	}
+	if _, err := part.Write(raw); err != nil {
+		return Result{}, err
+	}
+	if err := form.Close(); err != nil {
+		return Result{}, err
+	}
+	return t.post(ctx, "messages.mime", form.FormDataContentType(), &body)
+}
+
+// post отправляет форму методу method домена и разбирает ответ.
+func (t *MailgunTransport) post(ctx context.Context, method, contentType string, body io.Reader) (Result, error) {
+	endpoint := fmt.Sprintf("%s/v3/%s/%s", t.uri, url.PathEscape(t.domain), method)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, body)
+	if err != nil {
+		return Result{}, err
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.SetBasicAuth("api", t.apiKey)
+
+	resp, err := t.http.Do(req)
+	if err != nil {
+		return Result{}, err
+	}
+	tracing.HTTPStatus(ctx, resp.StatusCode)
+	defer func(Body io.ReadCloser) {
+		_ = Body.Close()
+	}(resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		return Result{}, apiError(Mailgun, resp)
+	}
+
+	var res struct {
+		ID      string `json:"id"`
+		Message string `json:"message"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return Result{}, fmt.Errorf("некорректный формат JSON: %w", err)
+	}
+	return Result{Provider: Mailgun, MessageID: res.ID}, nil
+}
+
+func writeFormFile(form *multipart.Writer, field, name string, data []byte) error {
+	part, err := form.CreateFormFile(field, name)
+	if err != nil {
+		return err
+	}
+	_, err = part.Write(data)
+	return err
+}
@@ -0,0 +1,109 @@
+// Package providers реализует отправку писем через HTTP API транзакционных почтовых сервисов
+// (SendGrid, Mailgun, Amazon SES) как альтернативу SMTP.
+//
+// API провайдеров не держат SMTP-сессию на каждое письмо и возвращают идентификатор сообщения,
+// по которому провайдер сообщает о доставке, отказах и жалобах.
+package providers
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/epheer/notephee/attachment"
+)
+
+// Имена провайдеров в NOTEPHEE_EMAIL_PROVIDER.
+const (
+	SMTP     = "smtp"
+	SendGrid = "sendgrid"
+	Mailgun  = "mailgun"
+	SES      = "ses"
+)
+
+// Message — письмо для отправки через транспорт.
+type Message struct {
+	ID          string                // Идентификатор попытки в журнале доставки
+	From        string                // Адрес отправителя
+	FromName    string                // Отображаемое имя отправителя
+	ReplyTo     string                // Адрес для ответов (необязательно)
+	To          string                // Адрес получателя
+	Subject     string                // Тема
+	Text        string                // Текст письма (text/plain)
+	HTML        string                // HTML-версия письма (необязательно)
+	Headers     map[string]string     // Дополнительные заголовки
+	Attachments []*attachment.Encoded // Вложения
+
+	Inline map[string]*attachment.Encoded // Картинки для HTML по Content-ID (необязательно)
+
+	// Raw — то же письмо в формате MIME. Используется транспортами, которые принимают
+	// готовое письмо (SES), чтобы заголовки и вложения не собирались заново.
+	Raw io.WriterTo
+}
+
+// Result — метаданные, которые провайдер вернул о принятом письме.
+type Result struct {
+	Provider  string // Имя провайдера
+	MessageID string // Идентификатор письма у провайдера
+}
+
+// EmailTransport отправляет одно письмо. Реализации должны быть безопасны для конкурентного использования.
+type EmailTransport interface {
+	// Name возвращает имя провайдера.
+	Name() string
+	// Send отправляет письмо и возвращает метаданные провайдера.
+	Send(ctx context.Context, msg Message) (Result, error)
+}
+
+// RawTransport — транспорт, который умеет отправить письмо, уже собранное в формате MIME.
+// Реализуется SES и Mailgun; SendGrid готовые письма не принимает.
+type RawTransport interface {
+	EmailTransport
+	// SendRaw отправляет письмо raw от from получателю to без изменений.
+	SendRaw(ctx context.Context, from, to string, raw []byte) (Result, error)
+}
+
+// APIError — ответ HTTP API провайдера с ошибкой. Находится через errors.As.
+type APIError struct {
+	Provider   string // Имя провайдера
+	StatusCode int    // HTTP-код ответа
+	Body       string // Начало тела ответа
+}
+
+// Error возвращает провайдера, код и начало тела ответа.
+func (e *APIError) Error() string {
+	return fmt.Sprintf("ошибка API %s: код %d: %s", e.Provider, e.StatusCode, e.Body)
+}
+
+// Temporary сообщает, что письмо можно отправить повторно позже: провайдер ограничил поток (429)
+// или не смог обработать запрос (5xx).
+func (e *APIError) Temporary() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= 500
+}
+
+// apiError формирует ошибку HTTP API провайдера с началом тела ответа.
+func apiError(provider string, resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return &APIError{Provider: provider, StatusCode: resp.StatusCode, Body: strings.TrimSpace(string(body))}
+}
+
+// orDefault возвращает client или http.DefaultClient, если он не задан.
+func orDefault(client *http.Client) *http.Client {
+	if client == nil {
+		return http.DefaultClient
+	}
+	return client
+}
+
+// sortedKeys возвращает ключи m по алфавиту, чтобы запрос к провайдеру собирался одинаково.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,150 @@
+package providers_test
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/epheer/notephee/attachment"
+	"github.com/epheer/notephee/email/providers"
+)
+
+type rawMessage string
+
+func (m rawMessage) WriteTo(w io.Writer) (int64, error) {
+	n, err := io.WriteString(w, string(m))
+	return int64(n), err
+}
+
+var testMessage = providers.Message{
+	ID:       "d1",
+	From:     "noreply@example.com",
+	FromName: "Notephee",
+	ReplyTo:  "support@example.com",
+	To:       "user@example.com",
+	Subject:  "Отчёт",
+	Text:     "во вложении",
+	HTML:     `<p>во вложении</p><img src="cid:logo">`,
+	Headers:  map[string]string{"List-Unsubscribe": "<https://example.com/u>"},
+	Attachments: []*attachment.Encoded{
+		attachment.Encode(attachment.File{Name: "отчёт.pdf", ContentType: "application/pdf", Data: []byte("%PDF-1.4")}),
+	},
+	Inline: map[string]*attachment.Encoded{
+		"logo": attachment.Encode(attachment.File{Name: "logo.png", ContentType: "image/png", Data: []byte("PNG")}),
+	},
+	Raw: rawMessage("From: noreply@example.com\r\nTo: user@example.com\r\n\r\nво вложении"),
+}
+
+func TestSendGrid(t *testing.T) {
+	var got map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v3/mail/send" || r.Header.Get("Authorization") != "Bearer SG.test" {
+			t.Errorf("неожиданный запрос: %s %s", r.URL.Path, r.Header.Get("Authorization"))
+		}
+		_ = json.NewDecoder(r.Body).Decode(&got)
+		w.Header().Set("X-Message-Id", "sg-123")
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	t.Cleanup(srv.Close)
+
+	tr := providers.NewSendGrid("SG.test", srv.Client())
+	tr.SetBaseURL(srv.URL)
+
+	res, err := tr.Send(context.Background(), testMessage)
+	if err != nil || res.MessageID != "sg-123" {
+		t.Fatalf("ожидался message_id sg-123, получено %q, %v", res.MessageID, err)
+	}
+	att := got["attachments"].([]any)[0].(map[string]any)
+	if att["content"] != base64.StdEncoding.EncodeToString([]byte("%PDF-1.4")) || got["custom_args"].(map[string]any)["notephee_id"] != "d1" ||
+		got["reply_to"].(map[string]any)["email"] != "support@example.com" {
+		t.Fatalf("неожиданное тело запроса: %v", got)
+	}
+	inline := got["attachments"].([]any)[1].(map[string]any)
+	if len(got["content"].([]any)) != 2 || inline["disposition"] != "inline" || inline["content_id"] != "logo" {
+		t.Fatalf("ожидались HTML-версия и встроенная картинка logo: %v", got)
+	}
+}
+
+func TestMailgun(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, key, _ := r.BasicAuth()
+		if r.URL.Path != "/v3/mg.example.com/messages" || user != "api" || key != "key-test" {
+			t.Errorf("неожиданный запрос: %s %s", r.URL.Path, user)
+		}
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			t.Errorf("некорректная форма: %v", err)
+		}
+		if r.FormValue("h:List-Unsubscribe") == "" || r.FormValue("h:Reply-To") != "support@example.com" || r.FormValue("v:notephee_id") != "d1" || len(r.MultipartForm.File["attachment"]) != 1 ||
+			r.FormValue("html") == "" || len(r.MultipartForm.File["inline"]) != 1 || r.MultipartForm.File["inline"][0].Filename != "logo" {
+			t.Errorf("неожиданные поля формы: %v", r.MultipartForm.Value)
+		}
+		_, _ = w.Write([]byte(`{"id":"<mg-1@mg.example.com>","message":"Queued. Thank you."}`))
+	}))
+	t.Cleanup(srv.Close)
+
+	tr := providers.NewMailgun("mg.example.com", "key-test", srv.Client())
+	tr.SetBaseURL(srv.URL)
+
+	res, err := tr.Send(context.Background(), testMessage)
+	if err != nil || res.MessageID != "<mg-1@mg.example.com>" {
+		t.Fatalf("ожидался message_id Mailgun, получено %q, %v", res.MessageID, err)
+	}
+}
+
+func TestSES(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth := r.Header.Get("Authorization")
+		if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=AKIDTEST/") || !strings.Contains(auth, "/eu-central-1/ses/aws4_request") {
+			t.Errorf("неожиданная подпись: %s", auth)
+		}
+		var body struct {
+			Content struct {
+				Raw struct{ Data string }
+			}
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		raw, _ := base64.StdEncoding.DecodeString(body.Content.Raw.Data)
+		if !strings.HasSuffix(string(raw), "во вложении") {
+			t.Errorf("неожиданное письмо: %q", raw)
+		}
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"message":"Email address is not verified."}`))
+	}))
+	t.Cleanup(srv.Close)
+
+	tr := providers.NewSES("eu-central-1", "AKIDTEST", "secret", srv.Client())
+	tr.SetBaseURL(srv.URL)
+
+	if _, err := tr.Send(context.Background(), testMessage); err == nil || !strings.Contains(err.Error(), "not verified") {
+		t.Fatalf("ожидалась ошибка SES с текстом ответа, получено %v", err)
+	}
+}
+
+func TestMailgunRaw(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v3/mg.example.com/messages.mime" {
+			t.Errorf("неожиданный путь: %s", r.URL.Path)
+		}
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			t.Errorf("некорректная форма: %v", err)
+		}
+		if r.FormValue("to") != "user@example.com" || len(r.MultipartForm.File["message"]) != 1 {
+			t.Errorf("неожиданные поля формы: %v", r.MultipartForm.Value)
+		}
+		_, _ = w.Write([]byte(`{"id":"<mg-2@mg.example.com>","message":"Queued. Thank you."}`))
+	}))
+	t.Cleanup(srv.Close)
+
+	tr := providers.NewMailgun("mg.example.com", "key-test", srv.Client())
+	tr.SetBaseURL(srv.URL)
+
+	res, err := tr.SendRaw(context.Background(), "noreply@example.com", "user@example.com", []byte("Subject: raw\r\n\r\nтекст"))
+	if err != nil || res.MessageID != "<mg-2@mg.example.com>" {
+		t.Fatalf("ожидался message_id Mailgun, получено %q, %v", res.MessageID, err)
+	}
+}
@@ -0,0 +1,142 @@
+package providers
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/epheer/notephee/tracing"
+)
+
+// SendGridAPI — базовый URL SendGrid Web API v3.
+const SendGridAPI = "https://api.sendgrid.com"
+
+type sgAddress struct {
+	Email string `json:"email"`
+	Name  string `json:"name,omitempty"`
+}
+
+type sgAttachment struct {
+	Content     string `json:"content"`
+	Filename    string `json:"filename"`
+	Type        string `json:"type,omitempty"`
+	Disposition string `json:"disposition"`
+	ContentID   string `json:"content_id,omitempty"`
+}
+
+type sgPersonalization struct {
+	To []sgAddress `json:"to"`
+}
+
+type sgMail struct {
+	Personalizations []sgPersonalization `json:"personalizations"`
+	From             sgAddress           `json:"from"`
+	ReplyTo          *sgAddress          `json:"reply_to,omitempty"`
+	Subject          string              `json:"subject"`
+	Content          []sgContent         `json:"content"`
+	Headers          map[string]string   `json:"headers,omitempty"`
+	Attachments      []sgAttachment      `json:"attachments,omitempty"`
+	CustomArgs       map[string]string   `json:"custom_args,omitempty"`
+}
+
+type sgContent struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+// SendGridTransport отправляет письма методом /v3/mail/send.
+type SendGridTransport struct {
+	apiKey string
+	uri    string
+	http   *http.Client
+}
+
+// NewSendGrid создаёт транспорт SendGrid с API-ключом apiKey. client == nil — http.DefaultClient.
+func NewSendGrid(apiKey string, client *http.Client) *SendGridTransport {
+	return &SendGridTransport{apiKey: apiKey, uri: SendGridAPI, http: orDefault(client)}
+}
+
+// SetBaseURL задаёт базовый URL API, например https://api.eu.sendgrid.com для субпользователей в регионе EU.
+func (t *SendGridTransport) SetBaseURL(uri string) {
+	t.uri = uri
+}
+
+// SetHTTPClient заменяет HTTP-клиент для запросов к API; nil — http.DefaultClient.
+func (t *SendGridTransport) SetHTTPClient(client *http.Client) {
+	t.http = orDefault(client)
+}
+
+// Name возвращает имя провайдера.
+func (t *SendGridTransport) Name() string {
+	return SendGrid
+}
+
+// Send отправляет письмо. Идентификатор попытки передаётся в custom_args и приходит
+// в Event Webhook SendGrid вместе с событиями доставки.
+func (t *SendGridTransport) Send(ctx context.Context, msg Message) (Result, error) {
+	mail := sgMail{
+		Personalizations: []sgPersonalization{{To: []sgAddress{{Email: msg.To}}}},
+		From:             sgAddress{Email: msg.From, Name: msg.FromName},
+		Subject:          msg.Subject,
+		Content:          []sgContent{{Type: "text/plain", Value: msg.Text}},
+		Headers:          msg.Headers,
+	}
+	if msg.HTML != "" {
+		mail.Content = append(mail.Content, sgContent{Type: "text/html", Value: msg.HTML})
+	}
+	if msg.ReplyTo != "" {
+		mail.ReplyTo = &sgAddress{Email: msg.ReplyTo}
+	}
+	if msg.ID != "" {
+		mail.CustomArgs = map[string]string{"notephee_id": msg.ID}
+	}
+	for _, f := range msg.Attachments {
+		// SendGrid ждёт base64 одной строкой, без переносов MIME
+		mail.Attachments = append(mail.Attachments, sgAttachment{
+			Content:     base64.StdEncoding.EncodeToString(f.Data),
+			Filename:    f.Name,
+			Type:        f.MediaType(),
+			Disposition: "attachment",
+		})
+	}
+	for _, cid := range sortedKeys(msg.Inline) {
+		f := msg.Inline[cid]
+		mail.Attachments = append(mail.Attachments, sgAttachment{
+			Content:     base64.StdEncoding.EncodeToString(f.Data),
+			Filename:    f.Name,
+			Type:        f.MediaType(),
+			Disposition: "inline",
+			ContentID:   cid,
+		})
+	}
+
+	data, err := json.Marshal(mail)
+	if err != nil {
+		return Result{}, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.uri+"/v3/mail/send", bytes.NewReader(data))
+	if err != nil {
+		return Result{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+t.apiKey)
+
+	resp, err := t.http.Do(req)
+	if err != nil {
+		return Result{}, err
+	}
+	tracing.HTTPStatus(ctx, resp.StatusCode)
+	defer func(Body io.ReadCloser) {
+		_ = Body.Close()
+	}(resp.Body)
+
+	if resp.StatusCode != http.StatusAccepted && resp.StatusCode != http.StatusOK {
+		return Result{}, apiError(SendGrid, resp)
+	}
+	return Result{Provider: SendGrid, MessageID: strings.TrimSpace(resp.Header.Get("X-Message-Id"))}, nil
+}
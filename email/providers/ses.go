@@ -0,0 +1,171 @@
+package providers
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/epheer/notephee/tracing"
+)
+
+// sesPath — метод SendEmail в SES API v2.
+const sesPath = "/v2/email/outbound-emails"
+
+// SESTransport отправляет письма методом SendEmail Amazon SES API v2 с подписью AWS Signature V4.
+//
+// Письмо передаётся как Raw MIME, поэтому заголовки отписки и вложения доходят без изменений.
+type SESTransport struct {
+	region    string
+	accessKey string
+	secretKey string
+	uri       string
+	http      *http.Client
+	now       func() time.Time
+}
+
+// NewSES создаёт транспорт SES в регионе region с ключами IAM-пользователя. client == nil — http.DefaultClient.
+func NewSES(region, accessKey, secretKey string, client *http.Client) *SESTransport {
+	return &SESTransport{
+		region:    region,
+		accessKey: accessKey,
+		secretKey: secretKey,
+		uri:       fmt.Sprintf("https://email.%s.amazonaws.com", region),
+		http:      orDefault(client),
+		now:       time.Now,
+	}
+}
+
+// SetBaseURL задаёт адрес API вместо регионального, например VPC-эндпоинт.
+func (t *SESTransport) SetBaseURL(uri string) {
+	t.uri = uri
+}
+
+// SetHTTPClient заменяет HTTP-клиент для запросов к API; nil — http.DefaultClient.
+func (t *SESTransport) SetHTTPClient(client *http.Client) {
+	t.http = orDefault(client)
+}
+
+// Name возвращает имя провайдера.
+func (t *SESTransport) Name() string {
+	return SES
+}
+
+// Send отправляет письмо. msg.Raw обязателен.
+func (t *SESTransport) Send(ctx context.Context, msg Message) (Result, error) {
+	if msg.Raw == nil {
+		return Result{}, errors.New("для SES нужно письмо в формате MIME")
+	}
+
+	var raw bytes.Buffer
+	if _, err := msg.Raw.WriteTo(&raw); err != nil {
+		return Result{}, err
+	}
+
+	payload := map[string]any{
+		"FromEmailAddress": msg.From,
+		"Destination":      map[string]any{"ToAddresses": []string{msg.To}},
+		"Content":          map[string]any{"Raw": map[string]string{"Data": base64.StdEncoding.EncodeToString(raw.Bytes())}},
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return Result{}, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.uri+sesPath, bytes.NewReader(data))
+	if err != nil {
+		return Result{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	t.sign(req, data)
+
+	resp, err := t.http.Do(req)
+	if err != nil {
+		return Result{}, err
+	}
+	tracing.HTTPStatus(ctx, resp.StatusCode)
+	defer func(Body io.ReadCloser) {
+		_ = Body.Close()
+	}(resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		return Result{}, apiError(SES, resp)
+	}
+
+	var res struct {
+		MessageID string `json:"MessageId"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return Result{}, fmt.Errorf("некорректный формат JSON: %w", err)
+	}
+	return Result{Provider: SES, MessageID: res.MessageID}, nil
+}
+
+// SendRaw отправляет готовое письмо в формате MIME.
+func (t *SESTransport) SendRaw(ctx context.Context, from, to string, raw []byte) (Result, error) {
+	return t.Send(ctx, Message{From: from, To: to, Raw: bytes.NewReader(raw)})
+}
+
+// sign подписывает запрос по AWS Signature Version 4 для сервиса ses.
+func (t *SESTransport) sign(req *http.Request, payload []byte) {
+	now := t.now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	day := now.Format("20060102")
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	host := req.URL.Host
+
+	payloadHash := sha256Hex(payload)
+	canonical := strings.Join([]string{
+		req.Method,
+		escapePath(req.URL.Path),
+		req.URL.RawQuery,
+		"content-type:" + req.Header.Get("Content-Type") + "\nhost:" + host + "\nx-amz-date:" + amzDate + "\n",
+		"content-type;host;x-amz-date",
+		payloadHash,
+	}, "\n")
+
+	scope := day + "/" + t.region + "/ses/aws4_request"
+	toSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonical))
+
+	key := hmacSHA256([]byte("AWS4"+t.secretKey), day)
+	key = hmacSHA256(key, t.region)
+	key = hmacSHA256(key, "ses")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, toSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=content-type;host;x-amz-date, Signature=%s",
+		t.accessKey, scope, signature,
+	))
+}
+
+// escapePath кодирует путь по правилам канонического запроса SigV4.
+func escapePath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, s := range segments {
+		segments[i] = url.PathEscape(s)
+	}
+	return strings.Join(segments, "/")
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
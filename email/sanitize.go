@@ -0,0 +1,138 @@
+package email
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"strings"
+
+	xhtml "golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// EscapeHTML экранирует текст для вставки в HTML-версию письма и заменяет переводы строк на <br>,
+// чтобы пользовательский ввод сохранил строки и не мог добавить в письмо свою разметку.
+func EscapeHTML(s string) string {
+	s = html.EscapeString(strings.ReplaceAll(s, "\r\n", "\n"))
+	return strings.ReplaceAll(s, "\n", "<br>")
+}
+
+// HTMLf работает как fmt.Sprintf, но экранирует каждый аргумент EscapeHTML, оставляя разметку в format как есть:
+//
+//	body := email.HTMLf("<p>Комментарий клиента:</p><blockquote>%s</blockquote>", comment)
+func HTMLf(format string, args ...any) string {
+	wrapped := make([]any, len(args))
+	for i, arg := range args {
+		wrapped[i] = escapedArg{arg}
+	}
+	return fmt.Sprintf(format, wrapped...)
+}
+
+// escapedArg форматирует значение с тем же глаголом и флагами, что и в строке формата, и экранирует результат.
+type escapedArg struct {
+	value any
+}
+
+func (a escapedArg) Format(f fmt.State, verb rune) {
+	_, _ = f.Write([]byte(EscapeHTML(fmt.Sprintf(fmt.FormatString(f, verb), a.value))))
+}
+
+// droppedTags — элементы, которые SanitizeHTML удаляет вместе с содержимым: скрипты, встроенные
+// документы и формы почтовые клиенты блокируют или показывают как угрозу.
+var droppedTags = map[atom.Atom]bool{
+	atom.Script: true, atom.Style: true, atom.Iframe: true, atom.Frame: true, atom.Frameset: true,
+	atom.Object: true, atom.Embed: true, atom.Applet: true, atom.Form: true, atom.Input: true,
+	atom.Button: true, atom.Textarea: true, atom.Select: true, atom.Link: true, atom.Meta: true,
+	atom.Base: true, atom.Svg: true, atom.Math: true, atom.Template: true, atom.Noscript: true,
+}
+
+// urlAttrs — атрибуты со ссылками, схема которых проверяется.
+var urlAttrs = map[string]bool{"href": true, "src": true, "action": true, "background": true, "poster": true, "cite": true}
+
+// safeSchemes — допустимые схемы ссылок; cid — встроенные картинки письма.
+var safeSchemes = []string{"http:", "https:", "mailto:", "tel:", "cid:"}
+
+// SanitizeHTML очищает фрагмент HTML из недоверенного источника перед вставкой в письмо: удаляет
+// скрипты, стили, встроенные документы и формы, обработчики событий (on*) и ссылки со схемами
+// кроме http, https, mailto, tel и cid (например, javascript:). Форматирование, таблицы и картинки
+// остаются. Незакрытые теги закрываются, поэтому фрагмент не ломает разметку вокруг себя.
+func SanitizeHTML(s string) string {
+	body := &xhtml.Node{Type: xhtml.ElementNode, Data: "body", DataAtom: atom.Body}
+	nodes, err := xhtml.ParseFragment(strings.NewReader(s), body)
+	if err != nil {
+		// Разбор из строки не возвращает ошибок чтения; на всякий случай отдаём текст без разметки
+		return EscapeHTML(s)
+	}
+
+	var buf bytes.Buffer
+	for _, n := range nodes {
+		if !sanitizeNode(n) {
+			continue
+		}
+		_ = xhtml.Render(&buf, n)
+	}
+	return buf.String()
+}
+
+// sanitizeNode очищает n и его потомков и сообщает, оставить ли сам n.
+func sanitizeNode(n *xhtml.Node) bool {
+	switch n.Type {
+	case xhtml.CommentNode, xhtml.DoctypeNode:
+		return false
+	case xhtml.ElementNode:
+		if droppedTags[n.DataAtom] || n.Namespace != "" {
+			return false
+		}
+		attrs := n.Attr[:0]
+		for _, a := range n.Attr {
+			key := strings.ToLower(a.Key)
+			switch {
+			case a.Namespace != "", strings.HasPrefix(key, "on"), key == "srcdoc", key == "formaction":
+				continue
+			case urlAttrs[key] && !safeURL(a.Val):
+				continue
+			case key == "style" && unsafeStyle(a.Val):
+				continue
+			}
+			attrs = append(attrs, a)
+		}
+		n.Attr = attrs
+	}
+
+	for c := n.FirstChild; c != nil; {
+		next := c.NextSibling
+		if !sanitizeNode(c) {
+			n.RemoveChild(c)
+		}
+		c = next
+	}
+	return true
+}
+
+// safeURL сообщает, что ссылка относительная или с допустимой схемой.
+func safeURL(u string) bool {
+	// Браузеры игнорируют пробелы и управляющие символы в схеме: "java\tscript:" работает как "javascript:"
+	u = strings.ToLower(strings.Map(func(r rune) rune {
+		if r <= ' ' {
+			return -1
+		}
+		return r
+	}, u))
+	scheme, _, ok := strings.Cut(u, ":")
+	if !ok || strings.ContainsAny(scheme, "/?#") {
+		return true
+	}
+	for _, s := range safeSchemes {
+		if scheme+":" == s {
+			return true
+		}
+	}
+	return false
+}
+
+// unsafeStyle находит в атрибуте style конструкции, исполняющие код или загружающие внешние ресурсы.
+func unsafeStyle(style string) bool {
+	style = strings.ToLower(style)
+	return strings.Contains(style, "expression(") || strings.Contains(style, "javascript:") ||
+		strings.Contains(style, "url(") || strings.Contains(style, "@import")
+}
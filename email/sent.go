@@ -0,0 +1,44 @@
+package email
+
+import (
+	"bytes"
+	"context"
+	"io"
+)
+
+// SentFolder — папка, в которую сохраняются копии отправленных писем, например «Отправленные»
+// ящика отправителя (imap.SentFolder).
+type SentFolder interface {
+	// Append сохраняет письмо msg в формате MIME.
+	Append(ctx context.Context, msg []byte) error
+}
+
+// SetSentFolder включает сохранение копии каждого отправленного письма в folder, чтобы сотрудники
+// видели в почтовом клиенте, что ушло получателю. Копия сохраняется после успешной отправки; ошибка
+// сохранения только пишется в лог. nil отключает сохранение.
+func (c *Client) SetSentFolder(folder SentFolder) {
+	c.sent = folder
+}
+
+// WithSentFolder сохраняет копии отправленных писем в folder (см. SetSentFolder).
+func WithSentFolder(folder SentFolder) Option {
+	return func(c *Client) error {
+		c.SetSentFolder(folder)
+		return nil
+	}
+}
+
+// saveSent сохраняет копию отправленного письма msg в папку отправленных, если она подключена.
+func (c *Client) saveSent(ctx context.Context, id string, msg io.WriterTo) {
+	if c.sent == nil {
+		return
+	}
+	var buf bytes.Buffer
+	_, err := msg.WriteTo(&buf)
+	if err == nil {
+		err = c.sent.Append(ctx, buf.Bytes())
+	}
+	if err != nil {
+		c.logger.Warn("не удалось сохранить копию письма в папку отправленных", "id", id, "error", err)
+	}
+}
@@ -0,0 +1,133 @@
+package email
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+
+	"github.com/epheer/notephee/attachment"
+)
+
+// deliver отправляет письмо одному получателю, записывая его в SMTP-соединение потоком.
+//
+// Повторяет поведение smtp.SendMail (STARTTLS, если сервер его поддерживает, затем AUTH),
+// но принимает io.WriterTo вместо готового []byte. Отмена ctx прерывает соединение.
+// Ответ сервера с ошибкой возвращается как *SMTPError.
+// from — адрес в конверте (MAIL FROM), обычно адрес клиента.
+func (c *Client) deliver(ctx context.Context, from, to string, msg io.WriterTo) (err error) {
+	defer func() {
+		err = parseSMTPError(err)
+	}()
+	if strings.ContainsAny(from+to, "\r\n") {
+		return errors.New("адрес содержит перевод строки")
+	}
+
+	client, stop, err := c.dial(ctx)
+	if err != nil {
+		return err
+	}
+	defer stop()
+
+	// Предел размера из EHLO (RFC 1870) проверяется до отправки, чтобы не передавать письмо, которое сервер отклонит
+	if ok, param := client.Extension("SIZE"); ok {
+		if limit, _ := strconv.ParseInt(param, 10, 64); limit > 0 {
+			if m, ok := msg.(*message); ok && m.size() > limit {
+				return &attachment.SizeError{Name: "письмо", Size: m.size(), Limit: limit}
+			}
+		}
+	}
+
+	if err := client.Mail(from); err != nil {
+		return err
+	}
+	if err := client.Rcpt(to); err != nil {
+		return err
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := msg.WriteTo(w); err != nil {
+		_ = w.Close()
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// CheckConnection проверяет доступность SMTP-сервера: подключается, проходит STARTTLS и AUTH
+// и отправляет NOOP. При отправке через HTTP API провайдера проверять нечего, возвращается nil.
+// Ответ сервера с ошибкой возвращается как *SMTPError.
+func (c *Client) CheckConnection(ctx context.Context) (err error) {
+	if !c.Enabled {
+		return fmt.Errorf("функционал email отключён: некорректная конфигурация")
+	}
+	if c.transport != nil {
+		return nil
+	}
+	defer func() {
+		err = parseSMTPError(err)
+	}()
+
+	client, stop, err := c.dial(ctx)
+	if err != nil {
+		return err
+	}
+	defer stop()
+	if err := client.Noop(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// dial подключается к SMTP-серверу, включает STARTTLS, если сервер его поддерживает, и проходит AUTH.
+// stop закрывает соединение; отмена ctx прерывает его раньше.
+func (c *Client) dial(ctx context.Context) (client *smtp.Client, stop func(), err error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", c.url)
+	if err != nil {
+		return nil, nil, err
+	}
+	unwatch := context.AfterFunc(ctx, func() {
+		_ = conn.Close()
+	})
+
+	host, _, _ := net.SplitHostPort(c.url)
+	client, err = smtp.NewClient(conn, host)
+	if err != nil {
+		unwatch()
+		_ = conn.Close()
+		return nil, nil, err
+	}
+	stop = func() {
+		unwatch()
+		_ = client.Close()
+	}
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			stop()
+			return nil, nil, err
+		}
+	}
+	c.authMu.RLock()
+	auth := c.auth
+	c.authMu.RUnlock()
+	if auth != nil {
+		if ok, _ := client.Extension("AUTH"); ok {
+			if err := client.Auth(auth); err != nil {
+				stop()
+				return nil, nil, err
+			}
+		}
+	}
+	return client, stop, nil
+}
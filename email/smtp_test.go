@@ -0,0 +1,670 @@
+package email
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/epheer/notephee/attachment"
+	"github.com/epheer/notephee/delivery"
+	"github.com/epheer/notephee/email/providers"
+	"github.com/epheer/notephee/email/unsubscribe"
+	"github.com/epheer/notephee/notify"
+	"github.com/epheer/notephee/suppression"
+)
+
+// newTestClient создаёт клиента с адресом SMTP-сервера addr и параметрами o.
+func newTestClient(t *testing.T, addr string, o Options) *Client {
+	t.Helper()
+	c, err := NewClient(addr, WithOptions(o))
+	if err != nil {
+		t.Fatalf("Ошибка NewClient: %v", err)
+	}
+	return c
+}
+
+// fakeSMTP принимает одно письмо по минимальному диалогу SMTP без STARTTLS и AUTH
+// и возвращает его содержимое в канал.
+func fakeSMTP(t *testing.T) (string, <-chan string) {
+	t.Helper()
+	return fakeSMTPReply(t, "250 ok")
+}
+
+// fakeSMTPReply работает как fakeSMTP, но отвечает на RCPT TO строкой rcpt.
+func fakeSMTPReply(t *testing.T, rcpt string) (string, <-chan string) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Ошибка Listen: %v", err)
+	}
+	t.Cleanup(func() { _ = ln.Close() })
+
+	data := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer func() { _ = conn.Close() }()
+
+		tp := textproto.NewConn(conn)
+		_ = tp.PrintfLine("220 fake ESMTP")
+		for {
+			line, err := tp.ReadLine()
+			if err != nil {
+				return
+			}
+			switch cmd := strings.ToUpper(strings.Fields(line)[0]); cmd {
+			case "EHLO", "HELO":
+				_ = tp.PrintfLine("250 fake")
+			case "DATA":
+				_ = tp.PrintfLine("354 go ahead")
+				body, _ := io.ReadAll(tp.DotReader())
+				data <- string(body)
+				_ = tp.PrintfLine("250 queued")
+			case "RCPT":
+				_ = tp.PrintfLine("%s", rcpt)
+			case "QUIT":
+				_ = tp.PrintfLine("221 bye")
+				return
+			default:
+				_ = tp.PrintfLine("250 ok")
+			}
+		}
+	}()
+	return ln.Addr().String(), data
+}
+
+func TestDeliverStreamsMultipart(t *testing.T) {
+	addr, data := fakeSMTP(t)
+
+	c := newTestClient(t, addr, Options{User: "noreply@example.com", Password: "x", FromName: "Notephee"})
+
+	file := c.Attach(attachment.File{Name: "отчёт.pdf", ContentType: "application/pdf", Data: []byte("%PDF-1.4")})
+	err := c.SendText(MessageOptions{To: "user@example.com", Subject: "Отчёт", Body: "во вложении", Attachments: []*attachment.Encoded{file}})
+	if err != nil {
+		t.Fatalf("Ошибка SendText: %v", err)
+	}
+
+	m, err := mail.ReadMessage(bufio.NewReader(strings.NewReader(<-data)))
+	if err != nil {
+		t.Fatalf("письмо не разбирается: %v", err)
+	}
+	_, params, _ := mime.ParseMediaType(m.Header.Get("Content-Type"))
+	r := multipart.NewReader(m.Body, params["boundary"])
+
+	var names []string
+	for {
+		p, err := r.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("некорректная часть письма: %v", err)
+		}
+		names = append(names, p.FileName())
+	}
+	if len(names) != 2 || names[1] != "отчёт.pdf" {
+		t.Fatalf("ожидались текст и вложение отчёт.pdf, получено %q", names)
+	}
+}
+
+func TestCheckConnection(t *testing.T) {
+	addr, _ := fakeSMTP(t)
+	c, err := NewClient(addr, WithFrom("a@example.com", ""))
+	if err != nil {
+		t.Fatalf("Ошибка NewClient: %v", err)
+	}
+	if err := c.CheckConnection(context.Background()); err != nil {
+		t.Fatalf("Ошибка CheckConnection: %v", err)
+	}
+
+	c.url = "127.0.0.1:1"
+	if err := c.CheckConnection(context.Background()); err == nil {
+		t.Fatal("ожидалась ошибка подключения к недоступному серверу")
+	}
+}
+
+func TestDeliverCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	c := &Client{url: "127.0.0.1:1", from: "a@example.com"}
+	if err := c.deliver(ctx, c.from, "b@example.com", &message{}); err == nil {
+		t.Fatal("ожидалась ошибка отменённого контекста")
+	}
+}
+
+type fakeTransport struct {
+	got   providers.Message
+	calls int
+	fail  []error // Ошибки первых вызовов Send по порядку
+}
+
+func (t *fakeTransport) Name() string { return "fake" }
+
+func (t *fakeTransport) Send(_ context.Context, msg providers.Message) (providers.Result, error) {
+	t.calls++
+	if len(t.fail) > 0 {
+		err := t.fail[0]
+		t.fail = t.fail[1:]
+		return providers.Result{}, err
+	}
+	t.got = msg
+	return providers.Result{Provider: "fake", MessageID: "m1"}, nil
+}
+
+func TestSendViaTransport(t *testing.T) {
+	c := newTestClient(t, "", Options{User: "noreply@example.com", Transport: &fakeTransport{}})
+	tr := &fakeTransport{}
+	c.SetTransport(tr)
+
+	err := c.Send(context.Background(), notify.Message{To: "user@example.com", Subject: "Счёт", Text: "оплачен", Campaign: "billing"})
+	if err != nil {
+		t.Fatalf("Ошибка Send: %v", err)
+	}
+	if tr.got.From != "noreply@example.com" || tr.got.Headers[CampaignHeader] != "billing" || tr.got.Raw == nil {
+		t.Fatalf("неожиданное письмо для транспорта: %+v", tr.got)
+	}
+	if _, ok := tr.got.Headers["List-Unsubscribe"]; ok {
+		t.Fatal("без подписи ссылок отписки заголовок List-Unsubscribe не добавляется")
+	}
+
+	// Письмо рассылки получает заголовки одношаговой отписки по RFC 8058
+	signer, _ := unsubscribe.NewSigner([]byte(strings.Repeat("k", 32)), "https://example.com/unsubscribe", 0)
+	c.SetUnsubscribeSigner(signer)
+	if err := c.Send(context.Background(), notify.Message{To: "user@example.com", Text: "скидки", Campaign: "promo"}); err != nil {
+		t.Fatalf("Ошибка Send: %v", err)
+	}
+	if !strings.HasPrefix(tr.got.Headers["List-Unsubscribe"], "<https://example.com/unsubscribe?token=") ||
+		tr.got.Headers["List-Unsubscribe-Post"] != "List-Unsubscribe=One-Click" {
+		t.Fatalf("ожидались заголовки List-Unsubscribe, получено %v", tr.got.Headers)
+	}
+}
+
+func TestSendNotification(t *testing.T) {
+	c := newTestClient(t, "", Options{User: "noreply@example.com", Transport: &fakeTransport{}})
+	tr := &fakeTransport{}
+	c.SetTransport(tr)
+
+	n := notify.Notification{
+		Title:    "Диск заполнен",
+		Body:     "Свободно 2%\nОчистите журналы",
+		Severity: notify.SeverityWarning,
+		Actions:  []notify.Action{{Label: "Панель", URL: "https://example.com/?a=1&b=2"}},
+		Metadata: map[string]string{"сервер": "db-1"},
+	}
+	if err := c.Send(context.Background(), n.Message("admin@example.com")); err != nil {
+		t.Fatalf("Ошибка Send: %v", err)
+	}
+	got := tr.got
+	if got.To != "admin@example.com" || got.Subject != "[Предупреждение] Диск заполнен" || got.Text != n.Text() {
+		t.Fatalf("неожиданное письмо: %+v", got)
+	}
+	for _, want := range []string{"Свободно 2%<br>Очистите журналы", "db-1", `href="https://example.com/?a=1&amp;b=2"`} {
+		if !strings.Contains(got.HTML, want) {
+			t.Fatalf("в HTML нет %q: %s", want, got.HTML)
+		}
+	}
+}
+
+func TestAttachmentPolicy(t *testing.T) {
+	c := newTestClient(t, "", Options{User: "noreply@example.com", Transport: &fakeTransport{}})
+	tr := &fakeTransport{}
+	c.SetTransport(tr)
+	c.SetAttachmentPolicy(attachment.Policy{MaxSize: 10})
+
+	big := attachment.File{Name: "report.csv", Data: []byte("id,name\n1,Анна\n2,Борис\n")}
+	if _, err := c.AttachFiles(big); !errors.Is(err, attachment.ErrTooLarge) {
+		t.Fatalf("ожидалась ошибка размера, получено %v", err)
+	}
+	err := c.SendText(MessageOptions{To: "user@example.com", Subject: "Отчёт", Body: "во вложении", Attachments: []*attachment.Encoded{c.Attach(big)}})
+	if !errors.Is(err, attachment.ErrTooLarge) || tr.got.To != "" {
+		t.Fatalf("письмо с большим вложением не должно отправляться: %v", err)
+	}
+
+	c.SetAttachmentPolicy(attachment.Policy{MaxSize: 10, Oversize: attachment.Split})
+	files, err := c.AttachFiles(big)
+	if err != nil || len(files) != 4 || files[0].Name != "report.csv.001" {
+		t.Fatalf("ожидалось 4 части, получено %d: %v", len(files), err)
+	}
+	if got := c.Attach(attachment.File{Name: "report.csv", Data: big.Data}).ContentType; !strings.HasPrefix(got, "text/csv") {
+		t.Fatalf("тип вложения не определён по имени: %s", got)
+	}
+}
+
+func TestWithOptions(t *testing.T) {
+	tr := &fakeTransport{}
+	c, err := NewClient("", WithFrom("noreply@example.com", "Example"), WithTransport(tr), WithRateLimit(100, 10))
+	if err != nil {
+		t.Fatalf("Ошибка NewClient: %v", err)
+	}
+	if !c.Enabled {
+		t.Fatal("клиент с отправителем и транспортом должен быть включён")
+	}
+	if err := c.Send(context.Background(), notify.Message{To: "user@example.com", Subject: "Счёт", Text: "оплачен"}); err != nil {
+		t.Fatalf("Ошибка Send: %v", err)
+	}
+	if tr.got.From != "noreply@example.com" || tr.got.FromName != "Example" {
+		t.Fatalf("неверный отправитель: %+v", tr.got)
+	}
+
+	if c, _ := NewClient("smtp.example.com:587"); c.Enabled {
+		t.Fatal("без адреса отправителя клиент должен быть отключён")
+	}
+	if _, err := NewClient("smtp.example.com:587", WithRateLimit(0, 1)); err == nil {
+		t.Fatal("ожидалась ошибка для нулевого лимита")
+	}
+
+	// Адрес отправителя по умолчанию — логин; без адреса сервера авторизация SMTP не нужна
+	c, err = NewClient("", WithOptions(Options{User: "bot@example.com", Password: "x", Transport: tr}))
+	if err != nil || !c.Enabled || c.from != "bot@example.com" || c.auth != nil {
+		t.Fatalf("ожидался включённый клиент с отправителем bot@example.com: %v", err)
+	}
+}
+
+func TestRetry(t *testing.T) {
+	unavailable := &providers.APIError{Provider: "fake", StatusCode: 503}
+	tr := &fakeTransport{fail: []error{unavailable, unavailable}}
+	c, err := NewClient("", WithFrom("noreply@example.com", ""), WithTransport(tr), WithRateLimit(1000, 10), WithRetry(2))
+	if err != nil {
+		t.Fatalf("Ошибка NewClient: %v", err)
+	}
+	c.retryDelay = time.Millisecond
+
+	if err := c.Send(context.Background(), notify.Message{To: "user@example.com", Text: "привет"}); err != nil || tr.calls != 3 {
+		t.Fatalf("ожидалась отправка с третьей попытки, получено %v за %d вызовов", err, tr.calls)
+	}
+
+	// Постоянная ошибка не повторяется
+	tr.calls, tr.fail = 0, []error{&providers.APIError{Provider: "fake", StatusCode: 400}}
+	if err := c.Send(context.Background(), notify.Message{To: "user@example.com", Text: "привет"}); err == nil || tr.calls != 1 {
+		t.Fatalf("ожидалась ошибка без повтора, получено %v за %d вызовов", err, tr.calls)
+	}
+}
+
+// countingLimiter считает вызовы Wait.
+type countingLimiter struct{ waits int }
+
+func (l *countingLimiter) Wait(context.Context) error {
+	l.waits++
+	return nil
+}
+
+func TestSuppressedSkipsLimits(t *testing.T) {
+	tr := &fakeTransport{}
+	c := newTestClient(t, "", Options{User: "noreply@example.com", Transport: tr})
+	limiter := &countingLimiter{}
+	c.SetLimiter(limiter)
+	var logs bytes.Buffer
+	c.logger = slog.New(slog.NewTextHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	store := suppression.NewMemoryStore()
+	_ = store.Suppress(context.Background(), suppression.Entry{Channel: Channel, Address: "gone@example.com", Reason: suppression.ReasonBounce})
+	c.SetSuppressionStore(store)
+
+	err := c.Send(context.Background(), notify.Message{To: "gone@example.com", Text: "привет"})
+	if !errors.Is(err, ErrSuppressed) {
+		t.Fatalf("ожидалась ErrSuppressed, получено %v", err)
+	}
+	if limiter.waits != 0 || tr.calls != 0 {
+		t.Fatalf("подавленный адрес не должен занимать лимит: %d ожиданий, %d отправок", limiter.waits, tr.calls)
+	}
+	if !strings.Contains(logs.String(), "level=INFO") || strings.Contains(logs.String(), "level=ERROR") {
+		t.Fatalf("пропуск подавленного адреса не является ошибкой: %s", logs.String())
+	}
+}
+
+func TestProviderHTTPOptions(t *testing.T) {
+	var path string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		_, _ = w.Write([]byte(`{"id":"<mg-1@mg.example.com>","message":"Queued. Thank you."}`))
+	}))
+	t.Cleanup(srv.Close)
+
+	c, err := NewClient("",
+		WithFrom("noreply@example.com", ""),
+		WithTransport(providers.NewMailgun("mg.example.com", "key-test", nil)),
+		WithHTTPClient(srv.Client()),
+		WithBaseURL(srv.URL+"/"),
+	)
+	if err != nil {
+		t.Fatalf("Ошибка NewClient: %v", err)
+	}
+	if err := c.Send(context.Background(), notify.Message{To: "user@example.com", Subject: "Счёт", Text: "оплачен"}); err != nil {
+		t.Fatalf("Ошибка Send: %v", err)
+	}
+	if path != "/v3/mg.example.com/messages" {
+		t.Fatalf("запрос ушёл не по адресу из WithBaseURL: %s", path)
+	}
+
+	if _, err := NewClient("smtp.example.com:587", WithBaseURL(srv.URL)); err == nil {
+		t.Fatal("для SMTP адрес API задаваться не должен")
+	}
+	if _, err := NewClient("smtp.example.com:587", WithHTTPClient(srv.Client())); err == nil {
+		t.Fatal("для SMTP HTTP-клиент задаваться не должен")
+	}
+}
+
+func TestStandardHeaders(t *testing.T) {
+	c := newTestClient(t, "", Options{User: "noreply@example.com", Transport: &fakeTransport{}})
+	tr := &fakeTransport{}
+	c.SetTransport(tr)
+
+	res, err := c.SendMessage(context.Background(), MessageOptions{ID: "d-1", To: "user@example.com", Subject: "Отчёт", Body: "готов"})
+	if err != nil {
+		t.Fatalf("Ошибка SendMessage: %v", err)
+	}
+	if res.ID != "d-1" || res.MessageID != "d-1@example.com" || res.Date.IsZero() || res.ProviderID != "m1" {
+		t.Fatalf("неожиданный результат отправки: %+v", res)
+	}
+	if tr.got.Headers["Message-ID"] != "<d-1@example.com>" {
+		t.Fatalf("Message-ID должен передаваться провайдеру: %v", tr.got.Headers)
+	}
+
+	var raw strings.Builder
+	_, _ = tr.got.Raw.WriteTo(&raw)
+	parsed, err := mail.ReadMessage(strings.NewReader(raw.String()))
+	if err != nil {
+		t.Fatalf("письмо не разбирается: %v", err)
+	}
+	if parsed.Header.Get("Message-Id") != "<d-1@example.com>" || parsed.Header.Get("Mime-Version") != "1.0" {
+		t.Fatalf("нет Message-ID или MIME-Version: %v", parsed.Header)
+	}
+	if date, err := parsed.Header.Date(); err != nil || !date.Equal(res.Date.Truncate(time.Second)) {
+		t.Fatalf("некорректный Date: %q, %v", parsed.Header.Get("Date"), err)
+	}
+
+	res, _ = c.SendMessage(context.Background(), MessageOptions{ID: "a b@c", To: "user@example.com"})
+	if strings.ContainsAny(strings.TrimSuffix(res.MessageID, "@example.com"), " @") {
+		t.Fatalf("недопустимый идентификатор попал в Message-ID: %q", res.MessageID)
+	}
+}
+
+func TestPerMessageSender(t *testing.T) {
+	c := newTestClient(t, "", Options{User: "noreply@example.com", FromName: "Example", Transport: &fakeTransport{}})
+	tr := &fakeTransport{}
+	c.SetTransport(tr)
+
+	err := c.SendText(MessageOptions{
+		To:       "user@example.com",
+		Subject:  "Заявка",
+		Body:     "принята",
+		From:     "support@example.com",
+		FromName: "Поддержка",
+		ReplyTo:  "ticket-42@example.com",
+	})
+	if err != nil {
+		t.Fatalf("Ошибка SendText: %v", err)
+	}
+	if tr.got.From != "support@example.com" || tr.got.FromName != "Поддержка" || tr.got.ReplyTo != "ticket-42@example.com" {
+		t.Fatalf("отправитель письма не переопределён: %+v", tr.got)
+	}
+	var raw strings.Builder
+	_, _ = tr.got.Raw.WriteTo(&raw)
+	if !strings.Contains(raw.String(), "Reply-To: ticket-42@example.com\r\n") || !strings.Contains(raw.String(), "<support@example.com>") {
+		t.Fatalf("в письме нет переопределённых From и Reply-To:\n%s", raw.String())
+	}
+
+	if err := c.SendText(MessageOptions{To: "user@example.com", Body: "x"}); err != nil || tr.got.From != "noreply@example.com" || tr.got.FromName != "Example" {
+		t.Fatalf("без переопределения письмо уходит от клиента: %+v, %v", tr.got, err)
+	}
+	if err := c.SendText(MessageOptions{To: "user@example.com", ReplyTo: "not an address\r\nBcc: x@y"}); err == nil {
+		t.Fatal("некорректный Reply-To должен отклоняться")
+	}
+}
+
+func TestSendRaw(t *testing.T) {
+	addr, data := fakeSMTP(t)
+
+	c := newTestClient(t, addr, Options{User: "noreply@example.com", Password: "x"})
+	raw := "From: noreply@example.com\r\nTo: user@example.com\r\nSubject: raw\r\nContent-Type: text/html; charset=utf-8\r\n\r\n<b>привет</b>\r\n"
+	if err := c.SendRaw(context.Background(), RawOptions{To: "user@example.com", Message: []byte(raw)}); err != nil {
+		t.Fatalf("Ошибка SendRaw: %v", err)
+	}
+	// DotReader отдаёт строки с LF вместо CRLF
+	if got := <-data; got != strings.ReplaceAll(raw, "\r\n", "\n") {
+		t.Fatalf("письмо должно уйти без изменений, получено %q", got)
+	}
+
+	// SendGrid не принимает MIME
+	c.SetTransport(&fakeTransport{})
+	if err := c.SendRaw(context.Background(), RawOptions{To: "user@example.com", Message: []byte(raw)}); !errors.Is(err, ErrRawUnsupported) {
+		t.Fatalf("ожидалась ErrRawUnsupported, получено %v", err)
+	}
+
+	// Несколько получателей и свой адрес конверта: каждому отдельная попытка в журнале
+	tr := &rawTransport{}
+	c.SetTransport(tr)
+	log := delivery.NewMemoryLog()
+	c.SetDeliveryLog(log)
+	err := c.SendRaw(context.Background(), RawOptions{
+		From: "bounces@example.com", To: "a@example.com", Recipients: []string{"b@example.com"}, UserID: "u1", Message: []byte(raw),
+	})
+	if err != nil {
+		t.Fatalf("Ошибка SendRaw: %v", err)
+	}
+	if strings.Join(tr.sent, ",") != "bounces@example.com>a@example.com,bounces@example.com>b@example.com" {
+		t.Fatalf("неверные конверты писем: %v", tr.sent)
+	}
+	if records, _ := log.History(context.Background(), "u1"); len(records) != 2 || records[0].ID == records[1].ID {
+		t.Fatalf("каждому получателю нужна своя запись в журнале: %+v", records)
+	}
+	if err := c.SendRaw(context.Background(), RawOptions{From: "bounces", To: "a@example.com", Message: []byte(raw)}); err == nil {
+		t.Fatal("некорректный адрес конверта должен отклоняться")
+	}
+}
+
+// rawTransport — провайдер, принимающий письма в формате MIME.
+type rawTransport struct {
+	fakeTransport
+	sent []string // from>to по порядку отправки
+}
+
+func (t *rawTransport) SendRaw(_ context.Context, from, to string, _ []byte) (providers.Result, error) {
+	t.sent = append(t.sent, from+">"+to)
+	return providers.Result{Provider: "fake"}, nil
+}
+
+func TestInlineImages(t *testing.T) {
+	addr, data := fakeSMTP(t)
+
+	c := newTestClient(t, addr, Options{User: "noreply@example.com", Password: "x"})
+
+	logo := c.Attach(attachment.File{Name: "logo.png", ContentType: "image/png", Data: []byte("PNG")})
+	if err := c.SendText(MessageOptions{To: "user@example.com", Body: "x", Inline: map[string]*attachment.Encoded{"logo": logo}}); err == nil {
+		t.Fatal("встроенные картинки без HTML должны отклоняться")
+	}
+	err := c.SendText(MessageOptions{
+		To: "user@example.com", Subject: "Отчёт", Body: "отчёт готов",
+		HTML:   `<img src="cid:logo"><p>отчёт готов</p>`,
+		Inline: map[string]*attachment.Encoded{"logo": logo},
+	})
+	if err != nil {
+		t.Fatalf("Ошибка SendText: %v", err)
+	}
+
+	m, err := mail.ReadMessage(bufio.NewReader(strings.NewReader(<-data)))
+	if err != nil {
+		t.Fatalf("письмо не разбирается: %v", err)
+	}
+	// multipart/alternative: text/plain и multipart/related из text/html и картинки
+	var parts []string
+	var walk func(contentType string, body io.Reader)
+	walk = func(contentType string, body io.Reader) {
+		mediaType, params, err := mime.ParseMediaType(contentType)
+		if err != nil {
+			t.Fatalf("некорректный Content-Type %q: %v", contentType, err)
+		}
+		parts = append(parts, mediaType)
+		if !strings.HasPrefix(mediaType, "multipart/") {
+			return
+		}
+		r := multipart.NewReader(body, params["boundary"])
+		for {
+			p, err := r.NextPart()
+			if err == io.EOF {
+				return
+			}
+			if err != nil {
+				t.Fatalf("некорректная часть письма: %v", err)
+			}
+			if id := p.Header.Get("Content-Id"); id != "" {
+				parts = append(parts, id)
+			}
+			walk(p.Header.Get("Content-Type"), p)
+		}
+	}
+	walk(m.Header.Get("Content-Type"), m.Body)
+
+	want := []string{"multipart/alternative", "text/plain", "multipart/related", "text/html", "<logo>", "image/png"}
+	if strings.Join(parts, " ") != strings.Join(want, " ") {
+		t.Fatalf("неожиданная структура письма: %q", parts)
+	}
+}
+
+// concurrentTransport считает одновременные отправки по доменам получателей.
+type concurrentTransport struct {
+	mu      sync.Mutex
+	current map[string]int
+	peak    map[string]int
+}
+
+func (t *concurrentTransport) Name() string { return "fake" }
+
+func (t *concurrentTransport) Send(_ context.Context, msg providers.Message) (providers.Result, error) {
+	domain := msg.To[strings.LastIndexByte(msg.To, '@')+1:]
+	t.mu.Lock()
+	t.current[domain]++
+	t.peak[domain] = max(t.peak[domain], t.current[domain])
+	t.mu.Unlock()
+
+	time.Sleep(20 * time.Millisecond)
+
+	t.mu.Lock()
+	t.current[domain]--
+	t.mu.Unlock()
+	return providers.Result{Provider: "fake"}, nil
+}
+
+func TestDomainLimits(t *testing.T) {
+	tr := &concurrentTransport{current: map[string]int{}, peak: map[string]int{}}
+	c, err := NewClient("", WithFrom("noreply@example.com", ""), WithTransport(tr), WithRateLimit(1000, 100),
+		WithDomainLimits(DomainLimit{Domains: []string{"gmail.com", "googlemail.com"}, Rate: 1000, Connections: 2}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recipients := []string{"a@gmail.com", "b@gmail.com", "c@googlemail.com", "d@GMAIL.com", "e@gmail.com"}
+	for i := range 4 {
+		recipients = append(recipients, fmt.Sprintf("user%d@example.org", i))
+	}
+	for _, res := range c.SendMessaging(SendingOptions{Recipients: recipients, Body: "x"}) {
+		if res.Error != nil {
+			t.Fatalf("%s: %v", res.To, res.Error)
+		}
+	}
+
+	gmail := tr.peak["gmail.com"] + tr.peak["googlemail.com"]
+	if tr.peak["gmail.com"] > 2 || tr.peak["googlemail.com"] > 2 || gmail == 0 {
+		t.Errorf("на домены Gmail не больше 2 одновременных отправок, получено %v", tr.peak)
+	}
+	if tr.peak["example.org"] < 3 {
+		t.Errorf("домены без лимита не должны ограничиваться, пик %d", tr.peak["example.org"])
+	}
+
+	if err := c.SetDomainLimits(DomainLimit{Domains: []string{"gmail.com"}}); err == nil {
+		t.Error("лимит без rate должен отклоняться")
+	}
+}
+
+func TestSMTPError(t *testing.T) {
+	cases := []struct {
+		reply                            string
+		status                           string
+		permanent, throttled, badAddress bool
+	}{
+		{"550 5.1.1 <user@example.com>: Recipient address rejected: User unknown", "5.1.1", true, false, true},
+		{"550 Requested action not taken: mailbox unavailable", "", true, false, true},
+		{"554 5.7.1 Message rejected as spam", "5.7.1", true, false, false},
+		{"452 4.2.2 Mailbox full", "4.2.2", false, false, false},
+		{"450 4.7.28 Our system has detected an unusual rate of unsolicited mail", "4.7.28", false, true, false},
+		{"451 4.7.650 The mail server has been temporarily rate limited due to IP reputation", "4.7.650", false, true, false},
+		{"421 Try again later, ratelimit exceeded", "", false, true, false},
+	}
+	for _, tc := range cases {
+		addr, _ := fakeSMTPReply(t, tc.reply)
+		c := newTestClient(t, addr, Options{User: "noreply@example.com", Password: "x"})
+
+		res := c.SendMessaging(SendingOptions{Recipients: []string{"user@example.com"}, Body: "x"})[0]
+		var smtpErr *SMTPError
+		if !errors.As(res.Error, &smtpErr) || res.SMTP != smtpErr {
+			t.Fatalf("%q: ожидалась SMTPError в Error и SMTP, получено %v", tc.reply, res.Error)
+		}
+		if smtpErr.Status != tc.status || smtpErr.Permanent != tc.permanent || smtpErr.Throttled != tc.throttled || smtpErr.BadAddress != tc.badAddress {
+			t.Errorf("%q: разобрано неверно: %+v", tc.reply, smtpErr)
+		}
+		if !strings.Contains(res.Error.Error(), tc.reply[4:]) {
+			t.Errorf("текст ошибки должен содержать ответ сервера: %v", res.Error)
+		}
+	}
+}
+
+// fakeSentFolder запоминает сохранённые копии писем.
+type fakeSentFolder struct {
+	got [][]byte
+}
+
+func (f *fakeSentFolder) Append(_ context.Context, msg []byte) error {
+	f.got = append(f.got, msg)
+	return nil
+}
+
+func TestSentFolder(t *testing.T) {
+	addr, data := fakeSMTP(t)
+
+	c := newTestClient(t, addr, Options{User: "noreply@example.com", Password: "x"})
+	sent := &fakeSentFolder{}
+	c.SetSentFolder(sent)
+
+	if err := c.SendText(MessageOptions{To: "user@example.com", Subject: "Заказ", Body: "заказ принят"}); err != nil {
+		t.Fatalf("Ошибка SendText: %v", err)
+	}
+	delivered := <-data
+	if len(sent.got) != 1 || !strings.Contains(string(sent.got[0]), "Message-ID:") ||
+		strings.ReplaceAll(string(sent.got[0]), "\r\n", "\n") != strings.TrimSuffix(delivered, "\n") {
+		t.Fatalf("в папке отправленных должна быть копия письма, получено %q", sent.got)
+	}
+
+	// Готовое письмо нескольким получателям сохраняется один раз
+	c.SetTransport(&rawTransport{})
+	err := c.SendRaw(context.Background(), RawOptions{To: "a@example.com", Recipients: []string{"b@example.com"}, Message: []byte("Subject: x\r\n\r\nx\r\n")})
+	if err != nil {
+		t.Fatalf("Ошибка SendRaw: %v", err)
+	}
+	if len(sent.got) != 2 || string(sent.got[1]) != "Subject: x\r\n\r\nx\r\n" {
+		t.Fatalf("ожидалась одна копия готового письма, получено %q", sent.got[1:])
+	}
+
+	// Неотправленное письмо не сохраняется
+	c.SetTransport(&fakeTransport{})
+	_ = c.SendRaw(context.Background(), RawOptions{To: "a@example.com", Message: []byte("Subject: x\r\n\r\nx\r\n")})
+	if len(sent.got) != 2 {
+		t.Fatalf("копия неотправленного письма не сохраняется, получено %d", len(sent.got))
+	}
+}
@@ -0,0 +1,91 @@
+package email
+
+import (
+	"errors"
+	"net/textproto"
+	"regexp"
+	"strings"
+)
+
+// SMTPError — ответ SMTP-сервера с ошибкой, разобранный на поля, чтобы решения о повторе и подавлении
+// адреса принимались по кодам, а не по тексту ошибки. Возвращается отправкой через SMTP и находится
+// через errors.As; текст ошибки совпадает с ответом сервера.
+type SMTPError struct {
+	Code    int    // Код ответа: 421, 450, 550 и т.д.
+	Status  string // Расширенный код статуса по RFC 3463, например 5.1.1; пусто, если сервер его не прислал
+	Message string // Текст ответа сервера
+
+	Permanent  bool // Постоянная ошибка (5xx): повтор того же письма не поможет
+	Throttled  bool // Сервер временно ограничил поток писем от отправителя: стоит снизить частоту
+	BadAddress bool // Адрес получателя не существует: кандидат в список подавления
+
+	err *textproto.Error
+}
+
+// Error возвращает ответ сервера в исходном виде: код и текст.
+func (e *SMTPError) Error() string {
+	return e.err.Error()
+}
+
+// Unwrap возвращает исходную ошибку net/textproto.
+func (e *SMTPError) Unwrap() error {
+	return e.err
+}
+
+// Temporary сообщает, что письмо можно отправить повторно позже (4xx).
+func (e *SMTPError) Temporary() bool {
+	return !e.Permanent
+}
+
+var enhancedStatusRe = regexp.MustCompile(`\b([245])\.(\d{1,3})\.(\d{1,3})\b`)
+
+// throttleStatuses — расширенные коды, которыми почтовые сервисы сообщают об ограничении потока:
+// 4.7.28 (Gmail), 4.7.650 и 4.7.500 (Outlook), 4.7.0 с подсказкой в тексте — многие сервисы.
+var throttleStatuses = map[string]bool{"4.7.28": true, "4.7.650": true, "4.7.500": true}
+
+// throttleHints — фрагменты ответов Gmail, Yahoo, Mail.ru, Яндекса и Outlook об ограничении потока.
+var throttleHints = []string{
+	"rate limit", "ratelimit", "rate-limit", "unusual rate", "too many", "throttl", "try again later",
+	"temporarily deferred", "unexpected volume", "receiving mail at a rate", "slow down",
+}
+
+// badAddressHints — фрагменты ответов без расширенного кода о несуществующем ящике.
+var badAddressHints = []string{"user unknown", "no such user", "does not exist", "mailbox unavailable", "recipient not found"}
+
+// parseSMTPError находит в err ответ SMTP-сервера и заменяет его разобранным SMTPError.
+// Остальные ошибки (сети, TLS) возвращаются без изменений.
+func parseSMTPError(err error) error {
+	var tpErr *textproto.Error
+	if !errors.As(err, &tpErr) {
+		return err
+	}
+
+	e := &SMTPError{
+		Code:      tpErr.Code,
+		Status:    enhancedStatusRe.FindString(tpErr.Msg),
+		Message:   tpErr.Msg,
+		Permanent: tpErr.Code >= 500,
+		err:       tpErr,
+	}
+	msg := strings.ToLower(tpErr.Msg)
+	if !e.Permanent {
+		e.Throttled = throttleStatuses[e.Status] || containsAny(msg, throttleHints)
+	}
+	// 5.1.x — ошибки адреса получателя (5.1.1 нет такого ящика, 5.1.2 нет такого домена, 5.1.10 нулевой MX)
+	if e.Permanent && strings.HasPrefix(e.Status, "5.1.") && e.Status != "5.1.8" && e.Status != "5.1.7" {
+		e.BadAddress = true
+	}
+	if e.Permanent && e.Status == "" && tpErr.Code == 550 && containsAny(msg, badAddressHints) {
+		e.BadAddress = true
+	}
+	return e
+}
+
+func containsAny(s string, hints []string) bool {
+	for _, h := range hints {
+		if strings.Contains(s, h) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,42 @@
+package tracking
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/epheer/notephee/delivery"
+)
+
+// pixel — прозрачный GIF 1×1.
+var pixel = []byte("GIF89a\x01\x00\x01\x00\x80\x00\x00\x00\x00\x00\x00\x00\x00!\xf9\x04\x01\x00\x00\x00\x00,\x00\x00\x00\x00\x01\x00\x01\x00\x00\x02\x02D\x01\x00;")
+
+// Handler возвращает HTTP-обработчик адресов Tracker: записывает в log открытие письма и отдаёт пиксель
+// или записывает переход и перенаправляет на исходную ссылку.
+//
+// Ошибка записи в журнал не мешает получателю: пиксель отдаётся, а перенаправление выполняется.
+func Handler(t *Tracker, log delivery.EngagementLog, logger *slog.Logger) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claims, err := t.Verify(r.URL.Query().Get("t"))
+		if err != nil {
+			http.Error(w, "ссылка недействительна", http.StatusBadRequest)
+			return
+		}
+
+		e := delivery.Engagement{ID: claims.ID, Action: delivery.ActionOpen, URL: claims.URL, Time: time.Now()}
+		if claims.URL != "" {
+			e.Action = delivery.ActionClick
+		}
+		if err := log.SaveEngagement(r.Context(), e); err != nil {
+			logger.Error("не удалось записать действие получателя", "id", e.ID, "action", e.Action, "error", err)
+		}
+
+		if e.Action == delivery.ActionClick {
+			http.Redirect(w, r, claims.URL, http.StatusFound)
+			return
+		}
+		w.Header().Set("Content-Type", "image/gif")
+		w.Header().Set("Cache-Control", "no-store, no-cache, must-revalidate")
+		_, _ = w.Write(pixel)
+	})
+}
@@ -0,0 +1,106 @@
+// Package tracking отслеживает открытия писем и переходы по ссылкам.
+//
+// Tracker встраивает в HTML письма пиксель и заменяет ссылки адресами обработчика Handler,
+// который записывает действия получателя в журнал доставки по идентификатору попытки.
+// Адреса подписываются, поэтому обработчик не перенаправляет на ссылки, которых не было в письме.
+package tracking
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Claims — данные, зашитые в адрес отслеживания.
+type Claims struct {
+	ID  string `json:"i"`           // Идентификатор попытки в журнале доставки
+	URL string `json:"u,omitempty"` // Исходная ссылка (пусто — пиксель открытия)
+}
+
+// Tracker выпускает и проверяет подписанные адреса отслеживания.
+type Tracker struct {
+	key     []byte // Секрет HMAC-SHA256
+	baseURL string // Адрес HTTP-обработчика отслеживания
+}
+
+// NewTracker создаёт Tracker.
+//
+// key — секрет для подписи (не короче 32 байт).
+// baseURL — публичный адрес, на котором смонтирован Handler.
+func NewTracker(key []byte, baseURL string) (*Tracker, error) {
+	if len(key) < 32 {
+		return nil, fmt.Errorf("ключ подписи должен быть не короче 32 байт")
+	}
+	if _, err := url.Parse(baseURL); err != nil {
+		return nil, fmt.Errorf("некорректный адрес обработчика отслеживания: %w", err)
+	}
+	return &Tracker{key: key, baseURL: baseURL}, nil
+}
+
+// hrefPattern находит ссылки http и https в атрибутах href.
+var hrefPattern = regexp.MustCompile(`(?i)(<a\s[^>]*?href\s*=\s*)("https?://[^"]*"|'https?://[^']*')`)
+
+// Rewrite возвращает HTML письма попытки id с пикселем открытия перед </body> (или в конце) и ссылками
+// http и https, заменёнными адресами перехода. Остальные ссылки (mailto:, cid:, якоря) не меняются.
+func (t *Tracker) Rewrite(body, id string) string {
+	body = hrefPattern.ReplaceAllStringFunc(body, func(m string) string {
+		parts := hrefPattern.FindStringSubmatch(m)
+		quoted := parts[2]
+		link := html.UnescapeString(quoted[1 : len(quoted)-1])
+		return parts[1] + `"` + html.EscapeString(t.URL(id, link)) + `"`
+	})
+
+	pixel := `<img src="` + html.EscapeString(t.URL(id, "")) + `" width="1" height="1" alt="" style="display:none">`
+	if i := strings.LastIndex(strings.ToLower(body), "</body>"); i >= 0 {
+		return body[:i] + pixel + body[i:]
+	}
+	return body + pixel
+}
+
+// URL возвращает адрес отслеживания: перехода по ссылке link или, если link пуст, пикселя открытия.
+func (t *Tracker) URL(id, link string) string {
+	payload, _ := json.Marshal(Claims{ID: id, URL: link})
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	token := encoded + "." + base64.RawURLEncoding.EncodeToString(t.sign(encoded))
+
+	u, _ := url.Parse(t.baseURL)
+	q := u.Query()
+	q.Set("t", token)
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+// Verify проверяет подпись адреса отслеживания и возвращает его содержимое.
+func (t *Tracker) Verify(token string) (Claims, error) {
+	encoded, sig, ok := strings.Cut(token, ".")
+	if !ok {
+		return Claims{}, fmt.Errorf("некорректный формат токена")
+	}
+
+	got, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil || !hmac.Equal(got, t.sign(encoded)) {
+		return Claims{}, fmt.Errorf("неверная подпись токена")
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return Claims{}, fmt.Errorf("некорректный формат токена: %w", err)
+	}
+	var claims Claims
+	if err := json.Unmarshal(payload, &claims); err != nil || claims.ID == "" {
+		return Claims{}, fmt.Errorf("некорректный формат токена")
+	}
+	return claims, nil
+}
+
+func (t *Tracker) sign(payload string) []byte {
+	mac := hmac.New(sha256.New, t.key)
+	mac.Write([]byte(payload))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,86 @@
+package tracking_test
+
+import (
+	"bytes"
+	"context"
+	"image/gif"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/epheer/notephee/delivery"
+	"github.com/epheer/notephee/email/tracking"
+)
+
+var testKey = []byte("0123456789abcdef0123456789abcdef")
+
+func TestRewrite(t *testing.T) {
+	tracker, err := tracking.NewTracker(testKey, "https://example.com/track")
+	if err != nil {
+		t.Fatalf("Ошибка NewTracker: %v", err)
+	}
+
+	html := `<html><body><a href="https://example.com/report?a=1&amp;b=2">отчёт</a> <a href="mailto:x@example.com">почта</a></body></html>`
+	got := tracker.Rewrite(html, "d1")
+
+	if !strings.Contains(got, `href="mailto:x@example.com"`) || strings.Contains(got, `href="https://example.com/report`) {
+		t.Fatalf("ссылки переписаны неверно: %s", got)
+	}
+	if !strings.Contains(got, `style="display:none"></body>`) {
+		t.Fatalf("пиксель должен стоять перед </body>: %s", got)
+	}
+
+	hrefs := regexp.MustCompile(`(?:href|src)="([^"]+)"`).FindAllStringSubmatch(got, -1)
+	var claims []tracking.Claims
+	for _, m := range hrefs {
+		u, err := url.Parse(strings.ReplaceAll(m[1], "&amp;", "&"))
+		if err != nil || !strings.HasPrefix(m[1], "https://example.com/track?") {
+			continue
+		}
+		c, err := tracker.Verify(u.Query().Get("t"))
+		if err != nil {
+			t.Fatalf("Ошибка Verify: %v", err)
+		}
+		claims = append(claims, c)
+	}
+	if len(claims) != 2 || claims[0].URL != "https://example.com/report?a=1&b=2" || claims[1] != (tracking.Claims{ID: "d1"}) {
+		t.Fatalf("неверное содержимое адресов отслеживания: %+v", claims)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	tracker, _ := tracking.NewTracker(testKey, "https://example.com/track")
+	log := delivery.NewMemoryLog()
+	handler := tracking.Handler(tracker, log, slog.Default())
+
+	open, _ := url.Parse(tracker.URL("d1", ""))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, open.RequestURI(), nil))
+	if _, err := gif.Decode(bytes.NewReader(rec.Body.Bytes())); err != nil || rec.Header().Get("Content-Type") != "image/gif" {
+		t.Fatalf("ожидался пиксель GIF: %v", err)
+	}
+
+	click, _ := url.Parse(tracker.URL("d1", "https://example.com/report"))
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, click.RequestURI(), nil))
+	if rec.Code != http.StatusFound || rec.Header().Get("Location") != "https://example.com/report" {
+		t.Fatalf("ожидалось перенаправление на ссылку, получено %d %q", rec.Code, rec.Header().Get("Location"))
+	}
+
+	// Подделанный адрес не перенаправляет и не записывается
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/track?t=eyJpIjoiZDEiLCJ1IjoiaHR0cHM6Ly9ldmlsLmV4YW1wbGUifQ.AAAA", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("подделанный адрес должен отклоняться, получено %d", rec.Code)
+	}
+
+	engagements, _ := log.Engagements(context.Background(), "d1")
+	if len(engagements) != 2 || engagements[0].Action != delivery.ActionOpen || engagements[1].Action != delivery.ActionClick ||
+		engagements[1].URL != "https://example.com/report" {
+		t.Fatalf("неверные действия получателя: %+v", engagements)
+	}
+}
@@ -0,0 +1,80 @@
+package unsubscribe
+
+import (
+	"html/template"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/epheer/notephee/preferences"
+	"github.com/epheer/notephee/suppression"
+)
+
+// confirmPage показывается при переходе по ссылке из браузера.
+//
+// По RFC 8058 GET-запрос не должен отписывать: почтовые сканеры открывают ссылки автоматически.
+var confirmPage = template.Must(template.New("confirm").Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>Отписка</title></head>
+<body>
+<form method="post">
+<input type="hidden" name="List-Unsubscribe" value="One-Click">
+<p>Отписать {{.}} от рассылки?</p>
+<button type="submit">Отписаться</button>
+</form>
+</body></html>`))
+
+// Handler возвращает HTTP-обработчик одношаговой отписки.
+//
+// POST с токеном в параметре token добавляет адрес в список подавления,
+// GET показывает страницу подтверждения.
+func Handler(signer *Signer, store suppression.Store, logger *slog.Logger) http.Handler {
+	return TopicHandler(signer, store, nil, logger)
+}
+
+// TopicHandler — Handler, который отписывает от темы, а не от всей почты: токен со списком
+// (Signer.Token(address, list)) сохраняет в policy отказ адреса от категории list в канале email,
+// и preferences.Wrap перестаёт пропускать письма этой темы. Токен без списка и policy == nil
+// добавляют адрес в список подавления, как Handler.
+func TopicHandler(signer *Signer, store suppression.Store, policy *preferences.Policy, logger *slog.Logger) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claims, err := signer.Verify(r.URL.Query().Get("token"))
+		if err != nil {
+			http.Error(w, "ссылка отписки недействительна", http.StatusBadRequest)
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			_ = confirmPage.Execute(w, claims.Address)
+		case http.MethodPost:
+			if policy != nil && claims.List != "" {
+				if err := policy.OptOut(r.Context(), claims.Address, "email", claims.List); err != nil {
+					logger.Error("не удалось сохранить отписку от темы", "to", claims.Address, "list", claims.List, "error", err)
+					http.Error(w, "не удалось выполнить отписку", http.StatusInternalServerError)
+					return
+				}
+				logger.Info("адрес отписан от темы", "to", claims.Address, "list", claims.List)
+				w.WriteHeader(http.StatusOK)
+				return
+			}
+			err := store.Suppress(r.Context(), suppression.Entry{
+				Channel:   "email",
+				Address:   claims.Address,
+				Reason:    suppression.ReasonUnsubscribe,
+				List:      claims.List,
+				CreatedAt: time.Now(),
+			})
+			if err != nil {
+				logger.Error("не удалось сохранить отписку", "to", claims.Address, "error", err)
+				http.Error(w, "не удалось выполнить отписку", http.StatusInternalServerError)
+				return
+			}
+			logger.Info("адрес отписан от рассылки", "to", claims.Address, "list", claims.List)
+			w.WriteHeader(http.StatusOK)
+		default:
+			w.Header().Set("Allow", "GET, POST")
+			http.Error(w, "метод не поддерживается", http.StatusMethodNotAllowed)
+		}
+	})
+}
@@ -0,0 +1,103 @@
+package unsubscribe
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Claims — данные, зашитые в токен отписки.
+type Claims struct {
+	Address string `json:"a"`           // Email получателя
+	List    string `json:"l,omitempty"` // Список рассылки (пусто — все рассылки)
+	Expiry  int64  `json:"e,omitempty"` // Unix-время окончания действия (0 — бессрочно)
+}
+
+// Signer выпускает и проверяет подписанные токены отписки.
+type Signer struct {
+	key     []byte        // Секрет HMAC-SHA256
+	baseURL string        // Адрес HTTP-обработчика отписки
+	ttl     time.Duration // Время жизни токена (0 — бессрочно)
+}
+
+// NewSigner создаёт Signer.
+//
+// key — секрет для подписи (не короче 32 байт).
+// baseURL — публичный адрес, на котором смонтирован Handler.
+// ttl — время жизни ссылки, 0 — бессрочно.
+func NewSigner(key []byte, baseURL string, ttl time.Duration) (*Signer, error) {
+	if len(key) < 32 {
+		return nil, fmt.Errorf("ключ подписи должен быть не короче 32 байт")
+	}
+	if _, err := url.Parse(baseURL); err != nil {
+		return nil, fmt.Errorf("некорректный адрес обработчика отписки: %w", err)
+	}
+	return &Signer{key: key, baseURL: baseURL, ttl: ttl}, nil
+}
+
+// Token выпускает токен отписки адреса от списка list.
+func (s *Signer) Token(address, list string) string {
+	claims := Claims{Address: address, List: list}
+	if s.ttl > 0 {
+		claims.Expiry = time.Now().Add(s.ttl).Unix()
+	}
+
+	payload, _ := json.Marshal(claims)
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(s.sign(encoded))
+}
+
+// Verify проверяет подпись и срок действия токена и возвращает его содержимое.
+func (s *Signer) Verify(token string) (Claims, error) {
+	encoded, sig, ok := strings.Cut(token, ".")
+	if !ok {
+		return Claims{}, fmt.Errorf("некорректный формат токена")
+	}
+
+	got, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil || !hmac.Equal(got, s.sign(encoded)) {
+		return Claims{}, fmt.Errorf("неверная подпись токена")
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return Claims{}, fmt.Errorf("некорректный формат токена: %w", err)
+	}
+
+	var claims Claims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return Claims{}, fmt.Errorf("некорректный формат токена: %w", err)
+	}
+	if claims.Expiry > 0 && time.Now().Unix() > claims.Expiry {
+		return Claims{}, fmt.Errorf("срок действия токена истёк")
+	}
+	return claims, nil
+}
+
+// URL возвращает ссылку одношаговой отписки для адреса.
+func (s *Signer) URL(address, list string) string {
+	u, _ := url.Parse(s.baseURL)
+	q := u.Query()
+	q.Set("token", s.Token(address, list))
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+// Headers возвращает заголовки List-Unsubscribe и List-Unsubscribe-Post по RFC 8058.
+func (s *Signer) Headers(address, list string) map[string]string {
+	return map[string]string{
+		"List-Unsubscribe":      "<" + s.URL(address, list) + ">",
+		"List-Unsubscribe-Post": "List-Unsubscribe=One-Click",
+	}
+}
+
+func (s *Signer) sign(payload string) []byte {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write([]byte(payload))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,89 @@
+package unsubscribe_test
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/epheer/notephee/email/unsubscribe"
+	"github.com/epheer/notephee/preferences"
+	"github.com/epheer/notephee/suppression"
+)
+
+var testKey = []byte("0123456789abcdef0123456789abcdef")
+
+func TestTokenRoundTrip(t *testing.T) {
+	signer, err := unsubscribe.NewSigner(testKey, "https://example.com/unsubscribe", 0)
+	if err != nil {
+		t.Fatalf("Ошибка NewSigner: %v", err)
+	}
+
+	token := signer.Token("user@example.com", "news")
+	claims, err := signer.Verify(token)
+	if err != nil {
+		t.Fatalf("Ошибка Verify: %v", err)
+	}
+	if claims.Address != "user@example.com" || claims.List != "news" {
+		t.Fatalf("неверное содержимое токена: %+v", claims)
+	}
+
+	if _, err := signer.Verify(token + "x"); err == nil {
+		t.Fatal("изменённый токен прошёл проверку")
+	}
+}
+
+func TestHandlerOneClick(t *testing.T) {
+	signer, _ := unsubscribe.NewSigner(testKey, "https://example.com/unsubscribe", 0)
+	store := suppression.NewMemoryStore()
+	handler := unsubscribe.Handler(signer, store, slog.Default())
+
+	link, _ := url.Parse(signer.URL("User@Example.com", ""))
+
+	get := httptest.NewRecorder()
+	handler.ServeHTTP(get, httptest.NewRequest(http.MethodGet, link.RequestURI(), nil))
+	if ok, _ := store.IsSuppressed(context.Background(), "email", "user@example.com", ""); ok {
+		t.Fatal("GET-запрос не должен отписывать")
+	}
+
+	req := httptest.NewRequest(http.MethodPost, link.RequestURI(), strings.NewReader("List-Unsubscribe=One-Click"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("неожиданный код ответа: %d", rec.Code)
+	}
+	if ok, _ := store.IsSuppressed(context.Background(), "email", "user@example.com", ""); !ok {
+		t.Fatal("адрес не попал в список подавления")
+	}
+}
+
+func TestTopicHandler(t *testing.T) {
+	signer, _ := unsubscribe.NewSigner(testKey, "https://example.com/unsubscribe", 0)
+	store := suppression.NewMemoryStore()
+	policy := preferences.NewPolicy(preferences.NewMemoryStore())
+	handler := unsubscribe.TopicHandler(signer, store, policy, slog.Default())
+
+	for _, list := range []string{"billing", ""} {
+		link, _ := url.Parse(signer.URL("user@example.com", list))
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, link.RequestURI(), nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("неожиданный код ответа: %d", rec.Code)
+		}
+
+		ctx := context.Background()
+		suppressed, _ := store.IsSuppressed(ctx, "email", "user@example.com", "")
+		billing, _ := policy.Allowed(ctx, "user@example.com", "email", "billing")
+		if list != "" && (suppressed || billing) {
+			t.Fatal("отписка от темы должна отключать только тему")
+		}
+		if list == "" && !suppressed {
+			t.Fatal("отписка без темы должна добавлять адрес в список подавления")
+		}
+	}
+}
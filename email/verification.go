@@ -0,0 +1,322 @@
+package email
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/epheer/notephee/otp"
+)
+
+// VerificationMode — способ подтверждения адреса.
+type VerificationMode string
+
+const (
+	VerifyByLink VerificationMode = "link" // Подписанная ссылка на обработчик приложения
+	VerifyByCode VerificationMode = "code" // Шестизначный код, который пользователь вводит вручную вместе с адресом
+)
+
+// DefaultVerificationTTL — срок действия ссылки или кода, если в VerificationOptions не задан другой.
+const DefaultVerificationTTL = 30 * time.Minute
+
+// Шаблоны письма по умолчанию.
+const (
+	DefaultVerificationSubject = "Подтверждение адреса электронной почты"
+	DefaultVerificationBody    = `Здравствуйте!
+
+{{if .Link}}Чтобы подтвердить адрес {{.Address}}, перейдите по ссылке:
+{{.Link}}{{else}}Код подтверждения адреса {{.Address}}: {{.Code}}{{end}}
+
+Срок действия истекает через {{.TTL}}. Если вы не запрашивали подтверждение, проигнорируйте это письмо.
+`
+)
+
+// ErrVerificationNotFound возвращается, если код или ссылка неизвестны, уже использованы или истекли.
+var ErrVerificationNotFound = errors.New("код подтверждения не найден или истёк")
+
+// ErrVerificationExists возвращается VerificationStore.Put, если ключ уже занят действующим подтверждением.
+var ErrVerificationExists = errors.New("код подтверждения уже выдан")
+
+// Verification — ожидающее подтверждение адреса.
+type Verification struct {
+	Address   string    // Подтверждаемый адрес
+	UserID    string    // Пользователь, запросивший подтверждение (может быть пустым)
+	CreatedAt time.Time // Время выпуска
+	ExpiresAt time.Time // Время окончания действия
+}
+
+// VerificationStore хранит выданные подтверждения до использования. Ключ — идентификатор ссылки
+// или «code:<адрес>» для кода. Реализации должны быть безопасны для конкурентного использования.
+type VerificationStore interface {
+	// Put сохраняет подтверждение под ключом key или возвращает ErrVerificationExists,
+	// если ключ занят действующим подтверждением.
+	Put(ctx context.Context, key string, v Verification) error
+	// Take возвращает подтверждение и удаляет его, чтобы код нельзя было использовать повторно.
+	// Для неизвестного или истёкшего ключа возвращает ErrVerificationNotFound.
+	Take(ctx context.Context, key string) (Verification, error)
+}
+
+// MemoryVerificationStore — VerificationStore в памяти процесса.
+type MemoryVerificationStore struct {
+	mu      sync.Mutex
+	pending map[string]Verification
+}
+
+// NewMemoryVerificationStore создаёт пустое хранилище подтверждений в памяти.
+func NewMemoryVerificationStore() *MemoryVerificationStore {
+	return &MemoryVerificationStore{pending: make(map[string]Verification)}
+}
+
+// Put сохраняет подтверждение и удаляет истёкшие.
+func (s *MemoryVerificationStore) Put(_ context.Context, key string, v Verification) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for k, p := range s.pending {
+		if !now.Before(p.ExpiresAt) {
+			delete(s.pending, k)
+		}
+	}
+	if _, ok := s.pending[key]; ok {
+		return ErrVerificationExists
+	}
+	s.pending[key] = v
+	return nil
+}
+
+// Take возвращает и удаляет подтверждение.
+func (s *MemoryVerificationStore) Take(_ context.Context, key string) (Verification, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	v, ok := s.pending[key]
+	if !ok {
+		return Verification{}, ErrVerificationNotFound
+	}
+	delete(s.pending, key)
+	if !time.Now().Before(v.ExpiresAt) {
+		return Verification{}, ErrVerificationNotFound
+	}
+	return v, nil
+}
+
+// VerificationOptions — настройки VerificationManager.
+type VerificationOptions struct {
+	Mode        VerificationMode // Ссылка или код (по умолчанию — ссылка)
+	Key         []byte           // Секрет подписи ссылок и хэшей кодов, не короче 32 байт (обязателен для VerifyByLink)
+	BaseURL     string           // Адрес обработчика подтверждения в приложении; токен добавляется параметром token
+	TTL         time.Duration    // Срок действия (0 — DefaultVerificationTTL)
+	MaxAttempts int              // Число попыток ввода кода для VerifyByCode (0 — otp.DefaultMaxAttempts)
+	Subject     string           // Шаблон темы письма (text/template, пусто — DefaultVerificationSubject)
+	Body        string           // Шаблон текста письма (text/template, пусто — DefaultVerificationBody)
+}
+
+// VerificationData — данные, доступные шаблонам письма.
+type VerificationData struct {
+	Address string        // Подтверждаемый адрес
+	UserID  string        // Пользователь, запросивший подтверждение
+	Link    string        // Ссылка подтверждения (для VerifyByLink)
+	Code    string        // Код подтверждения (для VerifyByCode)
+	TTL     time.Duration // Срок действия
+}
+
+// VerificationManager подтверждает владение адресом: отправляет письмо со ссылкой или кодом
+// и по предъявленному коду возвращает адрес, на который он был отправлен.
+type VerificationManager struct {
+	client  *Client
+	store   VerificationStore
+	codes   *otp.Manager // Коды и попытки их ввода (для VerifyByCode)
+	mode    VerificationMode
+	key     []byte
+	baseURL string
+	ttl     time.Duration
+	subject *template.Template
+	body    *template.Template
+	logger  *slog.Logger
+}
+
+// NewVerificationManager создаёт VerificationManager, отправляющий письма через client.
+func NewVerificationManager(client *Client, opts VerificationOptions, logger *slog.Logger) (*VerificationManager, error) {
+	if opts.Mode == "" {
+		opts.Mode = VerifyByLink
+	}
+	switch opts.Mode {
+	case VerifyByLink:
+		if len(opts.Key) < 32 {
+			return nil, fmt.Errorf("ключ подписи ссылок подтверждения должен быть не короче 32 байт")
+		}
+		if _, err := url.Parse(opts.BaseURL); err != nil || opts.BaseURL == "" {
+			return nil, fmt.Errorf("некорректный адрес обработчика подтверждения: %q", opts.BaseURL)
+		}
+	case VerifyByCode:
+	default:
+		return nil, fmt.Errorf("неизвестный способ подтверждения: %q", opts.Mode)
+	}
+	if opts.TTL <= 0 {
+		opts.TTL = DefaultVerificationTTL
+	}
+	if opts.Subject == "" {
+		opts.Subject = DefaultVerificationSubject
+	}
+	if opts.Body == "" {
+		opts.Body = DefaultVerificationBody
+	}
+
+	subject, err := template.New("subject").Parse(opts.Subject)
+	if err != nil {
+		return nil, fmt.Errorf("некорректный шаблон темы письма подтверждения: %w", err)
+	}
+	body, err := template.New("body").Parse(opts.Body)
+	if err != nil {
+		return nil, fmt.Errorf("некорректный шаблон письма подтверждения: %w", err)
+	}
+
+	vm := &VerificationManager{
+		client:  client,
+		store:   NewMemoryVerificationStore(),
+		mode:    opts.Mode,
+		key:     opts.Key,
+		baseURL: opts.BaseURL,
+		ttl:     opts.TTL,
+		subject: subject,
+		body:    body,
+		logger:  logger,
+	}
+	if opts.Mode == VerifyByCode {
+		// Коды выпускает и проверяет otp: лимит попыток и одноразовость общие с одноразовыми кодами
+		vm.codes, err = otp.New(nil, otp.Options{TTL: opts.TTL, MaxAttempts: opts.MaxAttempts, Key: opts.Key}, logger)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return vm, nil
+}
+
+// SetStore заменяет хранилище выданных подтверждений (по умолчанию — в памяти процесса).
+// Общее хранилище нужно, если письмо отправляет один экземпляр сервиса, а код проверяет другой.
+func (vm *VerificationManager) SetStore(store VerificationStore) {
+	vm.store = store
+}
+
+// SetCodeStore заменяет хранилище кодов и счётчиков попыток VerifyByCode (по умолчанию — в памяти процесса).
+// Для нескольких экземпляров сервиса оно должно быть общим, как и хранилище SetStore.
+func (vm *VerificationManager) SetCodeStore(store otp.Store) {
+	if vm.codes != nil {
+		vm.codes.SetStore(store)
+	}
+}
+
+// Send выпускает ссылку или код для address и отправляет письмо. userID сохраняется
+// в подтверждении, чтобы приложение сверило его с пользователем, предъявившим код.
+func (vm *VerificationManager) Send(ctx context.Context, address, userID string) error {
+	now := time.Now()
+	v := Verification{Address: address, UserID: userID, CreatedAt: now, ExpiresAt: now.Add(vm.ttl)}
+	data := VerificationData{Address: address, UserID: userID, TTL: vm.ttl}
+
+	switch vm.mode {
+	case VerifyByLink:
+		id := uuid.NewString()
+		if err := vm.store.Put(ctx, id, v); err != nil {
+			return err
+		}
+		data.Link = vm.link(id)
+	case VerifyByCode:
+		key := codeKey(address)
+		// Новый код заменяет прежний код того же адреса
+		if _, err := vm.store.Take(ctx, key); err != nil && !errors.Is(err, ErrVerificationNotFound) {
+			return err
+		}
+		if err := vm.store.Put(ctx, key, v); err != nil {
+			return err
+		}
+		code, err := vm.codes.Issue(ctx, key, Channel, address)
+		if err != nil {
+			return err
+		}
+		data.Code = code
+	}
+
+	var subject, body bytes.Buffer
+	if err := vm.subject.Execute(&subject, data); err != nil {
+		return fmt.Errorf("ошибка шаблона темы письма подтверждения: %w", err)
+	}
+	if err := vm.body.Execute(&body, data); err != nil {
+		return fmt.Errorf("ошибка шаблона письма подтверждения: %w", err)
+	}
+
+	if err := vm.client.sendText(ctx, MessageOptions{To: address, Subject: subject.String(), Body: body.String(), UserID: userID}); err != nil {
+		return err
+	}
+	vm.logger.Info("отправлено письмо подтверждения адреса", "to", address, "mode", vm.mode)
+	return nil
+}
+
+// Verify проверяет код из письма, отправленного на address, или токен из ссылки и возвращает
+// подтверждение с адресом. Код и ссылка действуют один раз.
+//
+// Код действует только для своего адреса: после MaxAttempts неверных попыток он удаляется
+// и возвращается otp.ErrTooManyAttempts. Для ссылки address может быть пустым; непустой адрес
+// должен совпасть с адресом подтверждения.
+func (vm *VerificationManager) Verify(ctx context.Context, address, code string) (Verification, error) {
+	code = strings.TrimSpace(code)
+	if vm.mode == VerifyByCode {
+		key := codeKey(address)
+		switch err := vm.codes.Validate(ctx, key, code); {
+		case errors.Is(err, otp.ErrTooManyAttempts):
+			_, _ = vm.store.Take(ctx, key)
+			return Verification{}, err
+		case errors.Is(err, otp.ErrNotFound), errors.Is(err, otp.ErrInvalid):
+			return Verification{}, ErrVerificationNotFound
+		case err != nil:
+			return Verification{}, err
+		}
+		return vm.store.Take(ctx, key)
+	}
+
+	id, sig, ok := strings.Cut(code, ".")
+	if !ok || !hmac.Equal([]byte(sig), []byte(vm.sign(id))) {
+		return Verification{}, ErrVerificationNotFound
+	}
+	v, err := vm.store.Take(ctx, id)
+	if err != nil {
+		return Verification{}, err
+	}
+	if address != "" && !strings.EqualFold(strings.TrimSpace(address), v.Address) {
+		return Verification{}, ErrVerificationNotFound
+	}
+	return v, nil
+}
+
+// codeKey возвращает ключ кода подтверждения адреса.
+func codeKey(address string) string {
+	return "code:" + strings.ToLower(strings.TrimSpace(address))
+}
+
+// link возвращает ссылку подтверждения с подписанным токеном id.
+func (vm *VerificationManager) link(id string) string {
+	u, _ := url.Parse(vm.baseURL)
+	q := u.Query()
+	q.Set("token", id+"."+vm.sign(id))
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+// sign возвращает подпись HMAC-SHA256 идентификатора ссылки в base64url без выравнивания.
+func (vm *VerificationManager) sign(id string) string {
+	mac := hmac.New(sha256.New, vm.key)
+	mac.Write([]byte(id))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,125 @@
+package email
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/epheer/notephee/otp"
+)
+
+func newVerificationClient() (*Client, *fakeTransport) {
+	tr := &fakeTransport{}
+	c, _ := NewClient("", WithFrom("noreply@example.com", ""), WithTransport(tr))
+	return c, tr
+}
+
+func TestVerificationLink(t *testing.T) {
+	c, tr := newVerificationClient()
+	vm, err := NewVerificationManager(c, VerificationOptions{
+		Key:     []byte(strings.Repeat("k", 32)),
+		BaseURL: "https://app.example.com/verify?lang=ru",
+	}, slog.Default())
+	if err != nil {
+		t.Fatalf("Ошибка NewVerificationManager: %v", err)
+	}
+
+	ctx := context.Background()
+	if err := vm.Send(ctx, "user@example.com", "u1"); err != nil {
+		t.Fatalf("Ошибка Send: %v", err)
+	}
+	link := regexp.MustCompile(`https://\S+`).FindString(tr.got.Text)
+	u, err := url.Parse(link)
+	if err != nil || u.Query().Get("lang") != "ru" || u.Query().Get("token") == "" {
+		t.Fatalf("письмо должно содержать ссылку с токеном, получено %q", tr.got.Text)
+	}
+	token := u.Query().Get("token")
+
+	// Изменённая подпись отклоняется до обращения к хранилищу
+	if _, err := vm.Verify(ctx, "", token+"x"); !errors.Is(err, ErrVerificationNotFound) {
+		t.Fatalf("ожидалась ErrVerificationNotFound для неверной подписи, получено %v", err)
+	}
+	// Ссылка не действует для чужого адреса
+	if _, err := vm.Verify(ctx, "other@example.com", token); !errors.Is(err, ErrVerificationNotFound) {
+		t.Fatalf("ожидалась ErrVerificationNotFound для чужого адреса, получено %v", err)
+	}
+	if err := vm.Send(ctx, "user@example.com", "u1"); err != nil {
+		t.Fatalf("Ошибка Send: %v", err)
+	}
+	u, _ = url.Parse(regexp.MustCompile(`https://\S+`).FindString(tr.got.Text))
+	token = u.Query().Get("token")
+	v, err := vm.Verify(ctx, "", token)
+	if err != nil || v.Address != "user@example.com" || v.UserID != "u1" {
+		t.Fatalf("неожиданный результат Verify: %+v, %v", v, err)
+	}
+	if _, err := vm.Verify(ctx, "", token); !errors.Is(err, ErrVerificationNotFound) {
+		t.Fatalf("ссылка должна действовать один раз, получено %v", err)
+	}
+}
+
+func TestVerificationCode(t *testing.T) {
+	c, tr := newVerificationClient()
+	vm, err := NewVerificationManager(c, VerificationOptions{
+		Mode:    VerifyByCode,
+		Subject: "Код {{.Code}}",
+		Body:    "Ваш код: {{.Code}}",
+	}, slog.Default())
+	if err != nil {
+		t.Fatalf("Ошибка NewVerificationManager: %v", err)
+	}
+
+	ctx := context.Background()
+	if err := vm.Send(ctx, "user@example.com", ""); err != nil {
+		t.Fatalf("Ошибка Send: %v", err)
+	}
+	code := strings.TrimPrefix(tr.got.Text, "Ваш код: ")
+	if !regexp.MustCompile(`^\d{6}$`).MatchString(code) || tr.got.Subject != "Код "+code {
+		t.Fatalf("неожиданное письмо: %+v", tr.got)
+	}
+	// Код действует только для адреса, на который отправлен
+	if _, err := vm.Verify(ctx, "other@example.com", code); !errors.Is(err, ErrVerificationNotFound) {
+		t.Fatalf("ожидалась ErrVerificationNotFound для чужого адреса, получено %v", err)
+	}
+	if v, err := vm.Verify(ctx, " User@Example.com", " "+code+" "); err != nil || v.Address != "user@example.com" {
+		t.Fatalf("неожиданный результат Verify: %+v, %v", v, err)
+	}
+	if _, err := vm.Verify(ctx, "user@example.com", code); !errors.Is(err, ErrVerificationNotFound) {
+		t.Fatalf("код должен действовать один раз, получено %v", err)
+	}
+
+	if _, err := NewVerificationManager(c, VerificationOptions{Key: []byte("short"), BaseURL: "https://x"}, slog.Default()); err == nil {
+		t.Fatal("короткий ключ подписи должен отклоняться")
+	}
+}
+
+func TestVerificationCodeAttempts(t *testing.T) {
+	c, tr := newVerificationClient()
+	vm, err := NewVerificationManager(c, VerificationOptions{Mode: VerifyByCode, Body: "{{.Code}}", MaxAttempts: 2}, slog.Default())
+	if err != nil {
+		t.Fatalf("Ошибка NewVerificationManager: %v", err)
+	}
+
+	ctx := context.Background()
+	if err := vm.Send(ctx, "user@example.com", "u1"); err != nil {
+		t.Fatalf("Ошибка Send: %v", err)
+	}
+	code := tr.got.Text
+	wrong := "000000"
+	if code == wrong {
+		wrong = "111111"
+	}
+	if _, err := vm.Verify(ctx, "user@example.com", wrong); !errors.Is(err, ErrVerificationNotFound) {
+		t.Fatalf("ожидалась ErrVerificationNotFound для неверного кода, получено %v", err)
+	}
+	if _, err := vm.Verify(ctx, "user@example.com", wrong); !errors.Is(err, otp.ErrTooManyAttempts) {
+		t.Fatalf("ожидалась otp.ErrTooManyAttempts, получено %v", err)
+	}
+	// После исчерпания попыток верный код уже не принимается
+	if _, err := vm.Verify(ctx, "user@example.com", code); !errors.Is(err, ErrVerificationNotFound) {
+		t.Fatalf("код должен удаляться после исчерпания попыток, получено %v", err)
+	}
+}
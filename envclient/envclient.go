@@ -0,0 +1,184 @@
+// Package envclient создаёт клиентов Telegram и email по конфигурации из переменных окружения NOTEPHEE_*.
+//
+// Пакеты telegram и email от пакета config не зависят: приложения со своей системой конфигурации
+// создают клиентов через telegram.NewTgClient и email.NewClient с опциями. envclient — необязательная надстройка
+// для сервера, CLI и приложений, которые настраиваются через окружение.
+package envclient
+
+import (
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	"github.com/epheer/notephee/config"
+	"github.com/epheer/notephee/email"
+	"github.com/epheer/notephee/email/imap"
+	"github.com/epheer/notephee/email/providers"
+	"github.com/epheer/notephee/telegram"
+)
+
+// TelegramOptions возвращает параметры клиента Telegram из cfg. Токен передаётся в telegram.NewTgClient отдельно.
+func TelegramOptions(cfg *config.Config) telegram.Options {
+	return telegram.Options{
+		BotName: cfg.TelegramBotName,
+		APIURL:  cfg.TelegramAPIURL,
+		Proxy:   cfg.TelegramProxy,
+	}
+}
+
+// Telegram создаёт клиента Telegram по cfg. Клиент включён, если заданы токен и имя бота.
+//
+// Если адрес Bot API или прокси некорректны, клиент создаётся отключённым: без прокси запросы
+// ушли бы в обход него.
+func Telegram(cfg *config.Config, logger *slog.Logger) *telegram.TgClient {
+	c, err := telegram.NewTgClient(cfg.TelegramToken, telegram.WithLogger(logger), telegram.WithOptions(TelegramOptions(cfg)))
+	if err != nil {
+		logger.Error("некорректная конфигурация Telegram, функционал отключён", "error", err)
+		c, _ = telegram.NewTgClient(cfg.TelegramToken, telegram.WithLogger(logger), telegram.WithBotName(cfg.TelegramBotName))
+		c.Enabled = false
+		return c
+	}
+	c.Enabled = cfg.IsTelegramEnabled()
+	return c
+}
+
+// Bots создаёт набор из основного бота cfg (под пустым именем) и ботов личностей отправителя
+// из NOTEPHEE_IDENTITIES с telegram_token (под именами личностей). ttl — время жизни инвайтов.
+func Bots(cfg *config.Config, ttl time.Duration, logger *slog.Logger) (*telegram.Bots, error) {
+	bots := telegram.NewBots()
+	if cfg.IsTelegramEnabled() {
+		client := Telegram(cfg, logger)
+		if err := bots.Add("", client, client.NewBindingManager(ttl, logger)); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.Identities == "" {
+		return bots, nil
+	}
+
+	identities, err := config.ParseIdentities(cfg.Identities)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range identities {
+		if id.TelegramToken == "" {
+			continue
+		}
+		client := Telegram(cfg.WithIdentity(id), logger)
+		if !client.Enabled {
+			return nil, fmt.Errorf("бот %s: некорректная конфигурация Telegram", id.Name)
+		}
+		if err := bots.Add(id.Name, client, client.NewBindingManager(ttl, logger)); err != nil {
+			return nil, err
+		}
+	}
+	return bots, nil
+}
+
+// EmailAddr возвращает адрес SMTP-сервера из cfg для email.NewClient; пусто, если сервер не задан.
+func EmailAddr(cfg *config.Config) string {
+	if cfg.EmailHost == "" {
+		return ""
+	}
+	return net.JoinHostPort(cfg.EmailHost, cfg.EmailPort)
+}
+
+// EmailOptions возвращает параметры почтового клиента из cfg. Транспорт провайдера создаётся
+// отдельно — EmailTransport.
+func EmailOptions(cfg *config.Config) email.Options {
+	o := email.Options{
+		User:     cfg.EmailUser,
+		Password: cfg.EmailPassword,
+		FromName: cfg.EmailFromName,
+	}
+	// Некорректный NOTEPHEE_EMAIL_DOMAIN_LIMITS отклоняется при проверке конфигурации
+	limits, _ := config.ParseDomainLimits(cfg.EmailDomainLimits)
+	for _, l := range limits {
+		o.DomainLimits = append(o.DomainLimits, email.DomainLimit{
+			Domains: l.Domains, Rate: rate.Limit(l.Rate), Burst: l.Burst, Connections: l.Connections,
+		})
+	}
+	// Копии сохраняются в тот же ящик, от имени которого уходят письма
+	if cfg.IMAPAddr != "" {
+		o.SentFolder = imap.NewSentFolder(cfg.IMAPAddr, cfg.EmailUser, cfg.EmailPassword, cfg.IMAPSentFolder)
+	}
+	return o
+}
+
+// Email создаёт почтового клиента по cfg. Клиент включён, если настроены SMTP или провайдер
+// (см. config.Config.IsEmailEnabled); транспорт провайдера подключается через EmailTransport.
+func Email(cfg *config.Config, logger *slog.Logger) *email.Client {
+	c, err := email.NewClient(EmailAddr(cfg), email.WithLogger(logger), email.WithOptions(EmailOptions(cfg)))
+	if err != nil {
+		logger.Error("некорректная конфигурация email, функционал отключён", "error", err)
+		c, _ = email.NewClient("", email.WithLogger(logger))
+	}
+	c.Enabled = err == nil && cfg.IsEmailEnabled()
+	return c
+}
+
+// Accounts создаёт набор из основной учётной записи cfg (под пустым именем) и учётных записей
+// личностей отправителя из NOTEPHEE_IDENTITIES со smtp_user (под именами личностей). Незаданные
+// в личности сервер, порт, пароль и имя отправителя берутся из основной настройки.
+func Accounts(cfg *config.Config, logger *slog.Logger) (*email.Accounts, error) {
+	accounts := email.NewAccounts()
+	if cfg.IsEmailEnabled() {
+		if err := accounts.Add("", Email(cfg, logger)); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.Identities == "" {
+		return accounts, nil
+	}
+
+	identities, err := config.ParseIdentities(cfg.Identities)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range identities {
+		if id.SMTPUser == "" {
+			continue
+		}
+		if err := accounts.Add(id.Name, Email(cfg.WithIdentity(id), logger)); err != nil {
+			return nil, err
+		}
+	}
+	return accounts, nil
+}
+
+// EmailTransport создаёт транспорт, выбранный в cfg.EmailProvider. Для SMTP и пустого значения
+// возвращает nil: письма отправляет SMTP-клиент пакета email.
+func EmailTransport(cfg *config.Config) (providers.EmailTransport, error) {
+	client := &http.Client{Timeout: 15 * time.Second}
+
+	switch strings.ToLower(cfg.EmailProvider) {
+	case "", providers.SMTP:
+		return nil, nil
+	case providers.SendGrid:
+		if cfg.SendGridAPIKey == "" {
+			return nil, fmt.Errorf("для SendGrid нужен NOTEPHEE_SENDGRID_API_KEY")
+		}
+		return providers.NewSendGrid(cfg.SendGridAPIKey, client), nil
+	case providers.Mailgun:
+		if cfg.MailgunDomain == "" || cfg.MailgunAPIKey == "" {
+			return nil, fmt.Errorf("для Mailgun нужны NOTEPHEE_MAILGUN_DOMAIN и NOTEPHEE_MAILGUN_API_KEY")
+		}
+		m := providers.NewMailgun(cfg.MailgunDomain, cfg.MailgunAPIKey, client)
+		if strings.EqualFold(cfg.MailgunRegion, "eu") {
+			m.SetBaseURL(providers.MailgunEU)
+		}
+		return m, nil
+	case providers.SES:
+		if cfg.SESRegion == "" || cfg.SESAccessKeyID == "" || cfg.SESSecretAccessKey == "" {
+			return nil, fmt.Errorf("для SES нужны NOTEPHEE_SES_REGION, NOTEPHEE_SES_ACCESS_KEY_ID и NOTEPHEE_SES_SECRET_ACCESS_KEY")
+		}
+		return providers.NewSES(cfg.SESRegion, cfg.SESAccessKeyID, cfg.SESSecretAccessKey, client), nil
+	default:
+		return nil, fmt.Errorf("неизвестный почтовый провайдер: %q", cfg.EmailProvider)
+	}
+}
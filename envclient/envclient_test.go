@@ -0,0 +1,108 @@
+package envclient_test
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/epheer/notephee/config"
+	"github.com/epheer/notephee/email"
+	"github.com/epheer/notephee/email/providers"
+	"github.com/epheer/notephee/envclient"
+	"github.com/epheer/notephee/notify"
+)
+
+type fakeTransport struct {
+	got providers.Message
+}
+
+func (t *fakeTransport) Name() string { return "fake" }
+
+func (t *fakeTransport) Send(_ context.Context, msg providers.Message) (providers.Result, error) {
+	t.got = msg
+	return providers.Result{Provider: "fake", MessageID: "m1"}, nil
+}
+
+func TestTelegram(t *testing.T) {
+	cfg := &config.Config{TelegramToken: "1:abc", TelegramBotName: "test_bot", TelegramAPIURL: "https://tg.example.com"}
+	if c := envclient.Telegram(cfg, slog.Default()); !c.Enabled {
+		t.Fatal("клиент с токеном и именем бота должен быть включён")
+	}
+	cfg.TelegramAPIURL = "api.local"
+	if c := envclient.Telegram(cfg, slog.Default()); c.Enabled {
+		t.Fatal("с некорректным адресом Bot API клиент должен быть отключён")
+	}
+	if c := envclient.Telegram(&config.Config{TelegramToken: "1:abc"}, slog.Default()); c.Enabled {
+		t.Fatal("без имени бота клиент должен быть отключён")
+	}
+}
+
+func TestBots(t *testing.T) {
+	cfg := &config.Config{
+		TelegramToken:   "1:main",
+		TelegramBotName: "main_bot",
+		Identities:      `[{"name":"alerts","telegram_token":"2:alerts","telegram_bot_name":"alerts_bot"},{"name":"mail","smtp_user":"a@example.com"}]`,
+	}
+	bots, err := envclient.Bots(cfg, time.Minute, slog.Default())
+	if err != nil {
+		t.Fatalf("Ошибка Bots: %v", err)
+	}
+	if names := bots.Names(); len(names) != 2 || names[0] != "" || names[1] != "alerts" {
+		t.Fatalf("ожидались основной бот и alerts, получено %q", names)
+	}
+}
+
+func TestAccounts(t *testing.T) {
+	cfg := &config.Config{
+		EmailUser:      "noreply@example.com",
+		EmailProvider:  "sendgrid",
+		SendGridAPIKey: "SG.x",
+		Identities:     `[{"name":"billing","smtp_user":"billing@example.com","smtp_from_name":"Бухгалтерия"},{"name":"bot","telegram_token":"1:a","telegram_bot_name":"a_bot"}]`,
+	}
+	accounts, err := envclient.Accounts(cfg, slog.Default())
+	if err != nil {
+		t.Fatalf("Ошибка Accounts: %v", err)
+	}
+	if names := accounts.Names(); len(names) != 2 || names[0] != "" || names[1] != "billing" {
+		t.Fatalf("ожидались основная запись и billing, получено %q", names)
+	}
+
+	transports := make(map[string]*fakeTransport)
+	for _, name := range accounts.Names() {
+		client, _ := accounts.Account(name)
+		transports[name] = &fakeTransport{}
+		client.SetTransport(transports[name])
+	}
+
+	if err := accounts.Send(context.Background(), notify.Message{To: "user@example.com", Text: "счёт", Identity: "billing"}); err != nil {
+		t.Fatalf("Ошибка Send: %v", err)
+	}
+	if got := transports["billing"].got; got.From != "billing@example.com" || got.FromName != "Бухгалтерия" {
+		t.Fatalf("письмо должно уйти от billing@example.com: %+v", got)
+	}
+	if err := accounts.SendText(context.Background(), "", email.MessageOptions{To: "user@example.com", Body: "привет"}); err != nil {
+		t.Fatalf("Ошибка SendText: %v", err)
+	}
+	if got := transports[""].got; got.From != "noreply@example.com" {
+		t.Fatalf("письмо без учётной записи должно уйти от основной: %+v", got)
+	}
+	if err := accounts.Send(context.Background(), notify.Message{To: "user@example.com", Identity: "alerts"}); !errors.Is(err, email.ErrUnknownAccount) {
+		t.Fatalf("ожидалась email.ErrUnknownAccount, получено %v", err)
+	}
+}
+
+func TestEmailTransport(t *testing.T) {
+	tr, err := envclient.EmailTransport(&config.Config{})
+	if tr != nil || err != nil {
+		t.Fatalf("для SMTP транспорт не нужен, получено %v, %v", tr, err)
+	}
+	if _, err := envclient.EmailTransport(&config.Config{EmailProvider: "mailgun"}); err == nil {
+		t.Fatal("ожидалась ошибка без ключей Mailgun")
+	}
+	tr, err = envclient.EmailTransport(&config.Config{EmailProvider: "SES", SESRegion: "us-east-1", SESAccessKeyID: "a", SESSecretAccessKey: "b"})
+	if err != nil || tr.Name() != providers.SES {
+		t.Fatalf("ожидался транспорт SES, получено %v, %v", tr, err)
+	}
+}
@@ -0,0 +1,75 @@
+package events
+
+import (
+	"sync"
+	"time"
+)
+
+// Type — тип события.
+type Type string
+
+// Типы событий, публикуемых модулями notephee
+const (
+	Complaint Type = "complaint" // Получатель пожаловался на письмо
+	Bounce    Type = "bounce"    // Письмо не доставлено или получен автоответ
+	Degraded  Type = "degraded"  // Канал замедлился, низкоприоритетный трафик ограничен
+	Recovered Type = "recovered" // Задержка канала вернулась в норму
+
+	ConfigReloaded Type = "config_reloaded" // Шаблоны и политики перезагружены во время работы
+
+	BotBlocked   Type = "bot_blocked"   // Пользователь заблокировал бота или бота исключили из чата
+	BotUnblocked Type = "bot_unblocked" // Пользователь разблокировал бота или бота вернули в чат
+)
+
+// Event описывает одно событие, связанное с доставкой уведомлений.
+type Event struct {
+	Type      Type              // Тип события
+	Time      time.Time         // Время возникновения
+	Channel   string            // Канал доставки (email, telegram и т.д.)
+	Recipient string            // Адрес получателя в канале
+	Campaign  string            // Рассылка, к которой относится событие (если известна)
+	Data      map[string]string // Дополнительные поля, специфичные для типа события
+}
+
+// Handler обрабатывает событие.
+type Handler func(Event)
+
+// Bus рассылает события подписчикам.
+//
+// Нулевой указатель *Bus допустим: Publish на нём ничего не делает.
+type Bus struct {
+	mu       sync.RWMutex
+	handlers []Handler
+}
+
+// NewBus создаёт шину событий без подписчиков.
+func NewBus() *Bus {
+	return &Bus{}
+}
+
+// Subscribe регистрирует обработчик, который будет вызываться для каждого события.
+func (b *Bus) Subscribe(h Handler) {
+	b.mu.Lock()
+	b.handlers = append(b.handlers, h)
+	b.mu.Unlock()
+}
+
+// Publish синхронно передаёт событие всем подписчикам.
+//
+// Если Time не заполнено, подставляется текущее время.
+func (b *Bus) Publish(e Event) {
+	if b == nil {
+		return
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+
+	b.mu.RLock()
+	handlers := b.handlers
+	b.mu.RUnlock()
+
+	for _, h := range handlers {
+		h(e)
+	}
+}
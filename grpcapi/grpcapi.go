@@ -0,0 +1,149 @@
+package grpcapi
+
+//go:generate protoc -I ../proto --go_out=. --go_opt=module=github.com/epheer/notephee/grpcapi --go-grpc_out=. --go-grpc_opt=module=github.com/epheer/notephee/grpcapi notephee/v1/notification.proto
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/epheer/notephee/delivery"
+	pb "github.com/epheer/notephee/grpcapi/notepheev1"
+	"github.com/epheer/notephee/notify"
+)
+
+// Service реализует pb.NotificationServiceServer поверх реестра каналов notephee.
+type Service struct {
+	pb.UnimplementedNotificationServiceServer
+
+	registry *notify.Registry     // Доступные каналы отправки
+	log      delivery.DeliveryLog // Журнал доставки для GetDelivery
+	logger   *slog.Logger         // Логгер
+}
+
+// New создаёт gRPC-сервис уведомлений.
+func New(registry *notify.Registry, log delivery.DeliveryLog, logger *slog.Logger) *Service {
+	return &Service{registry: registry, log: log, logger: logger}
+}
+
+// Register регистрирует сервис на gRPC-сервере.
+func (s *Service) Register(srv grpc.ServiceRegistrar) {
+	pb.RegisterNotificationServiceServer(srv, s)
+}
+
+// SendNotification синхронно отправляет одно сообщение.
+func (s *Service) SendNotification(ctx context.Context, req *pb.SendNotificationRequest) (*pb.SendNotificationResponse, error) {
+	if req.GetTo() == "" || req.GetText() == "" {
+		return nil, status.Error(codes.InvalidArgument, "поля to и text обязательны")
+	}
+	sender, err := s.registry.Get(req.GetChannel())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	msg := notify.Message{
+		ID:       uuid.New().String(),
+		To:       req.GetTo(),
+		UserID:   req.GetUserId(),
+		Subject:  req.GetSubject(),
+		Text:     req.GetText(),
+		Campaign: req.GetCampaign(),
+	}
+
+	resp := &pb.SendNotificationResponse{DeliveryId: msg.ID, Status: pb.DeliveryStatus_DELIVERY_STATUS_SENT}
+	if err := sender.Send(ctx, msg); err != nil {
+		resp.Status = deliveryStatus(delivery.StatusOf(err))
+		resp.Error = err.Error()
+	}
+	return resp, nil
+}
+
+// Broadcast отправляет сообщение всем получателям и передаёт результат каждой отправки в поток.
+//
+// Отмена вызова клиентом останавливает рассылку.
+func (s *Service) Broadcast(req *pb.BroadcastRequest, stream grpc.ServerStreamingServer[pb.BroadcastProgress]) error {
+	if len(req.GetRecipients()) == 0 || req.GetText() == "" {
+		return status.Error(codes.InvalidArgument, "поля recipients и text обязательны")
+	}
+	sender, err := s.registry.Get(req.GetChannel())
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	ctx := stream.Context()
+	progress := &pb.BroadcastProgress{
+		BroadcastId: uuid.New().String(),
+		Total:       int64(len(req.GetRecipients())),
+	}
+
+	for _, to := range req.GetRecipients() {
+		if err := ctx.Err(); err != nil {
+			s.logger.Warn("рассылка прервана клиентом", "broadcast_id", progress.BroadcastId, "error", err)
+			return status.FromContextError(err).Err()
+		}
+
+		msg := notify.Message{
+			ID:       uuid.New().String(),
+			To:       to,
+			Subject:  req.GetSubject(),
+			Text:     req.GetText(),
+			Campaign: req.GetCampaign(),
+		}
+		result := &pb.RecipientResult{Recipient: to, DeliveryId: msg.ID, Status: pb.DeliveryStatus_DELIVERY_STATUS_SENT}
+		if err := sender.Send(ctx, msg); err != nil {
+			result.Status = deliveryStatus(delivery.StatusOf(err))
+			result.Error = err.Error()
+			progress.Failed++
+		} else {
+			progress.Sent++
+		}
+
+		progress.Result = result
+		if err := stream.Send(progress); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetDelivery возвращает запись журнала доставки.
+func (s *Service) GetDelivery(ctx context.Context, req *pb.GetDeliveryRequest) (*pb.Delivery, error) {
+	rec, err := s.log.Get(ctx, req.GetId())
+	if errors.Is(err, delivery.ErrNotFound) {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+	if err != nil {
+		s.logger.Error("ошибка чтения журнала доставки", "error", err)
+		return nil, status.Error(codes.Internal, "ошибка чтения журнала доставки")
+	}
+
+	return &pb.Delivery{
+		Id:          rec.ID,
+		Channel:     rec.Channel,
+		UserId:      rec.UserID,
+		Recipient:   rec.Recipient,
+		MessageHash: rec.MessageHash,
+		Status:      deliveryStatus(rec.Status),
+		Error:       rec.Error,
+		CreatedAt:   timestamppb.New(rec.CreatedAt),
+		CompletedAt: timestamppb.New(rec.CompletedAt),
+	}, nil
+}
+
+func deliveryStatus(s delivery.Status) pb.DeliveryStatus {
+	switch s {
+	case delivery.StatusSent:
+		return pb.DeliveryStatus_DELIVERY_STATUS_SENT
+	case delivery.StatusFailed:
+		return pb.DeliveryStatus_DELIVERY_STATUS_FAILED
+	case delivery.StatusIndeterminate:
+		return pb.DeliveryStatus_DELIVERY_STATUS_INDETERMINATE
+	}
+	return pb.DeliveryStatus_DELIVERY_STATUS_UNSPECIFIED
+}
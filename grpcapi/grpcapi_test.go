@@ -0,0 +1,96 @@
+package grpcapi_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/epheer/notephee/delivery"
+	"github.com/epheer/notephee/grpcapi"
+	pb "github.com/epheer/notephee/grpcapi/notepheev1"
+	"github.com/epheer/notephee/notify"
+)
+
+// fakeSender отклоняет получателя "bad" и принимает остальных.
+type fakeSender struct{}
+
+func (fakeSender) Channel() string { return "fake" }
+
+func (fakeSender) Send(_ context.Context, msg notify.Message) error {
+	if msg.To == "bad" {
+		return errors.New("получатель недоступен")
+	}
+	return nil
+}
+
+func TestBroadcastStreamsProgress(t *testing.T) {
+	registry := notify.NewRegistry()
+	registry.Register(fakeSender{})
+
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer(grpcapi.TokenAuth("secret")...)
+	grpcapi.New(registry, delivery.NewMemoryLog(), slog.Default()).Register(srv)
+	go func() { _ = srv.Serve(lis) }()
+	defer srv.Stop()
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("Ошибка подключения: %v", err)
+	}
+	defer func() { _ = conn.Close() }()
+
+	client := pb.NewNotificationServiceClient(conn)
+	_, err = client.SendNotification(context.Background(), &pb.SendNotificationRequest{Channel: "fake", To: "1", Text: "привет"})
+	if status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("вызов без токена должен отклоняться, получено %v", err)
+	}
+	wrong := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer wrong")
+	denied, err := client.Broadcast(wrong, &pb.BroadcastRequest{Channel: "fake", Recipients: []string{"1"}, Text: "привет"})
+	if err == nil {
+		_, err = denied.Recv()
+	}
+	if status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("поток с неверным токеном должен отклоняться, получено %v", err)
+	}
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer secret")
+	stream, err := client.Broadcast(ctx, &pb.BroadcastRequest{
+		Channel:    "fake",
+		Recipients: []string{"1", "bad", "3"},
+		Text:       "привет",
+	})
+	if err != nil {
+		t.Fatalf("Ошибка Broadcast: %v", err)
+	}
+
+	var last *pb.BroadcastProgress
+	count := 0
+	for {
+		p, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Ошибка чтения потока: %v", err)
+		}
+		last = p
+		count++
+	}
+
+	if count != 3 || last.GetSent() != 2 || last.GetFailed() != 1 {
+		t.Fatalf("неверный прогресс рассылки: %d сообщений, последнее %+v", count, last)
+	}
+}
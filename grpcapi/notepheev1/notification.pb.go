@@ -0,0 +1,736 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.9
+// 	protoc        (unknown)
+// source: notephee/v1/notification.proto
+
+// API notephee для отправки уведомлений из внутренних сервисов.
+
+package notepheev1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// DeliveryStatus — итог попытки отправки.
+type DeliveryStatus int32
+
+const (
+	DeliveryStatus_DELIVERY_STATUS_UNSPECIFIED DeliveryStatus = 0
+	DeliveryStatus_DELIVERY_STATUS_SENT        DeliveryStatus = 1
+	DeliveryStatus_DELIVERY_STATUS_FAILED      DeliveryStatus = 2
+	// Запрос мог дойти до провайдера, но ответ не получен.
+	DeliveryStatus_DELIVERY_STATUS_INDETERMINATE DeliveryStatus = 3
+)
+
+// Enum value maps for DeliveryStatus.
+var (
+	DeliveryStatus_name = map[int32]string{
+		0: "DELIVERY_STATUS_UNSPECIFIED",
+		1: "DELIVERY_STATUS_SENT",
+		2: "DELIVERY_STATUS_FAILED",
+		3: "DELIVERY_STATUS_INDETERMINATE",
+	}
+	DeliveryStatus_value = map[string]int32{
+		"DELIVERY_STATUS_UNSPECIFIED":   0,
+		"DELIVERY_STATUS_SENT":          1,
+		"DELIVERY_STATUS_FAILED":        2,
+		"DELIVERY_STATUS_INDETERMINATE": 3,
+	}
+)
+
+func (x DeliveryStatus) Enum() *DeliveryStatus {
+	p := new(DeliveryStatus)
+	*p = x
+	return p
+}
+
+func (x DeliveryStatus) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (DeliveryStatus) Descriptor() protoreflect.EnumDescriptor {
+	return file_notephee_v1_notification_proto_enumTypes[0].Descriptor()
+}
+
+func (DeliveryStatus) Type() protoreflect.EnumType {
+	return &file_notephee_v1_notification_proto_enumTypes[0]
+}
+
+func (x DeliveryStatus) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use DeliveryStatus.Descriptor instead.
+func (DeliveryStatus) EnumDescriptor() ([]byte, []int) {
+	return file_notephee_v1_notification_proto_rawDescGZIP(), []int{0}
+}
+
+type SendNotificationRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Канал отправки: telegram, email.
+	Channel string `protobuf:"bytes,1,opt,name=channel,proto3" json:"channel,omitempty"`
+	// Адрес получателя в канале: chatID, email.
+	To string `protobuf:"bytes,2,opt,name=to,proto3" json:"to,omitempty"`
+	// Внутренний идентификатор пользователя.
+	UserId string `protobuf:"bytes,3,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	// Тема (для каналов, в которых она есть).
+	Subject string `protobuf:"bytes,4,opt,name=subject,proto3" json:"subject,omitempty"`
+	// Текст сообщения.
+	Text string `protobuf:"bytes,5,opt,name=text,proto3" json:"text,omitempty"`
+	// Идентификатор рассылки.
+	Campaign      string `protobuf:"bytes,6,opt,name=campaign,proto3" json:"campaign,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SendNotificationRequest) Reset() {
+	*x = SendNotificationRequest{}
+	mi := &file_notephee_v1_notification_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SendNotificationRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SendNotificationRequest) ProtoMessage() {}
+
+func (x *SendNotificationRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_notephee_v1_notification_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SendNotificationRequest.ProtoReflect.Descriptor instead.
+func (*SendNotificationRequest) Descriptor() ([]byte, []int) {
+	return file_notephee_v1_notification_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *SendNotificationRequest) GetChannel() string {
+	if x != nil {
+		return x.Channel
+	}
+	return ""
+}
+
+func (x *SendNotificationRequest) GetTo() string {
+	if x != nil {
+		return x.To
+	}
+	return ""
+}
+
+func (x *SendNotificationRequest) GetUserId() string {
+	if x != nil {
+		return x.UserId
+	}
+	return ""
+}
+
+func (x *SendNotificationRequest) GetSubject() string {
+	if x != nil {
+		return x.Subject
+	}
+	return ""
+}
+
+func (x *SendNotificationRequest) GetText() string {
+	if x != nil {
+		return x.Text
+	}
+	return ""
+}
+
+func (x *SendNotificationRequest) GetCampaign() string {
+	if x != nil {
+		return x.Campaign
+	}
+	return ""
+}
+
+type SendNotificationResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Идентификатор записи в журнале доставки.
+	DeliveryId string         `protobuf:"bytes,1,opt,name=delivery_id,json=deliveryId,proto3" json:"delivery_id,omitempty"`
+	Status     DeliveryStatus `protobuf:"varint,2,opt,name=status,proto3,enum=notephee.v1.DeliveryStatus" json:"status,omitempty"`
+	// Текст ошибки, если отправка не удалась.
+	Error         string `protobuf:"bytes,3,opt,name=error,proto3" json:"error,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SendNotificationResponse) Reset() {
+	*x = SendNotificationResponse{}
+	mi := &file_notephee_v1_notification_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SendNotificationResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SendNotificationResponse) ProtoMessage() {}
+
+func (x *SendNotificationResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_notephee_v1_notification_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SendNotificationResponse.ProtoReflect.Descriptor instead.
+func (*SendNotificationResponse) Descriptor() ([]byte, []int) {
+	return file_notephee_v1_notification_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *SendNotificationResponse) GetDeliveryId() string {
+	if x != nil {
+		return x.DeliveryId
+	}
+	return ""
+}
+
+func (x *SendNotificationResponse) GetStatus() DeliveryStatus {
+	if x != nil {
+		return x.Status
+	}
+	return DeliveryStatus_DELIVERY_STATUS_UNSPECIFIED
+}
+
+func (x *SendNotificationResponse) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+type BroadcastRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Channel       string                 `protobuf:"bytes,1,opt,name=channel,proto3" json:"channel,omitempty"`
+	Recipients    []string               `protobuf:"bytes,2,rep,name=recipients,proto3" json:"recipients,omitempty"`
+	Subject       string                 `protobuf:"bytes,3,opt,name=subject,proto3" json:"subject,omitempty"`
+	Text          string                 `protobuf:"bytes,4,opt,name=text,proto3" json:"text,omitempty"`
+	Campaign      string                 `protobuf:"bytes,5,opt,name=campaign,proto3" json:"campaign,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BroadcastRequest) Reset() {
+	*x = BroadcastRequest{}
+	mi := &file_notephee_v1_notification_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BroadcastRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BroadcastRequest) ProtoMessage() {}
+
+func (x *BroadcastRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_notephee_v1_notification_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BroadcastRequest.ProtoReflect.Descriptor instead.
+func (*BroadcastRequest) Descriptor() ([]byte, []int) {
+	return file_notephee_v1_notification_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *BroadcastRequest) GetChannel() string {
+	if x != nil {
+		return x.Channel
+	}
+	return ""
+}
+
+func (x *BroadcastRequest) GetRecipients() []string {
+	if x != nil {
+		return x.Recipients
+	}
+	return nil
+}
+
+func (x *BroadcastRequest) GetSubject() string {
+	if x != nil {
+		return x.Subject
+	}
+	return ""
+}
+
+func (x *BroadcastRequest) GetText() string {
+	if x != nil {
+		return x.Text
+	}
+	return ""
+}
+
+func (x *BroadcastRequest) GetCampaign() string {
+	if x != nil {
+		return x.Campaign
+	}
+	return ""
+}
+
+// RecipientResult — результат отправки одному получателю рассылки.
+type RecipientResult struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Recipient     string                 `protobuf:"bytes,1,opt,name=recipient,proto3" json:"recipient,omitempty"`
+	DeliveryId    string                 `protobuf:"bytes,2,opt,name=delivery_id,json=deliveryId,proto3" json:"delivery_id,omitempty"`
+	Status        DeliveryStatus         `protobuf:"varint,3,opt,name=status,proto3,enum=notephee.v1.DeliveryStatus" json:"status,omitempty"`
+	Error         string                 `protobuf:"bytes,4,opt,name=error,proto3" json:"error,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RecipientResult) Reset() {
+	*x = RecipientResult{}
+	mi := &file_notephee_v1_notification_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RecipientResult) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RecipientResult) ProtoMessage() {}
+
+func (x *RecipientResult) ProtoReflect() protoreflect.Message {
+	mi := &file_notephee_v1_notification_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RecipientResult.ProtoReflect.Descriptor instead.
+func (*RecipientResult) Descriptor() ([]byte, []int) {
+	return file_notephee_v1_notification_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *RecipientResult) GetRecipient() string {
+	if x != nil {
+		return x.Recipient
+	}
+	return ""
+}
+
+func (x *RecipientResult) GetDeliveryId() string {
+	if x != nil {
+		return x.DeliveryId
+	}
+	return ""
+}
+
+func (x *RecipientResult) GetStatus() DeliveryStatus {
+	if x != nil {
+		return x.Status
+	}
+	return DeliveryStatus_DELIVERY_STATUS_UNSPECIFIED
+}
+
+func (x *RecipientResult) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+// BroadcastProgress отправляется после каждой попытки доставки.
+type BroadcastProgress struct {
+	state       protoimpl.MessageState `protogen:"open.v1"`
+	BroadcastId string                 `protobuf:"bytes,1,opt,name=broadcast_id,json=broadcastId,proto3" json:"broadcast_id,omitempty"`
+	Result      *RecipientResult       `protobuf:"bytes,2,opt,name=result,proto3" json:"result,omitempty"`
+	// Счётчики на момент отправки сообщения.
+	Total         int64 `protobuf:"varint,3,opt,name=total,proto3" json:"total,omitempty"`
+	Sent          int64 `protobuf:"varint,4,opt,name=sent,proto3" json:"sent,omitempty"`
+	Failed        int64 `protobuf:"varint,5,opt,name=failed,proto3" json:"failed,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BroadcastProgress) Reset() {
+	*x = BroadcastProgress{}
+	mi := &file_notephee_v1_notification_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BroadcastProgress) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BroadcastProgress) ProtoMessage() {}
+
+func (x *BroadcastProgress) ProtoReflect() protoreflect.Message {
+	mi := &file_notephee_v1_notification_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BroadcastProgress.ProtoReflect.Descriptor instead.
+func (*BroadcastProgress) Descriptor() ([]byte, []int) {
+	return file_notephee_v1_notification_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *BroadcastProgress) GetBroadcastId() string {
+	if x != nil {
+		return x.BroadcastId
+	}
+	return ""
+}
+
+func (x *BroadcastProgress) GetResult() *RecipientResult {
+	if x != nil {
+		return x.Result
+	}
+	return nil
+}
+
+func (x *BroadcastProgress) GetTotal() int64 {
+	if x != nil {
+		return x.Total
+	}
+	return 0
+}
+
+func (x *BroadcastProgress) GetSent() int64 {
+	if x != nil {
+		return x.Sent
+	}
+	return 0
+}
+
+func (x *BroadcastProgress) GetFailed() int64 {
+	if x != nil {
+		return x.Failed
+	}
+	return 0
+}
+
+type GetDeliveryRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetDeliveryRequest) Reset() {
+	*x = GetDeliveryRequest{}
+	mi := &file_notephee_v1_notification_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetDeliveryRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetDeliveryRequest) ProtoMessage() {}
+
+func (x *GetDeliveryRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_notephee_v1_notification_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetDeliveryRequest.ProtoReflect.Descriptor instead.
+func (*GetDeliveryRequest) Descriptor() ([]byte, []int) {
+	return file_notephee_v1_notification_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *GetDeliveryRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+type Delivery struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Channel       string                 `protobuf:"bytes,2,opt,name=channel,proto3" json:"channel,omitempty"`
+	UserId        string                 `protobuf:"bytes,3,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	Recipient     string                 `protobuf:"bytes,4,opt,name=recipient,proto3" json:"recipient,omitempty"`
+	MessageHash   string                 `protobuf:"bytes,5,opt,name=message_hash,json=messageHash,proto3" json:"message_hash,omitempty"`
+	Status        DeliveryStatus         `protobuf:"varint,6,opt,name=status,proto3,enum=notephee.v1.DeliveryStatus" json:"status,omitempty"`
+	Error         string                 `protobuf:"bytes,7,opt,name=error,proto3" json:"error,omitempty"`
+	CreatedAt     *timestamppb.Timestamp `protobuf:"bytes,8,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	CompletedAt   *timestamppb.Timestamp `protobuf:"bytes,9,opt,name=completed_at,json=completedAt,proto3" json:"completed_at,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Delivery) Reset() {
+	*x = Delivery{}
+	mi := &file_notephee_v1_notification_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Delivery) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Delivery) ProtoMessage() {}
+
+func (x *Delivery) ProtoReflect() protoreflect.Message {
+	mi := &file_notephee_v1_notification_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Delivery.ProtoReflect.Descriptor instead.
+func (*Delivery) Descriptor() ([]byte, []int) {
+	return file_notephee_v1_notification_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *Delivery) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *Delivery) GetChannel() string {
+	if x != nil {
+		return x.Channel
+	}
+	return ""
+}
+
+func (x *Delivery) GetUserId() string {
+	if x != nil {
+		return x.UserId
+	}
+	return ""
+}
+
+func (x *Delivery) GetRecipient() string {
+	if x != nil {
+		return x.Recipient
+	}
+	return ""
+}
+
+func (x *Delivery) GetMessageHash() string {
+	if x != nil {
+		return x.MessageHash
+	}
+	return ""
+}
+
+func (x *Delivery) GetStatus() DeliveryStatus {
+	if x != nil {
+		return x.Status
+	}
+	return DeliveryStatus_DELIVERY_STATUS_UNSPECIFIED
+}
+
+func (x *Delivery) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+func (x *Delivery) GetCreatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.CreatedAt
+	}
+	return nil
+}
+
+func (x *Delivery) GetCompletedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.CompletedAt
+	}
+	return nil
+}
+
+var File_notephee_v1_notification_proto protoreflect.FileDescriptor
+
+const file_notephee_v1_notification_proto_rawDesc = "" +
+	"\n" +
+	"\x1enotephee/v1/notification.proto\x12\vnotephee.v1\x1a\x1fgoogle/protobuf/timestamp.proto\"\xa6\x01\n" +
+	"\x17SendNotificationRequest\x12\x18\n" +
+	"\achannel\x18\x01 \x01(\tR\achannel\x12\x0e\n" +
+	"\x02to\x18\x02 \x01(\tR\x02to\x12\x17\n" +
+	"\auser_id\x18\x03 \x01(\tR\x06userId\x12\x18\n" +
+	"\asubject\x18\x04 \x01(\tR\asubject\x12\x12\n" +
+	"\x04text\x18\x05 \x01(\tR\x04text\x12\x1a\n" +
+	"\bcampaign\x18\x06 \x01(\tR\bcampaign\"\x86\x01\n" +
+	"\x18SendNotificationResponse\x12\x1f\n" +
+	"\vdelivery_id\x18\x01 \x01(\tR\n" +
+	"deliveryId\x123\n" +
+	"\x06status\x18\x02 \x01(\x0e2\x1b.notephee.v1.DeliveryStatusR\x06status\x12\x14\n" +
+	"\x05error\x18\x03 \x01(\tR\x05error\"\x96\x01\n" +
+	"\x10BroadcastRequest\x12\x18\n" +
+	"\achannel\x18\x01 \x01(\tR\achannel\x12\x1e\n" +
+	"\n" +
+	"recipients\x18\x02 \x03(\tR\n" +
+	"recipients\x12\x18\n" +
+	"\asubject\x18\x03 \x01(\tR\asubject\x12\x12\n" +
+	"\x04text\x18\x04 \x01(\tR\x04text\x12\x1a\n" +
+	"\bcampaign\x18\x05 \x01(\tR\bcampaign\"\x9b\x01\n" +
+	"\x0fRecipientResult\x12\x1c\n" +
+	"\trecipient\x18\x01 \x01(\tR\trecipient\x12\x1f\n" +
+	"\vdelivery_id\x18\x02 \x01(\tR\n" +
+	"deliveryId\x123\n" +
+	"\x06status\x18\x03 \x01(\x0e2\x1b.notephee.v1.DeliveryStatusR\x06status\x12\x14\n" +
+	"\x05error\x18\x04 \x01(\tR\x05error\"\xae\x01\n" +
+	"\x11BroadcastProgress\x12!\n" +
+	"\fbroadcast_id\x18\x01 \x01(\tR\vbroadcastId\x124\n" +
+	"\x06result\x18\x02 \x01(\v2\x1c.notephee.v1.RecipientResultR\x06result\x12\x14\n" +
+	"\x05total\x18\x03 \x01(\x03R\x05total\x12\x12\n" +
+	"\x04sent\x18\x04 \x01(\x03R\x04sent\x12\x16\n" +
+	"\x06failed\x18\x05 \x01(\x03R\x06failed\"$\n" +
+	"\x12GetDeliveryRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\"\xd3\x02\n" +
+	"\bDelivery\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x18\n" +
+	"\achannel\x18\x02 \x01(\tR\achannel\x12\x17\n" +
+	"\auser_id\x18\x03 \x01(\tR\x06userId\x12\x1c\n" +
+	"\trecipient\x18\x04 \x01(\tR\trecipient\x12!\n" +
+	"\fmessage_hash\x18\x05 \x01(\tR\vmessageHash\x123\n" +
+	"\x06status\x18\x06 \x01(\x0e2\x1b.notephee.v1.DeliveryStatusR\x06status\x12\x14\n" +
+	"\x05error\x18\a \x01(\tR\x05error\x129\n" +
+	"\n" +
+	"created_at\x18\b \x01(\v2\x1a.google.protobuf.TimestampR\tcreatedAt\x12=\n" +
+	"\fcompleted_at\x18\t \x01(\v2\x1a.google.protobuf.TimestampR\vcompletedAt*\x8a\x01\n" +
+	"\x0eDeliveryStatus\x12\x1f\n" +
+	"\x1bDELIVERY_STATUS_UNSPECIFIED\x10\x00\x12\x18\n" +
+	"\x14DELIVERY_STATUS_SENT\x10\x01\x12\x1a\n" +
+	"\x16DELIVERY_STATUS_FAILED\x10\x02\x12!\n" +
+	"\x1dDELIVERY_STATUS_INDETERMINATE\x10\x032\x8b\x02\n" +
+	"\x13NotificationService\x12_\n" +
+	"\x10SendNotification\x12$.notephee.v1.SendNotificationRequest\x1a%.notephee.v1.SendNotificationResponse\x12L\n" +
+	"\tBroadcast\x12\x1d.notephee.v1.BroadcastRequest\x1a\x1e.notephee.v1.BroadcastProgress0\x01\x12E\n" +
+	"\vGetDelivery\x12\x1f.notephee.v1.GetDeliveryRequest\x1a\x15.notephee.v1.DeliveryB:Z8github.com/epheer/notephee/grpcapi/notepheev1;notepheev1b\x06proto3"
+
+var (
+	file_notephee_v1_notification_proto_rawDescOnce sync.Once
+	file_notephee_v1_notification_proto_rawDescData []byte
+)
+
+func file_notephee_v1_notification_proto_rawDescGZIP() []byte {
+	file_notephee_v1_notification_proto_rawDescOnce.Do(func() {
+		file_notephee_v1_notification_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_notephee_v1_notification_proto_rawDesc), len(file_notephee_v1_notification_proto_rawDesc)))
+	})
+	return file_notephee_v1_notification_proto_rawDescData
+}
+
+var file_notephee_v1_notification_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
+var file_notephee_v1_notification_proto_msgTypes = make([]protoimpl.MessageInfo, 7)
+var file_notephee_v1_notification_proto_goTypes = []any{
+	(DeliveryStatus)(0),              // 0: notephee.v1.DeliveryStatus
+	(*SendNotificationRequest)(nil),  // 1: notephee.v1.SendNotificationRequest
+	(*SendNotificationResponse)(nil), // 2: notephee.v1.SendNotificationResponse
+	(*BroadcastRequest)(nil),         // 3: notephee.v1.BroadcastRequest
+	(*RecipientResult)(nil),          // 4: notephee.v1.RecipientResult
+	(*BroadcastProgress)(nil),        // 5: notephee.v1.BroadcastProgress
+	(*GetDeliveryRequest)(nil),       // 6: notephee.v1.GetDeliveryRequest
+	(*Delivery)(nil),                 // 7: notephee.v1.Delivery
+	(*timestamppb.Timestamp)(nil),    // 8: google.protobuf.Timestamp
+}
+var file_notephee_v1_notification_proto_depIdxs = []int32{
+	0, // 0: notephee.v1.SendNotificationResponse.status:type_name -> notephee.v1.DeliveryStatus
+	0, // 1: notephee.v1.RecipientResult.status:type_name -> notephee.v1.DeliveryStatus
+	4, // 2: notephee.v1.BroadcastProgress.result:type_name -> notephee.v1.RecipientResult
+	0, // 3: notephee.v1.Delivery.status:type_name -> notephee.v1.DeliveryStatus
+	8, // 4: notephee.v1.Delivery.created_at:type_name -> google.protobuf.Timestamp
+	8, // 5: notephee.v1.Delivery.completed_at:type_name -> google.protobuf.Timestamp
+	1, // 6: notephee.v1.NotificationService.SendNotification:input_type -> notephee.v1.SendNotificationRequest
+	3, // 7: notephee.v1.NotificationService.Broadcast:input_type -> notephee.v1.BroadcastRequest
+	6, // 8: notephee.v1.NotificationService.GetDelivery:input_type -> notephee.v1.GetDeliveryRequest
+	2, // 9: notephee.v1.NotificationService.SendNotification:output_type -> notephee.v1.SendNotificationResponse
+	5, // 10: notephee.v1.NotificationService.Broadcast:output_type -> notephee.v1.BroadcastProgress
+	7, // 11: notephee.v1.NotificationService.GetDelivery:output_type -> notephee.v1.Delivery
+	9, // [9:12] is the sub-list for method output_type
+	6, // [6:9] is the sub-list for method input_type
+	6, // [6:6] is the sub-list for extension type_name
+	6, // [6:6] is the sub-list for extension extendee
+	0, // [0:6] is the sub-list for field type_name
+}
+
+func init() { file_notephee_v1_notification_proto_init() }
+func file_notephee_v1_notification_proto_init() {
+	if File_notephee_v1_notification_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_notephee_v1_notification_proto_rawDesc), len(file_notephee_v1_notification_proto_rawDesc)),
+			NumEnums:      1,
+			NumMessages:   7,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_notephee_v1_notification_proto_goTypes,
+		DependencyIndexes: file_notephee_v1_notification_proto_depIdxs,
+		EnumInfos:         file_notephee_v1_notification_proto_enumTypes,
+		MessageInfos:      file_notephee_v1_notification_proto_msgTypes,
+	}.Build()
+	File_notephee_v1_notification_proto = out.File
+	file_notephee_v1_notification_proto_goTypes = nil
+	file_notephee_v1_notification_proto_depIdxs = nil
+}
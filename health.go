@@ -0,0 +1,176 @@
+package notephee
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/epheer/notephee/degrade"
+	"github.com/epheer/notephee/email"
+	"github.com/epheer/notephee/queue"
+	"github.com/epheer/notephee/telegram"
+)
+
+// HealthStatus — состояние канала или notephee в целом.
+type HealthStatus string
+
+const (
+	HealthOK       HealthStatus = "ok"       // Канал работает
+	HealthDegraded HealthStatus = "degraded" // Канал работает с ограничениями: деградация, заполненная очередь
+	HealthDown     HealthStatus = "down"     // Канал недоступен
+	HealthDisabled HealthStatus = "disabled" // Канал не настроен
+)
+
+// healthTimeout — предел проверки одного канала, если у ctx нет своего срока.
+const healthTimeout = 10 * time.Second
+
+// HealthReport — состояние всех каналов, например для ответа /healthz.
+type HealthReport struct {
+	Status    HealthStatus    `json:"status"`     // Худшее состояние среди настроенных каналов
+	CheckedAt time.Time       `json:"checked_at"` // Время проверки
+	Channels  []ChannelHealth `json:"channels"`   // Каналы в порядке: telegram, email, остальные по подключению
+}
+
+// ChannelHealth — состояние одного канала.
+type ChannelHealth struct {
+	Channel string          `json:"channel"`           // Имя канала
+	Status  HealthStatus    `json:"status"`            // Состояние канала
+	Error   string          `json:"error,omitempty"`   // Ошибка проверки подключения
+	Latency time.Duration   `json:"latency"`           // Длительность проверки подключения
+	Bot     *telegram.User  `json:"bot,omitempty"`     // Бот по ответу getMe (только telegram)
+	Queue   *QueueHealth    `json:"queue,omitempty"`   // Очередь отправки (если подключена через MonitorQueue)
+	Degrade *degrade.Health `json:"degrade,omitempty"` // Деградация канала (если подключена через MonitorDegrade)
+}
+
+// QueueHealth — состояние очереди отправки канала.
+type QueueHealth struct {
+	Backlog  int           `json:"backlog"`  // Сообщений в очереди
+	Capacity int           `json:"capacity"` // Ёмкость очереди
+	Workers  int           `json:"workers"`  // Текущее число воркеров
+	Latency  time.Duration `json:"latency"`  // Средняя задержка отправки
+}
+
+// MonitorQueue добавляет очередь отправки в отчёт Health: её глубина выводится у канала очереди.
+func (n *Notephee) MonitorQueue(d *queue.Dispatcher) {
+	n.mu.Lock()
+	n.queues = append(n.queues, d)
+	n.mu.Unlock()
+}
+
+// MonitorDegrade добавляет обёртку деградации в отчёт Health.
+func (n *Notephee) MonitorDegrade(s *degrade.Sender) {
+	n.mu.Lock()
+	n.degrades = append(n.degrades, s)
+	n.mu.Unlock()
+}
+
+// Health проверяет подключение к Telegram (getMe) и SMTP-серверу (NOOP) и собирает состояние очередей
+// и деградации каналов, подключённых через MonitorQueue и MonitorDegrade. Проверки каналов идут параллельно;
+// без срока у ctx каждая ограничена 10 секундами.
+func (n *Notephee) Health(ctx context.Context) HealthReport {
+	n.mu.Lock()
+	tg, mail := n.Telegram, n.Email
+	queues := append([]*queue.Dispatcher(nil), n.queues...)
+	degrades := append([]*degrade.Sender(nil), n.degrades...)
+	n.mu.Unlock()
+
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, healthTimeout)
+		defer cancel()
+	}
+
+	channels := []ChannelHealth{{Channel: telegram.Channel}, {Channel: email.Channel}}
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		h := &channels[0]
+		if tg == nil || !tg.Enabled {
+			h.Status = HealthDisabled
+			return
+		}
+		started := time.Now()
+		me, err := tg.Me(ctx)
+		h.Latency = time.Since(started)
+		h.check(err)
+		if err == nil {
+			h.Bot = &me
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		h := &channels[1]
+		if mail == nil || !mail.Enabled {
+			h.Status = HealthDisabled
+			return
+		}
+		started := time.Now()
+		err := mail.CheckConnection(ctx)
+		h.Latency = time.Since(started)
+		h.check(err)
+	}()
+	wg.Wait()
+
+	channel := func(name string) *ChannelHealth {
+		for i := range channels {
+			if channels[i].Channel == name {
+				return &channels[i]
+			}
+		}
+		// Каналы без проверки подключения считаются рабочими, пока очередь или деградация не скажут иного
+		channels = append(channels, ChannelHealth{Channel: name, Status: HealthOK})
+		return &channels[len(channels)-1]
+	}
+	for _, d := range queues {
+		h := channel(d.Channel())
+		h.Queue = &QueueHealth{Backlog: d.Backlog(), Capacity: d.Capacity(), Workers: d.Workers(), Latency: d.Latency()}
+		if h.Queue.Backlog >= h.Queue.Capacity {
+			h.worsen(HealthDegraded)
+		}
+	}
+	for _, s := range degrades {
+		state := s.Health()
+		h := channel(state.Channel)
+		h.Degrade = &state
+		if state.State == degrade.StateDegraded {
+			h.worsen(HealthDegraded)
+		}
+	}
+
+	report := HealthReport{Status: HealthOK, CheckedAt: time.Now(), Channels: channels}
+	for _, h := range channels {
+		if rank(h.Status) > rank(report.Status) {
+			report.Status = h.Status
+		}
+	}
+	return report
+}
+
+// check выставляет состояние по результату проверки подключения.
+func (h *ChannelHealth) check(err error) {
+	if err != nil {
+		h.Status, h.Error = HealthDown, err.Error()
+		return
+	}
+	h.Status = HealthOK
+}
+
+// worsen ухудшает состояние канала до status, но не улучшает его.
+func (h *ChannelHealth) worsen(status HealthStatus) {
+	if h.Status != HealthDisabled && rank(status) > rank(h.Status) {
+		h.Status = status
+	}
+}
+
+// rank упорядочивает состояния от лучшего к худшему. Отключённый канал не влияет на общее состояние.
+func rank(s HealthStatus) int {
+	switch s {
+	case HealthDegraded:
+		return 1
+	case HealthDown:
+		return 2
+	default:
+		return 0
+	}
+}
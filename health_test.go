@@ -0,0 +1,62 @@
+package notephee_test
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/epheer/notephee"
+	"github.com/epheer/notephee/degrade"
+	"github.com/epheer/notephee/notify"
+	"github.com/epheer/notephee/queue"
+)
+
+type slowSender struct {
+	channel string
+	delay   time.Duration
+}
+
+func (s *slowSender) Channel() string { return s.channel }
+
+func (s *slowSender) Send(_ context.Context, _ notify.Message) error {
+	time.Sleep(s.delay)
+	return nil
+}
+
+func TestHealth(t *testing.T) {
+	// Без Init клиенты Telegram и email не созданы
+	n := &notephee.Notephee{}
+	slow := degrade.Wrap(&slowSender{channel: "slack", delay: 20 * time.Millisecond}, degrade.Policy{Threshold: 10 * time.Millisecond}, slog.Default())
+	if err := slow.Send(context.Background(), notify.Message{To: "1", Text: "проба"}); err != nil {
+		t.Fatalf("Ошибка Send: %v", err)
+	}
+	n.MonitorDegrade(slow)
+
+	// Диспетчер не запущен, поэтому сообщения остаются в очереди
+	d := queue.NewDispatcher(&slowSender{channel: "vk"}, queue.Options{QueueSize: 4}, slog.Default())
+	for range 2 {
+		if err := d.Enqueue(context.Background(), notify.Message{To: "1", Text: "привет"}); err != nil {
+			t.Fatalf("Ошибка Enqueue: %v", err)
+		}
+	}
+	n.MonitorQueue(d)
+
+	report := n.Health(context.Background())
+	if report.Status != notephee.HealthDegraded || len(report.Channels) != 4 {
+		t.Fatalf("неверный отчёт: %+v", report)
+	}
+	byName := make(map[string]notephee.ChannelHealth)
+	for _, h := range report.Channels {
+		byName[h.Channel] = h
+	}
+	if byName["telegram"].Status != notephee.HealthDisabled || byName["email"].Status != notephee.HealthDisabled {
+		t.Fatalf("без клиентов Telegram и email не настроены: %+v", report.Channels)
+	}
+	if h := byName["slack"]; h.Status != notephee.HealthDegraded || h.Degrade == nil {
+		t.Fatalf("деградация канала не попала в отчёт: %+v", h)
+	}
+	if h := byName["vk"]; h.Status != notephee.HealthOK || h.Queue == nil || h.Queue.Backlog != 2 || h.Queue.Capacity != 4 {
+		t.Fatalf("неверное состояние очереди: %+v", h)
+	}
+}
@@ -0,0 +1,120 @@
+// Package hooks сообщает о каждой отправке структурированными событиями.
+//
+// Обработчики Hooks подключаются одной обёрткой над каналом, поэтому метрики, аудит
+// или вебхуки не требуют правок в клиентах каналов.
+package hooks
+
+import (
+	"context"
+	"time"
+
+	"github.com/epheer/notephee/delivery"
+	"github.com/epheer/notephee/notify"
+	"github.com/epheer/notephee/tracing"
+)
+
+// Send описывает одну попытку отправки.
+type Send struct {
+	Channel  string    // Имя канала
+	ID       string    // Идентификатор сообщения (если задан)
+	To       string    // Адрес получателя в канале
+	UserID   string    // Внутренний идентификатор пользователя (если задан)
+	Campaign string    // Идентификатор рассылки (если задан)
+	Category string    // Категория уведомления (если задана)
+	Priority string    // Приоритет сообщения
+	Attempt  int       // Номер повтора; 0 — первая попытка
+	Started  time.Time // Время начала попытки
+}
+
+// SendStart — событие начала отправки.
+type SendStart struct {
+	Send
+}
+
+// SendSuccess — событие успешной отправки.
+type SendSuccess struct {
+	Send
+	Duration time.Duration // Длительность попытки
+}
+
+// SendFailure — событие неудачной отправки.
+type SendFailure struct {
+	Send
+	Duration      time.Duration // Длительность попытки
+	Err           error         // Ошибка канала
+	Indeterminate bool          // Исход неизвестен: сообщение могло дойти (delivery.IsIndeterminate)
+}
+
+// Retry — событие повторной попытки. Следует перед SendStart той же попытки.
+type Retry struct {
+	Send
+}
+
+// Hooks — обработчики событий отправки. Пустые поля пропускаются.
+//
+// Обработчики вызываются синхронно в горутине отправки, поэтому долгую работу
+// (вебхуки, запись в БД) стоит выносить в отдельную горутину.
+type Hooks struct {
+	OnSendStart   func(ctx context.Context, e SendStart)
+	OnSendSuccess func(ctx context.Context, e SendSuccess)
+	OnSendFailure func(ctx context.Context, e SendFailure)
+	OnRetry       func(ctx context.Context, e Retry)
+}
+
+// Sender — обёртка над notify.Sender, вызывающая Hooks вокруг каждой отправки.
+type Sender struct {
+	next  notify.Sender // Обёрнутый канал
+	hooks Hooks         // Обработчики
+}
+
+// Wrap оборачивает канал next обработчиками hooks.
+//
+// Чтобы получать OnRetry, обёртка должна стоять внутри redelivery.Wrap: номер повтора
+// берётся из контекста (tracing.WithRetry).
+func Wrap(next notify.Sender, hooks Hooks) *Sender {
+	return &Sender{next: next, hooks: hooks}
+}
+
+// Channel возвращает имя обёрнутого канала.
+func (s *Sender) Channel() string {
+	return s.next.Channel()
+}
+
+// Unwrap возвращает обёрнутый канал.
+func (s *Sender) Unwrap() notify.Sender {
+	return s.next
+}
+
+// Send отправляет сообщение, сообщая о начале, повторе и исходе попытки.
+func (s *Sender) Send(ctx context.Context, msg notify.Message) error {
+	e := Send{
+		Channel:  s.next.Channel(),
+		ID:       msg.ID,
+		To:       msg.To,
+		UserID:   msg.UserID,
+		Campaign: msg.Campaign,
+		Category: msg.Category,
+		Priority: string(msg.Priority),
+		Attempt:  tracing.RetryCount(ctx),
+		Started:  time.Now(),
+	}
+	if e.Attempt > 0 && s.hooks.OnRetry != nil {
+		s.hooks.OnRetry(ctx, Retry{e})
+	}
+	if s.hooks.OnSendStart != nil {
+		s.hooks.OnSendStart(ctx, SendStart{e})
+	}
+
+	err := s.next.Send(ctx, msg)
+	duration := time.Since(e.Started)
+	if err != nil {
+		if s.hooks.OnSendFailure != nil {
+			s.hooks.OnSendFailure(ctx, SendFailure{Send: e, Duration: duration, Err: err, Indeterminate: delivery.IsIndeterminate(err)})
+		}
+		return err
+	}
+	if s.hooks.OnSendSuccess != nil {
+		s.hooks.OnSendSuccess(ctx, SendSuccess{Send: e, Duration: duration})
+	}
+	return nil
+}
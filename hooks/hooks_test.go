@@ -0,0 +1,63 @@
+package hooks_test
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"testing"
+
+	"github.com/epheer/notephee/delivery"
+	"github.com/epheer/notephee/hooks"
+	"github.com/epheer/notephee/notify"
+	"github.com/epheer/notephee/redelivery"
+)
+
+// flakySender первой попыткой возвращает ошибку с неизвестным исходом, второй — успех.
+type flakySender struct {
+	calls int
+}
+
+func (s *flakySender) Channel() string { return "fake" }
+
+func (s *flakySender) Send(context.Context, notify.Message) error {
+	s.calls++
+	if s.calls == 1 {
+		return fmt.Errorf("таймаут: %w", delivery.ErrIndeterminate)
+	}
+	return nil
+}
+
+func TestHooksReportAttempts(t *testing.T) {
+	var events []string
+	h := hooks.Hooks{
+		OnSendStart: func(_ context.Context, e hooks.SendStart) {
+			events = append(events, fmt.Sprintf("start %s %d", e.ID, e.Attempt))
+		},
+		OnSendSuccess: func(_ context.Context, e hooks.SendSuccess) {
+			events = append(events, fmt.Sprintf("success %d", e.Attempt))
+		},
+		OnSendFailure: func(_ context.Context, e hooks.SendFailure) {
+			events = append(events, fmt.Sprintf("failure %d %t", e.Attempt, e.Indeterminate))
+		},
+		OnRetry: func(_ context.Context, e hooks.Retry) {
+			events = append(events, fmt.Sprintf("retry %d", e.Attempt))
+		},
+	}
+	s := redelivery.Wrap(hooks.Wrap(&flakySender{}, h), redelivery.Resend, slog.Default())
+
+	if err := s.Send(context.Background(), notify.Message{ID: "m1", To: "1", Text: "привет"}); err != nil {
+		t.Fatalf("Ошибка Send: %v", err)
+	}
+
+	want := []string{"start m1 0", "failure 0 true", "retry 1", "start m1 1", "success 1"}
+	if fmt.Sprint(events) != fmt.Sprint(want) {
+		t.Fatalf("ожидались события %v, получены %v", want, events)
+	}
+}
+
+func TestEmptyHooks(t *testing.T) {
+	s := hooks.Wrap(&flakySender{calls: 1}, hooks.Hooks{})
+	if err := s.Send(context.Background(), notify.Message{To: "1"}); err != nil {
+		t.Fatalf("Ошибка Send: %v", err)
+	}
+}
@@ -0,0 +1,76 @@
+package amqp
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+
+	amqp091 "github.com/rabbitmq/amqp091-go"
+
+	"github.com/epheer/notephee/notify"
+)
+
+// fakeAcknowledger запоминает подтверждение сообщения.
+type fakeAcknowledger struct {
+	acked string
+}
+
+func (a *fakeAcknowledger) Ack(uint64, bool) error { a.acked = "ack"; return nil }
+
+func (a *fakeAcknowledger) Nack(_ uint64, _ bool, requeue bool) error {
+	a.acked = "drop"
+	if requeue {
+		a.acked = "requeue"
+	}
+	return nil
+}
+
+func (a *fakeAcknowledger) Reject(_ uint64, requeue bool) error { return a.Nack(0, false, requeue) }
+
+// fakeSink отвечает ошибкой err и запоминает последнее сообщение.
+type fakeSink struct {
+	err error
+	got notify.Message
+}
+
+func (s *fakeSink) Send(_ context.Context, _ string, msg notify.Message) error {
+	s.got = msg
+	return s.err
+}
+
+func TestHandle(t *testing.T) {
+	const body = `{"channel":"telegram","to":"42","text":"привет"}`
+	cases := []struct {
+		name        string
+		body        string
+		err         error
+		redelivered bool
+		want        string
+	}{
+		{"отправлено", body, nil, false, "ack"},
+		{"временная ошибка", body, errors.New("503"), false, "requeue"},
+		{"повторная доставка не помогла", body, errors.New("503"), true, "drop"},
+		{"некорректное сообщение", `{"to":`, nil, false, "drop"},
+		{"неизвестный исход", body, context.DeadlineExceeded, false, "drop"},
+	}
+	for _, tc := range cases {
+		ack := &fakeAcknowledger{}
+		c := NewConsumer(nil, nil, &fakeSink{err: tc.err}, Options{Queue: "notifications", Backoff: time.Millisecond}, slog.Default())
+		c.handle(context.Background(), amqp091.Delivery{Acknowledger: ack, Body: []byte(tc.body), Redelivered: tc.redelivered})
+		if ack.acked != tc.want {
+			t.Errorf("%s: ожидалось %s, получено %s", tc.name, tc.want, ack.acked)
+		}
+	}
+
+	// Повторная доставка сообщения с тем же MessageId получает тот же ID записи в журнале доставки
+	first, second := &fakeSink{}, &fakeSink{}
+	for _, s := range []*fakeSink{first, second} {
+		c := NewConsumer(nil, nil, s, Options{Queue: "notifications"}, slog.Default())
+		c.handle(context.Background(), amqp091.Delivery{Acknowledger: &fakeAcknowledger{}, MessageId: "m1", Body: []byte(body)})
+	}
+	if first.got.ID == "" || first.got.ID != second.got.ID {
+		t.Fatalf("ID повторной доставки различаются: %q и %q", first.got.ID, second.got.ID)
+	}
+}
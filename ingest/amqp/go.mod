@@ -0,0 +1,15 @@
+module github.com/epheer/notephee/ingest/amqp
+
+go 1.24.3
+
+replace github.com/epheer/notephee => ../../
+
+require (
+	github.com/epheer/notephee v0.0.0-00010101000000-000000000000
+	github.com/rabbitmq/amqp091-go v1.10.0
+)
+
+require (
+	github.com/google/uuid v1.6.0 // indirect
+	golang.org/x/time v0.11.0 // indirect
+)
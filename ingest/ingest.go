@@ -0,0 +1,245 @@
+// Package ingest — общая часть адаптеров брокеров сообщений (ingest/kafka, ingest/nats, ingest/amqp):
+// формат уведомления в сообщении, проверка и отправка в каналы notephee с повторами.
+//
+// Адаптеры живут в отдельных модулях со своими клиентами брокеров и по результату Processor.Process
+// подтверждают сообщение, просят доставить его повторно или отправляют в очередь необработанных (DLQ).
+package ingest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/epheer/notephee/delivery"
+	"github.com/epheer/notephee/notify"
+	"github.com/epheer/notephee/preferences"
+	"github.com/epheer/notephee/queue"
+)
+
+// Payload — уведомление в сообщении брокера. Поля совпадают с телом POST /v1/notifications HTTP API.
+type Payload struct {
+	Channel  string `json:"channel"`             // Канал отправки: telegram, email (без to может быть пустым)
+	To       string `json:"to"`                  // Адрес получателя в канале (пусто — из каталога по user_id)
+	UserID   string `json:"user_id,omitempty"`   // Внутренний ID пользователя
+	Subject  string `json:"subject,omitempty"`   // Тема (для email)
+	Text     string `json:"text"`                // Текст сообщения
+	Campaign string `json:"campaign,omitempty"`  // Идентификатор рассылки
+	DedupKey string `json:"dedup_key,omitempty"` // Ключ дедупликации
+	Priority string `json:"priority,omitempty"`  // Приоритет: low, normal, high
+	Category string `json:"category,omitempty"`  // Категория уведомления
+	Identity string `json:"identity,omitempty"`  // Личность отправителя
+
+	Notification *notify.Notification `json:"notification,omitempty"` // Структурированное уведомление вместо subject и text
+}
+
+// Validate проверяет, что у сообщения есть получатель и содержимое.
+func (p Payload) Validate() error {
+	if p.To == "" && p.UserID == "" {
+		return errors.New("поля to (или user_id) обязательны")
+	}
+	switch notify.Priority(p.Priority) {
+	case "", notify.PriorityLow, notify.PriorityNormal, notify.PriorityHigh:
+	default:
+		return fmt.Errorf("неизвестный приоритет: %q", p.Priority)
+	}
+	if p.Notification != nil {
+		return p.Notification.Validate()
+	}
+	if p.Text == "" {
+		return errors.New("поля text (или notification) обязательны")
+	}
+	return nil
+}
+
+// Message возвращает сообщение для отправки.
+func (p Payload) Message() notify.Message {
+	msg := notify.Message{Subject: p.Subject, Text: p.Text, Priority: notify.Priority(p.Priority)}
+	if p.Notification != nil {
+		msg = p.Notification.Message("")
+		if p.Priority != "" {
+			msg.Priority = notify.Priority(p.Priority)
+		}
+	}
+	msg.To = p.To
+	msg.UserID = p.UserID
+	msg.Campaign = p.Campaign
+	msg.DedupKey = p.DedupKey
+	msg.Category = p.Category
+	msg.Identity = p.Identity
+	return msg
+}
+
+// Decoder разбирает тело сообщения брокера.
+type Decoder func(value []byte) (Payload, error)
+
+// DecodeJSON разбирает тело в формате JSON. Неизвестные поля считаются ошибкой,
+// чтобы опечатка в имени поля не отправила сообщение без него.
+func DecodeJSON(value []byte) (Payload, error) {
+	var p Payload
+	dec := json.NewDecoder(bytes.NewReader(value))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&p); err != nil {
+		return Payload{}, err
+	}
+	return p, nil
+}
+
+// Sink принимает уведомления: notify.Registry отправляет их сразу, Queues ставит в очереди отправки.
+type Sink interface {
+	Send(ctx context.Context, channel string, msg notify.Message) error
+}
+
+// Resolver находит канал и адрес пользователя по user_id. Его реализует notify.Registry с каталогом
+// получателей; без него сообщения без to считаются некорректными.
+type Resolver interface {
+	Resolve(ctx context.Context, userID, channel, category string) (string, string, error)
+}
+
+// Queues — Sink, который ставит сообщения в очереди отправки по имени канала. Заполненная очередь
+// задерживает чтение из брокера, пока не освободится место.
+type Queues map[string]*queue.Dispatcher
+
+// Send ставит сообщение в очередь канала channel.
+func (q Queues) Send(ctx context.Context, channel string, msg notify.Message) error {
+	d, ok := q[channel]
+	if !ok {
+		return fmt.Errorf("%s: %w", channel, notify.ErrUnknownChannel)
+	}
+	return d.Enqueue(ctx, msg)
+}
+
+// Outcome — результат обработки сообщения, по которому адаптер подтверждает его в брокере.
+type Outcome int
+
+const (
+	Delivered     Outcome = iota // Отправлено: подтвердить
+	Rejected                     // Некорректно или не может быть доставлено: в DLQ, повтор не поможет
+	Failed                       // Временная ошибка: доставить повторно позже
+	Indeterminate                // Исход отправки неизвестен: не повторять, чтобы не отправить дважды
+	Skipped                      // Не отправлено по решению получателя (отказ, подавление): подтвердить
+)
+
+// String возвращает название результата для журнала.
+func (o Outcome) String() string {
+	switch o {
+	case Delivered:
+		return "delivered"
+	case Rejected:
+		return "rejected"
+	case Failed:
+		return "failed"
+	case Skipped:
+		return "skipped"
+	default:
+		return "indeterminate"
+	}
+}
+
+// Processor разбирает, проверяет и отправляет сообщения брокера.
+type Processor struct {
+	sink   Sink         // Получатель уведомлений
+	decode Decoder      // Разбор тела
+	logger *slog.Logger // Логгер
+
+	// MaxAttempts — попыток отправки внутри Process при временной ошибке; по умолчанию 1 — повтор
+	// остаётся брокеру (повторная доставка в NATS JetStream). Адаптеры без отложенной повторной доставки
+	// (Kafka, AMQP) задают больше.
+	MaxAttempts int
+	// Backoff — пауза перед повтором внутри Process, удваивается с каждой попыткой; по умолчанию 1 секунда.
+	Backoff time.Duration
+}
+
+// NewProcessor создаёт обработчик, который разбирает тела decode (nil — DecodeJSON) и отправляет в sink.
+func NewProcessor(sink Sink, decode Decoder, logger *slog.Logger) *Processor {
+	if decode == nil {
+		decode = DecodeJSON
+	}
+	return &Processor{sink: sink, decode: decode, logger: logger}
+}
+
+// Process разбирает тело value и отправляет уведомление. key — постоянный идентификатор сообщения
+// в брокере (топик и смещение, поток и номер); из него строится ID записи в журнале доставки, поэтому
+// повторная доставка того же сообщения попадает в ту же запись. Пустой key — ID генерируется каналом.
+//
+// Ошибка возвращается для всех результатов, кроме Delivered. При отмене ctx возвращается Failed.
+func (p *Processor) Process(ctx context.Context, key string, value []byte) (Outcome, error) {
+	payload, err := p.decode(value)
+	if err == nil {
+		err = payload.Validate()
+	}
+	if err == nil && payload.To == "" {
+		if _, ok := p.sink.(Resolver); !ok {
+			err = errors.New("user_id без to требует Sink с каталогом получателей")
+		}
+	}
+	if err != nil {
+		return Rejected, fmt.Errorf("некорректное сообщение: %w", err)
+	}
+
+	msg := payload.Message()
+	if key != "" {
+		msg.ID = uuid.NewSHA1(uuid.NameSpaceURL, []byte(key)).String()
+	}
+	channel := payload.Channel
+	if msg.To == "" {
+		if channel, msg.To, err = p.sink.(Resolver).Resolve(ctx, payload.UserID, payload.Channel, payload.Category); err != nil {
+			return p.outcome(ctx, err), err
+		}
+	}
+
+	attempts, backoff := max(p.MaxAttempts, 1), p.Backoff
+	if backoff <= 0 {
+		backoff = time.Second
+	}
+	for attempt := 1; ; attempt++ {
+		err = p.sink.Send(ctx, channel, msg)
+		outcome := p.outcome(ctx, err)
+		if outcome != Failed || attempt >= attempts || ctx.Err() != nil {
+			return outcome, err
+		}
+		p.logger.Warn("повтор отправки уведомления из брокера", "channel", channel, "attempt", attempt, "error", err)
+		select {
+		case <-ctx.Done():
+			return Failed, ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// outcome классифицирует ошибку отправки и записывает в журнал пропущенные сообщения.
+func (p *Processor) outcome(ctx context.Context, err error) Outcome {
+	outcome := Classify(ctx, err)
+	if outcome == Skipped {
+		p.logger.Info("уведомление из брокера пропущено по решению получателя", "reason", err)
+	}
+	return outcome
+}
+
+// Classify возвращает результат отправки с ошибкой err (nil — Delivered) — для очередей, которые
+// подтверждают сообщения так же, как адаптеры брокеров. Отказ получателя — не сбой: такое сообщение
+// подтверждается, а не уходит в DLQ, а получатель без адреса или с некорректным адресом не появится при повторе.
+func Classify(ctx context.Context, err error) Outcome {
+	switch {
+	case err == nil:
+		return Delivered
+	case ctx.Err() != nil:
+		return Failed
+	case errors.Is(err, preferences.ErrOptedOut), errors.Is(err, notify.ErrSuppressed), errors.Is(err, notify.ErrMuted):
+		return Skipped
+	case errors.Is(err, notify.ErrUnknownChannel), errors.Is(err, notify.ErrUnknownIdentity),
+		errors.Is(err, notify.ErrUnknownRecipient), errors.Is(err, notify.ErrNoAddress),
+		errors.Is(err, notify.ErrInvalidAddress):
+		return Rejected
+	case delivery.IsIndeterminate(err):
+		return Indeterminate
+	default:
+		return Failed
+	}
+}
@@ -0,0 +1,85 @@
+package ingest_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"testing"
+
+	"github.com/epheer/notephee/ingest"
+	"github.com/epheer/notephee/notify"
+	"github.com/epheer/notephee/preferences"
+)
+
+// sink отвечает ошибкой err и запоминает последнее сообщение.
+type sink struct {
+	err error
+	got notify.Message
+}
+
+func (s *sink) Send(_ context.Context, _ string, msg notify.Message) error {
+	s.got = msg
+	return s.err
+}
+
+// directory — sink с каталогом получателей.
+type directory struct {
+	sink
+}
+
+func (d *directory) Resolve(_ context.Context, userID, _, _ string) (string, string, error) {
+	if userID != "u1" {
+		return "", "", notify.ErrUnknownRecipient
+	}
+	return "telegram", "42", nil
+}
+
+func TestProcess(t *testing.T) {
+	ctx := context.Background()
+	cases := []struct {
+		name string
+		err  error
+		body string
+		want ingest.Outcome
+	}{
+		{"отправлено", nil, `{"channel":"telegram","to":"42","text":"привет"}`, ingest.Delivered},
+		{"некорректный JSON", nil, `{"to":`, ingest.Rejected},
+		{"неизвестное поле", nil, `{"channel":"telegram","to":"42","txt":"привет"}`, ingest.Rejected},
+		{"без получателя", nil, `{"channel":"telegram","text":"привет"}`, ingest.Rejected},
+		{"user_id без каталога", nil, `{"channel":"telegram","user_id":"u1","text":"привет"}`, ingest.Rejected},
+		{"неизвестный канал", notify.ErrUnknownChannel, `{"channel":"sms","to":"42","text":"привет"}`, ingest.Rejected},
+		{"временная ошибка", errors.New("503"), `{"channel":"telegram","to":"42","text":"привет"}`, ingest.Failed},
+		{"неизвестный исход", fmt.Errorf("отправка: %w", context.DeadlineExceeded), `{"channel":"telegram","to":"42","text":"привет"}`, ingest.Indeterminate},
+		{"отказ от категории", preferences.ErrOptedOut, `{"channel":"telegram","to":"42","text":"привет"}`, ingest.Skipped},
+		{"список подавления", fmt.Errorf("42: %w", notify.ErrSuppressed), `{"channel":"telegram","to":"42","text":"привет"}`, ingest.Skipped},
+		{"категория отключена", notify.ErrMuted, `{"channel":"telegram","to":"42","text":"привет"}`, ingest.Skipped},
+		{"нет адреса", notify.ErrNoAddress, `{"channel":"telegram","to":"42","text":"привет"}`, ingest.Rejected},
+		{"некорректный адрес", fmt.Errorf("x: %w", notify.ErrInvalidAddress), `{"channel":"email","to":"x","text":"привет"}`, ingest.Rejected},
+	}
+	for _, tc := range cases {
+		s := &sink{err: tc.err}
+		got, err := ingest.NewProcessor(s, nil, slog.Default()).Process(ctx, "broker:topic/1", []byte(tc.body))
+		if got != tc.want || (got == ingest.Delivered) != (err == nil) {
+			t.Errorf("%s: ожидался результат %s, получено %s (%v)", tc.name, tc.want, got, err)
+		}
+	}
+
+	d := &directory{}
+	if got, err := ingest.NewProcessor(d, nil, slog.Default()).Process(ctx, "k", []byte(`{"user_id":"u1","text":"привет"}`)); got != ingest.Delivered || d.got.To != "42" {
+		t.Fatalf("адрес по user_id не найден: %s %v %+v", got, err, d.got)
+	}
+	if got, _ := ingest.NewProcessor(d, nil, slog.Default()).Process(ctx, "k", []byte(`{"user_id":"u2","text":"привет"}`)); got != ingest.Rejected {
+		t.Fatalf("неизвестный пользователь должен отклоняться, получено %s", got)
+	}
+
+	// ID записи в журнале доставки зависит только от ключа сообщения
+	first := &sink{}
+	second := &sink{}
+	body := []byte(`{"channel":"telegram","to":"42","text":"привет"}`)
+	_, _ = ingest.NewProcessor(first, nil, slog.Default()).Process(ctx, "broker:topic/7", body)
+	_, _ = ingest.NewProcessor(second, nil, slog.Default()).Process(ctx, "broker:topic/7", body)
+	if first.got.ID == "" || first.got.ID != second.got.ID {
+		t.Fatalf("ID повторной доставки различаются: %q и %q", first.got.ID, second.got.ID)
+	}
+}
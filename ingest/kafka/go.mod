@@ -0,0 +1,22 @@
+module github.com/epheer/notephee/ingest/kafka
+
+go 1.24.3
+
+replace github.com/epheer/notephee => ../../
+
+require (
+	github.com/epheer/notephee v0.0.0-00010101000000-000000000000
+	github.com/hamba/avro/v2 v2.28.0
+	github.com/segmentio/kafka-go v0.4.49
+)
+
+require (
+	github.com/google/uuid v1.6.0 // indirect
+	github.com/json-iterator/go v1.1.12 // indirect
+	github.com/klauspost/compress v1.17.11 // indirect
+	github.com/mitchellh/mapstructure v1.5.0 // indirect
+	github.com/modern-go/concurrent v0.0.0-20180306012644-bacd9c7ef1dd // indirect
+	github.com/modern-go/reflect2 v1.0.2 // indirect
+	github.com/pierrec/lz4/v4 v4.1.15 // indirect
+	golang.org/x/time v0.11.0 // indirect
+)
@@ -0,0 +1,213 @@
+// Package kafka читает уведомления из топика Kafka и передаёт их в каналы notephee, чтобы сервисы-источники
+// не зависели от сервиса уведомлений: они пишут в топик, а notephee отправляет в своём темпе.
+// Формат уведомления и отправка — общие для всех брокеров, см. пакет ingest.
+//
+// Смещения хранятся в Kafka в группе потребителей и фиксируются после обработки сообщения: при перезапуске
+// необработанные сообщения читаются снова (доставка «хотя бы один раз»). Сообщения, которые не разбираются
+// или не отправились за MaxAttempts попыток, уходят в топик DLQ с текстом ошибки в заголовках и не
+// останавливают чтение.
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	kafkago "github.com/segmentio/kafka-go"
+
+	"github.com/epheer/notephee/alert"
+	"github.com/epheer/notephee/ingest"
+)
+
+// Форматы значений сообщений (Options.Format).
+const (
+	FormatJSON          = "json"           // JSON с полями POST /v1/notifications (ingest.Payload)
+	FormatAvro          = "avro"           // Avro по схеме Options.AvroSchema
+	FormatAvroConfluent = "avro-confluent" // Avro с префиксом Confluent Schema Registry
+)
+
+// Заголовки сообщения в топике DLQ.
+const (
+	HeaderError     = "notephee-error"     // Текст ошибки
+	HeaderTopic     = "notephee-topic"     // Исходный топик
+	HeaderPartition = "notephee-partition" // Исходная партиция
+	HeaderOffset    = "notephee-offset"    // Исходное смещение
+)
+
+// Options — настройки потребителя.
+type Options struct {
+	Brokers    []string // Адреса брокеров host:port
+	Topic      string   // Топик уведомлений
+	GroupID    string   // Группа потребителей: экземпляры делят партиции, смещения хранятся в Kafka
+	DLQTopic   string   // Топик для сообщений, которые не удалось обработать (пусто — только запись в журнал)
+	Format     string   // Формат значений: FormatJSON (по умолчанию), FormatAvro или FormatAvroConfluent
+	AvroSchema string   // Схема Avro (пусто — PayloadSchema)
+
+	MaxAttempts int           // Попыток отправки до DLQ; по умолчанию 3
+	Backoff     time.Duration // Пауза перед повторной отправкой, удваивается с каждой попыткой; по умолчанию 1 секунда
+}
+
+func (o *Options) defaults() {
+	if o.MaxAttempts <= 0 {
+		o.MaxAttempts = 3
+	}
+	if o.Backoff <= 0 {
+		o.Backoff = time.Second
+	}
+}
+
+// Reader — источник сообщений; его реализует *kafka.Reader из github.com/segmentio/kafka-go.
+type Reader interface {
+	FetchMessage(ctx context.Context) (kafkago.Message, error)
+	CommitMessages(ctx context.Context, msgs ...kafkago.Message) error
+	Close() error
+}
+
+// Writer — запись в топик DLQ; его реализует *kafka.Writer из github.com/segmentio/kafka-go.
+type Writer interface {
+	WriteMessages(ctx context.Context, msgs ...kafkago.Message) error
+	Close() error
+}
+
+// Consumer читает уведомления из топика и передаёт их в Sink.
+type Consumer struct {
+	reader    Reader            // Топик уведомлений
+	dlq       Writer            // Топик DLQ (необязательно)
+	processor *ingest.Processor // Разбор и отправка
+	opts      Options           // Настройки
+	logger    *slog.Logger      // Логгер
+}
+
+// New создаёт потребителя топика opts.Topic в группе opts.GroupID.
+func New(opts Options, sink ingest.Sink, logger *slog.Logger) (*Consumer, error) {
+	if len(opts.Brokers) == 0 || opts.Topic == "" || opts.GroupID == "" {
+		return nil, errors.New("для чтения из Kafka нужны брокеры, топик и группа потребителей")
+	}
+	decode, err := NewDecoder(opts.Format, opts.AvroSchema)
+	if err != nil {
+		return nil, err
+	}
+
+	reader := kafkago.NewReader(kafkago.ReaderConfig{
+		Brokers: opts.Brokers,
+		Topic:   opts.Topic,
+		GroupID: opts.GroupID,
+	})
+	var dlq Writer
+	if opts.DLQTopic != "" {
+		dlq = &kafkago.Writer{
+			Addr:                   kafkago.TCP(opts.Brokers...),
+			Topic:                  opts.DLQTopic,
+			Balancer:               &kafkago.Hash{},
+			AllowAutoTopicCreation: true,
+		}
+	}
+	return NewConsumer(reader, dlq, decode, sink, opts, logger), nil
+}
+
+// NewConsumer создаёт потребителя поверх готовых reader и dlq (nil — без DLQ), например с настройками
+// TLS и SASL, которых нет в Options. Из opts используются MaxAttempts, Backoff и Topic для журнала.
+func NewConsumer(reader Reader, dlq Writer, decode ingest.Decoder, sink ingest.Sink, opts Options, logger *slog.Logger) *Consumer {
+	opts.defaults()
+	processor := ingest.NewProcessor(sink, decode, logger)
+	processor.MaxAttempts, processor.Backoff = opts.MaxAttempts, opts.Backoff
+	return &Consumer{reader: reader, dlq: dlq, processor: processor, opts: opts, logger: logger}
+}
+
+// NewDecoder возвращает Decoder для формата format; schema используется форматами Avro.
+func NewDecoder(format, schema string) (ingest.Decoder, error) {
+	switch format {
+	case "", FormatJSON:
+		return ingest.DecodeJSON, nil
+	case FormatAvro:
+		return NewAvroDecoder(schema)
+	case FormatAvroConfluent:
+		d, err := NewAvroDecoder(schema)
+		if err != nil {
+			return nil, err
+		}
+		return Confluent(d), nil
+	default:
+		return nil, fmt.Errorf("неизвестный формат сообщений Kafka: %q", format)
+	}
+}
+
+// Run читает и обрабатывает сообщения, пока не отменён ctx. Смещение сообщения фиксируется после
+// отправки или записи в DLQ. Отмена ctx возвращает nil; ошибка чтения, фиксации смещения или записи
+// в DLQ останавливает чтение и возвращается: необработанное сообщение будет прочитано снова.
+func (c *Consumer) Run(ctx context.Context) error {
+	c.logger.Info("чтение уведомлений из Kafka", "topic", c.opts.Topic, "group", c.opts.GroupID)
+	for {
+		m, err := c.reader.FetchMessage(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("чтение из Kafka: %w", err)
+		}
+		if err := c.handle(ctx, m); err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		if err := c.reader.CommitMessages(ctx, m); err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("фиксация смещения Kafka: %w", err)
+		}
+	}
+}
+
+// Close закрывает чтение топика и запись в DLQ.
+func (c *Consumer) Close() error {
+	var dlqErr error
+	if c.dlq != nil {
+		dlqErr = c.dlq.Close()
+	}
+	return errors.Join(c.reader.Close(), dlqErr)
+}
+
+// handle разбирает и отправляет одно сообщение. Ошибка означает, что смещение фиксировать нельзя.
+// Сообщения, которые не удалось отправить, и отправки с неизвестным исходом уходят в DLQ: Kafka
+// не умеет доставить одно сообщение повторно, не остановив чтение партиции.
+func (c *Consumer) handle(ctx context.Context, m kafkago.Message) error {
+	key := fmt.Sprintf("kafka:%s/%d/%d", m.Topic, m.Partition, m.Offset)
+	outcome, err := c.processor.Process(ctx, key, m.Value)
+	switch {
+	case outcome == ingest.Delivered, outcome == ingest.Skipped:
+		return nil
+	case ctx.Err() != nil:
+		return ctx.Err()
+	default:
+		return c.deadLetter(ctx, m, err)
+	}
+}
+
+// deadLetter пишет сообщение в топик DLQ с ошибкой и исходным положением в заголовках.
+// Без DLQ сообщение только записывается в журнал.
+func (c *Consumer) deadLetter(ctx context.Context, m kafkago.Message, cause error) error {
+	c.logger.Error("уведомление из Kafka не обработано", "topic", m.Topic, "partition", m.Partition,
+		"offset", m.Offset, "dlq", c.opts.DLQTopic, "error", cause)
+	alert.Alert(alert.Warning, fmt.Sprintf("Уведомления из топика Kafka %s не обрабатываются и уходят в DLQ %q: проверьте журнал.",
+		c.opts.Topic, c.opts.DLQTopic))
+	if c.dlq == nil {
+		return nil
+	}
+
+	headers := append([]kafkago.Header(nil), m.Headers...)
+	headers = append(headers,
+		kafkago.Header{Key: HeaderError, Value: []byte(cause.Error())},
+		kafkago.Header{Key: HeaderTopic, Value: []byte(m.Topic)},
+		kafkago.Header{Key: HeaderPartition, Value: []byte(strconv.Itoa(m.Partition))},
+		kafkago.Header{Key: HeaderOffset, Value: []byte(strconv.FormatInt(m.Offset, 10))},
+	)
+	if err := c.dlq.WriteMessages(ctx, kafkago.Message{Key: m.Key, Value: m.Value, Headers: headers}); err != nil {
+		return fmt.Errorf("запись в DLQ %s: %w", c.opts.DLQTopic, err)
+	}
+	return nil
+}
@@ -0,0 +1,173 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hamba/avro/v2"
+	kafkago "github.com/segmentio/kafka-go"
+
+	"github.com/epheer/notephee/ingest"
+	"github.com/epheer/notephee/notify"
+)
+
+// fakeReader отдаёт сообщения по порядку, а затем ждёт отмены ctx.
+type fakeReader struct {
+	mu        sync.Mutex
+	messages  []kafkago.Message
+	committed []int64
+	cancel    context.CancelFunc
+}
+
+func (r *fakeReader) FetchMessage(ctx context.Context) (kafkago.Message, error) {
+	r.mu.Lock()
+	if len(r.messages) > 0 {
+		m := r.messages[0]
+		r.messages = r.messages[1:]
+		r.mu.Unlock()
+		return m, nil
+	}
+	r.mu.Unlock()
+	r.cancel()
+	<-ctx.Done()
+	return kafkago.Message{}, ctx.Err()
+}
+
+func (r *fakeReader) CommitMessages(_ context.Context, msgs ...kafkago.Message) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, m := range msgs {
+		r.committed = append(r.committed, m.Offset)
+	}
+	return nil
+}
+
+func (r *fakeReader) Close() error { return nil }
+
+// fakeWriter запоминает сообщения DLQ.
+type fakeWriter struct {
+	written []kafkago.Message
+}
+
+func (w *fakeWriter) WriteMessages(_ context.Context, msgs ...kafkago.Message) error {
+	w.written = append(w.written, msgs...)
+	return nil
+}
+
+func (w *fakeWriter) Close() error { return nil }
+
+// fakeSink запоминает отправки и отвечает ошибками из fail по порядку.
+type fakeSink struct {
+	sent  []notify.Message
+	calls int
+	fail  []error
+}
+
+func (s *fakeSink) Send(_ context.Context, channel string, msg notify.Message) error {
+	s.calls++
+	if channel != "telegram" {
+		return notify.ErrUnknownChannel
+	}
+	if len(s.fail) > 0 {
+		err := s.fail[0]
+		s.fail = s.fail[1:]
+		return err
+	}
+	s.sent = append(s.sent, msg)
+	return nil
+}
+
+func message(offset int64, value string) kafkago.Message {
+	return kafkago.Message{Topic: "notifications", Partition: 0, Offset: offset, Key: []byte("k"), Value: []byte(value)}
+}
+
+func run(t *testing.T, c *Consumer, r *fakeReader) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	r.cancel = cancel
+	if err := c.Run(ctx); err != nil {
+		t.Fatalf("Ошибка Run: %v", err)
+	}
+}
+
+func TestConsumer(t *testing.T) {
+	reader := &fakeReader{messages: []kafkago.Message{
+		message(1, `{"channel":"telegram","to":"42","text":"привет"}`),
+		message(2, `{"channel":"telegram","to":"42"`),
+		message(3, `{"channel":"slack","to":"C1","text":"нет канала"}`),
+		message(4, `{"channel":"telegram","to":"42","notification":{"title":"Сбой","severity":"critical"}}`),
+	}}
+	dlq := &fakeWriter{}
+	sink := &fakeSink{fail: []error{errors.New("503 Service Unavailable")}}
+	c := NewConsumer(reader, dlq, ingest.DecodeJSON, sink, Options{Topic: "notifications", DLQTopic: "notifications.dlq", Backoff: time.Millisecond}, slog.Default())
+	run(t, c, reader)
+
+	if len(reader.committed) != 4 {
+		t.Fatalf("ожидалась фиксация всех 4 смещений, получено %v", reader.committed)
+	}
+	if len(sink.sent) != 2 || sink.sent[0].Text != "привет" || sink.sent[1].Priority != notify.PriorityHigh {
+		t.Fatalf("неожиданные отправки: %+v", sink.sent)
+	}
+	// Первая отправка повторена после временной ошибки, канал slack не повторялся
+	if sink.calls != 4 {
+		t.Fatalf("ожидалось 4 вызова Send, получено %d", sink.calls)
+	}
+	if len(dlq.written) != 2 {
+		t.Fatalf("ожидалось 2 сообщения в DLQ, получено %d", len(dlq.written))
+	}
+	headers := map[string]string{}
+	for _, h := range dlq.written[0].Headers {
+		headers[h.Key] = string(h.Value)
+	}
+	if headers[HeaderOffset] != "2" || headers[HeaderTopic] != "notifications" || headers[HeaderError] == "" ||
+		string(dlq.written[0].Value) != `{"channel":"telegram","to":"42"` {
+		t.Fatalf("неверное сообщение DLQ: %+v", dlq.written[0])
+	}
+
+	// Повторное чтение того же сообщения получает тот же ID записи в журнале доставки
+	again := &fakeReader{messages: []kafkago.Message{message(1, `{"channel":"telegram","to":"42","text":"привет"}`)}}
+	first := sink.sent[0].ID
+	run(t, NewConsumer(again, dlq, ingest.DecodeJSON, sink, Options{}, slog.Default()), again)
+	if sink.sent[2].ID != first {
+		t.Fatalf("ID повторного чтения %s не совпал с %s", sink.sent[2].ID, first)
+	}
+}
+
+func TestAvroDecoder(t *testing.T) {
+	schema := avro.MustParse(PayloadSchema)
+	value, err := avro.Marshal(schema, avroPayload{
+		Channel: "telegram",
+		To:      "42",
+		Notification: &avroNotification{
+			Title:    "Деплой",
+			Actions:  []avroAction{{Label: "Журнал", URL: "https://ci.example.com/1"}},
+			Metadata: map[string]string{"сервис": "api"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Ошибка кодирования Avro: %v", err)
+	}
+
+	decode, err := NewDecoder(FormatAvroConfluent, "")
+	if err != nil {
+		t.Fatalf("Ошибка NewDecoder: %v", err)
+	}
+	p, err := decode(append([]byte{0, 0, 0, 0, 7}, value...))
+	if err != nil {
+		t.Fatalf("Ошибка разбора: %v", err)
+	}
+	if p.To != "42" || p.Notification == nil || p.Notification.Actions[0].Label != "Журнал" || p.Notification.Metadata["сервис"] != "api" {
+		t.Fatalf("неожиданный результат разбора: %+v", p)
+	}
+	if err := p.Validate(); err != nil {
+		t.Fatalf("Ошибка проверки: %v", err)
+	}
+	if _, err := decode(value); err == nil {
+		t.Fatal("значение без префикса Confluent должно отклоняться")
+	}
+}
@@ -0,0 +1,133 @@
+package kafka
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/hamba/avro/v2"
+
+	"github.com/epheer/notephee/ingest"
+	"github.com/epheer/notephee/notify"
+)
+
+// PayloadSchema — схема Avro для ingest.Payload. Необязательные поля — строки со значением по умолчанию "",
+// notification — null или запись.
+const PayloadSchema = `{
+  "type": "record",
+  "name": "Notification",
+  "namespace": "notephee.v1",
+  "fields": [
+    {"name": "channel", "type": "string", "default": ""},
+    {"name": "to", "type": "string", "default": ""},
+    {"name": "user_id", "type": "string", "default": ""},
+    {"name": "subject", "type": "string", "default": ""},
+    {"name": "text", "type": "string", "default": ""},
+    {"name": "campaign", "type": "string", "default": ""},
+    {"name": "dedup_key", "type": "string", "default": ""},
+    {"name": "priority", "type": "string", "default": ""},
+    {"name": "category", "type": "string", "default": ""},
+    {"name": "identity", "type": "string", "default": ""},
+    {"name": "notification", "default": null, "type": ["null", {
+      "type": "record",
+      "name": "Structured",
+      "fields": [
+        {"name": "title", "type": "string", "default": ""},
+        {"name": "body", "type": "string", "default": ""},
+        {"name": "severity", "type": "string", "default": ""},
+        {"name": "actions", "default": [], "type": {"type": "array", "items": {
+          "type": "record",
+          "name": "Action",
+          "fields": [{"name": "label", "type": "string"}, {"name": "url", "type": "string"}]
+        }}},
+        {"name": "metadata", "type": {"type": "map", "values": "string"}, "default": {}}
+      ]
+    }]}
+  ]
+}`
+
+// avroPayload — ingest.Payload с именами полей схемы Avro.
+type avroPayload struct {
+	Channel      string            `avro:"channel"`
+	To           string            `avro:"to"`
+	UserID       string            `avro:"user_id"`
+	Subject      string            `avro:"subject"`
+	Text         string            `avro:"text"`
+	Campaign     string            `avro:"campaign"`
+	DedupKey     string            `avro:"dedup_key"`
+	Priority     string            `avro:"priority"`
+	Category     string            `avro:"category"`
+	Identity     string            `avro:"identity"`
+	Notification *avroNotification `avro:"notification"`
+}
+
+type avroNotification struct {
+	Title    string            `avro:"title"`
+	Body     string            `avro:"body"`
+	Severity string            `avro:"severity"`
+	Actions  []avroAction      `avro:"actions"`
+	Metadata map[string]string `avro:"metadata"`
+}
+
+type avroAction struct {
+	Label string `avro:"label"`
+	URL   string `avro:"url"`
+}
+
+// NewAvroDecoder возвращает Decoder значений в формате Avro по схеме schema (пусто — PayloadSchema).
+// Схема должна совпадать с PayloadSchema по именам полей. Значения с префиксом Confluent Schema Registry
+// разбирает обёртка Confluent.
+func NewAvroDecoder(schema string) (ingest.Decoder, error) {
+	if strings.TrimSpace(schema) == "" {
+		schema = PayloadSchema
+	}
+	s, err := avro.Parse(schema)
+	if err != nil {
+		return nil, fmt.Errorf("некорректная схема Avro: %w", err)
+	}
+
+	return func(value []byte) (ingest.Payload, error) {
+		var a avroPayload
+		if err := avro.Unmarshal(s, value, &a); err != nil {
+			return ingest.Payload{}, err
+		}
+		p := ingest.Payload{
+			Channel:  a.Channel,
+			To:       a.To,
+			UserID:   a.UserID,
+			Subject:  a.Subject,
+			Text:     a.Text,
+			Campaign: a.Campaign,
+			DedupKey: a.DedupKey,
+			Priority: a.Priority,
+			Category: a.Category,
+			Identity: a.Identity,
+		}
+		if n := a.Notification; n != nil {
+			p.Notification = &notify.Notification{
+				Title:    n.Title,
+				Body:     n.Body,
+				Severity: notify.Severity(n.Severity),
+				Metadata: n.Metadata,
+			}
+			for _, action := range n.Actions {
+				p.Notification.Actions = append(p.Notification.Actions, notify.Action{Label: action.Label, URL: action.URL})
+			}
+		}
+		return p, nil
+	}, nil
+}
+
+// confluentHeader — длина префикса Confluent Schema Registry: нулевой байт и 4 байта ID схемы.
+const confluentHeader = 5
+
+// Confluent возвращает Decoder значений с префиксом Confluent Schema Registry: префикс проверяется
+// и отбрасывается, остаток разбирает d. ID схемы не используется — все значения разбираются схемой d.
+func Confluent(d ingest.Decoder) ingest.Decoder {
+	return func(value []byte) (ingest.Payload, error) {
+		if len(value) < confluentHeader || value[0] != 0 {
+			return ingest.Payload{}, errors.New("нет префикса Confluent Schema Registry")
+		}
+		return d(value[confluentHeader:])
+	}
+}
@@ -0,0 +1,21 @@
+module github.com/epheer/notephee/ingest/nats
+
+go 1.24.3
+
+replace github.com/epheer/notephee => ../../
+
+require (
+	github.com/epheer/notephee v0.0.0-00010101000000-000000000000
+	github.com/nats-io/nats.go v1.37.0
+)
+
+require (
+	github.com/google/uuid v1.6.0 // indirect
+	github.com/klauspost/compress v1.17.2 // indirect
+	github.com/nats-io/nkeys v0.4.7 // indirect
+	github.com/nats-io/nuid v1.0.1 // indirect
+	golang.org/x/crypto v0.18.0 // indirect
+	golang.org/x/sys v0.40.0 // indirect
+	golang.org/x/text v0.33.0 // indirect
+	golang.org/x/time v0.11.0 // indirect
+)
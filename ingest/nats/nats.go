@@ -0,0 +1,199 @@
+// Package nats читает уведомления из потока NATS JetStream и передаёт их в каналы notephee.
+// Формат уведомления и отправка — общие для всех брокеров, см. пакет ingest.
+//
+// Подтверждение сообщения следует результату отправки: отправленное подтверждается (Ack), после временной
+// ошибки сервер доставляет его повторно с растущей паузой (NakWithDelay), а некорректное, отправленное
+// с неизвестным исходом или не отправленное за MaxDeliver доставок публикуется в тему DLQ и больше
+// не доставляется (Term).
+package nats
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+
+	"github.com/epheer/notephee/alert"
+	"github.com/epheer/notephee/ingest"
+)
+
+// Заголовки сообщения в теме DLQ.
+const (
+	HeaderError    = "Notephee-Error"    // Текст ошибки
+	HeaderStream   = "Notephee-Stream"   // Исходный поток
+	HeaderSequence = "Notephee-Sequence" // Номер сообщения в потоке
+	HeaderSubject  = "Notephee-Subject"  // Исходная тема
+)
+
+// Options — настройки потребителя.
+type Options struct {
+	URL        string // Адрес сервера NATS, например nats://localhost:4222
+	Stream     string // Поток JetStream с уведомлениями
+	Consumer   string // Имя постоянного потребителя: экземпляры с одним именем делят сообщения
+	Subject    string // Тема уведомлений в потоке (пусто — все темы потока)
+	DLQSubject string // Тема для сообщений, которые не удалось обработать (пусто — только запись в журнал)
+
+	MaxDeliver int           // Доставок сообщения до DLQ; по умолчанию 5
+	Backoff    time.Duration // Пауза перед повторной доставкой, удваивается с каждой доставкой; по умолчанию 1 секунда
+}
+
+func (o *Options) defaults() {
+	if o.MaxDeliver <= 0 {
+		o.MaxDeliver = 5
+	}
+	if o.Backoff <= 0 {
+		o.Backoff = time.Second
+	}
+}
+
+// Publisher — публикация в тему DLQ; его реализует jetstream.JetStream.
+type Publisher interface {
+	PublishMsg(ctx context.Context, msg *nats.Msg, opts ...jetstream.PublishOpt) (*jetstream.PubAck, error)
+}
+
+// Consumer читает уведомления из потока и передаёт их в Sink.
+type Consumer struct {
+	consumer  jetstream.Consumer // Потребитель JetStream
+	dlq       Publisher          // Публикация в DLQ
+	conn      *nats.Conn         // Соединение, закрываемое Close (nil, если передано вызывающим)
+	processor *ingest.Processor  // Разбор и отправка
+	opts      Options            // Настройки
+	logger    *slog.Logger       // Логгер
+}
+
+// New подключается к серверу opts.URL и создаёт (или обновляет) постоянного потребителя opts.Consumer
+// потока opts.Stream. Тела сообщений разбираются как JSON (ingest.DecodeJSON).
+func New(ctx context.Context, opts Options, sink ingest.Sink, logger *slog.Logger) (*Consumer, error) {
+	if opts.URL == "" || opts.Stream == "" || opts.Consumer == "" {
+		return nil, errors.New("для чтения из NATS нужны адрес сервера, поток и имя потребителя")
+	}
+	opts.defaults()
+
+	conn, err := nats.Connect(opts.URL, nats.Name("notephee"))
+	if err != nil {
+		return nil, fmt.Errorf("подключение к NATS: %w", err)
+	}
+	js, err := jetstream.New(conn)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	consumer, err := js.CreateOrUpdateConsumer(ctx, opts.Stream, jetstream.ConsumerConfig{
+		Durable:       opts.Consumer,
+		FilterSubject: opts.Subject,
+		AckPolicy:     jetstream.AckExplicitPolicy,
+		// Предел доставок соблюдает сам Consumer, чтобы отправить сообщение в DLQ, а не потерять его
+		MaxDeliver: -1,
+	})
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("потребитель NATS %s/%s: %w", opts.Stream, opts.Consumer, err)
+	}
+
+	c := NewConsumer(consumer, js, nil, sink, opts, logger)
+	c.conn = conn
+	return c, nil
+}
+
+// NewConsumer создаёт потребителя поверх готового consumer, например с настройками TLS и авторизации,
+// которых нет в Options. dlq — публикация в DLQ (nil — без DLQ), decode — разбор тел (nil — JSON).
+func NewConsumer(consumer jetstream.Consumer, dlq Publisher, decode ingest.Decoder, sink ingest.Sink, opts Options, logger *slog.Logger) *Consumer {
+	opts.defaults()
+	return &Consumer{
+		consumer:  consumer,
+		dlq:       dlq,
+		processor: ingest.NewProcessor(sink, decode, logger),
+		opts:      opts,
+		logger:    logger,
+	}
+}
+
+// Run обрабатывает сообщения, пока не отменён ctx.
+func (c *Consumer) Run(ctx context.Context) error {
+	cc, err := c.consumer.Consume(func(msg jetstream.Msg) {
+		c.handle(ctx, msg)
+	})
+	if err != nil {
+		return fmt.Errorf("чтение из NATS: %w", err)
+	}
+	c.logger.Info("чтение уведомлений из NATS JetStream", "stream", c.opts.Stream, "consumer", c.opts.Consumer)
+	<-ctx.Done()
+	cc.Stop()
+	return nil
+}
+
+// Close закрывает соединение, открытое New.
+func (c *Consumer) Close() error {
+	if c.conn != nil {
+		return c.conn.Drain()
+	}
+	return nil
+}
+
+// handle обрабатывает одно сообщение и подтверждает его по результату.
+func (c *Consumer) handle(ctx context.Context, msg jetstream.Msg) {
+	meta, err := msg.Metadata()
+	if err != nil {
+		c.logger.Warn("сообщение NATS без метаданных JetStream", "subject", msg.Subject(), "error", err)
+		_ = msg.Term()
+		return
+	}
+
+	key := fmt.Sprintf("nats:%s/%d", meta.Stream, meta.Sequence.Stream)
+	outcome, err := c.processor.Process(ctx, key, msg.Data())
+	switch {
+	case outcome == ingest.Delivered, outcome == ingest.Skipped:
+		c.ack(msg.Ack())
+	case outcome == ingest.Failed && ctx.Err() != nil:
+		// Остановка: сообщение будет доставлено другому экземпляру или после перезапуска
+		_ = msg.Nak()
+	case outcome == ingest.Failed && meta.NumDelivered < uint64(c.opts.MaxDeliver):
+		delay := c.opts.Backoff << min(meta.NumDelivered-1, 16)
+		c.logger.Warn("повторная доставка уведомления из NATS", "stream", meta.Stream, "sequence", meta.Sequence.Stream,
+			"delivered", meta.NumDelivered, "delay", delay, "error", err)
+		c.ack(msg.NakWithDelay(delay))
+	default:
+		if dlqErr := c.deadLetter(ctx, msg, meta, outcome, err); dlqErr != nil {
+			// Без записи в DLQ сообщение не теряется: сервер доставит его снова
+			c.logger.Error("не удалось опубликовать сообщение в DLQ NATS", "subject", c.opts.DLQSubject, "error", dlqErr)
+			c.ack(msg.NakWithDelay(c.opts.Backoff))
+			return
+		}
+		c.ack(msg.Term())
+	}
+}
+
+// ack записывает в журнал ошибку подтверждения: сервер доставит сообщение повторно по AckWait.
+func (c *Consumer) ack(err error) {
+	if err != nil {
+		c.logger.Warn("не удалось подтвердить сообщение NATS", "error", err)
+	}
+}
+
+// deadLetter публикует сообщение в тему DLQ с ошибкой и исходным положением в заголовках.
+// Без DLQ сообщение только записывается в журнал.
+func (c *Consumer) deadLetter(ctx context.Context, msg jetstream.Msg, meta *jetstream.MsgMetadata, outcome ingest.Outcome, cause error) error {
+	c.logger.Error("уведомление из NATS не обработано", "stream", meta.Stream, "sequence", meta.Sequence.Stream,
+		"outcome", outcome, "dlq", c.opts.DLQSubject, "error", cause)
+	alert.Alert(alert.Warning, fmt.Sprintf("Уведомления из потока NATS %s не обрабатываются и уходят в DLQ %q: проверьте журнал.",
+		c.opts.Stream, c.opts.DLQSubject))
+	if c.dlq == nil || c.opts.DLQSubject == "" {
+		return nil
+	}
+
+	header := nats.Header{}
+	for k, v := range msg.Headers() {
+		header[k] = append([]string(nil), v...)
+	}
+	header.Set(HeaderError, cause.Error())
+	header.Set(HeaderStream, meta.Stream)
+	header.Set(HeaderSequence, strconv.FormatUint(meta.Sequence.Stream, 10))
+	header.Set(HeaderSubject, msg.Subject())
+	_, err := c.dlq.PublishMsg(ctx, &nats.Msg{Subject: c.opts.DLQSubject, Header: header, Data: msg.Data()})
+	return err
+}
@@ -0,0 +1,97 @@
+package nats
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+
+	"github.com/epheer/notephee/notify"
+)
+
+// fakeMsg — сообщение JetStream, которое запоминает подтверждение.
+type fakeMsg struct {
+	jetstream.Msg
+	data      string
+	delivered uint64
+	acked     string
+	delay     time.Duration
+}
+
+func (m *fakeMsg) Metadata() (*jetstream.MsgMetadata, error) {
+	return &jetstream.MsgMetadata{
+		Stream:       "NOTIFICATIONS",
+		Sequence:     jetstream.SequencePair{Stream: 7},
+		NumDelivered: m.delivered,
+	}, nil
+}
+
+func (m *fakeMsg) Data() []byte         { return []byte(m.data) }
+func (m *fakeMsg) Subject() string      { return "notifications.telegram" }
+func (m *fakeMsg) Headers() nats.Header { return nats.Header{"Trace": {"t1"}} }
+func (m *fakeMsg) Ack() error           { m.acked = "ack"; return nil }
+func (m *fakeMsg) Nak() error           { m.acked = "nak"; return nil }
+func (m *fakeMsg) Term() error          { m.acked = "term"; return nil }
+
+func (m *fakeMsg) NakWithDelay(d time.Duration) error {
+	m.acked, m.delay = "nak", d
+	return nil
+}
+
+// fakePublisher запоминает сообщения DLQ.
+type fakePublisher struct {
+	published []*nats.Msg
+}
+
+func (p *fakePublisher) PublishMsg(_ context.Context, msg *nats.Msg, _ ...jetstream.PublishOpt) (*jetstream.PubAck, error) {
+	p.published = append(p.published, msg)
+	return &jetstream.PubAck{}, nil
+}
+
+// fakeSink отвечает ошибкой err.
+type fakeSink struct {
+	err error
+}
+
+func (s *fakeSink) Send(context.Context, string, notify.Message) error { return s.err }
+
+func TestHandle(t *testing.T) {
+	const body = `{"channel":"telegram","to":"42","text":"привет"}`
+	cases := []struct {
+		name      string
+		data      string
+		err       error
+		delivered uint64
+		want      string
+		dlq       bool
+	}{
+		{"отправлено", body, nil, 1, "ack", false},
+		{"временная ошибка", body, errors.New("503"), 2, "nak", false},
+		{"доставки исчерпаны", body, errors.New("503"), 5, "term", true},
+		{"некорректное сообщение", `{"to":`, nil, 1, "term", true},
+		{"неизвестный исход", body, context.DeadlineExceeded, 1, "term", true},
+	}
+	for _, tc := range cases {
+		dlq := &fakePublisher{}
+		c := NewConsumer(nil, dlq, nil, &fakeSink{err: tc.err}, Options{Stream: "NOTIFICATIONS", DLQSubject: "notifications.dlq", Backoff: time.Second}, slog.Default())
+		msg := &fakeMsg{data: tc.data, delivered: tc.delivered}
+		c.handle(context.Background(), msg)
+
+		if msg.acked != tc.want || (len(dlq.published) == 1) != tc.dlq {
+			t.Errorf("%s: ожидалось %s (DLQ %v), получено %s (DLQ %d)", tc.name, tc.want, tc.dlq, msg.acked, len(dlq.published))
+		}
+		if tc.name == "временная ошибка" && msg.delay != 2*time.Second {
+			t.Errorf("пауза перед второй повторной доставкой должна удвоиться, получено %s", msg.delay)
+		}
+		if tc.dlq && len(dlq.published) == 1 {
+			h := dlq.published[0].Header
+			if h.Get(HeaderSequence) != "7" || h.Get(HeaderError) == "" || h.Get("Trace") != "t1" || string(dlq.published[0].Data) != tc.data {
+				t.Errorf("%s: неверное сообщение DLQ: %+v", tc.name, dlq.published[0])
+			}
+		}
+	}
+}
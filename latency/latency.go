@@ -0,0 +1,166 @@
+// Package latency измеряет сквозную задержку уведомлений: от постановки в очередь
+// (notify.Message.EnqueuedAt) до приёма сообщения провайдером.
+//
+// Tracker считает перцентили задержки по каналам, а сообщения с Message.SLO, доставленные
+// дольше допустимого, отмечаются как опоздавшие.
+package latency
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/epheer/notephee/notify"
+)
+
+// DefaultWindow — сколько последних отправок канала учитывается в перцентилях.
+const DefaultWindow = 1000
+
+// Stats — задержка канала по последним отправкам.
+type Stats struct {
+	Channel string        // Имя канала
+	Count   int64         // Успешных отправок с момента запуска
+	Late    int64         // Из них доставлено позже SLO сообщения
+	P50     time.Duration // Медиана задержки
+	P95     time.Duration // 95-й перцентиль
+	P99     time.Duration // 99-й перцентиль
+}
+
+// Result — задержка одной отправки.
+type Result struct {
+	Latency time.Duration // Время от постановки в очередь до приёма провайдером
+	Late    bool          // Задержка превысила SLO сообщения
+}
+
+// Measure возвращает задержку сообщения msg, принятого провайдером в момент at.
+// Если EnqueuedAt не задано, задержка не известна и Result нулевой.
+func Measure(msg notify.Message, at time.Time) Result {
+	if msg.EnqueuedAt.IsZero() {
+		return Result{}
+	}
+	d := at.Sub(msg.EnqueuedAt)
+	return Result{Latency: d, Late: msg.SLO > 0 && d > msg.SLO}
+}
+
+// Tracker собирает задержки отправок по каналам. Безопасен для конкурентного использования.
+type Tracker struct {
+	mu       sync.Mutex
+	window   int
+	channels map[string]*series
+}
+
+// series — кольцевой буфер последних задержек канала.
+type series struct {
+	samples []time.Duration
+	next    int
+	count   int64
+	late    int64
+}
+
+// NewTracker создаёт Tracker, считающий перцентили по window последним отправкам канала
+// (DefaultWindow, если window <= 0).
+func NewTracker(window int) *Tracker {
+	if window <= 0 {
+		window = DefaultWindow
+	}
+	return &Tracker{window: window, channels: make(map[string]*series)}
+}
+
+// Observe учитывает задержку отправки в канал channel.
+func (t *Tracker) Observe(channel string, r Result) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	s, ok := t.channels[channel]
+	if !ok {
+		s = &series{samples: make([]time.Duration, 0, t.window)}
+		t.channels[channel] = s
+	}
+	s.count++
+	if r.Late {
+		s.late++
+	}
+	if len(s.samples) < t.window {
+		s.samples = append(s.samples, r.Latency)
+		return
+	}
+	s.samples[s.next] = r.Latency
+	s.next = (s.next + 1) % t.window
+}
+
+// Stats возвращает задержки всех каналов, по которым были отправки, в алфавитном порядке.
+func (t *Tracker) Stats() []Stats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	out := make([]Stats, 0, len(t.channels))
+	for channel, s := range t.channels {
+		sorted := slices.Clone(s.samples)
+		slices.Sort(sorted)
+		out = append(out, Stats{
+			Channel: channel,
+			Count:   s.count,
+			Late:    s.late,
+			P50:     percentile(sorted, 0.50),
+			P95:     percentile(sorted, 0.95),
+			P99:     percentile(sorted, 0.99),
+		})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Channel < out[j].Channel })
+	return out
+}
+
+// percentile возвращает перцентиль q отсортированной выборки методом ближайшего ранга.
+func percentile(sorted []time.Duration, q float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	i := int(float64(len(sorted))*q+0.5) - 1
+	return sorted[min(max(i, 0), len(sorted)-1)]
+}
+
+// Sender — обёртка над notify.Sender, записывающая в Tracker задержку каждой успешной отправки.
+//
+// Оборачивать нужно клиент канала напрямую, чтобы задержка заканчивалась приёмом сообщения
+// провайдером, а не откладыванием в сводку или буфер деградации.
+type Sender struct {
+	next    notify.Sender // Обёрнутый канал
+	tracker *Tracker      // Сборщик задержек
+	logger  *slog.Logger  // Логгер
+}
+
+// Wrap оборачивает канал next.
+func Wrap(next notify.Sender, tracker *Tracker, logger *slog.Logger) *Sender {
+	return &Sender{next: next, tracker: tracker, logger: logger}
+}
+
+// Channel возвращает имя обёрнутого канала.
+func (s *Sender) Channel() string {
+	return s.next.Channel()
+}
+
+// Unwrap возвращает обёрнутый канал.
+func (s *Sender) Unwrap() notify.Sender {
+	return s.next
+}
+
+// Send отправляет сообщение и учитывает его задержку. Сообщения без EnqueuedAt
+// считаются поставленными в очередь в момент вызова Send.
+func (s *Sender) Send(ctx context.Context, msg notify.Message) error {
+	if msg.EnqueuedAt.IsZero() {
+		msg.EnqueuedAt = time.Now()
+	}
+	if err := s.next.Send(ctx, msg); err != nil {
+		return err
+	}
+
+	r := Measure(msg, time.Now())
+	s.tracker.Observe(s.next.Channel(), r)
+	if r.Late {
+		s.logger.Warn("сообщение доставлено позже SLO", "channel", s.next.Channel(), "id", msg.ID, "latency", r.Latency, "slo", msg.SLO)
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package latency_test
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/epheer/notephee/latency"
+	"github.com/epheer/notephee/notify"
+)
+
+type slowSender struct {
+	delay time.Duration
+}
+
+func (s *slowSender) Channel() string { return "fake" }
+
+func (s *slowSender) Send(context.Context, notify.Message) error {
+	time.Sleep(s.delay)
+	return nil
+}
+
+func TestPercentiles(t *testing.T) {
+	tracker := latency.NewTracker(100)
+	// Старые значения вытесняются из окна, но учитываются в счётчике
+	for range 50 {
+		tracker.Observe("fake", latency.Result{Latency: time.Hour})
+	}
+	for i := 1; i <= 100; i++ {
+		tracker.Observe("fake", latency.Result{Latency: time.Duration(i) * time.Millisecond, Late: i > 90})
+	}
+
+	stats := tracker.Stats()
+	if len(stats) != 1 {
+		t.Fatalf("ожидался один канал, получено %d", len(stats))
+	}
+	got := stats[0]
+	if got.Count != 150 || got.Late != 10 {
+		t.Fatalf("неверные счётчики: %+v", got)
+	}
+	if got.P50 != 50*time.Millisecond || got.P95 != 95*time.Millisecond || got.P99 != 99*time.Millisecond {
+		t.Fatalf("неверные перцентили: %+v", got)
+	}
+}
+
+func TestLateMessage(t *testing.T) {
+	tracker := latency.NewTracker(0)
+	s := latency.Wrap(&slowSender{delay: 20 * time.Millisecond}, tracker, slog.Default())
+
+	enqueued := time.Now().Add(-time.Second)
+	if err := s.Send(context.Background(), notify.Message{To: "1", Text: "a", EnqueuedAt: enqueued, SLO: 500 * time.Millisecond}); err != nil {
+		t.Fatalf("Ошибка Send: %v", err)
+	}
+	if err := s.Send(context.Background(), notify.Message{To: "1", Text: "b", SLO: time.Second}); err != nil {
+		t.Fatalf("Ошибка Send: %v", err)
+	}
+
+	got := tracker.Stats()[0]
+	if got.Count != 2 || got.Late != 1 {
+		t.Fatalf("опоздать должно только сообщение из очереди: %+v", got)
+	}
+	if got.P99 < time.Second {
+		t.Fatalf("задержка должна считаться от EnqueuedAt, p99 = %v", got.P99)
+	}
+
+	r := latency.Measure(notify.Message{EnqueuedAt: enqueued}, enqueued.Add(time.Minute))
+	if r.Latency != time.Minute || r.Late {
+		t.Fatalf("без SLO сообщение не опаздывает: %+v", r)
+	}
+}
@@ -0,0 +1,215 @@
+// Package matrix реализует канал Matrix: отправку сообщений в комнаты через Client-Server API
+// с авторизацией по access token. Подходит для self-hosted Matrix/Element.
+package matrix
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/epheer/notephee/config"
+	"github.com/epheer/notephee/delivery"
+	"github.com/epheer/notephee/notify"
+	"github.com/epheer/notephee/tracing"
+)
+
+// Channel — имя канала Matrix в notify.Registry и журнале доставки.
+const Channel = "matrix"
+
+// Типы сообщений m.room.message.
+const (
+	MsgText   = "m.text"
+	MsgNotice = "m.notice" // Сообщение бота: клиенты не отвечают на него автоматически
+)
+
+// HTMLFormat — значение format для formatted_body в HTML.
+const HTMLFormat = "org.matrix.custom.html"
+
+// MessageOptions содержит параметры одного сообщения в комнату.
+type MessageOptions struct {
+	RoomID        string // Идентификатор комнаты, например !abc:example.org
+	Body          string // Текст без разметки (обязателен, показывается клиентами без HTML)
+	FormattedBody string // Текст в HTML (необязательно)
+	MsgType       string // m.text или m.notice; пустой — m.text
+	UserID        string // Внутренний ID пользователя для журнала доставки (необязательно)
+	ID            string // Идентификатор попытки: также используется как txnId для идемпотентности
+}
+
+// content — тело события m.room.message.
+type content struct {
+	MsgType       string `json:"msgtype"`
+	Body          string `json:"body"`
+	Format        string `json:"format,omitempty"`
+	FormattedBody string `json:"formatted_body,omitempty"`
+}
+
+// Response — ответ сервера на отправку события.
+type Response struct {
+	EventID      string `json:"event_id,omitempty"`       // ID созданного события
+	ErrCode      string `json:"errcode,omitempty"`        // Код ошибки, например M_FORBIDDEN
+	Error        string `json:"error,omitempty"`          // Описание ошибки
+	RetryAfterMs int    `json:"retry_after_ms,omitempty"` // Задержка перед повтором при M_LIMIT_EXCEEDED
+}
+
+// Client инкапсулирует клиента Matrix Client-Server API.
+type Client struct {
+	homeserver string       // Базовый URL сервера, например https://matrix.example.org
+	token      string       // Access token пользователя-бота
+	http       *http.Client // HTTP-клиент
+	logger     *slog.Logger // Логгер
+	Enabled    bool         // Флаг доступности функционала
+
+	deliveryLog delivery.DeliveryLog // Журнал попыток отправки (необязательно)
+	inflight    notify.InFlight      // Начатые отправки, которых ждёт Close
+}
+
+// NewClient создаёт клиента Matrix.
+//
+// cfg — конфигурация приложения с адресом сервера и access token.
+// logger — логгер для ведения журнала.
+func NewClient(cfg *config.Config, logger *slog.Logger) *Client {
+	return &Client{
+		homeserver: strings.TrimRight(cfg.MatrixHomeserver, "/"),
+		token:      cfg.MatrixToken,
+		http:       &http.Client{Timeout: 10 * time.Second},
+		logger:     logger,
+		Enabled:    cfg.IsMatrixEnabled(),
+	}
+}
+
+// SetDeliveryLog подключает журнал, в который записывается каждая попытка отправки.
+func (c *Client) SetDeliveryLog(log delivery.DeliveryLog) {
+	c.deliveryLog = log
+}
+
+// Close перестаёт принимать новые отправки и ждёт завершения начатых до истечения ctx.
+// Отправки после Close возвращают notify.ErrClosed.
+func (c *Client) Close(ctx context.Context) error {
+	return c.inflight.Close(ctx)
+}
+
+// Channel возвращает имя канала для notify.Registry.
+func (c *Client) Channel() string {
+	return Channel
+}
+
+// Capabilities возвращает возможности канала: HTML в MessageOptions.FormattedBody и тема жирной строкой.
+func (c *Client) Capabilities() notify.Capabilities {
+	return notify.Capabilities{Channel: Channel, Subject: true, HTML: true}
+}
+
+// Send реализует notify.Sender: msg.To — ID комнаты. Тема, если есть, выводится жирной первой строкой.
+func (c *Client) Send(ctx context.Context, msg notify.Message) error {
+	ctx = delivery.WithTemplate(ctx, msg.Template)
+	options := MessageOptions{
+		RoomID:  msg.To,
+		Body:    msg.Text,
+		MsgType: MsgNotice,
+		UserID:  msg.UserID,
+		ID:      msg.ID,
+	}
+	if msg.Subject != "" {
+		options.Body = msg.Subject + "\n" + msg.Text
+		options.FormattedBody = "<strong>" + html.EscapeString(msg.Subject) + "</strong><br>" +
+			strings.ReplaceAll(html.EscapeString(msg.Text), "\n", "<br>")
+	}
+
+	_, err := c.sendText(ctx, options)
+	return err
+}
+
+// SendText отправляет одно сообщение в комнату.
+func (c *Client) SendText(options MessageOptions) (Response, error) {
+	return c.sendText(context.Background(), options)
+}
+
+func (c *Client) sendText(ctx context.Context, options MessageOptions) (Response, error) {
+	if !c.Enabled {
+		return Response{}, fmt.Errorf("функционал Matrix отключён: некорректная конфигурация")
+	}
+	if err := c.inflight.Acquire(); err != nil {
+		return Response{}, err
+	}
+	defer c.inflight.Release()
+
+	if options.ID == "" {
+		options.ID = uuid.New().String()
+	}
+	body := content{MsgType: options.MsgType, Body: options.Body}
+	if body.MsgType == "" {
+		body.MsgType = MsgText
+	}
+	if options.FormattedBody != "" {
+		body.Format = HTMLFormat
+		body.FormattedBody = options.FormattedBody
+	}
+
+	ctx, span := tracing.Start(ctx, Channel, options.RoomID)
+	started := time.Now()
+	res, err := c.put(ctx, options.RoomID, options.ID, body)
+	tracing.End(span, err)
+	c.logDelivery(ctx, options, started, err)
+	return res, err
+}
+
+// put отправляет событие m.room.message. txnID делает повтор запроса идемпотентным:
+// сервер не создаст второе событие с тем же txnId от того же токена.
+func (c *Client) put(ctx context.Context, roomID, txnID string, body content) (Response, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return Response{}, err
+	}
+
+	endpoint := fmt.Sprintf("%s/_matrix/client/v3/rooms/%s/send/m.room.message/%s",
+		c.homeserver, url.PathEscape(roomID), url.PathEscape(txnID))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, endpoint, bytes.NewReader(data))
+	if err != nil {
+		return Response{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+c.token)
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return Response{}, err
+	}
+	tracing.HTTPStatus(ctx, resp.StatusCode)
+	defer func(Body io.ReadCloser) {
+		_ = Body.Close()
+	}(resp.Body)
+
+	var res Response
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return Response{}, fmt.Errorf("некорректный формат JSON: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		errMsg := fmt.Sprintf("%s: %s", res.ErrCode, res.Error)
+		if res.RetryAfterMs > 0 {
+			errMsg = fmt.Sprintf("%s (повторите через %d мс)", errMsg, res.RetryAfterMs)
+		}
+		return res, fmt.Errorf("ошибка Matrix API: код %d: %s", resp.StatusCode, errMsg)
+	}
+	return res, nil
+}
+
+// logDelivery записывает попытку отправки в журнал доставки, если он подключён.
+func (c *Client) logDelivery(ctx context.Context, options MessageOptions, started time.Time, sendErr error) {
+	delivery.LogAttempt(ctx, c.deliveryLog, c.logger, delivery.Record{
+		ID:          options.ID,
+		Channel:     Channel,
+		UserID:      options.UserID,
+		Recipient:   options.RoomID,
+		MessageHash: delivery.Hash(options.Body),
+		CreatedAt:   started,
+	}, sendErr)
+}
@@ -0,0 +1,58 @@
+package matrix_test
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/epheer/notephee/config"
+	"github.com/epheer/notephee/matrix"
+	"github.com/epheer/notephee/notify"
+)
+
+func TestSendToRoom(t *testing.T) {
+	var (
+		path string
+		got  map[string]string
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut || r.Header.Get("Authorization") != "Bearer syt_test" {
+			t.Errorf("неожиданный запрос: %s %s", r.Method, r.Header.Get("Authorization"))
+		}
+		path = r.URL.EscapedPath()
+		_ = json.NewDecoder(r.Body).Decode(&got)
+		_, _ = w.Write([]byte(`{"event_id":"$ev1"}`))
+	}))
+	t.Cleanup(srv.Close)
+
+	c := matrix.NewClient(&config.Config{MatrixHomeserver: srv.URL + "/", MatrixToken: "syt_test"}, slog.Default())
+	err := c.Send(context.Background(), notify.Message{ID: "d1", To: "!room:example.org", Subject: "Инцидент", Text: "db <down>"})
+	if err != nil {
+		t.Fatalf("Ошибка Send: %v", err)
+	}
+
+	if !strings.HasSuffix(path, "/rooms/%21room:example.org/send/m.room.message/d1") {
+		t.Fatalf("неожиданный путь: %s", path)
+	}
+	if got["format"] != matrix.HTMLFormat || got["formatted_body"] != "<strong>Инцидент</strong><br>db &lt;down&gt;" {
+		t.Fatalf("неожиданное тело события: %v", got)
+	}
+}
+
+func TestRateLimitError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+		_, _ = w.Write([]byte(`{"errcode":"M_LIMIT_EXCEEDED","error":"Too many requests","retry_after_ms":2000}`))
+	}))
+	t.Cleanup(srv.Close)
+
+	c := matrix.NewClient(&config.Config{MatrixHomeserver: srv.URL, MatrixToken: "syt_test"}, slog.Default())
+	res, err := c.SendText(matrix.MessageOptions{RoomID: "!room:example.org", Body: "привет"})
+	if err == nil || res.ErrCode != "M_LIMIT_EXCEEDED" || res.RetryAfterMs != 2000 {
+		t.Fatalf("ожидалась ошибка лимита, получено %+v: %v", res, err)
+	}
+}
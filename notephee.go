@@ -1,21 +1,115 @@
 package notephee
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/epheer/notephee/alert"
 	"github.com/epheer/notephee/config"
+	"github.com/epheer/notephee/degrade"
 	"github.com/epheer/notephee/email"
+	"github.com/epheer/notephee/envclient"
+	"github.com/epheer/notephee/notify"
+	"github.com/epheer/notephee/queue"
 	"github.com/epheer/notephee/telegram"
-	"log/slog"
-	"time"
 )
 
-func Init(logger *slog.Logger) {
-	config.Get(logger)
-	tg := telegram.NewTgClient(config.Cfg, logger)
-	err := tg.CheckConnection()
-	if err != nil {
-		slog.Warn("Невозможно подключиться к Telegram. Проверьте валидность токена в NOTEPHEE_TELEGRAM_TOKEN.")
+// ErrNoChannels возвращается Init, если в конфигурации не настроен ни один канал.
+var ErrNoChannels = errors.New("не настроен ни один канал: задайте NOTEPHEE_TELEGRAM_* или NOTEPHEE_SMTP_*")
+
+// Notephee — клиенты каналов, созданные Init по конфигурации из окружения.
+type Notephee struct {
+	Config   *config.Config           // Конфигурация, по которой созданы клиенты
+	Telegram *telegram.TgClient       // Клиент Telegram; Enabled сообщает, настроен ли он
+	Email    *email.Client            // Клиент email; Enabled сообщает, настроен ли он
+	Bindings *telegram.BindingManager // Привязка чатов к пользователям; nil, если Telegram не настроен
+	Registry *notify.Registry         // Настроенные каналы для отправки по имени
+
+	mu       sync.Mutex
+	queues   []*queue.Dispatcher // Очереди для Health
+	degrades []*degrade.Sender   // Обёртки деградации для Health
+}
+
+// Init загружает конфигурацию из переменных окружения NOTEPHEE_*, создаёт клиенты Telegram и email
+// и проверяет подключение к ним.
+//
+// При некорректной конфигурации возвращается nil и *config.ValidationError. Если клиенты созданы,
+// но ни один канал не настроен или подключение к каналу не удалось, возвращаются и Notephee, и ошибка
+// со всеми неполадками: работающими каналами можно пользоваться.
+func Init(logger *slog.Logger) (*Notephee, error) {
+	cfg := config.Get(logger)
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	n := &Notephee{
+		Config:   cfg,
+		Telegram: envclient.Telegram(cfg, logger),
+		Email:    envclient.Email(cfg, logger),
+		Registry: notify.NewRegistry(),
+	}
+	if !n.Telegram.Enabled && !n.Email.Enabled {
+		return n, ErrNoChannels
+	}
+
+	if n.Telegram.Enabled {
+		n.Registry.Register(n.Telegram)
+		n.Bindings = n.Telegram.NewBindingManager(10*time.Minute, logger)
+	}
+	if n.Email.Enabled {
+		n.Registry.Register(n.Email)
+	}
+	// Оповещения настраиваются до проверок, чтобы администратор узнал о недоступном канале по другому
+	n.setupAlerts(logger)
+
+	ctx, cancel := context.WithTimeout(context.Background(), healthTimeout)
+	defer cancel()
+	var errs []error
+	if n.Telegram.Enabled {
+		if err := n.Telegram.CheckConnection(); err != nil {
+			errs = append(errs, fmt.Errorf("невозможно подключиться к Telegram, проверьте NOTEPHEE_TELEGRAM_TOKEN: %w", err))
+			alert.Alert(alert.Critical, "Невозможно подключиться к Telegram: проверьте токен бота в NOTEPHEE_TELEGRAM_TOKEN.")
+		}
+	}
+	if n.Email.Enabled {
+		if err := n.Email.CheckConnection(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("невозможно подключиться к SMTP-серверу: %w", err))
+			alert.Alert(alert.Critical, "Невозможно подключиться к SMTP-серверу: проверьте NOTEPHEE_SMTP_*.")
+		}
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return n, err
+	}
+	logger.Info("Notephee готов 🚀", "channels", n.Registry.Channels())
+	return n, nil
+}
+
+// Close ждёт завершения начатых отправок всех клиентов до отмены ctx.
+func (n *Notephee) Close(ctx context.Context) error {
+	return errors.Join(n.Telegram.Close(ctx), n.Email.Close(ctx))
+}
+
+// Alert сообщает администратору, заданному в NOTEPHEE_ALERT_TELEGRAM_CHAT и NOTEPHEE_ALERT_EMAIL,
+// о неполадке уровня level. До Init или без администратора ничего не делает.
+func Alert(level alert.Level, msg string) {
+	alert.Alert(level, msg)
+}
+
+// setupAlerts направляет оповещения модулей notephee администратору из конфигурации.
+func (n *Notephee) setupAlerts(logger *slog.Logger) {
+	var targets []alert.Target
+	if n.Config.AlertTelegramChat != "" && n.Telegram.Enabled {
+		targets = append(targets, alert.Target{Channel: telegram.Channel, To: n.Config.AlertTelegramChat})
+	}
+	if n.Config.AlertEmail != "" && n.Email.Enabled {
+		targets = append(targets, alert.Target{Channel: email.Channel, To: n.Config.AlertEmail})
+	}
+	if len(targets) > 0 {
+		alert.SetDefault(alert.New(n.Registry, logger, targets...))
 	}
-	tg.NewBindingManager(10*time.Minute, logger)
-	email.NewClient(config.Cfg, logger)
-	slog.Info("Notephee готов 🚀")
 }
@@ -0,0 +1,54 @@
+package notify
+
+// Capabilities — возможности канала, под которые рендереры и маршрутизация подстраивают содержимое.
+type Capabilities struct {
+	Channel     string `json:"channel"`     // Имя канала
+	Subject     bool   `json:"subject"`     // Тема выводится отдельно от текста
+	HTML        bool   `json:"html"`        // Текст может содержать разметку HTML
+	Markdown    bool   `json:"markdown"`    // Текст может содержать разметку Markdown
+	Attachments bool   `json:"attachments"` // Можно приложить файл
+	Buttons     bool   `json:"buttons"`     // Можно добавить кнопки
+	Media       bool   `json:"media"`       // Можно отправить изображение или видео со встроенным просмотром
+	MaxLength   int    `json:"max_length"`  // Предел длины текста в символах; 0 — без ограничения
+}
+
+// Describer — канал, который сообщает свои возможности.
+type Describer interface {
+	Capabilities() Capabilities
+}
+
+// Unwrapper — обёртка над каналом (дедупликация, сводки и т.д.), которая отдаёт обёрнутый канал.
+type Unwrapper interface {
+	Unwrap() Sender
+}
+
+// CapabilitiesOf возвращает возможности канала s, снимая обёртки через Unwrap.
+// Если ни s, ни обёрнутые им каналы не реализуют Describer, возвращает false.
+func CapabilitiesOf(s Sender) (Capabilities, bool) {
+	for s != nil {
+		if d, ok := s.(Describer); ok {
+			return d.Capabilities(), true
+		}
+		u, ok := s.(Unwrapper)
+		if !ok {
+			break
+		}
+		s = u.Unwrap()
+	}
+	return Capabilities{}, false
+}
+
+// Capabilities возвращает возможности зарегистрированных каналов по имени.
+// Для каналов, которые не описывают себя, возвращается только имя: обычный текст без ограничений.
+func (r *Registry) Capabilities() map[string]Capabilities {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	out := make(map[string]Capabilities, len(r.senders))
+	for name, s := range r.senders {
+		c, _ := CapabilitiesOf(s)
+		c.Channel = name
+		out[name] = c
+	}
+	return out
+}
@@ -0,0 +1,63 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrClosed возвращается при отправке через клиента, у которого уже вызван Close.
+var ErrClosed = errors.New("клиент закрыт")
+
+// Closer — канал или очередь, которые можно остановить без потери начатых отправок.
+type Closer interface {
+	// Close перестаёт принимать новые отправки и ждёт завершения начатых до истечения ctx.
+	Close(ctx context.Context) error
+}
+
+// InFlight учитывает выполняющиеся отправки клиента, чтобы при остановке дождаться их завершения.
+// Нулевое значение готово к использованию.
+type InFlight struct {
+	mu     sync.Mutex
+	closed bool
+	wg     sync.WaitGroup
+}
+
+// Acquire регистрирует начало отправки. После Close возвращает ErrClosed.
+// Каждому успешному Acquire должен соответствовать Release.
+func (f *InFlight) Acquire() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return ErrClosed
+	}
+	f.wg.Add(1)
+	return nil
+}
+
+// Release отмечает завершение отправки, начатой Acquire.
+func (f *InFlight) Release() {
+	f.wg.Done()
+}
+
+// Close перестаёт принимать новые отправки и ждёт завершения начатых.
+// Если ctx завершится раньше, возвращает ошибку контекста; начатые отправки при этом не прерываются.
+func (f *InFlight) Close(ctx context.Context) error {
+	f.mu.Lock()
+	f.closed = true
+	f.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		f.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("не дождались завершения отправок: %w", ctx.Err())
+	}
+}
@@ -0,0 +1,11 @@
+package notify
+
+import "context"
+
+// Limiter ограничивает частоту отправок клиента канала. *rate.Limiter из golang.org/x/time/rate
+// реализует его; если сервис запущен в нескольких экземплярах с одним ботом или почтовым ящиком,
+// можно подключить распределённый лимитер, например на Redis, чтобы экземпляры делили один бюджет.
+type Limiter interface {
+	// Wait блокируется, пока отправка не будет разрешена, или возвращает ошибку, например ctx.Err().
+	Wait(ctx context.Context) error
+}
@@ -0,0 +1,36 @@
+package notify
+
+import (
+	"fmt"
+	"time"
+)
+
+// LocalTime — время суток в часовом поясе получателя, например 09:00.
+type LocalTime struct {
+	Hour   int // Час, 0–23
+	Minute int // Минута, 0–59
+}
+
+// ParseLocalTime разбирает время суток в формате 15:04.
+func ParseLocalTime(s string) (LocalTime, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return LocalTime{}, fmt.Errorf("некорректное время суток %q: ожидается ЧЧ:ММ", s)
+	}
+	return LocalTime{Hour: t.Hour(), Minute: t.Minute()}, nil
+}
+
+// String возвращает время в формате 15:04.
+func (t LocalTime) String() string {
+	return fmt.Sprintf("%02d:%02d", t.Hour, t.Minute)
+}
+
+// Next возвращает ближайший момент не раньше from, когда в поясе loc наступает время t.
+func (t LocalTime) Next(from time.Time, loc *time.Location) time.Time {
+	local := from.In(loc)
+	at := time.Date(local.Year(), local.Month(), local.Day(), t.Hour, t.Minute, 0, 0, loc)
+	if at.Before(from) {
+		at = time.Date(local.Year(), local.Month(), local.Day()+1, t.Hour, t.Minute, 0, 0, loc)
+	}
+	return at
+}
@@ -0,0 +1,137 @@
+package notify
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// Severity — важность уведомления.
+type Severity string
+
+const (
+	SeverityInfo     Severity = "info"     // Для сведения
+	SeverityWarning  Severity = "warning"  // Требует внимания
+	SeverityCritical Severity = "critical" // Требует немедленных действий
+)
+
+// Label возвращает название важности для текста уведомления.
+func (s Severity) Label() string {
+	switch s {
+	case SeverityWarning:
+		return "Предупреждение"
+	case SeverityCritical:
+		return "Критично"
+	default:
+		return "Информация"
+	}
+}
+
+// Emoji возвращает значок важности для заголовка в мессенджерах.
+func (s Severity) Emoji() string {
+	switch s {
+	case SeverityWarning:
+		return "⚠️"
+	case SeverityCritical:
+		return "🚨"
+	default:
+		return "ℹ️"
+	}
+}
+
+// Action — ссылка-действие уведомления: кнопка в Telegram и Slack, ссылка в письме.
+type Action struct {
+	Label string `json:"label"` // Текст кнопки
+	URL   string `json:"url"`   // Адрес http или https
+}
+
+// Notification — структурированное уведомление, которое каждый канал отображает по-своему: Telegram —
+// текстом с кнопками, email — темой и HTML-письмом, Slack — блоками. Каналы без своего отображения
+// получают обычный текст из Text.
+//
+// Уведомление отправляется как notify.Message, полученное из Message: адрес получателя, категория
+// и остальные поля доставки задаются в нём.
+type Notification struct {
+	Title    string            `json:"title"`              // Заголовок
+	Body     string            `json:"body,omitempty"`     // Текст без разметки
+	Severity Severity          `json:"severity,omitempty"` // Важность; пустая равна SeverityInfo
+	Actions  []Action          `json:"actions,omitempty"`  // Ссылки-действия (необязательно)
+	Metadata map[string]string `json:"metadata,omitempty"` // Поля «название — значение»: номер заказа, сервис и т.д. (необязательно)
+}
+
+// Validate проверяет, что у уведомления есть заголовок или текст, важность известна,
+// а у действий есть текст и адрес http или https.
+func (n Notification) Validate() error {
+	var errs []error
+	if n.Title == "" && n.Body == "" {
+		errs = append(errs, errors.New("у уведомления нет ни заголовка, ни текста"))
+	}
+	switch n.Severity {
+	case "", SeverityInfo, SeverityWarning, SeverityCritical:
+	default:
+		errs = append(errs, fmt.Errorf("неизвестная важность уведомления: %q", n.Severity))
+	}
+	for i, a := range n.Actions {
+		if a.Label == "" {
+			errs = append(errs, fmt.Errorf("действие %d: пустой текст", i+1))
+		}
+		if u, err := url.Parse(a.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("действие %d: некорректный адрес %q", i+1, a.URL))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// MetadataKeys возвращает названия полей Metadata по алфавиту — в этом порядке их выводят каналы.
+func (n Notification) MetadataKeys() []string {
+	return slices.Sorted(maps.Keys(n.Metadata))
+}
+
+// Text возвращает уведомление обычным текстом: заголовок, текст, поля и ссылки действий.
+func (n Notification) Text() string {
+	var b strings.Builder
+	section := func() {
+		if b.Len() > 0 {
+			b.WriteString("\n\n")
+		}
+	}
+	if n.Title != "" {
+		b.WriteString(n.Title)
+	}
+	if n.Body != "" {
+		section()
+		b.WriteString(n.Body)
+	}
+	if len(n.Metadata) > 0 {
+		section()
+		for i, k := range n.MetadataKeys() {
+			if i > 0 {
+				b.WriteByte('\n')
+			}
+			fmt.Fprintf(&b, "%s: %s", k, n.Metadata[k])
+		}
+	}
+	if len(n.Actions) > 0 {
+		section()
+		for i, a := range n.Actions {
+			if i > 0 {
+				b.WriteByte('\n')
+			}
+			fmt.Fprintf(&b, "%s: %s", a.Label, a.URL)
+		}
+	}
+	return b.String()
+}
+
+// Message возвращает сообщение с уведомлением: каналы с отображением уведомлений берут его из
+// Message.Notification, остальные отправляют Subject и Text. Критичные уведомления получают PriorityHigh.
+func (n Notification) Message(to string) Message {
+	msg := Message{To: to, Subject: n.Title, Text: n.Text(), Notification: &n}
+	if n.Severity == SeverityCritical {
+		msg.Priority = PriorityHigh
+	}
+	return msg
+}
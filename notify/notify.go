@@ -0,0 +1,150 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Message — канально-нейтральное сообщение для одного получателя.
+type Message struct {
+	ID       string   // Идентификатор попытки в журнале доставки (генерируется, если пуст)
+	To       string   // Адрес получателя в канале: chatID, email и т.д.
+	UserID   string   // Внутренний идентификатор пользователя (необязательно)
+	Subject  string   // Тема (используется каналами, в которых она есть)
+	Text     string   // Текст сообщения
+	Campaign string   // Идентификатор рассылки (необязательно)
+	DedupKey string   // Ключ дедупликации: одинаковые ключи одному получателю схлопываются (необязательно)
+	Priority Priority // Приоритет; пустой равен PriorityNormal
+	Category string   // Категория уведомления: alerts, reports и т.д. (необязательно)
+	Identity string   // Личность отправителя: бот или адрес white-label-клиента (необязательно)
+	Template string   // Версия шаблона вида name@v3, из которой собрано сообщение (необязательно)
+
+	EnqueuedAt time.Time     // Время постановки в очередь; от него считается сквозная задержка (необязательно)
+	SLO        time.Duration // Допустимая задержка до приёма провайдером; дольше — сообщение опоздало (необязательно)
+
+	// Notification — структурированное уведомление, которое канал может отобразить вместо Subject и Text:
+	// с кнопками, HTML или блоками. Subject и Text остаются запасным вариантом для остальных каналов (необязательно).
+	Notification *Notification
+}
+
+// Priority — приоритет сообщения.
+type Priority string
+
+const (
+	PriorityLow    Priority = "low"    // Может быть отложено и объединено в сводку
+	PriorityNormal Priority = "normal" // Отправляется сразу
+	PriorityHigh   Priority = "high"   // Отправляется сразу, в обход сводок
+)
+
+// Progress — счётчики массовой рассылки на текущий момент.
+type Progress struct {
+	Total   int // Всего получателей (0 — неизвестно: получатели передаются потоком)
+	Sent    int // Успешно отправлено
+	Failed  int // Завершилось ошибкой
+	Skipped int // Пропущено без попытки отправки: некорректный адрес, список подавления
+}
+
+// Done возвращает число уже обработанных получателей.
+func (p Progress) Done() int {
+	return p.Sent + p.Failed + p.Skipped
+}
+
+// Sender — общий интерфейс отправки сообщений, который реализует каждый канал.
+type Sender interface {
+	// Channel возвращает имя канала (telegram, email и т.д.).
+	Channel() string
+	// Send отправляет одно сообщение.
+	Send(ctx context.Context, msg Message) error
+}
+
+// ErrUnknownChannel возвращается, если канал не зарегистрирован в Registry.
+var ErrUnknownChannel = errors.New("канал не зарегистрирован")
+
+// ErrUnknownIdentity возвращается, если для канала не зарегистрирована запрошенная личность отправителя.
+var ErrUnknownIdentity = errors.New("личность отправителя не зарегистрирована")
+
+// Registry хранит доступные каналы отправки по имени.
+type Registry struct {
+	mu         sync.RWMutex
+	senders    map[string]Sender
+	identities map[string]map[string]Sender // Канал → личность → канал этой личности
+	recipients RecipientStore               // Каталог получателей (необязательно)
+}
+
+// NewRegistry создаёт пустой реестр каналов.
+func NewRegistry() *Registry {
+	return &Registry{senders: make(map[string]Sender), identities: make(map[string]map[string]Sender)}
+}
+
+// Register добавляет канал в реестр, заменяя ранее зарегистрированный с тем же именем.
+func (r *Registry) Register(s Sender) {
+	r.mu.Lock()
+	r.senders[s.Channel()] = s
+	r.mu.Unlock()
+}
+
+// Get возвращает канал по имени.
+func (r *Registry) Get(channel string) (Sender, error) {
+	r.mu.RLock()
+	s, ok := r.senders[channel]
+	r.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%s: %w", channel, ErrUnknownChannel)
+	}
+	return s, nil
+}
+
+// RegisterIdentity добавляет канал личности отправителя identity, например клиента
+// с ботом white-label-клиента, заменяя ранее зарегистрированный для той же пары.
+func (r *Registry) RegisterIdentity(identity string, s Sender) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	byIdentity, ok := r.identities[s.Channel()]
+	if !ok {
+		byIdentity = make(map[string]Sender)
+		r.identities[s.Channel()] = byIdentity
+	}
+	byIdentity[identity] = s
+}
+
+// Identity возвращает канал личности identity. Пустая личность означает основной канал, как в Get.
+func (r *Registry) Identity(channel, identity string) (Sender, error) {
+	if identity == "" {
+		return r.Get(channel)
+	}
+
+	r.mu.RLock()
+	s, ok := r.identities[channel][identity]
+	r.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%s/%s: %w", channel, identity, ErrUnknownIdentity)
+	}
+	return s, nil
+}
+
+// Channels возвращает имена зарегистрированных каналов в алфавитном порядке.
+func (r *Registry) Channels() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	names := make([]string, 0, len(r.senders))
+	for name := range r.senders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Send отправляет сообщение через канал channel от личности msg.Identity.
+func (r *Registry) Send(ctx context.Context, channel string, msg Message) error {
+	s, err := r.Identity(channel, msg.Identity)
+	if err != nil {
+		return err
+	}
+	return s.Send(ctx, msg)
+}
@@ -0,0 +1,193 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrUnknownRecipient возвращается, если получателя нет в каталоге или каталог не подключён.
+var ErrUnknownRecipient = errors.New("получатель не найден")
+
+// ErrNoAddress возвращается, если у получателя нет адреса ни в одном подходящем канале.
+var ErrNoAddress = errors.New("у получателя нет адреса в канале")
+
+// ErrMuted возвращается, если получатель отключил уведомления категории сообщения.
+var ErrMuted = errors.New("получатель отключил уведомления этой категории")
+
+// ErrSuppressed возвращается каналом, если получатель в списке подавления: отписался, пожаловался,
+// адрес недоставляем или бот заблокирован.
+var ErrSuppressed = errors.New("получатель находится в списке подавления")
+
+// ErrInvalidAddress возвращается каналом для адреса, на который сообщение заведомо не дойдёт.
+var ErrInvalidAddress = errors.New("некорректный адрес получателя")
+
+// Recipient — получатель из каталога: пользователь приложения и его адреса во всех каналах.
+type Recipient struct {
+	ID          string            `json:"id"`                    // Внутренний идентификатор пользователя
+	Name        string            `json:"name,omitempty"`        // Отображаемое имя
+	Addresses   map[string]string `json:"addresses"`             // Канал → адрес: chatID, email и т.д.
+	Preferences Preferences       `json:"preferences,omitempty"` // Предпочтения доставки
+	Locale      string            `json:"locale,omitempty"`      // Язык уведомлений (BCP 47), например ru
+	Timezone    string            `json:"timezone,omitempty"`    // Часовой пояс IANA, например Europe/Moscow
+}
+
+// Preferences — предпочтения получателя по доставке.
+type Preferences struct {
+	Channels        []string `json:"channels,omitempty"`         // Каналы по убыванию предпочтения
+	MutedCategories []string `json:"muted_categories,omitempty"` // Отключённые категории уведомлений
+}
+
+// Address возвращает адрес получателя в канале channel.
+func (r Recipient) Address(channel string) (string, bool) {
+	addr, ok := r.Addresses[channel]
+	return addr, ok && addr != ""
+}
+
+// Muted сообщает, отключил ли получатель уведомления категории category. Пустая категория не отключается.
+func (r Recipient) Muted(category string) bool {
+	return category != "" && slices.Contains(r.Preferences.MutedCategories, category)
+}
+
+// Location возвращает часовой пояс получателя; UTC, если он не задан или неизвестен.
+func (r Recipient) Location() *time.Location {
+	if r.Timezone == "" {
+		return time.UTC
+	}
+	loc, err := time.LoadLocation(r.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// RecipientStore — каталог получателей. Реализации должны быть безопасны для конкурентного использования.
+type RecipientStore interface {
+	// Get возвращает получателя по идентификатору или ErrUnknownRecipient.
+	Get(ctx context.Context, id string) (Recipient, error)
+	// Put сохраняет получателя, заменяя прежнюю запись с тем же ID.
+	Put(ctx context.Context, r Recipient) error
+	// Delete удаляет получателя. Отсутствующий получатель не считается ошибкой.
+	Delete(ctx context.Context, id string) error
+	// List возвращает всех получателей в порядке ID.
+	List(ctx context.Context) ([]Recipient, error)
+}
+
+// MemoryRecipientStore — RecipientStore в памяти процесса.
+type MemoryRecipientStore struct {
+	mu         sync.RWMutex
+	recipients map[string]Recipient
+}
+
+// NewMemoryRecipientStore создаёт пустой каталог получателей в памяти.
+func NewMemoryRecipientStore() *MemoryRecipientStore {
+	return &MemoryRecipientStore{recipients: make(map[string]Recipient)}
+}
+
+// Get возвращает получателя.
+func (s *MemoryRecipientStore) Get(_ context.Context, id string) (Recipient, error) {
+	s.mu.RLock()
+	r, ok := s.recipients[id]
+	s.mu.RUnlock()
+	if !ok {
+		return Recipient{}, fmt.Errorf("%s: %w", id, ErrUnknownRecipient)
+	}
+	return r, nil
+}
+
+// Put сохраняет получателя.
+func (s *MemoryRecipientStore) Put(_ context.Context, r Recipient) error {
+	if r.ID == "" {
+		return fmt.Errorf("у получателя должен быть ID")
+	}
+	s.mu.Lock()
+	s.recipients[r.ID] = r
+	s.mu.Unlock()
+	return nil
+}
+
+// Delete удаляет получателя.
+func (s *MemoryRecipientStore) Delete(_ context.Context, id string) error {
+	s.mu.Lock()
+	delete(s.recipients, id)
+	s.mu.Unlock()
+	return nil
+}
+
+// List возвращает всех получателей.
+func (s *MemoryRecipientStore) List(_ context.Context) ([]Recipient, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	out := make([]Recipient, 0, len(s.recipients))
+	for _, r := range s.recipients {
+		out = append(out, r)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out, nil
+}
+
+// SetRecipients подключает каталог получателей, по которому Resolve и SendToUser находят адрес
+// пользователя в канале. Вызывается при настройке, до отправки.
+func (r *Registry) SetRecipients(store RecipientStore) {
+	r.mu.Lock()
+	r.recipients = store
+	r.mu.Unlock()
+}
+
+// Resolve находит канал и адрес пользователя userID для сообщения категории category.
+//
+// Если channel задан, возвращается адрес в нём. Иначе выбирается первый зарегистрированный канал
+// из Preferences.Channels, в котором у получателя есть адрес, а без предпочтений — первый такой канал
+// по алфавиту.
+func (r *Registry) Resolve(ctx context.Context, userID, channel, category string) (string, string, error) {
+	r.mu.RLock()
+	store := r.recipients
+	r.mu.RUnlock()
+	if store == nil {
+		return "", "", fmt.Errorf("каталог получателей не подключён: %w", ErrUnknownRecipient)
+	}
+
+	rcpt, err := store.Get(ctx, userID)
+	if err != nil {
+		return "", "", err
+	}
+	if rcpt.Muted(category) {
+		return "", "", fmt.Errorf("%s/%s: %w", userID, category, ErrMuted)
+	}
+
+	if channel != "" {
+		addr, ok := rcpt.Address(channel)
+		if !ok {
+			return "", "", fmt.Errorf("%s/%s: %w", userID, channel, ErrNoAddress)
+		}
+		return channel, addr, nil
+	}
+
+	candidates := slices.Concat(rcpt.Preferences.Channels, r.Channels())
+	for _, ch := range candidates {
+		if _, err := r.Get(ch); err != nil {
+			continue
+		}
+		if addr, ok := rcpt.Address(ch); ok {
+			return ch, addr, nil
+		}
+	}
+	return "", "", fmt.Errorf("%s: %w", userID, ErrNoAddress)
+}
+
+// SendToUser отправляет сообщение пользователю userID по адресу из каталога получателей.
+// channel может быть пустым — тогда канал выбирается по предпочтениям получателя, как в Resolve.
+func (r *Registry) SendToUser(ctx context.Context, userID, channel string, msg Message) error {
+	channel, to, err := r.Resolve(ctx, userID, channel, msg.Category)
+	if err != nil {
+		return err
+	}
+	msg.To = to
+	msg.UserID = userID
+	return r.Send(ctx, channel, msg)
+}
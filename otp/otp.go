@@ -0,0 +1,314 @@
+// Package otp выпускает и проверяет одноразовые коды (OTP): для входа, подтверждения операции
+// или телефона. Код отправляется через любой канал notify.Registry — Telegram, email, SMS.
+package otp
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/epheer/notephee/notify"
+)
+
+// Алфавиты кодов.
+const (
+	Numeric = "0123456789"
+	// Alphanumeric не содержит похожих символов (0/O, 1/I); код сравнивается без учёта регистра.
+	Alphanumeric = "23456789ABCDEFGHJKLMNPQRSTUVWXYZ"
+)
+
+// Значения Options по умолчанию.
+const (
+	DefaultLength      = 6
+	DefaultTTL         = 5 * time.Minute
+	DefaultMaxAttempts = 5
+	DefaultText        = "Ваш код: {{.Code}}. Он действует {{.TTL}}. Никому его не сообщайте."
+)
+
+var (
+	// ErrNotFound возвращается, если кода нет: он не выпускался, уже использован или истёк.
+	ErrNotFound = errors.New("код не найден или истёк")
+	// ErrInvalid возвращается, если предъявлен неверный код.
+	ErrInvalid = errors.New("неверный код")
+	// ErrTooManyAttempts возвращается, если исчерпаны попытки ввода; код после этого недействителен.
+	ErrTooManyAttempts = errors.New("превышено число попыток ввода кода")
+)
+
+// Entry — выпущенный код в хранилище. Сам код не хранится, только его хэш.
+type Entry struct {
+	Hash        []byte    // HMAC-SHA256 ключа и кода на секрете Options.Key
+	Channel     string    // Канал, через который отправлен код
+	To          string    // Адрес получателя в канале
+	Attempts    int       // Число попыток проверки, включая текущую
+	MaxAttempts int       // Допустимое число попыток
+	ExpiresAt   time.Time // Время окончания действия
+}
+
+// Store хранит выпущенные коды по ключу (например, "login:<userID>").
+// Реализации должны быть безопасны для конкурентного использования, а Attempt — атомарен,
+// чтобы параллельные проверки не обходили лимит попыток.
+type Store interface {
+	// Save сохраняет код под ключом key, заменяя прежний.
+	Save(ctx context.Context, key string, e Entry) error
+	// Attempt увеличивает счётчик попыток и возвращает запись после увеличения
+	// или ErrNotFound, если кода нет или он истёк.
+	Attempt(ctx context.Context, key string) (Entry, error)
+	// Delete удаляет код. Отсутствующий код не считается ошибкой.
+	Delete(ctx context.Context, key string) error
+}
+
+// MemoryStore — Store в памяти процесса.
+type MemoryStore struct {
+	mu      sync.Mutex
+	entries map[string]Entry
+	purge   time.Time // Время следующей очистки истёкших кодов
+}
+
+// NewMemoryStore создаёт пустое хранилище кодов в памяти.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{entries: make(map[string]Entry)}
+}
+
+// Save сохраняет код.
+func (s *MemoryStore) Save(_ context.Context, key string, e Entry) error {
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if now.After(s.purge) {
+		for k, old := range s.entries {
+			if now.After(old.ExpiresAt) {
+				delete(s.entries, k)
+			}
+		}
+		s.purge = now.Add(time.Minute)
+	}
+	s.entries[key] = e
+	return nil
+}
+
+// Attempt увеличивает счётчик попыток.
+func (s *MemoryStore) Attempt(_ context.Context, key string) (Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.entries[key]
+	if !ok || time.Now().After(e.ExpiresAt) {
+		delete(s.entries, key)
+		return Entry{}, ErrNotFound
+	}
+	e.Attempts++
+	s.entries[key] = e
+	return e, nil
+}
+
+// Delete удаляет код.
+func (s *MemoryStore) Delete(_ context.Context, key string) error {
+	s.mu.Lock()
+	delete(s.entries, key)
+	s.mu.Unlock()
+	return nil
+}
+
+// Options — параметры выпускаемых кодов.
+type Options struct {
+	Length      int           // Длина кода (0 — DefaultLength)
+	Alphabet    string        // Символы кода (пусто — Numeric)
+	TTL         time.Duration // Срок действия (0 — DefaultTTL)
+	MaxAttempts int           // Число попыток ввода (0 — DefaultMaxAttempts)
+	Subject     string        // Шаблон темы для каналов с темой (text/template, необязательно)
+	Text        string        // Шаблон текста (text/template, пусто — DefaultText)
+
+	// Key — секрет HMAC для хэшей кодов, не короче 32 байт. Без него ключ генерируется при запуске
+	// и хэши не переживают перезапуск, поэтому с общим хранилищем Key обязателен.
+	Key []byte
+}
+
+// Data — данные, доступные шаблонам сообщения с кодом.
+type Data struct {
+	Code string        // Код
+	TTL  time.Duration // Срок действия
+}
+
+// Manager выпускает, отправляет и проверяет одноразовые коды.
+type Manager struct {
+	registry *notify.Registry
+	store    Store
+	opts     Options
+	subject  *template.Template
+	text     *template.Template
+	logger   *slog.Logger
+}
+
+// New создаёт Manager, отправляющий коды через каналы registry.
+func New(registry *notify.Registry, opts Options, logger *slog.Logger) (*Manager, error) {
+	if opts.Length <= 0 {
+		opts.Length = DefaultLength
+	}
+	if opts.Alphabet == "" {
+		opts.Alphabet = Numeric
+	}
+	if len(opts.Alphabet) < 2 {
+		return nil, fmt.Errorf("алфавит кода должен содержать хотя бы два символа")
+	}
+	if opts.TTL <= 0 {
+		opts.TTL = DefaultTTL
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = DefaultMaxAttempts
+	}
+	if opts.Text == "" {
+		opts.Text = DefaultText
+	}
+	switch {
+	case len(opts.Key) == 0:
+		opts.Key = make([]byte, 32)
+		if _, err := rand.Read(opts.Key); err != nil {
+			return nil, fmt.Errorf("не удалось сгенерировать ключ кодов: %w", err)
+		}
+	case len(opts.Key) < 32:
+		return nil, fmt.Errorf("ключ кодов должен быть не короче 32 байт")
+	}
+
+	subject, err := template.New("subject").Parse(opts.Subject)
+	if err != nil {
+		return nil, fmt.Errorf("некорректный шаблон темы: %w", err)
+	}
+	text, err := template.New("text").Parse(opts.Text)
+	if err != nil {
+		return nil, fmt.Errorf("некорректный шаблон текста: %w", err)
+	}
+
+	return &Manager{
+		registry: registry,
+		store:    NewMemoryStore(),
+		opts:     opts,
+		subject:  subject,
+		text:     text,
+		logger:   logger,
+	}, nil
+}
+
+// SetStore заменяет хранилище кодов (по умолчанию — в памяти процесса).
+func (m *Manager) SetStore(store Store) {
+	m.store = store
+}
+
+// Issue выпускает и сохраняет код под ключом key, не отправляя его, и возвращает код — для модулей,
+// которые доставляют код своим сообщением, например письмом подтверждения адреса. channel и to
+// сохраняются в записи. Повторный вызов с тем же ключом заменяет прежний код и сбрасывает счётчик попыток.
+func (m *Manager) Issue(ctx context.Context, key, channel, to string) (string, error) {
+	code, err := m.generate()
+	if err != nil {
+		return "", err
+	}
+	entry := Entry{
+		Hash:        m.hash(key, code),
+		Channel:     channel,
+		To:          to,
+		MaxAttempts: m.opts.MaxAttempts,
+		ExpiresAt:   time.Now().Add(m.opts.TTL),
+	}
+	if err := m.store.Save(ctx, key, entry); err != nil {
+		return "", fmt.Errorf("не удалось сохранить код: %w", err)
+	}
+	return code, nil
+}
+
+// Send выпускает код под ключом key и отправляет его получателю to через канал channel.
+// Повторный вызов с тем же ключом заменяет прежний код и сбрасывает счётчик попыток.
+func (m *Manager) Send(ctx context.Context, key, channel, to string) error {
+	code, err := m.Issue(ctx, key, channel, to)
+	if err != nil {
+		return err
+	}
+
+	if err := m.deliver(ctx, channel, to, code); err != nil {
+		// Недоставленный код не должен оставаться действующим
+		if delErr := m.store.Delete(context.WithoutCancel(ctx), key); delErr != nil {
+			m.logger.Warn("не удалось удалить неотправленный код", "key", key, "error", delErr)
+		}
+		return err
+	}
+	m.logger.Info("одноразовый код отправлен", "key", key, "channel", channel)
+	return nil
+}
+
+// deliver отправляет сообщение с кодом по шаблонам Options.
+func (m *Manager) deliver(ctx context.Context, channel, to, code string) error {
+	data := Data{Code: code, TTL: m.opts.TTL}
+	var subject, text bytes.Buffer
+	if err := m.subject.Execute(&subject, data); err != nil {
+		return fmt.Errorf("ошибка шаблона темы: %w", err)
+	}
+	if err := m.text.Execute(&text, data); err != nil {
+		return fmt.Errorf("ошибка шаблона текста: %w", err)
+	}
+	msg := notify.Message{To: to, Subject: subject.String(), Text: text.String(), Priority: notify.PriorityHigh}
+	return m.registry.Send(ctx, channel, msg)
+}
+
+// Validate проверяет код, предъявленный для ключа key. Верный код действует один раз.
+// После MaxAttempts неверных попыток код удаляется и возвращается ErrTooManyAttempts.
+func (m *Manager) Validate(ctx context.Context, key, code string) error {
+	e, err := m.store.Attempt(ctx, key)
+	if err != nil {
+		return err
+	}
+	if e.Attempts > e.MaxAttempts {
+		_ = m.store.Delete(ctx, key)
+		return ErrTooManyAttempts
+	}
+
+	if subtle.ConstantTimeCompare(e.Hash, m.hash(key, m.normalize(code))) != 1 {
+		if e.Attempts == e.MaxAttempts {
+			_ = m.store.Delete(ctx, key)
+			return ErrTooManyAttempts
+		}
+		return ErrInvalid
+	}
+	return m.store.Delete(ctx, key)
+}
+
+// generate возвращает случайный код из алфавита.
+func (m *Manager) generate() (string, error) {
+	size := big.NewInt(int64(len(m.opts.Alphabet)))
+	code := make([]byte, m.opts.Length)
+	for i := range code {
+		n, err := rand.Int(rand.Reader, size)
+		if err != nil {
+			return "", fmt.Errorf("не удалось сгенерировать код: %w", err)
+		}
+		code[i] = m.opts.Alphabet[n.Int64()]
+	}
+	return string(code), nil
+}
+
+// normalize убирает пробелы вокруг кода и приводит его к регистру алфавита.
+func (m *Manager) normalize(code string) string {
+	code = strings.TrimSpace(code)
+	if m.opts.Alphabet == Alphanumeric {
+		code = strings.ToUpper(code)
+	}
+	return code
+}
+
+// hash возвращает HMAC кода, привязанный к ключу: одинаковые коды разных ключей не совпадают,
+// а по хэшу из хранилища без секрета нельзя перебрать короткий код.
+func (m *Manager) hash(key, code string) []byte {
+	mac := hmac.New(sha256.New, m.opts.Key)
+	mac.Write([]byte(key + "\x00" + code))
+	return mac.Sum(nil)
+}
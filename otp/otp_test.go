@@ -0,0 +1,126 @@
+package otp_test
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/epheer/notephee/notify"
+	"github.com/epheer/notephee/otp"
+)
+
+type captureSender struct {
+	sent []notify.Message
+	err  error
+}
+
+func (s *captureSender) Channel() string { return "sms" }
+
+func (s *captureSender) Send(_ context.Context, msg notify.Message) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.sent = append(s.sent, msg)
+	return nil
+}
+
+func newManager(t *testing.T, opts otp.Options) (*otp.Manager, *captureSender) {
+	t.Helper()
+	sender := &captureSender{}
+	registry := notify.NewRegistry()
+	registry.Register(sender)
+	m, err := otp.New(registry, opts, slog.Default())
+	if err != nil {
+		t.Fatalf("Ошибка New: %v", err)
+	}
+	return m, sender
+}
+
+func TestSendAndValidate(t *testing.T) {
+	m, sender := newManager(t, otp.Options{Text: "{{.Code}}"})
+	ctx := context.Background()
+
+	if err := m.Send(ctx, "login:u1", "sms", "+79990000000"); err != nil {
+		t.Fatalf("Ошибка Send: %v", err)
+	}
+	if len(sender.sent) != 1 || sender.sent[0].To != "+79990000000" || sender.sent[0].Priority != notify.PriorityHigh {
+		t.Fatalf("неожиданное сообщение: %+v", sender.sent)
+	}
+	code := sender.sent[0].Text
+	if !regexp.MustCompile(`^\d{6}$`).MatchString(code) {
+		t.Fatalf("ожидался шестизначный код, получено %q", code)
+	}
+
+	if err := m.Validate(ctx, "login:u2", code); !errors.Is(err, otp.ErrNotFound) {
+		t.Fatalf("код другого ключа не должен подходить, получено %v", err)
+	}
+	if err := m.Validate(ctx, "login:u1", code); err != nil {
+		t.Fatalf("Ошибка Validate: %v", err)
+	}
+	if err := m.Validate(ctx, "login:u1", code); !errors.Is(err, otp.ErrNotFound) {
+		t.Fatalf("код должен действовать один раз, получено %v", err)
+	}
+}
+
+func TestAttemptLimit(t *testing.T) {
+	m, sender := newManager(t, otp.Options{Alphabet: otp.Alphanumeric, Length: 8, MaxAttempts: 2, Text: "{{.Code}}"})
+	ctx := context.Background()
+
+	_ = m.Send(ctx, "k", "sms", "+7")
+	code := sender.sent[0].Text
+	if err := m.Validate(ctx, "k", "wrong"); !errors.Is(err, otp.ErrInvalid) {
+		t.Fatalf("ожидалась ErrInvalid, получено %v", err)
+	}
+	if err := m.Validate(ctx, "k", "wrong"); !errors.Is(err, otp.ErrTooManyAttempts) {
+		t.Fatalf("ожидалась ErrTooManyAttempts, получено %v", err)
+	}
+	if err := m.Validate(ctx, "k", code); !errors.Is(err, otp.ErrNotFound) {
+		t.Fatalf("после исчерпания попыток код недействителен, получено %v", err)
+	}
+
+	// Буквенно-цифровой код сравнивается без учёта регистра
+	_ = m.Send(ctx, "k", "sms", "+7")
+	if err := m.Validate(ctx, "k", strings.ToLower(sender.sent[1].Text)); err != nil {
+		t.Fatalf("Ошибка Validate: %v", err)
+	}
+
+	// Неотправленный код не остаётся действующим
+	sender.err = errors.New("провайдер недоступен")
+	if err := m.Send(ctx, "k", "sms", "+7"); err == nil {
+		t.Fatal("ожидалась ошибка отправки")
+	}
+	if err := m.Validate(ctx, "k", "anything"); !errors.Is(err, otp.ErrNotFound) {
+		t.Fatalf("ожидалась ErrNotFound, получено %v", err)
+	}
+}
+
+func TestSharedKey(t *testing.T) {
+	key := []byte(strings.Repeat("k", 32))
+	store := otp.NewMemoryStore()
+	issuer, sender := newManager(t, otp.Options{Key: key, Text: "{{.Code}}"})
+	issuer.SetStore(store)
+	ctx := context.Background()
+
+	// Другой экземпляр с тем же секретом и общим хранилищем принимает код
+	checker, _ := newManager(t, otp.Options{Key: key})
+	checker.SetStore(store)
+	_ = issuer.Send(ctx, "k", "sms", "+7")
+	if err := checker.Validate(ctx, "k", sender.sent[0].Text); err != nil {
+		t.Fatalf("Ошибка Validate: %v", err)
+	}
+
+	// С другим секретом хэш не совпадает
+	other, _ := newManager(t, otp.Options{Key: []byte(strings.Repeat("x", 32))})
+	other.SetStore(store)
+	_ = issuer.Send(ctx, "k", "sms", "+7")
+	if err := other.Validate(ctx, "k", sender.sent[1].Text); !errors.Is(err, otp.ErrInvalid) {
+		t.Fatalf("ожидалась ErrInvalid для другого секрета, получено %v", err)
+	}
+
+	if _, err := otp.New(notify.NewRegistry(), otp.Options{Key: []byte("short")}, slog.Default()); err == nil {
+		t.Fatal("короткий ключ должен отклоняться")
+	}
+}
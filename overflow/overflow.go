@@ -0,0 +1,285 @@
+// Package overflow подгоняет слишком длинные сообщения под предел длины канала.
+//
+// Вместо ошибки провайдера («message is too long») к сообщению применяется стратегия,
+// выбранная по категории уведомления: обрезать со ссылкой «подробнее», разбить на части
+// или отправить полный текст файлом.
+package overflow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync/atomic"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/epheer/notephee/attachment"
+	"github.com/epheer/notephee/notify"
+)
+
+// Strategy — способ уложить длинное сообщение в предел канала.
+type Strategy string
+
+const (
+	Truncate Strategy = "truncate" // Обрезать и добавить ссылку на полный текст
+	Split    Strategy = "split"    // Отправить несколькими сообщениями
+	Attach   Strategy = "attach"   // Отправить полный текст файлом, а начало — подписью
+)
+
+// ParseStrategy разбирает имя стратегии. Пустая строка даёт пустую стратегию (по умолчанию).
+func ParseStrategy(s string) (Strategy, error) {
+	switch st := Strategy(strings.ToLower(strings.TrimSpace(s))); st {
+	case "", Truncate, Split, Attach:
+		return st, nil
+	default:
+		return "", fmt.Errorf("неизвестная стратегия длинных сообщений: %q", s)
+	}
+}
+
+// ParseCategories разбирает стратегии по категориям в формате «alerts=split,reports=attach».
+func ParseCategories(s string) (map[string]Strategy, error) {
+	out := make(map[string]Strategy)
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(pair, "=")
+		if !ok || strings.TrimSpace(name) == "" {
+			return nil, fmt.Errorf("некорректная пара категории %q: ожидается категория=стратегия", pair)
+		}
+		st, err := ParseStrategy(value)
+		if err != nil {
+			return nil, err
+		}
+		out[strings.TrimSpace(name)] = st
+	}
+	return out, nil
+}
+
+// Policy — выбор стратегии по категории. Нулевые значения заменяются значениями по умолчанию.
+type Policy struct {
+	Default    Strategy            // Стратегия для категорий без своей; по умолчанию Truncate
+	Categories map[string]Strategy // Стратегии по notify.Message.Category
+
+	// MoreURL возвращает ссылку на полный текст для Truncate, например страницу уведомления
+	// в веб-интерфейсе (необязательно). Пустая ссылка не добавляется.
+	MoreURL func(msg notify.Message) string
+	// MoreText — подпись перед ссылкой; по умолчанию «Подробнее: ».
+	MoreText string
+	// MaxParts — предел частей для Split, после которого остаток обрезается; по умолчанию 10.
+	MaxParts int
+}
+
+func (p *Policy) defaults() {
+	if p.Default == "" {
+		p.Default = Truncate
+	}
+	if p.MoreText == "" {
+		p.MoreText = "Подробнее: "
+	}
+	if p.MaxParts <= 0 {
+		p.MaxParts = 10
+	}
+}
+
+// Strategy возвращает стратегию для категории.
+func (p Policy) Strategy(category string) Strategy {
+	if st, ok := p.Categories[category]; ok && st != "" {
+		return st
+	}
+	return p.Default
+}
+
+// MoreURLTemplate возвращает функцию для Policy.MoreURL, которая подставляет ID сообщения
+// вместо {id} в шаблон tmpl. Пустой шаблон даёт nil.
+func MoreURLTemplate(tmpl string) func(notify.Message) string {
+	if tmpl == "" {
+		return nil
+	}
+	return func(msg notify.Message) string {
+		if msg.ID == "" && strings.Contains(tmpl, "{id}") {
+			return ""
+		}
+		return strings.ReplaceAll(tmpl, "{id}", msg.ID)
+	}
+}
+
+// FileSender — канал, который умеет отправить файл с подписью msg.Text. Нужен для стратегии Attach;
+// без него Attach заменяется на Truncate.
+type FileSender interface {
+	SendFile(ctx context.Context, msg notify.Message, file attachment.File) error
+}
+
+// Sender — обёртка над notify.Sender, применяющая стратегию к сообщениям длиннее предела канала.
+//
+// Предел берётся из notify.CapabilitiesOf; каналы без предела обёртка пропускает без изменений.
+type Sender struct {
+	next   notify.Sender          // Обёрнутый канал
+	limit  int                    // Предел длины текста в символах; 0 — без ограничения
+	title  bool                   // Канал выводит тему отдельно от текста
+	policy atomic.Pointer[Policy] // Выбор стратегии; заменяется целиком в SetPolicy
+	logger *slog.Logger           // Логгер
+}
+
+// Wrap оборачивает канал next. Оборачивать нужно клиент канала до повторов (redelivery),
+// чтобы повтор отправлял части с теми же ID.
+func Wrap(next notify.Sender, policy Policy, logger *slog.Logger) *Sender {
+	caps, _ := notify.CapabilitiesOf(next)
+	s := &Sender{next: next, limit: caps.MaxLength, title: caps.Subject, logger: logger}
+	s.SetPolicy(policy)
+	return s
+}
+
+// SetPolicy заменяет политику во время работы. Начатые отправки доделываются по прежней политике.
+func (s *Sender) SetPolicy(policy Policy) {
+	policy.defaults()
+	s.policy.Store(&policy)
+}
+
+// Channel возвращает имя обёрнутого канала.
+func (s *Sender) Channel() string {
+	return s.next.Channel()
+}
+
+// Unwrap возвращает обёрнутый канал.
+func (s *Sender) Unwrap() notify.Sender {
+	return s.next
+}
+
+// Send отправляет сообщение, при необходимости применяя стратегию его категории.
+func (s *Sender) Send(ctx context.Context, msg notify.Message) error {
+	limit := s.textLimit(msg)
+	if limit <= 0 || utf8.RuneCountInString(msg.Text) <= limit {
+		return s.next.Send(ctx, msg)
+	}
+
+	policy := s.policy.Load()
+	st := policy.Strategy(msg.Category)
+	s.logger.Debug("сообщение длиннее предела канала", "channel", s.next.Channel(), "to", msg.To,
+		"length", utf8.RuneCountInString(msg.Text), "limit", limit, "strategy", st)
+
+	switch st {
+	case Split:
+		return s.split(ctx, policy, msg, limit)
+	case Attach:
+		if fs, ok := fileSender(s.next); ok {
+			return s.attach(ctx, fs, msg, limit)
+		}
+		s.logger.Warn("канал не умеет отправлять файлы, сообщение обрезано", "channel", s.next.Channel())
+	}
+	msg.Text = truncate(policy, msg, limit)
+	return s.next.Send(ctx, msg)
+}
+
+// textLimit возвращает предел для текста сообщения. Каналы без отдельной темы (VK, Viber)
+// выводят её перед текстом через пустую строку, и она занимает часть предела.
+func (s *Sender) textLimit(msg notify.Message) int {
+	if s.limit <= 0 || s.title || msg.Subject == "" {
+		return s.limit
+	}
+	return max(s.limit-utf8.RuneCountInString(msg.Subject)-2, 1)
+}
+
+// truncate обрезает текст под limit и добавляет ссылку на полный текст, если она есть.
+func truncate(policy *Policy, msg notify.Message, limit int) string {
+	suffix := "…"
+	if policy.MoreURL != nil {
+		if u := policy.MoreURL(msg); u != "" {
+			suffix = "…\n\n" + policy.MoreText + u
+		}
+	}
+	keep := limit - utf8.RuneCountInString(suffix)
+	if keep <= 0 {
+		return cut(msg.Text, limit)
+	}
+	return strings.TrimRightFunc(cut(msg.Text, keep), unicode.IsSpace) + suffix
+}
+
+// split отправляет текст частями. Тема остаётся только у первой части. Первая часть уходит с ID
+// сообщения, чтобы ID, возвращённый вызывающему, нашёлся в журнале доставки, а ID остальных частей
+// выводятся из него (<id>-2, <id>-3…), чтобы повтор не создал дублей в каналах с идемпотентной отправкой.
+func (s *Sender) split(ctx context.Context, policy *Policy, msg notify.Message, limit int) error {
+	parts := chunks(msg.Text, limit)
+	if len(parts) > policy.MaxParts {
+		tail := strings.Join(parts[policy.MaxParts-1:], "")
+		parts = append(parts[:policy.MaxParts-1], truncate(policy, notify.Message{ID: msg.ID, Text: tail}, limit))
+	}
+
+	var errs []error
+	for i, text := range parts {
+		part := msg
+		part.Text = text
+		if i > 0 {
+			part.Subject = ""
+		}
+		if i > 0 && msg.ID != "" {
+			part.ID = fmt.Sprintf("%s-%d", msg.ID, i+1)
+		}
+		if err := s.next.Send(ctx, part); err != nil {
+			errs = append(errs, fmt.Errorf("часть %d из %d: %w", i+1, len(parts), err))
+			if ctx.Err() != nil {
+				break
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// attach отправляет полный текст файлом, а его начало — подписью.
+func (s *Sender) attach(ctx context.Context, fs FileSender, msg notify.Message, limit int) error {
+	file := attachment.File{Name: "message.txt", ContentType: "text/plain; charset=utf-8", Data: []byte(msg.Text)}
+	msg.Text = strings.TrimRightFunc(cut(msg.Text, limit-1), unicode.IsSpace) + "…"
+	return fs.SendFile(ctx, msg, file)
+}
+
+// fileSender ищет FileSender среди s и обёрнутых им каналов.
+func fileSender(s notify.Sender) (FileSender, bool) {
+	for s != nil {
+		if fs, ok := s.(FileSender); ok {
+			return fs, true
+		}
+		u, ok := s.(notify.Unwrapper)
+		if !ok {
+			break
+		}
+		s = u.Unwrap()
+	}
+	return nil, false
+}
+
+// cut возвращает первые n символов s.
+func cut(s string, n int) string {
+	i := 0
+	for pos := range s {
+		if i == n {
+			return s[:pos]
+		}
+		i++
+	}
+	return s
+}
+
+// chunks делит текст на части не длиннее limit символов, по возможности по границе абзаца,
+// строки или слова в последней трети части.
+func chunks(s string, limit int) []string {
+	var out []string
+	for utf8.RuneCountInString(s) > limit {
+		head := cut(s, limit)
+		at := len(head)
+		for _, sep := range []string{"\n\n", "\n", " "} {
+			if i := strings.LastIndex(head, sep); i > 0 && utf8.RuneCountInString(head[:i]) >= limit*2/3 {
+				at = i + len(sep)
+				break
+			}
+		}
+		out = append(out, strings.TrimRightFunc(s[:at], unicode.IsSpace))
+		s = strings.TrimLeftFunc(s[at:], unicode.IsSpace)
+	}
+	if s = strings.TrimRightFunc(s, unicode.IsSpace); s != "" {
+		out = append(out, s)
+	}
+	return out
+}
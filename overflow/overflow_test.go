@@ -0,0 +1,139 @@
+package overflow_test
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/epheer/notephee/attachment"
+	"github.com/epheer/notephee/notify"
+	"github.com/epheer/notephee/overflow"
+)
+
+type limitedSender struct {
+	limit int
+	sent  []notify.Message
+	files []attachment.File
+}
+
+func (s *limitedSender) Channel() string { return "fake" }
+
+func (s *limitedSender) Capabilities() notify.Capabilities {
+	return notify.Capabilities{Channel: "fake", MaxLength: s.limit}
+}
+
+func (s *limitedSender) Send(_ context.Context, msg notify.Message) error {
+	s.sent = append(s.sent, msg)
+	return nil
+}
+
+type fileSender struct {
+	limitedSender
+}
+
+func (s *fileSender) SendFile(_ context.Context, msg notify.Message, file attachment.File) error {
+	s.sent = append(s.sent, msg)
+	s.files = append(s.files, file)
+	return nil
+}
+
+func TestTruncateAddsMoreLink(t *testing.T) {
+	next := &limitedSender{limit: 50}
+	s := overflow.Wrap(next, overflow.Policy{MoreURL: overflow.MoreURLTemplate("https://app/n/{id}")}, slog.Default())
+
+	short := notify.Message{ID: "42", To: "1", Text: "коротко"}
+	long := notify.Message{ID: "42", To: "1", Text: strings.Repeat("длинный текст ", 20)}
+	for _, msg := range []notify.Message{short, long} {
+		if err := s.Send(context.Background(), msg); err != nil {
+			t.Fatalf("Ошибка Send: %v", err)
+		}
+	}
+
+	if next.sent[0].Text != short.Text {
+		t.Fatalf("короткое сообщение не должно меняться, получено %q", next.sent[0].Text)
+	}
+	got := next.sent[1].Text
+	if n := utf8.RuneCountInString(got); n > 50 {
+		t.Fatalf("текст длиннее предела: %d символов", n)
+	}
+	if !strings.HasSuffix(got, "Подробнее: https://app/n/42") {
+		t.Fatalf("нет ссылки на полный текст: %q", got)
+	}
+}
+
+func TestSplitByCategory(t *testing.T) {
+	next := &limitedSender{limit: 100}
+	s := overflow.Wrap(next, overflow.Policy{Categories: map[string]overflow.Strategy{"alerts": overflow.Split}}, slog.Default())
+
+	text := strings.Repeat("строка журнала\n", 20)
+	err := s.Send(context.Background(), notify.Message{ID: "a", To: "1", Subject: "Сбой", Text: text, Category: "alerts"})
+	if err != nil {
+		t.Fatalf("Ошибка Send: %v", err)
+	}
+
+	if len(next.sent) != 4 {
+		t.Fatalf("ожидалось 4 части, получено %d", len(next.sent))
+	}
+	var joined []string
+	for i, part := range next.sent {
+		want := "a"
+		if i > 0 {
+			want += "-" + string(rune('1'+i))
+		}
+		if part.ID != want {
+			t.Fatalf("ID части %d: ожидался %q, получен %q", i, want, part.ID)
+		}
+		if (i == 0) != (part.Subject != "") {
+			t.Fatalf("тема должна быть только у первой части, часть %d: %q", i, part.Subject)
+		}
+		if n := utf8.RuneCountInString(part.Text) + utf8.RuneCountInString("Сбой") + 2; n > 100 {
+			t.Fatalf("часть %d длиннее предела: %d символов", i, n)
+		}
+		if strings.HasSuffix(part.Text, "\n") {
+			t.Fatalf("часть %d должна заканчиваться на границе строки без переноса: %q", i, part.Text)
+		}
+		joined = append(joined, part.Text)
+	}
+	if strings.Join(joined, "\n") != strings.TrimSuffix(text, "\n") {
+		t.Fatal("части не складываются в исходный текст")
+	}
+}
+
+func TestAttachFallsBackToTruncate(t *testing.T) {
+	policy := overflow.Policy{Default: overflow.Attach}
+	text := strings.Repeat("я", 300)
+
+	files := &fileSender{limitedSender{limit: 100}}
+	if err := overflow.Wrap(files, policy, slog.Default()).Send(context.Background(), notify.Message{To: "1", Text: text}); err != nil {
+		t.Fatalf("Ошибка Send: %v", err)
+	}
+	if len(files.files) != 1 || string(files.files[0].Data) != text {
+		t.Fatal("полный текст должен уйти файлом")
+	}
+	if n := utf8.RuneCountInString(files.sent[0].Text); n > 100 {
+		t.Fatalf("подпись длиннее предела: %d символов", n)
+	}
+
+	plain := &limitedSender{limit: 100}
+	if err := overflow.Wrap(plain, policy, slog.Default()).Send(context.Background(), notify.Message{To: "1", Text: text}); err != nil {
+		t.Fatalf("Ошибка Send: %v", err)
+	}
+	if len(plain.sent) != 1 || utf8.RuneCountInString(plain.sent[0].Text) != 100 {
+		t.Fatal("без поддержки файлов сообщение должно быть обрезано")
+	}
+}
+
+func TestParseCategories(t *testing.T) {
+	got, err := overflow.ParseCategories("alerts=split, reports=attach")
+	if err != nil {
+		t.Fatalf("Ошибка ParseCategories: %v", err)
+	}
+	if got["alerts"] != overflow.Split || got["reports"] != overflow.Attach {
+		t.Fatalf("неверный разбор: %v", got)
+	}
+	if _, err := overflow.ParseCategories("alerts=shorten"); err == nil {
+		t.Fatal("ожидалась ошибка для неизвестной стратегии")
+	}
+}
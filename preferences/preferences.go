@@ -0,0 +1,284 @@
+// Package preferences хранит согласия получателей на категории уведомлений по каналам
+// и не пропускает сообщения категорий, от которых получатель отказался.
+//
+// Категории бывают двух видов: по умолчанию разрешённые (отказ — opt-out, например alerts)
+// и по умолчанию запрещённые (нужно явное согласие — opt-in, например marketing).
+package preferences
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/epheer/notephee/notify"
+)
+
+// AllChannels — значение Preference.Channel, относящееся ко всем каналам сразу.
+const AllChannels = ""
+
+// ErrOptedOut возвращается при попытке отправить сообщение категории, на которую у получателя нет согласия.
+var ErrOptedOut = errors.New("получатель отказался от уведомлений этой категории")
+
+// Preference — решение получателя по одной категории в одном канале.
+type Preference struct {
+	Subject   string    `json:"subject"`    // ID пользователя или, если его нет в сообщениях, адрес получателя
+	Channel   string    `json:"channel"`    // Канал; AllChannels — все каналы
+	Category  string    `json:"category"`   // Категория уведомлений
+	Allowed   bool      `json:"allowed"`    // true — согласие (opt-in), false — отказ (opt-out)
+	UpdatedAt time.Time `json:"updated_at"` // Время решения
+}
+
+// Store хранит решения получателей. Реализации должны быть безопасны для конкурентного использования.
+type Store interface {
+	// Set сохраняет решение, заменяя прежнее для той же тройки (subject, channel, category).
+	Set(ctx context.Context, p Preference) error
+	// Get возвращает решение для тройки; false, если решения нет.
+	Get(ctx context.Context, subject, channel, category string) (Preference, bool, error)
+	// List возвращает все решения получателя subject, упорядоченные по категории и каналу.
+	List(ctx context.Context, subject string) ([]Preference, error)
+	// Delete удаляет решение, возвращая категорию к поведению по умолчанию.
+	Delete(ctx context.Context, subject, channel, category string) error
+}
+
+// MemoryStore — Store в памяти процесса.
+type MemoryStore struct {
+	mu    sync.RWMutex
+	prefs map[string]map[string]Preference // subject → channel/category → решение
+}
+
+// NewMemoryStore создаёт пустое хранилище решений в памяти.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{prefs: make(map[string]map[string]Preference)}
+}
+
+func prefKey(channel, category string) string {
+	return channel + "/" + category
+}
+
+// Set сохраняет решение.
+func (s *MemoryStore) Set(_ context.Context, p Preference) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	bySubject, ok := s.prefs[p.Subject]
+	if !ok {
+		bySubject = make(map[string]Preference)
+		s.prefs[p.Subject] = bySubject
+	}
+	bySubject[prefKey(p.Channel, p.Category)] = p
+	return nil
+}
+
+// Get возвращает решение.
+func (s *MemoryStore) Get(_ context.Context, subject, channel, category string) (Preference, bool, error) {
+	s.mu.RLock()
+	p, ok := s.prefs[subject][prefKey(channel, category)]
+	s.mu.RUnlock()
+	return p, ok, nil
+}
+
+// List возвращает все решения получателя.
+func (s *MemoryStore) List(_ context.Context, subject string) ([]Preference, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	out := make([]Preference, 0, len(s.prefs[subject]))
+	for _, p := range s.prefs[subject] {
+		out = append(out, p)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Category != out[j].Category {
+			return out[i].Category < out[j].Category
+		}
+		return out[i].Channel < out[j].Channel
+	})
+	return out, nil
+}
+
+// Delete удаляет решение.
+func (s *MemoryStore) Delete(_ context.Context, subject, channel, category string) error {
+	s.mu.Lock()
+	delete(s.prefs[subject], prefKey(channel, category))
+	s.mu.Unlock()
+	return nil
+}
+
+// Policy решает, можно ли отправить сообщение категории получателю, по его решениям и значениям
+// по умолчанию. Решение для конкретного канала важнее решения для всех каналов.
+type Policy struct {
+	store Store
+	optIn atomic.Pointer[map[string]bool] // Категории, требующие явного согласия
+}
+
+// NewPolicy создаёт Policy над store. optIn — категории, которые без явного согласия запрещены
+// (например, marketing); остальные разрешены, пока получатель не откажется.
+func NewPolicy(store Store, optIn ...string) *Policy {
+	p := &Policy{store: store}
+	p.SetOptIn(optIn...)
+	return p
+}
+
+// SetOptIn заменяет категории, требующие явного согласия. Безопасен во время отправки:
+// так их меняет перезагрузка конфигурации (reload).
+func (p *Policy) SetOptIn(categories ...string) {
+	optIn := make(map[string]bool, len(categories))
+	for _, category := range categories {
+		optIn[category] = true
+	}
+	p.optIn.Store(&optIn)
+}
+
+// OptInCategories возвращает категории, требующие явного согласия, по алфавиту.
+func (p *Policy) OptInCategories() []string {
+	optIn := *p.optIn.Load()
+	out := make([]string, 0, len(optIn))
+	for category := range optIn {
+		out = append(out, category)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// ParseCategories разбирает список категорий через запятую, например "marketing,news".
+func ParseCategories(s string) []string {
+	var out []string
+	for _, c := range strings.Split(s, ",") {
+		if c = strings.TrimSpace(c); c != "" {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// Store возвращает хранилище решений.
+func (p *Policy) Store() Store {
+	return p.store
+}
+
+// OptIn сохраняет согласие subject на категорию в канале (AllChannels — во всех каналах).
+func (p *Policy) OptIn(ctx context.Context, subject, channel, category string) error {
+	return p.set(ctx, subject, channel, category, true)
+}
+
+// OptOut сохраняет отказ subject от категории в канале (AllChannels — во всех каналах).
+func (p *Policy) OptOut(ctx context.Context, subject, channel, category string) error {
+	return p.set(ctx, subject, channel, category, false)
+}
+
+func (p *Policy) set(ctx context.Context, subject, channel, category string, allowed bool) error {
+	if subject == "" || category == "" {
+		return fmt.Errorf("получатель и категория обязательны")
+	}
+	return p.store.Set(ctx, Preference{
+		Subject:   subject,
+		Channel:   channel,
+		Category:  category,
+		Allowed:   allowed,
+		UpdatedAt: time.Now(),
+	})
+}
+
+// Allowed сообщает, можно ли отправить subject сообщение категории category в канал channel.
+// Сообщения без категории разрешены всегда.
+func (p *Policy) Allowed(ctx context.Context, subject, channel, category string) (bool, error) {
+	return p.AllowedAny(ctx, []string{subject}, channel, category)
+}
+
+// AllowedAny — Allowed для получателя, известного под несколькими идентификаторами: ID пользователя
+// и адресом, от которого отписались по ссылке из письма. Отказ под любым из них запрещает отправку,
+// согласие под любым разрешает категорию с обязательным согласием.
+func (p *Policy) AllowedAny(ctx context.Context, subjects []string, channel, category string) (bool, error) {
+	if category == "" {
+		return true, nil
+	}
+	consent := false
+	for _, subject := range subjects {
+		pref, ok, err := p.decision(ctx, subject, channel, category)
+		if err != nil {
+			return false, err
+		}
+		if ok && !pref.Allowed {
+			return false, nil
+		}
+		consent = consent || ok
+	}
+	return consent || !(*p.optIn.Load())[category], nil
+}
+
+// decision возвращает решение subject для канала, а если его нет — для всех каналов.
+func (p *Policy) decision(ctx context.Context, subject, channel, category string) (Preference, bool, error) {
+	for _, ch := range []string{channel, AllChannels} {
+		pref, ok, err := p.store.Get(ctx, subject, ch, category)
+		if err != nil || ok {
+			return pref, ok, err
+		}
+	}
+	return Preference{}, false, nil
+}
+
+// Sender — обёртка над notify.Sender, не пропускающая сообщения категорий, на которые
+// у получателя нет согласия.
+type Sender struct {
+	next    notify.Sender // Обёрнутый канал
+	policy  *Policy       // Решения получателей
+	logger  *slog.Logger  // Логгер
+	blocked atomic.Int64  // Число отклонённых сообщений
+}
+
+// Wrap оборачивает канал next проверкой согласий по policy.
+func Wrap(next notify.Sender, policy *Policy, logger *slog.Logger) *Sender {
+	return &Sender{next: next, policy: policy, logger: logger}
+}
+
+// Channel возвращает имя обёрнутого канала.
+func (s *Sender) Channel() string {
+	return s.next.Channel()
+}
+
+// Unwrap возвращает обёрнутый канал.
+func (s *Sender) Unwrap() notify.Sender {
+	return s.next
+}
+
+// Send отправляет сообщение, если получатель не отказался от его категории.
+//
+// Решения ищутся по msg.UserID и по msg.To (см. Subjects). Отклонённое сообщение
+// возвращает ErrOptedOut. Если хранилище недоступно, сообщение с категорией тоже не отправляется:
+// отказ от рассылок обязателен по закону, и лучше пропустить уведомление, чем нарушить его.
+func (s *Sender) Send(ctx context.Context, msg notify.Message) error {
+	if msg.Category == "" {
+		return s.next.Send(ctx, msg)
+	}
+
+	subjects := Subjects(msg)
+	ok, err := s.policy.AllowedAny(ctx, subjects, s.next.Channel(), msg.Category)
+	if err != nil {
+		return fmt.Errorf("не удалось проверить согласие получателя: %w", err)
+	}
+	if !ok {
+		s.blocked.Add(1)
+		s.logger.Info("сообщение не отправлено: нет согласия получателя", "channel", s.next.Channel(), "subjects", subjects, "category", msg.Category)
+		return fmt.Errorf("%s/%s: %w", s.next.Channel(), msg.Category, ErrOptedOut)
+	}
+	return s.next.Send(ctx, msg)
+}
+
+// Blocked возвращает число сообщений, отклонённых с момента создания обёртки.
+func (s *Sender) Blocked() int64 {
+	return s.blocked.Load()
+}
+
+// Subjects возвращает идентификаторы получателя сообщения, по которым ищутся решения: msg.UserID
+// (если задан) и адрес msg.To.
+func Subjects(msg notify.Message) []string {
+	if msg.UserID != "" && msg.UserID != msg.To {
+		return []string{msg.UserID, msg.To}
+	}
+	return []string{msg.To}
+}
@@ -0,0 +1,83 @@
+package preferences_test
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+
+	"github.com/epheer/notephee/notify"
+	"github.com/epheer/notephee/preferences"
+)
+
+type countingSender struct {
+	sent int
+}
+
+func (s *countingSender) Channel() string { return "email" }
+
+func (s *countingSender) Send(context.Context, notify.Message) error {
+	s.sent++
+	return nil
+}
+
+func TestPolicy(t *testing.T) {
+	ctx := context.Background()
+	policy := preferences.NewPolicy(preferences.NewMemoryStore(), preferences.ParseCategories(" marketing , ")...)
+
+	check := func(subject, channel, category string, want bool) {
+		t.Helper()
+		got, err := policy.Allowed(ctx, subject, channel, category)
+		if err != nil || got != want {
+			t.Fatalf("Allowed(%s, %s, %s) = %v, %v; ожидалось %v", subject, channel, category, got, err, want)
+		}
+	}
+
+	check("u1", "email", "marketing", false)
+	check("u1", "email", "alerts", true)
+	check("u1", "email", "", true)
+
+	_ = policy.OptIn(ctx, "u1", preferences.AllChannels, "marketing")
+	_ = policy.OptOut(ctx, "u1", "telegram", "marketing")
+	_ = policy.OptOut(ctx, "u1", "email", "alerts")
+	check("u1", "email", "marketing", true)
+	check("u1", "telegram", "marketing", false)
+	check("u1", "email", "alerts", false)
+	check("u1", "telegram", "alerts", true)
+
+	prefs, _ := policy.Store().List(ctx, "u1")
+	if len(prefs) != 3 || prefs[0].Category != "alerts" || prefs[1].Channel != preferences.AllChannels {
+		t.Fatalf("неожиданный список решений: %+v", prefs)
+	}
+}
+
+func TestWrap(t *testing.T) {
+	ctx := context.Background()
+	policy := preferences.NewPolicy(preferences.NewMemoryStore())
+	next := &countingSender{}
+	s := preferences.Wrap(next, policy, slog.Default())
+
+	_ = policy.OptOut(ctx, "u1", "email", "marketing")
+	_ = policy.OptOut(ctx, "b@example.com", preferences.AllChannels, "marketing")
+
+	err := s.Send(ctx, notify.Message{To: "a@example.com", UserID: "u1", Category: "marketing"})
+	if !errors.Is(err, preferences.ErrOptedOut) {
+		t.Fatalf("ожидалась ErrOptedOut, получено %v", err)
+	}
+	// Без UserID решение ищется по адресу
+	if err := s.Send(ctx, notify.Message{To: "b@example.com", Category: "marketing"}); !errors.Is(err, preferences.ErrOptedOut) {
+		t.Fatalf("ожидалась ErrOptedOut для адреса, получено %v", err)
+	}
+	for _, msg := range []notify.Message{
+		{To: "a@example.com", UserID: "u1"},
+		{To: "a@example.com", UserID: "u1", Category: "alerts"},
+		{To: "c@example.com", Category: "marketing"},
+	} {
+		if err := s.Send(ctx, msg); err != nil {
+			t.Fatalf("Ошибка Send: %v", err)
+		}
+	}
+	if next.sent != 3 || s.Blocked() != 2 {
+		t.Fatalf("ожидалось 3 отправки и 2 отклонения, получено %d и %d", next.sent, s.Blocked())
+	}
+}
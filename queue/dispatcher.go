@@ -0,0 +1,441 @@
+// Package queue реализует очередь отправки с пулом воркеров, который сам подбирает
+// число воркеров под скорость канала.
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	"github.com/epheer/notephee/alert"
+	"github.com/epheer/notephee/notify"
+	"github.com/epheer/notephee/spool"
+)
+
+// ErrStopped возвращается при постановке в очередь после остановки диспетчера.
+var ErrStopped = errors.New("диспетчер остановлен")
+
+// Options — настройки диспетчера. Нулевые значения заменяются значениями по умолчанию.
+type Options struct {
+	Rate          float64       // Лимит канала, сообщений в секунду; 0 — без ограничения
+	Burst         int           // Допустимый всплеск лимитера; по умолчанию 1
+	MinWorkers    int           // Минимум воркеров; по умолчанию 1
+	MaxWorkers    int           // Максимум воркеров; по умолчанию 64
+	QueueSize     int           // Ёмкость очереди; по умолчанию 1024
+	ScaleInterval time.Duration // Период пересчёта числа воркеров; по умолчанию 1s
+	Headroom      float64       // Запас над расчётным числом воркеров; по умолчанию 1.2
+
+	// OnResult вызывается после каждой отправки (из горутины воркера).
+	OnResult func(Result)
+}
+
+func (o *Options) defaults() {
+	if o.Burst <= 0 {
+		o.Burst = 1
+	}
+	if o.MinWorkers <= 0 {
+		o.MinWorkers = 1
+	}
+	if o.MaxWorkers < o.MinWorkers {
+		o.MaxWorkers = max(64, o.MinWorkers)
+	}
+	if o.QueueSize <= 0 {
+		o.QueueSize = 1024
+	}
+	if o.ScaleInterval <= 0 {
+		o.ScaleInterval = time.Second
+	}
+	if o.Headroom < 1 {
+		o.Headroom = 1.2
+	}
+}
+
+// ParseRates разбирает лимиты каналов в формате «telegram=30,email=10» (сообщений в секунду).
+func ParseRates(s string) (map[string]float64, error) {
+	out := make(map[string]float64)
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(pair, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("некорректная пара канала %q: ожидается канал=лимит", pair)
+		}
+		r, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil || r <= 0 {
+			return nil, fmt.Errorf("лимит канала %s должен быть положительным числом: %q", name, value)
+		}
+		out[name] = r
+	}
+	return out, nil
+}
+
+// Job — сообщение в очереди.
+type Job struct {
+	Message    notify.Message // Сообщение
+	EnqueuedAt time.Time      // Время постановки в очередь
+}
+
+// Result — итог обработки одного сообщения.
+type Result struct {
+	Job
+	Error   error         // Ошибка отправки
+	Latency time.Duration // Время вызова Send у канала
+	Done    time.Time     // Время завершения отправки
+}
+
+// Dispatcher отправляет сообщения из очереди через один канал пулом воркеров.
+//
+// Число воркеров подбирается по закону Литтла: чтобы держать скорость λ при времени
+// отправки W, нужно L = λ·W одновременных отправок. λ — лимит канала (Options.Rate),
+// W — скользящее среднее наблюдаемой задержки Send. Когда очередь пуста, лишние воркеры завершаются.
+type Dispatcher struct {
+	sender  notify.Sender
+	opts    Options
+	limiter *rate.Limiter
+	logger  *slog.Logger
+	spool   spool.Store // Хранилище сообщений, не отправленных до Close (необязательно)
+
+	jobs chan Job
+	quit chan struct{} // Сигналы лишним воркерам завершиться
+
+	workers atomic.Int32 // Текущее целевое число воркеров
+	busy    atomic.Int32 // Воркеры, занятые отправкой
+	latency ewma         // Средняя задержка Send
+	full    atomic.Bool  // Очередь заполнялась с последней постановки без ожидания
+
+	mu          sync.Mutex
+	ctx         context.Context
+	cancel      context.CancelFunc
+	started     bool
+	closing     bool             // Close вызван: новые сообщения не принимаются
+	interrupted []notify.Message // Отправки, прерванные Close по истечении срока
+	wg          sync.WaitGroup
+
+	enqueueMu sync.RWMutex // Не даёт Close начаться посреди Enqueue
+}
+
+// NewDispatcher создаёт диспетчер для канала sender. Отправка начинается после Start.
+func NewDispatcher(sender notify.Sender, opts Options, logger *slog.Logger) *Dispatcher {
+	opts.defaults()
+
+	limit := rate.Inf
+	if opts.Rate > 0 {
+		limit = rate.Limit(opts.Rate)
+	}
+
+	return &Dispatcher{
+		sender:  sender,
+		opts:    opts,
+		limiter: rate.NewLimiter(limit, opts.Burst),
+		logger:  logger,
+		jobs:    make(chan Job, opts.QueueSize),
+		quit:    make(chan struct{}, opts.MaxWorkers),
+	}
+}
+
+// Start запускает MinWorkers воркеров и периодическое масштабирование. Работа останавливается по завершении ctx.
+func (d *Dispatcher) Start(ctx context.Context) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.started {
+		return
+	}
+	d.started = true
+	d.ctx, d.cancel = context.WithCancel(ctx)
+	ctx = d.ctx
+
+	for range d.opts.MinWorkers {
+		d.spawn()
+	}
+
+	d.wg.Add(1)
+	go func() {
+		defer d.wg.Done()
+		ticker := time.NewTicker(d.opts.ScaleInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				d.scale()
+			}
+		}
+	}()
+}
+
+// Wait ждёт завершения всех воркеров после отмены контекста Start.
+func (d *Dispatcher) Wait() {
+	d.wg.Wait()
+}
+
+// SetSpool подключает хранилище, в которое Close сохраняет неотправленные сообщения.
+func (d *Dispatcher) SetSpool(store spool.Store) {
+	d.spool = store
+}
+
+// Close останавливает диспетчер без потери сообщений.
+//
+// Новые сообщения больше не принимаются (Enqueue возвращает ErrStopped), а воркеры
+// продолжают отправлять очередь, пока она не опустеет или не завершится ctx.
+// После этого оставшиеся в очереди и прерванные сообщения сохраняются в хранилище
+// из SetSpool. Без хранилища они теряются, и Close возвращает ошибку с их числом.
+func (d *Dispatcher) Close(ctx context.Context) error {
+	d.enqueueMu.Lock()
+	d.mu.Lock()
+	d.closing = true
+	started := d.started
+	d.mu.Unlock()
+	d.enqueueMu.Unlock()
+
+	if started {
+		d.waitIdle(ctx)
+		d.cancel()
+		d.wg.Wait()
+	}
+
+	d.mu.Lock()
+	left := d.interrupted
+	d.interrupted = nil
+	d.mu.Unlock()
+	// Воркеры остановлены, а новые сообщения не принимаются, поэтому очередь больше не меняется
+	for len(d.jobs) > 0 {
+		left = append(left, (<-d.jobs).Message)
+	}
+	if len(left) == 0 {
+		return nil
+	}
+
+	if d.spool == nil {
+		return fmt.Errorf("%s: не отправлено сообщений: %d", d.sender.Channel(), len(left))
+	}
+	if err := spool.Save(context.WithoutCancel(ctx), d.spool, d.sender.Channel(), left); err != nil {
+		return fmt.Errorf("%s: не удалось сохранить %d неотправленных сообщений: %w", d.sender.Channel(), len(left), err)
+	}
+	d.logger.Info("неотправленные сообщения сохранены", "channel", d.sender.Channel(), "count", len(left))
+	return nil
+}
+
+// waitIdle ждёт, пока очередь опустеет и воркеры закончат отправку, или завершения ctx.
+func (d *Dispatcher) waitIdle(ctx context.Context) {
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+	for len(d.jobs) > 0 || d.busy.Load() > 0 {
+		select {
+		case <-ctx.Done():
+			return
+		case <-d.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Enqueue ставит сообщение в очередь. Если очередь заполнена, ждёт освобождения места или отмены ctx;
+// о заполненной очереди сообщается администратору (alert.Warning).
+func (d *Dispatcher) Enqueue(ctx context.Context, msg notify.Message) error {
+	d.enqueueMu.RLock()
+	defer d.enqueueMu.RUnlock()
+	if d.stopped() {
+		return ErrStopped
+	}
+
+	job := Job{Message: msg, EnqueuedAt: time.Now()}
+	if job.Message.EnqueuedAt.IsZero() {
+		// Сквозная задержка сообщения считается от постановки в очередь, если вызывающая сторона не задала её раньше
+		job.Message.EnqueuedAt = job.EnqueuedAt
+	}
+	select {
+	case d.jobs <- job:
+		d.full.Store(false)
+		return nil
+	default:
+	}
+
+	// Пока очередь не освободится, о ней сообщается один раз
+	if !d.full.Swap(true) {
+		d.logger.Warn("очередь отправки заполнена", "channel", d.sender.Channel(), "size", d.opts.QueueSize)
+		alert.Alert(alert.Warning, fmt.Sprintf("Очередь отправки канала %s заполнена (%d сообщений): отправители ждут освобождения места.",
+			d.sender.Channel(), d.opts.QueueSize))
+	}
+	select {
+	case d.jobs <- job:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (d *Dispatcher) stopped() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.closing || d.started && d.ctx.Err() != nil
+}
+
+// Channel возвращает имя канала диспетчера.
+func (d *Dispatcher) Channel() string {
+	return d.sender.Channel()
+}
+
+// Capacity возвращает ёмкость очереди (Options.QueueSize).
+func (d *Dispatcher) Capacity() int {
+	return cap(d.jobs)
+}
+
+// Workers возвращает текущее число воркеров.
+func (d *Dispatcher) Workers() int {
+	return int(d.workers.Load())
+}
+
+// Backlog возвращает число сообщений, ожидающих отправки.
+func (d *Dispatcher) Backlog() int {
+	return len(d.jobs)
+}
+
+// Latency возвращает среднюю задержку отправки.
+func (d *Dispatcher) Latency() time.Duration {
+	return d.latency.value()
+}
+
+// spawn запускает ещё одного воркера. Вызывается под d.mu.
+func (d *Dispatcher) spawn() {
+	d.workers.Add(1)
+	d.wg.Add(1)
+	go d.work()
+}
+
+func (d *Dispatcher) work() {
+	defer d.wg.Done()
+	for {
+		select {
+		case <-d.ctx.Done():
+			return
+		case <-d.quit:
+			return
+		case job := <-d.jobs:
+			d.process(job)
+		}
+	}
+}
+
+func (d *Dispatcher) process(job Job) {
+	d.busy.Add(1)
+	defer d.busy.Add(-1)
+
+	if err := d.limiter.Wait(d.ctx); err != nil {
+		d.interrupt(job)
+		d.report(Result{Job: job, Error: err, Done: time.Now()})
+		return
+	}
+
+	started := time.Now()
+	err := d.sender.Send(d.ctx, job.Message)
+	done := time.Now()
+	d.latency.observe(done.Sub(started))
+	if err != nil && d.ctx.Err() != nil {
+		d.interrupt(job)
+	}
+
+	if err != nil {
+		d.logger.Warn("не удалось отправить сообщение из очереди", "channel", d.sender.Channel(), "to", job.Message.To, "error", err)
+	}
+	d.report(Result{Job: job, Error: err, Latency: done.Sub(started), Done: done})
+}
+
+// interrupt запоминает сообщение, отправка которого прервана остановкой диспетчера.
+func (d *Dispatcher) interrupt(job Job) {
+	d.mu.Lock()
+	d.interrupted = append(d.interrupted, job.Message)
+	d.mu.Unlock()
+}
+
+func (d *Dispatcher) report(res Result) {
+	if d.opts.OnResult != nil {
+		d.opts.OnResult(res)
+	}
+}
+
+// scale приводит число воркеров к расчётному.
+func (d *Dispatcher) scale() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	current := d.Workers()
+	want := desiredWorkers(d.opts, d.latency.value(), len(d.jobs), int(d.busy.Load()))
+	switch {
+	case want > current:
+		// Сначала отзываем ещё не полученные сигналы завершения, затем добавляем воркеров
+		for n := current; n < want; n++ {
+			select {
+			case <-d.quit:
+				d.workers.Add(1)
+			default:
+				d.spawn()
+			}
+		}
+	case want < current:
+		for range current - want {
+			select {
+			case d.quit <- struct{}{}:
+				d.workers.Add(-1)
+			default:
+			}
+		}
+	default:
+		return
+	}
+	d.logger.Debug("число воркеров изменено", "channel", d.sender.Channel(), "from", current, "to", want, "latency", d.latency.value())
+}
+
+// desiredWorkers считает число воркеров по закону Литтла: L = λ·W с запасом Headroom.
+// Без лимита скорости λ неизвестна, и при наличии очереди берётся MaxWorkers.
+// Без очереди воркеров остаётся столько, сколько сейчас занято отправкой, но не меньше MinWorkers.
+func desiredWorkers(opts Options, latency time.Duration, backlog, busy int) int {
+	if backlog == 0 {
+		return max(busy, opts.MinWorkers)
+	}
+	if opts.Rate <= 0 || latency <= 0 {
+		return opts.MaxWorkers
+	}
+
+	n := int(math.Ceil(opts.Rate * latency.Seconds() * opts.Headroom))
+	return min(max(n, opts.MinWorkers), opts.MaxWorkers)
+}
+
+// ewma — экспоненциальное скользящее среднее задержки.
+type ewma struct {
+	mu    sync.Mutex
+	avg   float64 // Наносекунды
+	ready bool
+}
+
+// ewmaAlpha — вес нового наблюдения.
+const ewmaAlpha = 0.2
+
+func (e *ewma) observe(d time.Duration) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if !e.ready {
+		e.avg, e.ready = float64(d), true
+		return
+	}
+	e.avg = ewmaAlpha*float64(d) + (1-ewmaAlpha)*e.avg
+}
+
+func (e *ewma) value() time.Duration {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return time.Duration(e.avg)
+}
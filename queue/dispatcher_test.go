@@ -0,0 +1,154 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/epheer/notephee/notify"
+	"github.com/epheer/notephee/spool"
+)
+
+type slowSender struct {
+	delay time.Duration
+	sent  atomic.Int32
+}
+
+func (s *slowSender) Channel() string { return "fake" }
+
+func (s *slowSender) Send(context.Context, notify.Message) error {
+	time.Sleep(s.delay)
+	s.sent.Add(1)
+	return nil
+}
+
+func TestDesiredWorkers(t *testing.T) {
+	opts := Options{Rate: 100, MaxWorkers: 50}
+	opts.defaults()
+
+	// 100 сообщений/с при 50 мс на отправку: 5 одновременных отправок плюс 20% запаса
+	if n := desiredWorkers(opts, 50*time.Millisecond, 10, 0); n != 6 {
+		t.Fatalf("ожидалось 6 воркеров, получено %d", n)
+	}
+	if n := desiredWorkers(opts, 2*time.Second, 10, 0); n != 50 {
+		t.Fatalf("ожидалось ограничение MaxWorkers, получено %d", n)
+	}
+	if n := desiredWorkers(opts, 50*time.Millisecond, 0, 3); n != 3 {
+		t.Fatalf("без очереди должны остаться только занятые воркеры, получено %d", n)
+	}
+}
+
+func TestParseRates(t *testing.T) {
+	rates, err := ParseRates("telegram=30, email=0.5,")
+	if err != nil || len(rates) != 2 || rates["telegram"] != 30 || rates["email"] != 0.5 {
+		t.Fatalf("неверно разобраны лимиты: %v, %v", rates, err)
+	}
+	for _, bad := range []string{"telegram", "=5", "email=0", "email=fast"} {
+		if _, err := ParseRates(bad); err == nil {
+			t.Fatalf("ожидалась ошибка для %q", bad)
+		}
+	}
+}
+
+func TestDispatcherScalesUp(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sender := &slowSender{delay: 20 * time.Millisecond}
+	var results atomic.Int32
+	d := NewDispatcher(sender, Options{
+		Rate:          200,
+		Burst:         10,
+		MaxWorkers:    20,
+		ScaleInterval: 10 * time.Millisecond,
+		OnResult:      func(Result) { results.Add(1) },
+	}, slog.Default())
+	d.Start(ctx)
+
+	for range 100 {
+		if err := d.Enqueue(ctx, notify.Message{To: "1", Text: "x"}); err != nil {
+			t.Fatalf("Ошибка Enqueue: %v", err)
+		}
+	}
+
+	peak := 0
+	deadline := time.Now().Add(5 * time.Second)
+	for results.Load() < 100 && time.Now().Before(deadline) {
+		peak = max(peak, d.Workers())
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	if sender.sent.Load() != 100 {
+		t.Fatalf("ожидалось 100 отправок, получено %d", sender.sent.Load())
+	}
+	if peak < 2 {
+		t.Fatalf("диспетчер не масштабировался: максимум %d воркеров", peak)
+	}
+
+	cancel()
+	d.Wait()
+	if err := d.Enqueue(context.Background(), notify.Message{}); err != ErrStopped {
+		t.Fatalf("ожидалась ErrStopped после остановки, получено: %v", err)
+	}
+}
+
+func TestCloseDrainsQueue(t *testing.T) {
+	sender := &slowSender{delay: time.Millisecond}
+	d := NewDispatcher(sender, Options{MaxWorkers: 1}, slog.Default())
+	store := spool.NewMemoryStore()
+	d.SetSpool(store)
+	d.Start(context.Background())
+
+	for range 5 {
+		if err := d.Enqueue(context.Background(), notify.Message{To: "1", Text: "x"}); err != nil {
+			t.Fatalf("Ошибка Enqueue: %v", err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := d.Close(ctx); err != nil {
+		t.Fatalf("Ошибка Close: %v", err)
+	}
+	if n := sender.sent.Load(); n != 5 {
+		t.Fatalf("до остановки должны уйти все 5 сообщений, ушло %d", n)
+	}
+	if err := d.Enqueue(context.Background(), notify.Message{To: "1"}); !errors.Is(err, ErrStopped) {
+		t.Fatalf("после Close ожидался ErrStopped, получено %v", err)
+	}
+}
+
+func TestCloseSpoolsBacklog(t *testing.T) {
+	sender := &slowSender{delay: 50 * time.Millisecond}
+	d := NewDispatcher(sender, Options{MaxWorkers: 1}, slog.Default())
+	store := spool.NewMemoryStore()
+	d.SetSpool(store)
+	d.Start(context.Background())
+
+	for range 5 {
+		if err := d.Enqueue(context.Background(), notify.Message{To: "1", Text: "x"}); err != nil {
+			t.Fatalf("Ошибка Enqueue: %v", err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 70*time.Millisecond)
+	defer cancel()
+	if err := d.Close(ctx); err != nil {
+		t.Fatalf("Ошибка Close: %v", err)
+	}
+
+	entries, _ := store.Pending(context.Background())
+	sent := int(sender.sent.Load())
+	if sent == 5 || len(entries) == 0 {
+		t.Fatalf("часть сообщений должна остаться неотправленной: ушло %d, сохранено %d", sent, len(entries))
+	}
+	if sent+len(entries) != 5 {
+		t.Fatalf("сообщения потеряны: ушло %d, сохранено %d", sent, len(entries))
+	}
+	if entries[0].Channel != "fake" {
+		t.Fatalf("ожидался канал fake, получено %q", entries[0].Channel)
+	}
+}
@@ -0,0 +1,109 @@
+// Package redelivery применяет политику к отправкам с неизвестным исходом: когда запрос мог
+// дойти до провайдера, но ответ не получен (таймаут, обрыв соединения).
+//
+// Слепой повтор в такой ситуации даёт дубли, а отказ от повтора — пропуски. Политика задаёт,
+// какой риск допустим для канала.
+package redelivery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync/atomic"
+
+	"github.com/google/uuid"
+
+	"github.com/epheer/notephee/delivery"
+	"github.com/epheer/notephee/notify"
+	"github.com/epheer/notephee/tracing"
+)
+
+// Policy — действие при неизвестном исходе отправки.
+type Policy string
+
+const (
+	AssumeSent Policy = "assume_sent" // Считать сообщение отправленным: лучше пропуск, чем дубль
+	Resend     Policy = "resend"      // Отправить повторно с тем же ID как токеном идемпотентности
+	Manual     Policy = "manual"      // Вернуть ErrManual: решение о повторе принимает оператор
+)
+
+// ErrManual возвращается по политике Manual: повтор нужно запустить вручную после проверки у провайдера.
+var ErrManual = errors.New("исход отправки неизвестен, требуется ручная переотправка")
+
+// ParsePolicy разбирает название политики.
+func ParsePolicy(s string) (Policy, error) {
+	switch p := Policy(s); p {
+	case AssumeSent, Resend, Manual:
+		return p, nil
+	default:
+		return "", fmt.Errorf("неизвестная политика неизвестного исхода: %q", s)
+	}
+}
+
+// Sender — обёртка над notify.Sender, применяющая политику к ошибкам с неизвестным исходом
+// (delivery.IsIndeterminate). Остальные ошибки возвращаются как есть.
+type Sender struct {
+	next     notify.Sender          // Обёрнутый канал
+	policy   atomic.Pointer[Policy] // Политика; заменяется в SetPolicy
+	attempts int                    // Число повторов для Resend
+	logger   *slog.Logger           // Логгер
+}
+
+// Wrap оборачивает канал next политикой policy. Для Resend выполняется один повтор.
+func Wrap(next notify.Sender, policy Policy, logger *slog.Logger) *Sender {
+	s := &Sender{next: next, attempts: 1, logger: logger}
+	s.SetPolicy(policy)
+	return s
+}
+
+// SetPolicy заменяет политику во время работы.
+func (s *Sender) SetPolicy(policy Policy) {
+	s.policy.Store(&policy)
+}
+
+// SetAttempts задаёт число повторов для политики Resend.
+func (s *Sender) SetAttempts(n int) {
+	s.attempts = max(n, 1)
+}
+
+// Channel возвращает имя обёрнутого канала.
+func (s *Sender) Channel() string {
+	return s.next.Channel()
+}
+
+// Unwrap возвращает обёрнутый канал.
+func (s *Sender) Unwrap() notify.Sender {
+	return s.next
+}
+
+// Send отправляет сообщение и при неизвестном исходе применяет политику.
+//
+// Если msg.ID пуст, он генерируется до первой попытки: повтор по политике Resend идёт с тем же ID,
+// а каналы с идемпотентной отправкой (Matrix txnId, VK random_id) не создадут дубль.
+// Каналы без такой поддержки при Resend могут доставить сообщение дважды.
+func (s *Sender) Send(ctx context.Context, msg notify.Message) error {
+	if msg.ID == "" {
+		msg.ID = uuid.New().String()
+	}
+
+	err := s.next.Send(ctx, msg)
+	if !delivery.IsIndeterminate(err) {
+		return err
+	}
+
+	switch *s.policy.Load() {
+	case AssumeSent:
+		s.logger.Warn("исход отправки неизвестен, сообщение считается отправленным", "channel", s.next.Channel(), "id", msg.ID, "to", msg.To, "error", err)
+		return nil
+	case Resend:
+		for attempt := 1; attempt <= s.attempts && delivery.IsIndeterminate(err) && ctx.Err() == nil; attempt++ {
+			s.logger.Warn("исход отправки неизвестен, повтор с тем же ID", "channel", s.next.Channel(), "id", msg.ID, "to", msg.To, "attempt", attempt, "error", err)
+			err = s.next.Send(tracing.WithRetry(ctx, attempt), msg)
+		}
+		return err
+	default:
+		s.logger.Warn("исход отправки неизвестен, требуется ручная переотправка", "channel", s.next.Channel(), "id", msg.ID, "to", msg.To, "error", err)
+		return fmt.Errorf("%w: %w", ErrManual, err)
+	}
+}
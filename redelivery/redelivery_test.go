@@ -0,0 +1,55 @@
+package redelivery_test
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+
+	"github.com/epheer/notephee/delivery"
+	"github.com/epheer/notephee/notify"
+	"github.com/epheer/notephee/redelivery"
+)
+
+// flakySender теряет ответ на первую попытку и запоминает ID всех попыток.
+type flakySender struct {
+	ids []string
+}
+
+func (s *flakySender) Channel() string { return "fake" }
+
+func (s *flakySender) Send(_ context.Context, msg notify.Message) error {
+	s.ids = append(s.ids, msg.ID)
+	if len(s.ids) == 1 {
+		return context.DeadlineExceeded
+	}
+	return nil
+}
+
+func TestPolicies(t *testing.T) {
+	ctx := context.Background()
+	msg := notify.Message{To: "1", Text: "код 1234"}
+
+	next := &flakySender{}
+	if err := redelivery.Wrap(next, redelivery.Resend, slog.Default()).Send(ctx, msg); err != nil {
+		t.Fatalf("повтор должен пройти: %v", err)
+	}
+	if len(next.ids) != 2 || next.ids[0] == "" || next.ids[0] != next.ids[1] {
+		t.Fatalf("повтор должен идти с тем же ID, получено %q", next.ids)
+	}
+
+	next = &flakySender{}
+	if err := redelivery.Wrap(next, redelivery.AssumeSent, slog.Default()).Send(ctx, msg); err != nil || len(next.ids) != 1 {
+		t.Fatalf("assume_sent не должен повторять и возвращать ошибку: %v, попыток %d", err, len(next.ids))
+	}
+
+	next = &flakySender{}
+	err := redelivery.Wrap(next, redelivery.Manual, slog.Default()).Send(ctx, msg)
+	if !errors.Is(err, redelivery.ErrManual) || delivery.StatusOf(err) != delivery.StatusIndeterminate || len(next.ids) != 1 {
+		t.Fatalf("ожидалась ErrManual без повтора, получено %v, попыток %d", err, len(next.ids))
+	}
+
+	if _, err := redelivery.ParsePolicy("retry"); err == nil {
+		t.Fatal("ожидалась ошибка для неизвестной политики")
+	}
+}
@@ -0,0 +1,289 @@
+// Package reload перезагружает шаблоны, политики и настройки подписок во время работы, без перезапуска сервиса.
+//
+// Новая конфигурация сначала целиком проверяется, и только затем применяется: ошибка в одной
+// из политик не оставляет сервис с наполовину применёнными настройками.
+package reload
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/epheer/notephee/degrade"
+	"github.com/epheer/notephee/events"
+	"github.com/epheer/notephee/overflow"
+	"github.com/epheer/notephee/preferences"
+	"github.com/epheer/notephee/redelivery"
+	"github.com/epheer/notephee/templates"
+)
+
+// Config — перезагружаемая часть конфигурации. Пустые разделы оставляют текущие настройки.
+type Config struct {
+	Overflow      *Overflow    `json:"overflow,omitempty"`             // Стратегии длинных сообщений
+	Degrade       *Degrade     `json:"degrade,omitempty"`              // Политика деградации
+	Indeterminate string       `json:"indeterminate_policy,omitempty"` // Политика неизвестного исхода
+	Preferences   *Preferences `json:"preferences,omitempty"`          // Настройки подписок по умолчанию
+	Templates     []Template   `json:"templates,omitempty"`            // Шаблоны сообщений
+}
+
+// Preferences — настройки preferences.Policy по умолчанию.
+type Preferences struct {
+	OptIn []string `json:"opt_in"` // Категории, требующие явного согласия; пустой список — все разрешены до отказа
+}
+
+// Overflow — настройки overflow.Policy.
+type Overflow struct {
+	Strategy   string            `json:"strategy,omitempty"`   // Стратегия по умолчанию
+	Categories map[string]string `json:"categories,omitempty"` // Стратегии по категориям
+	MoreURL    string            `json:"more_url,omitempty"`   // Шаблон ссылки на полный текст с {id}
+}
+
+// Degrade — настройки degrade.Policy.
+type Degrade struct {
+	Threshold string `json:"threshold"`        // Средняя задержка деградации, например 2s
+	Low       string `json:"low,omitempty"`    // Действие для low
+	Normal    string `json:"normal,omitempty"` // Действие для normal
+}
+
+// Template — шаблон сообщения. Изменённый шаблон публикуется новой версией;
+// кампании с закреплённой версией продолжают использовать прежнюю.
+type Template struct {
+	Name    string `json:"name"`              // Имя шаблона
+	Subject string `json:"subject,omitempty"` // Шаблон темы
+	Body    string `json:"body"`              // Шаблон текста
+}
+
+// Reloader применяет Config к подключённым обёрткам каналов и хранилищу шаблонов.
+type Reloader struct {
+	mu         sync.Mutex
+	current    Config               // Последняя применённая конфигурация
+	templates  templates.Store      // Хранилище шаблонов (необязательно)
+	overflow   []*overflow.Sender   // Обёртки длинных сообщений
+	degrade    []*degrade.Sender    // Обёртки деградации
+	redelivery []*redelivery.Sender // Обёртки неизвестного исхода
+	prefs      *preferences.Policy  // Политика подписок (необязательно)
+	bus        *events.Bus          // Шина для events.ConfigReloaded (необязательно)
+	logger     *slog.Logger         // Логгер
+}
+
+// New создаёт Reloader с начальной конфигурацией current, которую возвращает Current до первой перезагрузки.
+func New(current Config, logger *slog.Logger) *Reloader {
+	return &Reloader{current: current, logger: logger}
+}
+
+// SetTemplates подключает хранилище, в которое публикуются шаблоны из Config.Templates.
+func (r *Reloader) SetTemplates(store templates.Store) {
+	r.templates = store
+}
+
+// SetEvents подключает шину, в которую публикуется events.ConfigReloaded.
+func (r *Reloader) SetEvents(bus *events.Bus) {
+	r.bus = bus
+}
+
+// SetPreferences подключает политику подписок, категории с обязательным согласием которой
+// меняются разделом Preferences.
+func (r *Reloader) SetPreferences(p *preferences.Policy) {
+	r.prefs = p
+}
+
+// AddOverflow подключает обёртку, политика которой меняется разделом Overflow.
+func (r *Reloader) AddOverflow(s *overflow.Sender) {
+	r.overflow = append(r.overflow, s)
+}
+
+// AddDegrade подключает обёртку, политика которой меняется разделом Degrade.
+func (r *Reloader) AddDegrade(s *degrade.Sender) {
+	r.degrade = append(r.degrade, s)
+}
+
+// AddRedelivery подключает обёртку, политика которой меняется полем Indeterminate.
+func (r *Reloader) AddRedelivery(s *redelivery.Sender) {
+	r.redelivery = append(r.redelivery, s)
+}
+
+// Current возвращает последнюю применённую конфигурацию.
+func (r *Reloader) Current() Config {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.current
+}
+
+// Versions возвращает последние опубликованные версии шаблонов текущей конфигурации в порядке Config.Templates.
+func (r *Reloader) Versions(ctx context.Context) ([]templates.Version, error) {
+	current := r.Current()
+	if len(current.Templates) == 0 {
+		return []templates.Version{}, nil
+	}
+	if r.templates == nil {
+		return nil, fmt.Errorf("хранилище шаблонов не подключено")
+	}
+	versions := make([]templates.Version, 0, len(current.Templates))
+	for _, t := range current.Templates {
+		v, err := r.templates.Latest(ctx, t.Name)
+		if err != nil {
+			return nil, fmt.Errorf("не удалось прочитать шаблон %s: %w", t.Name, err)
+		}
+		versions = append(versions, *v)
+	}
+	return versions, nil
+}
+
+// plan — проверенная конфигурация, готовая к применению.
+type plan struct {
+	overflow   *overflow.Policy
+	degrade    *degrade.Policy
+	redelivery redelivery.Policy
+	optIn      []string // nil — раздел Preferences не задан
+}
+
+// Reload проверяет cfg и применяет её. При ошибке проверки ничего не меняется.
+//
+// Изменённые шаблоны публикуются одним шагом (templates.Store.PublishAll) до замены политик;
+// если хранилище шаблонов вернуло ошибку, не публикуется ни один шаблон и политики остаются прежними.
+func (r *Reloader) Reload(ctx context.Context, cfg Config) error {
+	p, err := r.validate(cfg)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var drafts []templates.Draft
+	for _, t := range cfg.Templates {
+		latest, err := r.templates.Latest(ctx, t.Name)
+		if err == nil && latest.Subject == t.Subject && latest.Body == t.Body {
+			continue
+		}
+		if err != nil && !errors.Is(err, templates.ErrNotFound) {
+			return fmt.Errorf("не удалось прочитать шаблон %s: %w", t.Name, err)
+		}
+		drafts = append(drafts, templates.Draft{Name: t.Name, Subject: t.Subject, Body: t.Body})
+	}
+	if len(drafts) > 0 {
+		if _, err := r.templates.PublishAll(ctx, drafts); err != nil {
+			return fmt.Errorf("не удалось опубликовать шаблоны: %w", err)
+		}
+	}
+	published := len(drafts)
+
+	if p.overflow != nil {
+		for _, s := range r.overflow {
+			s.SetPolicy(*p.overflow)
+		}
+		r.current.Overflow = cfg.Overflow
+	}
+	if p.degrade != nil {
+		for _, s := range r.degrade {
+			s.SetPolicy(*p.degrade)
+		}
+		r.current.Degrade = cfg.Degrade
+	}
+	if p.redelivery != "" {
+		for _, s := range r.redelivery {
+			s.SetPolicy(p.redelivery)
+		}
+		r.current.Indeterminate = cfg.Indeterminate
+	}
+	if p.optIn != nil {
+		r.prefs.SetOptIn(p.optIn...)
+		r.current.Preferences = cfg.Preferences
+	}
+	if len(cfg.Templates) > 0 {
+		r.current.Templates = cfg.Templates
+	}
+
+	r.logger.Info("конфигурация перезагружена", "templates", published,
+		"overflow", p.overflow != nil, "degrade", p.degrade != nil, "indeterminate", p.redelivery,
+		"preferences", p.optIn != nil)
+	r.bus.Publish(events.Event{
+		Type: events.ConfigReloaded,
+		Time: time.Now(),
+		Data: map[string]string{
+			"templates":     strconv.Itoa(published),
+			"overflow":      strconv.FormatBool(p.overflow != nil),
+			"degrade":       strconv.FormatBool(p.degrade != nil),
+			"indeterminate": string(p.redelivery),
+			"preferences":   strconv.FormatBool(p.optIn != nil),
+		},
+	})
+	return nil
+}
+
+// validate разбирает все разделы cfg, не меняя состояния.
+func (r *Reloader) validate(cfg Config) (plan, error) {
+	var p plan
+
+	if o := cfg.Overflow; o != nil {
+		strategy, err := overflow.ParseStrategy(o.Strategy)
+		if err != nil {
+			return p, err
+		}
+		policy := overflow.Policy{
+			Default:    strategy,
+			Categories: make(map[string]overflow.Strategy, len(o.Categories)),
+			MoreURL:    overflow.MoreURLTemplate(o.MoreURL),
+		}
+		for category, value := range o.Categories {
+			if policy.Categories[category], err = overflow.ParseStrategy(value); err != nil {
+				return p, fmt.Errorf("категория %s: %w", category, err)
+			}
+		}
+		p.overflow = &policy
+	}
+
+	if d := cfg.Degrade; d != nil {
+		threshold, err := time.ParseDuration(d.Threshold)
+		if err != nil || threshold <= 0 {
+			return p, fmt.Errorf("некорректный порог деградации %q", d.Threshold)
+		}
+		policy := degrade.Policy{Threshold: threshold}
+		if policy.Low, err = degrade.ParseAction(d.Low); err != nil {
+			return p, err
+		}
+		if policy.Normal, err = degrade.ParseAction(d.Normal); err != nil {
+			return p, err
+		}
+		p.degrade = &policy
+	}
+
+	if cfg.Indeterminate != "" {
+		policy, err := redelivery.ParsePolicy(cfg.Indeterminate)
+		if err != nil {
+			return p, err
+		}
+		p.redelivery = policy
+	}
+
+	if pr := cfg.Preferences; pr != nil {
+		if r.prefs == nil {
+			return p, fmt.Errorf("политика подписок не подключена")
+		}
+		p.optIn = []string{}
+		for _, category := range pr.OptIn {
+			if category = strings.TrimSpace(category); category == "" {
+				return p, fmt.Errorf("пустая категория в списке opt_in")
+			}
+			p.optIn = append(p.optIn, category)
+		}
+	}
+
+	if len(cfg.Templates) > 0 && r.templates == nil {
+		return p, fmt.Errorf("хранилище шаблонов не подключено")
+	}
+	for _, t := range cfg.Templates {
+		if t.Name == "" {
+			return p, fmt.Errorf("у шаблона не задано имя")
+		}
+		if err := templates.Validate(t.Name, t.Subject, t.Body); err != nil {
+			return p, err
+		}
+	}
+	return p, nil
+}
@@ -0,0 +1,156 @@
+package reload_test
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/epheer/notephee/events"
+	"github.com/epheer/notephee/notify"
+	"github.com/epheer/notephee/overflow"
+	"github.com/epheer/notephee/preferences"
+	"github.com/epheer/notephee/reload"
+	"github.com/epheer/notephee/templates"
+)
+
+type limitedSender struct {
+	sent []notify.Message
+}
+
+func (s *limitedSender) Channel() string { return "fake" }
+
+func (s *limitedSender) Capabilities() notify.Capabilities {
+	return notify.Capabilities{Channel: "fake", MaxLength: 20}
+}
+
+func (s *limitedSender) Send(_ context.Context, msg notify.Message) error {
+	s.sent = append(s.sent, msg)
+	return nil
+}
+
+func TestReloadSwapsPolicies(t *testing.T) {
+	ctx := context.Background()
+	next := &limitedSender{}
+	o := overflow.Wrap(next, overflow.Policy{}, slog.Default())
+
+	store := templates.NewMemoryStore()
+	bus := events.NewBus()
+	var reloaded []events.Event
+	bus.Subscribe(func(e events.Event) { reloaded = append(reloaded, e) })
+
+	r := reload.New(reload.Config{}, slog.Default())
+	r.SetTemplates(store)
+	r.SetEvents(bus)
+	r.AddOverflow(o)
+
+	cfg := reload.Config{
+		Overflow:  &reload.Overflow{Strategy: "split"},
+		Templates: []reload.Template{{Name: "otp", Body: "Код: {{.code}}"}},
+	}
+	if err := r.Reload(ctx, cfg); err != nil {
+		t.Fatalf("Ошибка Reload: %v", err)
+	}
+	// Повторная загрузка того же шаблона не создаёт новую версию
+	if err := r.Reload(ctx, cfg); err != nil {
+		t.Fatalf("Ошибка Reload: %v", err)
+	}
+
+	_ = o.Send(ctx, notify.Message{To: "1", Text: strings.Repeat("слово ", 10)})
+	if len(next.sent) < 2 {
+		t.Fatalf("после перезагрузки длинное сообщение должно разбиваться, отправлено %d", len(next.sent))
+	}
+	if versions, _ := store.Versions(ctx, "otp"); len(versions) != 1 {
+		t.Fatalf("ожидалась одна версия шаблона, получено %d", len(versions))
+	}
+	if len(reloaded) != 2 || reloaded[0].Type != events.ConfigReloaded || reloaded[0].Data["templates"] != "1" {
+		t.Fatalf("ожидались события config_reloaded, получено %+v", reloaded)
+	}
+}
+
+func TestReloadValidatesBeforeApplying(t *testing.T) {
+	ctx := context.Background()
+	next := &limitedSender{}
+	o := overflow.Wrap(next, overflow.Policy{}, slog.Default())
+
+	r := reload.New(reload.Config{}, slog.Default())
+	r.SetTemplates(templates.NewMemoryStore())
+	r.AddOverflow(o)
+
+	bad := []reload.Config{
+		{Overflow: &reload.Overflow{Strategy: "split"}, Degrade: &reload.Degrade{Threshold: "скоро"}},
+		{Overflow: &reload.Overflow{Strategy: "split"}, Templates: []reload.Template{{Name: "otp", Body: "{{.code"}}},
+		{Overflow: &reload.Overflow{Strategy: "split"}, Indeterminate: "retry"},
+	}
+	for _, cfg := range bad {
+		if err := r.Reload(ctx, cfg); err == nil {
+			t.Fatalf("ожидалась ошибка проверки для %+v", cfg)
+		}
+	}
+
+	_ = o.Send(ctx, notify.Message{To: "1", Text: strings.Repeat("слово ", 10)})
+	if len(next.sent) != 1 || r.Current().Overflow != nil {
+		t.Fatal("конфигурация с ошибкой не должна применяться частично")
+	}
+}
+
+// failingStore не публикует шаблоны.
+type failingStore struct {
+	*templates.MemoryStore
+}
+
+func (s failingStore) PublishAll(context.Context, []templates.Draft) ([]templates.Version, error) {
+	return nil, errors.New("хранилище недоступно")
+}
+
+func TestReloadTemplatesAtomic(t *testing.T) {
+	ctx := context.Background()
+	next := &limitedSender{}
+	o := overflow.Wrap(next, overflow.Policy{}, slog.Default())
+	store := failingStore{templates.NewMemoryStore()}
+
+	r := reload.New(reload.Config{}, slog.Default())
+	r.SetTemplates(store)
+	r.AddOverflow(o)
+
+	cfg := reload.Config{
+		Overflow:  &reload.Overflow{Strategy: "split"},
+		Templates: []reload.Template{{Name: "otp", Body: "Код: {{.code}}"}, {Name: "welcome", Body: "Привет"}},
+	}
+	if err := r.Reload(ctx, cfg); err == nil {
+		t.Fatal("ожидалась ошибка публикации")
+	}
+	if _, err := store.Latest(ctx, "otp"); !errors.Is(err, templates.ErrNotFound) {
+		t.Fatalf("при ошибке не должен публиковаться ни один шаблон: %v", err)
+	}
+	if r.Current().Overflow != nil {
+		t.Fatal("при ошибке публикации политики не должны меняться")
+	}
+}
+
+func TestReloadPreferences(t *testing.T) {
+	ctx := context.Background()
+	prefs := preferences.NewPolicy(preferences.NewMemoryStore(), "marketing")
+	r := reload.New(reload.Config{}, slog.Default())
+
+	if err := r.Reload(ctx, reload.Config{Preferences: &reload.Preferences{OptIn: []string{"news"}}}); err == nil {
+		t.Fatal("без подключённой политики подписок ожидалась ошибка")
+	}
+	r.SetPreferences(prefs)
+	if err := r.Reload(ctx, reload.Config{Preferences: &reload.Preferences{OptIn: []string{" "}}}); err == nil {
+		t.Fatal("ожидалась ошибка для пустой категории")
+	}
+	if err := r.Reload(ctx, reload.Config{Preferences: &reload.Preferences{OptIn: []string{"news"}}}); err != nil {
+		t.Fatalf("Ошибка Reload: %v", err)
+	}
+	if ok, _ := prefs.Allowed(ctx, "u1", "email", "marketing"); !ok {
+		t.Fatal("marketing больше не требует согласия")
+	}
+	if ok, _ := prefs.Allowed(ctx, "u1", "email", "news"); ok {
+		t.Fatal("news должна требовать согласия")
+	}
+	if got := prefs.OptInCategories(); len(got) != 1 || got[0] != "news" || r.Current().Preferences == nil {
+		t.Fatalf("неверные категории после перезагрузки: %v", got)
+	}
+}
@@ -0,0 +1,158 @@
+// Package replay сохраняет всё, из чего было собрано сообщение, и позволяет позже
+// собрать его заново точно так же, как оно было отправлено.
+//
+// Нужен поддержке для разбора обращений вида «3 марта письмо выглядело неправильно»:
+// по снимку видно шаблон, данные и настройки на момент отправки, а Render показывает,
+// что именно получил пользователь.
+package replay
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/epheer/notephee/campaign"
+	"github.com/epheer/notephee/config"
+	"github.com/epheer/notephee/delivery"
+	"github.com/epheer/notephee/notify"
+)
+
+// ErrNotFound возвращается, если снимка для доставки нет.
+var ErrNotFound = errors.New("снимок сообщения не найден")
+
+// Snapshot — всё, из чего было собрано одно отправленное сообщение.
+type Snapshot struct {
+	DeliveryID      string            `json:"delivery_id"`                // ID записи в журнале доставки (notify.Message.ID)
+	Channel         string            `json:"channel"`                    // Канал отправки
+	Recipient       string            `json:"recipient"`                  // Адрес получателя в канале
+	UserID          string            `json:"user_id,omitempty"`          // Внутренний ID пользователя
+	Campaign        string            `json:"campaign,omitempty"`         // Имя кампании
+	Template        string            `json:"template,omitempty"`         // Имя шаблона
+	TemplateVersion int               `json:"template_version,omitempty"` // Версия шаблона; 0 — без версий
+	Subject         string            `json:"subject,omitempty"`          // Исходник шаблона темы
+	Body            string            `json:"body"`                       // Исходник шаблона текста
+	Raw             bool              `json:"raw,omitempty"`              // Subject и Body — готовый текст без шаблона
+	Data            map[string]any    `json:"data,omitempty"`             // Данные, с которыми исполнялся шаблон
+	Config          map[string]string `json:"config,omitempty"`           // Несекретные настройки на момент отправки
+	MessageHash     string            `json:"message_hash"`               // Хэш отправленных темы и текста
+	CreatedAt       time.Time         `json:"created_at"`                 // Время отправки
+}
+
+// Query — условия поиска снимков. Пустые поля не ограничивают выборку.
+type Query struct {
+	Recipient string    // Адрес получателя
+	UserID    string    // Внутренний ID пользователя
+	Campaign  string    // Имя кампании
+	From      time.Time // Не раньше этого времени
+	To        time.Time // Раньше этого времени
+}
+
+// Match сообщает, подходит ли снимок под условия.
+func (q Query) Match(s Snapshot) bool {
+	switch {
+	case q.Recipient != "" && s.Recipient != q.Recipient:
+		return false
+	case q.UserID != "" && s.UserID != q.UserID:
+		return false
+	case q.Campaign != "" && s.Campaign != q.Campaign:
+		return false
+	case !q.From.IsZero() && s.CreatedAt.Before(q.From):
+		return false
+	case !q.To.IsZero() && !s.CreatedAt.Before(q.To):
+		return false
+	}
+	return true
+}
+
+// Store хранит снимки сообщений.
+type Store interface {
+	// Save сохраняет снимок.
+	Save(ctx context.Context, s Snapshot) error
+	// Get возвращает снимок по ID доставки или ErrNotFound.
+	Get(ctx context.Context, deliveryID string) (*Snapshot, error)
+	// Find возвращает снимки, подходящие под q, в порядке отправки.
+	Find(ctx context.Context, q Query) ([]Snapshot, error)
+}
+
+// Capture снимает снимок сообщения msg, собранного кампанией c для получателя r
+// (см. campaign.Campaign.Message), вместе с закреплённой версией шаблона.
+// cfg — конфигурация на момент отправки; секреты не сохраняются.
+func Capture(c *campaign.Campaign, r campaign.Recipient, channel string, msg notify.Message, cfg *config.Config) Snapshot {
+	return Snapshot{
+		DeliveryID:      msg.ID,
+		Channel:         channel,
+		Recipient:       msg.To,
+		UserID:          msg.UserID,
+		Campaign:        c.Name,
+		Template:        c.TemplateName,
+		TemplateVersion: c.TemplateVersion,
+		Subject:         c.Subject,
+		Body:            c.Template,
+		Data:            r.Data,
+		Config:          ConfigOf(cfg),
+		MessageHash:     delivery.Hash(msg.Subject, msg.Text),
+		CreatedAt:       time.Now(),
+	}
+}
+
+// CaptureMessage снимает снимок сообщения msg, отправленного в канал channel готовым текстом,
+// без шаблона кампании. cfg — конфигурация на момент отправки; секреты не сохраняются.
+func CaptureMessage(channel string, msg notify.Message, cfg *config.Config) Snapshot {
+	return Snapshot{
+		DeliveryID:  msg.ID,
+		Channel:     channel,
+		Recipient:   msg.To,
+		UserID:      msg.UserID,
+		Campaign:    msg.Campaign,
+		Subject:     msg.Subject,
+		Body:        msg.Text,
+		Raw:         true,
+		Config:      ConfigOf(cfg),
+		MessageHash: delivery.Hash(msg.Subject, msg.Text),
+		CreatedAt:   time.Now(),
+	}
+}
+
+// Result — сообщение, собранное заново по снимку.
+type Result struct {
+	Subject string // Тема
+	Text    string // Текст
+	Matches bool   // Совпадает ли результат с тем, что было отправлено
+}
+
+// Render заново собирает сообщение по снимку тем же движком шаблонов, что и кампания.
+//
+// Matches == false означает, что снимок не воспроизводит отправленное сообщение — например,
+// данные были изменены после отправки.
+func Render(s Snapshot) (Result, error) {
+	if s.Raw {
+		return Result{Subject: s.Subject, Text: s.Body, Matches: delivery.Hash(s.Subject, s.Body) == s.MessageHash}, nil
+	}
+	c := campaign.Campaign{Subject: s.Subject, Template: s.Body}
+	subject, text, err := c.Render(s.Data)
+	if err != nil {
+		return Result{}, err
+	}
+	return Result{
+		Subject: subject,
+		Text:    text,
+		Matches: delivery.Hash(subject, text) == s.MessageHash,
+	}, nil
+}
+
+// ConfigOf возвращает несекретные настройки, влияющие на вид и доставку сообщений.
+// Токены и пароли в снимок не попадают.
+func ConfigOf(cfg *config.Config) map[string]string {
+	if cfg == nil {
+		return nil
+	}
+	return map[string]string{
+		"telegram_bot_name": cfg.TelegramBotName,
+		"smtp_host":         cfg.EmailHost,
+		"smtp_port":         cfg.EmailPort,
+		"smtp_user":         cfg.EmailUser,
+		"smtp_from_name":    cfg.EmailFromName,
+		"dedup_window":      cfg.DedupWindow.String(),
+		"digest_interval":   cfg.DigestInterval.String(),
+	}
+}
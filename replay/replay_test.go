@@ -0,0 +1,102 @@
+package replay_test
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/epheer/notephee/campaign"
+	"github.com/epheer/notephee/config"
+	"github.com/epheer/notephee/notify"
+	"github.com/epheer/notephee/replay"
+)
+
+func TestReplayFromFileStore(t *testing.T) {
+	ctx := context.Background()
+	c := &campaign.Campaign{
+		Name:     "march",
+		Subject:  "Счёт №{{.Invoice}}",
+		Template: "К оплате {{.Amount}} ₽",
+		Channels: campaign.ChannelPolicy{Channels: []string{"email"}},
+	}
+	r := campaign.Recipient{
+		UserID:    "u1",
+		Addresses: map[string]string{"email": "u1@example.com"},
+		Data:      map[string]any{"Invoice": 42, "Amount": 1500.5},
+	}
+
+	channel, msg, err := c.Message(r)
+	if err != nil {
+		t.Fatalf("Ошибка Message: %v", err)
+	}
+	snap := replay.Capture(c, r, channel, msg, &config.Config{EmailHost: "smtp.example.com", EmailPassword: "secret"})
+	if _, ok := snap.Config["smtp_password"]; ok {
+		t.Fatal("пароль не должен попадать в снимок")
+	}
+
+	store, err := replay.NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("Ошибка NewFileStore: %v", err)
+	}
+	if err := store.Save(ctx, snap); err != nil {
+		t.Fatalf("Ошибка Save: %v", err)
+	}
+
+	// Правка кампании после отправки не влияет на воспроизведение
+	c.Template = "Новый текст"
+
+	found, err := store.Find(ctx, replay.Query{
+		Recipient: "u1@example.com",
+		From:      time.Now().Add(-time.Hour),
+		To:        time.Now().Add(time.Hour),
+	})
+	if err != nil || len(found) != 1 {
+		t.Fatalf("ожидался один снимок, получено %d (%v)", len(found), err)
+	}
+
+	res, err := replay.Render(found[0])
+	if err != nil {
+		t.Fatalf("Ошибка Render: %v", err)
+	}
+	if !res.Matches || res.Subject != msg.Subject || res.Text != msg.Text {
+		t.Fatalf("сообщение не воспроизведено: %+v, ожидалось %q / %q", res, msg.Subject, msg.Text)
+	}
+}
+
+type okSender struct {
+	sent []notify.Message
+}
+
+func (s *okSender) Channel() string { return "telegram" }
+
+func (s *okSender) Send(_ context.Context, msg notify.Message) error {
+	s.sent = append(s.sent, msg)
+	return nil
+}
+
+func TestSenderCapturesMessages(t *testing.T) {
+	ctx := context.Background()
+	store, err := replay.NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("Ошибка NewFileStore: %v", err)
+	}
+	next := &okSender{}
+	s := replay.Wrap(next, store, &config.Config{TelegramBotName: "notephee_bot"}, slog.Default())
+
+	if err := s.Send(ctx, notify.Message{To: "42", Text: "Оплата {{не шаблон}}"}); err != nil {
+		t.Fatalf("Ошибка Send: %v", err)
+	}
+	id := next.sent[0].ID
+	if id == "" {
+		t.Fatal("обёртка должна назначить ID сообщению без него")
+	}
+	snap, err := store.Get(ctx, id)
+	if err != nil {
+		t.Fatalf("снимок не сохранён: %v", err)
+	}
+	res, err := replay.Render(*snap)
+	if err != nil || !res.Matches || res.Text != "Оплата {{не шаблон}}" || snap.Config["telegram_bot_name"] != "notephee_bot" {
+		t.Fatalf("неверный снимок: %+v %+v %v", snap, res, err)
+	}
+}
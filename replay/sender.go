@@ -0,0 +1,52 @@
+package replay
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/google/uuid"
+
+	"github.com/epheer/notephee/config"
+	"github.com/epheer/notephee/notify"
+)
+
+// Sender — обёртка канала, которая сохраняет снимок каждого успешно отправленного сообщения
+// (CaptureMessage), чтобы его можно было найти и показать через notephee replay.
+//
+// Сообщению без ID обёртка назначает его сама, чтобы снимок и запись журнала доставки совпадали.
+// Ошибка сохранения снимка только записывается в лог: сообщение уже отправлено.
+type Sender struct {
+	next   notify.Sender
+	store  Store
+	cfg    *config.Config
+	logger *slog.Logger
+}
+
+// Wrap оборачивает канал next: снимки сохраняются в store вместе с несекретными настройками cfg.
+func Wrap(next notify.Sender, store Store, cfg *config.Config, logger *slog.Logger) *Sender {
+	return &Sender{next: next, store: store, cfg: cfg, logger: logger}
+}
+
+// Channel возвращает имя обёрнутого канала.
+func (s *Sender) Channel() string {
+	return s.next.Channel()
+}
+
+// Unwrap возвращает обёрнутый канал.
+func (s *Sender) Unwrap() notify.Sender {
+	return s.next
+}
+
+// Send отправляет сообщение и после успешной отправки сохраняет его снимок.
+func (s *Sender) Send(ctx context.Context, msg notify.Message) error {
+	if msg.ID == "" {
+		msg.ID = uuid.New().String()
+	}
+	if err := s.next.Send(ctx, msg); err != nil {
+		return err
+	}
+	if err := s.store.Save(context.WithoutCancel(ctx), CaptureMessage(s.next.Channel(), msg, s.cfg)); err != nil {
+		s.logger.Warn("не удалось сохранить снимок сообщения", "channel", s.next.Channel(), "id", msg.ID, "error", err)
+	}
+	return nil
+}
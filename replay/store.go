@@ -0,0 +1,139 @@
+package replay
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// MemoryStore — Store в памяти процесса. Подходит для тестов.
+type MemoryStore struct {
+	mu        sync.RWMutex
+	snapshots map[string]Snapshot
+}
+
+// NewMemoryStore создаёт пустое хранилище снимков в памяти.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{snapshots: make(map[string]Snapshot)}
+}
+
+// Save сохраняет снимок.
+func (s *MemoryStore) Save(_ context.Context, snap Snapshot) error {
+	s.mu.Lock()
+	s.snapshots[snap.DeliveryID] = snap
+	s.mu.Unlock()
+	return nil
+}
+
+// Get возвращает снимок по ID доставки.
+func (s *MemoryStore) Get(_ context.Context, deliveryID string) (*Snapshot, error) {
+	s.mu.RLock()
+	snap, ok := s.snapshots[deliveryID]
+	s.mu.RUnlock()
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &snap, nil
+}
+
+// Find возвращает снимки, подходящие под q, в порядке отправки.
+func (s *MemoryStore) Find(_ context.Context, q Query) ([]Snapshot, error) {
+	s.mu.RLock()
+	var out []Snapshot
+	for _, snap := range s.snapshots {
+		if q.Match(snap) {
+			out = append(out, snap)
+		}
+	}
+	s.mu.RUnlock()
+
+	sortSnapshots(out)
+	return out, nil
+}
+
+// FileStore хранит каждый снимок в отдельном JSON-файле каталога.
+//
+// Числа в данных читаются как json.Number, чтобы шаблон выводил их так же, как при отправке.
+type FileStore struct {
+	dir string
+}
+
+// NewFileStore создаёт хранилище в каталоге dir, создавая его при необходимости.
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("не удалось создать каталог снимков %s: %w", dir, err)
+	}
+	return &FileStore{dir: dir}, nil
+}
+
+func (s *FileStore) path(id string) string {
+	return filepath.Join(s.dir, filepath.Base(id)+".json")
+}
+
+// Save записывает снимок на диск. Снимок неизменяем, поэтому повторная запись
+// того же ID просто перезаписывает файл.
+func (s *FileStore) Save(_ context.Context, snap Snapshot) error {
+	data, err := json.Marshal(snap)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(s.path(snap.DeliveryID), data, 0o644); err != nil {
+		return fmt.Errorf("не удалось сохранить снимок %s: %w", snap.DeliveryID, err)
+	}
+	return nil
+}
+
+// Get читает снимок с диска.
+func (s *FileStore) Get(_ context.Context, deliveryID string) (*Snapshot, error) {
+	data, err := os.ReadFile(s.path(deliveryID))
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("не удалось прочитать снимок %s: %w", deliveryID, err)
+	}
+
+	var snap Snapshot
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&snap); err != nil {
+		return nil, fmt.Errorf("повреждённый файл снимка %s: %w", deliveryID, err)
+	}
+	return &snap, nil
+}
+
+// Find перебирает все снимки каталога и возвращает подходящие под q в порядке отправки.
+func (s *FileStore) Find(ctx context.Context, q Query) ([]Snapshot, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось прочитать каталог снимков: %w", err)
+	}
+
+	var out []Snapshot
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasSuffix(name, ".json") {
+			continue
+		}
+		snap, err := s.Get(ctx, strings.TrimSuffix(name, ".json"))
+		if err != nil {
+			return nil, err
+		}
+		if q.Match(*snap) {
+			out = append(out, *snap)
+		}
+	}
+
+	sortSnapshots(out)
+	return out, nil
+}
+
+func sortSnapshots(snaps []Snapshot) {
+	sort.Slice(snaps, func(i, j int) bool { return snaps[i].CreatedAt.Before(snaps[j].CreatedAt) })
+}
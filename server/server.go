@@ -0,0 +1,575 @@
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/epheer/notephee/backfill"
+	"github.com/epheer/notephee/degrade"
+	"github.com/epheer/notephee/delivery"
+	"github.com/epheer/notephee/latency"
+	"github.com/epheer/notephee/notify"
+	"github.com/epheer/notephee/preferences"
+	"github.com/epheer/notephee/queue"
+	"github.com/epheer/notephee/reload"
+	"github.com/epheer/notephee/spool"
+)
+
+// HealthReporter — источник состояния канала для /readyz, например degrade.Sender.
+type HealthReporter interface {
+	Health() degrade.Health
+}
+
+// Server — HTTP API для отправки уведомлений из сервисов, написанных не на Go.
+type Server struct {
+	registry *notify.Registry     // Доступные каналы отправки
+	log      delivery.DeliveryLog // Журнал доставки для GET /v1/deliveries/{id}
+	logger   *slog.Logger         // Логгер
+	token    string               // Bearer-токен для /v1/* (пусто — без авторизации)
+	health   []HealthReporter     // Состояние каналов для /readyz
+	spool    spool.Store          // Хранилище сообщений прерванных рассылок (необязательно)
+	reloader *reload.Reloader     // Перезагрузка шаблонов и политик для /v1/admin/* (необязательно)
+	admin    string               // Bearer-токен для /v1/admin/*
+	latency  *latency.Tracker     // Сквозная задержка отправок для GET /v1/latency (необязательно)
+	prefs    *preferences.Policy  // Согласия получателей для /v1/preferences/* (необязательно)
+	unsub    http.Handler         // Обработчик одношаговой отписки для /unsubscribe (необязательно)
+	track    http.Handler         // Обработчик открытий и переходов для /track (необязательно)
+	importer *backfill.Importer   // Загрузка истории прежней системы для /v1/admin/import (необязательно)
+
+	queues map[notify.Sender]*queue.Dispatcher // Очереди рассылок по каналам (необязательно)
+
+	ctx context.Context // Контекст фоновых рассылок
+	wg  sync.WaitGroup  // Незавершённые фоновые рассылки
+}
+
+// New создаёт HTTP-сервер notephee.
+//
+// token — Bearer-токен, которым должны подписываться запросы к /v1/*. Пустая строка отключает авторизацию,
+// поэтому сервер без токена стоит слушать только на localhost.
+func New(registry *notify.Registry, log delivery.DeliveryLog, token string, logger *slog.Logger) *Server {
+	return &Server{
+		registry: registry,
+		log:      log,
+		logger:   logger,
+		token:    token,
+		ctx:      context.Background(),
+	}
+}
+
+// AddHealth подключает источник состояния канала, который выводится в /readyz.
+// Вызывается при настройке, до запуска сервера.
+func (s *Server) AddHealth(h HealthReporter) {
+	s.health = append(s.health, h)
+}
+
+// SetSpool подключает хранилище, в которое сохраняются неотправленные сообщения рассылок,
+// прерванных остановкой сервера.
+func (s *Server) SetSpool(store spool.Store) {
+	s.spool = store
+}
+
+// SetReloader включает административный API перезагрузки шаблонов и политик.
+// Запросы к /v1/admin/* должны содержать Bearer-токен adminToken, отдельный от токена /v1/*;
+// пустой токен оставляет административный API выключенным.
+func (s *Server) SetReloader(r *reload.Reloader, adminToken string) {
+	s.reloader = r
+	s.admin = adminToken
+}
+
+// SetLatency подключает сборщик сквозной задержки, который выводится в GET /v1/latency.
+func (s *Server) SetLatency(t *latency.Tracker) {
+	s.latency = t
+}
+
+// SetPreferences включает API согласий получателей на категории уведомлений: /v1/preferences/{subject}.
+func (s *Server) SetPreferences(p *preferences.Policy) {
+	s.prefs = p
+}
+
+// SetUnsubscribe подключает обработчик ссылок отписки из писем (unsubscribe.Handler или TopicHandler)
+// на /unsubscribe. Путь открыт без токена: по нему переходят получатели и почтовые клиенты,
+// а запрос подтверждается подписью токена в ссылке.
+func (s *Server) SetUnsubscribe(h http.Handler) {
+	s.unsub = h
+}
+
+// SetTracking подключает обработчик пикселей и ссылок отслеживания из писем (tracking.Handler) на /track.
+// Путь открыт без токена, как /unsubscribe: адреса в письмах подписаны.
+func (s *Server) SetTracking(h http.Handler) {
+	s.track = h
+}
+
+// SetImporter включает загрузку истории прежней системы рассылок: POST /v1/admin/import.
+// Запросы подписываются токеном административного API (см. SetReloader).
+func (s *Server) SetImporter(im *backfill.Importer) {
+	s.importer = im
+}
+
+// SetQueue направляет рассылки через канал sender в очередь d вместо отправки по одному сообщению:
+// пул воркеров очереди подстраивается под лимит канала. sender — канал из реестра, в том числе канал
+// личности отправителя. Вызывается при настройке, до запуска сервера.
+func (s *Server) SetQueue(sender notify.Sender, d *queue.Dispatcher) {
+	if s.queues == nil {
+		s.queues = make(map[notify.Sender]*queue.Dispatcher)
+	}
+	s.queues[sender] = d
+}
+
+// Handler возвращает маршрутизатор HTTP API.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("POST /v1/notifications", s.auth(http.HandlerFunc(s.handleNotification)))
+	mux.Handle("POST /v1/broadcasts", s.auth(http.HandlerFunc(s.handleBroadcast)))
+	mux.Handle("GET /v1/deliveries/{id}", s.auth(http.HandlerFunc(s.handleDelivery)))
+	mux.Handle("GET /v1/channels", s.auth(http.HandlerFunc(s.handleChannels)))
+	if s.latency != nil {
+		mux.Handle("GET /v1/latency", s.auth(http.HandlerFunc(s.handleLatency)))
+	}
+	if s.prefs != nil {
+		mux.Handle("GET /v1/preferences/{subject}", s.auth(http.HandlerFunc(s.handleGetPreferences)))
+		mux.Handle("PUT /v1/preferences/{subject}", s.auth(http.HandlerFunc(s.handleSetPreference)))
+	}
+	if s.reloader != nil && s.admin != "" {
+		mux.Handle("GET /v1/admin/config", s.adminAuth(http.HandlerFunc(s.handleAdminConfig)))
+		mux.Handle("GET /v1/admin/templates", s.adminAuth(http.HandlerFunc(s.handleAdminTemplates)))
+		mux.Handle("POST /v1/admin/reload", s.adminAuth(http.HandlerFunc(s.handleAdminReload)))
+	}
+	if s.importer != nil && s.admin != "" {
+		mux.Handle("POST /v1/admin/import", s.adminAuth(http.HandlerFunc(s.handleAdminImport)))
+	}
+	if s.unsub != nil {
+		mux.Handle("GET /unsubscribe", s.unsub)
+		mux.Handle("POST /unsubscribe", s.unsub)
+	}
+	if s.track != nil {
+		mux.Handle("GET /track", s.track)
+	}
+	mux.HandleFunc("GET /healthz", s.handleLive)
+	mux.HandleFunc("GET /readyz", s.handleReady)
+	return mux
+}
+
+// ListenAndServe запускает сервер на addr и останавливает его по завершении ctx,
+// дожидаясь окончания запущенных рассылок.
+func (s *Server) ListenAndServe(ctx context.Context, addr string) error {
+	s.ctx = ctx
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           s.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		s.logger.Info("HTTP API notephee запущен", "addr", addr)
+		errCh <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	err := srv.Shutdown(shutdownCtx)
+	s.wg.Wait()
+	s.logger.Info("HTTP API notephee остановлен")
+	return err
+}
+
+// auth проверяет Bearer-токен, если он задан.
+func (s *Server) auth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.token != "" && !bearer(r, s.token) {
+			writeError(w, http.StatusUnauthorized, "требуется авторизация")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// adminAuth проверяет Bearer-токен административного API.
+func (s *Server) adminAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !bearer(r, s.admin) {
+			writeError(w, http.StatusUnauthorized, "требуется авторизация")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// bearer сообщает, передан ли в заголовке Authorization токен token со схемой Bearer.
+// Токен без схемы не принимается.
+func bearer(r *http.Request, token string) bool {
+	got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && subtle.ConstantTimeCompare([]byte(got), []byte(token)) == 1
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, errorResponse{Error: msg})
+}
+
+// decode читает JSON-тело запроса, ограничивая его размер.
+func decode(r *http.Request, v any) error {
+	dec := json.NewDecoder(http.MaxBytesReader(nil, r.Body, 10<<20))
+	dec.DisallowUnknownFields()
+	return dec.Decode(v)
+}
+
+func (s *Server) handleNotification(w http.ResponseWriter, r *http.Request) {
+	received := time.Now()
+	var req notificationRequest
+	if err := decode(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, "некорректный JSON: "+err.Error())
+		return
+	}
+	if (req.To == "" && req.UserID == "") || (req.Text == "" && req.Notification == nil) {
+		writeError(w, http.StatusBadRequest, "поля to (или user_id) и text (или notification) обязательны")
+		return
+	}
+	if req.Notification != nil {
+		if err := req.Notification.Validate(); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	if req.To == "" {
+		// Адрес и, если канал не указан, канал берутся из каталога получателей
+		channel, to, err := s.registry.Resolve(r.Context(), req.UserID, req.Channel, req.Category)
+		switch {
+		case errors.Is(err, notify.ErrUnknownRecipient):
+			writeError(w, http.StatusNotFound, err.Error())
+			return
+		case err != nil:
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		req.Channel, req.To = channel, to
+	}
+
+	sender, err := s.registry.Identity(req.Channel, req.Identity)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	msg := req.message()
+	msg.ID = uuid.New().String()
+	msg.EnqueuedAt = received
+	if req.SLO != "" {
+		if msg.SLO, err = time.ParseDuration(req.SLO); err != nil || msg.SLO <= 0 {
+			writeError(w, http.StatusBadRequest, "некорректное значение slo: "+req.SLO)
+			return
+		}
+	}
+
+	resp := sendResponse{ID: msg.ID, Status: string(delivery.StatusSent)}
+	status := http.StatusOK
+	if err := sender.Send(r.Context(), msg); err != nil {
+		resp.Status = string(delivery.StatusOf(err))
+		resp.Error = err.Error()
+		status = http.StatusBadGateway
+	} else {
+		lat := latency.Measure(msg, time.Now())
+		resp.LatencyMs = millis(lat.Latency)
+		resp.Late = lat.Late
+	}
+	writeJSON(w, status, resp)
+}
+
+func (s *Server) handleBroadcast(w http.ResponseWriter, r *http.Request) {
+	var req broadcastRequest
+	if err := decode(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, "некорректный JSON: "+err.Error())
+		return
+	}
+	if len(req.Recipients) == 0 || req.Text == "" {
+		writeError(w, http.StatusBadRequest, "поля recipients и text обязательны")
+		return
+	}
+
+	sender, err := s.registry.Identity(req.Channel, req.Identity)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	resp := broadcastResponse{ID: uuid.New().String()}
+	messages := make([]notify.Message, 0, len(req.Recipients))
+	for _, to := range req.Recipients {
+		msg := notify.Message{
+			ID:       uuid.New().String(),
+			To:       to,
+			Subject:  req.Subject,
+			Text:     req.Text,
+			Campaign: req.Campaign,
+			UserID:   req.UserID,
+			DedupKey: req.DedupKey,
+			Priority: notify.Priority(req.Priority),
+			Category: req.Category,
+			Identity: req.Identity,
+		}
+		messages = append(messages, msg)
+		resp.Deliveries = append(resp.Deliveries, broadcastDelivery{Recipient: to, ID: msg.ID})
+	}
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		if d, ok := s.queues[sender]; ok {
+			s.enqueueBroadcast(resp.ID, d, messages)
+			return
+		}
+		s.runBroadcast(resp.ID, sender, messages)
+	}()
+
+	writeJSON(w, http.StatusAccepted, resp)
+}
+
+// runBroadcast последовательно отправляет сообщения рассылки.
+//
+// Результат каждой отправки попадает в журнал доставки через клиента канала.
+// При остановке сервера начатая отправка завершается, а оставшиеся сообщения
+// сохраняются в хранилище из SetSpool.
+func (s *Server) runBroadcast(id string, sender notify.Sender, messages []notify.Message) {
+	// Остановка не прерывает начатую отправку: её результат неизвестен, и она могла бы уйти дважды
+	sendCtx := context.WithoutCancel(s.ctx)
+	failed := 0
+	for i, msg := range messages {
+		if err := s.ctx.Err(); err != nil {
+			s.interrupt(id, sender.Channel(), messages[i:], err)
+			return
+		}
+		if err := sender.Send(sendCtx, msg); err != nil {
+			failed++
+		}
+	}
+	s.logger.Info("рассылка завершена", "broadcast_id", id, "total", len(messages), "failed", failed)
+}
+
+// enqueueBroadcast ставит сообщения рассылки в очередь канала. Если очередь остановлена вместе
+// с сервером, оставшиеся сообщения сохраняются в хранилище из SetSpool.
+func (s *Server) enqueueBroadcast(id string, d *queue.Dispatcher, messages []notify.Message) {
+	for i, msg := range messages {
+		if err := d.Enqueue(s.ctx, msg); err != nil {
+			s.interrupt(id, d.Channel(), messages[i:], err)
+			return
+		}
+	}
+	s.logger.Info("рассылка поставлена в очередь", "broadcast_id", id, "total", len(messages))
+}
+
+// interrupt сохраняет неотправленные сообщения прерванной рассылки.
+func (s *Server) interrupt(id, channel string, left []notify.Message, cause error) {
+	if s.spool == nil {
+		s.logger.Warn("рассылка прервана", "broadcast_id", id, "left", len(left), "error", cause)
+		return
+	}
+	if err := spool.Save(context.Background(), s.spool, channel, left); err != nil {
+		s.logger.Error("рассылка прервана, не удалось сохранить неотправленные сообщения", "broadcast_id", id, "left", len(left), "error", err)
+		return
+	}
+	s.logger.Info("рассылка прервана, неотправленные сообщения сохранены", "broadcast_id", id, "left", len(left))
+}
+
+func (s *Server) handleDelivery(w http.ResponseWriter, r *http.Request) {
+	rec, err := s.log.Get(r.Context(), r.PathValue("id"))
+	if errors.Is(err, delivery.ErrNotFound) {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		s.logger.Error("ошибка чтения журнала доставки", "error", err)
+		writeError(w, http.StatusInternalServerError, "ошибка чтения журнала доставки")
+		return
+	}
+	resp := newDeliveryResponse(rec)
+	if log, ok := s.log.(delivery.EngagementLog); ok {
+		engagements, err := log.Engagements(r.Context(), rec.ID)
+		if err != nil {
+			s.logger.Error("ошибка чтения действий получателя", "error", err)
+		}
+		for _, e := range engagements {
+			resp.Engagements = append(resp.Engagements, engagementResponse{Action: string(e.Action), URL: e.URL, Time: e.Time})
+		}
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// handleGetPreferences возвращает решения получателя по категориям.
+func (s *Server) handleGetPreferences(w http.ResponseWriter, r *http.Request) {
+	prefs, err := s.prefs.Store().List(r.Context(), r.PathValue("subject"))
+	if err != nil {
+		s.logger.Error("ошибка чтения согласий получателя", "error", err)
+		writeError(w, http.StatusInternalServerError, "ошибка чтения согласий получателя")
+		return
+	}
+	writeJSON(w, http.StatusOK, preferencesResponse{Preferences: prefs})
+}
+
+// handleSetPreference сохраняет согласие или отказ получателя по категории.
+func (s *Server) handleSetPreference(w http.ResponseWriter, r *http.Request) {
+	var req preferenceRequest
+	if err := decode(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, "некорректный JSON: "+err.Error())
+		return
+	}
+	if req.Category == "" || req.Allowed == nil {
+		writeError(w, http.StatusBadRequest, "поля category и allowed обязательны")
+		return
+	}
+
+	subject := r.PathValue("subject")
+	set := s.prefs.OptOut
+	if *req.Allowed {
+		set = s.prefs.OptIn
+	}
+	if err := set(r.Context(), subject, req.Channel, req.Category); err != nil {
+		s.logger.Error("ошибка сохранения согласия получателя", "error", err)
+		writeError(w, http.StatusInternalServerError, "ошибка сохранения согласия получателя")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleChannels возвращает зарегистрированные каналы с их возможностями.
+func (s *Server) handleChannels(w http.ResponseWriter, _ *http.Request) {
+	caps := s.registry.Capabilities()
+	resp := channelsResponse{Channels: make([]notify.Capabilities, 0, len(caps))}
+	for _, name := range s.registry.Channels() {
+		if c, ok := caps[name]; ok {
+			resp.Channels = append(resp.Channels, c)
+		}
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (s *Server) handleLatency(w http.ResponseWriter, _ *http.Request) {
+	stats := s.latency.Stats()
+	resp := latencyResponse{Channels: make([]latencyStats, 0, len(stats))}
+	for _, st := range stats {
+		resp.Channels = append(resp.Channels, latencyStats{
+			Channel: st.Channel,
+			Count:   st.Count,
+			Late:    st.Late,
+			P50Ms:   millis(st.P50),
+			P95Ms:   millis(st.P95),
+			P99Ms:   millis(st.P99),
+		})
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// millis переводит длительность в миллисекунды с дробной частью.
+func millis(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+func (s *Server) handleLive(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, healthResponse{Status: "ok"})
+}
+
+func (s *Server) handleReady(w http.ResponseWriter, _ *http.Request) {
+	channels := s.registry.Channels()
+	if len(channels) == 0 {
+		writeJSON(w, http.StatusServiceUnavailable, healthResponse{Status: "no channels", Channels: channels})
+		return
+	}
+
+	// Деградировавший канал продолжает принимать транзакционные сообщения, поэтому сервис остаётся готов
+	resp := healthResponse{Status: "ok", Channels: channels}
+	for _, h := range s.health {
+		state := h.Health()
+		if state.State == degrade.StateDegraded {
+			resp.Status = string(degrade.StateDegraded)
+		}
+		resp.Health = append(resp.Health, state)
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (s *Server) handleAdminConfig(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, s.reloader.Current())
+}
+
+// handleAdminTemplates возвращает последние версии шаблонов текущей конфигурации.
+func (s *Server) handleAdminTemplates(w http.ResponseWriter, r *http.Request) {
+	versions, err := s.reloader.Versions(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, versions)
+}
+
+func (s *Server) handleAdminReload(w http.ResponseWriter, r *http.Request) {
+	var cfg reload.Config
+	if err := decode(r, &cfg); err != nil {
+		writeError(w, http.StatusBadRequest, "некорректный JSON: "+err.Error())
+		return
+	}
+	if err := s.reloader.Reload(r.Context(), cfg); err != nil {
+		writeError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, s.reloader.Current())
+}
+
+// handleAdminImport загружает историю из тела запроса. Формат задаётся параметром format (csv или json),
+// канал строк без колонки channel — параметром channel, колонки источника — параметрами column=поле=колонка,
+// а dry_run=1 только проверяет файл.
+func (s *Server) handleAdminImport(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	opts := backfill.Options{Channel: q.Get("channel"), DryRun: q.Get("dry_run") == "1" || q.Get("dry_run") == "true"}
+	for _, c := range q["column"] {
+		field, column, ok := strings.Cut(c, "=")
+		if !ok || field == "" || column == "" {
+			writeError(w, http.StatusBadRequest, "параметр column задаётся как поле=колонка: "+c)
+			return
+		}
+		if opts.Columns == nil {
+			opts.Columns = make(map[string]string)
+		}
+		opts.Columns[field] = column
+	}
+	if f := q.Get("format"); f != "" {
+		format, err := backfill.ParseFormat(f)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		opts.Format = format
+	}
+
+	res, err := s.importer.Import(r.Context(), r.Body, opts)
+	if err != nil {
+		s.logger.Error("импорт истории прерван", "read", res.Read, "imported", res.Imported, "error", err)
+		writeError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	resp := importResponse{Read: res.Read, Imported: res.Imported, Skipped: res.Skipped, Suppressed: res.Suppressed}
+	for _, e := range res.Errors {
+		resp.Errors = append(resp.Errors, importError{Row: e.Row, Error: e.Err.Error()})
+	}
+	s.logger.Info("история импортирована", "read", res.Read, "imported", res.Imported, "skipped", res.Skipped,
+		"suppressed", res.Suppressed, "errors", len(res.Errors), "dry_run", opts.DryRun)
+	writeJSON(w, http.StatusOK, resp)
+}
@@ -0,0 +1,615 @@
+package server_test
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/epheer/notephee/backfill"
+	"github.com/epheer/notephee/dedup"
+	"github.com/epheer/notephee/delivery"
+	"github.com/epheer/notephee/email/unsubscribe"
+	"github.com/epheer/notephee/latency"
+	"github.com/epheer/notephee/notify"
+	"github.com/epheer/notephee/preferences"
+	"github.com/epheer/notephee/queue"
+	"github.com/epheer/notephee/reload"
+	"github.com/epheer/notephee/server"
+	"github.com/epheer/notephee/suppression"
+	"github.com/epheer/notephee/templates"
+)
+
+// fakeSender пишет каждую отправку в журнал доставки, как это делают настоящие клиенты.
+type fakeSender struct {
+	log delivery.DeliveryLog
+}
+
+func (f *fakeSender) Channel() string { return "fake" }
+
+func (f *fakeSender) Send(ctx context.Context, msg notify.Message) error {
+	return f.log.Save(ctx, delivery.Record{
+		ID:        msg.ID,
+		Channel:   "fake",
+		Recipient: msg.To,
+		Status:    delivery.StatusSent,
+		CreatedAt: time.Now(),
+	})
+}
+
+func TestNotificationAndDeliveryLookup(t *testing.T) {
+	log := delivery.NewMemoryLog()
+	registry := notify.NewRegistry()
+	registry.Register(&fakeSender{log: log})
+
+	srv := httptest.NewServer(server.New(registry, log, "secret", slog.Default()).Handler())
+	defer srv.Close()
+
+	body := `{"channel":"fake","to":"42","text":"привет"}`
+	req, _ := http.NewRequest(http.MethodPost, srv.URL+"/v1/notifications", strings.NewReader(body))
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Ошибка запроса: %v", err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("запрос без токена должен быть отклонён, получен %d", resp.StatusCode)
+	}
+
+	// Токен без схемы Bearer не принимается
+	req, _ = http.NewRequest(http.MethodPost, srv.URL+"/v1/notifications", strings.NewReader(body))
+	req.Header.Set("Authorization", "secret")
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Ошибка запроса: %v", err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("токен без Bearer должен быть отклонён, получен %d", resp.StatusCode)
+	}
+
+	req, _ = http.NewRequest(http.MethodPost, srv.URL+"/v1/notifications", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer secret")
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Ошибка запроса: %v", err)
+	}
+	var sent struct {
+		ID     string `json:"id"`
+		Status string `json:"status"`
+	}
+	_ = json.NewDecoder(resp.Body).Decode(&sent)
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || sent.Status != "sent" {
+		t.Fatalf("неожиданный ответ: %d %+v", resp.StatusCode, sent)
+	}
+
+	req, _ = http.NewRequest(http.MethodGet, srv.URL+"/v1/deliveries/"+sent.ID, nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Ошибка запроса: %v", err)
+	}
+	var rec struct {
+		Recipient string `json:"recipient"`
+	}
+	_ = json.NewDecoder(resp.Body).Decode(&rec)
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || rec.Recipient != "42" {
+		t.Fatalf("запись о доставке не найдена: %d %+v", resp.StatusCode, rec)
+	}
+}
+
+func TestBroadcastQueue(t *testing.T) {
+	log := delivery.NewMemoryLog()
+	registry := notify.NewRegistry()
+	sender := &fakeSender{log: log}
+	registry.Register(sender)
+	var queued atomic.Int32
+	d := queue.NewDispatcher(sender, queue.Options{Rate: 1000, OnResult: func(queue.Result) { queued.Add(1) }}, slog.Default())
+	d.Start(context.Background())
+	defer d.Close(context.Background())
+
+	s := server.New(registry, log, "", slog.Default())
+	s.SetQueue(sender, d)
+	srv := httptest.NewServer(s.Handler())
+	defer srv.Close()
+
+	resp, err := http.Post(srv.URL+"/v1/broadcasts", "application/json", strings.NewReader(`{"channel":"fake","recipients":["1","2","3"],"text":"привет"}`))
+	if err != nil {
+		t.Fatalf("Ошибка запроса: %v", err)
+	}
+	var got struct {
+		Deliveries []struct {
+			ID string `json:"id"`
+		} `json:"deliveries"`
+	}
+	_ = json.NewDecoder(resp.Body).Decode(&got)
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted || len(got.Deliveries) != 3 {
+		t.Fatalf("неожиданный ответ: %d %+v", resp.StatusCode, got)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for queued.Load() < 3 {
+		if time.Now().After(deadline) {
+			t.Fatalf("через очередь отправлено %d сообщений из 3", queued.Load())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if _, err := log.Get(context.Background(), got.Deliveries[2].ID); err != nil {
+		t.Fatalf("отправка из очереди не попала в журнал доставки: %v", err)
+	}
+}
+
+// channelSender передаёт отправленные сообщения в канал sent.
+type channelSender struct {
+	sent chan notify.Message
+}
+
+func (s *channelSender) Channel() string { return "fake" }
+
+func (s *channelSender) Send(_ context.Context, msg notify.Message) error {
+	s.sent <- msg
+	return nil
+}
+
+func TestBroadcastFields(t *testing.T) {
+	sender := &channelSender{sent: make(chan notify.Message, 1)}
+	registry := notify.NewRegistry()
+	registry.Register(sender)
+	srv := httptest.NewServer(server.New(registry, delivery.NewMemoryLog(), "", slog.Default()).Handler())
+	defer srv.Close()
+
+	body := `{"channel":"fake","recipients":["1"],"text":"скидка","user_id":"u1","dedup_key":"sale-1","priority":"low","category":"marketing"}`
+	resp, err := http.Post(srv.URL+"/v1/broadcasts", "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("Ошибка запроса: %v", err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("ожидался 202, получен %d", resp.StatusCode)
+	}
+
+	select {
+	case msg := <-sender.sent:
+		if msg.UserID != "u1" || msg.DedupKey != "sale-1" || msg.Priority != notify.PriorityLow || msg.Category != "marketing" {
+			t.Fatalf("поля рассылки не переданы в сообщение: %+v", msg)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("сообщение рассылки не отправлено")
+	}
+}
+
+// notificationSender запоминает последнее сообщение.
+type notificationSender struct {
+	fakeSender
+	got notify.Message
+}
+
+func (s *notificationSender) Send(ctx context.Context, msg notify.Message) error {
+	s.got = msg
+	return s.fakeSender.Send(ctx, msg)
+}
+
+func TestStructuredNotification(t *testing.T) {
+	log := delivery.NewMemoryLog()
+	sender := &notificationSender{fakeSender: fakeSender{log: log}}
+	registry := notify.NewRegistry()
+	registry.Register(sender)
+
+	srv := httptest.NewServer(server.New(registry, log, "secret", slog.Default()).Handler())
+	defer srv.Close()
+
+	post := func(body string) int {
+		req, _ := http.NewRequest(http.MethodPost, srv.URL+"/v1/notifications", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer secret")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Ошибка запроса: %v", err)
+		}
+		_ = resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	code := post(`{"channel":"fake","to":"42","notification":{"title":"Сбой оплаты","severity":"critical",
+		"actions":[{"label":"Открыть","url":"https://example.com/p/1"}],"metadata":{"заказ":"1"}}}`)
+	if code != http.StatusOK {
+		t.Fatalf("неожиданный ответ: %d", code)
+	}
+	if n := sender.got.Notification; n == nil || n.Title != "Сбой оплаты" || sender.got.Priority != notify.PriorityHigh ||
+		sender.got.Text != "Сбой оплаты\n\nзаказ: 1\n\nОткрыть: https://example.com/p/1" {
+		t.Fatalf("неожиданное сообщение: %+v", sender.got)
+	}
+
+	if code := post(`{"channel":"fake","to":"42","notification":{"title":"x","actions":[{"label":"Открыть","url":"javascript:alert(1)"}]}}`); code != http.StatusBadRequest {
+		t.Fatalf("действие с небезопасной ссылкой должно отклоняться, получен %d", code)
+	}
+}
+
+// richSender — канал, который описывает свои возможности.
+type richSender struct {
+	fakeSender
+}
+
+func (r *richSender) Channel() string { return "rich" }
+
+func (r *richSender) Capabilities() notify.Capabilities {
+	return notify.Capabilities{Channel: "rich", HTML: true, MaxLength: 100}
+}
+
+func TestChannels(t *testing.T) {
+	log := delivery.NewMemoryLog()
+	registry := notify.NewRegistry()
+	registry.Register(&fakeSender{log: log})
+	// Возможности видны и через обёртки
+	registry.Register(dedup.Wrap(&richSender{fakeSender{log: log}}, dedup.NewMemoryStore(), time.Minute, slog.Default()))
+
+	srv := httptest.NewServer(server.New(registry, log, "", slog.Default()).Handler())
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/v1/channels")
+	if err != nil {
+		t.Fatalf("Ошибка запроса: %v", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	var got struct {
+		Channels []notify.Capabilities `json:"channels"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatalf("некорректный JSON: %v", err)
+	}
+	if len(got.Channels) != 2 || got.Channels[0].Channel != "fake" || got.Channels[0].HTML {
+		t.Fatalf("канал без описания должен быть обычным текстом: %+v", got.Channels)
+	}
+	if rich := got.Channels[1]; rich.Channel != "rich" || !rich.HTML || rich.MaxLength != 100 {
+		t.Fatalf("ожидались возможности канала rich, получено %+v", rich)
+	}
+}
+
+// tenantSender — канал личности отправителя, запоминающий полученные сообщения.
+type tenantSender struct {
+	sent []notify.Message
+}
+
+func (t *tenantSender) Channel() string { return "fake" }
+
+func (t *tenantSender) Send(_ context.Context, msg notify.Message) error {
+	t.sent = append(t.sent, msg)
+	return nil
+}
+
+func TestNotificationIdentity(t *testing.T) {
+	log := delivery.NewMemoryLog()
+	registry := notify.NewRegistry()
+	registry.Register(&fakeSender{log: log})
+	tenant := &tenantSender{}
+	registry.RegisterIdentity("acme", tenant)
+
+	srv := httptest.NewServer(server.New(registry, log, "", slog.Default()).Handler())
+	defer srv.Close()
+
+	send := func(identity string) int {
+		body := `{"channel":"fake","to":"42","text":"привет","identity":"` + identity + `"}`
+		resp, err := http.Post(srv.URL+"/v1/notifications", "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatalf("Ошибка запроса: %v", err)
+		}
+		_ = resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	if code := send("acme"); code != http.StatusOK {
+		t.Fatalf("ожидался 200, получен %d", code)
+	}
+	if len(tenant.sent) != 1 || tenant.sent[0].Identity != "acme" {
+		t.Fatalf("сообщение должно уйти через канал личности: %+v", tenant.sent)
+	}
+	if code := send("globex"); code != http.StatusBadRequest {
+		t.Fatalf("незарегистрированная личность должна отклоняться с 400, получен %d", code)
+	}
+}
+
+func TestAdminReload(t *testing.T) {
+	log := delivery.NewMemoryLog()
+	s := server.New(notify.NewRegistry(), log, "secret", slog.Default())
+	s.SetReloader(reload.New(reload.Config{}, slog.Default()), "admin")
+
+	srv := httptest.NewServer(s.Handler())
+	defer srv.Close()
+
+	reloadWith := func(token, body string) int {
+		req, _ := http.NewRequest(http.MethodPost, srv.URL+"/v1/admin/reload", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Ошибка запроса: %v", err)
+		}
+		_ = resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	if code := reloadWith("secret", `{"indeterminate_policy":"resend"}`); code != http.StatusUnauthorized {
+		t.Fatalf("токен /v1/* не должен давать доступ к административному API, получен %d", code)
+	}
+	if code := reloadWith("admin", `{"indeterminate_policy":"retry"}`); code != http.StatusUnprocessableEntity {
+		t.Fatalf("некорректная политика должна отклоняться с 422, получен %d", code)
+	}
+	if code := reloadWith("admin", `{"indeterminate_policy":"resend"}`); code != http.StatusOK {
+		t.Fatalf("ожидался 200, получен %d", code)
+	}
+}
+
+func TestAdminReloadTemplates(t *testing.T) {
+	store := templates.NewMemoryStore()
+	reloader := reload.New(reload.Config{}, slog.Default())
+	reloader.SetTemplates(store)
+	s := server.New(notify.NewRegistry(), delivery.NewMemoryLog(), "", slog.Default())
+	s.SetReloader(reloader, "admin")
+	srv := httptest.NewServer(s.Handler())
+	defer srv.Close()
+
+	body := `{"templates":[{"name":"welcome","subject":"Привет","body":"Здравствуйте, {{.Name}}"}]}`
+	req, _ := http.NewRequest(http.MethodPost, srv.URL+"/v1/admin/reload", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer admin")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Ошибка запроса: %v", err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("ожидался 200, получен %d", resp.StatusCode)
+	}
+	if v, err := store.Latest(context.Background(), "welcome"); err != nil || v.Version != 1 {
+		t.Fatalf("шаблон не опубликован: %+v, %v", v, err)
+	}
+
+	req, _ = http.NewRequest(http.MethodGet, srv.URL+"/v1/admin/templates", nil)
+	req.Header.Set("Authorization", "Bearer admin")
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Ошибка запроса: %v", err)
+	}
+	defer resp.Body.Close()
+	var versions []templates.Version
+	if err := json.NewDecoder(resp.Body).Decode(&versions); err != nil || len(versions) != 1 || versions[0].String() != "welcome@v1" {
+		t.Fatalf("неверные версии шаблонов: %+v, %v", versions, err)
+	}
+}
+
+func TestAdminImport(t *testing.T) {
+	log := delivery.NewMemoryLog()
+	suppressed := suppression.NewMemoryStore()
+	im := backfill.New(log, slog.Default())
+	im.SetSuppression(suppressed)
+	s := server.New(notify.NewRegistry(), log, "secret", slog.Default())
+	s.SetReloader(reload.New(reload.Config{}, slog.Default()), "admin")
+	s.SetImporter(im)
+
+	srv := httptest.NewServer(s.Handler())
+	defer srv.Close()
+
+	history := "id,channel,email,status,created_at\nold-1,email,a@example.com,delivered,2024-05-01T10:00:00Z\n" +
+		"old-2,email,b@example.com,bounced,2024-05-02T10:00:00Z\nold-3,email,c@example.com,queued,2024-05-03T10:00:00Z\n"
+	req, _ := http.NewRequest(http.MethodPost, srv.URL+"/v1/admin/import?format=csv&column=recipient=email", strings.NewReader(history))
+	req.Header.Set("Authorization", "Bearer admin")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Ошибка запроса: %v", err)
+	}
+	defer resp.Body.Close()
+	var got struct {
+		Read       int `json:"read"`
+		Imported   int `json:"imported"`
+		Suppressed int `json:"suppressed"`
+		Errors     []struct {
+			Row int `json:"row"`
+		} `json:"errors"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("ожидался 200 с итогом импорта, получен %d: %v", resp.StatusCode, err)
+	}
+	if got.Read != 3 || got.Imported != 2 || got.Suppressed != 1 || len(got.Errors) != 1 || got.Errors[0].Row != 3 {
+		t.Fatalf("неожиданный итог импорта: %+v", got)
+	}
+	if ok, _ := suppressed.IsSuppressed(context.Background(), "email", "b@example.com", ""); !ok {
+		t.Fatal("адрес с отказом должен попасть в список подавления")
+	}
+
+	req, _ = http.NewRequest(http.MethodPost, srv.URL+"/v1/admin/import?format=xml", strings.NewReader(history))
+	req.Header.Set("Authorization", "Bearer admin")
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Ошибка запроса: %v", err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("неизвестный формат должен отклоняться с 400, получен %d", resp.StatusCode)
+	}
+}
+
+func TestNotificationLatency(t *testing.T) {
+	log := delivery.NewMemoryLog()
+	registry := notify.NewRegistry()
+	tracker := latency.NewTracker(0)
+	registry.Register(latency.Wrap(&fakeSender{log: log}, tracker, slog.Default()))
+
+	s := server.New(registry, log, "", slog.Default())
+	s.SetLatency(tracker)
+	srv := httptest.NewServer(s.Handler())
+	defer srv.Close()
+
+	resp, err := http.Post(srv.URL+"/v1/notifications", "application/json", strings.NewReader(`{"channel":"fake","to":"42","text":"привет","slo":"вчера"}`))
+	if err != nil {
+		t.Fatalf("Ошибка запроса: %v", err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("некорректный slo должен отклоняться с 400, получен %d", resp.StatusCode)
+	}
+
+	resp, err = http.Post(srv.URL+"/v1/notifications", "application/json", strings.NewReader(`{"channel":"fake","to":"42","text":"привет","slo":"1ns"}`))
+	if err != nil {
+		t.Fatalf("Ошибка запроса: %v", err)
+	}
+	var sent struct {
+		LatencyMs float64 `json:"latency_ms"`
+		Late      bool    `json:"late"`
+	}
+	_ = json.NewDecoder(resp.Body).Decode(&sent)
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || !sent.Late || sent.LatencyMs <= 0 {
+		t.Fatalf("сообщение должно быть отмечено опоздавшим: %d %+v", resp.StatusCode, sent)
+	}
+
+	resp, err = http.Get(srv.URL + "/v1/latency")
+	if err != nil {
+		t.Fatalf("Ошибка запроса: %v", err)
+	}
+	var stats struct {
+		Channels []struct {
+			Channel string `json:"channel"`
+			Count   int64  `json:"count"`
+			Late    int64  `json:"late"`
+		} `json:"channels"`
+	}
+	_ = json.NewDecoder(resp.Body).Decode(&stats)
+	_ = resp.Body.Close()
+	if len(stats.Channels) != 1 || stats.Channels[0].Count != 1 || stats.Channels[0].Late != 1 {
+		t.Fatalf("неожиданная статистика задержки: %+v", stats)
+	}
+}
+
+func TestNotificationByUserID(t *testing.T) {
+	log := delivery.NewMemoryLog()
+	registry := notify.NewRegistry()
+	registry.Register(&fakeSender{log: log})
+	recipients := notify.NewMemoryRecipientStore()
+	_ = recipients.Put(context.Background(), notify.Recipient{
+		ID:          "u1",
+		Addresses:   map[string]string{"email": "u1@example.com", "fake": "42"},
+		Preferences: notify.Preferences{Channels: []string{"email", "fake"}, MutedCategories: []string{"marketing"}},
+	})
+	registry.SetRecipients(recipients)
+
+	srv := httptest.NewServer(server.New(registry, log, "", slog.Default()).Handler())
+	defer srv.Close()
+
+	send := func(body string) (int, string) {
+		resp, err := http.Post(srv.URL+"/v1/notifications", "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatalf("Ошибка запроса: %v", err)
+		}
+		defer func() { _ = resp.Body.Close() }()
+		var sent struct {
+			ID string `json:"id"`
+		}
+		_ = json.NewDecoder(resp.Body).Decode(&sent)
+		return resp.StatusCode, sent.ID
+	}
+
+	// Канал email не зарегистрирован, поэтому выбирается следующий по предпочтению
+	code, id := send(`{"user_id":"u1","text":"привет"}`)
+	if code != http.StatusOK {
+		t.Fatalf("ожидался 200, получен %d", code)
+	}
+	rec, err := log.Get(context.Background(), id)
+	if err != nil || rec.Recipient != "42" {
+		t.Fatalf("адрес должен браться из каталога: %+v, %v", rec, err)
+	}
+
+	if code, _ := send(`{"user_id":"u2","text":"привет"}`); code != http.StatusNotFound {
+		t.Fatalf("неизвестный получатель должен отклоняться с 404, получен %d", code)
+	}
+	if code, _ := send(`{"user_id":"u1","text":"скидки","category":"marketing"}`); code != http.StatusBadRequest {
+		t.Fatalf("отключённая категория должна отклоняться с 400, получен %d", code)
+	}
+}
+
+func TestPreferences(t *testing.T) {
+	log := delivery.NewMemoryLog()
+	registry := notify.NewRegistry()
+	prefs := preferences.NewPolicy(preferences.NewMemoryStore(), "marketing")
+	registry.Register(preferences.Wrap(&fakeSender{log: log}, prefs, slog.Default()))
+
+	s := server.New(registry, log, "", slog.Default())
+	s.SetPreferences(prefs)
+	srv := httptest.NewServer(s.Handler())
+	defer srv.Close()
+
+	do := func(method, path, body string) *http.Response {
+		req, _ := http.NewRequest(method, srv.URL+path, strings.NewReader(body))
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Ошибка запроса: %v", err)
+		}
+		return resp
+	}
+
+	send := `{"channel":"fake","to":"42","user_id":"u1","text":"скидки","category":"marketing"}`
+	resp := do(http.MethodPost, "/v1/notifications", send)
+	_ = resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		t.Fatal("категория с обязательным согласием не должна отправляться без него")
+	}
+
+	resp = do(http.MethodPut, "/v1/preferences/u1", `{"category":"marketing"}`)
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("запрос без allowed должен отклоняться с 400, получен %d", resp.StatusCode)
+	}
+	resp = do(http.MethodPut, "/v1/preferences/u1", `{"category":"marketing","allowed":true}`)
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("ожидался 204, получен %d", resp.StatusCode)
+	}
+
+	resp = do(http.MethodPost, "/v1/notifications", send)
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("после согласия ожидался 200, получен %d", resp.StatusCode)
+	}
+
+	resp = do(http.MethodGet, "/v1/preferences/u1", "")
+	defer func() { _ = resp.Body.Close() }()
+	var got struct {
+		Preferences []preferences.Preference `json:"preferences"`
+	}
+	_ = json.NewDecoder(resp.Body).Decode(&got)
+	if len(got.Preferences) != 1 || !got.Preferences[0].Allowed {
+		t.Fatalf("неожиданные согласия: %+v", got.Preferences)
+	}
+}
+
+func TestUnsubscribe(t *testing.T) {
+	log := delivery.NewMemoryLog()
+	signer, _ := unsubscribe.NewSigner([]byte(strings.Repeat("k", 32)), "https://example.com/unsubscribe", 0)
+	store := suppression.NewMemoryStore()
+
+	s := server.New(notify.NewRegistry(), log, "secret", slog.Default())
+	s.SetUnsubscribe(unsubscribe.Handler(signer, store, slog.Default()))
+	srv := httptest.NewServer(s.Handler())
+	defer srv.Close()
+
+	// Ссылку открывает почтовый клиент получателя, у которого нет токена API
+	token := url.QueryEscape(signer.Token("user@example.com", ""))
+	resp, err := http.Post(srv.URL+"/unsubscribe?token="+token, "application/x-www-form-urlencoded", strings.NewReader("List-Unsubscribe=One-Click"))
+	if err != nil {
+		t.Fatalf("Ошибка запроса: %v", err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("ожидался 200, получен %d", resp.StatusCode)
+	}
+	if ok, _ := store.IsSuppressed(context.Background(), "email", "user@example.com", ""); !ok {
+		t.Fatal("адрес не попал в список подавления")
+	}
+}
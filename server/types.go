@@ -0,0 +1,176 @@
+package server
+
+import (
+	"time"
+
+	"github.com/epheer/notephee/degrade"
+	"github.com/epheer/notephee/delivery"
+	"github.com/epheer/notephee/notify"
+	"github.com/epheer/notephee/preferences"
+)
+
+// notificationRequest — тело POST /v1/notifications.
+type notificationRequest struct {
+	Channel  string `json:"channel"`             // Канал отправки: telegram, email (без to может быть пустым)
+	To       string `json:"to"`                  // Адрес получателя в канале (пусто — из каталога по user_id)
+	UserID   string `json:"user_id,omitempty"`   // Внутренний ID пользователя
+	Subject  string `json:"subject,omitempty"`   // Тема (для email)
+	Text     string `json:"text"`                // Текст сообщения
+	Campaign string `json:"campaign,omitempty"`  // Идентификатор рассылки
+	DedupKey string `json:"dedup_key,omitempty"` // Ключ дедупликации
+	Priority string `json:"priority,omitempty"`  // Приоритет: low, normal, high
+	Category string `json:"category,omitempty"`  // Категория уведомления
+	Identity string `json:"identity,omitempty"`  // Личность отправителя
+	SLO      string `json:"slo,omitempty"`       // Допустимая задержка доставки, например 5s
+
+	Notification *notify.Notification `json:"notification,omitempty"` // Структурированное уведомление вместо subject и text
+}
+
+func (r notificationRequest) message() notify.Message {
+	msg := notify.Message{Subject: r.Subject, Text: r.Text, Priority: notify.Priority(r.Priority)}
+	if r.Notification != nil {
+		msg = r.Notification.Message("")
+		if r.Priority != "" {
+			msg.Priority = notify.Priority(r.Priority)
+		}
+	}
+	msg.To = r.To
+	msg.UserID = r.UserID
+	msg.Campaign = r.Campaign
+	msg.DedupKey = r.DedupKey
+	msg.Category = r.Category
+	msg.Identity = r.Identity
+	return msg
+}
+
+// broadcastRequest — тело POST /v1/broadcasts.
+type broadcastRequest struct {
+	Channel    string   `json:"channel"`             // Канал отправки
+	Recipients []string `json:"recipients"`          // Адреса получателей
+	Subject    string   `json:"subject,omitempty"`   // Тема (для email)
+	Text       string   `json:"text"`                // Текст сообщения
+	Campaign   string   `json:"campaign,omitempty"`  // Идентификатор рассылки
+	UserID     string   `json:"user_id,omitempty"`   // Внутренний ID пользователя
+	DedupKey   string   `json:"dedup_key,omitempty"` // Ключ дедупликации
+	Priority   string   `json:"priority,omitempty"`  // Приоритет: low, normal, high
+	Category   string   `json:"category,omitempty"`  // Категория уведомления
+	Identity   string   `json:"identity,omitempty"`  // Личность отправителя
+}
+
+// sendResponse — ответ на POST /v1/notifications.
+type sendResponse struct {
+	ID        string  `json:"id"`                   // Идентификатор записи в журнале доставки
+	Status    string  `json:"status"`               // sent или failed
+	Error     string  `json:"error,omitempty"`      // Текст ошибки
+	LatencyMs float64 `json:"latency_ms,omitempty"` // Задержка от приёма запроса до приёма провайдером
+	Late      bool    `json:"late,omitempty"`       // Задержка превысила slo запроса
+}
+
+// preferenceRequest — тело PUT /v1/preferences/{subject}.
+type preferenceRequest struct {
+	Channel  string `json:"channel,omitempty"` // Канал (пусто — все каналы)
+	Category string `json:"category"`          // Категория уведомлений
+	Allowed  *bool  `json:"allowed"`           // true — согласие, false — отказ
+}
+
+// preferencesResponse — ответ на GET /v1/preferences/{subject}.
+type preferencesResponse struct {
+	Preferences []preferences.Preference `json:"preferences"`
+}
+
+// latencyResponse — ответ на GET /v1/latency.
+type latencyResponse struct {
+	Channels []latencyStats `json:"channels"`
+}
+
+// latencyStats — сквозная задержка канала в миллисекундах.
+type latencyStats struct {
+	Channel string  `json:"channel"`
+	Count   int64   `json:"count"` // Успешных отправок с момента запуска
+	Late    int64   `json:"late"`  // Из них доставлено позже slo
+	P50Ms   float64 `json:"p50_ms"`
+	P95Ms   float64 `json:"p95_ms"`
+	P99Ms   float64 `json:"p99_ms"`
+}
+
+// broadcastDelivery связывает получателя рассылки с идентификатором записи в журнале доставки.
+type broadcastDelivery struct {
+	Recipient string `json:"recipient"`
+	ID        string `json:"id"`
+}
+
+// broadcastResponse — ответ на POST /v1/broadcasts.
+type broadcastResponse struct {
+	ID         string              `json:"id"`         // Идентификатор рассылки
+	Deliveries []broadcastDelivery `json:"deliveries"` // Идентификаторы доставок по получателям
+}
+
+// deliveryResponse — ответ на GET /v1/deliveries/{id}.
+type deliveryResponse struct {
+	ID          string    `json:"id"`
+	Channel     string    `json:"channel"`
+	UserID      string    `json:"user_id,omitempty"`
+	Recipient   string    `json:"recipient"`
+	MessageHash string    `json:"message_hash"`
+	Template    string    `json:"template,omitempty"`
+	Status      string    `json:"status"`
+	Error       string    `json:"error,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	CompletedAt time.Time `json:"completed_at"`
+
+	Engagements []engagementResponse `json:"engagements,omitempty"` // Открытия и переходы, если отслеживаются
+}
+
+// engagementResponse — открытие письма или переход по ссылке в ответе GET /v1/deliveries/{id}.
+type engagementResponse struct {
+	Action string    `json:"action"`
+	URL    string    `json:"url,omitempty"`
+	Time   time.Time `json:"time"`
+}
+
+func newDeliveryResponse(rec delivery.Record) deliveryResponse {
+	return deliveryResponse{
+		ID:          rec.ID,
+		Channel:     rec.Channel,
+		UserID:      rec.UserID,
+		Recipient:   rec.Recipient,
+		MessageHash: rec.MessageHash,
+		Template:    rec.Template,
+		Status:      string(rec.Status),
+		Error:       rec.Error,
+		CreatedAt:   rec.CreatedAt,
+		CompletedAt: rec.CompletedAt,
+	}
+}
+
+// healthResponse — ответ на /healthz и /readyz.
+type healthResponse struct {
+	Status   string           `json:"status"`
+	Channels []string         `json:"channels,omitempty"`
+	Health   []degrade.Health `json:"health,omitempty"`
+}
+
+// errorResponse — тело ответа с ошибкой.
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// channelsResponse — ответ на GET /v1/channels.
+type channelsResponse struct {
+	Channels []notify.Capabilities `json:"channels"` // Каналы в алфавитном порядке
+}
+
+// importResponse — ответ на POST /v1/admin/import.
+type importResponse struct {
+	Read       int           `json:"read"`             // Прочитано записей
+	Imported   int           `json:"imported"`         // Записано в журнал доставки
+	Skipped    int           `json:"skipped"`          // Уже были в журнале
+	Suppressed int           `json:"suppressed"`       // Адресов добавлено в список подавления
+	Errors     []importError `json:"errors,omitempty"` // Пропущенные записи
+}
+
+// importError — запись истории, пропущенная из-за ошибки.
+type importError struct {
+	Row   int    `json:"row"`   // Номер записи в файле, начиная с 1
+	Error string `json:"error"` // Причина
+}
@@ -0,0 +1,69 @@
+package slack
+
+// Text — текстовый объект Block Kit.
+type Text struct {
+	Type string `json:"type"` // plain_text или mrkdwn
+	Text string `json:"text"` // Содержимое
+}
+
+// Block — блок Block Kit. Поддерживаются основные типы: header, section, divider, context.
+type Block struct {
+	Type     string `json:"type"`               // Тип блока
+	BlockID  string `json:"block_id,omitempty"` // Идентификатор блока (необязательно)
+	Text     *Text  `json:"text,omitempty"`     // Текст для header и section
+	Fields   []Text `json:"fields,omitempty"`   // Колонки section
+	Elements []Text `json:"elements,omitempty"` // Элементы context
+}
+
+// Attachment — вложение сообщения Slack (цветная полоса слева, заголовок, поля).
+type Attachment struct {
+	Color     string            `json:"color,omitempty"`      // Цвет полосы: good, warning, danger или #RRGGBB
+	Fallback  string            `json:"fallback,omitempty"`   // Текст для уведомлений без поддержки вложений
+	Pretext   string            `json:"pretext,omitempty"`    // Текст над вложением
+	Title     string            `json:"title,omitempty"`      // Заголовок
+	TitleLink string            `json:"title_link,omitempty"` // Ссылка заголовка
+	Text      string            `json:"text,omitempty"`       // Основной текст
+	Fields    []AttachmentField `json:"fields,omitempty"`     // Поля «название — значение»
+	Footer    string            `json:"footer,omitempty"`     // Подпись внизу
+	Blocks    []Block           `json:"blocks,omitempty"`     // Блоки внутри вложения
+}
+
+// AttachmentField — поле вложения.
+type AttachmentField struct {
+	Title string `json:"title"`           // Название
+	Value string `json:"value"`           // Значение
+	Short bool   `json:"short,omitempty"` // Можно ли выводить в две колонки
+}
+
+// Header возвращает блок-заголовок с простым текстом.
+func Header(text string) Block {
+	return Block{Type: "header", Text: &Text{Type: "plain_text", Text: text}}
+}
+
+// Section возвращает блок с текстом в разметке mrkdwn.
+func Section(markdown string) Block {
+	return Block{Type: "section", Text: &Text{Type: "mrkdwn", Text: markdown}}
+}
+
+// Fields возвращает section-блок с колонками в разметке mrkdwn.
+func Fields(markdown ...string) Block {
+	b := Block{Type: "section"}
+	for _, f := range markdown {
+		b.Fields = append(b.Fields, Text{Type: "mrkdwn", Text: f})
+	}
+	return b
+}
+
+// Context возвращает блок с мелким поясняющим текстом.
+func Context(markdown ...string) Block {
+	b := Block{Type: "context"}
+	for _, e := range markdown {
+		b.Elements = append(b.Elements, Text{Type: "mrkdwn", Text: e})
+	}
+	return b
+}
+
+// Divider возвращает блок-разделитель.
+func Divider() Block {
+	return Block{Type: "divider"}
+}
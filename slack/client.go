@@ -0,0 +1,323 @@
+// Package slack реализует канал Slack: входящие вебхуки и метод chat.postMessage с токеном бота.
+package slack
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	"github.com/epheer/notephee/config"
+	"github.com/epheer/notephee/delivery"
+	"github.com/epheer/notephee/notify"
+	"github.com/epheer/notephee/tracing"
+)
+
+// Channel — имя канала Slack в notify.Registry и журнале доставки.
+const Channel = "slack"
+
+// PostMessage — метод Web API для отправки сообщения от имени бота.
+const PostMessage = "/chat.postMessage"
+
+// MaxText — длина текста, после которой Slack обрезает сообщение.
+const MaxText = 40000
+
+// MessageOptions содержит параметры одного сообщения Slack.
+type MessageOptions struct {
+	Channel     string       `json:"channel,omitempty"`     // ID канала или пользователя; для вебхука не нужен
+	Text        string       `json:"text"`                  // Текст; при наличии блоков — запасной текст уведомления
+	Blocks      []Block      `json:"blocks,omitempty"`      // Блоки Block Kit (необязательно)
+	Attachments []Attachment `json:"attachments,omitempty"` // Вложения (необязательно)
+	ThreadTS    string       `json:"thread_ts,omitempty"`   // Ответ в тред (необязательно)
+	UserID      string       `json:"-"`                     // Внутренний ID пользователя для журнала доставки (необязательно)
+	ID          string       `json:"-"`                     // Идентификатор попытки в журнале доставки (генерируется, если пуст)
+}
+
+// SendingOptions используется для отправки одного сообщения в несколько каналов Slack.
+type SendingOptions struct {
+	Channels    []string     // ID каналов
+	Text        string       // Текст сообщения
+	Blocks      []Block      // Блоки Block Kit (необязательно)
+	Attachments []Attachment // Вложения (необязательно)
+}
+
+// Response — ответ Slack Web API. Для вебхуков заполняется только OK и Error.
+type Response struct {
+	OK      bool   `json:"ok"`                // Успешность запроса
+	Error   string `json:"error,omitempty"`   // Код ошибки Slack (например, channel_not_found)
+	Channel string `json:"channel,omitempty"` // ID канала, куда ушло сообщение
+	TS      string `json:"ts,omitempty"`      // Временная метка сообщения (его идентификатор)
+}
+
+// SendResult представляет результат отправки в один канал.
+type SendResult struct {
+	Channel  string    // ID канала
+	Index    int       // Номер канала в SendingOptions.Channels
+	Response *Response // Ответ Slack
+	Error    error     // Ошибка, если произошла
+}
+
+// Client инкапсулирует клиента Slack.
+//
+// Если задан токен бота, сообщения отправляются через chat.postMessage в любой канал,
+// куда бот приглашён; иначе — через входящий вебхук в привязанный к нему канал.
+type Client struct {
+	webhook string       // URL входящего вебхука
+	token   string       // Токен бота xoxb-...
+	uri     string       // Базовый URL Web API
+	http    *http.Client // HTTP-клиент
+	logger  *slog.Logger // Логгер
+	Enabled bool         // Флаг доступности функционала
+
+	deliveryLog delivery.DeliveryLog // Журнал попыток отправки (необязательно)
+	inflight    notify.InFlight      // Начатые отправки, которых ждёт Close
+}
+
+// NewClient создаёт клиента Slack.
+//
+// cfg — конфигурация приложения с URL вебхука и/или токеном бота.
+// logger — логгер для ведения журнала.
+func NewClient(cfg *config.Config, logger *slog.Logger) *Client {
+	return &Client{
+		webhook: cfg.SlackWebhookURL,
+		token:   cfg.SlackToken,
+		uri:     "https://slack.com/api",
+		http:    &http.Client{Timeout: 10 * time.Second},
+		logger:  logger,
+		Enabled: cfg.IsSlackEnabled(),
+	}
+}
+
+// SetDeliveryLog подключает журнал, в который записывается каждая попытка отправки.
+func (c *Client) SetDeliveryLog(log delivery.DeliveryLog) {
+	c.deliveryLog = log
+}
+
+// Close перестаёт принимать новые отправки и ждёт завершения начатых до истечения ctx.
+// Отправки после Close возвращают notify.ErrClosed.
+func (c *Client) Close(ctx context.Context) error {
+	return c.inflight.Close(ctx)
+}
+
+// Channel возвращает имя канала для notify.Registry.
+func (c *Client) Channel() string {
+	return Channel
+}
+
+// Capabilities возвращает возможности канала: разметка mrkdwn и тема блоком-заголовком.
+func (c *Client) Capabilities() notify.Capabilities {
+	return notify.Capabilities{Channel: Channel, Subject: true, Markdown: true, MaxLength: MaxText}
+}
+
+// Send реализует notify.Sender: msg.To — ID канала Slack (для вебхука может быть пустым).
+// Если задана тема, она выводится блоком-заголовком над текстом, а уведомление из msg.Notification —
+// блоками по RenderNotification.
+func (c *Client) Send(ctx context.Context, msg notify.Message) error {
+	ctx = delivery.WithTemplate(ctx, msg.Template)
+	options := MessageOptions{Text: msg.Text}
+	switch {
+	case msg.Notification != nil:
+		options = RenderNotification(*msg.Notification)
+	case msg.Subject != "":
+		options.Blocks = []Block{Header(msg.Subject), Section(msg.Text)}
+	}
+	options.Channel = msg.To
+	options.UserID = msg.UserID
+	options.ID = msg.ID
+
+	_, err := c.sendText(ctx, options)
+	return err
+}
+
+// SendText отправляет одно сообщение.
+func (c *Client) SendText(options MessageOptions) (Response, error) {
+	return c.sendText(context.Background(), options)
+}
+
+func (c *Client) sendText(ctx context.Context, options MessageOptions) (Response, error) {
+	if !c.Enabled {
+		return Response{}, fmt.Errorf("функционал Slack отключён: некорректная конфигурация")
+	}
+	if err := c.inflight.Acquire(); err != nil {
+		return Response{}, err
+	}
+	defer c.inflight.Release()
+
+	data, err := json.Marshal(options)
+	if err != nil {
+		return Response{}, err
+	}
+
+	ctx, span := tracing.Start(ctx, Channel, options.Channel)
+	started := time.Now()
+	var res Response
+	if c.token != "" {
+		res, err = c.postMessage(ctx, data)
+	} else {
+		res, err = c.postWebhook(ctx, data)
+	}
+	tracing.End(span, err)
+	c.logDelivery(ctx, options, started, err)
+	return res, err
+}
+
+// postMessage отправляет сообщение методом chat.postMessage.
+func (c *Client) postMessage(ctx context.Context, data []byte) (Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.uri+PostMessage, bytes.NewReader(data))
+	if err != nil {
+		return Response{}, err
+	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	req.Header.Set("Authorization", "Bearer "+c.token)
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return Response{}, err
+	}
+	tracing.HTTPStatus(ctx, resp.StatusCode)
+	defer func(Body io.ReadCloser) {
+		_ = Body.Close()
+	}(resp.Body)
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return Response{}, fmt.Errorf("ошибка Slack API: превышен лимит запросов (повторите через %s секунд)", resp.Header.Get("Retry-After"))
+	}
+
+	var res Response
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return Response{}, fmt.Errorf("некорректный формат JSON: %w", err)
+	}
+	if !res.OK {
+		return res, fmt.Errorf("ошибка Slack API: %s", res.Error)
+	}
+	return res, nil
+}
+
+// postWebhook отправляет сообщение во входящий вебхук. Slack отвечает на него
+// не JSON, а текстом: «ok» при успехе или кодом ошибки.
+func (c *Client) postWebhook(ctx context.Context, data []byte) (Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.webhook, bytes.NewReader(data))
+	if err != nil {
+		return Response{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return Response{}, err
+	}
+	tracing.HTTPStatus(ctx, resp.StatusCode)
+	defer func(Body io.ReadCloser) {
+		_ = Body.Close()
+	}(resp.Body)
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	text := strings.TrimSpace(string(body))
+	if resp.StatusCode != http.StatusOK {
+		if resp.StatusCode == http.StatusTooManyRequests {
+			text = fmt.Sprintf("превышен лимит запросов (повторите через %s секунд)", resp.Header.Get("Retry-After"))
+		}
+		return Response{Error: text}, fmt.Errorf("ошибка вебхука Slack: код %d: %s", resp.StatusCode, text)
+	}
+	return Response{OK: true}, nil
+}
+
+// logDelivery записывает попытку отправки в журнал доставки, если он подключён.
+func (c *Client) logDelivery(ctx context.Context, options MessageOptions, started time.Time, sendErr error) {
+	delivery.LogAttempt(ctx, c.deliveryLog, c.logger, delivery.Record{
+		ID:          options.ID,
+		Channel:     Channel,
+		UserID:      options.UserID,
+		Recipient:   options.Channel,
+		MessageHash: delivery.Hash(options.Text),
+		CreatedAt:   started,
+	}, sendErr)
+}
+
+// SendMessaging отправляет одно и то же сообщение в несколько каналов с соблюдением rate limit.
+//
+// Возвращает срез результатов по каждому каналу в порядке каналов.
+func (c *Client) SendMessaging(options SendingOptions) []SendResult {
+	return c.SendMessagingWithProgress(context.Background(), options, nil)
+}
+
+// SendMessagingWithProgress работает как SendMessaging, но после каждой отправки вызывает onProgress
+// с её результатом и текущими счётчиками. onProgress вызывается последовательно из одной горутины.
+func (c *Client) SendMessagingWithProgress(ctx context.Context, options SendingOptions, onProgress func(SendResult, notify.Progress)) []SendResult {
+	results := make([]SendResult, 0, len(options.Channels))
+	progress := notify.Progress{Total: len(options.Channels)}
+
+	for res := range c.SendMessagingStream(ctx, options) {
+		if res.Error != nil {
+			progress.Failed++
+		} else {
+			progress.Sent++
+		}
+		results = append(results, res)
+		if onProgress != nil {
+			onProgress(res, progress)
+		}
+	}
+	// Результаты приходят по мере завершения отправок, а возвращаются в порядке получателей
+	slices.SortFunc(results, func(a, b SendResult) int { return cmp.Compare(a.Index, b.Index) })
+	return results
+}
+
+// SendMessagingStream запускает отправку в несколько каналов и возвращает канал результатов,
+// который закрывается после обработки всех получателей.
+//
+// Slack допускает около одного сообщения в секунду на канал с короткими всплесками,
+// поэтому лимитер пропускает одно сообщение в секунду с запасом на всплеск.
+func (c *Client) SendMessagingStream(ctx context.Context, options SendingOptions) <-chan SendResult {
+	out := make(chan SendResult, len(options.Channels))
+
+	if !c.Enabled {
+		c.logger.Warn("отправка сообщений Slack отключена: возвращаем заглушку")
+		for i, ch := range options.Channels {
+			out <- SendResult{Channel: ch, Index: i, Error: fmt.Errorf("функционал Slack отключён")}
+		}
+		close(out)
+		return out
+	}
+
+	limiter := rate.NewLimiter(rate.Every(time.Second), 3)
+
+	var wg sync.WaitGroup
+	for i, ch := range options.Channels {
+		wg.Add(1)
+
+		go func(i int, ch string) {
+			defer wg.Done()
+
+			if err := limiter.Wait(ctx); err != nil {
+				c.logger.Error("лимитер не пропустил", "channel", ch, "error", err)
+				out <- SendResult{Channel: ch, Index: i, Error: err}
+				return
+			}
+
+			resp, err := c.sendText(ctx, MessageOptions{
+				Channel:     ch,
+				Text:        options.Text,
+				Blocks:      options.Blocks,
+				Attachments: options.Attachments,
+			})
+			out <- SendResult{Channel: ch, Index: i, Response: &resp, Error: err}
+		}(i, ch)
+	}
+
+	go func() {
+		wg.Wait()
+		close(out)
+	}()
+	return out
+}
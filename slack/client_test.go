@@ -0,0 +1,144 @@
+package slack
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/epheer/notephee/config"
+	"github.com/epheer/notephee/notify"
+)
+
+func TestPostMessageWithToken(t *testing.T) {
+	var got MessageOptions
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != PostMessage || r.Header.Get("Authorization") != "Bearer xoxb-test" {
+			t.Errorf("неожиданный запрос: %s %s", r.URL.Path, r.Header.Get("Authorization"))
+		}
+		_ = json.NewDecoder(r.Body).Decode(&got)
+		if got.Channel == "C404" {
+			_, _ = w.Write([]byte(`{"ok":false,"error":"channel_not_found"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"ok":true,"channel":"C1","ts":"1700000000.000100"}`))
+	}))
+	t.Cleanup(srv.Close)
+
+	c := NewClient(&config.Config{SlackToken: "xoxb-test"}, slog.Default())
+	c.uri = srv.URL
+
+	err := c.Send(context.Background(), notify.Message{To: "C1", Subject: "Деплой", Text: "готово"})
+	if err != nil {
+		t.Fatalf("Ошибка Send: %v", err)
+	}
+	if len(got.Blocks) != 2 || got.Blocks[0].Type != "header" || got.Text != "готово" {
+		t.Fatalf("неожиданное тело запроса: %+v", got)
+	}
+
+	results := c.SendMessaging(SendingOptions{Channels: []string{"C1", "C404"}, Text: "привет"})
+	failed := 0
+	for _, res := range results {
+		if res.Error != nil {
+			failed++
+		}
+	}
+	if len(results) != 2 || failed != 1 {
+		t.Fatalf("ожидалась одна ошибка из двух отправок, получено %d из %d", failed, len(results))
+	}
+}
+
+func TestRenderNotification(t *testing.T) {
+	n := notify.Notification{
+		Title:    "Деплой завершён",
+		Body:     "Версия <1.4> выкачена",
+		Actions:  []notify.Action{{Label: "Журнал", URL: "https://ci.example.com/42"}},
+		Metadata: map[string]string{"сервис": "api"},
+	}
+	got := RenderNotification(n)
+	if got.Text != n.Text() || len(got.Blocks) != 5 || got.Blocks[0].Text.Text != "ℹ️ Деплой завершён" {
+		t.Fatalf("неожиданные блоки: %+v", got)
+	}
+	if got.Blocks[1].Text.Text != "Версия &lt;1.4&gt; выкачена" || got.Blocks[2].Fields[0].Text != "*сервис*\napi" ||
+		got.Blocks[4].Text.Text != "<https://ci.example.com/42|Журнал>" {
+		t.Fatalf("неверное содержимое блоков: %+v", got.Blocks)
+	}
+}
+
+func TestWebhook(t *testing.T) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls > 1 {
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte("invalid_token"))
+			return
+		}
+		_, _ = w.Write([]byte("ok"))
+	}))
+	t.Cleanup(srv.Close)
+
+	c := NewClient(&config.Config{SlackWebhookURL: srv.URL}, slog.Default())
+
+	if _, err := c.SendText(MessageOptions{Text: "привет", Attachments: []Attachment{{Color: "good", Text: "ok"}}}); err != nil {
+		t.Fatalf("Ошибка SendText: %v", err)
+	}
+	res, err := c.SendText(MessageOptions{Text: "привет"})
+	if err == nil || res.Error != "invalid_token" {
+		t.Fatalf("ожидалась ошибка вебхука invalid_token, получено %v", err)
+	}
+}
+
+func TestCloseWaitsForInFlight(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan struct{})
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			close(started)
+			<-release
+		}
+		_, _ = w.Write([]byte(`{"ok":true,"channel":"C1","ts":"1700000000.000100"}`))
+	}))
+	t.Cleanup(srv.Close)
+
+	c := NewClient(&config.Config{SlackToken: "xoxb-test"}, slog.Default())
+	c.uri = srv.URL
+
+	sent := make(chan error, 1)
+	go func() { sent <- c.Send(context.Background(), notify.Message{To: "C1", Text: "x"}) }()
+	<-started
+
+	closed := make(chan error, 1)
+	go func() { closed <- c.Close(context.Background()) }()
+
+	// Close ждёт начатую отправку, а новые отправки уже отклоняются
+	deadline := time.Now().Add(time.Second)
+	for {
+		err := c.Send(context.Background(), notify.Message{To: "C1", Text: "y"})
+		if errors.Is(err, notify.ErrClosed) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("после Close ожидался notify.ErrClosed, получено %v", err)
+		}
+	}
+	select {
+	case err := <-closed:
+		t.Fatalf("Close завершился до окончания отправки: %v", err)
+	default:
+	}
+
+	close(release)
+	if err := <-sent; err != nil {
+		t.Fatalf("начатая отправка должна завершиться успешно: %v", err)
+	}
+	if err := <-closed; err != nil {
+		t.Fatalf("Ошибка Close: %v", err)
+	}
+}
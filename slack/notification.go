@@ -0,0 +1,50 @@
+package slack
+
+import (
+	"strings"
+
+	"github.com/epheer/notephee/notify"
+)
+
+// mrkdwnEscaper экранирует символы, которые Slack разбирает как ссылки и упоминания в mrkdwn.
+var mrkdwnEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// Escape экранирует текст для вставки в mrkdwn: пользовательский ввод не превращается в ссылки
+// и упоминания вроде <!channel>.
+func Escape(s string) string {
+	return mrkdwnEscaper.Replace(s)
+}
+
+// RenderNotification отображает уведомление блоками: заголовок со значком важности, текст, поля Metadata
+// колонками и действия ссылками. Text заполняется notify.Notification.Text для уведомлений на телефоне.
+// Канал и поля журнала доставки заполняет вызывающий.
+func RenderNotification(n notify.Notification) MessageOptions {
+	title := n.Severity.Emoji()
+	if n.Title != "" {
+		title += " " + n.Title
+	}
+	blocks := []Block{Header(title)}
+	if n.Body != "" {
+		blocks = append(blocks, Section(Escape(n.Body)))
+	}
+	if len(n.Metadata) > 0 {
+		fields := make([]string, 0, len(n.Metadata))
+		for _, k := range n.MetadataKeys() {
+			fields = append(fields, "*"+Escape(k)+"*\n"+Escape(n.Metadata[k]))
+		}
+		// Slack принимает не больше 10 колонок в одном section-блоке
+		for len(fields) > 0 {
+			k := min(len(fields), 10)
+			blocks = append(blocks, Fields(fields[:k]...))
+			fields = fields[k:]
+		}
+	}
+	if len(n.Actions) > 0 {
+		links := make([]string, 0, len(n.Actions))
+		for _, a := range n.Actions {
+			links = append(links, "<"+a.URL+"|"+Escape(a.Label)+">")
+		}
+		blocks = append(blocks, Divider(), Section(strings.Join(links, "  ·  ")))
+	}
+	return MessageOptions{Text: n.Text(), Blocks: blocks}
+}
@@ -0,0 +1,326 @@
+// Package spool сохраняет сообщения, которые не успели уйти до остановки процесса,
+// и отправляет их заново после перезапуска.
+//
+// Без него остановка посреди рассылки или с непустой очередью молча теряет сообщения.
+package spool
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/epheer/notephee/broadcast"
+	"github.com/epheer/notephee/delivery"
+	"github.com/epheer/notephee/notify"
+)
+
+// MaxAttempts — число неудачных попыток Replay, после которого сообщение удаляется из хранилища.
+const MaxAttempts = 5
+
+// Entry — неотправленное сообщение вместе с каналом, через который его нужно отправить.
+type Entry struct {
+	ID        string         `json:"id,omitempty"`       // Идентификатор записи в хранилище; задаётся Save
+	Channel   string         `json:"channel"`            // Имя канала в notify.Registry
+	Message   notify.Message `json:"message"`            // Сообщение
+	SpooledAt time.Time      `json:"spooled_at"`         // Время сохранения
+	Attempts  int            `json:"attempts,omitempty"` // Число неудачных попыток отправки в Replay
+}
+
+// Store — хранилище неотправленных сообщений. Сообщение удаляется только после отправки,
+// поэтому падение посреди Replay приводит к повторной отправке, а не к потере.
+type Store interface {
+	// Save сохраняет сообщения, присваивая каждому ID.
+	Save(ctx context.Context, entries []Entry) error
+	// Pending возвращает сохранённые сообщения в порядке сохранения, не удаляя их.
+	Pending(ctx context.Context) ([]Entry, error)
+	// Remove удаляет сообщения с идентификаторами ids. Отсутствующие не считаются ошибкой.
+	Remove(ctx context.Context, ids ...string) error
+	// Update перезаписывает сохранённое сообщение с идентификатором e.ID, сохраняя его место в порядке.
+	Update(ctx context.Context, e Entry) error
+}
+
+// Save сохраняет msgs канала channel в store. Пустой список и nil store ничего не делают.
+func Save(ctx context.Context, store Store, channel string, msgs []notify.Message) error {
+	if store == nil || len(msgs) == 0 {
+		return nil
+	}
+	now := time.Now()
+	entries := make([]Entry, len(msgs))
+	for i, msg := range msgs {
+		entries[i] = Entry{Channel: channel, Message: msg, SpooledAt: now}
+	}
+	return store.Save(ctx, entries)
+}
+
+// Replay отправляет сохранённые сообщения через registry и удаляет каждое сразу после отправки.
+//
+// Сообщения, которые не удалось отправить из-за незарегистрированного канала, закрытого
+// клиента или завершения ctx, остаются в хранилище до следующего запуска. Временные ошибки
+// провайдера (SMTP или Bot API недоступны) тоже оставляют сообщение в хранилище, увеличивая
+// Entry.Attempts; после MaxAttempts попыток оно удаляется. Сообщение удаляется сразу, если
+// ошибка постоянная (broadcast.KindPermanent, получатель отключил уведомления или у него нет
+// адреса) или исход отправки неизвестен: повтор мог бы доставить его дважды, и решение
+// остаётся за журналом доставки.
+func Replay(ctx context.Context, store Store, registry *notify.Registry, logger *slog.Logger) error {
+	entries, err := store.Pending(ctx)
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		return nil
+	}
+	logger.Info("отправка сообщений, сохранённых при остановке", "count", len(entries))
+
+	kept := 0
+	for i, e := range entries {
+		if ctx.Err() != nil {
+			kept += len(entries) - i
+			break
+		}
+		err := registry.Send(ctx, e.Channel, e.Message)
+		switch {
+		case err == nil:
+		case errors.Is(err, notify.ErrUnknownChannel), errors.Is(err, notify.ErrClosed), ctx.Err() != nil:
+			kept++
+			continue
+		case retryable(err) && e.Attempts+1 < MaxAttempts:
+			e.Attempts++
+			logger.Warn("не удалось отправить сохранённое сообщение, повтор при следующем запуске",
+				"channel", e.Channel, "to", e.Message.To, "attempts", e.Attempts, "error", err)
+			if err := store.Update(context.WithoutCancel(ctx), e); err != nil {
+				return fmt.Errorf("не удалось обновить сохранённое сообщение %s: %w", e.ID, err)
+			}
+			kept++
+			continue
+		default:
+			logger.Error("сохранённое сообщение не отправлено и удалено", "channel", e.Channel, "to", e.Message.To,
+				"attempts", e.Attempts+1, "error", err)
+		}
+		if err := store.Remove(context.WithoutCancel(ctx), e.ID); err != nil {
+			return fmt.Errorf("не удалось удалить отправленное сообщение %s: %w", e.ID, err)
+		}
+	}
+	if kept > 0 {
+		logger.Warn("часть сохранённых сообщений отложена до следующего запуска", "count", kept)
+	}
+	return nil
+}
+
+// retryable сообщает, может ли повторная отправка после ошибки err пройти, не доставив сообщение дважды.
+func retryable(err error) bool {
+	if delivery.IsIndeterminate(err) {
+		return false
+	}
+	switch broadcast.Kind(err) {
+	case broadcast.KindPermanent, broadcast.KindMuted, broadcast.KindNoAddress:
+		return false
+	default:
+		return true
+	}
+}
+
+// MemoryStore — Store в памяти процесса. Подходит для тестов:
+// после перезапуска сообщения теряются.
+type MemoryStore struct {
+	mu      sync.Mutex
+	entries []Entry
+}
+
+// NewMemoryStore создаёт пустое хранилище в памяти.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{}
+}
+
+// Save сохраняет сообщения.
+func (s *MemoryStore) Save(_ context.Context, entries []Entry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, e := range entries {
+		e.ID = uuid.New().String()
+		s.entries = append(s.entries, e)
+	}
+	return nil
+}
+
+// Pending возвращает сохранённые сообщения.
+func (s *MemoryStore) Pending(_ context.Context) ([]Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.entries), nil
+}
+
+// Remove удаляет сообщения.
+func (s *MemoryStore) Remove(_ context.Context, ids ...string) error {
+	s.mu.Lock()
+	s.entries = slices.DeleteFunc(s.entries, func(e Entry) bool { return slices.Contains(ids, e.ID) })
+	s.mu.Unlock()
+	return nil
+}
+
+// Update перезаписывает сообщение.
+func (s *MemoryStore) Update(_ context.Context, e Entry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if i := slices.IndexFunc(s.entries, func(cur Entry) bool { return cur.ID == e.ID }); i >= 0 {
+		s.entries[i] = e
+	}
+	return nil
+}
+
+// QuarantineDir — подкаталог FileStore, куда переносятся нечитаемые файлы.
+const QuarantineDir = "quarantine"
+
+// FileStore хранит каждое сообщение в отдельном JSON-файле каталога.
+//
+// Запись атомарна (через временный файл и rename), поэтому сообщения не теряются
+// при падении процесса посреди сохранения. Нечитаемый или повреждённый файл не останавливает
+// отправку остальных: он переносится в подкаталог QuarantineDir для разбора вручную.
+type FileStore struct {
+	dir    string
+	mu     sync.Mutex
+	logger *slog.Logger
+}
+
+// NewFileStore создаёт хранилище в каталоге dir, создавая его при необходимости.
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("не удалось создать каталог неотправленных сообщений %s: %w", dir, err)
+	}
+	return &FileStore{dir: dir, logger: slog.Default()}, nil
+}
+
+// SetLogger задаёт логгер для сообщений о перенесённых в карантин файлах (по умолчанию slog.Default).
+func (s *FileStore) SetLogger(logger *slog.Logger) {
+	s.logger = logger
+}
+
+// Save атомарно записывает каждое сообщение на диск.
+func (s *FileStore) Save(_ context.Context, entries []Entry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Имя начинается со времени и номера в пакете, чтобы Pending возвращал сообщения в порядке сохранения
+	now := time.Now().UnixNano()
+	for i, e := range entries {
+		e.ID = fmt.Sprintf("%020d-%06d-%s", now, i, uuid.New().String())
+		data, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		if err := s.write(e.ID, data); err != nil {
+			return fmt.Errorf("не удалось сохранить неотправленные сообщения: %w", err)
+		}
+	}
+	return nil
+}
+
+// write атомарно записывает файл id.json.
+func (s *FileStore) write(id string, data []byte) error {
+	tmp, err := os.CreateTemp(s.dir, id+".*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), filepath.Join(s.dir, id+".json"))
+}
+
+// Pending читает сохранённые сообщения в порядке сохранения. Нечитаемые файлы переносятся в карантин.
+func (s *FileStore) Pending(_ context.Context) ([]Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	dirEntries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось прочитать каталог неотправленных сообщений: %w", err)
+	}
+
+	var names []string
+	for _, e := range dirEntries {
+		if !e.IsDir() && strings.HasSuffix(e.Name(), ".json") {
+			names = append(names, e.Name())
+		}
+	}
+	sort.Strings(names)
+
+	var out []Entry
+	for _, name := range names {
+		var e Entry
+		data, err := os.ReadFile(filepath.Join(s.dir, name))
+		if err == nil {
+			err = json.Unmarshal(data, &e)
+		}
+		if err != nil {
+			s.quarantine(name, err)
+			continue
+		}
+		e.ID = strings.TrimSuffix(name, ".json")
+		out = append(out, e)
+	}
+	return out, nil
+}
+
+// quarantine переносит нечитаемый файл name в подкаталог QuarantineDir.
+func (s *FileStore) quarantine(name string, cause error) {
+	dir := filepath.Join(s.dir, QuarantineDir)
+	err := os.MkdirAll(dir, 0o755)
+	if err == nil {
+		err = os.Rename(filepath.Join(s.dir, name), filepath.Join(dir, name))
+	}
+	if err != nil {
+		s.logger.Error("не удалось перенести повреждённый файл неотправленных сообщений в карантин", "file", name, "error", err)
+		return
+	}
+	s.logger.Warn("повреждённый файл неотправленных сообщений перенесён в карантин", "file", name, "error", cause)
+}
+
+// Update атомарно перезаписывает файл сообщения; имя файла, а с ним и место в порядке, не меняется.
+func (s *FileStore) Update(_ context.Context, e Entry) error {
+	if filepath.Base(e.ID) != e.ID {
+		return fmt.Errorf("некорректный идентификатор сообщения: %q", e.ID)
+	}
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.write(e.ID, data); err != nil {
+		return fmt.Errorf("не удалось обновить неотправленное сообщение: %w", err)
+	}
+	return nil
+}
+
+// Remove удаляет файлы сообщений.
+func (s *FileStore) Remove(_ context.Context, ids ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, id := range ids {
+		if filepath.Base(id) != id {
+			return fmt.Errorf("некорректный идентификатор сообщения: %q", id)
+		}
+		if err := os.Remove(filepath.Join(s.dir, id+".json")); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+package spool_test
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/epheer/notephee/notify"
+	"github.com/epheer/notephee/spool"
+)
+
+type fakeSender struct {
+	channel string
+	sent    []string
+	err     error
+}
+
+func (s *fakeSender) Channel() string { return s.channel }
+
+func (s *fakeSender) Send(_ context.Context, msg notify.Message) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.sent = append(s.sent, msg.To)
+	return nil
+}
+
+func TestFileStore(t *testing.T) {
+	dir := t.TempDir()
+	store, err := spool.NewFileStore(dir)
+	if err != nil {
+		t.Fatalf("Ошибка NewFileStore: %v", err)
+	}
+
+	ctx := context.Background()
+	if err := spool.Save(ctx, store, "fake", []notify.Message{{ID: "1", To: "a"}, {ID: "2", To: "b"}}); err != nil {
+		t.Fatalf("Ошибка Save: %v", err)
+	}
+	if err := spool.Save(ctx, store, "other", []notify.Message{{ID: "3", To: "c"}}); err != nil {
+		t.Fatalf("Ошибка Save: %v", err)
+	}
+
+	// Новое хранилище в том же каталоге видит сообщения, как после перезапуска
+	reopened, _ := spool.NewFileStore(dir)
+	entries, err := reopened.Pending(ctx)
+	if err != nil {
+		t.Fatalf("Ошибка Pending: %v", err)
+	}
+	if len(entries) != 3 || entries[0].Message.ID != "1" || entries[2].Channel != "other" {
+		t.Fatalf("неожиданные сообщения: %+v", entries)
+	}
+
+	// Удаляется только указанное сообщение
+	if err := reopened.Remove(ctx, entries[0].ID); err != nil {
+		t.Fatalf("Ошибка Remove: %v", err)
+	}
+	entries, _ = reopened.Pending(ctx)
+	if len(entries) != 2 || entries[0].Message.ID != "2" {
+		t.Fatalf("Remove должен удалять только отправленное сообщение, осталось %+v", entries)
+	}
+}
+
+func TestFileStoreQuarantine(t *testing.T) {
+	dir := t.TempDir()
+	store, _ := spool.NewFileStore(dir)
+	ctx := context.Background()
+	_ = spool.Save(ctx, store, "fake", []notify.Message{{ID: "1", To: "a"}})
+	if err := os.WriteFile(filepath.Join(dir, "00000000000000000000-broken.json"), []byte("{"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Повреждённый файл не мешает прочитать остальные и переносится в карантин
+	entries, err := store.Pending(ctx)
+	if err != nil || len(entries) != 1 || entries[0].Message.ID != "1" {
+		t.Fatalf("неожиданный результат Pending: %+v, %v", entries, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, spool.QuarantineDir, "00000000000000000000-broken.json")); err != nil {
+		t.Fatalf("повреждённый файл не перенесён в карантин: %v", err)
+	}
+}
+
+func TestReplay(t *testing.T) {
+	ctx := context.Background()
+	store := spool.NewMemoryStore()
+	_ = spool.Save(ctx, store, "fake", []notify.Message{{To: "a"}, {To: "b"}})
+	_ = spool.Save(ctx, store, "closed", []notify.Message{{To: "c"}})
+	_ = spool.Save(ctx, store, "missing", []notify.Message{{To: "d"}})
+
+	sender := &fakeSender{channel: "fake"}
+	registry := notify.NewRegistry()
+	registry.Register(sender)
+	registry.Register(&fakeSender{channel: "closed", err: notify.ErrClosed})
+
+	if err := spool.Replay(ctx, store, registry, slog.Default()); err != nil {
+		t.Fatalf("Ошибка Replay: %v", err)
+	}
+	if len(sender.sent) != 2 {
+		t.Fatalf("ожидалось 2 отправки, получено %v", sender.sent)
+	}
+
+	// Сообщения закрытого и незарегистрированного каналов остаются до следующего запуска
+	left, _ := store.Pending(ctx)
+	if len(left) != 2 {
+		t.Fatalf("ожидалось 2 отложенных сообщения, получено %+v", left)
+	}
+	for _, e := range left {
+		if e.Channel == "fake" {
+			t.Fatalf("отправленное сообщение не должно остаться в хранилище: %+v", e)
+		}
+	}
+}
+
+// permanentError — постоянная ошибка провайдера, как email.SMTPError с кодом 5xx.
+type permanentError struct{}
+
+func (permanentError) Error() string   { return "550 ящик не существует" }
+func (permanentError) Temporary() bool { return false }
+
+func TestReplayRetries(t *testing.T) {
+	ctx := context.Background()
+	store, err := spool.NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("Ошибка NewFileStore: %v", err)
+	}
+	_ = spool.Save(ctx, store, "down", []notify.Message{{To: "a"}})
+	_ = spool.Save(ctx, store, "rejected", []notify.Message{{To: "b"}})
+
+	registry := notify.NewRegistry()
+	registry.Register(&fakeSender{channel: "down", err: errors.New("соединение с SMTP-сервером отклонено")})
+	registry.Register(&fakeSender{channel: "rejected", err: permanentError{}})
+
+	if err := spool.Replay(ctx, store, registry, slog.Default()); err != nil {
+		t.Fatalf("Ошибка Replay: %v", err)
+	}
+	// Постоянная ошибка удаляет сообщение, временная оставляет его с учётом попытки
+	left, _ := store.Pending(ctx)
+	if len(left) != 1 || left[0].Channel != "down" || left[0].Attempts != 1 {
+		t.Fatalf("ожидалось одно сообщение недоступного канала с одной попыткой, получено %+v", left)
+	}
+
+	for range spool.MaxAttempts - 1 {
+		if err := spool.Replay(ctx, store, registry, slog.Default()); err != nil {
+			t.Fatalf("Ошибка Replay: %v", err)
+		}
+	}
+	if left, _ := store.Pending(ctx); len(left) != 0 {
+		t.Fatalf("после %d попыток сообщение должно быть удалено, осталось %+v", spool.MaxAttempts, left)
+	}
+}
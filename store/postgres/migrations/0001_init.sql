@@ -0,0 +1,59 @@
+-- Привязки чатов Telegram к пользователям (telegram.BindingStore)
+CREATE TABLE notephee_telegram_bindings (
+	chat_id BIGINT PRIMARY KEY,
+	user_id VARCHAR(255) NOT NULL,
+	bot VARCHAR(255) NOT NULL,
+	metadata JSONB NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX notephee_telegram_bindings_user_idx ON notephee_telegram_bindings (user_id);
+
+-- Подписки получателей на категории уведомлений (preferences.Store)
+CREATE TABLE notephee_subscriptions (
+	subject VARCHAR(320) NOT NULL,
+	channel VARCHAR(32) NOT NULL,
+	category VARCHAR(64) NOT NULL,
+	allowed BOOLEAN NOT NULL,
+	updated_at TIMESTAMPTZ NOT NULL,
+	PRIMARY KEY (subject, channel, category)
+);
+
+-- Журнал доставки (delivery.SQLLog) и открытия писем и переходы по ссылкам
+CREATE TABLE notephee_deliveries (
+	id VARCHAR(36) PRIMARY KEY,
+	channel VARCHAR(32) NOT NULL,
+	user_id VARCHAR(255) NOT NULL,
+	recipient VARCHAR(320) NOT NULL,
+	message_hash CHAR(64) NOT NULL,
+	status VARCHAR(16) NOT NULL,
+	error TEXT NOT NULL,
+	created_at TIMESTAMP NOT NULL,
+	completed_at TIMESTAMP NOT NULL
+);
+CREATE INDEX notephee_deliveries_user_idx ON notephee_deliveries (user_id, created_at);
+CREATE INDEX notephee_deliveries_failed_idx ON notephee_deliveries (created_at) WHERE status = 'failed';
+CREATE TABLE notephee_deliveries_engagement (
+	delivery_id VARCHAR(36) NOT NULL,
+	action VARCHAR(16) NOT NULL,
+	url TEXT NOT NULL,
+	created_at TIMESTAMP NOT NULL
+);
+CREATE INDEX notephee_deliveries_engagement_idx ON notephee_deliveries_engagement (delivery_id, created_at);
+
+-- Сообщения, записанные в транзакции приложения и ещё не отправленные (Outbox)
+CREATE TABLE notephee_outbox (
+	id BIGSERIAL PRIMARY KEY,
+	channel VARCHAR(32) NOT NULL,
+	message JSONB NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- Задания массовых рассылок (broadcast.JobStore)
+CREATE TABLE notephee_broadcast_jobs (
+	id VARCHAR(64) PRIMARY KEY,
+	status VARCHAR(16) NOT NULL,
+	job JSONB NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL,
+	updated_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX notephee_broadcast_jobs_unfinished_idx ON notephee_broadcast_jobs (created_at) WHERE status <> 'completed';
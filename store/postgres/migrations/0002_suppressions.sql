@@ -0,0 +1,9 @@
+-- Список подавления (suppression.Store): пустой list — блокировка для всех рассылок
+CREATE TABLE notephee_suppressions (
+	channel VARCHAR(32) NOT NULL,
+	address VARCHAR(320) NOT NULL,
+	list VARCHAR(255) NOT NULL,
+	reason VARCHAR(16) NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL,
+	PRIMARY KEY (channel, address, list)
+);
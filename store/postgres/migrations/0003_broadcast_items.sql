@@ -0,0 +1,15 @@
+-- Получатели и ошибки рассылок хранятся построчно: чекпойнт обновляет только курсор в notephee_broadcast_jobs
+CREATE TABLE notephee_broadcast_recipients (
+	job_id VARCHAR(64) NOT NULL,
+	seq INTEGER NOT NULL,
+	address VARCHAR(320) NOT NULL,
+	PRIMARY KEY (job_id, seq)
+);
+CREATE TABLE notephee_broadcast_failures (
+	job_id VARCHAR(64) NOT NULL,
+	seq INTEGER NOT NULL,
+	recipient VARCHAR(320) NOT NULL,
+	kind VARCHAR(32) NOT NULL,
+	error TEXT NOT NULL,
+	PRIMARY KEY (job_id, seq)
+);
@@ -0,0 +1,166 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/epheer/notephee/ingest"
+	"github.com/epheer/notephee/notify"
+)
+
+// Execer выполняет запрос; его реализуют *sql.DB, *sql.Tx и *sql.Conn.
+type Execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// OutboxOptions — настройки отправки из outbox. Нулевые значения заменяются значениями по умолчанию.
+type OutboxOptions struct {
+	Batch    int           // Сообщений за одну транзакцию; по умолчанию 100
+	Interval time.Duration // Пауза между проверками пустого outbox; по умолчанию 1 секунда
+}
+
+func (o *OutboxOptions) defaults() {
+	if o.Batch <= 0 {
+		o.Batch = 100
+	}
+	if o.Interval <= 0 {
+		o.Interval = time.Second
+	}
+}
+
+// Outbox — таблица notephee_outbox с сообщениями, записанными в транзакции приложения: уведомление
+// уходит, только если транзакция зафиксирована, и не теряется, если процесс упал сразу после неё.
+type Outbox struct {
+	db     *sql.DB
+	opts   OutboxOptions
+	logger *slog.Logger
+}
+
+// NewOutbox создаёт outbox.
+func NewOutbox(db *sql.DB, opts OutboxOptions, logger *slog.Logger) *Outbox {
+	opts.defaults()
+	return &Outbox{db: db, opts: opts, logger: logger}
+}
+
+// Add записывает сообщение канала channel через exec — обычно транзакцию приложения (*sql.Tx),
+// в которой меняются данные, о которых сообщает уведомление.
+func (o *Outbox) Add(ctx context.Context, exec Execer, channel string, msg notify.Message) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	if _, err := exec.ExecContext(ctx, "INSERT INTO notephee_outbox (channel, message) VALUES ($1, $2)", channel, data); err != nil {
+		return fmt.Errorf("не удалось записать сообщение в outbox: %w", err)
+	}
+	return nil
+}
+
+// Send записывает сообщение в outbox вне транзакции приложения; так Outbox реализует ingest.Sink.
+func (o *Outbox) Send(ctx context.Context, channel string, msg notify.Message) error {
+	return o.Add(ctx, o.db, channel, msg)
+}
+
+// Run отправляет сообщения из outbox через sink в порядке записи, пока не отменён ctx. Экземпляры
+// сервиса делят outbox: строки блокируются FOR UPDATE SKIP LOCKED. Сообщение удаляется после отправки;
+// ошибки отправки записываются в журнал доставки клиентом канала. Сообщения незарегистрированного канала,
+// закрытого клиента и прерванные остановкой остаются в outbox. Отмена ctx возвращает nil.
+func (o *Outbox) Run(ctx context.Context, sink ingest.Sink) error {
+	o.logger.Info("отправка сообщений из outbox PostgreSQL")
+	for ctx.Err() == nil {
+		n, err := o.relay(ctx, sink)
+		if err != nil && ctx.Err() == nil {
+			o.logger.Error("не удалось отправить сообщения из outbox", "error", err)
+		}
+		if n == o.opts.Batch {
+			continue
+		}
+		t := time.NewTimer(o.opts.Interval)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+		case <-t.C:
+		}
+	}
+	return nil
+}
+
+// outboxEntry — строка outbox.
+type outboxEntry struct {
+	id      int64
+	channel string
+	message []byte
+}
+
+// relay отправляет одну пачку сообщений и возвращает число удалённых из outbox.
+func (o *Outbox) relay(ctx context.Context, sink ingest.Sink) (int, error) {
+	// Транзакция не прерывается остановкой, чтобы отправленные сообщения были удалены
+	txCtx := context.WithoutCancel(ctx)
+	tx, err := o.db.BeginTx(txCtx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	rows, err := tx.QueryContext(txCtx, `SELECT id, channel, message FROM notephee_outbox
+ORDER BY id LIMIT $1 FOR UPDATE SKIP LOCKED`, o.opts.Batch)
+	if err != nil {
+		return 0, err
+	}
+	var entries []outboxEntry
+	for rows.Next() {
+		var e outboxEntry
+		if err := rows.Scan(&e.id, &e.channel, &e.message); err != nil {
+			_ = rows.Close()
+			return 0, err
+		}
+		entries = append(entries, e)
+	}
+	if err := rows.Close(); err != nil {
+		return 0, err
+	}
+
+	done := 0
+	for _, e := range entries {
+		if ctx.Err() != nil {
+			break
+		}
+		if !o.send(ctx, sink, e) {
+			continue
+		}
+		if _, err := tx.ExecContext(txCtx, "DELETE FROM notephee_outbox WHERE id = $1", e.id); err != nil {
+			return 0, err
+		}
+		done++
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return done, nil
+}
+
+// send отправляет сообщение и сообщает, можно ли удалить его из outbox.
+func (o *Outbox) send(ctx context.Context, sink ingest.Sink, e outboxEntry) bool {
+	var msg notify.Message
+	if err := json.Unmarshal(e.message, &msg); err != nil {
+		o.logger.Error("повреждённое сообщение в outbox", "id", e.id, "error", err)
+		return true
+	}
+	err := sink.Send(ctx, e.channel, msg)
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, notify.ErrUnknownChannel), errors.Is(err, notify.ErrClosed), ctx.Err() != nil:
+		o.logger.Warn("сообщение оставлено в outbox", "channel", e.channel, "id", e.id, "error", err)
+		return false
+	default:
+		o.logger.Warn("не удалось отправить сообщение из outbox", "channel", e.channel, "to", msg.To, "error", err)
+		return true
+	}
+}
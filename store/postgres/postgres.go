@@ -0,0 +1,126 @@
+// Package postgres хранит данные notephee в PostgreSQL: привязки Telegram, подписки получателей,
+// журнал доставки, outbox, задания рассылок и список подавления живут в одной базе, и экземпляры сервиса делят их.
+//
+// Схема создаётся встроенными миграциями (Migrate). Типы принимают *sql.DB, поэтому подключение можно
+// открыть Open (драйвер pgx) или передать своё, например с пулом и TLS из настроек приложения.
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strconv"
+	"strings"
+
+	_ "github.com/jackc/pgx/v5/stdlib" // Драйвер pgx для database/sql
+
+	"github.com/epheer/notephee/delivery"
+)
+
+//go:embed migrations/*.sql
+var migrationFS embed.FS
+
+// migrationLock — ключ pg_advisory_xact_lock, с которым миграции одновременно запущенных экземпляров
+// выполняются по очереди.
+const migrationLock = 7_061_700_000
+
+// Open открывает подключение к базе по строке dsn, например postgres://user:pass@db:5432/notephee.
+func Open(dsn string) (*sql.DB, error) {
+	db, err := sql.Open("pgx", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось открыть базу PostgreSQL: %w", err)
+	}
+	return db, nil
+}
+
+// migration — один файл миграции.
+type migration struct {
+	version int
+	name    string
+	sql     string
+}
+
+// migrations возвращает встроенные миграции по возрастанию версии. Версия — число в начале имени файла.
+func migrations() ([]migration, error) {
+	names, err := fs.Glob(migrationFS, "migrations/*.sql")
+	if err != nil {
+		return nil, err
+	}
+	out := make([]migration, 0, len(names))
+	for _, name := range names {
+		base := strings.TrimPrefix(name, "migrations/")
+		version, err := strconv.Atoi(strings.SplitN(base, "_", 2)[0])
+		if err != nil {
+			return nil, fmt.Errorf("миграция %s: имя должно начинаться с номера версии", base)
+		}
+		data, err := migrationFS.ReadFile(name)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, migration{version: version, name: base, sql: string(data)})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].version < out[j].version })
+	for i := 1; i < len(out); i++ {
+		if out[i].version == out[i-1].version {
+			return nil, fmt.Errorf("миграции %s и %s с одной версией", out[i-1].name, out[i].name)
+		}
+	}
+	return out, nil
+}
+
+// Migrate применяет недостающие миграции в одной транзакции и записывает их версии в таблицу
+// notephee_schema_migrations. Безопасен для одновременного вызова несколькими экземплярами.
+func Migrate(ctx context.Context, db *sql.DB) error {
+	list, err := migrations()
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("не удалось начать миграцию: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	if _, err := tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock($1)", migrationLock); err != nil {
+		return fmt.Errorf("не удалось заблокировать миграции: %w", err)
+	}
+	_, err = tx.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS notephee_schema_migrations (
+	version INTEGER PRIMARY KEY,
+	applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+)`)
+	if err != nil {
+		return fmt.Errorf("не удалось создать таблицу миграций: %w", err)
+	}
+
+	var current int
+	if err := tx.QueryRowContext(ctx, "SELECT COALESCE(MAX(version), 0) FROM notephee_schema_migrations").Scan(&current); err != nil {
+		return fmt.Errorf("не удалось прочитать версию схемы: %w", err)
+	}
+	for _, m := range list {
+		if m.version <= current {
+			continue
+		}
+		if _, err := tx.ExecContext(ctx, m.sql); err != nil {
+			return fmt.Errorf("миграция %s: %w", m.name, err)
+		}
+		if _, err := tx.ExecContext(ctx, "INSERT INTO notephee_schema_migrations (version) VALUES ($1)", m.version); err != nil {
+			return fmt.Errorf("миграция %s: %w", m.name, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("не удалось завершить миграцию: %w", err)
+	}
+	return nil
+}
+
+// NewDeliveryLog возвращает журнал доставки в таблицах notephee_deliveries и notephee_deliveries_engagement,
+// созданных Migrate. Журнал реализует delivery.DeliveryLog и delivery.EngagementLog.
+func NewDeliveryLog(db *sql.DB) *delivery.SQLLog {
+	return delivery.NewSQLLog(db, "notephee_deliveries", delivery.DollarPlaceholder)
+}
@@ -0,0 +1,164 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/epheer/notephee/broadcast"
+	"github.com/epheer/notephee/delivery"
+	"github.com/epheer/notephee/delivery/deliverytest"
+	"github.com/epheer/notephee/notify"
+	"github.com/epheer/notephee/preferences"
+	"github.com/epheer/notephee/suppression"
+	"github.com/epheer/notephee/telegram"
+)
+
+func TestMigrations(t *testing.T) {
+	list, err := migrations()
+	if err != nil {
+		t.Fatalf("Ошибка чтения миграций: %v", err)
+	}
+	if len(list) == 0 || list[0].version != 1 {
+		t.Fatalf("первая миграция должна иметь версию 1: %+v", list)
+	}
+	for _, table := range []string{"notephee_telegram_bindings", "notephee_subscriptions", "notephee_deliveries",
+		"notephee_deliveries_engagement", "notephee_outbox", "notephee_broadcast_jobs", "notephee_suppressions"} {
+		found := false
+		for _, m := range list {
+			found = found || strings.Contains(m.sql, "CREATE TABLE "+table+" (")
+		}
+		if !found {
+			t.Errorf("нет миграции, создающей таблицу %s", table)
+		}
+	}
+}
+
+// sink запоминает отправки и отвечает ошибками из fail по каналу.
+type sink struct {
+	sent []notify.Message
+	fail map[string]error
+}
+
+func (s *sink) Send(_ context.Context, channel string, msg notify.Message) error {
+	if err := s.fail[channel]; err != nil {
+		return err
+	}
+	s.sent = append(s.sent, msg)
+	return nil
+}
+
+// TestPostgres проверяет хранилища на настоящей базе: NOTEPHEE_TEST_POSTGRES_DSN должен указывать
+// на пустую базу, которую тест может изменять.
+func TestPostgres(t *testing.T) {
+	dsn := os.Getenv("NOTEPHEE_TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("NOTEPHEE_TEST_POSTGRES_DSN не задан, пропускаем тест")
+	}
+	ctx := context.Background()
+	db, err := Open(dsn)
+	if err != nil {
+		t.Fatalf("Ошибка Open: %v", err)
+	}
+	defer db.Close()
+	for range 2 {
+		if err := Migrate(ctx, db); err != nil {
+			t.Fatalf("Ошибка Migrate: %v", err)
+		}
+	}
+	now := time.Now().UTC().Truncate(time.Millisecond)
+
+	bindings := NewBindingStore(db)
+	_ = bindings.Save(ctx, telegram.Binding{UserID: "u1", ChatID: 1, CreatedAt: now, Metadata: map[string]string{"роль": "админ"}})
+	_ = bindings.Save(ctx, telegram.Binding{UserID: "u2", ChatID: 1, CreatedAt: now})
+	if b, err := bindings.ByChat(ctx, 1); err != nil || b.UserID != "u2" {
+		t.Fatalf("привязка чата не заменена: %+v %v", b, err)
+	}
+	_ = bindings.Delete(ctx, "u2", 1)
+	if _, err := bindings.ByChat(ctx, 1); !errors.Is(err, telegram.ErrBindingNotFound) {
+		t.Fatalf("ожидалась ErrBindingNotFound, получено %v", err)
+	}
+
+	subs := NewSubscriptionStore(db)
+	_ = subs.Set(ctx, preferences.Preference{Subject: "u1", Category: "marketing", Allowed: true, UpdatedAt: now})
+	if p, ok, err := subs.Get(ctx, "u1", preferences.AllChannels, "marketing"); !ok || !p.Allowed || err != nil {
+		t.Fatalf("подписка не найдена: %+v %v %v", p, ok, err)
+	}
+
+	log := NewDeliveryLog(db)
+	rec := delivery.Record{ID: "00000000-0000-0000-0000-000000000001", Channel: "telegram", UserID: "u1",
+		Status: delivery.StatusFailed, CreatedAt: now, CompletedAt: now}
+	_ = log.Save(ctx, rec)
+	rec.Status = delivery.StatusSent
+	if err := log.Save(ctx, rec); err != nil {
+		t.Fatalf("повторная попытка не обновила запись: %v", err)
+	}
+	if got, err := log.Get(ctx, rec.ID); err != nil || got.Status != delivery.StatusSent {
+		t.Fatalf("неверная запись журнала: %+v %v", got, err)
+	}
+
+	suppressed := NewSuppressionStore(db)
+	_ = suppressed.Suppress(ctx, suppression.Entry{Channel: "email", Address: "User@Example.com", Reason: suppression.ReasonUnsubscribe, List: "news", CreatedAt: now})
+	_ = suppressed.Suppress(ctx, suppression.Entry{Channel: "email", Address: "gone@example.com", Reason: suppression.ReasonBounce, List: "news", CreatedAt: now})
+	if ok, err := suppressed.IsSuppressed(ctx, "email", "user@example.com", "news"); !ok || err != nil {
+		t.Fatalf("отписка от списка не найдена: %v", err)
+	}
+	if ok, _ := suppressed.IsSuppressed(ctx, "email", "user@example.com", ""); ok {
+		t.Fatal("отписка от списка не должна действовать на другие письма")
+	}
+	if ok, _ := suppressed.IsSuppressed(ctx, "email", "gone@example.com", "billing"); !ok {
+		t.Fatal("недоставляемый адрес должен блокироваться для всех рассылок")
+	}
+	_ = suppressed.Remove(ctx, "email", "USER@example.com")
+	if ok, _ := suppressed.IsSuppressed(ctx, "email", "user@example.com", "news"); ok {
+		t.Fatal("блокировка не снята")
+	}
+
+	jobs := NewJobStore(db)
+	_ = jobs.Save(ctx, &broadcast.Job{ID: "j1", Recipients: []string{"1", "2"}, Status: broadcast.StatusRunning, CreatedAt: now, UpdatedAt: now})
+	if unfinished, err := jobs.Unfinished(ctx); err != nil || len(unfinished) != 1 || len(unfinished[0].Recipients) != 2 {
+		t.Fatalf("незавершённое задание не найдено: %+v %v", unfinished, err)
+	}
+
+	outbox := NewOutbox(db, OutboxOptions{}, slog.Default())
+	tx, _ := db.BeginTx(ctx, nil)
+	_ = outbox.Add(ctx, tx, "telegram", notify.Message{To: "42", Text: "откатано"})
+	_ = tx.Rollback()
+	_ = outbox.Send(ctx, "telegram", notify.Message{To: "42", Text: "привет"})
+	_ = outbox.Send(ctx, "sms", notify.Message{To: "42", Text: "нет канала"})
+	s := &sink{fail: map[string]error{"sms": notify.ErrUnknownChannel}}
+	if n, err := outbox.relay(ctx, s); n != 1 || err != nil {
+		t.Fatalf("ожидалась 1 отправка, получено %d %v", n, err)
+	}
+	if len(s.sent) != 1 || s.sent[0].Text != "привет" {
+		t.Fatalf("неожиданные отправки: %+v", s.sent)
+	}
+}
+
+// TestPostgresDeliveryLog проверяет журнал доставки общим набором тестов; журнал очищается перед каждым.
+func TestPostgresDeliveryLog(t *testing.T) {
+	dsn := os.Getenv("NOTEPHEE_TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("NOTEPHEE_TEST_POSTGRES_DSN не задан, пропускаем тест")
+	}
+	ctx := context.Background()
+	db, err := Open(dsn)
+	if err != nil {
+		t.Fatalf("Ошибка Open: %v", err)
+	}
+	defer db.Close()
+	if err := Migrate(ctx, db); err != nil {
+		t.Fatalf("Ошибка Migrate: %v", err)
+	}
+
+	deliverytest.Run(t, func(t *testing.T) delivery.DeliveryLog {
+		if _, err := db.ExecContext(ctx, "DELETE FROM notephee_deliveries"); err != nil {
+			t.Fatalf("Ошибка очистки журнала: %v", err)
+		}
+		return NewDeliveryLog(db)
+	})
+}
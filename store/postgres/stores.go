@@ -0,0 +1,40 @@
+package postgres
+
+import (
+	"database/sql"
+
+	"github.com/epheer/notephee/delivery"
+	"github.com/epheer/notephee/store/sqlstore"
+)
+
+// BindingStore — telegram.BindingStore в таблице notephee_telegram_bindings.
+type BindingStore = sqlstore.BindingStore
+
+// NewBindingStore создаёт хранилище привязок Telegram.
+func NewBindingStore(db *sql.DB) *BindingStore {
+	return sqlstore.NewBindingStore(db, delivery.DollarPlaceholder)
+}
+
+// SubscriptionStore — preferences.Store в таблице notephee_subscriptions.
+type SubscriptionStore = sqlstore.SubscriptionStore
+
+// NewSubscriptionStore создаёт хранилище подписок.
+func NewSubscriptionStore(db *sql.DB) *SubscriptionStore {
+	return sqlstore.NewSubscriptionStore(db, delivery.DollarPlaceholder)
+}
+
+// JobStore — broadcast.JobStore в таблице notephee_broadcast_jobs.
+type JobStore = sqlstore.JobStore
+
+// NewJobStore создаёт хранилище заданий рассылок.
+func NewJobStore(db *sql.DB) *JobStore {
+	return sqlstore.NewJobStore(db, delivery.DollarPlaceholder)
+}
+
+// SuppressionStore — suppression.Store в таблице notephee_suppressions.
+type SuppressionStore = sqlstore.SuppressionStore
+
+// NewSuppressionStore создаёт список подавления.
+func NewSuppressionStore(db *sql.DB) *SuppressionStore {
+	return sqlstore.NewSuppressionStore(db, delivery.DollarPlaceholder)
+}
@@ -0,0 +1,37 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	goredis "github.com/redis/go-redis/v9"
+)
+
+// DedupStore — dedup.Store в Redis: окно дедупликации общее для всех экземпляров сервиса.
+type DedupStore struct {
+	client goredis.UniversalClient
+	prefix string
+}
+
+// NewDedupStore создаёт хранилище ключей дедупликации с префиксом prefix, например "{notephee}:dedup".
+func NewDedupStore(client goredis.UniversalClient, prefix string) *DedupStore {
+	return &DedupStore{client: client, prefix: prefix}
+}
+
+// Claim занимает ключ на ttl командой SET NX, поэтому из одновременных одинаковых сообщений проходит одно.
+func (s *DedupStore) Claim(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	ok, err := s.client.SetNX(ctx, s.prefix+":"+key, 1, ttl).Result()
+	if err != nil {
+		return false, fmt.Errorf("не удалось занять ключ дедупликации: %w", err)
+	}
+	return ok, nil
+}
+
+// Release освобождает ключ.
+func (s *DedupStore) Release(ctx context.Context, key string) error {
+	if err := s.client.Del(ctx, s.prefix+":"+key).Err(); err != nil {
+		return fmt.Errorf("не удалось освободить ключ дедупликации: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+// Package redis хранит общее состояние notephee в Redis, чтобы несколько экземпляров сервиса делили
+// лимиты отправки, очередь, окно дедупликации, привязки и offset опроса Telegram.
+//
+// Типы принимают redis.UniversalClient из github.com/redis/go-redis/v9, поэтому подходят и одиночный
+// сервер, и Sentinel, и Redis Cluster. Ключи одного хранилища начинаются с общего префикса; в Cluster
+// префикс должен содержать хеш-тег, например {notephee}, чтобы ключи попадали в один слот.
+package redis
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	goredis "github.com/redis/go-redis/v9"
+)
+
+// limiterScript реализует GCRA: ключ хранит теоретическое время прихода (TAT) следующей отправки
+// в микросекундах по часам Redis. Возвращает 0, если отправка разрешена, иначе паузу в микросекундах.
+var limiterScript = goredis.NewScript(`
+local now = redis.call('TIME')
+now = tonumber(now[1]) * 1000000 + tonumber(now[2])
+local interval = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local tat = tonumber(redis.call('GET', KEYS[1]) or '0')
+if tat < now then tat = now end
+local wait = tat + interval - now - interval * burst
+if wait > 0 then return wait end
+redis.call('SET', KEYS[1], tat + interval, 'PX', math.ceil((tat + interval - now) / 1000) + 1)
+return 0
+`)
+
+// Limiter — notify.Limiter с общим бюджетом отправок в Redis: экземпляры сервиса с одним ключом
+// вместе отправляют не чаще rate сообщений в секунду.
+type Limiter struct {
+	client   goredis.UniversalClient
+	key      string
+	interval time.Duration // Промежуток между отправками
+	burst    int           // Допустимый всплеск
+}
+
+// NewLimiter создаёт лимитер rate сообщений в секунду со всплеском burst (не меньше 1) на ключе key,
+// например "{notephee}:limit:telegram:mybot".
+func NewLimiter(client goredis.UniversalClient, key string, rate float64, burst int) *Limiter {
+	return &Limiter{
+		client:   client,
+		key:      key,
+		interval: time.Duration(float64(time.Second) / rate),
+		burst:    max(burst, 1),
+	}
+}
+
+// Wait блокируется, пока отправка не будет разрешена, или возвращает ошибку Redis либо ctx.Err().
+func (l *Limiter) Wait(ctx context.Context) error {
+	for {
+		wait, err := limiterScript.Run(ctx, l.client, []string{l.key}, l.interval.Microseconds(), l.burst).Int64()
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return fmt.Errorf("лимитер Redis %s: %w", l.key, err)
+		}
+		if wait <= 0 {
+			return nil
+		}
+		t := time.NewTimer(time.Duration(min(wait, math.MaxInt64/1000)) * time.Microsecond)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		case <-t.C:
+		}
+	}
+}
@@ -0,0 +1,237 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	goredis "github.com/redis/go-redis/v9"
+
+	"github.com/epheer/notephee/ingest"
+	"github.com/epheer/notephee/notify"
+	"github.com/epheer/notephee/spool"
+)
+
+// QueueOptions — настройки очереди. Нулевые значения заменяются значениями по умолчанию.
+type QueueOptions struct {
+	Group    string        // Группа потребителей потока; по умолчанию notephee
+	Consumer string        // Имя экземпляра в группе; по умолчанию имя хоста и PID
+	Batch    int           // Сообщений за одно чтение; по умолчанию 10
+	MinIdle  time.Duration // Через сколько неподтверждённое сообщение упавшего экземпляра забирает другой; по умолчанию 5 минут
+	MaxLen   int64         // Приблизительный предел длины потока (XADD MAXLEN ~); 0 — без ограничения
+	// MaxDeliveries — сколько раз сообщение с временной ошибкой выдаётся на отправку, прежде чем уйти
+	// в DeadLetter; по умолчанию 5. Повтор происходит через MinIdle.
+	MaxDeliveries int
+	// DeadLetter — поток для сообщений, которые не удалось отправить; по умолчанию имя очереди с суффиксом :dead
+	DeadLetter string
+}
+
+func (o *QueueOptions) defaults() {
+	if o.Group == "" {
+		o.Group = "notephee"
+	}
+	if o.Consumer == "" {
+		host, _ := os.Hostname()
+		o.Consumer = host + "-" + strconv.Itoa(os.Getpid())
+	}
+	if o.Batch <= 0 {
+		o.Batch = 10
+	}
+	if o.MinIdle <= 0 {
+		o.MinIdle = 5 * time.Minute
+	}
+	if o.MaxDeliveries <= 0 {
+		o.MaxDeliveries = 5
+	}
+}
+
+// Queue — общая очередь отправки на потоке Redis (Streams). Send ставит сообщение в поток, а Run
+// каждого экземпляра читает его в группе потребителей и отправляет через sink: сообщение получает
+// один экземпляр, а сообщения упавшего экземпляра через MinIdle забирают остальные.
+//
+// Queue реализует ingest.Sink, поэтому её можно передать потребителям брокеров и HTTP-обработчикам
+// вместо notify.Registry.
+type Queue struct {
+	client goredis.UniversalClient
+	stream string
+	opts   QueueOptions
+	logger *slog.Logger
+}
+
+// NewQueue создаёт очередь на потоке stream, например "{notephee}:queue".
+func NewQueue(client goredis.UniversalClient, stream string, opts QueueOptions, logger *slog.Logger) *Queue {
+	opts.defaults()
+	if opts.DeadLetter == "" {
+		opts.DeadLetter = stream + ":dead"
+	}
+	return &Queue{client: client, stream: stream, opts: opts, logger: logger}
+}
+
+// Send ставит сообщение канала channel в очередь.
+func (q *Queue) Send(ctx context.Context, channel string, msg notify.Message) error {
+	data, err := json.Marshal(spool.Entry{Channel: channel, Message: msg, SpooledAt: time.Now()})
+	if err != nil {
+		return err
+	}
+	args := &goredis.XAddArgs{Stream: q.stream, Values: map[string]any{"entry": data}}
+	if q.opts.MaxLen > 0 {
+		args.MaxLen, args.Approx = q.opts.MaxLen, true
+	}
+	if err := q.client.XAdd(ctx, args).Err(); err != nil {
+		return fmt.Errorf("не удалось поставить сообщение в очередь Redis: %w", err)
+	}
+	return nil
+}
+
+// Run отправляет сообщения из очереди через sink, пока не отменён ctx. Сообщение подтверждается
+// и удаляется из потока после отправки, пропуска по решению получателя или с неизвестным исходом.
+// Сообщения с временной ошибкой или незарегистрированного канала остаются неподтверждёнными
+// и отправляются снова через MinIdle, а после MaxDeliveries выдач переносятся в поток DeadLetter
+// вместе с повреждёнными и недоставляемыми сообщениями. Прерванные остановкой и закрытым клиентом
+// сообщения остаются в очереди без ограничения. Отмена ctx возвращает nil.
+func (q *Queue) Run(ctx context.Context, sink ingest.Sink) error {
+	err := q.client.XGroupCreateMkStream(ctx, q.stream, q.opts.Group, "0").Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return fmt.Errorf("не удалось создать группу потребителей очереди Redis: %w", err)
+	}
+	q.logger.Info("чтение очереди Redis", "stream", q.stream, "group", q.opts.Group, "consumer", q.opts.Consumer)
+
+	claimed := time.Time{}
+	for ctx.Err() == nil {
+		var msgs []goredis.XMessage
+		fromClaim := false
+		if time.Since(claimed) >= q.opts.MinIdle/2 {
+			// Сообщения упавших экземпляров и отложенные этим экземпляром
+			msgs, _, err = q.client.XAutoClaim(ctx, &goredis.XAutoClaimArgs{
+				Stream: q.stream, Group: q.opts.Group, Consumer: q.opts.Consumer,
+				MinIdle: q.opts.MinIdle, Start: "0-0", Count: int64(q.opts.Batch),
+			}).Result()
+			if err == nil && len(msgs) < q.opts.Batch {
+				claimed = time.Now()
+			}
+			fromClaim = len(msgs) > 0
+		}
+		if err == nil && len(msgs) == 0 {
+			var streams []goredis.XStream
+			streams, err = q.client.XReadGroup(ctx, &goredis.XReadGroupArgs{
+				Group: q.opts.Group, Consumer: q.opts.Consumer, Streams: []string{q.stream, ">"},
+				// go-redis не прерывает ожидание по отмене ctx, поэтому короткое ожидание ускоряет остановку
+				Count: int64(q.opts.Batch), Block: time.Second,
+			}).Result()
+			for _, s := range streams {
+				msgs = append(msgs, s.Messages...)
+			}
+			if errors.Is(err, goredis.Nil) {
+				err = nil
+			}
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			q.logger.Error("не удалось прочитать очередь Redis", "stream", q.stream, "error", err)
+			if err := sleep(ctx, time.Second); err != nil {
+				return nil
+			}
+			continue
+		}
+		for _, m := range msgs {
+			deliveries := int64(1)
+			if fromClaim {
+				deliveries = q.deliveries(ctx, m.ID)
+			}
+			q.handle(ctx, sink, m, deliveries)
+		}
+	}
+	return nil
+}
+
+// handle отправляет одно сообщение, выданное deliveries раз, и подтверждает его, если повторять
+// отправку не нужно.
+func (q *Queue) handle(ctx context.Context, sink ingest.Sink, m goredis.XMessage, deliveries int64) {
+	data, _ := m.Values["entry"].(string)
+	var e spool.Entry
+	if err := json.Unmarshal([]byte(data), &e); err != nil {
+		q.deadLetter(ctx, m.ID, data, fmt.Errorf("повреждённое сообщение: %w", err))
+		return
+	}
+
+	err := sink.Send(ctx, e.Channel, e.Message)
+	outcome := ingest.Classify(ctx, err)
+	switch {
+	case outcome == ingest.Delivered, outcome == ingest.Skipped:
+	case outcome == ingest.Indeterminate:
+		q.logger.Warn("исход отправки из очереди Redis неизвестен, сообщение не повторяется", "channel", e.Channel,
+			"to", e.Message.To, "error", err)
+	case ctx.Err() != nil, errors.Is(err, notify.ErrClosed):
+		q.logger.Warn("сообщение оставлено в очереди Redis", "channel", e.Channel, "id", m.ID, "error", err)
+		return
+	case (outcome == ingest.Failed || errors.Is(err, notify.ErrUnknownChannel)) && deliveries < int64(q.opts.MaxDeliveries):
+		q.logger.Warn("сообщение оставлено в очереди Redis для повтора", "channel", e.Channel, "id", m.ID,
+			"deliveries", deliveries, "error", err)
+		return
+	default:
+		q.deadLetter(ctx, m.ID, data, err)
+		return
+	}
+	q.ack(ctx, m.ID)
+}
+
+// deliveries возвращает, сколько раз сообщение id выдавалось потребителям группы.
+func (q *Queue) deliveries(ctx context.Context, id string) int64 {
+	pending, err := q.client.XPendingExt(ctx, &goredis.XPendingExtArgs{
+		Stream: q.stream, Group: q.opts.Group, Start: id, End: id, Count: 1,
+	}).Result()
+	if err != nil || len(pending) == 0 {
+		return 1
+	}
+	return pending[0].RetryCount
+}
+
+// deadLetter переносит сообщение в поток DeadLetter с причиной и исходным ID. Если запись не удалась,
+// сообщение остаётся неподтверждённым и будет выдано снова.
+func (q *Queue) deadLetter(ctx context.Context, id, data string, cause error) {
+	q.logger.Error("сообщение очереди Redis перенесено в DeadLetter", "stream", q.stream, "id", id,
+		"dead_letter", q.opts.DeadLetter, "error", cause)
+	err := q.client.XAdd(context.WithoutCancel(ctx), &goredis.XAddArgs{
+		Stream: q.opts.DeadLetter,
+		Values: map[string]any{"entry": data, "error": cause.Error(), "id": id},
+	}).Err()
+	if err != nil {
+		q.logger.Error("не удалось записать сообщение в DeadLetter очереди Redis", "dead_letter", q.opts.DeadLetter, "error", err)
+		return
+	}
+	q.ack(ctx, id)
+}
+
+// ack подтверждает и удаляет сообщение из потока. Подтверждение не прерывается остановкой,
+// чтобы отправленное сообщение не ушло повторно.
+func (q *Queue) ack(ctx context.Context, id string) {
+	ctx = context.WithoutCancel(ctx)
+	_, err := q.client.TxPipelined(ctx, func(p goredis.Pipeliner) error {
+		p.XAck(ctx, q.stream, q.opts.Group, id)
+		p.XDel(ctx, q.stream, id)
+		return nil
+	})
+	if err != nil {
+		q.logger.Warn("не удалось подтвердить сообщение очереди Redis", "id", id, "error", err)
+	}
+}
+
+// sleep ждёт d или завершения ctx.
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
@@ -0,0 +1,229 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	goredis "github.com/redis/go-redis/v9"
+
+	"github.com/epheer/notephee/notify"
+	"github.com/epheer/notephee/telegram"
+)
+
+func newClient(t *testing.T) (*miniredis.Miniredis, goredis.UniversalClient) {
+	t.Helper()
+	srv := miniredis.RunT(t)
+	client := goredis.NewClient(&goredis.Options{Addr: srv.Addr()})
+	t.Cleanup(func() { _ = client.Close() })
+	return srv, client
+}
+
+func TestLimiter(t *testing.T) {
+	_, client := newClient(t)
+	ctx := context.Background()
+	first := NewLimiter(client, "{notephee}:limit", 10, 2)
+	second := NewLimiter(client, "{notephee}:limit", 10, 2)
+
+	// Два экземпляра делят один бюджет: всплеск из двух отправок, затем не чаще 10 в секунду
+	start := time.Now()
+	for _, l := range []*Limiter{first, second, first, second} {
+		if err := l.Wait(ctx); err != nil {
+			t.Fatalf("Ошибка Wait: %v", err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 150*time.Millisecond {
+		t.Fatalf("четыре отправки при лимите 10/с и всплеске 2 прошли за %s", elapsed)
+	}
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if err := NewLimiter(client, "{notephee}:slow", 0.001, 1).Wait(cancelled); !errors.Is(err, context.Canceled) {
+		t.Fatalf("ожидалась отмена ожидания, получено %v", err)
+	}
+}
+
+func TestDedupStore(t *testing.T) {
+	srv, client := newClient(t)
+	ctx := context.Background()
+	s := NewDedupStore(client, "{notephee}:dedup")
+
+	if ok, err := s.Claim(ctx, "k", time.Minute); !ok || err != nil {
+		t.Fatalf("первый Claim должен занять ключ: %v %v", ok, err)
+	}
+	if ok, _ := s.Claim(ctx, "k", time.Minute); ok {
+		t.Fatal("занятый ключ занят повторно")
+	}
+	if err := s.Release(ctx, "k"); err != nil {
+		t.Fatalf("Ошибка Release: %v", err)
+	}
+	if ok, _ := s.Claim(ctx, "k", time.Minute); !ok {
+		t.Fatal("освобождённый ключ не занят")
+	}
+	srv.FastForward(2 * time.Minute)
+	if ok, _ := s.Claim(ctx, "k", time.Minute); !ok {
+		t.Fatal("ключ не освободился по истечении окна")
+	}
+}
+
+func TestBindingStore(t *testing.T) {
+	_, client := newClient(t)
+	ctx := context.Background()
+	s := NewBindingStore(client, "{notephee}:telegram")
+	now := time.Now().UTC()
+
+	_ = s.Save(ctx, telegram.Binding{UserID: "u1", ChatID: 2, CreatedAt: now.Add(time.Second)})
+	_ = s.Save(ctx, telegram.Binding{UserID: "u1", ChatID: 1, CreatedAt: now, Metadata: map[string]string{"роль": "админ"}})
+	_ = s.Save(ctx, telegram.Binding{UserID: "u2", ChatID: 3, CreatedAt: now})
+
+	got, err := s.ByUser(ctx, "u1")
+	if err != nil || len(got) != 2 || got[0].ChatID != 1 || got[0].Metadata["роль"] != "админ" {
+		t.Fatalf("неверные привязки u1: %+v %v", got, err)
+	}
+
+	// Привязка чата к другому пользователю заменяет прежнюю
+	_ = s.Save(ctx, telegram.Binding{UserID: "u2", ChatID: 2, CreatedAt: now})
+	if got, _ := s.ByUser(ctx, "u1"); len(got) != 1 {
+		t.Fatalf("чат 2 остался у u1: %+v", got)
+	}
+	if b, err := s.ByChat(ctx, 2); err != nil || b.UserID != "u2" {
+		t.Fatalf("неверная привязка чата 2: %+v %v", b, err)
+	}
+
+	// Чужая привязка не удаляется
+	_ = s.Delete(ctx, "u1", 3)
+	if all, _ := s.List(ctx); len(all) != 3 {
+		t.Fatalf("ожидалось 3 привязки, получено %+v", all)
+	}
+	_ = s.Delete(ctx, "u2", 3)
+	if _, err := s.ByChat(ctx, 3); !errors.Is(err, telegram.ErrBindingNotFound) {
+		t.Fatalf("ожидалась ErrBindingNotFound, получено %v", err)
+	}
+	if got, err := s.ByUser(ctx, "u3"); err != nil || got == nil || len(got) != 0 {
+		t.Fatalf("для пользователя без привязок ожидался пустой список: %+v %v", got, err)
+	}
+}
+
+func TestOffsetStoreAndPollingLock(t *testing.T) {
+	srv, client := newClient(t)
+	ctx := context.Background()
+
+	offsets := NewOffsetStore(client, "{notephee}:telegram:offset")
+	if offset, err := offsets.Load(ctx); offset != 0 || err != nil {
+		t.Fatalf("ожидался offset 0, получено %d %v", offset, err)
+	}
+	_ = offsets.Save(ctx, 42)
+	if offset, _ := offsets.Load(ctx); offset != 42 {
+		t.Fatalf("ожидался offset 42, получено %d", offset)
+	}
+
+	leader := NewPollingLock(client, "{notephee}:telegram:poll")
+	follower := NewPollingLock(client, "{notephee}:telegram:poll")
+	if ok, err := leader.TryLock(ctx, time.Second); !ok || err != nil {
+		t.Fatalf("блокировка не захвачена: %v %v", ok, err)
+	}
+	if ok, _ := follower.TryLock(ctx, time.Second); ok {
+		t.Fatal("занятая блокировка захвачена вторым экземпляром")
+	}
+	if err := follower.Refresh(ctx, time.Second); !errors.Is(err, ErrLockLost) {
+		t.Fatalf("чужая блокировка продлена: %v", err)
+	}
+	_ = follower.Unlock(ctx)
+	if err := leader.Refresh(ctx, time.Second); err != nil {
+		t.Fatalf("Ошибка Refresh: %v", err)
+	}
+
+	srv.FastForward(2 * time.Second)
+	if err := leader.Refresh(ctx, time.Second); !errors.Is(err, ErrLockLost) {
+		t.Fatalf("истёкшая блокировка продлена: %v", err)
+	}
+	if ok, _ := follower.TryLock(ctx, time.Second); !ok {
+		t.Fatal("истёкшая блокировка не перехвачена")
+	}
+}
+
+// sink запоминает отправки и отвечает ошибками из fail по каналу.
+type sink struct {
+	mu    sync.Mutex
+	sent  []notify.Message
+	calls int
+	fail  map[string]error
+}
+
+func (s *sink) Send(_ context.Context, channel string, msg notify.Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls++
+	if err := s.fail[channel]; err != nil {
+		return err
+	}
+	s.sent = append(s.sent, msg)
+	return nil
+}
+
+func (s *sink) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.calls
+}
+
+func TestQueue(t *testing.T) {
+	_, client := newClient(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	producer := NewQueue(client, "{notephee}:queue", QueueOptions{}, slog.Default())
+	for _, ch := range []string{"telegram", "email", "sms"} {
+		if err := producer.Send(ctx, ch, notify.Message{To: "42", Text: "привет"}); err != nil {
+			t.Fatalf("Ошибка Send: %v", err)
+		}
+	}
+	// Повреждённое сообщение сразу уходит в DeadLetter
+	client.XAdd(ctx, &goredis.XAddArgs{Stream: "{notephee}:queue", Values: map[string]any{"entry": "{"}})
+
+	s := &sink{fail: map[string]error{"email": errors.New("503"), "sms": notify.ErrUnknownChannel}}
+	run := func(opts QueueOptions, calls int) {
+		t.Helper()
+		consumer := NewQueue(client, "{notephee}:queue", opts, slog.Default())
+		runCtx, stop := context.WithCancel(ctx)
+		done := make(chan error, 1)
+		go func() { done <- consumer.Run(runCtx, s) }()
+		for s.count() < calls && ctx.Err() == nil {
+			time.Sleep(10 * time.Millisecond)
+		}
+		stop()
+		if err := <-done; err != nil {
+			t.Fatalf("Ошибка Run: %v", err)
+		}
+	}
+	run(QueueOptions{Consumer: "a"}, 3)
+
+	if len(s.sent) != 1 || s.sent[0].Text != "привет" {
+		t.Fatalf("неожиданные отправки: %+v", s.sent)
+	}
+	// Сообщения с временной ошибкой и незарегистрированного канала остались для повтора
+	bg := context.Background()
+	if n := client.XLen(bg, "{notephee}:queue").Val(); n != 2 {
+		t.Fatalf("в потоке ожидалось 2 сообщения, осталось %d", n)
+	}
+	if pending := client.XPending(bg, "{notephee}:queue", "notephee").Val(); pending.Count != 2 {
+		t.Fatalf("ожидалось 2 неподтверждённых сообщения, получено %+v", pending)
+	}
+	if n := client.XLen(bg, "{notephee}:queue:dead").Val(); n != 1 {
+		t.Fatalf("повреждённое сообщение должно уйти в DeadLetter, там %d", n)
+	}
+
+	// Вторая выдача исчерпывает MaxDeliveries: сообщения переносятся в DeadLetter
+	time.Sleep(20 * time.Millisecond)
+	run(QueueOptions{Consumer: "b", MinIdle: 10 * time.Millisecond, MaxDeliveries: 2}, 5)
+	if n := client.XLen(bg, "{notephee}:queue").Val(); n != 0 {
+		t.Fatalf("поток должен опустеть, осталось %d", n)
+	}
+	if n := client.XLen(bg, "{notephee}:queue:dead").Val(); n != 3 {
+		t.Fatalf("в DeadLetter ожидалось 3 сообщения, получено %d", n)
+	}
+}
@@ -0,0 +1,230 @@
+package redis
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	goredis "github.com/redis/go-redis/v9"
+
+	"github.com/epheer/notephee/telegram"
+)
+
+// BindingStore — telegram.BindingStore в Redis. Привязки лежат в хеше prefix:bindings (чат → JSON),
+// владельцы чатов — в хеше prefix:owners, чаты пользователя — в множестве prefix:user:<id>.
+type BindingStore struct {
+	client goredis.UniversalClient
+	prefix string
+}
+
+// NewBindingStore создаёт хранилище привязок с префиксом prefix, например "{notephee}:telegram".
+func NewBindingStore(client goredis.UniversalClient, prefix string) *BindingStore {
+	return &BindingStore{client: client, prefix: prefix}
+}
+
+// saveBindingScript заменяет привязку чата и переносит чат из множества прежнего владельца.
+var saveBindingScript = goredis.NewScript(`
+local old = redis.call('HGET', KEYS[2], ARGV[1])
+if old then redis.call('SREM', ARGV[4] .. old, ARGV[1]) end
+redis.call('HSET', KEYS[1], ARGV[1], ARGV[3])
+redis.call('HSET', KEYS[2], ARGV[1], ARGV[2])
+redis.call('SADD', ARGV[4] .. ARGV[2], ARGV[1])
+return 1
+`)
+
+// deleteBindingScript удаляет привязку чата, если она принадлежит пользователю.
+var deleteBindingScript = goredis.NewScript(`
+if redis.call('HGET', KEYS[2], ARGV[1]) ~= ARGV[2] then return 0 end
+redis.call('HDEL', KEYS[1], ARGV[1])
+redis.call('HDEL', KEYS[2], ARGV[1])
+redis.call('SREM', ARGV[3] .. ARGV[2], ARGV[1])
+return 1
+`)
+
+func (s *BindingStore) keys() []string {
+	return []string{s.prefix + ":bindings", s.prefix + ":owners"}
+}
+
+// Save сохраняет привязку, заменяя прежнюю привязку того же чата.
+func (s *BindingStore) Save(ctx context.Context, b telegram.Binding) error {
+	data, err := json.Marshal(b)
+	if err != nil {
+		return err
+	}
+	chat := strconv.FormatInt(b.ChatID, 10)
+	if err := saveBindingScript.Run(ctx, s.client, s.keys(), chat, b.UserID, data, s.prefix+":user:").Err(); err != nil {
+		return fmt.Errorf("не удалось сохранить привязку Telegram: %w", err)
+	}
+	return nil
+}
+
+// ByUser возвращает привязки пользователя.
+func (s *BindingStore) ByUser(ctx context.Context, userID string) ([]telegram.Binding, error) {
+	chats, err := s.client.SMembers(ctx, s.prefix+":user:"+userID).Result()
+	if err != nil {
+		return nil, fmt.Errorf("не удалось прочитать привязки Telegram: %w", err)
+	}
+	if len(chats) == 0 {
+		return []telegram.Binding{}, nil
+	}
+	values, err := s.client.HMGet(ctx, s.prefix+":bindings", chats...).Result()
+	if err != nil {
+		return nil, fmt.Errorf("не удалось прочитать привязки Telegram: %w", err)
+	}
+	var raw []string
+	for _, v := range values {
+		if v, ok := v.(string); ok {
+			raw = append(raw, v)
+		}
+	}
+	return decodeBindings(raw)
+}
+
+// ByChat возвращает привязку чата или telegram.ErrBindingNotFound.
+func (s *BindingStore) ByChat(ctx context.Context, chatID int64) (telegram.Binding, error) {
+	data, err := s.client.HGet(ctx, s.prefix+":bindings", strconv.FormatInt(chatID, 10)).Result()
+	if errors.Is(err, goredis.Nil) {
+		return telegram.Binding{}, telegram.ErrBindingNotFound
+	}
+	if err != nil {
+		return telegram.Binding{}, fmt.Errorf("не удалось прочитать привязку Telegram: %w", err)
+	}
+	var b telegram.Binding
+	if err := json.Unmarshal([]byte(data), &b); err != nil {
+		return telegram.Binding{}, fmt.Errorf("повреждённая привязка Telegram чата %d: %w", chatID, err)
+	}
+	return b, nil
+}
+
+// List возвращает все привязки.
+func (s *BindingStore) List(ctx context.Context) ([]telegram.Binding, error) {
+	values, err := s.client.HVals(ctx, s.prefix+":bindings").Result()
+	if err != nil {
+		return nil, fmt.Errorf("не удалось прочитать привязки Telegram: %w", err)
+	}
+	return decodeBindings(values)
+}
+
+// Delete удаляет привязку.
+func (s *BindingStore) Delete(ctx context.Context, userID string, chatID int64) error {
+	chat := strconv.FormatInt(chatID, 10)
+	if err := deleteBindingScript.Run(ctx, s.client, s.keys(), chat, userID, s.prefix+":user:").Err(); err != nil {
+		return fmt.Errorf("не удалось удалить привязку Telegram: %w", err)
+	}
+	return nil
+}
+
+// decodeBindings разбирает привязки и сортирует их в порядке создания, как MemoryBindingStore.
+func decodeBindings(values []string) ([]telegram.Binding, error) {
+	out := make([]telegram.Binding, 0, len(values))
+	for _, v := range values {
+		var b telegram.Binding
+		if err := json.Unmarshal([]byte(v), &b); err != nil {
+			return nil, fmt.Errorf("повреждённая привязка Telegram: %w", err)
+		}
+		out = append(out, b)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if !out[i].CreatedAt.Equal(out[j].CreatedAt) {
+			return out[i].CreatedAt.Before(out[j].CreatedAt)
+		}
+		return out[i].ChatID < out[j].ChatID
+	})
+	return out, nil
+}
+
+// OffsetStore — telegram.OffsetStore в Redis: новый ведущий экземпляр продолжает опрос с того же места.
+type OffsetStore struct {
+	client goredis.UniversalClient
+	key    string
+}
+
+// NewOffsetStore создаёт хранилище offset на ключе key. Каждому боту нужен свой ключ.
+func NewOffsetStore(client goredis.UniversalClient, key string) *OffsetStore {
+	return &OffsetStore{client: client, key: key}
+}
+
+// Load возвращает сохранённый offset или 0, если его ещё нет.
+func (s *OffsetStore) Load(ctx context.Context) (int64, error) {
+	offset, err := s.client.Get(ctx, s.key).Int64()
+	if errors.Is(err, goredis.Nil) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("не удалось прочитать offset: %w", err)
+	}
+	return offset, nil
+}
+
+// Save сохраняет offset.
+func (s *OffsetStore) Save(ctx context.Context, offset int64) error {
+	if err := s.client.Set(ctx, s.key, offset, 0).Err(); err != nil {
+		return fmt.Errorf("не удалось сохранить offset: %w", err)
+	}
+	return nil
+}
+
+// ErrLockLost возвращается Refresh, если блокировка истекла или захвачена другим экземпляром.
+var ErrLockLost = errors.New("блокировка опроса потеряна")
+
+// refreshScript продлевает блокировку, если она принадлежит владельцу ARGV[1].
+var refreshScript = goredis.NewScript(`
+if redis.call('GET', KEYS[1]) ~= ARGV[1] then return 0 end
+return redis.call('PEXPIRE', KEYS[1], ARGV[2])
+`)
+
+// unlockScript удаляет блокировку, если она принадлежит владельцу ARGV[1].
+var unlockScript = goredis.NewScript(`
+if redis.call('GET', KEYS[1]) ~= ARGV[1] then return 0 end
+return redis.call('DEL', KEYS[1])
+`)
+
+// PollingLock — telegram.PollingLock в Redis. Каждый экземпляр создаёт свой PollingLock на общем ключе:
+// владелец отличается случайным токеном.
+type PollingLock struct {
+	client goredis.UniversalClient
+	key    string
+	token  string
+}
+
+// NewPollingLock создаёт блокировку опроса на ключе key, например "{notephee}:telegram:poll:mybot".
+func NewPollingLock(client goredis.UniversalClient, key string) *PollingLock {
+	token := make([]byte, 16)
+	_, _ = rand.Read(token)
+	return &PollingLock{client: client, key: key, token: hex.EncodeToString(token)}
+}
+
+// TryLock пытается захватить блокировку на ttl.
+func (l *PollingLock) TryLock(ctx context.Context, ttl time.Duration) (bool, error) {
+	ok, err := l.client.SetNX(ctx, l.key, l.token, ttl).Result()
+	if err != nil {
+		return false, fmt.Errorf("не удалось захватить блокировку опроса: %w", err)
+	}
+	return ok, nil
+}
+
+// Refresh продлевает захваченную блокировку на ttl или возвращает ErrLockLost.
+func (l *PollingLock) Refresh(ctx context.Context, ttl time.Duration) error {
+	n, err := refreshScript.Run(ctx, l.client, []string{l.key}, l.token, ttl.Milliseconds()).Int()
+	if err != nil {
+		return fmt.Errorf("не удалось продлить блокировку опроса: %w", err)
+	}
+	if n == 0 {
+		return ErrLockLost
+	}
+	return nil
+}
+
+// Unlock освобождает блокировку, если она ещё принадлежит этому экземпляру.
+func (l *PollingLock) Unlock(ctx context.Context) error {
+	if err := unlockScript.Run(ctx, l.client, []string{l.key}, l.token).Err(); err != nil {
+		return fmt.Errorf("не удалось освободить блокировку опроса: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+-- Привязки чатов Telegram к пользователям (telegram.BindingStore)
+CREATE TABLE notephee_telegram_bindings (
+	chat_id INTEGER PRIMARY KEY,
+	user_id TEXT NOT NULL,
+	bot TEXT NOT NULL,
+	metadata TEXT NOT NULL,
+	created_at TIMESTAMP NOT NULL
+);
+CREATE INDEX notephee_telegram_bindings_user_idx ON notephee_telegram_bindings (user_id);
+
+-- Offset опроса getUpdates по ботам (telegram.OffsetStore)
+CREATE TABLE notephee_telegram_offsets (
+	bot TEXT PRIMARY KEY,
+	offset_id INTEGER NOT NULL
+);
+
+-- Подписки получателей на категории уведомлений (preferences.Store)
+CREATE TABLE notephee_subscriptions (
+	subject TEXT NOT NULL,
+	channel TEXT NOT NULL,
+	category TEXT NOT NULL,
+	allowed BOOLEAN NOT NULL,
+	updated_at TIMESTAMP NOT NULL,
+	PRIMARY KEY (subject, channel, category)
+);
+
+-- Журнал доставки (delivery.SQLLog) и открытия писем и переходы по ссылкам
+CREATE TABLE notephee_deliveries (
+	id VARCHAR(36) PRIMARY KEY,
+	channel VARCHAR(32) NOT NULL,
+	user_id VARCHAR(255) NOT NULL,
+	recipient VARCHAR(320) NOT NULL,
+	message_hash CHAR(64) NOT NULL,
+	status VARCHAR(16) NOT NULL,
+	error TEXT NOT NULL,
+	created_at TIMESTAMP NOT NULL,
+	completed_at TIMESTAMP NOT NULL
+);
+CREATE INDEX notephee_deliveries_user_idx ON notephee_deliveries (user_id, created_at);
+CREATE INDEX notephee_deliveries_failed_idx ON notephee_deliveries (created_at) WHERE status = 'failed';
+CREATE TABLE notephee_deliveries_engagement (
+	delivery_id VARCHAR(36) NOT NULL,
+	action VARCHAR(16) NOT NULL,
+	url TEXT NOT NULL,
+	created_at TIMESTAMP NOT NULL
+);
+CREATE INDEX notephee_deliveries_engagement_idx ON notephee_deliveries_engagement (delivery_id, created_at);
+
+-- Задания массовых рассылок (broadcast.JobStore)
+CREATE TABLE notephee_broadcast_jobs (
+	id TEXT PRIMARY KEY,
+	status TEXT NOT NULL,
+	job TEXT NOT NULL,
+	created_at TIMESTAMP NOT NULL,
+	updated_at TIMESTAMP NOT NULL
+);
+CREATE INDEX notephee_broadcast_jobs_unfinished_idx ON notephee_broadcast_jobs (created_at) WHERE status <> 'completed';
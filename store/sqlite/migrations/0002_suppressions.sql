@@ -0,0 +1,9 @@
+-- Список подавления (suppression.Store): пустой list — блокировка для всех рассылок
+CREATE TABLE notephee_suppressions (
+	channel TEXT NOT NULL,
+	address TEXT NOT NULL,
+	list TEXT NOT NULL,
+	reason TEXT NOT NULL,
+	created_at TIMESTAMP NOT NULL,
+	PRIMARY KEY (channel, address, list)
+);
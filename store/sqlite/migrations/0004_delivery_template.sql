@@ -0,0 +1,2 @@
+-- Версия шаблона (name@v3), из которой собрано сообщение, хранится вместе с попыткой доставки
+ALTER TABLE notephee_deliveries ADD COLUMN template VARCHAR(255) NOT NULL DEFAULT '';
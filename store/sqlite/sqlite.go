@@ -0,0 +1,158 @@
+// Package sqlite хранит данные notephee в файле SQLite, чтобы небольшой сервер работал одним бинарником
+// без внешней инфраструктуры: привязки и offset опроса Telegram, подписки получателей, журнал доставки,
+// задания рассылок и список подавления лежат в одном файле.
+//
+// Драйвер modernc.org/sqlite написан на Go и не требует cgo. Схема создаётся встроенными миграциями (Migrate).
+// Для нескольких экземпляров сервиса нужна общая база, см. модуль store/postgres.
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strconv"
+	"strings"
+
+	_ "modernc.org/sqlite" // Драйвер SQLite для database/sql
+
+	"github.com/epheer/notephee/delivery"
+)
+
+//go:embed migrations/*.sql
+var migrationFS embed.FS
+
+// Open открывает (и при необходимости создаёт) базу в файле path. Журнал WAL позволяет читать во время
+// записи, а единственное соединение на запись исключает ошибки SQLITE_BUSY внутри процесса.
+func Open(path string) (*sql.DB, error) {
+	dsn := "file:" + path + "?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_pragma=foreign_keys(1)&_txlock=immediate"
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось открыть базу SQLite %s: %w", path, err)
+	}
+	db.SetMaxOpenConns(1)
+	return db, nil
+}
+
+// migration — один файл миграции.
+type migration struct {
+	version int
+	name    string
+	sql     string
+}
+
+// migrations возвращает встроенные миграции по возрастанию версии. Версия — число в начале имени файла.
+func migrations() ([]migration, error) {
+	names, err := fs.Glob(migrationFS, "migrations/*.sql")
+	if err != nil {
+		return nil, err
+	}
+	out := make([]migration, 0, len(names))
+	for _, name := range names {
+		base := strings.TrimPrefix(name, "migrations/")
+		version, err := strconv.Atoi(strings.SplitN(base, "_", 2)[0])
+		if err != nil {
+			return nil, fmt.Errorf("миграция %s: имя должно начинаться с номера версии", base)
+		}
+		data, err := migrationFS.ReadFile(name)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, migration{version: version, name: base, sql: string(data)})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].version < out[j].version })
+	for i := 1; i < len(out); i++ {
+		if out[i].version == out[i-1].version {
+			return nil, fmt.Errorf("миграции %s и %s с одной версией", out[i-1].name, out[i].name)
+		}
+	}
+	return out, nil
+}
+
+// Migrate применяет недостающие миграции в одной транзакции и записывает их версии в таблицу
+// notephee_schema_migrations.
+func Migrate(ctx context.Context, db *sql.DB) error {
+	list, err := migrations()
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("не удалось начать миграцию: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	_, err = tx.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS notephee_schema_migrations (
+	version INTEGER PRIMARY KEY,
+	applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+)`)
+	if err != nil {
+		return fmt.Errorf("не удалось создать таблицу миграций: %w", err)
+	}
+
+	var current int
+	if err := tx.QueryRowContext(ctx, "SELECT COALESCE(MAX(version), 0) FROM notephee_schema_migrations").Scan(&current); err != nil {
+		return fmt.Errorf("не удалось прочитать версию схемы: %w", err)
+	}
+	for _, m := range list {
+		if m.version <= current {
+			continue
+		}
+		if _, err := tx.ExecContext(ctx, m.sql); err != nil {
+			return fmt.Errorf("миграция %s: %w", m.name, err)
+		}
+		if _, err := tx.ExecContext(ctx, "INSERT INTO notephee_schema_migrations (version) VALUES (?)", m.version); err != nil {
+			return fmt.Errorf("миграция %s: %w", m.name, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("не удалось завершить миграцию: %w", err)
+	}
+	return nil
+}
+
+// NewDeliveryLog возвращает журнал доставки в таблицах notephee_deliveries и notephee_deliveries_engagement,
+// созданных Migrate. Журнал реализует delivery.DeliveryLog и delivery.EngagementLog.
+func NewDeliveryLog(db *sql.DB) *delivery.SQLLog {
+	return delivery.NewSQLLog(db, "notephee_deliveries", delivery.QuestionPlaceholder)
+}
+
+// OffsetStore — telegram.OffsetStore в таблице notephee_telegram_offsets.
+type OffsetStore struct {
+	db  *sql.DB
+	bot string
+}
+
+// NewOffsetStore создаёт хранилище offset бота bot: каждому боту нужно своё имя.
+func NewOffsetStore(db *sql.DB, bot string) *OffsetStore {
+	return &OffsetStore{db: db, bot: bot}
+}
+
+// Load возвращает сохранённый offset или 0, если его ещё нет.
+func (s *OffsetStore) Load(ctx context.Context) (int64, error) {
+	var offset int64
+	err := s.db.QueryRowContext(ctx, "SELECT offset_id FROM notephee_telegram_offsets WHERE bot = ?", s.bot).Scan(&offset)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("не удалось прочитать offset: %w", err)
+	}
+	return offset, nil
+}
+
+// Save сохраняет offset.
+func (s *OffsetStore) Save(ctx context.Context, offset int64) error {
+	_, err := s.db.ExecContext(ctx, `INSERT INTO notephee_telegram_offsets (bot, offset_id) VALUES (?, ?)
+ON CONFLICT (bot) DO UPDATE SET offset_id = excluded.offset_id`, s.bot, offset)
+	if err != nil {
+		return fmt.Errorf("не удалось сохранить offset: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,148 @@
+package sqlite
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/epheer/notephee/broadcast"
+	"github.com/epheer/notephee/delivery"
+	"github.com/epheer/notephee/delivery/deliverytest"
+	"github.com/epheer/notephee/preferences"
+	"github.com/epheer/notephee/suppression"
+	"github.com/epheer/notephee/telegram"
+)
+
+func TestStores(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "notephee.db")
+	db, err := Open(path)
+	if err != nil {
+		t.Fatalf("Ошибка Open: %v", err)
+	}
+	defer func() { _ = db.Close() }()
+	for range 2 {
+		if err := Migrate(ctx, db); err != nil {
+			t.Fatalf("Ошибка Migrate: %v", err)
+		}
+	}
+	now := time.Now().UTC()
+
+	bindings := NewBindingStore(db)
+	_ = bindings.Save(ctx, telegram.Binding{UserID: "u1", ChatID: 2, CreatedAt: now.Add(time.Second)})
+	_ = bindings.Save(ctx, telegram.Binding{UserID: "u1", ChatID: 1, CreatedAt: now, Metadata: map[string]string{"роль": "админ"}})
+	got, err := bindings.ByUser(ctx, "u1")
+	if err != nil || len(got) != 2 || got[0].ChatID != 1 || got[0].Metadata["роль"] != "админ" || !got[0].CreatedAt.Equal(now) {
+		t.Fatalf("неверные привязки u1: %+v %v", got, err)
+	}
+	_ = bindings.Save(ctx, telegram.Binding{UserID: "u2", ChatID: 2, CreatedAt: now})
+	_ = bindings.Delete(ctx, "u1", 2)
+	if b, err := bindings.ByChat(ctx, 2); err != nil || b.UserID != "u2" {
+		t.Fatalf("привязка чата 2 должна перейти к u2: %+v %v", b, err)
+	}
+	_ = bindings.Delete(ctx, "u1", 1)
+	if _, err := bindings.ByChat(ctx, 1); !errors.Is(err, telegram.ErrBindingNotFound) {
+		t.Fatalf("ожидалась ErrBindingNotFound, получено %v", err)
+	}
+
+	offsets := NewOffsetStore(db, "mybot")
+	_ = offsets.Save(ctx, 41)
+	_ = offsets.Save(ctx, 42)
+	if offset, err := offsets.Load(ctx); offset != 42 || err != nil {
+		t.Fatalf("ожидался offset 42, получено %d %v", offset, err)
+	}
+	if offset, _ := NewOffsetStore(db, "other").Load(ctx); offset != 0 {
+		t.Fatalf("offset другого бота должен быть 0, получено %d", offset)
+	}
+
+	subs := NewSubscriptionStore(db)
+	_ = subs.Set(ctx, preferences.Preference{Subject: "u1", Category: "marketing", Allowed: true, UpdatedAt: now})
+	_ = subs.Set(ctx, preferences.Preference{Subject: "u1", Channel: "email", Category: "alerts", UpdatedAt: now})
+	if list, err := subs.List(ctx, "u1"); err != nil || len(list) != 2 || list[0].Category != "alerts" || list[0].Allowed {
+		t.Fatalf("неверные подписки: %+v %v", list, err)
+	}
+	_ = subs.Delete(ctx, "u1", "email", "alerts")
+	if _, ok, _ := subs.Get(ctx, "u1", "email", "alerts"); ok {
+		t.Fatal("удалённая подписка найдена")
+	}
+
+	log := NewDeliveryLog(db)
+	rec := delivery.Record{ID: "r1", Channel: "telegram", UserID: "u1", Template: "welcome@v2", Status: delivery.StatusFailed, CreatedAt: now, CompletedAt: now}
+	_ = log.Save(ctx, rec)
+	if failed, err := log.FailedSince(ctx, now.Add(-time.Minute)); err != nil || len(failed) != 1 {
+		t.Fatalf("неудачная попытка не найдена: %+v %v", failed, err)
+	}
+	rec.Status = delivery.StatusSent
+	if err := log.Save(ctx, rec); err != nil {
+		t.Fatalf("повторная попытка не обновила запись: %v", err)
+	}
+	if got, err := log.Get(ctx, "r1"); err != nil || got.Status != delivery.StatusSent || got.Template != "welcome@v2" {
+		t.Fatalf("неверная запись журнала: %+v %v", got, err)
+	}
+
+	suppressed := NewSuppressionStore(db)
+	_ = suppressed.Suppress(ctx, suppression.Entry{Channel: "email", Address: "User@Example.com", Reason: suppression.ReasonUnsubscribe, List: "news", CreatedAt: now})
+	_ = suppressed.Suppress(ctx, suppression.Entry{Channel: "email", Address: "gone@example.com", Reason: suppression.ReasonBounce, List: "news", CreatedAt: now})
+	if ok, err := suppressed.IsSuppressed(ctx, "email", "user@example.com", "news"); !ok || err != nil {
+		t.Fatalf("отписка от списка не найдена: %v", err)
+	}
+	if ok, _ := suppressed.IsSuppressed(ctx, "email", "user@example.com", ""); ok {
+		t.Fatal("отписка от списка не должна действовать на другие письма")
+	}
+	if ok, _ := suppressed.IsSuppressed(ctx, "email", "gone@example.com", "billing"); !ok {
+		t.Fatal("недоставляемый адрес должен блокироваться для всех рассылок")
+	}
+	_ = suppressed.Remove(ctx, "email", "USER@example.com")
+	if ok, _ := suppressed.IsSuppressed(ctx, "email", "user@example.com", "news"); ok {
+		t.Fatal("блокировка не снята")
+	}
+
+	jobs := NewJobStore(db)
+	job := &broadcast.Job{ID: "j1", Recipients: []string{"1", "2"}, Status: broadcast.StatusRunning, CreatedAt: now, UpdatedAt: now}
+	_ = jobs.Save(ctx, job)
+	_ = jobs.Save(ctx, &broadcast.Job{ID: "j2", Status: broadcast.StatusCompleted, CreatedAt: now, UpdatedAt: now})
+	job.Cursor, job.Failed = 1, 1
+	failure := broadcast.Failure{Position: 0, Recipient: "1", Kind: broadcast.KindOther, Error: "ошибка"}
+	if err := jobs.Checkpoint(ctx, job, []broadcast.Failure{failure}); err != nil {
+		t.Fatalf("Ошибка Checkpoint: %v", err)
+	}
+	unfinished, err := jobs.Unfinished(ctx)
+	if err != nil || len(unfinished) != 1 || unfinished[0].Cursor != 1 || len(unfinished[0].Recipients) != 2 {
+		t.Fatalf("неверные незавершённые задания: %+v %v", unfinished, err)
+	}
+	if failures := unfinished[0].Failures; len(failures) != 1 || failures[0] != failure {
+		t.Fatalf("ошибки не сохранены: %+v", failures)
+	}
+	if err := jobs.Checkpoint(ctx, &broadcast.Job{ID: "j3"}, nil); !errors.Is(err, broadcast.ErrJobNotFound) {
+		t.Fatalf("ожидалась ErrJobNotFound для чекпойнта, получено %v", err)
+	}
+	if _, err := jobs.Load(ctx, "j3"); !errors.Is(err, broadcast.ErrJobNotFound) {
+		t.Fatalf("ожидалась ErrJobNotFound, получено %v", err)
+	}
+
+	// Данные переживают повторное открытие файла
+	db.Close()
+	db, err = Open(path)
+	if err != nil {
+		t.Fatalf("Ошибка повторного Open: %v", err)
+	}
+	if offset, _ := NewOffsetStore(db, "mybot").Load(ctx); offset != 42 {
+		t.Fatalf("offset не сохранился в файле: %d", offset)
+	}
+}
+
+func TestDeliveryLog(t *testing.T) {
+	deliverytest.Run(t, func(t *testing.T) delivery.DeliveryLog {
+		db, err := Open(filepath.Join(t.TempDir(), "notephee.db"))
+		if err != nil {
+			t.Fatalf("Ошибка Open: %v", err)
+		}
+		t.Cleanup(func() { _ = db.Close() })
+		if err := Migrate(context.Background(), db); err != nil {
+			t.Fatalf("Ошибка Migrate: %v", err)
+		}
+		return NewDeliveryLog(db)
+	})
+}
@@ -0,0 +1,40 @@
+package sqlite
+
+import (
+	"database/sql"
+
+	"github.com/epheer/notephee/delivery"
+	"github.com/epheer/notephee/store/sqlstore"
+)
+
+// BindingStore — telegram.BindingStore в таблице notephee_telegram_bindings.
+type BindingStore = sqlstore.BindingStore
+
+// NewBindingStore создаёт хранилище привязок Telegram.
+func NewBindingStore(db *sql.DB) *BindingStore {
+	return sqlstore.NewBindingStore(db, delivery.QuestionPlaceholder)
+}
+
+// SubscriptionStore — preferences.Store в таблице notephee_subscriptions.
+type SubscriptionStore = sqlstore.SubscriptionStore
+
+// NewSubscriptionStore создаёт хранилище подписок.
+func NewSubscriptionStore(db *sql.DB) *SubscriptionStore {
+	return sqlstore.NewSubscriptionStore(db, delivery.QuestionPlaceholder)
+}
+
+// JobStore — broadcast.JobStore в таблице notephee_broadcast_jobs.
+type JobStore = sqlstore.JobStore
+
+// NewJobStore создаёт хранилище заданий рассылок.
+func NewJobStore(db *sql.DB) *JobStore {
+	return sqlstore.NewJobStore(db, delivery.QuestionPlaceholder)
+}
+
+// SuppressionStore — suppression.Store в таблице notephee_suppressions.
+type SuppressionStore = sqlstore.SuppressionStore
+
+// NewSuppressionStore создаёт список подавления.
+func NewSuppressionStore(db *sql.DB) *SuppressionStore {
+	return sqlstore.NewSuppressionStore(db, delivery.QuestionPlaceholder)
+}
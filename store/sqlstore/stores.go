@@ -0,0 +1,486 @@
+// Package sqlstore — общая реализация хранилищ notephee поверх database/sql для модулей store/postgres
+// и store/sqlite. Запросы отличаются только плейсхолдерами параметров (delivery.Placeholder), а схему
+// создают миграции этих модулей.
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/epheer/notephee/broadcast"
+	"github.com/epheer/notephee/delivery"
+	"github.com/epheer/notephee/preferences"
+	"github.com/epheer/notephee/suppression"
+	"github.com/epheer/notephee/telegram"
+)
+
+// placeholders возвращает плейсхолдеры параметров с 1 по n через запятую.
+func placeholders(ph delivery.Placeholder, n int) string {
+	out := make([]string, n)
+	for i := range out {
+		out[i] = ph(i + 1)
+	}
+	return strings.Join(out, ", ")
+}
+
+// BindingStore — telegram.BindingStore в таблице notephee_telegram_bindings.
+type BindingStore struct {
+	db *sql.DB
+	ph delivery.Placeholder
+}
+
+// NewBindingStore создаёт хранилище привязок Telegram с плейсхолдерами ph.
+func NewBindingStore(db *sql.DB, ph delivery.Placeholder) *BindingStore {
+	return &BindingStore{db: db, ph: ph}
+}
+
+const bindingColumns = "user_id, chat_id, bot, metadata, created_at"
+
+// Save сохраняет привязку, заменяя прежнюю привязку того же чата.
+func (s *BindingStore) Save(ctx context.Context, b telegram.Binding) error {
+	metadata, err := json.Marshal(b.Metadata)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.ExecContext(ctx, `INSERT INTO notephee_telegram_bindings (`+bindingColumns+`) VALUES (`+placeholders(s.ph, 5)+`)
+ON CONFLICT (chat_id) DO UPDATE SET user_id = EXCLUDED.user_id, bot = EXCLUDED.bot,
+	metadata = EXCLUDED.metadata, created_at = EXCLUDED.created_at`,
+		b.UserID, b.ChatID, b.Bot, string(metadata), b.CreatedAt.UTC())
+	if err != nil {
+		return fmt.Errorf("не удалось сохранить привязку Telegram: %w", err)
+	}
+	return nil
+}
+
+// ByUser возвращает привязки пользователя в порядке создания.
+func (s *BindingStore) ByUser(ctx context.Context, userID string) ([]telegram.Binding, error) {
+	return s.query(ctx, "WHERE user_id = "+s.ph(1), userID)
+}
+
+// ByChat возвращает привязку чата или telegram.ErrBindingNotFound.
+func (s *BindingStore) ByChat(ctx context.Context, chatID int64) (telegram.Binding, error) {
+	bindings, err := s.query(ctx, "WHERE chat_id = "+s.ph(1), chatID)
+	if err != nil {
+		return telegram.Binding{}, err
+	}
+	if len(bindings) == 0 {
+		return telegram.Binding{}, telegram.ErrBindingNotFound
+	}
+	return bindings[0], nil
+}
+
+// List возвращает все привязки в порядке создания.
+func (s *BindingStore) List(ctx context.Context) ([]telegram.Binding, error) {
+	return s.query(ctx, "")
+}
+
+// Delete удаляет привязку чата chatID к пользователю userID.
+func (s *BindingStore) Delete(ctx context.Context, userID string, chatID int64) error {
+	_, err := s.db.ExecContext(ctx, "DELETE FROM notephee_telegram_bindings WHERE user_id = "+s.ph(1)+" AND chat_id = "+s.ph(2),
+		userID, chatID)
+	if err != nil {
+		return fmt.Errorf("не удалось удалить привязку Telegram: %w", err)
+	}
+	return nil
+}
+
+func (s *BindingStore) query(ctx context.Context, where string, args ...any) ([]telegram.Binding, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT "+bindingColumns+" FROM notephee_telegram_bindings "+where+
+		" ORDER BY created_at, chat_id", args...)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось прочитать привязки Telegram: %w", err)
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	out := make([]telegram.Binding, 0)
+	for rows.Next() {
+		var (
+			b        telegram.Binding
+			metadata []byte
+		)
+		if err := rows.Scan(&b.UserID, &b.ChatID, &b.Bot, &metadata, &b.CreatedAt); err != nil {
+			return nil, fmt.Errorf("не удалось прочитать привязку Telegram: %w", err)
+		}
+		if err := json.Unmarshal(metadata, &b.Metadata); err != nil {
+			return nil, fmt.Errorf("повреждённые метаданные привязки чата %d: %w", b.ChatID, err)
+		}
+		out = append(out, b)
+	}
+	return out, rows.Err()
+}
+
+// SubscriptionStore — preferences.Store в таблице notephee_subscriptions: согласия и отказы
+// получателей по категориям уведомлений.
+type SubscriptionStore struct {
+	db *sql.DB
+	ph delivery.Placeholder
+}
+
+// NewSubscriptionStore создаёт хранилище подписок с плейсхолдерами ph.
+func NewSubscriptionStore(db *sql.DB, ph delivery.Placeholder) *SubscriptionStore {
+	return &SubscriptionStore{db: db, ph: ph}
+}
+
+// Set сохраняет решение, заменяя прежнее для той же тройки (subject, channel, category).
+func (s *SubscriptionStore) Set(ctx context.Context, p preferences.Preference) error {
+	_, err := s.db.ExecContext(ctx, `INSERT INTO notephee_subscriptions (subject, channel, category, allowed, updated_at)
+VALUES (`+placeholders(s.ph, 5)+`)
+ON CONFLICT (subject, channel, category) DO UPDATE SET allowed = EXCLUDED.allowed, updated_at = EXCLUDED.updated_at`,
+		p.Subject, p.Channel, p.Category, p.Allowed, p.UpdatedAt.UTC())
+	if err != nil {
+		return fmt.Errorf("не удалось сохранить подписку: %w", err)
+	}
+	return nil
+}
+
+// Get возвращает решение для тройки; false, если решения нет.
+func (s *SubscriptionStore) Get(ctx context.Context, subject, channel, category string) (preferences.Preference, bool, error) {
+	p := preferences.Preference{Subject: subject, Channel: channel, Category: category}
+	err := s.db.QueryRowContext(ctx, `SELECT allowed, updated_at FROM notephee_subscriptions
+WHERE subject = `+s.ph(1)+` AND channel = `+s.ph(2)+` AND category = `+s.ph(3), subject, channel, category).Scan(&p.Allowed, &p.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return preferences.Preference{}, false, nil
+	}
+	if err != nil {
+		return preferences.Preference{}, false, fmt.Errorf("не удалось прочитать подписку: %w", err)
+	}
+	return p, true, nil
+}
+
+// List возвращает все решения получателя subject, упорядоченные по категории и каналу.
+func (s *SubscriptionStore) List(ctx context.Context, subject string) ([]preferences.Preference, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT channel, category, allowed, updated_at FROM notephee_subscriptions
+WHERE subject = `+s.ph(1)+` ORDER BY category, channel`, subject)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось прочитать подписки: %w", err)
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	out := make([]preferences.Preference, 0)
+	for rows.Next() {
+		p := preferences.Preference{Subject: subject}
+		if err := rows.Scan(&p.Channel, &p.Category, &p.Allowed, &p.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("не удалось прочитать подписку: %w", err)
+		}
+		out = append(out, p)
+	}
+	return out, rows.Err()
+}
+
+// Delete удаляет решение, возвращая категорию к поведению по умолчанию.
+func (s *SubscriptionStore) Delete(ctx context.Context, subject, channel, category string) error {
+	_, err := s.db.ExecContext(ctx, "DELETE FROM notephee_subscriptions WHERE subject = "+s.ph(1)+" AND channel = "+s.ph(2)+" AND category = "+s.ph(3),
+		subject, channel, category)
+	if err != nil {
+		return fmt.Errorf("не удалось удалить подписку: %w", err)
+	}
+	return nil
+}
+
+// JobStore — broadcast.JobStore в таблице notephee_broadcast_jobs. Курсор, счётчики и текст задания
+// хранятся в JSON, состояние — отдельным столбцом для выборки незавершённых, а получатели и ошибки —
+// построчно в notephee_broadcast_recipients и notephee_broadcast_failures, поэтому чекпойнт не
+// перезаписывает весь список.
+type JobStore struct {
+	db *sql.DB
+	ph delivery.Placeholder
+}
+
+// NewJobStore создаёт хранилище заданий рассылок с плейсхолдерами ph.
+func NewJobStore(db *sql.DB, ph delivery.Placeholder) *JobStore {
+	return &JobStore{db: db, ph: ph}
+}
+
+// batchRows — сколько строк вставляется одним запросом: SQLite ограничивает число параметров.
+const batchRows = 200
+
+// Save создаёт задание или заменяет его целиком, вместе с получателями и ошибками.
+func (s *JobStore) Save(ctx context.Context, job *broadcast.Job) error {
+	if err := s.inTx(ctx, func(tx *sql.Tx) error { return s.save(ctx, tx, job) }); err != nil {
+		return fmt.Errorf("не удалось сохранить задание рассылки %s: %w", job.ID, err)
+	}
+	return nil
+}
+
+// Checkpoint обновляет курсор и счётчики задания и дописывает ошибки в одной транзакции.
+func (s *JobStore) Checkpoint(ctx context.Context, job *broadcast.Job, failures []broadcast.Failure) error {
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		var legacy bool
+		err := tx.QueryRowContext(ctx, `SELECT NOT EXISTS (SELECT 1 FROM notephee_broadcast_recipients WHERE job_id = `+s.ph(1)+`)
+AND EXISTS (SELECT 1 FROM notephee_broadcast_jobs WHERE id = `+s.ph(2)+`)`, job.ID, job.ID).Scan(&legacy)
+		if err != nil {
+			return err
+		}
+		if legacy && len(job.Recipients) > 0 {
+			// Задание сохранено прежней версией целиком в JSON: переносим получателей в таблицу
+			return s.save(ctx, tx, job)
+		}
+		if err := s.updateHeader(ctx, tx, job); err != nil {
+			return err
+		}
+		return s.insertFailures(ctx, tx, job.ID, failures)
+	})
+	if errors.Is(err, broadcast.ErrJobNotFound) {
+		return err
+	}
+	if err != nil {
+		return fmt.Errorf("не удалось сохранить курсор рассылки %s: %w", job.ID, err)
+	}
+	return nil
+}
+
+func (s *JobStore) save(ctx context.Context, tx *sql.Tx, job *broadcast.Job) error {
+	data, err := header(job)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `INSERT INTO notephee_broadcast_jobs (id, status, job, created_at, updated_at)
+VALUES (`+placeholders(s.ph, 5)+`)
+ON CONFLICT (id) DO UPDATE SET status = EXCLUDED.status, job = EXCLUDED.job, updated_at = EXCLUDED.updated_at`,
+		job.ID, string(job.Status), data, job.CreatedAt.UTC(), job.UpdatedAt.UTC())
+	if err != nil {
+		return err
+	}
+	for _, table := range []string{"notephee_broadcast_recipients", "notephee_broadcast_failures"} {
+		if _, err := tx.ExecContext(ctx, "DELETE FROM "+table+" WHERE job_id = "+s.ph(1), job.ID); err != nil {
+			return err
+		}
+	}
+	rows := make([][]any, len(job.Recipients))
+	for i, to := range job.Recipients {
+		rows[i] = []any{job.ID, i, to}
+	}
+	if err := s.insert(ctx, tx, "INSERT INTO notephee_broadcast_recipients (job_id, seq, address) VALUES ", "", rows); err != nil {
+		return err
+	}
+	return s.insertFailures(ctx, tx, job.ID, job.Failures)
+}
+
+// updateHeader сохраняет курсор, счётчики и состояние задания или возвращает broadcast.ErrJobNotFound.
+func (s *JobStore) updateHeader(ctx context.Context, tx *sql.Tx, job *broadcast.Job) error {
+	data, err := header(job)
+	if err != nil {
+		return err
+	}
+	res, err := tx.ExecContext(ctx, "UPDATE notephee_broadcast_jobs SET status = "+s.ph(1)+", job = "+s.ph(2)+
+		", updated_at = "+s.ph(3)+" WHERE id = "+s.ph(4), string(job.Status), data, job.UpdatedAt.UTC(), job.ID)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return broadcast.ErrJobNotFound
+	}
+	return nil
+}
+
+func (s *JobStore) insertFailures(ctx context.Context, tx *sql.Tx, jobID string, failures []broadcast.Failure) error {
+	rows := make([][]any, len(failures))
+	for i, f := range failures {
+		rows[i] = []any{jobID, f.Position, f.Recipient, f.Kind, f.Error}
+	}
+	// Повторная ошибка того же получателя после сбоя заменяет прежнюю
+	return s.insert(ctx, tx, "INSERT INTO notephee_broadcast_failures (job_id, seq, recipient, kind, error) VALUES ",
+		" ON CONFLICT (job_id, seq) DO UPDATE SET recipient = EXCLUDED.recipient, kind = EXCLUDED.kind, error = EXCLUDED.error", rows)
+}
+
+// insert вставляет строки rows порциями по batchRows.
+func (s *JobStore) insert(ctx context.Context, tx *sql.Tx, prefix, suffix string, rows [][]any) error {
+	for len(rows) > 0 {
+		batch := rows[:min(len(rows), batchRows)]
+		rows = rows[len(batch):]
+
+		var query strings.Builder
+		query.WriteString(prefix)
+		var args []any
+		for i, row := range batch {
+			if i > 0 {
+				query.WriteString(", ")
+			}
+			values := make([]string, len(row))
+			for j := range row {
+				values[j] = s.ph(len(args) + j + 1)
+			}
+			query.WriteString("(" + strings.Join(values, ", ") + ")")
+			args = append(args, row...)
+		}
+		query.WriteString(suffix)
+		if _, err := tx.ExecContext(ctx, query.String(), args...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *JobStore) inTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// header возвращает JSON задания без получателей и ошибок.
+func header(job *broadcast.Job) (string, error) {
+	h := *job
+	h.Recipients, h.Failures = nil, nil
+	data, err := json.Marshal(h)
+	return string(data), err
+}
+
+// Load возвращает задание по идентификатору или broadcast.ErrJobNotFound.
+func (s *JobStore) Load(ctx context.Context, id string) (*broadcast.Job, error) {
+	jobs, err := s.query(ctx, "WHERE id = "+s.ph(1), id)
+	if err != nil {
+		return nil, err
+	}
+	if len(jobs) == 0 {
+		return nil, broadcast.ErrJobNotFound
+	}
+	return jobs[0], nil
+}
+
+// Unfinished возвращает задания в состояниях pending, running и paused в порядке создания.
+func (s *JobStore) Unfinished(ctx context.Context) ([]*broadcast.Job, error) {
+	return s.query(ctx, "WHERE status <> "+s.ph(1), string(broadcast.StatusCompleted))
+}
+
+func (s *JobStore) query(ctx context.Context, where string, args ...any) ([]*broadcast.Job, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT job FROM notephee_broadcast_jobs "+where+" ORDER BY created_at, id", args...)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось прочитать задания рассылок: %w", err)
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	var out []*broadcast.Job
+	for rows.Next() {
+		var data []byte
+		if err := rows.Scan(&data); err != nil {
+			return nil, fmt.Errorf("не удалось прочитать задание рассылки: %w", err)
+		}
+		job := &broadcast.Job{}
+		if err := json.Unmarshal(data, job); err != nil {
+			return nil, fmt.Errorf("повреждённое задание рассылки: %w", err)
+		}
+		out = append(out, job)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("не удалось прочитать задания рассылок: %w", err)
+	}
+	_ = rows.Close()
+
+	for _, job := range out {
+		if err := s.loadItems(ctx, job); err != nil {
+			return nil, fmt.Errorf("не удалось прочитать задание рассылки %s: %w", job.ID, err)
+		}
+	}
+	return out, nil
+}
+
+// loadItems читает получателей и ошибки задания. Задание прежней версии без строк в таблицах
+// получателей остаётся с теми, что были в JSON.
+func (s *JobStore) loadItems(ctx context.Context, job *broadcast.Job) error {
+	rows, err := s.db.QueryContext(ctx, "SELECT address FROM notephee_broadcast_recipients WHERE job_id = "+s.ph(1)+" ORDER BY seq", job.ID)
+	if err != nil {
+		return err
+	}
+	var recipients []string
+	for rows.Next() {
+		var to string
+		if err := rows.Scan(&to); err != nil {
+			_ = rows.Close()
+			return err
+		}
+		recipients = append(recipients, to)
+	}
+	_ = rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if recipients == nil {
+		return nil
+	}
+	job.Recipients = recipients
+
+	rows, err = s.db.QueryContext(ctx, "SELECT seq, recipient, kind, error FROM notephee_broadcast_failures WHERE job_id = "+s.ph(1)+" ORDER BY seq", job.ID)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+	job.Failures = nil
+	for rows.Next() {
+		var f broadcast.Failure
+		if err := rows.Scan(&f.Position, &f.Recipient, &f.Kind, &f.Error); err != nil {
+			return err
+		}
+		job.Failures = append(job.Failures, f)
+	}
+	return rows.Err()
+}
+
+// SuppressionStore — suppression.Store в таблице notephee_suppressions: по записи на канал, адрес
+// и список рассылки (пустой список — все рассылки).
+type SuppressionStore struct {
+	db *sql.DB
+	ph delivery.Placeholder
+}
+
+// NewSuppressionStore создаёт список подавления с плейсхолдерами ph.
+func NewSuppressionStore(db *sql.DB, ph delivery.Placeholder) *SuppressionStore {
+	return &SuppressionStore{db: db, ph: ph}
+}
+
+// Suppress добавляет адрес в список подавления, заменяя прежнюю запись для того же списка.
+func (s *SuppressionStore) Suppress(ctx context.Context, entry suppression.Entry) error {
+	if entry.CreatedAt.IsZero() {
+		entry.CreatedAt = time.Now()
+	}
+	_, err := s.db.ExecContext(ctx, `INSERT INTO notephee_suppressions (channel, address, list, reason, created_at)
+VALUES (`+placeholders(s.ph, 5)+`)
+ON CONFLICT (channel, address, list) DO UPDATE SET reason = EXCLUDED.reason, created_at = EXCLUDED.created_at`,
+		entry.Channel, suppression.Normalize(entry.Address), entry.Scope(), string(entry.Reason), entry.CreatedAt.UTC())
+	if err != nil {
+		return fmt.Errorf("не удалось сохранить запись списка подавления: %w", err)
+	}
+	return nil
+}
+
+// IsSuppressed сообщает, заблокирован ли адрес в канале для списка рассылки list.
+func (s *SuppressionStore) IsSuppressed(ctx context.Context, channel, address, list string) (bool, error) {
+	var n int
+	err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM notephee_suppressions
+WHERE channel = `+s.ph(1)+` AND address = `+s.ph(2)+` AND list IN ('', `+s.ph(3)+`)`,
+		channel, suppression.Normalize(address), list).Scan(&n)
+	if err != nil {
+		return false, fmt.Errorf("не удалось прочитать список подавления: %w", err)
+	}
+	return n > 0, nil
+}
+
+// Remove снимает с адреса все блокировки в канале.
+func (s *SuppressionStore) Remove(ctx context.Context, channel, address string) error {
+	_, err := s.db.ExecContext(ctx, "DELETE FROM notephee_suppressions WHERE channel = "+s.ph(1)+" AND address = "+s.ph(2),
+		channel, suppression.Normalize(address))
+	if err != nil {
+		return fmt.Errorf("не удалось удалить запись списка подавления: %w", err)
+	}
+	return nil
+}
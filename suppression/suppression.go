@@ -0,0 +1,107 @@
+package suppression
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Reason описывает причину блокировки адреса.
+type Reason string
+
+// Причины добавления адреса в список подавления
+const (
+	ReasonUnsubscribe Reason = "unsubscribe" // Пользователь отписался
+	ReasonComplaint   Reason = "complaint"   // Пользователь пожаловался на спам
+	ReasonBounce      Reason = "bounce"      // Адрес недоставляем
+	ReasonManual      Reason = "manual"      // Добавлен вручную
+	ReasonBlocked     Reason = "blocked"     // Получатель заблокировал бота или удалил его из чата
+)
+
+// Entry — запись списка подавления.
+type Entry struct {
+	Channel   string    // Канал (email, telegram и т.д.)
+	Address   string    // Адрес получателя в этом канале
+	Reason    Reason    // Причина блокировки
+	List      string    // Список рассылки, от которого отписались (пусто — все рассылки); см. Scope
+	CreatedAt time.Time // Время добавления
+}
+
+// Scope возвращает список рассылки, к которому относится запись: List для отписки и пусто (все рассылки)
+// для остальных причин — недоставляемый адрес, жалоба или блокировка бота действуют на любую отправку.
+func (e Entry) Scope() string {
+	if e.Reason != ReasonUnsubscribe {
+		return ""
+	}
+	return e.List
+}
+
+// Store хранит адреса, на которые нельзя отправлять сообщения.
+//
+// Реализации должны быть безопасны для конкурентного использования.
+type Store interface {
+	// Suppress добавляет адрес в список подавления для списка рассылки entry.Scope().
+	Suppress(ctx context.Context, entry Entry) error
+	// IsSuppressed сообщает, заблокирован ли адрес в канале для отправки по списку рассылки list:
+	// есть запись на все рассылки или на этот список. Пустой list проверяет только записи на все рассылки.
+	IsSuppressed(ctx context.Context, channel, address, list string) (bool, error)
+	// Remove снимает с адреса все блокировки в канале, включая отписки от отдельных списков.
+	Remove(ctx context.Context, channel, address string) error
+}
+
+// Normalize приводит адрес к виду, в котором он хранится в списке подавления.
+func Normalize(address string) string {
+	return strings.ToLower(strings.TrimSpace(address))
+}
+
+// MemoryStore — реализация Store в памяти процесса.
+type MemoryStore struct {
+	mu      sync.RWMutex
+	entries map[string]map[string]Entry // Канал и адрес → список рассылки → запись
+}
+
+// NewMemoryStore создаёт пустой список подавления в памяти.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{entries: make(map[string]map[string]Entry)}
+}
+
+func key(channel, address string) string {
+	return channel + ":" + Normalize(address)
+}
+
+// Suppress добавляет адрес в список подавления.
+func (s *MemoryStore) Suppress(_ context.Context, entry Entry) error {
+	if entry.CreatedAt.IsZero() {
+		entry.CreatedAt = time.Now()
+	}
+	entry.List = entry.Scope()
+	k := key(entry.Channel, entry.Address)
+	s.mu.Lock()
+	if s.entries[k] == nil {
+		s.entries[k] = make(map[string]Entry)
+	}
+	s.entries[k][entry.List] = entry
+	s.mu.Unlock()
+	return nil
+}
+
+// IsSuppressed сообщает, заблокирован ли адрес в канале для списка рассылки list.
+func (s *MemoryStore) IsSuppressed(_ context.Context, channel, address, list string) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	lists := s.entries[key(channel, address)]
+	if _, ok := lists[""]; ok {
+		return true, nil
+	}
+	_, ok := lists[list]
+	return ok, nil
+}
+
+// Remove снимает с адреса все блокировки в канале.
+func (s *MemoryStore) Remove(_ context.Context, channel, address string) error {
+	s.mu.Lock()
+	delete(s.entries, key(channel, address))
+	s.mu.Unlock()
+	return nil
+}
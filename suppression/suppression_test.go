@@ -0,0 +1,34 @@
+package suppression_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/epheer/notephee/suppression"
+)
+
+func TestListScope(t *testing.T) {
+	store := suppression.NewMemoryStore()
+	ctx := context.Background()
+
+	_ = store.Suppress(ctx, suppression.Entry{Channel: "email", Address: "User@Example.com", Reason: suppression.ReasonUnsubscribe, List: "news"})
+	if ok, _ := store.IsSuppressed(ctx, "email", "user@example.com", "news"); !ok {
+		t.Fatal("отписка от списка должна действовать на этот список")
+	}
+	for _, list := range []string{"", "billing"} {
+		if ok, _ := store.IsSuppressed(ctx, "email", "user@example.com", list); ok {
+			t.Fatalf("отписка от news не должна действовать на список %q", list)
+		}
+	}
+
+	// Жалоба действует на все рассылки, даже если указан список
+	_ = store.Suppress(ctx, suppression.Entry{Channel: "email", Address: "user@example.com", Reason: suppression.ReasonComplaint, List: "news"})
+	if ok, _ := store.IsSuppressed(ctx, "email", "user@example.com", "billing"); !ok {
+		t.Fatal("жалоба должна действовать на любой список")
+	}
+
+	_ = store.Remove(ctx, "email", "user@example.com")
+	if ok, _ := store.IsSuppressed(ctx, "email", "user@example.com", "news"); ok {
+		t.Fatal("Remove должен снимать все блокировки адреса")
+	}
+}
@@ -0,0 +1,253 @@
+// Package teamchat реализует канал входящих вебхуков self-hosted чатов: Mattermost и Rocket.Chat.
+//
+// Каждый получатель — именованная цель со своим URL вебхука, каналом, именем и иконкой бота.
+package teamchat
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/epheer/notephee/delivery"
+	"github.com/epheer/notephee/notify"
+	"github.com/epheer/notephee/tracing"
+)
+
+// Channel — имя канала в notify.Registry и журнале доставки.
+const Channel = "teamchat"
+
+// Flavor — разновидность сервера чата: от неё зависят имена полей вебхука.
+type Flavor string
+
+const (
+	Mattermost Flavor = "mattermost"
+	RocketChat Flavor = "rocketchat"
+)
+
+// Пределы длины сообщения в символах по умолчанию.
+const (
+	MaxMattermostText = 16383 // Предел поста Mattermost
+	MaxRocketChatText = 5000  // Message_MaxAllowedSize в Rocket.Chat
+)
+
+// ErrUnknownTarget возвращается при отправке на незарегистрированную цель.
+var ErrUnknownTarget = errors.New("цель вебхука не найдена")
+
+// Target — настройки одной цели вебхука.
+type Target struct {
+	Name      string `json:"name"`                 // Имя цели; используется как notify.Message.To
+	Flavor    Flavor `json:"flavor"`               // mattermost или rocketchat
+	URL       string `json:"url"`                  // URL входящего вебхука
+	Channel   string `json:"channel,omitempty"`    // Канал вместо заданного в вебхуке (необязательно)
+	Username  string `json:"username,omitempty"`   // Отображаемое имя бота (необязательно)
+	IconURL   string `json:"icon_url,omitempty"`   // URL аватара (необязательно)
+	IconEmoji string `json:"icon_emoji,omitempty"` // Эмодзи вместо аватара, например :bell: (необязательно)
+}
+
+// ParseTargets разбирает список целей в формате JSON-массива объектов Target.
+func ParseTargets(data string) ([]Target, error) {
+	var targets []Target
+	if err := json.Unmarshal([]byte(data), &targets); err != nil {
+		return nil, fmt.Errorf("некорректный список целей вебхуков: %w", err)
+	}
+	for i, t := range targets {
+		if t.Name == "" || t.URL == "" {
+			return nil, fmt.Errorf("цель #%d: name и url обязательны", i)
+		}
+		if t.Flavor != Mattermost && t.Flavor != RocketChat {
+			return nil, fmt.Errorf("цель %s: неизвестный flavor %q", t.Name, t.Flavor)
+		}
+	}
+	return targets, nil
+}
+
+// Client отправляет сообщения во входящие вебхуки Mattermost и Rocket.Chat.
+type Client struct {
+	targets map[string]Target // Цели по имени
+	http    *http.Client      // HTTP-клиент
+	logger  *slog.Logger      // Логгер
+	Enabled bool              // Флаг доступности функционала
+
+	deliveryLog delivery.DeliveryLog // Журнал попыток отправки (необязательно)
+	inflight    notify.InFlight      // Начатые отправки, которых ждёт Close
+}
+
+// NewClient создаёт клиента с заданными целями. Клиент без целей отключён.
+func NewClient(targets []Target, logger *slog.Logger) *Client {
+	c := &Client{
+		targets: make(map[string]Target, len(targets)),
+		http:    &http.Client{Timeout: 10 * time.Second},
+		logger:  logger,
+	}
+	for _, t := range targets {
+		c.AddTarget(t)
+	}
+	return c
+}
+
+// AddTarget добавляет или заменяет цель и включает клиента.
+// Вызывается при настройке, до начала отправки.
+func (c *Client) AddTarget(t Target) {
+	c.targets[t.Name] = t
+	c.Enabled = true
+}
+
+// Targets возвращает имена целей в алфавитном порядке.
+func (c *Client) Targets() []string {
+	names := make([]string, 0, len(c.targets))
+	for name := range c.targets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// SetDeliveryLog подключает журнал, в который записывается каждая попытка отправки.
+func (c *Client) SetDeliveryLog(log delivery.DeliveryLog) {
+	c.deliveryLog = log
+}
+
+// Close перестаёт принимать новые отправки и ждёт завершения начатых до истечения ctx.
+// Отправки после Close возвращают notify.ErrClosed.
+func (c *Client) Close(ctx context.Context) error {
+	return c.inflight.Close(ctx)
+}
+
+// Channel возвращает имя канала для notify.Registry.
+func (c *Client) Channel() string {
+	return Channel
+}
+
+// Capabilities возвращает возможности канала: Markdown и тема жирной строкой над текстом.
+// Предел длины — наименьший среди целей: Rocket.Chat по умолчанию принимает меньше Mattermost.
+func (c *Client) Capabilities() notify.Capabilities {
+	limit := 0
+	for _, t := range c.targets {
+		n := MaxMattermostText
+		if t.Flavor == RocketChat {
+			n = MaxRocketChatText
+		}
+		if limit == 0 || n < limit {
+			limit = n
+		}
+	}
+	return notify.Capabilities{Channel: Channel, Subject: true, Markdown: true, MaxLength: limit}
+}
+
+// Send реализует notify.Sender: msg.To — имя цели. Тема, если есть, выводится жирной первой строкой.
+func (c *Client) Send(ctx context.Context, msg notify.Message) error {
+	text := msg.Text
+	if msg.Subject != "" {
+		text = "**" + msg.Subject + "**\n" + text
+	}
+
+	started := time.Now()
+	err := c.SendText(ctx, msg.To, text)
+	c.logDelivery(ctx, msg, started, err)
+	return err
+}
+
+// SendText отправляет текст в цель target.
+func (c *Client) SendText(ctx context.Context, target, text string) error {
+	if !c.Enabled {
+		return fmt.Errorf("функционал вебхуков чатов отключён: не заданы цели")
+	}
+	if err := c.inflight.Acquire(); err != nil {
+		return err
+	}
+	defer c.inflight.Release()
+
+	ctx, span := tracing.Start(ctx, Channel, target)
+	err := c.post(ctx, target, text)
+	tracing.End(span, err)
+	return err
+}
+
+// post отправляет текст в вебхук цели target и проверяет ответ.
+func (c *Client) post(ctx context.Context, target, text string) error {
+	t, ok := c.targets[target]
+	if !ok {
+		return fmt.Errorf("%s: %w", target, ErrUnknownTarget)
+	}
+
+	data, err := json.Marshal(payload(t, text))
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.URL, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("ошибка отправки в %s: %w", target, err)
+	}
+	tracing.HTTPStatus(ctx, resp.StatusCode)
+	defer func(Body io.ReadCloser) {
+		_ = Body.Close()
+	}(resp.Body)
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("ошибка вебхука %s: код %d: %s", target, resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	// Rocket.Chat может ответить 200 с {"success":false}
+	if t.Flavor == RocketChat {
+		var res struct {
+			Success bool   `json:"success"`
+			Error   string `json:"error"`
+		}
+		if json.Unmarshal(body, &res) == nil && !res.Success {
+			return fmt.Errorf("ошибка вебхука %s: %s", target, res.Error)
+		}
+	}
+	return nil
+}
+
+// payload собирает тело вебхука с именами полей, которые ожидает сервер цели.
+func payload(t Target, text string) map[string]string {
+	p := map[string]string{"text": text}
+	set := func(key, value string) {
+		if value != "" {
+			p[key] = value
+		}
+	}
+
+	set("channel", t.Channel)
+	switch t.Flavor {
+	case RocketChat:
+		set("alias", t.Username)
+		set("avatar", t.IconURL)
+		set("emoji", t.IconEmoji)
+	default:
+		set("username", t.Username)
+		set("icon_url", t.IconURL)
+		set("icon_emoji", t.IconEmoji)
+	}
+	return p
+}
+
+// logDelivery записывает попытку отправки в журнал доставки, если он подключён.
+func (c *Client) logDelivery(ctx context.Context, msg notify.Message, started time.Time, sendErr error) {
+	delivery.LogAttempt(ctx, c.deliveryLog, c.logger, delivery.Record{
+		ID:          msg.ID,
+		Channel:     Channel,
+		UserID:      msg.UserID,
+		Recipient:   msg.To,
+		MessageHash: delivery.Hash(msg.Subject, msg.Text),
+		Template:    msg.Template,
+		CreatedAt:   started,
+	}, sendErr)
+}
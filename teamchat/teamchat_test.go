@@ -0,0 +1,56 @@
+package teamchat_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/epheer/notephee/notify"
+	"github.com/epheer/notephee/teamchat"
+)
+
+func TestPayloadPerFlavor(t *testing.T) {
+	var got []map[string]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var p map[string]string
+		_ = json.NewDecoder(r.Body).Decode(&p)
+		got = append(got, p)
+		if r.URL.Path == "/rc" {
+			_, _ = w.Write([]byte(`{"success":true}`))
+			return
+		}
+		_, _ = w.Write([]byte("ok"))
+	}))
+	t.Cleanup(srv.Close)
+
+	targets, err := teamchat.ParseTargets(`[
+		{"name":"ops","flavor":"mattermost","url":"` + srv.URL + `/mm","channel":"alerts","username":"bot"},
+		{"name":"support","flavor":"rocketchat","url":"` + srv.URL + `/rc","username":"bot","icon_emoji":":bell:"}
+	]`)
+	if err != nil {
+		t.Fatalf("Ошибка ParseTargets: %v", err)
+	}
+	c := teamchat.NewClient(targets, slog.Default())
+	ctx := context.Background()
+
+	if err := c.Send(ctx, notify.Message{To: "ops", Text: "привет"}); err != nil {
+		t.Fatalf("Ошибка Send: %v", err)
+	}
+	if err := c.Send(ctx, notify.Message{To: "support", Text: "привет"}); err != nil {
+		t.Fatalf("Ошибка Send: %v", err)
+	}
+	if got[0]["username"] != "bot" || got[0]["channel"] != "alerts" {
+		t.Fatalf("неверное тело для Mattermost: %v", got[0])
+	}
+	if got[1]["alias"] != "bot" || got[1]["emoji"] != ":bell:" {
+		t.Fatalf("неверное тело для Rocket.Chat: %v", got[1])
+	}
+
+	if err := c.Send(ctx, notify.Message{To: "nobody", Text: "привет"}); !errors.Is(err, teamchat.ErrUnknownTarget) {
+		t.Fatalf("ожидалась ошибка неизвестной цели, получено: %v", err)
+	}
+}
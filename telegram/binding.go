@@ -2,51 +2,50 @@ package telegram
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
+	"maps"
 	"strings"
-	"sync"
 	"time"
 
 	"github.com/google/uuid"
+
+	"github.com/epheer/notephee/telegram/qrcode"
 )
 
 // Binding представляет успешную привязку между внутренним userID и Telegram chatID.
 type Binding struct {
-	UserID string // Внутренний идентификатор пользователя
-	ChatID int64  // Идентификатор чата в Telegram
+	UserID    string            // Внутренний идентификатор пользователя
+	ChatID    int64             // Идентификатор чата в Telegram
+	Bot       string            // Имя бота в Bots, через которого выполнена привязка; пусто для основного
+	Metadata  map[string]string // Метаданные инвайта из InviteOptions.Metadata
+	CreatedAt time.Time         // Время подтверждения привязки
 }
 
-// pendingBinding хранит временные данные до подтверждения пользователем /start.
-type pendingBinding struct {
-	UserID string    // Внутренний ID пользователя, инициировавшего инвайт
-	Expiry time.Time // Время окончания действия инвайта
+// InviteOptions — параметры отдельного инвайта. Нулевые значения дают одноразовый инвайт
+// со временем жизни BindingManager.
+type InviteOptions struct {
+	TTL      time.Duration     // Время жизни инвайта; 0 — время жизни BindingManager
+	Batch    string            // Партия инвайтов для подсчёта конверсии через Stats
+	Metadata map[string]string // Произвольные данные, которые попадут в Binding (роль, команда и т.д.)
+	MultiUse bool              // Инвайт могут использовать несколько чатов, например приглашение команды
+	MaxUses  int               // Предел привязок многоразового инвайта; 0 — без ограничения до истечения TTL
 }
 
 // BindingManager управляет созданием и проверкой Telegram-инвайтов.
 type BindingManager struct {
-	store  sync.Map      // Хранилище инвайтов по UUID
+	store  InviteStore   // Хранилище инвайтов по коду
 	ttl    time.Duration // Время жизни каждого инвайта
 	logger *slog.Logger  // Логгер для отладки
 	bot    string        // Имя Telegram-бота
-}
+	botKey string        // Имя бота в Bots для Binding.Bot
 
-// Update представляет одно обновление от Telegram API (например, входящее сообщение).
-type Update struct {
-	UpdateID int64 `json:"update_id"` // ID обновления
-	Message  struct {
-		Text string `json:"text"` // Текст сообщения
-		Chat struct {
-			ID int64 `json:"id"` // Chat ID, с которого пришло сообщение
-		} `json:"chat"`
-	} `json:"message"`
-}
+	tracker *inviteTracker // Статистика переходов и привязок по партиям
+	replies bindingReplies // Автоответы опроса на /start
 
-// UpdatesResponse — структура ответа Telegram API на метод getUpdates.
-type UpdatesResponse struct {
-	OK     bool     `json:"ok"`     // Статус ответа API
-	Result []Update `json:"result"` // Список новых обновлений
+	bindings BindingStore // Подтверждённые привязки
+	keys     [][]byte     // Ключи подписи инвайтов; первый подписывает новые (необязательно)
 }
 
 // NewBindingManager создаёт новый BindingManager с заданным временем жизни инвайтов.
@@ -59,9 +58,13 @@ func (c *TgClient) NewBindingManager(ttl time.Duration, logger *slog.Logger) *Bi
 	}
 
 	return &BindingManager{
-		ttl:    ttl,
-		logger: logger,
-		bot:    c.name,
+		store:   NewMemoryInviteStore(),
+		ttl:     ttl,
+		logger:  logger,
+		bot:     c.name,
+		tracker: newInviteTracker(),
+
+		bindings: NewMemoryBindingStore(),
 	}
 }
 
@@ -69,44 +72,151 @@ func (c *TgClient) NewBindingManager(ttl time.Duration, logger *slog.Logger) *Bi
 // Возвращает ссылку вида: https://t.me/<bot>?start=<uuid>
 //
 // userID — идентификатор пользователя, которому создаётся инвайт.
-func (bm *BindingManager) CreateInvite(userID string) string {
-	inviteCode := uuid.New().String()
-	bm.store.Store(inviteCode, pendingBinding{
-		UserID: userID,
-		Expiry: time.Now().Add(bm.ttl),
+// opts — параметры инвайта (необязательно; учитывается первый элемент): своё время жизни,
+// метаданные для Binding и многоразовое использование.
+//
+// Возвращает пустую строку, если инвайт не удалось сохранить (ошибка пишется в лог).
+func (bm *BindingManager) CreateInvite(userID string, opts ...InviteOptions) string {
+	var o InviteOptions
+	if len(opts) > 0 {
+		o = opts[0]
+	}
+	links, err := bm.createInvites([]string{userID}, o)
+	if err != nil {
+		bm.logger.Error("не удалось создать инвайт", "user_id", userID, "error", err)
+		return ""
+	}
+	return links[userID]
+}
+
+// CreateBatchInvite создаёт инвайт, как CreateInvite, и относит его к партии batch
+// (например, рассылке или экрану онбординга) для подсчёта конверсии через Stats.
+//
+// Возвращает пустую строку, если инвайт не удалось сохранить (ошибка пишется в лог).
+func (bm *BindingManager) CreateBatchInvite(batch, userID string) string {
+	return bm.CreateInvite(userID, InviteOptions{Batch: batch})
+}
+
+// CreateInvites выпускает инвайты сразу для множества пользователей — например, при импорте
+// тысяч учётных записей. Все коды сохраняются в хранилище одним вызовом InviteStore.Put.
+//
+// Возвращает соответствие userID → ссылка.
+func (bm *BindingManager) CreateInvites(userIDs []string) (map[string]string, error) {
+	return bm.createInvites(userIDs, InviteOptions{})
+}
+
+// SetInviteStore заменяет хранилище инвайтов (по умолчанию — в памяти процесса).
+func (bm *BindingManager) SetInviteStore(store InviteStore) {
+	bm.store = store
+}
+
+func (bm *BindingManager) createInvites(userIDs []string, opts InviteOptions) (map[string]string, error) {
+	ttl := bm.ttl
+	if opts.TTL > 0 {
+		ttl = opts.TTL
+	}
+	expiry := time.Now().Add(ttl)
+	invites := make([]Invite, 0, len(userIDs))
+	links := make(map[string]string, len(userIDs))
+	codes := make([]string, 0, len(userIDs))
+
+	for _, userID := range userIDs {
+		code := uuid.New().String()
+		invites = append(invites, Invite{
+			Code:     code,
+			UserID:   userID,
+			Batch:    opts.Batch,
+			Expiry:   expiry,
+			Metadata: maps.Clone(opts.Metadata),
+			MultiUse: opts.MultiUse,
+			MaxUses:  opts.MaxUses,
+		})
+		links[userID] = bm.link(code)
+		codes = append(codes, code)
+	}
+
+	if err := bm.store.Put(context.Background(), invites); err != nil {
+		return nil, fmt.Errorf("не удалось сохранить инвайты: %w", err)
+	}
+	for _, inv := range invites {
+		bm.tracker.created(inv.Code, opts.Batch)
+	}
+
+	// Один таймер на всю пачку вместо горутины на каждый инвайт
+	time.AfterFunc(ttl, func() {
+		if err := bm.store.Delete(context.Background(), codes); err != nil {
+			bm.logger.Warn("не удалось удалить просроченные инвайты", "error", err)
+		}
+		for _, code := range codes {
+			bm.tracker.expired(code)
+		}
 	})
 
-	go func() {
-		time.Sleep(bm.ttl)
-		bm.store.Delete(inviteCode)
-	}()
+	return links, nil
+}
 
-	return fmt.Sprintf("https://t.me/%s?start=%s", bm.bot, inviteCode)
+// link возвращает ссылку вида https://t.me/<bot>?start=<code>.
+func (bm *BindingManager) link(code string) string {
+	return fmt.Sprintf("https://t.me/%s?start=%s", bm.bot, bm.payload(code))
+}
+
+// CreateInviteQR создаёт инвайт, как CreateInvite, и дополнительно рисует его QR-код
+// для показа на экране киоска или в десктопном интерфейсе.
+//
+// Возвращает ссылку и изображение в формате, заданном opts.
+func (bm *BindingManager) CreateInviteQR(userID string, opts qrcode.Options) (string, []byte, error) {
+	link := bm.CreateInvite(userID)
+	img, err := qrcode.Render(link, opts)
+	if err != nil {
+		return "", nil, err
+	}
+	return link, img, nil
 }
 
-// ResolveBinding проверяет, существует ли данный инвайт и создаёт привязку chatID к userID.
+// ResolveBinding проверяет, существует ли данный инвайт, создаёт привязку chatID к userID
+// и сохраняет её в BindingStore.
 //
-// uuid — код из ссылки Telegram (/start <uuid>).
+// uuid — код из ссылки Telegram (/start <uuid>); с SetSigningKey — вместе с подписью.
 // chatID — идентификатор Telegram-чата, инициировавшего запрос.
 //
 // Возвращает Binding, если UUID действителен, или ошибку — если нет.
 func (bm *BindingManager) ResolveBinding(uuid string, chatID int64) (*Binding, error) {
-	val, ok := bm.store.Load(uuid)
-	if !ok {
-		return nil, fmt.Errorf("инвайт просрочен или не найден")
+	code, err := bm.verify(uuid)
+	if err != nil {
+		return nil, err
+	}
+	ctx := context.Background()
+	inv, err := bm.store.Take(ctx, code)
+	if err != nil {
+		return nil, err
 	}
-	bm.store.Delete(uuid)
 
-	p := val.(pendingBinding)
-	return &Binding{
-		UserID: p.UserID,
-		ChatID: chatID,
-	}, nil
+	binding := Binding{
+		UserID:    inv.UserID,
+		ChatID:    chatID,
+		Bot:       bm.botKey,
+		Metadata:  inv.Metadata,
+		CreatedAt: time.Now(),
+	}
+	if err := bm.bindings.Save(ctx, binding); err != nil {
+		return nil, fmt.Errorf("не удалось сохранить привязку: %w", err)
+	}
+	return &binding, nil
 }
 
 // StartPolling запускает постоянный опрос Telegram Bot API методом getUpdates.
 // При получении команды /start с UUID пытается выполнить привязку и вызывает callback.
 //
+// Опрос продолжается с offset из OffsetStore (SetOffsetStore), а новый offset сохраняется после
+// обработки каждого обновления. Если процесс упадёт между callback и сохранением, обновление придёт
+// снова, но инвайт уже извлечён из InviteStore, и callback повторно не вызывается.
+//
+// Если тем же токеном опрашивает другой экземпляр, Telegram отвечает 409: опрос продолжается с нарастающей
+// паузой, а ответ передаётся обработчику из SetConflictHandler. SetPollingLock оставляет опрос одному экземпляру.
+//
+// Кроме привязки, каждое обновление целиком (Update.Raw) передаётся обработчикам из AddUpdateHandler,
+// а AddUpdateMiddleware оборачивает всю обработку.
+//
 // ctx — контекст, по завершении которого polling будет остановлен.
 // bm — менеджер инвайтов для проверки кодов /start.
 // callback — вызывается при успешной привязке.
@@ -121,48 +231,67 @@ func (c *TgClient) StartPolling(ctx context.Context, bm *BindingManager, callbac
 		return
 	}
 
-	var offset int64
+	if c.pollLock != nil {
+		c.leadPolling(ctx, bm, callback)
+	} else {
+		_ = c.poll(ctx, bm, callback)
+	}
+	c.logger.Info("Polling stopped")
+}
 
-	for {
-		select {
-		case <-ctx.Done():
-			c.logger.Info("Polling stopped")
-			return
-		default:
-		}
+// handleUpdate обрабатывает одно обновление: выполняет привязку по команде /start с кодом инвайта
+// и отвечает в чат автоответом из BindingManager.SetReplies, при блокировке бота удаляет привязку чата,
+// а нажатия inline-кнопок передаёт обработчикам HandleCallback.
+func (c *TgClient) handleUpdate(ctx context.Context, upd Update, bm *BindingManager, callback func(Binding)) {
+	if upd.MyChatMember != nil {
+		c.handleMemberUpdate(ctx, upd.MyChatMember, bm)
+		return
+	}
+	if upd.CallbackQuery != nil {
+		c.handleCallback(ctx, upd.CallbackQuery)
+		return
+	}
+	if upd.Message == nil {
+		return
+	}
+	text := upd.Message.Text
+	chatID := upd.Message.Chat.ID
+	data := ReplyData{ChatID: chatID}
+	if from := upd.Message.From; from != nil {
+		data.Username, data.FirstName = from.Username, from.FirstName
+	}
 
-		url := fmt.Sprintf("%s/getUpdates?timeout=30&offset=%d", c.uri, offset)
-		resp, err := c.http.Get(url)
-		if err != nil {
-			c.logger.Error("Ошибка при запросе getUpdates", "error", err)
-			time.Sleep(2 * time.Second)
-			continue
-		}
+	if text == "/start" {
+		c.reply(ctx, bm.replies.start, data)
+		return
+	}
+	if !strings.HasPrefix(text, "/start ") {
+		return
+	}
 
-		var updates UpdatesResponse
-		if err := json.NewDecoder(resp.Body).Decode(&updates); err != nil {
-			c.logger.Error("Ошибка декодирования ответа", "error", err)
-			_ = resp.Body.Close()
-			time.Sleep(2 * time.Second)
-			continue
-		}
-		_ = resp.Body.Close()
-
-		for _, upd := range updates.Result {
-			offset = upd.UpdateID + 1
-
-			text := upd.Message.Text
-			chatID := upd.Message.Chat.ID
-
-			if strings.HasPrefix(text, "/start ") {
-				inviteCode := strings.TrimPrefix(text, "/start ")
-				binding, err := bm.ResolveBinding(inviteCode, chatID)
-				if err != nil {
-					c.logger.Warn("uuid не найден", "uuid", inviteCode, "chatID", chatID)
-					continue
-				}
-				callback(*binding)
-			}
-		}
+	inviteCode := strings.TrimPrefix(text, "/start ")
+	code, err := bm.verify(inviteCode)
+	if err == nil {
+		bm.tracker.clicked(code)
 	}
+	binding, err := bm.ResolveBinding(inviteCode, chatID)
+	if errors.Is(err, ErrInviteSignature) {
+		c.logger.Warn("подпись инвайта неверна", "chatID", chatID)
+		c.reply(ctx, bm.replies.expired, data)
+		return
+	}
+	if errors.Is(err, ErrInviteNotFound) {
+		c.logger.Warn("uuid не найден", "uuid", inviteCode, "chatID", chatID)
+		c.reply(ctx, bm.replies.expired, data)
+		return
+	}
+	if err != nil {
+		c.logger.Error("не удалось выполнить привязку", "uuid", inviteCode, "chatID", chatID, "error", err)
+		return
+	}
+	callback(*binding)
+	bm.tracker.completed(code)
+
+	data.UserID = binding.UserID
+	c.reply(ctx, bm.replies.bound, data)
 }
@@ -0,0 +1,139 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+)
+
+// ErrBindingNotFound возвращается, если привязки нет или она отозвана.
+var ErrBindingNotFound = errors.New("привязка Telegram не найдена")
+
+// BindingStore хранит подтверждённые привязки чатов к пользователям.
+//
+// Чат привязан не больше чем к одному пользователю, а у пользователя может быть несколько чатов
+// (личный чат, рабочая группа). Реализации должны быть безопасны для конкурентного использования.
+type BindingStore interface {
+	// Save сохраняет привязку, заменяя прежнюю привязку того же чата.
+	Save(ctx context.Context, b Binding) error
+	// ByUser возвращает привязки пользователя в порядке создания; пустой список, если их нет.
+	ByUser(ctx context.Context, userID string) ([]Binding, error)
+	// ByChat возвращает привязку чата или ErrBindingNotFound.
+	ByChat(ctx context.Context, chatID int64) (Binding, error)
+	// List возвращает все привязки в порядке создания.
+	List(ctx context.Context) ([]Binding, error)
+	// Delete удаляет привязку чата chatID к пользователю userID. Отсутствующая привязка не считается ошибкой.
+	Delete(ctx context.Context, userID string, chatID int64) error
+}
+
+// MemoryBindingStore — BindingStore в памяти процесса.
+type MemoryBindingStore struct {
+	mu       sync.RWMutex
+	bindings map[int64]Binding // chatID → привязка
+}
+
+// NewMemoryBindingStore создаёт пустое хранилище привязок в памяти.
+func NewMemoryBindingStore() *MemoryBindingStore {
+	return &MemoryBindingStore{bindings: make(map[int64]Binding)}
+}
+
+// Save сохраняет привязку.
+func (s *MemoryBindingStore) Save(_ context.Context, b Binding) error {
+	s.mu.Lock()
+	s.bindings[b.ChatID] = b
+	s.mu.Unlock()
+	return nil
+}
+
+// ByUser возвращает привязки пользователя.
+func (s *MemoryBindingStore) ByUser(_ context.Context, userID string) ([]Binding, error) {
+	return s.filter(func(b Binding) bool { return b.UserID == userID }), nil
+}
+
+// ByChat возвращает привязку чата.
+func (s *MemoryBindingStore) ByChat(_ context.Context, chatID int64) (Binding, error) {
+	s.mu.RLock()
+	b, ok := s.bindings[chatID]
+	s.mu.RUnlock()
+	if !ok {
+		return Binding{}, ErrBindingNotFound
+	}
+	return b, nil
+}
+
+// List возвращает все привязки.
+func (s *MemoryBindingStore) List(_ context.Context) ([]Binding, error) {
+	return s.filter(func(Binding) bool { return true }), nil
+}
+
+// Delete удаляет привязку.
+func (s *MemoryBindingStore) Delete(_ context.Context, userID string, chatID int64) error {
+	s.mu.Lock()
+	if b, ok := s.bindings[chatID]; ok && b.UserID == userID {
+		delete(s.bindings, chatID)
+	}
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *MemoryBindingStore) filter(match func(Binding) bool) []Binding {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	out := make([]Binding, 0)
+	for _, b := range s.bindings {
+		if match(b) {
+			out = append(out, b)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if !out[i].CreatedAt.Equal(out[j].CreatedAt) {
+			return out[i].CreatedAt.Before(out[j].CreatedAt)
+		}
+		return out[i].ChatID < out[j].ChatID
+	})
+	return out
+}
+
+// SetBindingStore заменяет хранилище подтверждённых привязок (по умолчанию — в памяти процесса).
+func (bm *BindingManager) SetBindingStore(store BindingStore) {
+	bm.bindings = store
+}
+
+// Bindings возвращает чаты, привязанные к пользователю userID.
+func (bm *BindingManager) Bindings(ctx context.Context, userID string) ([]Binding, error) {
+	return bm.bindings.ByUser(ctx, userID)
+}
+
+// BindingByChat возвращает привязку чата chatID или ErrBindingNotFound.
+func (bm *BindingManager) BindingByChat(ctx context.Context, chatID int64) (*Binding, error) {
+	b, err := bm.bindings.ByChat(ctx, chatID)
+	if err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+// AllBindings возвращает все подтверждённые привязки.
+func (bm *BindingManager) AllBindings(ctx context.Context) ([]Binding, error) {
+	return bm.bindings.List(ctx)
+}
+
+// Revoke отзывает привязку чата chatID к пользователю userID, например когда пользователь
+// отключил Telegram в приложении. chatID == 0 отзывает все чаты пользователя.
+func (bm *BindingManager) Revoke(ctx context.Context, userID string, chatID int64) error {
+	if chatID != 0 {
+		return bm.bindings.Delete(ctx, userID, chatID)
+	}
+	bindings, err := bm.bindings.ByUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+	for _, b := range bindings {
+		if err := bm.bindings.Delete(ctx, userID, b.ChatID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,413 @@
+package telegram
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/epheer/notephee/notify"
+)
+
+// updatesHandler отдаёт заданные тексты сообщений одним пакетом обновлений, затем пустые ответы.
+func updatesHandler(texts ...string) http.HandlerFunc {
+	var served atomic.Bool
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasSuffix(r.URL.Path, "/getUpdates") {
+			okHandler(w, r)
+			return
+		}
+		if served.Swap(true) {
+			time.Sleep(10 * time.Millisecond)
+			_, _ = w.Write([]byte(`{"ok":true,"result":[]}`))
+			return
+		}
+
+		var items []string
+		for i, text := range texts {
+			items = append(items, fmt.Sprintf(`{"update_id":%d,"message":{"text":%q,"chat":{"id":%d}}}`, i+1, text, 100+i))
+		}
+		_, _ = fmt.Fprintf(w, `{"ok":true,"result":[%s]}`, strings.Join(items, ","))
+	}
+}
+
+func TestInviteStats(t *testing.T) {
+	c := newTestClient(t, okHandler)
+	bm := c.NewBindingManager(time.Minute, c.logger)
+
+	code := func(link string) string { return link[strings.Index(link, "=")+1:] }
+	a := code(bm.CreateBatchInvite("onboarding", "u1"))
+	b := code(bm.CreateBatchInvite("onboarding", "u2"))
+	bm.CreateBatchInvite("onboarding", "u3")
+
+	// Второй /start с тем же кодом не считается повторным переходом
+	c = newTestClient(t, updatesHandler("/start "+a, "/start "+a, "/start "+b))
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	bound := 0
+	go func() {
+		c.StartPolling(ctx, bm, func(Binding) {
+			bound++
+			if bound == 2 {
+				cancel()
+			}
+		})
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("polling не завершился")
+	}
+
+	stats := bm.Stats("onboarding")
+	if stats.Created != 3 || stats.Clicked != 2 || stats.Completed != 2 {
+		t.Fatalf("неверная статистика: %+v", stats)
+	}
+	if conv := stats.Conversion(); conv < 0.66 || conv > 0.67 {
+		t.Fatalf("неверная конверсия: %f", conv)
+	}
+}
+
+// countingStore считает обращения к Put, чтобы проверить запись пачкой.
+type countingStore struct {
+	*MemoryInviteStore
+	puts int
+}
+
+func (s *countingStore) Put(ctx context.Context, invites []Invite) error {
+	s.puts++
+	return s.MemoryInviteStore.Put(ctx, invites)
+}
+
+func TestCreateInvites(t *testing.T) {
+	c := newTestClient(t, okHandler)
+	bm := c.NewBindingManager(time.Minute, c.logger)
+	store := &countingStore{MemoryInviteStore: NewMemoryInviteStore()}
+	bm.SetInviteStore(store)
+
+	userIDs := []string{"u1", "u2", "u3"}
+	links, err := bm.CreateInvites(userIDs)
+	if err != nil {
+		t.Fatalf("Ошибка CreateInvites: %v", err)
+	}
+	if len(links) != 3 || store.puts != 1 {
+		t.Fatalf("ожидалось 3 ссылки за одно обращение к хранилищу, получено %d ссылок и %d обращений", len(links), store.puts)
+	}
+
+	code := links["u2"][strings.Index(links["u2"], "=")+1:]
+	binding, err := bm.ResolveBinding(code, 42)
+	if err != nil || binding.UserID != "u2" {
+		t.Fatalf("инвайт из пачки не разрешился: %v %+v", err, binding)
+	}
+	if _, err := bm.ResolveBinding(code, 42); err == nil {
+		t.Fatal("инвайт не должен использоваться повторно")
+	}
+}
+
+func TestPollingOffsetSurvivesRestart(t *testing.T) {
+	var offsets []string
+	var mu sync.Mutex
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		var params getUpdatesRequest
+		_ = json.NewDecoder(r.Body).Decode(&params)
+		offset := strconv.FormatInt(params.Offset, 10)
+		mu.Lock()
+		offsets = append(offsets, offset)
+		mu.Unlock()
+		if offset == "0" {
+			_, _ = w.Write([]byte(`{"ok":true,"result":[{"update_id":7,"message":{"text":"привет","chat":{"id":1}}}]}`))
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+		_, _ = w.Write([]byte(`{"ok":true,"result":[]}`))
+	}
+
+	store, err := NewFileOffsetStore(filepath.Join(t.TempDir(), "bot", "offset"))
+	if err != nil {
+		t.Fatalf("Ошибка NewFileOffsetStore: %v", err)
+	}
+	poll := func() {
+		c := newTestClient(t, handler)
+		c.SetOffsetStore(store)
+		bm := c.NewBindingManager(time.Minute, c.logger)
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		c.StartPolling(ctx, bm, func(Binding) {})
+	}
+
+	poll()
+	if got, _ := store.Load(context.Background()); got != 8 {
+		t.Fatalf("ожидался сохранённый offset 8, получен %d", got)
+	}
+
+	mu.Lock()
+	offsets = nil
+	mu.Unlock()
+	poll()
+	mu.Lock()
+	defer mu.Unlock()
+	if len(offsets) == 0 || offsets[0] != "8" {
+		t.Fatalf("после перезапуска опрос должен продолжиться с offset 8: %v", offsets)
+	}
+}
+
+func TestBindingReplies(t *testing.T) {
+	c := newTestClient(t, okHandler)
+	bm := c.NewBindingManager(time.Minute, c.logger)
+	link := bm.CreateInvite("u1")
+	code := link[strings.Index(link, "=")+1:]
+
+	if err := bm.SetReplies(BindingReplies{Bound: "{{.Oops"}); err == nil {
+		t.Fatal("некорректный шаблон должен отклоняться")
+	}
+	if err := bm.SetReplies(BindingReplies{
+		Bound:   "Готово, {{.UserID}}",
+		Expired: "Ссылка устарела",
+		Start:   "Откройте ссылку из приложения",
+	}); err != nil {
+		t.Fatalf("Ошибка SetReplies: %v", err)
+	}
+
+	var mu sync.Mutex
+	replies := map[int64]string{}
+	updates := updatesHandler("/start "+code, "/start "+code, "/start", "привет")
+	c = newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/sendMessage") {
+			var req struct {
+				ChatID int64  `json:"chat_id"`
+				Text   string `json:"text"`
+			}
+			_ = json.NewDecoder(r.Body).Decode(&req)
+			mu.Lock()
+			replies[req.ChatID] = req.Text
+			mu.Unlock()
+			_, _ = w.Write([]byte(`{"ok":true,"result":{}}`))
+			return
+		}
+		updates(w, r)
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	c.StartPolling(ctx, bm, func(Binding) {})
+
+	mu.Lock()
+	defer mu.Unlock()
+	want := map[int64]string{100: "Готово, u1", 101: "Ссылка устарела", 102: "Откройте ссылку из приложения"}
+	if len(replies) != len(want) {
+		t.Fatalf("неожиданные автоответы: %v", replies)
+	}
+	for chat, text := range want {
+		if replies[chat] != text {
+			t.Fatalf("чат %d: ожидался ответ %q, получен %q", chat, text, replies[chat])
+		}
+	}
+}
+
+func TestInviteOptions(t *testing.T) {
+	c := newTestClient(t, okHandler)
+	bm := c.NewBindingManager(time.Minute, c.logger)
+	code := func(link string) string { return link[strings.Index(link, "=")+1:] }
+
+	team := code(bm.CreateInvite("team-1", InviteOptions{
+		Metadata: map[string]string{"role": "oncall"},
+		MultiUse: true,
+		MaxUses:  2,
+	}))
+	for chatID := int64(1); chatID <= 2; chatID++ {
+		b, err := bm.ResolveBinding(team, chatID)
+		if err != nil {
+			t.Fatalf("многоразовый инвайт должен работать для чата %d: %v", chatID, err)
+		}
+		if b.UserID != "team-1" || b.Metadata["role"] != "oncall" {
+			t.Fatalf("неожиданная привязка: %+v", b)
+		}
+	}
+	if _, err := bm.ResolveBinding(team, 3); !errors.Is(err, ErrInviteNotFound) {
+		t.Fatalf("инвайт должен исчерпаться после MaxUses, получено %v", err)
+	}
+
+	once := code(bm.CreateInvite("u1"))
+	if _, err := bm.ResolveBinding(once, 1); err != nil {
+		t.Fatalf("Ошибка ResolveBinding: %v", err)
+	}
+	if _, err := bm.ResolveBinding(once, 2); !errors.Is(err, ErrInviteNotFound) {
+		t.Fatalf("одноразовый инвайт не должен использоваться повторно, получено %v", err)
+	}
+
+	short := code(bm.CreateInvite("u2", InviteOptions{TTL: 20 * time.Millisecond}))
+	time.Sleep(50 * time.Millisecond)
+	if _, err := bm.ResolveBinding(short, 1); !errors.Is(err, ErrInviteNotFound) {
+		t.Fatalf("инвайт со своим TTL должен истечь, получено %v", err)
+	}
+}
+
+func TestBindingStore(t *testing.T) {
+	c := newTestClient(t, okHandler)
+	bm := c.NewBindingManager(time.Minute, c.logger)
+	ctx := context.Background()
+	code := func(link string) string { return link[strings.Index(link, "=")+1:] }
+
+	for _, chatID := range []int64{10, 20} {
+		if _, err := bm.ResolveBinding(code(bm.CreateInvite("u1")), chatID); err != nil {
+			t.Fatalf("Ошибка ResolveBinding: %v", err)
+		}
+	}
+	if _, err := bm.ResolveBinding(code(bm.CreateInvite("u2")), 30); err != nil {
+		t.Fatalf("Ошибка ResolveBinding: %v", err)
+	}
+
+	got, _ := bm.Bindings(ctx, "u1")
+	if len(got) != 2 || got[0].ChatID != 10 || got[1].ChatID != 20 || got[0].CreatedAt.IsZero() {
+		t.Fatalf("неожиданные привязки u1: %+v", got)
+	}
+	if b, err := bm.BindingByChat(ctx, 30); err != nil || b.UserID != "u2" {
+		t.Fatalf("привязка чата 30 не найдена: %+v %v", b, err)
+	}
+	if all, _ := bm.AllBindings(ctx); len(all) != 3 {
+		t.Fatalf("ожидалось 3 привязки, получено %d", len(all))
+	}
+
+	if err := bm.Revoke(ctx, "u2", 10); err != nil {
+		t.Fatalf("Ошибка Revoke: %v", err)
+	}
+	if _, err := bm.BindingByChat(ctx, 10); err != nil {
+		t.Fatal("чужую привязку нельзя отозвать")
+	}
+	if err := bm.Revoke(ctx, "u1", 0); err != nil {
+		t.Fatalf("Ошибка Revoke: %v", err)
+	}
+	if got, _ := bm.Bindings(ctx, "u1"); len(got) != 0 {
+		t.Fatalf("привязки u1 должны быть отозваны: %+v", got)
+	}
+	if _, err := bm.BindingByChat(ctx, 20); !errors.Is(err, ErrBindingNotFound) {
+		t.Fatalf("ожидалась ErrBindingNotFound, получено %v", err)
+	}
+}
+
+func TestSignedInvites(t *testing.T) {
+	c := newTestClient(t, okHandler)
+	bm := c.NewBindingManager(time.Minute, c.logger)
+	payload := func(link string) string { return link[strings.Index(link, "=")+1:] }
+
+	if err := bm.SetSigningKey([]byte("короткий")); err == nil {
+		t.Fatal("короткий ключ должен отклоняться")
+	}
+	oldKey := []byte(strings.Repeat("o", 32))
+	if err := bm.SetSigningKey(oldKey); err != nil {
+		t.Fatalf("Ошибка SetSigningKey: %v", err)
+	}
+	old := payload(bm.CreateInvite("u0"))
+
+	if err := bm.SetSigningKey([]byte(strings.Repeat("k", 32)), oldKey); err != nil {
+		t.Fatalf("Ошибка SetSigningKey: %v", err)
+	}
+	signed := payload(bm.CreateInvite("u1"))
+	if len(signed) > 64 {
+		t.Fatalf("параметр start длиннее 64 символов: %d", len(signed))
+	}
+	code, _, _ := strings.Cut(signed, "_")
+	tampered := signed[:len(signed)-1] + "x"
+	if strings.HasSuffix(signed, "x") {
+		tampered = signed[:len(signed)-1] + "y"
+	}
+
+	for _, forged := range []string{code, code + "_AAAAAAAAAAAAAAAAAAAAAA", tampered} {
+		if _, err := bm.ResolveBinding(forged, 1); !errors.Is(err, ErrInviteSignature) {
+			t.Fatalf("%q: ожидалась ErrInviteSignature, получено %v", forged, err)
+		}
+	}
+	if b, err := bm.ResolveBinding(signed, 1); err != nil || b.UserID != "u1" {
+		t.Fatalf("подписанный инвайт должен приниматься: %+v %v", b, err)
+	}
+	if b, err := bm.ResolveBinding(old, 2); err != nil || b.UserID != "u0" {
+		t.Fatalf("инвайт, подписанный прежним ключом, должен приниматься: %+v %v", b, err)
+	}
+}
+
+func TestBots(t *testing.T) {
+	var mu sync.Mutex
+	handlers := make(map[string]http.HandlerFunc)
+	var sent []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, method, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/bot"), "/")
+		mu.Lock()
+		h := handlers[token]
+		if "/"+method == SendMessage {
+			sent = append(sent, token)
+		}
+		mu.Unlock()
+		h(w, r)
+	}))
+	t.Cleanup(srv.Close)
+
+	bots := NewBots()
+	for name, token := range map[string]string{"": "1:main", "alerts": "2:alerts"} {
+		client, err := NewTgClient(token, WithOptions(Options{BotName: cmp.Or(name, "main") + "_bot", APIURL: srv.URL}))
+		if err != nil {
+			t.Fatalf("Ошибка NewTgClient: %v", err)
+		}
+		if err := bots.Add(name, client, client.NewBindingManager(time.Minute, slog.Default())); err != nil {
+			t.Fatalf("Ошибка Add: %v", err)
+		}
+	}
+	if names := bots.Names(); len(names) != 2 || names[0] != "" || names[1] != "alerts" {
+		t.Fatalf("ожидались основной бот и alerts, получено %q", names)
+	}
+
+	alerts, err := bots.Bot("alerts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	link := alerts.Bindings.CreateInvite("u1")
+	if !strings.HasPrefix(link, "https://t.me/alerts_bot?start=") {
+		t.Fatalf("инвайт должен вести на своего бота: %s", link)
+	}
+	_, code, _ := strings.Cut(link, "?start=")
+	mu.Lock()
+	handlers["1:main"] = updatesHandler()
+	handlers["2:alerts"] = updatesHandler("/start " + code)
+	mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	got := make(chan Binding, 1)
+	go bots.StartPolling(ctx, func(b Binding) {
+		got <- b
+		cancel()
+	})
+	select {
+	case b := <-got:
+		if b.Bot != "alerts" || b.UserID != "u1" {
+			t.Fatalf("привязка должна прийти от бота alerts: %+v", b)
+		}
+	case <-ctx.Done():
+		t.Fatal("привязка не выполнена")
+	}
+
+	registry := notify.NewRegistry()
+	bots.Register(registry)
+	if err := registry.Send(context.Background(), Channel, notify.Message{To: "100", Text: "привет", Identity: "alerts"}); err != nil {
+		t.Fatalf("Ошибка отправки: %v", err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(sent) == 0 || sent[len(sent)-1] != "2:alerts" {
+		t.Fatalf("сообщение с Identity=alerts должно уйти от бота alerts: %v", sent)
+	}
+	if _, err := bots.Bot("marketing"); !errors.Is(err, ErrUnknownBot) {
+		t.Fatalf("ожидалась ErrUnknownBot, получено %v", err)
+	}
+}
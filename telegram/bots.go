@@ -0,0 +1,122 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/epheer/notephee/notify"
+)
+
+// ErrUnknownBot возвращается, если бот с таким именем не добавлен в Bots.
+var ErrUnknownBot = errors.New("бот не зарегистрирован")
+
+// Bot — один из ботов процесса: свой клиент с токеном и лимитами и свой менеджер инвайтов.
+type Bot struct {
+	Name     string          // Имя бота в API — notify.Message.Identity; пусто для основного бота
+	Client   *TgClient       // Клиент бота
+	Bindings *BindingManager // Инвайты и привязки бота
+}
+
+// Bots — несколько ботов в одном процессе, например бот оповещений и маркетинговый бот.
+//
+// У каждого бота свой токен, лимит отправок, менеджер инвайтов и цикл опроса. Привязки тоже хранятся
+// у каждого бота отдельно: пользователь получает сообщения только от бота, которого сам запустил,
+// а ID личного чата у всех ботов совпадает с ID пользователя.
+type Bots struct {
+	mu   sync.RWMutex
+	bots map[string]*Bot
+}
+
+// NewBots создаёт пустой набор ботов.
+func NewBots() *Bots {
+	return &Bots{bots: make(map[string]*Bot)}
+}
+
+// Add добавляет бота name с клиентом client и менеджером инвайтов bm. Привязки через bm получают
+// Binding.Bot = name. Имя должно быть уникальным в наборе.
+func (b *Bots) Add(name string, client *TgClient, bm *BindingManager) error {
+	if client == nil || !client.Enabled {
+		return fmt.Errorf("бот %q: Telegram отключён", name)
+	}
+	if bm == nil {
+		return fmt.Errorf("бот %q: BindingManager == nil", name)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.bots[name]; ok {
+		return fmt.Errorf("бот %q добавлен дважды", name)
+	}
+	bm.botKey = name
+	b.bots[name] = &Bot{Name: name, Client: client, Bindings: bm}
+	return nil
+}
+
+// Bot возвращает бота по имени; пустое имя — основной бот.
+func (b *Bots) Bot(name string) (*Bot, error) {
+	b.mu.RLock()
+	bot, ok := b.bots[name]
+	b.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%q: %w", name, ErrUnknownBot)
+	}
+	return bot, nil
+}
+
+// Names возвращает имена ботов в алфавитном порядке.
+func (b *Bots) Names() []string {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	names := make([]string, 0, len(b.bots))
+	for name := range b.bots {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Register добавляет клиентов ботов в реестр каналов: основной бот — через Register,
+// остальные — как личности отправителя через RegisterIdentity, чтобы сообщение с
+// notify.Message.Identity = имя бота уходило от этого бота.
+func (b *Bots) Register(r *notify.Registry) {
+	for _, name := range b.Names() {
+		bot, _ := b.Bot(name)
+		if name == "" {
+			r.Register(bot.Client)
+		} else {
+			r.RegisterIdentity(name, bot.Client)
+		}
+	}
+}
+
+// StartPolling запускает опрос getUpdates всех ботов, каждого в своей горутине и со своим
+// менеджером инвайтов, и ждёт их остановки по завершении ctx. callback получает привязки
+// всех ботов; бот, через которого она выполнена, — в Binding.Bot.
+func (b *Bots) StartPolling(ctx context.Context, callback func(Binding)) {
+	var wg sync.WaitGroup
+	for _, name := range b.Names() {
+		bot, _ := b.Bot(name)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			bot.Client.StartPolling(ctx, bot.Bindings, callback)
+		}()
+	}
+	wg.Wait()
+}
+
+// Close закрывает клиентов всех ботов, дожидаясь начатых отправок до истечения ctx.
+func (b *Bots) Close(ctx context.Context) error {
+	var errs []error
+	for _, name := range b.Names() {
+		bot, _ := b.Bot(name)
+		if err := bot.Client.Close(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("бот %q: %w", name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,84 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// AnswerCallbackQuery — метод Telegram API для ответа на нажатие inline-кнопки.
+const AnswerCallbackQuery = "/answerCallbackQuery"
+
+// CallbackAnswer — ответ на нажатие inline-кнопки. Нулевое значение только убирает индикатор загрузки.
+type CallbackAnswer struct {
+	Text      string `json:"text,omitempty"`       // Всплывающее уведомление, до 200 символов (необязательно)
+	ShowAlert bool   `json:"show_alert,omitempty"` // Показать Text окном с кнопкой вместо уведомления вверху экрана
+	URL       string `json:"url,omitempty"`        // Ссылка, которую откроет клиент, например t.me/бот?start=... (необязательно)
+	CacheTime int    `json:"cache_time,omitempty"` // Сколько секунд клиент может кэшировать ответ
+}
+
+// CallbackHandler обрабатывает нажатие inline-кнопки и возвращает ответ, который отправляется
+// через answerCallbackQuery. Ответ отправляется и при ошибке, чтобы индикатор загрузки не завис.
+type CallbackHandler func(ctx context.Context, q *CallbackQuery) (CallbackAnswer, error)
+
+// callbackRoute — обработчик нажатий кнопок с callback_data, начинающимися с prefix.
+type callbackRoute struct {
+	prefix  string
+	handler CallbackHandler
+}
+
+// HandleCallback регистрирует обработчик нажатий кнопок, callback_data которых начинается с prefix.
+// Если подходят несколько префиксов, вызывается обработчик самого длинного. Вызывается до StartPolling.
+//
+// Когда зарегистрирован хотя бы один обработчик, опрос отвечает на каждое нажатие сам: ответом
+// обработчика или пустым ответом, если подходящего обработчика нет. Обработчики AddUpdateHandler
+// по-прежнему получают нажатия, но отвечать на них им уже не нужно.
+func (c *TgClient) HandleCallback(prefix string, h CallbackHandler) {
+	c.callbacks = append(c.callbacks, callbackRoute{prefix: prefix, handler: h})
+}
+
+// AnswerCallbackQuery отвечает на нажатие inline-кнопки с идентификатором queryID: убирает индикатор
+// загрузки и показывает answer.Text, если он задан. Ответить нужно в течение нескольких секунд после нажатия.
+func (c *TgClient) AnswerCallbackQuery(ctx context.Context, queryID string, answer CallbackAnswer) error {
+	var ok bool
+	return c.callResult(ctx, AnswerCallbackQuery, struct {
+		CallbackQueryID string `json:"callback_query_id"`
+		CallbackAnswer
+	}{queryID, answer}, &ok)
+}
+
+// callbackHandler возвращает обработчик с самым длинным префиксом, подходящим к data, или nil.
+func (c *TgClient) callbackHandler(data string) CallbackHandler {
+	var found *callbackRoute
+	for i, r := range c.callbacks {
+		if strings.HasPrefix(data, r.prefix) && (found == nil || len(r.prefix) > len(found.prefix)) {
+			found = &c.callbacks[i]
+		}
+	}
+	if found == nil {
+		return nil
+	}
+	return found.handler
+}
+
+// handleCallback передаёт нажатие кнопки обработчику HandleCallback и отвечает на него.
+// Без зарегистрированных обработчиков ничего не делает: на нажатие отвечает приложение.
+func (c *TgClient) handleCallback(ctx context.Context, q *CallbackQuery) {
+	if len(c.callbacks) == 0 {
+		return
+	}
+	var answer CallbackAnswer
+	if h := c.callbackHandler(q.Data); h != nil {
+		var err error
+		answer, err = h(ctx, q)
+		if err != nil {
+			c.logger.Warn("ошибка обработчика нажатия кнопки", "data", q.Data, "error", err)
+			c.pollingError(fmt.Errorf("обработчик нажатия кнопки %q: %w", q.Data, err))
+		}
+	} else {
+		c.logger.Debug("нет обработчика нажатия кнопки", "data", q.Data)
+	}
+	if err := c.AnswerCallbackQuery(ctx, q.ID, answer); err != nil {
+		c.logger.Error("не удалось ответить на нажатие кнопки", "data", q.Data, "error", err)
+	}
+}
@@ -0,0 +1,137 @@
+package telegram
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Методы Telegram API для сведений о чате и его участниках.
+const (
+	GetChat            = "/getChat"
+	GetChatMember      = "/getChatMember"
+	GetChatMemberCount = "/getChatMemberCount"
+)
+
+// Статусы участника чата.
+const (
+	MemberCreator       = "creator"
+	MemberAdministrator = "administrator"
+	MemberMember        = "member"
+	MemberRestricted    = "restricted"
+	MemberLeft          = "left"
+	MemberKicked        = "kicked"
+)
+
+// ChatInfo — сведения о чате, которые возвращает getChat.
+type ChatInfo struct {
+	Chat
+	FirstName   string `json:"first_name,omitempty"`  // Имя собеседника в личном чате
+	LastName    string `json:"last_name,omitempty"`   // Фамилия собеседника в личном чате
+	Description string `json:"description,omitempty"` // Описание группы или канала
+	InviteLink  string `json:"invite_link,omitempty"` // Основная ссылка-приглашение
+	IsForum     bool   `json:"is_forum,omitempty"`    // В супергруппе включены темы
+}
+
+// ChatMember — участник чата и его права.
+type ChatMember struct {
+	User            User   `json:"user"`                        // Пользователь
+	Status          string `json:"status"`                      // Статус: MemberCreator, MemberAdministrator и т.д.
+	IsMember        bool   `json:"is_member,omitempty"`         // Ограниченный участник состоит в чате (для MemberRestricted)
+	CanSendMessages bool   `json:"can_send_messages,omitempty"` // Ограниченному участнику можно писать (для MemberRestricted)
+	CanPostMessages bool   `json:"can_post_messages,omitempty"` // Администратор может публиковать в канале
+}
+
+// IsAdmin сообщает, является ли участник владельцем или администратором чата.
+func (m ChatMember) IsAdmin() bool {
+	return m.Status == MemberCreator || m.Status == MemberAdministrator
+}
+
+// InChat сообщает, состоит ли участник в чате: не вышел, не исключён и, если ограничен, не удалён.
+func (m ChatMember) InChat() bool {
+	switch m.Status {
+	case MemberCreator, MemberAdministrator, MemberMember:
+		return true
+	case MemberRestricted:
+		return m.IsMember
+	default:
+		return false
+	}
+}
+
+// GetChat возвращает сведения о чате chatID. Ошибка «chat not found» означает, что бот не видит чат:
+// его исключили из группы или пользователь удалил диалог.
+func (c *TgClient) GetChat(ctx context.Context, chatID int64) (ChatInfo, error) {
+	var chat ChatInfo
+	err := c.callResult(ctx, GetChat, struct {
+		ChatID int64 `json:"chat_id"`
+	}{chatID}, &chat)
+	return chat, err
+}
+
+// GetChatMember возвращает участника userID чата chatID. Для групп и каналов бот должен быть в чате,
+// а для сведений о других участниках канала — его администратором.
+func (c *TgClient) GetChatMember(ctx context.Context, chatID, userID int64) (ChatMember, error) {
+	var member ChatMember
+	err := c.callResult(ctx, GetChatMember, struct {
+		ChatID int64 `json:"chat_id"`
+		UserID int64 `json:"user_id"`
+	}{chatID, userID}, &member)
+	return member, err
+}
+
+// GetChatMemberCount возвращает число участников чата chatID.
+func (c *TgClient) GetChatMemberCount(ctx context.Context, chatID int64) (int, error) {
+	var count int
+	err := c.callResult(ctx, GetChatMemberCount, struct {
+		ChatID int64 `json:"chat_id"`
+	}{chatID}, &count)
+	return count, err
+}
+
+// BotMember возвращает статус самого бота в чате chatID — перед рассылкой в группу по нему видно,
+// состоит ли бот в ней (ChatMember.InChat) и может ли публиковать в канале (CanPostMessages).
+func (c *TgClient) BotMember(ctx context.Context, chatID int64) (ChatMember, error) {
+	botID, err := c.botID()
+	if err != nil {
+		return ChatMember{}, err
+	}
+	return c.GetChatMember(ctx, chatID, botID)
+}
+
+// Me возвращает сведения о боте через getMe. Успешный ответ подтверждает, что токен действителен
+// и Bot API доступен.
+func (c *TgClient) Me(ctx context.Context) (User, error) {
+	var me User
+	err := c.callResult(ctx, GetMe, nil, &me)
+	return me, err
+}
+
+// botID возвращает ID бота — числовую часть токена до двоеточия.
+func (c *TgClient) botID() (int64, error) {
+	c.tokenMu.RLock()
+	id, _, _ := strings.Cut(c.token, ":")
+	c.tokenMu.RUnlock()
+	botID, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("не удалось получить ID бота из токена")
+	}
+	return botID, nil
+}
+
+// callResult вызывает метод Bot API через Call и декодирует поле result ответа в out.
+func (c *TgClient) callResult(ctx context.Context, method string, params, out any) error {
+	if !c.Enabled {
+		return fmt.Errorf("функционал Telegram отключён: некорректная конфигурация")
+	}
+	res, err := c.Call(ctx, method, params)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(res.Result, out); err != nil {
+		return fmt.Errorf("некорректный ответ %s: %w", method, err)
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package telegram
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// Пределы Telegram для одного чата: не чаще сообщения в секунду в личный чат
+// и не больше 20 сообщений в минуту в группу.
+const (
+	PrivateChatInterval = time.Second
+	GroupChatInterval   = time.Minute / 20
+)
+
+// chatLimits хранит корзины токенов по chatID, чтобы частые сообщения в один чат
+// (повторяющиеся алерты в группу дежурных) не получали 429.
+type chatLimits struct {
+	mu      sync.Mutex
+	buckets map[int64]*chatBucket
+	purge   time.Time // Время следующей очистки неиспользуемых корзин
+}
+
+type chatBucket struct {
+	limiter *rate.Limiter
+	used    time.Time // Время последнего запроса разрешения
+}
+
+// wait ждёт, пока чат chatID сможет принять ещё одно сообщение.
+func (l *chatLimits) wait(ctx context.Context, chatID int64) error {
+	return l.bucket(chatID).Wait(ctx)
+}
+
+// bucket возвращает корзину чата, создавая её при первом обращении.
+// Группы и каналы в Telegram имеют отрицательные chatID.
+func (l *chatLimits) bucket(chatID int64) *rate.Limiter {
+	now := time.Now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.buckets == nil {
+		l.buckets = make(map[int64]*chatBucket)
+	}
+	if now.After(l.purge) {
+		// Корзина, простоявшая минуту, снова полна, и её можно создать заново
+		for id, b := range l.buckets {
+			if now.Sub(b.used) > time.Minute {
+				delete(l.buckets, id)
+			}
+		}
+		l.purge = now.Add(time.Minute)
+	}
+
+	b, ok := l.buckets[chatID]
+	if !ok {
+		interval := PrivateChatInterval
+		if chatID < 0 {
+			interval = GroupChatInterval
+		}
+		b = &chatBucket{limiter: rate.NewLimiter(rate.Every(interval), 1)}
+		l.buckets[chatID] = b
+	}
+	b.used = now
+	return b.limiter
+}
@@ -0,0 +1,29 @@
+package telegram
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+func TestChatLimits(t *testing.T) {
+	var l chatLimits
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	if err := l.wait(ctx, 1); err != nil {
+		t.Fatalf("первое сообщение в чат должно проходить сразу: %v", err)
+	}
+	if err := l.wait(ctx, 1); err == nil {
+		t.Fatal("второе сообщение в тот же чат раньше чем через секунду должно ждать")
+	}
+	if err := l.wait(ctx, 2); err != nil {
+		t.Fatalf("лимит одного чата не должен задерживать другие: %v", err)
+	}
+
+	if got, want := l.bucket(-100).Limit(), rate.Every(GroupChatInterval); got != want {
+		t.Fatalf("для группы ожидалось %v сообщений в секунду, получено %v", want, got)
+	}
+}
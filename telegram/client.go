@@ -2,30 +2,64 @@ package telegram
 
 import (
 	"bytes"
+	"cmp"
 	"context"
 	"encoding/json"
 	"fmt"
 	"io"
+	"iter"
 	"log/slog"
 	"net/http"
+	"slices"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"go.opentelemetry.io/otel/attribute"
 	"golang.org/x/time/rate"
 
-	"github.com/epheer/notephee/config"
+	"github.com/epheer/notephee/attachment"
+	"github.com/epheer/notephee/delivery"
+	"github.com/epheer/notephee/events"
+	"github.com/epheer/notephee/notify"
+	"github.com/epheer/notephee/suppression"
+	"github.com/epheer/notephee/tracing"
 )
 
 // MessageOptions содержит параметры для отправки одного текстового сообщения через Telegram Bot API.
 type MessageOptions struct {
-	ChatID int64  `json:"chat_id"` // Идентификатор чата Telegram
-	Text   string `json:"text"`    // Текст сообщения
+	ChatID    int64  `json:"chat_id"`              // Идентификатор чата Telegram
+	Text      string `json:"text"`                 // Текст сообщения
+	ParseMode string `json:"parse_mode,omitempty"` // Разметка текста: ParseModeHTML или ParseModeMarkdownV2 (пусто — без разметки)
+
+	BusinessConnectionID string                `json:"business_connection_id,omitempty"` // Отправка от имени бизнес-аккаунта (необязательно)
+	LinkPreviewOptions   *LinkPreviewOptions   `json:"link_preview_options,omitempty"`   // Настройки предпросмотра ссылок (необязательно)
+	ReplyMarkup          *InlineKeyboardMarkup `json:"reply_markup,omitempty"`           // Кнопки под сообщением (необязательно)
+
+	UserID string `json:"-"` // Внутренний ID пользователя для журнала доставки (необязательно)
+	ID     string `json:"-"` // Идентификатор попытки в журнале доставки (генерируется, если пуст)
+}
+
+// LinkPreviewOptions управляет предпросмотром ссылки в сообщении (Bot API 7.0).
+type LinkPreviewOptions struct {
+	IsDisabled       bool   `json:"is_disabled,omitempty"`        // Не показывать предпросмотр
+	URL              string `json:"url,omitempty"`                // Ссылка для предпросмотра вместо первой в тексте
+	PreferSmallMedia bool   `json:"prefer_small_media,omitempty"` // Уменьшенное изображение
+	PreferLargeMedia bool   `json:"prefer_large_media,omitempty"` // Увеличенное изображение
+	ShowAboveText    bool   `json:"show_above_text,omitempty"`    // Предпросмотр над текстом
 }
 
 // SendingOptions используется для массовой отправки сообщений по нескольким chatID.
 type SendingOptions struct {
-	ChatIDs []int64 `json:"chat_ids"` // Список идентификаторов чатов
-	Text    string  `json:"text"`     // Текст сообщения
+	ChatIDs   []int64          `json:"chat_ids"` // Список идентификаторов чатов
+	ChatIDSeq iter.Seq[int64]  `json:"-"`        // Получатели потоком, например из курсора базы: читаются по мере отправки после ChatIDs (необязательно)
+	Text      string           `json:"text"`     // Текст сообщения (подпись, если задан Document)
+	Document  *attachment.File `json:"-"`        // Файл для отправки вместо текста: загружается один раз (необязательно)
+
+	BusinessConnectionID string              `json:"business_connection_id,omitempty"` // Отправка от имени бизнес-аккаунта (необязательно)
+	LinkPreviewOptions   *LinkPreviewOptions `json:"link_preview_options,omitempty"`   // Настройки предпросмотра ссылок (необязательно)
 }
 
 // TgResponse представляет ответ Telegram Bot API на любой метод.
@@ -47,39 +81,123 @@ type TgClient struct {
 	http    *http.Client // HTTP-клиент
 	logger  *slog.Logger // Логгер для отладки
 	Enabled bool         // Флаг доступности функционала
+	tokenMu sync.RWMutex // Защищает token и uri при смене токена во время работы
+
+	requestTimeout time.Duration // Предел одного запроса к Bot API
+	pollTimeout    time.Duration // Сколько getUpdates ждёт новых обновлений
+	pollLimit      int           // Обновлений за запрос getUpdates (0 — по умолчанию Telegram)
+	allowedUpdates []string      // Типы обновлений getUpdates (nil — не передаются)
+	pollBackoff    time.Duration // Предел паузы между повторами getUpdates после ошибок
+	polling        atomic.Bool   // Последний запрос getUpdates опроса успешен
+
+	deliveryLog  delivery.DeliveryLog // Журнал попыток отправки (необязательно)
+	inflight     notify.InFlight      // Начатые отправки, которых ждёт Close
+	rate         *rate.Limiter        // Общий лимит отправок всех вызовов клиента
+	limiter      notify.Limiter       // Внешний лимит вместо rate (необязательно)
+	chats        chatLimits           // Лимиты отправок в отдельные чаты
+	flood        floodControl         // Пауза и снижение лимита после 429
+	floodRetries int                  // Повторов одной отправки после 429
+	offsets      OffsetStore          // Offset опроса getUpdates для StartPolling
+	pollLock     PollingLock          // Блокировка, с которой опрашивает один экземпляр (необязательно)
+	pollLockTTL  time.Duration        // Срок блокировки опроса
+	onConflict   func(error)          // Обработчик ответов 409 на getUpdates (необязательно)
+	onPollError  func(error)          // Обработчик ошибок опроса getUpdates (необязательно)
+	suppression  suppression.Store    // Чаты, в которые не отправляются сообщения (необязательно)
+	bus          *events.Bus          // Шина событий изменения статуса бота в чатах (необязательно)
+
+	updateHandlers   []UpdateHandler    // Обработчики обновлений StartPolling
+	updateMiddleware []UpdateMiddleware // Промежуточные обработчики обновлений StartPolling
+	callbacks        []callbackRoute    // Обработчики нажатий inline-кнопок по префиксу callback_data
+
+	uploadMu sync.Mutex        // Сериализует первые загрузки файлов
+	filesMu  sync.RWMutex      // Защищает fileIDs
+	fileIDs  map[string]string // Хэш содержимого файла → file_id в Telegram
+
+	attachPolicy attachment.Policy // Предел размера файлов (см. SetAttachmentPolicy)
 }
 
 // SendResult представляет результат отправки одного сообщения.
 type SendResult struct {
 	ChatID   int64       // Идентификатор получателя
+	Index    int         // Номер получателя в рассылке с 0: ChatIDs, затем ChatIDSeq
 	Response *TgResponse // Ответ Telegram API
 	Error    error       // Ошибка, если произошла
 }
 
+// Channel — имя канала Telegram в notify.Registry и журнале доставки.
+const Channel = "telegram"
+
 // Константы Telegram API методов
 const (
 	GetMe       = "/getMe"
 	SendMessage = "/sendMessage"
 )
 
-// NewTgClient создаёт и возвращает нового клиента Telegram.
+// MaxText — предел длины текста сообщения в символах после разбора сущностей.
+const MaxText = 4096
+
+// NewTgClient создаёт клиента бота с токеном token. Остальные параметры задаются опциями:
 //
-// cfg — конфигурация приложения с токеном и именем бота.
-// logger — логгер для ведения журнала.
-func NewTgClient(cfg *config.Config, logger *slog.Logger) *TgClient {
-	uri := fmt.Sprintf("https://api.telegram.org/bot%s", cfg.TelegramToken)
-	return &TgClient{
-		token:   cfg.TelegramToken,
-		name:    cfg.TelegramBotName,
-		uri:     uri,
-		http:    &http.Client{Timeout: 10 * time.Second},
-		logger:  logger,
-		Enabled: cfg.IsTelegramEnabled(),
+//	tg, err := telegram.NewTgClient(token, telegram.WithBotName("notephee_bot"), telegram.WithLogger(logger))
+//
+// Возвращает ошибку, если опция некорректна.
+func NewTgClient(token string, opts ...Option) (*TgClient, error) {
+	c := &TgClient{
+		token:   token,
+		uri:     fmt.Sprintf("%s/bot%s", DefaultAPIURL, token),
+		http:    &http.Client{},
+		logger:  slog.Default(),
+		Enabled: token != "",
+		fileIDs: make(map[string]string),
+		rate:    rate.NewLimiter(rate.Every(time.Second/30), 1),
+
+		requestTimeout: DefaultRequestTimeout,
+		pollTimeout:    DefaultPollTimeout,
+		pollBackoff:    DefaultPollBackoff,
+		floodRetries:   DefaultFloodRetries,
+		offsets:        NewMemoryOffsetStore(),
+	}
+	for _, opt := range opts {
+		if err := opt(c); err != nil {
+			return nil, err
+		}
 	}
+	return c, nil
+}
+
+// SetDeliveryLog подключает журнал, в который записывается каждая попытка отправки.
+func (c *TgClient) SetDeliveryLog(log delivery.DeliveryLog) {
+	c.deliveryLog = log
+}
+
+// SetLimiter заменяет общий лимит отправок клиента (по умолчанию 30 сообщений в секунду, предел
+// Telegram для бота). Распределённый лимитер нужен, если один бот используют несколько экземпляров сервиса.
+func (c *TgClient) SetLimiter(l notify.Limiter) {
+	c.limiter = l
+}
+
+// SetFloodRetries задаёт, сколько раз повторять отправку после ответа 429 с retry_after
+// (по умолчанию DefaultFloodRetries). 0 отключает повторы: ошибка возвращается сразу.
+func (c *TgClient) SetFloodRetries(n int) {
+	c.floodRetries = max(n, 0)
+}
+
+// SetOffsetStore заменяет хранилище offset опроса getUpdates (по умолчанию — в памяти процесса).
+// Вызывается до StartPolling.
+func (c *TgClient) SetOffsetStore(store OffsetStore) {
+	c.offsets = store
+}
+
+// Close перестаёт принимать новые отправки и ждёт завершения начатых до истечения ctx.
+// Отправки после Close возвращают notify.ErrClosed.
+func (c *TgClient) Close(ctx context.Context) error {
+	return c.inflight.Close(ctx)
 }
 
 // tg возвращает полный URL для метода Telegram API.
 func (c *TgClient) tg(method string) string {
+	c.tokenMu.RLock()
+	defer c.tokenMu.RUnlock()
 	return fmt.Sprintf("%s%s", c.uri, method)
 }
 
@@ -122,16 +240,80 @@ func (c *TgClient) parseResponse(resp *http.Response) (*TgResponse, error) {
 // postReq отправляет POST-запрос с JSON-данными к Telegram API.
 //
 // Возвращает результат и ошибку (если есть).
-func (c *TgClient) postReq(data json.RawMessage, method string) (*TgResponse, error) {
+func (c *TgClient) postReq(ctx context.Context, data json.RawMessage, method string) (*TgResponse, error) {
+	return c.post(ctx, bytes.NewReader(data), int64(len(data)), method)
+}
+
+// post отправляет POST-запрос с JSON-телом body длиной size.
+//
+// Если body реализует io.Closer, его закроет HTTP-транспорт, когда тело больше не нужно.
+func (c *TgClient) post(ctx context.Context, body io.Reader, size int64, method string) (*TgResponse, error) {
 	if !c.Enabled {
 		return nil, fmt.Errorf("функционал Telegram отключён: некорректная конфигурация")
 	}
 
-	res, err := c.http.Post(c.tg(method), "application/json", bytes.NewBuffer(data))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.tg(method), body)
 	if err != nil {
 		return nil, err
 	}
-	return c.parseResponse(res)
+	req.ContentLength = size
+	req.Header.Set("Content-Type", "application/json")
+
+	return c.do(req)
+}
+
+// Call вызывает произвольный метод Bot API с параметрами params, которые сериализуются в JSON.
+//
+// Нужен для возможностей API, которых ещё нет в библиотеке: вызов идёт тем же HTTP-клиентом,
+// учитывается Close и пишется в лог. Как и отправки, вызов ждёт общего лимита клиента и лимита чата
+// из параметра chat_id, а после 429 повторяется через retry_after. method указывается с ведущим «/» или без него.
+// Если API ответил ошибкой, вместе с ней возвращается ответ с ErrorCode и Parameters.
+func (c *TgClient) Call(ctx context.Context, method string, params any) (*TgResponse, error) {
+	data := []byte("{}")
+	if params != nil {
+		var err error
+		if data, err = json.Marshal(params); err != nil {
+			return nil, err
+		}
+	}
+	if !strings.HasPrefix(method, "/") {
+		method = "/" + method
+	}
+
+	chatID := callChatID(data)
+	if err := c.wait(ctx, chatID); err != nil {
+		return nil, err
+	}
+	if err := c.inflight.Acquire(); err != nil {
+		return nil, err
+	}
+	defer c.inflight.Release()
+
+	ctx, span := tracing.Start(ctx, Channel, "", attribute.String("telegram.method", method))
+	started := time.Now()
+	res, err := c.withFloodRetry(ctx, chatID, func() (*TgResponse, error) {
+		return c.postReq(ctx, data, method)
+	})
+	tracing.End(span, err)
+	if err != nil {
+		c.logger.Warn("ошибка вызова Telegram Bot API", "method", method, "duration", time.Since(started), "error", err)
+		return res, err
+	}
+	c.logger.Debug("вызов Telegram Bot API", "method", method, "duration", time.Since(started))
+	return res, nil
+}
+
+// callChatID возвращает числовой chat_id из параметров вызова или 0, если его нет
+// (например, у методов без чата или с @username канала).
+func callChatID(data []byte) int64 {
+	var params struct {
+		ChatID json.Number `json:"chat_id"`
+	}
+	if json.Unmarshal(data, &params) != nil {
+		return 0
+	}
+	id, _ := params.ChatID.Int64()
+	return id
 }
 
 // CheckConnection проверяет доступность Telegram API через метод getMe.
@@ -142,11 +324,11 @@ func (c *TgClient) CheckConnection() error {
 		return fmt.Errorf("функционал Telegram отключён: некорректная конфигурация")
 	}
 
-	res, err := c.http.Get(c.tg(GetMe))
+	req, err := http.NewRequest(http.MethodGet, c.tg(GetMe), nil)
 	if err != nil {
 		return err
 	}
-	_, err = c.parseResponse(res)
+	_, err = c.do(req)
 	return err
 }
 
@@ -154,68 +336,277 @@ func (c *TgClient) CheckConnection() error {
 //
 // Возвращает TgResponse и ошибку (если произошла).
 func (c *TgClient) SendText(options MessageOptions) (TgResponse, error) {
-	if !c.Enabled {
-		return TgResponse{}, fmt.Errorf("функционал Telegram отключён: некорректная конфигурация")
-	}
+	return c.sendText(context.Background(), options)
+}
+
+// Channel возвращает имя канала для notify.Registry.
+func (c *TgClient) Channel() string {
+	return Channel
+}
 
-	data, err := json.Marshal(options)
+// Capabilities возвращает возможности канала: текст до MaxText символов, файлы через SendDocument
+// и кнопки действий уведомлений (notify.Notification).
+func (c *TgClient) Capabilities() notify.Capabilities {
+	return notify.Capabilities{Channel: Channel, Attachments: true, Buttons: true, MaxLength: MaxText}
+}
+
+// Send реализует notify.Sender: msg.To должен содержать chatID.
+// Уведомление из msg.Notification отображается RenderNotification с кнопками действий.
+func (c *TgClient) Send(ctx context.Context, msg notify.Message) error {
+	ctx = delivery.WithTemplate(ctx, msg.Template)
+	chatID, err := strconv.ParseInt(msg.To, 10, 64)
 	if err != nil {
+		return fmt.Errorf("некорректный chatID %q: %w", msg.To, err)
+	}
+
+	options := MessageOptions{Text: msg.Text}
+	if msg.Notification != nil {
+		options = RenderNotification(*msg.Notification)
+	}
+	options.ChatID = chatID
+	options.UserID = msg.UserID
+	options.ID = msg.ID
+	_, err = c.sendText(ctx, options)
+	return err
+}
+
+// sendText отправляет текстовое сообщение с учётом контекста запроса.
+func (c *TgClient) sendText(ctx context.Context, options MessageOptions) (TgResponse, error) {
+	return c.sendJSON(ctx, SendMessage, options, options)
+}
+
+// sendPayload отправляет уже сериализованное тело метода method (sendMessage, sendPoll и т.д.)
+// и пишет попытку в журнал с получателем и текстом из options.
+// body вызывается на каждую попытку: повтор после 429 отправляет новое тело.
+func (c *TgClient) sendPayload(ctx context.Context, method string, options MessageOptions, body func() (io.Reader, int64)) (TgResponse, error) {
+	if err := c.checkSuppressed(ctx, options.ChatID); err != nil {
+		return TgResponse{}, err
+	}
+	if err := c.wait(ctx, options.ChatID); err != nil {
+		return TgResponse{}, err
+	}
+	if err := c.inflight.Acquire(); err != nil {
 		return TgResponse{}, err
 	}
-	res, err := c.postReq(data, SendMessage)
+	defer c.inflight.Release()
+
+	ctx, span := tracing.Start(ctx, Channel, strconv.FormatInt(options.ChatID, 10))
+	started := time.Now()
+	res, err := c.withFloodRetry(ctx, options.ChatID, func() (*TgResponse, error) {
+		b, size := body()
+		return c.post(ctx, b, size, method)
+	})
+	tracing.End(span, err)
+	c.logDelivery(ctx, options, started, err)
 	if err != nil {
 		return TgResponse{}, err
 	}
 	return *res, nil
 }
 
+// wait ждёт разрешения на отправку в чат chatID: окончания паузы после 429, лимита этого чата
+// и общего лимита клиента. Все вызовы клиента, включая параллельные рассылки, делят один бюджет.
+// chatID 0 — вызов без чата: лимит чата не применяется.
+func (c *TgClient) wait(ctx context.Context, chatID int64) error {
+	if err := c.flood.wait(ctx, c.defaultLimiter()); err != nil {
+		return err
+	}
+	if chatID != 0 {
+		if err := c.chats.wait(ctx, chatID); err != nil {
+			c.logger.Error("лимит чата не пропустил", "chat_id", chatID, "error", err)
+			return err
+		}
+	}
+	var limiter notify.Limiter = c.rate
+	if c.limiter != nil {
+		limiter = c.limiter
+	}
+	if err := limiter.Wait(ctx); err != nil {
+		c.logger.Error("лимитер не пропустил", "chat_id", chatID, "error", err)
+		return err
+	}
+	return nil
+}
+
+// logDelivery записывает попытку отправки в журнал доставки, если он подключён.
+func (c *TgClient) logDelivery(ctx context.Context, options MessageOptions, started time.Time, sendErr error) {
+	delivery.LogAttempt(ctx, c.deliveryLog, c.logger, delivery.Record{
+		ID:          options.ID,
+		Channel:     Channel,
+		UserID:      options.UserID,
+		Recipient:   strconv.FormatInt(options.ChatID, 10),
+		MessageHash: delivery.Hash(options.Text),
+		CreatedAt:   started,
+	}, sendErr)
+}
+
 // SendMessaging отправляет одно и то же сообщение множеству получателей с соблюдением rate limit.
 //
-// Возвращает срез результатов по каждому получателю.
+// Возвращает срез результатов по каждому получателю в порядке получателей.
 func (c *TgClient) SendMessaging(options SendingOptions) []SendResult {
-	if !c.Enabled {
-		c.logger.Warn("отправка сообщений Telegram отключена: возвращаем заглушку")
-		results := make([]SendResult, 0, len(options.ChatIDs))
-		for _, chatID := range options.ChatIDs {
-			results = append(results, SendResult{
-				ChatID: chatID,
-				Error:  fmt.Errorf("функционал Telegram отключён"),
-			})
+	return c.SendMessagingWithProgress(context.Background(), options, nil)
+}
+
+// SendMessagingWithProgress работает как SendMessaging, но после каждой отправки вызывает onProgress
+// с её результатом и текущими счётчиками. onProgress вызывается последовательно из одной горутины.
+func (c *TgClient) SendMessagingWithProgress(ctx context.Context, options SendingOptions, onProgress func(SendResult, notify.Progress)) []SendResult {
+	results := make([]SendResult, 0, len(options.ChatIDs))
+	progress := notify.Progress{Total: len(options.ChatIDs)}
+	if options.ChatIDSeq != nil {
+		progress.Total = 0
+	}
+
+	for res := range c.SendMessagingStream(ctx, options) {
+		if res.Error != nil {
+			progress.Failed++
+		} else {
+			progress.Sent++
+		}
+		results = append(results, res)
+		if onProgress != nil {
+			onProgress(res, progress)
 		}
-		return results
 	}
+	// Результаты приходят по мере завершения отправок, а возвращаются в порядке получателей
+	slices.SortFunc(results, func(a, b SendResult) int { return cmp.Compare(a.Index, b.Index) })
+	return results
+}
 
-	limiter := rate.NewLimiter(rate.Every(time.Second/30), 1)
+// Summary возвращает итоги рассылки по её результатам: сколько сообщений отправлено и сколько
+// завершилось ошибкой.
+func Summary(results []SendResult) notify.Progress {
+	p := notify.Progress{Total: len(results)}
+	for _, res := range results {
+		if res.Error != nil {
+			p.Failed++
+		} else {
+			p.Sent++
+		}
+	}
+	return p
+}
 
-	var (
-		results = make([]SendResult, 0, len(options.ChatIDs))
-		mu      sync.Mutex
-		wg      sync.WaitGroup
-	)
+// maxBulkInFlight — предел одновременных отправок рассылки. Остальные получатели ждут своей очереди
+// и не читаются из ChatIDSeq раньше времени.
+const maxBulkInFlight = 64
 
-	for _, chatID := range options.ChatIDs {
-		wg.Add(1)
+// chatIDs возвращает всех получателей рассылки: ChatIDs, затем ChatIDSeq.
+func (o SendingOptions) chatIDs() iter.Seq[int64] {
+	return func(yield func(int64) bool) {
+		for _, chatID := range o.ChatIDs {
+			if !yield(chatID) {
+				return
+			}
+		}
+		if o.ChatIDSeq != nil {
+			o.ChatIDSeq(yield)
+		}
+	}
+}
 
-		go func(chatID int64) {
-			defer wg.Done()
+// SendMessagingStream запускает массовую отправку и возвращает канал, в который попадает
+// результат каждой отправки по мере её завершения. Канал закрывается после обработки всех получателей.
+//
+// Одновременно выполняется не больше maxBulkInFlight отправок. Получатели из ChatIDSeq читаются
+// по мере освобождения мест, а если результаты не забираются из канала, чтение приостанавливается.
+// Отмена ctx прерывает ожидание лимитера: оставшиеся получатели получат ошибку контекста.
+func (c *TgClient) SendMessagingStream(ctx context.Context, options SendingOptions) <-chan SendResult {
+	size := len(options.ChatIDs)
+	if options.ChatIDSeq != nil {
+		size = maxBulkInFlight
+	}
+	out := make(chan SendResult, size)
 
-			if err := limiter.Wait(context.Background()); err != nil {
-				c.logger.Error("лимитер не пропустил", "chat_id", chatID, "error", err)
-				mu.Lock()
-				results = append(results, SendResult{ChatID: chatID, Error: err})
-				mu.Unlock()
-				return
+	if !c.Enabled {
+		c.logger.Warn("отправка сообщений Telegram отключена: возвращаем заглушку")
+		go func() {
+			defer close(out)
+			i := 0
+			for chatID := range options.chatIDs() {
+				out <- SendResult{
+					ChatID: chatID,
+					Index:  i,
+					Error:  fmt.Errorf("функционал Telegram отключён"),
+				}
+				i++
 			}
+		}()
+		return out
+	}
 
-			msg := MessageOptions{ChatID: chatID, Text: options.Text}
-			resp, err := c.SendText(msg)
+	// Файл проверяется, при необходимости сжимается или делится, и хэшируется один раз на всю рассылку
+	var (
+		docFiles  []attachment.File
+		docHashes []string
+		docErr    error
+	)
+	if options.Document != nil {
+		docFiles, docErr = c.attachmentPolicy().Apply(*options.Document)
+		for _, f := range docFiles {
+			docHashes = append(docHashes, f.Hash())
+		}
+	}
 
-			mu.Lock()
-			results = append(results, SendResult{ChatID: chatID, Response: &resp, Error: err})
-			mu.Unlock()
-		}(chatID)
+	var payload *bulkPayload
+	if options.Document == nil {
+		var err error
+		if payload, err = newBulkPayload(MessageOptions{
+			Text:                 options.Text,
+			BusinessConnectionID: options.BusinessConnectionID,
+			LinkPreviewOptions:   options.LinkPreviewOptions,
+		}); err != nil {
+			c.logger.Warn("не удалось подготовить шаблон сообщения, тело собирается для каждого чата", "error", err)
+		}
 	}
 
-	wg.Wait()
-	return results
+	send := func(i int, chatID int64) SendResult {
+		var (
+			resp TgResponse
+			err  error
+		)
+		msg := MessageOptions{
+			ChatID:               chatID,
+			Text:                 options.Text,
+			BusinessConnectionID: options.BusinessConnectionID,
+			LinkPreviewOptions:   options.LinkPreviewOptions,
+		}
+		switch {
+		case docErr != nil:
+			err = docErr
+		case options.Document != nil:
+			doc := DocumentOptions{
+				ChatID:               chatID,
+				Caption:              options.Text,
+				BusinessConnectionID: options.BusinessConnectionID,
+			}
+			resp, err = c.sendDocumentParts(ctx, doc, docFiles, docHashes)
+		case payload != nil:
+			resp, err = c.sendPayload(ctx, SendMessage, msg, func() (io.Reader, int64) {
+				body := payload.build(chatID)
+				return body, body.Size()
+			})
+		default:
+			resp, err = c.sendText(ctx, msg)
+		}
+		return SendResult{ChatID: chatID, Index: i, Response: &resp, Error: err}
+	}
+
+	go func() {
+		var wg sync.WaitGroup
+		slots := make(chan struct{}, maxBulkInFlight)
+		i := 0
+		for chatID := range options.chatIDs() {
+			slots <- struct{}{}
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				out <- send(i, chatID)
+				<-slots
+			}(i)
+			i++
+		}
+		wg.Wait()
+		close(out)
+	}()
+	return out
 }
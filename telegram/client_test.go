@@ -0,0 +1,584 @@
+package telegram
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	"github.com/epheer/notephee/attachment"
+	"github.com/epheer/notephee/notify"
+)
+
+// newTestClient создаёт клиента, который обращается к тестовому серверу вместо api.telegram.org.
+func newTestClient(t *testing.T, handler http.HandlerFunc) *TgClient {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+
+	c, _ := NewTgClient("test", WithBotName("test_bot"))
+	c.uri = srv.URL
+	return c
+}
+
+// okHandler отвечает успехом на любые методы, кроме отправки в чат 13.
+func okHandler(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		ChatID int64 `json:"chat_id"`
+	}
+	_ = json.NewDecoder(r.Body).Decode(&req)
+	if req.ChatID == 13 {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"ok":false,"error_code":400,"description":"Bad Request: chat not found"}`))
+		return
+	}
+	_, _ = w.Write([]byte(`{"ok":true,"result":{}}`))
+}
+
+func TestSendMessagingWithProgress(t *testing.T) {
+	c := newTestClient(t, okHandler)
+
+	var calls []notify.Progress
+	results := c.SendMessagingWithProgress(context.Background(), SendingOptions{
+		ChatIDs: []int64{1, 13, 3},
+		Text:    "привет",
+	}, func(_ SendResult, p notify.Progress) {
+		calls = append(calls, p)
+	})
+
+	if len(results) != 3 || len(calls) != 3 {
+		t.Fatalf("ожидалось 3 результата и 3 вызова, получено %d и %d", len(results), len(calls))
+	}
+	last := calls[len(calls)-1]
+	if last.Total != 3 || last.Sent != 2 || last.Failed != 1 || last.Done() != 3 {
+		t.Fatalf("неверные итоговые счётчики: %+v", last)
+	}
+}
+
+func TestSendMessagingSeq(t *testing.T) {
+	c := newTestClient(t, okHandler)
+	c.rate = rate.NewLimiter(rate.Inf, 1)
+
+	// Получатели читаются лениво: впереди полученных результатов — не больше отправок в работе и результатов в буфере
+	var read, sent atomic.Int32
+	seq := func(yield func(int64) bool) {
+		for id := int64(100); id < 300; id++ {
+			if n := read.Add(1); int(n-sent.Load()) > 2*maxBulkInFlight+1 {
+				t.Errorf("прочитано %d получателей при %d отправленных", n, sent.Load())
+			}
+			if !yield(id) {
+				return
+			}
+		}
+	}
+	var last notify.Progress
+	results := c.SendMessagingWithProgress(context.Background(), SendingOptions{ChatIDs: []int64{1, 2}, ChatIDSeq: seq, Text: "привет"},
+		func(_ SendResult, p notify.Progress) {
+			sent.Add(1)
+			last = p
+		})
+
+	if len(results) != 202 || last.Sent != 202 || last.Total != 0 {
+		t.Fatalf("ожидалось 202 отправки с неизвестным числом получателей, получено %d: %+v", len(results), last)
+	}
+	for i, res := range results {
+		want := int64(i + 98)
+		if i < 2 {
+			want = int64(i + 1)
+		}
+		if res.ChatID != want || res.Index != i {
+			t.Fatalf("результат %d не по порядку: chat %d, index %d", i, res.ChatID, res.Index)
+		}
+	}
+	if s := Summary(results); s.Sent != 202 || s.Failed != 0 {
+		t.Fatalf("неверные итоги рассылки: %+v", s)
+	}
+}
+
+func TestDocumentUploadedOnce(t *testing.T) {
+	var uploads, byID atomic.Int32
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+			uploads.Add(1)
+		} else {
+			byID.Add(1)
+		}
+		_, _ = w.Write([]byte(`{"ok":true,"result":{"document":{"file_id":"F1"}}}`))
+	})
+
+	doc := &attachment.File{Name: "report.pdf", Data: []byte("%PDF-1.4")}
+	results := c.SendMessaging(SendingOptions{ChatIDs: []int64{1, 2, 3, 4}, Text: "отчёт", Document: doc})
+	for _, res := range results {
+		if res.Error != nil {
+			t.Fatalf("Ошибка отправки в %d: %v", res.ChatID, res.Error)
+		}
+	}
+	if uploads.Load() != 1 || byID.Load() != 3 {
+		t.Fatalf("ожидалась 1 загрузка и 3 отправки по file_id, получено %d и %d", uploads.Load(), byID.Load())
+	}
+}
+
+func TestDocumentSizePolicy(t *testing.T) {
+	var uploads atomic.Int32
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		uploads.Add(1)
+		_, _ = w.Write([]byte(`{"ok":true,"result":{"document":{"file_id":"F1"}}}`))
+	})
+	doc := attachment.File{Name: "dump.bin", Data: bytes.Repeat([]byte{1, 2, 3, 4}, 64)}
+
+	c.SetAttachmentPolicy(attachment.Policy{MaxSize: 100})
+	_, err := c.SendDocument(context.Background(), DocumentOptions{ChatID: 1, Document: doc})
+	if !errors.Is(err, attachment.ErrTooLarge) || uploads.Load() != 0 {
+		t.Fatalf("большой файл должен отклоняться без запроса к API: %v, запросов %d", err, uploads.Load())
+	}
+
+	c.SetAttachmentPolicy(attachment.Policy{MaxSize: 100, Oversize: attachment.Split})
+	if _, err := c.SendDocument(context.Background(), DocumentOptions{ChatID: 1, Document: doc, Caption: "дамп"}); err != nil {
+		t.Fatalf("Ошибка отправки частей: %v", err)
+	}
+	if uploads.Load() != 3 {
+		t.Fatalf("ожидалось 3 части, загружено %d", uploads.Load())
+	}
+}
+
+func TestSendNotification(t *testing.T) {
+	var got MessageOptions
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(&got)
+		_, _ = w.Write([]byte(`{"ok":true,"result":{}}`))
+	})
+
+	n := notify.Notification{
+		Title:    "Оплата <не прошла>",
+		Body:     "Банк отклонил платёж",
+		Severity: notify.SeverityCritical,
+		Actions:  []notify.Action{{Label: "Открыть заказ", URL: "https://example.com/orders/12"}},
+		Metadata: map[string]string{"заказ": "12", "сумма": "990 ₽"},
+	}
+	if err := c.Send(context.Background(), n.Message("7")); err != nil {
+		t.Fatalf("Ошибка Send: %v", err)
+	}
+	if got.ChatID != 7 || got.ParseMode != ParseModeHTML || !strings.HasPrefix(got.Text, "🚨 <b>Оплата &lt;не прошла&gt;</b>") ||
+		!strings.Contains(got.Text, "<b>заказ:</b> 12\n<b>сумма:</b> 990 ₽") {
+		t.Fatalf("неожиданное сообщение: %+v", got)
+	}
+	if got.ReplyMarkup == nil || got.ReplyMarkup.InlineKeyboard[0][0].URL != "https://example.com/orders/12" {
+		t.Fatalf("ожидалась кнопка действия, получено %+v", got.ReplyMarkup)
+	}
+}
+
+func TestSendMediaGroup(t *testing.T) {
+	var media []inputMedia
+	var files int
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != SendMediaGroup {
+			t.Errorf("неожиданный метод %s", r.URL.Path)
+		}
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			t.Errorf("ожидалось тело multipart/form-data: %v", err)
+			return
+		}
+		_ = json.Unmarshal([]byte(r.FormValue("media")), &media)
+		files = len(r.MultipartForm.File)
+		_, _ = w.Write([]byte(`{"ok":true,"result":[]}`))
+	})
+
+	_, err := c.SendMediaGroup(context.Background(), MediaGroupOptions{
+		ChatID:  1,
+		Caption: "отчёт за неделю",
+		Items: []MediaItem{
+			{Type: MediaPhoto, File: attachment.File{Name: "a.png", Data: []byte("png-a")}},
+			{Type: MediaPhoto, FileID: "F2"},
+			{Type: MediaPhoto, File: attachment.File{Name: "c.png", Data: []byte("png-c")}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Ошибка SendMediaGroup: %v", err)
+	}
+	if len(media) != 3 || files != 2 {
+		t.Fatalf("ожидалось 3 элемента и 2 файла, получено %d и %d", len(media), files)
+	}
+	if media[0].Media != "attach://file0" || media[0].Caption != "отчёт за неделю" {
+		t.Fatalf("неверный первый элемент: %+v", media[0])
+	}
+	if media[1].Media != "F2" || media[1].Caption != "" {
+		t.Fatalf("неверный элемент по file_id: %+v", media[1])
+	}
+
+	for name, items := range map[string][]MediaItem{
+		"один элемент": {{Type: MediaPhoto, FileID: "F1"}},
+		"смешанные":    {{Type: MediaPhoto, FileID: "F1"}, {Type: MediaDocument, FileID: "F2"}},
+		"без файла":    {{Type: MediaDocument, FileID: "F1"}, {Type: MediaDocument}},
+	} {
+		if _, err := c.SendMediaGroup(context.Background(), MediaGroupOptions{ChatID: 1, Items: items}); err == nil {
+			t.Errorf("%s: ожидалась ошибка проверки альбома", name)
+		}
+	}
+}
+
+func TestSendPoll(t *testing.T) {
+	var got map[string]any
+	var path string
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		got = nil
+		_ = json.NewDecoder(r.Body).Decode(&got)
+		_, _ = w.Write([]byte(`{"ok":true,"result":{}}`))
+	})
+
+	_, err := c.SendPoll(context.Background(), PollOptions{
+		ChatID:   1,
+		Question: "Работы в субботу 02:00–04:00, все в курсе?",
+		Options:  []string{"Да", "Нет"},
+		Public:   true,
+	})
+	if err != nil {
+		t.Fatalf("Ошибка SendPoll: %v", err)
+	}
+	if path != SendPoll || got["is_anonymous"] != false {
+		t.Fatalf("неверный запрос %s: %v", path, got)
+	}
+	if opts, _ := got["options"].([]any); len(opts) != 2 || opts[0].(map[string]any)["text"] != "Да" {
+		t.Fatalf("неверные варианты ответа: %v", got["options"])
+	}
+	if _, ok := got["correct_option_id"]; ok {
+		t.Fatal("у обычного опроса не должно быть верного ответа")
+	}
+
+	bad := []PollOptions{
+		{ChatID: 1, Question: "?", Options: []string{"Да"}},
+		{ChatID: 1, Question: "?", Options: []string{"Да", "Нет"}, Type: PollQuiz, CorrectOption: 2},
+		{ChatID: 1, Question: "?", Options: []string{"Да", "Нет"}, OpenPeriod: time.Hour},
+	}
+	for _, options := range bad {
+		if _, err := c.SendPoll(context.Background(), options); err == nil {
+			t.Errorf("ожидалась ошибка проверки опроса: %+v", options)
+		}
+	}
+
+	if _, err := c.SendLocation(context.Background(), LocationOptions{ChatID: 1, Latitude: 55.75, Longitude: 37.62}); err != nil {
+		t.Fatalf("Ошибка SendLocation: %v", err)
+	}
+	if path != SendLocation || got["latitude"] != 55.75 {
+		t.Fatalf("неверный запрос %s: %v", path, got)
+	}
+}
+
+func TestChatMembership(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ChatID int64 `json:"chat_id"`
+			UserID int64 `json:"user_id"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		switch {
+		case r.URL.Path == GetChatMemberCount:
+			_, _ = w.Write([]byte(`{"ok":true,"result":17}`))
+		case r.URL.Path == GetChatMember && req.UserID == 42:
+			_, _ = w.Write([]byte(`{"ok":true,"result":{"user":{"id":42,"is_bot":true,"first_name":"bot"},"status":"left"}}`))
+		case r.URL.Path == GetChatMember:
+			_, _ = w.Write([]byte(`{"ok":true,"result":{"user":{"id":7,"is_bot":false,"first_name":"Анна"},"status":"administrator"}}`))
+		default:
+			_, _ = w.Write([]byte(`{"ok":false,"error_code":400,"description":"Bad Request: chat not found"}`))
+		}
+	})
+	c.token = "42:secret"
+	ctx := context.Background()
+
+	count, err := c.GetChatMemberCount(ctx, -100)
+	if err != nil || count != 17 {
+		t.Fatalf("ожидалось 17 участников, получено %d (%v)", count, err)
+	}
+	admin, err := c.GetChatMember(ctx, -100, 7)
+	if err != nil || !admin.IsAdmin() || !admin.InChat() {
+		t.Fatalf("ожидался администратор в чате: %+v (%v)", admin, err)
+	}
+	bot, err := c.BotMember(ctx, -100)
+	if err != nil || bot.InChat() || bot.User.ID != 42 {
+		t.Fatalf("ожидалось, что бот вышел из чата: %+v (%v)", bot, err)
+	}
+	if _, err := c.GetChat(ctx, -100); err == nil || !strings.Contains(err.Error(), "chat not found") {
+		t.Fatalf("ожидалась ошибка chat not found, получено %v", err)
+	}
+}
+
+func TestProxy(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		t.Error("запрос ушёл в обход прокси")
+	})
+	var proxied atomic.Int32
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Прокси получает запрос с абсолютным URL исходного сервера
+		if strings.HasPrefix(r.URL.String(), c.uri) {
+			proxied.Add(1)
+		}
+		_, _ = w.Write([]byte(`{"ok":true,"result":{}}`))
+	}))
+	t.Cleanup(proxy.Close)
+
+	if err := c.SetProxy(proxy.URL); err != nil {
+		t.Fatalf("Ошибка SetProxy: %v", err)
+	}
+	if _, err := c.sendText(context.Background(), MessageOptions{ChatID: 1, Text: "привет"}); err != nil {
+		t.Fatalf("Ошибка отправки через прокси: %v", err)
+	}
+	if proxied.Load() != 1 {
+		t.Fatalf("ожидался 1 запрос через прокси, получено %d", proxied.Load())
+	}
+
+	for _, bad := range []string{"ftp://proxy:21", "socks5://", "://"} {
+		if err := c.SetProxy(bad); err == nil {
+			t.Errorf("%q: ожидалась ошибка", bad)
+		}
+	}
+}
+
+func TestTimeouts(t *testing.T) {
+	var pollTimeout string
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/getUpdates") {
+			var params getUpdatesRequest
+			_ = json.NewDecoder(r.Body).Decode(&params)
+			pollTimeout = strconv.Itoa(params.Timeout)
+			// Долгий опрос дольше предела обычного запроса не должен обрываться
+			time.Sleep(100 * time.Millisecond)
+			_, _ = w.Write([]byte(`{"ok":true,"result":[]}`))
+			return
+		}
+		time.Sleep(100 * time.Millisecond)
+		_, _ = w.Write([]byte(`{"ok":true,"result":{}}`))
+	})
+	c.SetHTTPClient(&http.Client{})
+	c.SetTimeouts(50*time.Millisecond, time.Second)
+
+	if _, err := c.getUpdates(context.Background(), 0); err != nil {
+		t.Fatalf("Ошибка getUpdates: %v", err)
+	}
+	if pollTimeout != "1" {
+		t.Fatalf("ожидался timeout=1 в getUpdates, получено %q", pollTimeout)
+	}
+	if _, err := c.sendText(context.Background(), MessageOptions{ChatID: 1, Text: "привет"}); err == nil {
+		t.Fatal("ожидалась ошибка по таймауту запроса")
+	}
+}
+
+func TestAPIURL(t *testing.T) {
+	var path string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		_, _ = w.Write([]byte(`{"ok":true,"result":{}}`))
+	}))
+	t.Cleanup(srv.Close)
+
+	c, err := NewTgClient("1:abc", WithOptions(Options{BotName: "test_bot", APIURL: srv.URL + "/"}))
+	if err != nil {
+		t.Fatalf("Ошибка NewTgClient: %v", err)
+	}
+	if err := c.CheckConnection(); err != nil {
+		t.Fatalf("Ошибка CheckConnection: %v", err)
+	}
+	if path != "/bot1:abc"+GetMe {
+		t.Fatalf("запрос ушёл не на свой сервер Bot API: %s", path)
+	}
+	c.SetToken("1:rotated")
+	if err := c.CheckConnection(); err != nil || path != "/bot1:rotated"+GetMe {
+		t.Fatalf("после SetToken запрос должен идти с новым токеном: %s, %v", path, err)
+	}
+
+	if _, err := NewTgClient("1:abc", WithOptions(Options{APIURL: "api.local"})); err == nil {
+		t.Fatal("ожидалась ошибка некорректного адреса Bot API")
+	}
+}
+
+func TestWithOptions(t *testing.T) {
+	var path string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		_, _ = w.Write([]byte(`{"ok":true,"result":{}}`))
+	}))
+	t.Cleanup(srv.Close)
+
+	c, err := NewTgClient("1:abc", WithBotName("test_bot"), WithBaseURL(srv.URL), WithRetry(0), WithRateLimit(100, 5))
+	if err != nil {
+		t.Fatalf("Ошибка NewTgClient: %v", err)
+	}
+	if !c.Enabled || c.name != "test_bot" || c.floodRetries != 0 || c.rate.Burst() != 5 {
+		t.Fatalf("опции не применены: enabled=%v name=%q retries=%d", c.Enabled, c.name, c.floodRetries)
+	}
+	if _, err := c.sendText(context.Background(), MessageOptions{ChatID: 1, Text: "привет"}); err != nil {
+		t.Fatalf("Ошибка отправки: %v", err)
+	}
+	if path != "/bot1:abc"+SendMessage {
+		t.Fatalf("запрос ушёл не по адресу из WithBaseURL: %s", path)
+	}
+
+	if _, err := NewTgClient("1:abc", WithProxy("ftp://proxy")); err == nil {
+		t.Fatal("ожидалась ошибка некорректной опции")
+	}
+
+	c, err = NewTgClient("1:abc", WithOptions(Options{BotName: "test_bot", RequestTimeout: time.Second}))
+	if err != nil || !c.Enabled || c.requestTimeout != time.Second || c.pollTimeout != DefaultPollTimeout {
+		t.Fatalf("параметры Options не применены: %v", err)
+	}
+
+	// Пустое имя в Options не сбрасывает имя из WithBotName
+	c, err = NewTgClient("1:abc", WithBotName("test_bot"), WithOptions(Options{RequestTimeout: time.Second}))
+	if err != nil || c.name != "test_bot" {
+		t.Fatalf("имя бота из WithBotName потеряно: %q, %v", c.name, err)
+	}
+}
+
+func TestBulkPayloadMatchesMarshal(t *testing.T) {
+	options := MessageOptions{
+		Text:                 "Привет, <b>\"мир\"</b> &  ",
+		BusinessConnectionID: "bc-1",
+		LinkPreviewOptions:   &LinkPreviewOptions{IsDisabled: true},
+	}
+	p, err := newBulkPayload(options)
+	if err != nil {
+		t.Fatalf("Ошибка newBulkPayload: %v", err)
+	}
+
+	for _, chatID := range []int64{0, 1, -1001234567890} {
+		options.ChatID = chatID
+		want, _ := json.Marshal(options)
+		body := p.build(chatID)
+		got, _ := io.ReadAll(body)
+		_ = body.Close()
+		if string(got) != string(want) {
+			t.Fatalf("тело для %d отличается от json.Marshal:\n%s\n%s", chatID, got, want)
+		}
+	}
+}
+
+func BenchmarkBulkPayload(b *testing.B) {
+	text := strings.Repeat("Плановые работы сегодня в 22:00. ", 10)
+
+	b.Run("marshal", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := range b.N {
+			_, _ = json.Marshal(MessageOptions{ChatID: int64(i), Text: text})
+		}
+	})
+	b.Run("template", func(b *testing.B) {
+		p, _ := newBulkPayload(MessageOptions{Text: text})
+		b.ReportAllocs()
+		for i := range b.N {
+			_ = p.build(int64(i)).Close()
+		}
+	})
+}
+
+func TestCall(t *testing.T) {
+	var path string
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		okHandler(w, r)
+	})
+	limiter := &countingLimiter{}
+	c.SetLimiter(limiter)
+
+	res, err := c.Call(context.Background(), "setMessageReaction", map[string]any{"chat_id": 1, "message_id": 42})
+	if err != nil || !res.OK {
+		t.Fatalf("Ошибка Call: %v", err)
+	}
+	if path != "/setMessageReaction" {
+		t.Fatalf("ожидался метод /setMessageReaction, получено %q", path)
+	}
+	if n := limiter.waits.Load(); n != 1 {
+		t.Fatalf("вызов должен проходить через лимитер клиента, ожиданий: %d", n)
+	}
+
+	// Ответ с ошибкой возвращается вместе с ней, чтобы был доступен код
+	res, err = c.Call(context.Background(), "/sendPoll", map[string]any{"chat_id": 13})
+	if err == nil || res == nil || res.ErrorCode != 400 {
+		t.Fatalf("ожидалась ошибка с кодом 400, получено %+v, %v", res, err)
+	}
+}
+
+// countingLimiter считает запросы разрешения на отправку.
+type countingLimiter struct {
+	waits atomic.Int32
+}
+
+func (l *countingLimiter) Wait(ctx context.Context) error {
+	l.waits.Add(1)
+	return ctx.Err()
+}
+
+func TestSharedLimiter(t *testing.T) {
+	c := newTestClient(t, okHandler)
+	limiter := &countingLimiter{}
+	c.SetLimiter(limiter)
+
+	done := make(chan struct{})
+	for range 2 {
+		go func() {
+			c.SendMessaging(SendingOptions{ChatIDs: []int64{1, 2, 3}, Text: "рассылка"})
+			done <- struct{}{}
+		}()
+	}
+	if err := c.Send(context.Background(), notify.Message{To: "4", Text: "одиночное"}); err != nil {
+		t.Fatalf("Ошибка Send: %v", err)
+	}
+	<-done
+	<-done
+
+	if n := limiter.waits.Load(); n != 7 {
+		t.Fatalf("все отправки клиента должны проходить через один лимитер: ожидалось 7 ожиданий, получено %d", n)
+	}
+}
+
+func TestFloodRetry(t *testing.T) {
+	var calls atomic.Int32
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusTooManyRequests)
+			_, _ = w.Write([]byte(`{"ok":false,"error_code":429,"description":"Too Many Requests: retry after 1","parameters":{"retry_after":1}}`))
+			return
+		}
+		okHandler(w, r)
+	})
+
+	started := time.Now()
+	if _, err := c.SendText(MessageOptions{ChatID: 1, Text: "привет"}); err != nil {
+		t.Fatalf("после 429 отправка должна повториться: %v", err)
+	}
+	if calls.Load() != 2 || time.Since(started) < time.Second {
+		t.Fatalf("ожидался повтор через retry_after: %d запросов за %v", calls.Load(), time.Since(started))
+	}
+	if c.rate.Limit() >= rate.Every(time.Second/30) {
+		t.Fatal("после 429 общий лимит клиента должен снизиться")
+	}
+
+	calls.Store(0)
+	c.SetFloodRetries(0)
+	if _, err := c.SendText(MessageOptions{ChatID: 2, Text: "привет"}); err == nil || calls.Load() != 1 {
+		t.Fatalf("без повторов ожидалась ошибка после одного запроса, получено %v за %d запросов", err, calls.Load())
+	}
+}
+
+func TestEscape(t *testing.T) {
+	if got := EscapeMarkdownV2(`Заказ #12 (1.5 кг) _срочно_ \ [ссылка]`); got != `Заказ \#12 \(1\.5 кг\) \_срочно\_ \\ \[ссылка\]` {
+		t.Fatalf("неверное экранирование MarkdownV2: %s", got)
+	}
+	if got := EscapeMarkdownV2Code("a`b\\c_d"); got != "a\\`b\\\\c_d" {
+		t.Fatalf("неверное экранирование кода MarkdownV2: %s", got)
+	}
+	if got := MarkdownV2f("*Ошибка* %s: %d%%, %q", "db-1.local", -5, "x"); got != `*Ошибка* db\-1\.local: \-5%, "x"` {
+		t.Fatalf("неверный MarkdownV2f: %s", got)
+	}
+	if got := HTMLf("<b>%s</b> %[1]s", `<script>&"`); got != "<b>&lt;script&gt;&amp;&quot;</b> &lt;script&gt;&amp;&quot;" {
+		t.Fatalf("неверный HTMLf: %s", got)
+	}
+}
@@ -0,0 +1,287 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrConversationNotFound возвращается ConversationStore.Load, если у пользователя в чате нет начатого диалога.
+var ErrConversationNotFound = errors.New("диалог не найден")
+
+// DefaultConversationTimeout — через сколько бездействия пользователя диалог по умолчанию прерывается.
+const DefaultConversationTimeout = 10 * time.Minute
+
+// ConversationKey — собеседник диалога: пользователь UserID в чате ChatID. В группе у каждого участника
+// свой диалог, и сообщения одного не продвигают диалог другого.
+type ConversationKey struct {
+	ChatID int64 // Чат диалога
+	UserID int64 // Пользователь, с которым идёт диалог
+}
+
+// PrivateConversation возвращает ключ диалога в личном чате, где идентификатор чата совпадает
+// с идентификатором пользователя.
+func PrivateConversation(chatID int64) ConversationKey {
+	return ConversationKey{ChatID: chatID, UserID: chatID}
+}
+
+// ConversationState — состояние многошагового диалога с одним пользователем в чате.
+type ConversationState struct {
+	Step      string            // Текущий шаг — имя обработчика Conversations.Step
+	Data      map[string]string // Ответы, собранные на предыдущих шагах
+	UpdatedAt time.Time         // Время последнего перехода; по нему считается Timeout
+}
+
+// ConversationStore хранит состояния диалогов по чатам и пользователям.
+//
+// Реализации должны быть безопасны для конкурентного использования.
+type ConversationStore interface {
+	// Load возвращает состояние диалога или ErrConversationNotFound.
+	Load(ctx context.Context, key ConversationKey) (ConversationState, error)
+	// Save сохраняет состояние диалога, заменяя прежнее.
+	Save(ctx context.Context, key ConversationKey, state ConversationState) error
+	// Delete завершает диалог. Отсутствующий диалог не считается ошибкой.
+	Delete(ctx context.Context, key ConversationKey) error
+}
+
+// MemoryConversationStore — ConversationStore в памяти процесса.
+type MemoryConversationStore struct {
+	mu     sync.RWMutex
+	states map[ConversationKey]ConversationState
+}
+
+// NewMemoryConversationStore создаёт пустое хранилище диалогов в памяти.
+func NewMemoryConversationStore() *MemoryConversationStore {
+	return &MemoryConversationStore{states: make(map[ConversationKey]ConversationState)}
+}
+
+// Load возвращает состояние диалога.
+func (s *MemoryConversationStore) Load(_ context.Context, key ConversationKey) (ConversationState, error) {
+	s.mu.RLock()
+	state, ok := s.states[key]
+	s.mu.RUnlock()
+	if !ok {
+		return ConversationState{}, ErrConversationNotFound
+	}
+	state.Data = maps.Clone(state.Data)
+	return state, nil
+}
+
+// Save сохраняет состояние диалога.
+func (s *MemoryConversationStore) Save(_ context.Context, key ConversationKey, state ConversationState) error {
+	state.Data = maps.Clone(state.Data)
+	s.mu.Lock()
+	s.states[key] = state
+	s.mu.Unlock()
+	return nil
+}
+
+// Delete удаляет состояние диалога.
+func (s *MemoryConversationStore) Delete(_ context.Context, key ConversationKey) error {
+	s.mu.Lock()
+	delete(s.states, key)
+	s.mu.Unlock()
+	return nil
+}
+
+// ConversationOptions — настройки диалогов. Нулевые значения заменяются значениями по умолчанию.
+type ConversationOptions struct {
+	Store          ConversationStore // Хранилище состояний; по умолчанию в памяти процесса
+	Timeout        time.Duration     // Бездействие, после которого диалог прерывается; по умолчанию DefaultConversationTimeout
+	CancelCommands []string          // Команды, прерывающие диалог; по умолчанию /cancel
+	CancelText     string            // Ответ на команду отмены (пусто — без ответа)
+	TimeoutText    string            // Ответ на первое сообщение после истечения Timeout (пусто — без ответа)
+}
+
+func (o *ConversationOptions) defaults() {
+	if o.Store == nil {
+		o.Store = NewMemoryConversationStore()
+	}
+	if o.Timeout <= 0 {
+		o.Timeout = DefaultConversationTimeout
+	}
+	if len(o.CancelCommands) == 0 {
+		o.CancelCommands = []string{"/cancel"}
+	}
+}
+
+// StepHandler обрабатывает сообщение или нажатие кнопки на шаге диалога. Переход задаётся через
+// Session.Next или Session.Finish; без них диалог остаётся на том же шаге, например после неверного ответа.
+// При ошибке состояние не меняется, а ошибка возвращается обработчиком Conversations.Handler.
+// На нажатие кнопки Handler отвечает сам — ответом из Session.Answer или пустым, — если у клиента
+// нет обработчиков HandleCallback, которые отвечают на все нажатия.
+type StepHandler func(ctx context.Context, s *Session, upd Update) error
+
+// Session — диалог с пользователем в чате, переданный обработчику шага.
+type Session struct {
+	ChatID int64             // Чат диалога
+	UserID int64             // Пользователь, с которым идёт диалог
+	Step   string            // Текущий шаг
+	Data   map[string]string // Собранные ответы; изменения сохраняются после успешного шага
+
+	client   *TgClient
+	next     string
+	finished bool
+	answer   CallbackAnswer
+}
+
+// Next переводит диалог на шаг step после завершения обработчика.
+func (s *Session) Next(step string) {
+	s.next = step
+}
+
+// Finish завершает диалог после обработчика: состояние удаляется из хранилища.
+func (s *Session) Finish() {
+	s.finished = true
+}
+
+// Answer задаёт ответ на нажатие кнопки, которое обрабатывает шаг, например всплывающее уведомление.
+func (s *Session) Answer(answer CallbackAnswer) {
+	s.answer = answer
+}
+
+// Reply отправляет в чат диалога текстовое сообщение, например вопрос следующего шага.
+func (s *Session) Reply(ctx context.Context, options MessageOptions) error {
+	options.ChatID = s.ChatID
+	_, err := s.client.sendText(ctx, options)
+	return err
+}
+
+// Conversations ведёт многошаговые диалоги с пользователями, например выбор категорий уведомлений
+// после привязки чата. Состояние диалога с каждым пользователем в каждом чате хранится в ConversationStore,
+// поэтому диалог переживает перезапуск, если хранилище общее.
+type Conversations struct {
+	client *TgClient
+	opts   ConversationOptions
+	steps  map[string]StepHandler
+	now    func() time.Time
+}
+
+// NewConversations создаёт диалоги бота. Шаги регистрируются через Step, а обработка обновлений
+// подключается через AddUpdateHandler(conv.Handler()).
+func (c *TgClient) NewConversations(opts ConversationOptions) *Conversations {
+	opts.defaults()
+	return &Conversations{client: c, opts: opts, steps: make(map[string]StepHandler), now: time.Now}
+}
+
+// Step регистрирует обработчик шага name. Вызывается до StartPolling.
+func (cv *Conversations) Step(name string, h StepHandler) {
+	cv.steps[name] = h
+}
+
+// Start начинает диалог key с шага step и начальными данными data, заменяя начатый ранее.
+// Вопрос первого шага отправляет вызывающий.
+func (cv *Conversations) Start(ctx context.Context, key ConversationKey, step string, data map[string]string) error {
+	if _, ok := cv.steps[step]; !ok {
+		return fmt.Errorf("шаг диалога %q не зарегистрирован", step)
+	}
+	if data == nil {
+		data = make(map[string]string)
+	}
+	return cv.opts.Store.Save(ctx, key, ConversationState{Step: step, Data: data, UpdatedAt: cv.now()})
+}
+
+// Cancel прерывает диалог key.
+func (cv *Conversations) Cancel(ctx context.Context, key ConversationKey) error {
+	return cv.opts.Store.Delete(ctx, key)
+}
+
+// Handler возвращает обработчик для AddUpdateHandler: передаёт сообщения и нажатия кнопок пользователей
+// с начатым диалогом обработчику текущего шага. Сообщения других участников группы диалог не продвигают.
+// Команда отмены прерывает диалог, а диалог без ответа дольше Timeout прерывается при следующем сообщении,
+// не передавая его шагу.
+func (cv *Conversations) Handler() UpdateHandler {
+	return func(ctx context.Context, upd Update) error {
+		var key ConversationKey
+		var text string
+		q := upd.CallbackQuery
+		switch {
+		case upd.Message != nil && upd.Message.From != nil:
+			key = ConversationKey{ChatID: upd.Message.Chat.ID, UserID: upd.Message.From.ID}
+			text = upd.Message.Text
+		case q != nil && q.Message != nil:
+			key = ConversationKey{ChatID: q.Message.Chat.ID, UserID: q.From.ID}
+		default:
+			return nil
+		}
+
+		state, err := cv.opts.Store.Load(ctx, key)
+		if errors.Is(err, ErrConversationNotFound) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("не удалось прочитать состояние диалога: %w", err)
+		}
+
+		s := &Session{ChatID: key.ChatID, UserID: key.UserID, Step: state.Step, Data: state.Data, client: cv.client, next: state.Step}
+		if q != nil {
+			// Без HandleCallback на нажатие никто не ответит, и у пользователя зависнет индикатор загрузки
+			defer cv.answer(ctx, q, s)
+		}
+		if cv.now().Sub(state.UpdatedAt) > cv.opts.Timeout {
+			cv.client.logger.Info("диалог прерван по тайм-ауту", "chatID", key.ChatID, "userID", key.UserID, "step", state.Step)
+			return cv.stop(ctx, key, cv.opts.TimeoutText)
+		}
+		if cv.isCancel(text) {
+			cv.client.logger.Info("диалог отменён", "chatID", key.ChatID, "userID", key.UserID, "step", state.Step)
+			return cv.stop(ctx, key, cv.opts.CancelText)
+		}
+
+		h, ok := cv.steps[state.Step]
+		if !ok {
+			_ = cv.opts.Store.Delete(ctx, key)
+			return fmt.Errorf("шаг диалога %q не зарегистрирован", state.Step)
+		}
+		if s.Data == nil {
+			s.Data = make(map[string]string)
+		}
+		if err := h(ctx, s, upd); err != nil {
+			return fmt.Errorf("шаг диалога %q: %w", state.Step, err)
+		}
+		if s.finished {
+			return cv.opts.Store.Delete(ctx, key)
+		}
+		if _, ok := cv.steps[s.next]; !ok {
+			_ = cv.opts.Store.Delete(ctx, key)
+			return fmt.Errorf("шаг диалога %q не зарегистрирован", s.next)
+		}
+		return cv.opts.Store.Save(ctx, key, ConversationState{Step: s.next, Data: s.Data, UpdatedAt: cv.now()})
+	}
+}
+
+// answer отвечает на нажатие кнопки, обработанное диалогом, если на нажатия не отвечают обработчики HandleCallback.
+func (cv *Conversations) answer(ctx context.Context, q *CallbackQuery, s *Session) {
+	if len(cv.client.callbacks) > 0 {
+		return
+	}
+	if err := cv.client.AnswerCallbackQuery(ctx, q.ID, s.answer); err != nil {
+		cv.client.logger.Error("не удалось ответить на нажатие кнопки", "data", q.Data, "error", err)
+	}
+}
+
+// isCancel сообщает, является ли текст командой отмены. В группах команда приходит с именем бота.
+func (cv *Conversations) isCancel(text string) bool {
+	fields := strings.Fields(text)
+	if len(fields) == 0 {
+		return false
+	}
+	cmd, _, _ := strings.Cut(fields[0], "@")
+	return slices.Contains(cv.opts.CancelCommands, cmd)
+}
+
+// stop удаляет диалог и отвечает в чат текстом text, если он задан.
+func (cv *Conversations) stop(ctx context.Context, key ConversationKey, text string) error {
+	if err := cv.opts.Store.Delete(ctx, key); err != nil {
+		return fmt.Errorf("не удалось завершить диалог: %w", err)
+	}
+	if text == "" {
+		return nil
+	}
+	_, err := cv.client.sendText(ctx, MessageOptions{ChatID: key.ChatID, Text: text})
+	return err
+}
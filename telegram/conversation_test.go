@@ -0,0 +1,132 @@
+package telegram
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestConversations(t *testing.T) {
+	var sent, answered []string
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Text            string `json:"text"`
+			CallbackQueryID string `json:"callback_query_id"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		if strings.HasSuffix(r.URL.Path, AnswerCallbackQuery) {
+			answered = append(answered, req.CallbackQueryID+":"+req.Text)
+			// answerCallbackQuery возвращает true, а не объект
+			_, _ = w.Write([]byte(`{"ok":true,"result":true}`))
+			return
+		}
+		sent = append(sent, req.Text)
+		_, _ = w.Write([]byte(`{"ok":true,"result":{}}`))
+	})
+	var logs bytes.Buffer
+	c.logger = slog.New(slog.NewTextHandler(&logs, nil))
+	store := NewMemoryConversationStore()
+	conv := c.NewConversations(ConversationOptions{Store: store, Timeout: time.Minute, CancelText: "Отменено", TimeoutText: "Время вышло"})
+	now := time.Now()
+	conv.now = func() time.Time { return now }
+
+	var finished map[string]string
+	conv.Step("category", func(ctx context.Context, s *Session, upd Update) error {
+		if upd.Message.Text != "billing" && upd.Message.Text != "news" {
+			return s.Reply(ctx, MessageOptions{Text: "Выберите billing или news"})
+		}
+		s.Data["category"] = upd.Message.Text
+		s.Next("confirm")
+		return s.Reply(ctx, MessageOptions{Text: "Подтвердить?"})
+	})
+	conv.Step("confirm", func(_ context.Context, s *Session, upd Update) error {
+		if upd.CallbackQuery.Data != "yes" {
+			return errors.New("неизвестная кнопка")
+		}
+		s.Answer(CallbackAnswer{Text: "Сохранено"})
+		finished = s.Data
+		s.Finish()
+		return nil
+	})
+	handle := conv.Handler()
+	ctx := context.Background()
+	message := func(text string) Update {
+		return Update{Message: &IncomingMessage{From: &User{ID: 7}, Chat: Chat{ID: 7}, Text: text}}
+	}
+	button := func(data string) Update {
+		return Update{CallbackQuery: &CallbackQuery{ID: data, From: User{ID: 7}, Data: data, Message: &IncomingMessage{Chat: Chat{ID: 7}}}}
+	}
+	key := PrivateConversation(7)
+
+	if err := handle(ctx, message("без диалога")); err != nil || len(sent) != 0 {
+		t.Fatalf("чат без диалога не должен обрабатываться: %v %v", err, sent)
+	}
+	if err := conv.Start(ctx, key, "unknown", nil); err == nil {
+		t.Fatal("ожидалась ошибка для незарегистрированного шага")
+	}
+	if err := conv.Start(ctx, key, "category", map[string]string{"user": "u1"}); err != nil {
+		t.Fatalf("Ошибка Start: %v", err)
+	}
+
+	_ = handle(ctx, message("weather"))
+	if state, _ := store.Load(ctx, key); state.Step != "category" {
+		t.Fatalf("после неверного ответа диалог должен остаться на шаге, получено %q", state.Step)
+	}
+	_ = handle(ctx, message("billing"))
+	if err := handle(ctx, button("no")); err == nil {
+		t.Fatal("ошибка шага должна возвращаться")
+	}
+	if state, _ := store.Load(ctx, key); state.Step != "confirm" || state.Data["category"] != "billing" {
+		t.Fatalf("неверное состояние после ошибки шага: %+v", state)
+	}
+	if err := handle(ctx, button("yes")); err != nil {
+		t.Fatalf("Ошибка шага confirm: %v", err)
+	}
+	if finished["user"] != "u1" || finished["category"] != "billing" {
+		t.Fatalf("неверные данные диалога: %v", finished)
+	}
+	if _, err := store.Load(ctx, key); !errors.Is(err, ErrConversationNotFound) {
+		t.Fatalf("завершённый диалог должен удаляться: %v", err)
+	}
+	if strings.Join(sent, "|") != "Выберите billing или news|Подтвердить?" {
+		t.Fatalf("неожиданные ответы: %q", sent)
+	}
+	if strings.Join(answered, "|") != "no:|yes:Сохранено" {
+		t.Fatalf("на нажатия кнопок в диалоге нужно отвечать: %q", answered)
+	}
+	if strings.Contains(logs.String(), "не удалось ответить на нажатие кнопки") {
+		t.Fatalf("ответ на нажатие кнопки должен проходить: %s", logs.String())
+	}
+
+	// Отмена командой и тайм-аут
+	sent = nil
+	_ = conv.Start(ctx, key, "category", nil)
+	_ = handle(ctx, message("/cancel@test_bot"))
+	_ = conv.Start(ctx, key, "category", nil)
+	now = now.Add(2 * time.Minute)
+	_ = handle(ctx, message("billing"))
+	if strings.Join(sent, "|") != "Отменено|Время вышло" {
+		t.Fatalf("неожиданные ответы на отмену и тайм-аут: %q", sent)
+	}
+	if _, err := store.Load(ctx, key); !errors.Is(err, ErrConversationNotFound) {
+		t.Fatalf("просроченный диалог должен удаляться: %v", err)
+	}
+
+	// В группе сообщения других участников не продвигают чужой диалог
+	sent = nil
+	group := ConversationKey{ChatID: -100, UserID: 1}
+	_ = conv.Start(ctx, group, "category", nil)
+	other := Update{Message: &IncomingMessage{From: &User{ID: 2}, Chat: Chat{ID: -100}, Text: "news"}}
+	if err := handle(ctx, other); err != nil || len(sent) != 0 {
+		t.Fatalf("сообщение другого участника не должно обрабатываться: %v %q", err, sent)
+	}
+	if state, _ := store.Load(ctx, group); state.Step != "category" || state.Data["category"] != "" {
+		t.Fatalf("диалог продвинут чужим сообщением: %+v", state)
+	}
+}
@@ -0,0 +1,241 @@
+package telegram
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/epheer/notephee/attachment"
+	"github.com/epheer/notephee/notify"
+	"github.com/epheer/notephee/tracing"
+)
+
+// SendDocument — метод Telegram API для отправки файла.
+const SendDocument = "/sendDocument"
+
+// MaxCaption — предел длины подписи к файлу в символах.
+const MaxCaption = 1024
+
+// MaxUploadSize — предел размера файла, который бот загружает через Bot API (50 МБ).
+const MaxUploadSize = 50 << 20
+
+// DocumentOptions содержит параметры отправки файла в чат.
+type DocumentOptions struct {
+	ChatID   int64           // Идентификатор чата Telegram
+	Document attachment.File // Файл
+	Caption  string          // Подпись к файлу (необязательно)
+
+	BusinessConnectionID string // Отправка от имени бизнес-аккаунта (необязательно)
+
+	UserID string // Внутренний ID пользователя для журнала доставки (необязательно)
+	ID     string // Идентификатор попытки в журнале доставки (генерируется, если пуст)
+}
+
+// documentResult — часть ответа sendDocument, нужная для получения file_id.
+type documentResult struct {
+	Document struct {
+		FileID string `json:"file_id"`
+	} `json:"document"`
+}
+
+// SendDocument отправляет файл в чат.
+//
+// Файл загружается в Telegram только при первой отправке: полученный file_id запоминается
+// по хэшу содержимого, и следующие отправки того же файла передают только его.
+// Пустой MIME-тип определяется по содержимому, а файл больше MaxUploadSize отклоняется с *attachment.SizeError,
+// сжимается или разбивается на части по SetAttachmentPolicy. Подпись остаётся у первой части,
+// возвращается ответ на последнюю.
+func (c *TgClient) SendDocument(ctx context.Context, options DocumentOptions) (TgResponse, error) {
+	files, err := c.attachmentPolicy().Apply(options.Document)
+	if err != nil {
+		return TgResponse{}, err
+	}
+	return c.sendDocumentParts(ctx, options, files, nil)
+}
+
+// SetAttachmentPolicy задаёт предел размера файлов и действие для больших: отклонить, сжать или разбить
+// на части. Предел больше MaxUploadSize или нулевой заменяется на MaxUploadSize.
+// Вызывается при настройке, до начала отправок.
+func (c *TgClient) SetAttachmentPolicy(p attachment.Policy) {
+	if p.MaxSize <= 0 || p.MaxSize > MaxUploadSize {
+		p.MaxSize = MaxUploadSize
+	}
+	c.attachPolicy = p
+}
+
+// attachmentPolicy возвращает политику вложений; без SetAttachmentPolicy файлы больше MaxUploadSize отклоняются.
+func (c *TgClient) attachmentPolicy() attachment.Policy {
+	if c.attachPolicy.MaxSize == 0 {
+		return attachment.Policy{MaxSize: MaxUploadSize}
+	}
+	return c.attachPolicy
+}
+
+// sendDocumentParts отправляет файлы, подготовленные attachment.Policy.Apply, по порядку с подписью у первого.
+// hashes — заранее посчитанные хэши файлов (nil — посчитать).
+func (c *TgClient) sendDocumentParts(ctx context.Context, options DocumentOptions, files []attachment.File, hashes []string) (TgResponse, error) {
+	var res TgResponse
+	for i, f := range files {
+		part := options
+		part.Document = f
+		if i > 0 {
+			part.Caption = ""
+			part.ID = ""
+		}
+		var hash string
+		if hashes != nil {
+			hash = hashes[i]
+		} else {
+			hash = f.Hash()
+		}
+		var err error
+		if res, err = c.sendDocumentLogged(ctx, part, hash); err != nil {
+			return res, err
+		}
+	}
+	return res, nil
+}
+
+// SendFile отправляет файл получателю msg.To с подписью msg.Text, обрезанной до MaxCaption.
+// Используется обёрткой overflow для сообщений длиннее предела канала.
+func (c *TgClient) SendFile(ctx context.Context, msg notify.Message, file attachment.File) error {
+	chatID, err := strconv.ParseInt(msg.To, 10, 64)
+	if err != nil {
+		return fmt.Errorf("некорректный chatID %q: %w", msg.To, err)
+	}
+
+	caption := msg.Text
+	if r := []rune(caption); len(r) > MaxCaption {
+		caption = string(r[:MaxCaption-1]) + "…"
+	}
+	_, err = c.SendDocument(ctx, DocumentOptions{
+		ChatID:   chatID,
+		Document: file,
+		Caption:  caption,
+		UserID:   msg.UserID,
+		ID:       msg.ID,
+	})
+	return err
+}
+
+// sendDocumentLogged отправляет файл с заранее посчитанным хэшем содержимого и пишет попытку в журнал.
+// При массовой отправке хэш считается один раз на всю рассылку.
+func (c *TgClient) sendDocumentLogged(ctx context.Context, options DocumentOptions, hash string) (TgResponse, error) {
+	if !c.Enabled {
+		return TgResponse{}, fmt.Errorf("функционал Telegram отключён: некорректная конфигурация")
+	}
+	if err := c.checkSuppressed(ctx, options.ChatID); err != nil {
+		return TgResponse{}, err
+	}
+	if err := c.wait(ctx, options.ChatID); err != nil {
+		return TgResponse{}, err
+	}
+	if err := c.inflight.Acquire(); err != nil {
+		return TgResponse{}, err
+	}
+	defer c.inflight.Release()
+
+	ctx, span := tracing.Start(ctx, Channel, strconv.FormatInt(options.ChatID, 10))
+	started := time.Now()
+	res, err := c.withFloodRetry(ctx, options.ChatID, func() (*TgResponse, error) {
+		return c.sendDocument(ctx, options, hash)
+	})
+	tracing.End(span, err)
+	c.logDelivery(ctx, MessageOptions{
+		ChatID: options.ChatID,
+		Text:   options.Caption,
+		UserID: options.UserID,
+		ID:     options.ID,
+	}, started, err)
+	if err != nil {
+		return TgResponse{}, err
+	}
+	return *res, nil
+}
+
+func (c *TgClient) sendDocument(ctx context.Context, options DocumentOptions, hash string) (*TgResponse, error) {
+	if fileID, ok := c.fileID(hash); ok {
+		return c.sendDocumentByID(ctx, options, fileID)
+	}
+
+	// Загрузка идёт под блокировкой, чтобы при массовой отправке файл загрузился один раз,
+	// а остальные получатели дождались file_id
+	c.uploadMu.Lock()
+	defer c.uploadMu.Unlock()
+
+	if fileID, ok := c.fileID(hash); ok {
+		return c.sendDocumentByID(ctx, options, fileID)
+	}
+
+	res, err := c.uploadDocument(ctx, options)
+	if err != nil {
+		return res, err
+	}
+
+	var doc documentResult
+	if err := json.Unmarshal(res.Result, &doc); err == nil && doc.Document.FileID != "" {
+		c.filesMu.Lock()
+		c.fileIDs[hash] = doc.Document.FileID
+		c.filesMu.Unlock()
+	}
+	return res, nil
+}
+
+// fileID возвращает сохранённый file_id файла с хэшем hash.
+func (c *TgClient) fileID(hash string) (string, bool) {
+	c.filesMu.RLock()
+	defer c.filesMu.RUnlock()
+	id, ok := c.fileIDs[hash]
+	return id, ok
+}
+
+// sendDocumentByID отправляет ранее загруженный файл по его file_id.
+func (c *TgClient) sendDocumentByID(ctx context.Context, options DocumentOptions, fileID string) (*TgResponse, error) {
+	data, err := json.Marshal(struct {
+		ChatID               int64  `json:"chat_id"`
+		Document             string `json:"document"`
+		Caption              string `json:"caption,omitempty"`
+		BusinessConnectionID string `json:"business_connection_id,omitempty"`
+	}{options.ChatID, fileID, options.Caption, options.BusinessConnectionID})
+	if err != nil {
+		return nil, err
+	}
+	return c.postReq(ctx, data, SendDocument)
+}
+
+// uploadDocument загружает файл запросом multipart/form-data.
+func (c *TgClient) uploadDocument(ctx context.Context, options DocumentOptions) (*TgResponse, error) {
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+
+	_ = w.WriteField("chat_id", strconv.FormatInt(options.ChatID, 10))
+	if options.Caption != "" {
+		_ = w.WriteField("caption", options.Caption)
+	}
+	if options.BusinessConnectionID != "" {
+		_ = w.WriteField("business_connection_id", options.BusinessConnectionID)
+	}
+	part, err := w.CreateFormFile("document", options.Document.Name)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := part.Write(options.Document.Data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.tg(SendDocument), &body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", w.FormDataContentType())
+
+	return c.do(req)
+}
@@ -0,0 +1,76 @@
+package telegram
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Режимы разметки текста сообщений (MessageOptions.ParseMode).
+const (
+	ParseModeHTML       = "HTML"
+	ParseModeMarkdownV2 = "MarkdownV2"
+)
+
+// markdownV2Escaper экранирует все символы, которые MarkdownV2 считает разметкой.
+var markdownV2Escaper = strings.NewReplacer(
+	`\`, `\\`, "_", `\_`, "*", `\*`, "[", `\[`, "]", `\]`, "(", `\(`, ")", `\)`, "~", `\~`, "`", "\\`",
+	">", `\>`, "#", `\#`, "+", `\+`, "-", `\-`, "=", `\=`, "|", `\|`, "{", `\{`, "}", `\}`, ".", `\.`, "!", `\!`,
+)
+
+// htmlEscaper экранирует символы, которые Telegram разбирает в режиме HTML.
+var htmlEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&quot;")
+
+// EscapeMarkdownV2 экранирует текст для вставки в сообщение с ParseModeMarkdownV2: пользовательский
+// ввод с символами разметки не ломает сообщение (Telegram отклоняет его с ошибкой 400) и не добавляет
+// своё форматирование или ссылки.
+func EscapeMarkdownV2(s string) string {
+	return markdownV2Escaper.Replace(s)
+}
+
+// EscapeMarkdownV2Code экранирует текст для вставки внутрь `кода` или ```блока кода``` MarkdownV2,
+// где разметкой считаются только обратная кавычка и обратный слэш.
+func EscapeMarkdownV2Code(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "`", "\\`").Replace(s)
+}
+
+// EscapeMarkdownV2URL экранирует адрес для вставки в ссылку MarkdownV2 [текст](адрес).
+func EscapeMarkdownV2URL(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ")", `\)`).Replace(s)
+}
+
+// EscapeHTML экранирует текст для вставки в сообщение с ParseModeHTML.
+func EscapeHTML(s string) string {
+	return htmlEscaper.Replace(s)
+}
+
+// MarkdownV2f работает как fmt.Sprintf, но экранирует каждый аргумент EscapeMarkdownV2, оставляя
+// разметку в format как есть:
+//
+//	text := telegram.MarkdownV2f("*Ошибка оплаты* заказа %s: %v", orderID, err)
+func MarkdownV2f(format string, args ...any) string {
+	return sprintfEscaped(EscapeMarkdownV2, format, args)
+}
+
+// HTMLf работает как fmt.Sprintf, но экранирует каждый аргумент EscapeHTML, оставляя разметку в format как есть.
+func HTMLf(format string, args ...any) string {
+	return sprintfEscaped(EscapeHTML, format, args)
+}
+
+func sprintfEscaped(escape func(string) string, format string, args []any) string {
+	wrapped := make([]any, len(args))
+	for i, arg := range args {
+		wrapped[i] = escapedArg{value: arg, escape: escape}
+	}
+	return fmt.Sprintf(format, wrapped...)
+}
+
+// escapedArg форматирует значение с тем же глаголом и флагами, что и в исходной строке формата,
+// и экранирует результат.
+type escapedArg struct {
+	value  any
+	escape func(string) string
+}
+
+func (a escapedArg) Format(f fmt.State, verb rune) {
+	_, _ = f.Write([]byte(a.escape(fmt.Sprintf(fmt.FormatString(f, verb), a.value))))
+}
@@ -0,0 +1,112 @@
+package telegram
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// Повторы при ответе 429 Too Many Requests с retry_after.
+const (
+	DefaultFloodRetries = 3                // Повторов одной отправки по умолчанию
+	MaxFloodWait        = time.Minute      // Дольше retry_after не ждём: ошибка возвращается сразу
+	floodRecover        = 30 * time.Second // Через сколько без 429 лимит клиента возвращается к исходному
+)
+
+// floodControl приостанавливает все отправки клиента после 429 и снижает общий лимит,
+// пока Telegram не перестанет отвечать 429.
+type floodControl struct {
+	mu     sync.Mutex
+	until  time.Time  // Отправки приостановлены до этого момента
+	slowed time.Time  // Время последнего снижения лимита; нулевое — лимит исходный
+	base   rate.Limit // Исходный лимит клиента
+}
+
+// retryAfter возвращает задержку из ответа 429 или 0, если повторять не нужно.
+func retryAfter(res *TgResponse, err error) time.Duration {
+	if err == nil || res == nil || res.ErrorCode != 429 || res.Parameters.RetryAfter <= 0 {
+		return 0
+	}
+	return time.Duration(res.Parameters.RetryAfter) * time.Second
+}
+
+// hit учитывает ответ 429: ставит паузу на delay и снижает встроенный лимитер клиента на четверть.
+// Внешний лимитер, подключённый через SetLimiter, не меняется — им управляет владелец.
+func (f *floodControl) hit(limiter *rate.Limiter, delay time.Duration) {
+	now := time.Now()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if until := now.Add(delay); until.After(f.until) {
+		f.until = until
+	}
+	if limiter == nil {
+		return
+	}
+	if f.slowed.IsZero() {
+		f.base = limiter.Limit()
+	}
+	f.slowed = now
+	limiter.SetLimit(max(limiter.Limit()*3/4, 1))
+}
+
+// wait ждёт окончания паузы после 429 и возвращает встроенному лимитеру исходную частоту,
+// если 429 давно не было.
+func (f *floodControl) wait(ctx context.Context, limiter *rate.Limiter) error {
+	f.mu.Lock()
+	pause := time.Until(f.until)
+	if limiter != nil && !f.slowed.IsZero() && time.Since(f.slowed) > floodRecover {
+		limiter.SetLimit(f.base)
+		f.slowed = time.Time{}
+	}
+	f.mu.Unlock()
+
+	return sleep(ctx, pause)
+}
+
+// sleep ждёт d или отмены ctx.
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// withFloodRetry выполняет do и при ответе 429 повторяет его после retry_after, не больше
+// c.floodRetries раз. Пауза распространяется на все отправки клиента, даже если повтора не будет.
+func (c *TgClient) withFloodRetry(ctx context.Context, chatID int64, do func() (*TgResponse, error)) (*TgResponse, error) {
+	for attempt := 0; ; attempt++ {
+		res, err := do()
+		delay := retryAfter(res, err)
+		if delay == 0 {
+			return res, err
+		}
+		c.flood.hit(c.defaultLimiter(), delay)
+		if attempt >= c.floodRetries || delay > MaxFloodWait {
+			return res, err
+		}
+
+		c.logger.Warn("Telegram ограничил частоту отправки, повтор после паузы", "chat_id", chatID, "retry_after", delay, "attempt", attempt+1)
+		if sleep(ctx, delay) != nil {
+			return res, err
+		}
+	}
+}
+
+// defaultLimiter возвращает встроенный лимитер клиента или nil, если подключён внешний.
+func (c *TgClient) defaultLimiter() *rate.Limiter {
+	if c.limiter != nil {
+		return nil
+	}
+	return c.rate
+}
@@ -0,0 +1,71 @@
+package telegram
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrInviteSignature возвращается, если код из ссылки не подписан или подпись неверна.
+var ErrInviteSignature = errors.New("подпись инвайта неверна")
+
+// inviteSigLen — длина подписи в байтах. Вместе с кодом она укладывается в 64 символа
+// параметра start: 36 + 1 + 22.
+const inviteSigLen = 16
+
+// SetSigningKey включает подпись инвайтов HMAC-SHA256: ссылка получает вид ?start=<код>_<подпись>,
+// а ResolveBinding отклоняет коды без подписи или с неверной подписью до обращения к InviteStore.
+// Так подобранный или изменённый код не даёт привязать чат, а хранилище не перебирается по словарю.
+//
+// key должен быть не короче 32 байт. previous — прежние ключи, подписи которых ещё принимаются
+// (на время смены ключа, пока не истекли выпущенные ими инвайты). Вызывается до выпуска инвайтов.
+func (bm *BindingManager) SetSigningKey(key []byte, previous ...[]byte) error {
+	for _, k := range append([][]byte{key}, previous...) {
+		if len(k) < 32 {
+			return fmt.Errorf("ключ подписи инвайтов должен быть не короче 32 байт")
+		}
+	}
+	bm.keys = append([][]byte{key}, previous...)
+	return nil
+}
+
+// payload возвращает значение параметра start для кода инвайта: код с подписью, если задан ключ.
+func (bm *BindingManager) payload(code string) string {
+	if len(bm.keys) == 0 {
+		return code
+	}
+	return code + "_" + signInvite(bm.keys[0], code)
+}
+
+// verify проверяет подпись значения параметра start и возвращает код инвайта.
+// Без ключа значение и есть код.
+func (bm *BindingManager) verify(payload string) (string, error) {
+	if len(bm.keys) == 0 {
+		return payload, nil
+	}
+	code, sig, ok := strings.Cut(payload, "_")
+	if !ok {
+		return "", ErrInviteSignature
+	}
+	// Все ключи проверяются без раннего выхода, чтобы время ответа не зависело от того, какой подошёл
+	valid := false
+	for _, key := range bm.keys {
+		if hmac.Equal([]byte(sig), []byte(signInvite(key, code))) {
+			valid = true
+		}
+	}
+	if !valid {
+		return "", ErrInviteSignature
+	}
+	return code, nil
+}
+
+// signInvite возвращает усечённую подпись HMAC-SHA256 кода в base64url без выравнивания.
+func signInvite(key []byte, code string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(code))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil)[:inviteSigLen])
+}
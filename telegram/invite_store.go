@@ -0,0 +1,98 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// Invite — выпущенный, но ещё не подтверждённый инвайт.
+type Invite struct {
+	Code     string            // Код из ссылки (/start <code>)
+	UserID   string            // Внутренний ID пользователя, которому выпущен инвайт
+	Batch    string            // Партия инвайтов для статистики
+	Expiry   time.Time         // Время окончания действия
+	Metadata map[string]string // Данные, которые передаются в Binding
+	MultiUse bool              // Инвайт не удаляется после первой привязки
+	MaxUses  int               // Предел привязок многоразового инвайта; 0 — без ограничения
+	Uses     int               // Сколько раз инвайт уже использован
+}
+
+// Exhausted сообщает, исчерпан ли инвайт после очередного использования: одноразовый — после первого,
+// многоразовый — после MaxUses. Хранилища удаляют исчерпанные инвайты в Take.
+func (i Invite) Exhausted() bool {
+	return !i.MultiUse || i.MaxUses > 0 && i.Uses >= i.MaxUses
+}
+
+// Expired сообщает, истёк ли инвайт к моменту now.
+func (i Invite) Expired(now time.Time) bool {
+	return !i.Expiry.IsZero() && now.After(i.Expiry)
+}
+
+// ErrInviteNotFound возвращается, если инвайт не существует, уже использован или истёк.
+var ErrInviteNotFound = errors.New("инвайт просрочен или не найден")
+
+// InviteStore хранит выпущенные инвайты до подтверждения.
+//
+// Put принимает сразу пачку инвайтов, чтобы постоянные хранилища могли записать их
+// за одно обращение (например, одним INSERT или pipeline в Redis).
+type InviteStore interface {
+	// Put сохраняет инвайты.
+	Put(ctx context.Context, invites []Invite) error
+	// Take атомарно использует инвайт: увеличивает Uses и удаляет его, если он одноразовый
+	// или исчерпал MaxUses. Возвращает ErrInviteNotFound, если инвайта нет или он истёк.
+	Take(ctx context.Context, code string) (Invite, error)
+	// Delete удаляет инвайты по кодам; отсутствующие коды игнорируются.
+	Delete(ctx context.Context, codes []string) error
+}
+
+// MemoryInviteStore — InviteStore в памяти процесса.
+type MemoryInviteStore struct {
+	mu      sync.Mutex
+	invites map[string]Invite
+}
+
+// NewMemoryInviteStore создаёт пустое хранилище инвайтов в памяти.
+func NewMemoryInviteStore() *MemoryInviteStore {
+	return &MemoryInviteStore{invites: make(map[string]Invite)}
+}
+
+// Put сохраняет инвайты.
+func (s *MemoryInviteStore) Put(_ context.Context, invites []Invite) error {
+	s.mu.Lock()
+	for _, inv := range invites {
+		s.invites[inv.Code] = inv
+	}
+	s.mu.Unlock()
+	return nil
+}
+
+// Take атомарно использует инвайт.
+func (s *MemoryInviteStore) Take(_ context.Context, code string) (Invite, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	inv, ok := s.invites[code]
+	if !ok || inv.Expired(time.Now()) {
+		delete(s.invites, code)
+		return Invite{}, ErrInviteNotFound
+	}
+	inv.Uses++
+	if inv.Exhausted() {
+		delete(s.invites, code)
+	} else {
+		s.invites[code] = inv
+	}
+	return inv, nil
+}
+
+// Delete удаляет инвайты по кодам.
+func (s *MemoryInviteStore) Delete(_ context.Context, codes []string) error {
+	s.mu.Lock()
+	for _, code := range codes {
+		delete(s.invites, code)
+	}
+	s.mu.Unlock()
+	return nil
+}
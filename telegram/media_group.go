@@ -0,0 +1,184 @@
+package telegram
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/epheer/notephee/attachment"
+	"github.com/epheer/notephee/tracing"
+)
+
+// SendMediaGroup — метод Telegram API для отправки альбома.
+const SendMediaGroup = "/sendMediaGroup"
+
+// Пределы числа элементов альбома в Bot API.
+const (
+	MinMediaGroup = 2
+	MaxMediaGroup = 10
+)
+
+// MediaType — тип элемента альбома.
+type MediaType string
+
+// Типы элементов альбома. Telegram не смешивает документы с фотографиями в одном альбоме.
+const (
+	MediaPhoto    MediaType = "photo"
+	MediaDocument MediaType = "document"
+)
+
+// MediaItem — элемент альбома: загружаемый файл или ранее загруженный по file_id.
+type MediaItem struct {
+	Type    MediaType       // Тип элемента
+	File    attachment.File // Загружаемый файл (если FileID пуст)
+	FileID  string          // file_id ранее загруженного файла (необязательно)
+	Caption string          // Подпись к элементу (необязательно)
+}
+
+// MediaGroupOptions содержит параметры отправки альбома в чат.
+type MediaGroupOptions struct {
+	ChatID  int64       // Идентификатор чата Telegram
+	Items   []MediaItem // От MinMediaGroup до MaxMediaGroup элементов одного типа
+	Caption string      // Подпись альбома — показывается под ним, если у первого элемента нет своей
+
+	BusinessConnectionID string // Отправка от имени бизнес-аккаунта (необязательно)
+
+	UserID string // Внутренний ID пользователя для журнала доставки (необязательно)
+	ID     string // Идентификатор попытки в журнале доставки (генерируется, если пуст)
+}
+
+// inputMedia — элемент поля media запроса sendMediaGroup.
+type inputMedia struct {
+	Type    MediaType `json:"type"`
+	Media   string    `json:"media"`
+	Caption string    `json:"caption,omitempty"`
+}
+
+// validate проверяет альбом по ограничениям Bot API до отправки запроса.
+func (o MediaGroupOptions) validate() error {
+	if n := len(o.Items); n < MinMediaGroup || n > MaxMediaGroup {
+		return fmt.Errorf("в альбоме должно быть от %d до %d элементов, получено %d", MinMediaGroup, MaxMediaGroup, n)
+	}
+	if len([]rune(o.Caption)) > MaxCaption {
+		return fmt.Errorf("подпись альбома длиннее %d символов", MaxCaption)
+	}
+	for i, item := range o.Items {
+		if item.Type != MediaPhoto && item.Type != MediaDocument {
+			return fmt.Errorf("элемент %d: неизвестный тип %q", i, item.Type)
+		}
+		if item.Type != o.Items[0].Type {
+			return fmt.Errorf("элемент %d: фотографии и документы нельзя смешивать в одном альбоме", i)
+		}
+		if item.FileID == "" && len(item.File.Data) == 0 {
+			return fmt.Errorf("элемент %d: нужен файл или file_id", i)
+		}
+		if len([]rune(item.Caption)) > MaxCaption {
+			return fmt.Errorf("элемент %d: подпись длиннее %d символов", i, MaxCaption)
+		}
+	}
+	return nil
+}
+
+// SendMediaGroup отправляет в чат альбом из фотографий или документов, например отчёт с несколькими графиками.
+//
+// Подпись альбома ставится первому элементу, если у него нет своей: так Telegram показывает её под альбомом.
+// Результат ответа — массив отправленных сообщений.
+func (c *TgClient) SendMediaGroup(ctx context.Context, options MediaGroupOptions) (TgResponse, error) {
+	if !c.Enabled {
+		return TgResponse{}, fmt.Errorf("функционал Telegram отключён: некорректная конфигурация")
+	}
+	if err := options.validate(); err != nil {
+		return TgResponse{}, err
+	}
+	body, contentType, err := mediaGroupBody(options)
+	if err != nil {
+		return TgResponse{}, err
+	}
+
+	if err := c.checkSuppressed(ctx, options.ChatID); err != nil {
+		return TgResponse{}, err
+	}
+	if err := c.wait(ctx, options.ChatID); err != nil {
+		return TgResponse{}, err
+	}
+	if err := c.inflight.Acquire(); err != nil {
+		return TgResponse{}, err
+	}
+	defer c.inflight.Release()
+
+	ctx, span := tracing.Start(ctx, Channel, strconv.FormatInt(options.ChatID, 10))
+	started := time.Now()
+	res, err := c.withFloodRetry(ctx, options.ChatID, func() (*TgResponse, error) {
+		return c.postMultipart(ctx, body, contentType, SendMediaGroup)
+	})
+	tracing.End(span, err)
+	c.logDelivery(ctx, MessageOptions{
+		ChatID: options.ChatID,
+		Text:   options.Caption,
+		UserID: options.UserID,
+		ID:     options.ID,
+	}, started, err)
+	if err != nil {
+		return TgResponse{}, err
+	}
+	return *res, nil
+}
+
+// mediaGroupBody собирает тело multipart/form-data для sendMediaGroup. Загружаемые файлы передаются
+// отдельными частями, на которые элементы media ссылаются как attach://fileN.
+func mediaGroupBody(options MediaGroupOptions) ([]byte, string, error) {
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+
+	media := make([]inputMedia, len(options.Items))
+	for i, item := range options.Items {
+		media[i] = inputMedia{Type: item.Type, Media: item.FileID, Caption: item.Caption}
+		if i == 0 && media[i].Caption == "" {
+			media[i].Caption = options.Caption
+		}
+		if item.FileID != "" {
+			continue
+		}
+
+		name := "file" + strconv.Itoa(i)
+		media[i].Media = "attach://" + name
+		part, err := w.CreateFormFile(name, item.File.Name)
+		if err != nil {
+			return nil, "", err
+		}
+		if _, err := part.Write(item.File.Data); err != nil {
+			return nil, "", err
+		}
+	}
+
+	data, err := json.Marshal(media)
+	if err != nil {
+		return nil, "", err
+	}
+	_ = w.WriteField("chat_id", strconv.FormatInt(options.ChatID, 10))
+	_ = w.WriteField("media", string(data))
+	if options.BusinessConnectionID != "" {
+		_ = w.WriteField("business_connection_id", options.BusinessConnectionID)
+	}
+	if err := w.Close(); err != nil {
+		return nil, "", err
+	}
+	return body.Bytes(), w.FormDataContentType(), nil
+}
+
+// postMultipart отправляет готовое тело multipart/form-data. Тело не потребляется,
+// поэтому повтор после 429 отправляет его заново.
+func (c *TgClient) postMultipart(ctx context.Context, body []byte, contentType, method string) (*TgResponse, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.tg(method), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", contentType)
+
+	return c.do(req)
+}
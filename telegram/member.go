@@ -0,0 +1,99 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/epheer/notephee/events"
+	"github.com/epheer/notephee/notify"
+	"github.com/epheer/notephee/suppression"
+)
+
+// ErrSuppressed возвращается при попытке отправить сообщение в чат из списка подавления.
+var ErrSuppressed = notify.ErrSuppressed
+
+// SetSuppressionStore подключает список подавления: в чаты из него сообщения не отправляются.
+// StartPolling добавляет в список чаты, где пользователь заблокировал бота или бота исключили,
+// и убирает их, когда бота разблокируют или вернут в чат.
+func (c *TgClient) SetSuppressionStore(store suppression.Store) {
+	c.suppression = store
+}
+
+// SetEvents подключает шину, в которую StartPolling публикует events.BotBlocked и events.BotUnblocked.
+func (c *TgClient) SetEvents(bus *events.Bus) {
+	c.bus = bus
+}
+
+// checkSuppressed возвращает ErrSuppressed, если чат chatID в списке подавления. Ошибка чтения
+// списка пишется в лог и не мешает отправке.
+func (c *TgClient) checkSuppressed(ctx context.Context, chatID int64) error {
+	if c.suppression == nil {
+		return nil
+	}
+	suppressed, err := c.suppression.IsSuppressed(ctx, Channel, strconv.FormatInt(chatID, 10), "")
+	if err != nil {
+		c.logger.Error("не удалось проверить список подавления", "chat_id", chatID, "error", err)
+	}
+	if suppressed {
+		return fmt.Errorf("чат %d: %w", chatID, ErrSuppressed)
+	}
+	return nil
+}
+
+// handleMemberUpdate обрабатывает изменение статуса бота в чате. Когда бот перестаёт быть участником,
+// привязка чата удаляется, а чат попадает в список подавления, чтобы устаревшие chatID не копились.
+// Когда бот снова в чате, чат убирается из списка подавления; привязку пользователь создаёт заново.
+func (c *TgClient) handleMemberUpdate(ctx context.Context, upd *ChatMemberUpdated, bm *BindingManager) {
+	wasIn, isIn := upd.OldChatMember.InChat(), upd.NewChatMember.InChat()
+	if wasIn == isIn {
+		return
+	}
+	chatID := upd.Chat.ID
+	address := strconv.FormatInt(chatID, 10)
+	event := events.Event{
+		Type:      events.BotUnblocked,
+		Time:      time.Unix(upd.Date, 0),
+		Channel:   Channel,
+		Recipient: address,
+		Data:      map[string]string{"chat_type": upd.Chat.Type, "status": upd.NewChatMember.Status},
+	}
+
+	if isIn {
+		c.logger.Info("бот снова в чате", "chat_id", chatID, "status", upd.NewChatMember.Status)
+		if c.suppression != nil {
+			if err := c.suppression.Remove(ctx, Channel, address); err != nil {
+				c.logger.Error("не удалось убрать чат из списка подавления", "chat_id", chatID, "error", err)
+			}
+		}
+		c.bus.Publish(event)
+		return
+	}
+
+	c.logger.Info("бот заблокирован или исключён из чата", "chat_id", chatID, "status", upd.NewChatMember.Status)
+	event.Type = events.BotBlocked
+	binding, err := bm.bindings.ByChat(ctx, chatID)
+	switch {
+	case err == nil:
+		event.Data["user_id"] = binding.UserID
+		if err := bm.bindings.Delete(ctx, binding.UserID, chatID); err != nil {
+			c.logger.Error("не удалось удалить привязку чата", "chat_id", chatID, "user_id", binding.UserID, "error", err)
+		}
+	case !errors.Is(err, ErrBindingNotFound):
+		c.logger.Error("не удалось прочитать привязку чата", "chat_id", chatID, "error", err)
+	}
+	if c.suppression != nil {
+		err := c.suppression.Suppress(ctx, suppression.Entry{
+			Channel:   Channel,
+			Address:   address,
+			Reason:    suppression.ReasonBlocked,
+			CreatedAt: event.Time,
+		})
+		if err != nil {
+			c.logger.Error("не удалось добавить чат в список подавления", "chat_id", chatID, "error", err)
+		}
+	}
+	c.bus.Publish(event)
+}
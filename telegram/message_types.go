@@ -0,0 +1,217 @@
+package telegram
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+// Методы Telegram API для сообщений с геопозицией, контактом и опросом.
+const (
+	SendLocation = "/sendLocation"
+	SendContact  = "/sendContact"
+	SendPoll     = "/sendPoll"
+)
+
+// Пределы опроса в Bot API.
+const (
+	MaxPollQuestion = 300 // Длина вопроса в символах
+	MinPollOptions  = 2
+	MaxPollOptions  = 12
+	MaxPollOption   = 100 // Длина варианта ответа в символах
+)
+
+// Типы опроса.
+const (
+	PollRegular = "regular"
+	PollQuiz    = "quiz"
+)
+
+// LocationOptions содержит параметры отправки геопозиции.
+type LocationOptions struct {
+	ChatID             int64   `json:"chat_id"`                       // Идентификатор чата Telegram
+	Latitude           float64 `json:"latitude"`                      // Широта
+	Longitude          float64 `json:"longitude"`                     // Долгота
+	HorizontalAccuracy float64 `json:"horizontal_accuracy,omitempty"` // Радиус неопределённости в метрах, 0–1500 (необязательно)
+	LivePeriod         int     `json:"live_period,omitempty"`         // Срок трансляции геопозиции в секундах, 60–86400 (необязательно)
+
+	BusinessConnectionID string `json:"business_connection_id,omitempty"` // Отправка от имени бизнес-аккаунта (необязательно)
+
+	UserID string `json:"-"` // Внутренний ID пользователя для журнала доставки (необязательно)
+	ID     string `json:"-"` // Идентификатор попытки в журнале доставки (генерируется, если пуст)
+}
+
+// ContactOptions содержит параметры отправки контакта.
+type ContactOptions struct {
+	ChatID      int64  `json:"chat_id"`             // Идентификатор чата Telegram
+	PhoneNumber string `json:"phone_number"`        // Номер телефона
+	FirstName   string `json:"first_name"`          // Имя
+	LastName    string `json:"last_name,omitempty"` // Фамилия (необязательно)
+	VCard       string `json:"vcard,omitempty"`     // Дополнительные данные в формате vCard (необязательно)
+
+	BusinessConnectionID string `json:"business_connection_id,omitempty"` // Отправка от имени бизнес-аккаунта (необязательно)
+
+	UserID string `json:"-"` // Внутренний ID пользователя для журнала доставки (необязательно)
+	ID     string `json:"-"` // Идентификатор попытки в журнале доставки (генерируется, если пуст)
+}
+
+// PollOptions содержит параметры отправки опроса.
+type PollOptions struct {
+	ChatID   int64    // Идентификатор чата Telegram
+	Question string   // Вопрос, до MaxPollQuestion символов
+	Options  []string // Варианты ответа: от MinPollOptions до MaxPollOptions, каждый до MaxPollOption символов
+
+	Public                bool          // Неанонимный опрос: видно, кто как ответил (по умолчанию анонимный)
+	Type                  string        // PollRegular (по умолчанию) или PollQuiz
+	AllowsMultipleAnswers bool          // Можно выбрать несколько вариантов (только для PollRegular)
+	CorrectOption         int           // Индекс верного ответа (только для PollQuiz)
+	Explanation           string        // Пояснение к верному ответу викторины (необязательно)
+	OpenPeriod            time.Duration // Сколько опрос открыт, 5–600 секунд (необязательно)
+	CloseDate             time.Time     // Когда опрос закроется, вместо OpenPeriod (необязательно)
+
+	BusinessConnectionID string // Отправка от имени бизнес-аккаунта (необязательно)
+
+	UserID string // Внутренний ID пользователя для журнала доставки (необязательно)
+	ID     string // Идентификатор попытки в журнале доставки (генерируется, если пуст)
+}
+
+// pollOption — вариант ответа в запросе sendPoll.
+type pollOption struct {
+	Text string `json:"text"`
+}
+
+// pollRequest — тело запроса sendPoll.
+type pollRequest struct {
+	ChatID                int64        `json:"chat_id"`
+	Question              string       `json:"question"`
+	Options               []pollOption `json:"options"`
+	IsAnonymous           bool         `json:"is_anonymous"`
+	Type                  string       `json:"type,omitempty"`
+	AllowsMultipleAnswers bool         `json:"allows_multiple_answers,omitempty"`
+	CorrectOptionID       *int         `json:"correct_option_id,omitempty"`
+	Explanation           string       `json:"explanation,omitempty"`
+	OpenPeriod            int          `json:"open_period,omitempty"`
+	CloseDate             int64        `json:"close_date,omitempty"`
+	BusinessConnectionID  string       `json:"business_connection_id,omitempty"`
+}
+
+// SendLocation отправляет в чат точку на карте.
+func (c *TgClient) SendLocation(ctx context.Context, options LocationOptions) (TgResponse, error) {
+	if options.Latitude < -90 || options.Latitude > 90 || options.Longitude < -180 || options.Longitude > 180 {
+		return TgResponse{}, fmt.Errorf("некорректные координаты %v, %v", options.Latitude, options.Longitude)
+	}
+	text := strconv.FormatFloat(options.Latitude, 'f', -1, 64) + "," + strconv.FormatFloat(options.Longitude, 'f', -1, 64)
+	return c.sendJSON(ctx, SendLocation, MessageOptions{
+		ChatID: options.ChatID,
+		Text:   text,
+		UserID: options.UserID,
+		ID:     options.ID,
+	}, options)
+}
+
+// SendContact отправляет в чат карточку контакта.
+func (c *TgClient) SendContact(ctx context.Context, options ContactOptions) (TgResponse, error) {
+	if options.PhoneNumber == "" || options.FirstName == "" {
+		return TgResponse{}, fmt.Errorf("у контакта должны быть номер телефона и имя")
+	}
+	return c.sendJSON(ctx, SendContact, MessageOptions{
+		ChatID: options.ChatID,
+		Text:   options.PhoneNumber,
+		UserID: options.UserID,
+		ID:     options.ID,
+	}, options)
+}
+
+// SendPoll отправляет в чат опрос или викторину — например, чтобы команда подтвердила окно
+// технических работ. Ответы неанонимного опроса приходят обновлениями poll_answer.
+func (c *TgClient) SendPoll(ctx context.Context, options PollOptions) (TgResponse, error) {
+	req, err := options.request()
+	if err != nil {
+		return TgResponse{}, err
+	}
+	return c.sendJSON(ctx, SendPoll, MessageOptions{
+		ChatID: options.ChatID,
+		Text:   options.Question,
+		UserID: options.UserID,
+		ID:     options.ID,
+	}, req)
+}
+
+// request проверяет опрос по ограничениям Bot API и собирает тело запроса.
+func (o PollOptions) request() (pollRequest, error) {
+	if n := len([]rune(o.Question)); n == 0 || n > MaxPollQuestion {
+		return pollRequest{}, fmt.Errorf("вопрос опроса должен содержать от 1 до %d символов", MaxPollQuestion)
+	}
+	if n := len(o.Options); n < MinPollOptions || n > MaxPollOptions {
+		return pollRequest{}, fmt.Errorf("в опросе должно быть от %d до %d вариантов, получено %d", MinPollOptions, MaxPollOptions, n)
+	}
+
+	req := pollRequest{
+		ChatID:                o.ChatID,
+		Question:              o.Question,
+		Options:               make([]pollOption, len(o.Options)),
+		IsAnonymous:           !o.Public,
+		Type:                  o.Type,
+		AllowsMultipleAnswers: o.AllowsMultipleAnswers,
+		Explanation:           o.Explanation,
+		BusinessConnectionID:  o.BusinessConnectionID,
+	}
+	for i, text := range o.Options {
+		if n := len([]rune(text)); n == 0 || n > MaxPollOption {
+			return pollRequest{}, fmt.Errorf("вариант %d должен содержать от 1 до %d символов", i, MaxPollOption)
+		}
+		req.Options[i] = pollOption{Text: text}
+	}
+
+	switch o.Type {
+	case "", PollRegular:
+		if o.Explanation != "" {
+			return pollRequest{}, fmt.Errorf("пояснение бывает только у викторины")
+		}
+	case PollQuiz:
+		if o.AllowsMultipleAnswers {
+			return pollRequest{}, fmt.Errorf("в викторине может быть только один верный ответ")
+		}
+		if o.CorrectOption < 0 || o.CorrectOption >= len(o.Options) {
+			return pollRequest{}, fmt.Errorf("индекс верного ответа %d вне списка вариантов", o.CorrectOption)
+		}
+		correct := o.CorrectOption
+		req.CorrectOptionID = &correct
+	default:
+		return pollRequest{}, fmt.Errorf("неизвестный тип опроса %q", o.Type)
+	}
+
+	if o.OpenPeriod > 0 && !o.CloseDate.IsZero() {
+		return pollRequest{}, fmt.Errorf("OpenPeriod и CloseDate нельзя задавать вместе")
+	}
+	if o.OpenPeriod > 0 {
+		if o.OpenPeriod < 5*time.Second || o.OpenPeriod > 600*time.Second {
+			return pollRequest{}, fmt.Errorf("опрос может быть открыт от 5 до 600 секунд")
+		}
+		req.OpenPeriod = int(o.OpenPeriod / time.Second)
+	}
+	if !o.CloseDate.IsZero() {
+		req.CloseDate = o.CloseDate.Unix()
+	}
+	return req, nil
+}
+
+// sendJSON сериализует params и отправляет их методом method с ожиданием лимитов, повтором после 429
+// и записью в журнал доставки по options.
+func (c *TgClient) sendJSON(ctx context.Context, method string, options MessageOptions, params any) (TgResponse, error) {
+	if !c.Enabled {
+		return TgResponse{}, fmt.Errorf("функционал Telegram отключён: некорректная конфигурация")
+	}
+
+	data, err := json.Marshal(params)
+	if err != nil {
+		return TgResponse{}, err
+	}
+	return c.sendPayload(ctx, method, options, func() (io.Reader, int64) {
+		return bytes.NewReader(data), int64(len(data))
+	})
+}
@@ -0,0 +1,49 @@
+package telegram
+
+import (
+	"strings"
+
+	"github.com/epheer/notephee/notify"
+)
+
+// InlineKeyboardMarkup — кнопки под сообщением.
+type InlineKeyboardMarkup struct {
+	InlineKeyboard [][]InlineKeyboardButton `json:"inline_keyboard"` // Ряды кнопок
+}
+
+// InlineKeyboardButton — кнопка под сообщением: открывает ссылку или присылает боту callback_data.
+type InlineKeyboardButton struct {
+	Text         string `json:"text"`                    // Текст кнопки
+	URL          string `json:"url,omitempty"`           // Ссылка
+	CallbackData string `json:"callback_data,omitempty"` // Данные для HandleCallback, до 64 байт
+}
+
+// RenderNotification отображает уведомление сообщением в режиме HTML: значок важности и заголовок
+// жирным, текст, поля Metadata и действия кнопками по одной в ряд. ChatID и поля журнала доставки
+// заполняет вызывающий.
+func RenderNotification(n notify.Notification) MessageOptions {
+	var b strings.Builder
+	b.WriteString(n.Severity.Emoji())
+	if n.Title != "" {
+		b.WriteString(" <b>" + EscapeHTML(n.Title) + "</b>")
+	}
+	if n.Body != "" {
+		b.WriteString("\n\n" + EscapeHTML(n.Body))
+	}
+	for i, k := range n.MetadataKeys() {
+		if i == 0 {
+			b.WriteString("\n")
+		}
+		b.WriteString("\n<b>" + EscapeHTML(k) + ":</b> " + EscapeHTML(n.Metadata[k]))
+	}
+
+	options := MessageOptions{Text: b.String(), ParseMode: ParseModeHTML}
+	if len(n.Actions) > 0 {
+		markup := &InlineKeyboardMarkup{}
+		for _, a := range n.Actions {
+			markup.InlineKeyboard = append(markup.InlineKeyboard, []InlineKeyboardButton{{Text: a.Label, URL: a.URL}})
+		}
+		options.ReplyMarkup = markup
+	}
+	return options
+}
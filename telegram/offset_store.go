@@ -0,0 +1,104 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// OffsetStore хранит offset опроса getUpdates — update_id, следующий за последним обработанным
+// обновлением. Постоянное хранилище позволяет StartPolling продолжить после перезапуска с того же места:
+// уже обработанные обновления не приходят повторно, а полученные, но не обработанные — не теряются.
+type OffsetStore interface {
+	// Load возвращает сохранённый offset или 0, если его ещё нет.
+	Load(ctx context.Context) (int64, error)
+	// Save сохраняет offset.
+	Save(ctx context.Context, offset int64) error
+}
+
+// MemoryOffsetStore — OffsetStore в памяти процесса. Offset теряется при перезапуске.
+type MemoryOffsetStore struct {
+	mu     sync.Mutex
+	offset int64
+}
+
+// NewMemoryOffsetStore создаёт хранилище offset в памяти.
+func NewMemoryOffsetStore() *MemoryOffsetStore {
+	return &MemoryOffsetStore{}
+}
+
+// Load возвращает сохранённый offset.
+func (s *MemoryOffsetStore) Load(_ context.Context) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.offset, nil
+}
+
+// Save сохраняет offset.
+func (s *MemoryOffsetStore) Save(_ context.Context, offset int64) error {
+	s.mu.Lock()
+	s.offset = offset
+	s.mu.Unlock()
+	return nil
+}
+
+// FileOffsetStore — OffsetStore в файле. Запись атомарна (через временный файл и rename),
+// поэтому падение процесса посреди сохранения оставляет прежний offset, а не испорченный файл.
+type FileOffsetStore struct {
+	path string
+	mu   sync.Mutex
+}
+
+// NewFileOffsetStore создаёт хранилище offset в файле path. Каталог создаётся при необходимости.
+// Каждому боту нужен свой файл.
+func NewFileOffsetStore(path string) (*FileOffsetStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("не удалось создать каталог для offset %s: %w", path, err)
+	}
+	return &FileOffsetStore{path: path}, nil
+}
+
+// Load читает offset из файла. Отсутствующий файл означает offset 0.
+func (s *FileOffsetStore) Load(_ context.Context) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("не удалось прочитать offset: %w", err)
+	}
+	offset, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("некорректный offset в %s: %w", s.path, err)
+	}
+	return offset, nil
+}
+
+// Save атомарно записывает offset в файл.
+func (s *FileOffsetStore) Save(_ context.Context, offset int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("не удалось сохранить offset: %w", err)
+	}
+	if _, err := tmp.WriteString(strconv.FormatInt(offset, 10)); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("не удалось сохранить offset: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("не удалось сохранить offset: %w", err)
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
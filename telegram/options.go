@@ -0,0 +1,141 @@
+package telegram
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	"github.com/epheer/notephee/attachment"
+	"github.com/epheer/notephee/notify"
+)
+
+// Options — параметры клиента для приложений со своей системой конфигурации; токен передаётся в NewTgClient.
+// Пустые поля означают значения по умолчанию.
+type Options struct {
+	BotName        string        // Имя бота без @ для инвайт-ссылок (необязательно)
+	APIURL         string        // Адрес Bot API (пусто — DefaultAPIURL)
+	Proxy          string        // Прокси: http://, https:// или socks5:// (необязательно)
+	RequestTimeout time.Duration // Предел одного запроса (0 — DefaultRequestTimeout)
+	PollTimeout    time.Duration // Ожидание обновлений в getUpdates (0 — DefaultPollTimeout)
+}
+
+// Option настраивает TgClient при создании через NewTgClient.
+type Option func(*TgClient) error
+
+// WithLogger задаёт логгер клиента (по умолчанию slog.Default()).
+func WithLogger(logger *slog.Logger) Option {
+	return func(c *TgClient) error {
+		c.logger = logger
+		return nil
+	}
+}
+
+// WithBotName задаёт имя бота без @. Нужно для инвайт-ссылок вида https://t.me/<bot>?start=<код>.
+func WithBotName(name string) Option {
+	return func(c *TgClient) error {
+		c.name = name
+		return nil
+	}
+}
+
+// WithHTTPClient задаёт HTTP-клиент для запросов к Bot API (см. SetHTTPClient).
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *TgClient) error {
+		if hc == nil {
+			return fmt.Errorf("HTTP-клиент не задан")
+		}
+		c.SetHTTPClient(hc)
+		return nil
+	}
+}
+
+// WithTimeouts задаёт таймауты запроса и долгого опроса (см. SetTimeouts).
+func WithTimeouts(request, poll time.Duration) Option {
+	return func(c *TgClient) error {
+		c.SetTimeouts(request, poll)
+		return nil
+	}
+}
+
+// WithUpdatesOptions задаёт параметры запроса getUpdates (см. SetUpdatesOptions).
+func WithUpdatesOptions(o UpdatesOptions) Option {
+	return func(c *TgClient) error {
+		return c.SetUpdatesOptions(o)
+	}
+}
+
+// WithBaseURL задаёт адрес Bot API вместо DefaultAPIURL (см. SetAPIURL).
+func WithBaseURL(base string) Option {
+	return func(c *TgClient) error {
+		return c.SetAPIURL(base)
+	}
+}
+
+// WithOptions применяет параметры o:
+//
+//	tg, err := telegram.NewTgClient(appCfg.BotToken, telegram.WithOptions(telegram.Options{BotName: appCfg.BotName}))
+//
+// Возвращает ошибку, если адрес Bot API или прокси некорректны.
+func WithOptions(o Options) Option {
+	return func(c *TgClient) error {
+		if o.BotName != "" {
+			c.name = o.BotName
+		}
+		c.SetTimeouts(o.RequestTimeout, o.PollTimeout)
+		if o.APIURL != "" {
+			if err := c.SetAPIURL(o.APIURL); err != nil {
+				return err
+			}
+		}
+		if o.Proxy != "" {
+			return c.SetProxy(o.Proxy)
+		}
+		return nil
+	}
+}
+
+// WithProxy направляет запросы к Bot API через прокси (см. SetProxy).
+func WithProxy(rawURL string) Option {
+	return func(c *TgClient) error {
+		return c.SetProxy(rawURL)
+	}
+}
+
+// WithRateLimit задаёт встроенный общий лимит отправок: limit в секунду с всплеском burst
+// (по умолчанию 30 в секунду, предел Telegram для бота).
+func WithRateLimit(limit rate.Limit, burst int) Option {
+	return func(c *TgClient) error {
+		if limit <= 0 || burst <= 0 {
+			return fmt.Errorf("лимит и всплеск отправок должны быть положительными")
+		}
+		c.rate = rate.NewLimiter(limit, burst)
+		return nil
+	}
+}
+
+// WithAttachmentPolicy задаёт предел размера файлов и действие для больших (см. SetAttachmentPolicy).
+func WithAttachmentPolicy(p attachment.Policy) Option {
+	return func(c *TgClient) error {
+		c.SetAttachmentPolicy(p)
+		return nil
+	}
+}
+
+// WithLimiter заменяет встроенный лимит внешним, например распределённым (см. SetLimiter).
+func WithLimiter(l notify.Limiter) Option {
+	return func(c *TgClient) error {
+		c.SetLimiter(l)
+		return nil
+	}
+}
+
+// WithRetry задаёт число повторов отправки после ответа 429 (см. SetFloodRetries).
+func WithRetry(n int) Option {
+	return func(c *TgClient) error {
+		c.SetFloodRetries(n)
+		return nil
+	}
+}
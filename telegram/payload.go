@@ -0,0 +1,81 @@
+package telegram
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"sync"
+)
+
+// chatIDPrefix — начало тела sendMessage, которое даёт json.Marshal для MessageOptions с нулевым chat_id.
+const chatIDPrefix = `{"chat_id":0`
+
+// maxPooledPayload — буферы больше этого размера не возвращаются в пул, чтобы не держать память
+// после рассылки с необычно длинным текстом.
+const maxPooledPayload = 64 << 10
+
+// errUnexpectedLayout возвращается, если json.Marshal дал тело не с chat_id в начале.
+var errUnexpectedLayout = errors.New("неожиданный формат JSON sendMessage")
+
+var payloadPool = sync.Pool{
+	New: func() any {
+		b := make([]byte, 0, 1024)
+		return &b
+	},
+}
+
+// bulkPayload — заранее сериализованное тело sendMessage для массовой отправки.
+//
+// Текст сериализуется один раз, а для каждого получателя в буфер из пула подставляется
+// только chat_id — без повторного json.Marshal и лишней нагрузки на GC.
+type bulkPayload struct {
+	suffix []byte // Всё, что идёт после значения chat_id
+}
+
+// newBulkPayload сериализует общее для всех получателей сообщение для последующей подстановки chat_id.
+// options.ChatID игнорируется.
+func newBulkPayload(options MessageOptions) (*bulkPayload, error) {
+	options.ChatID = 0
+	data, err := json.Marshal(options)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.HasPrefix(data, []byte(chatIDPrefix)) {
+		// Порядок полей MessageOptions изменился — шаблон собрать нельзя
+		return nil, errUnexpectedLayout
+	}
+	return &bulkPayload{suffix: data[len(chatIDPrefix):]}, nil
+}
+
+// build собирает тело запроса для chatID в буфере из пула.
+func (p *bulkPayload) build(chatID int64) *pooledBody {
+	bp := payloadPool.Get().(*[]byte)
+	b := append((*bp)[:0], `{"chat_id":`...)
+	b = strconv.AppendInt(b, chatID, 10)
+	b = append(b, p.suffix...)
+	*bp = b
+
+	body := &pooledBody{buf: bp}
+	body.Reset(b)
+	return body
+}
+
+// pooledBody — тело запроса в буфере из пула. Буфер возвращается в пул в Close,
+// который HTTP-транспорт вызывает, когда тело больше не нужно, — даже если это
+// происходит уже после возврата из http.Client.Do.
+type pooledBody struct {
+	bytes.Reader
+	buf  *[]byte
+	once sync.Once
+}
+
+// Close возвращает буфер в пул.
+func (b *pooledBody) Close() error {
+	b.once.Do(func() {
+		if cap(*b.buf) <= maxPooledPayload {
+			payloadPool.Put(b.buf)
+		}
+	})
+	return nil
+}
@@ -0,0 +1,350 @@
+package telegram
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"runtime/debug"
+	"time"
+
+	"github.com/epheer/notephee/alert"
+)
+
+// DefaultPollingLockTTL — срок блокировки опроса, если в SetPollingLock не задан другой.
+// Блокировка продлевается перед каждым запросом getUpdates, поэтому срок должен быть больше
+// длительности одного запроса.
+const DefaultPollingLockTTL = time.Minute
+
+// maxConflictBackoff — предел паузы между запросами getUpdates при повторяющихся ответах 409.
+const maxConflictBackoff = time.Minute
+
+// DefaultPollBackoff — предел паузы между повторами getUpdates после ошибок сети или API,
+// если в UpdatesOptions не задан другой.
+const DefaultPollBackoff = time.Minute
+
+// minPollBackoff — пауза после первой ошибки getUpdates.
+const minPollBackoff = time.Second
+
+// ErrPollingConflict означает, что Telegram отклонил getUpdates с кодом 409: тем же токеном
+// уже опрашивает другой экземпляр или у бота установлен вебхук.
+var ErrPollingConflict = errors.New("конфликт опроса getUpdates")
+
+// ErrUnauthorized означает, что Telegram не принял токен бота в getUpdates (401 или 404): токен отозван
+// в @BotFather или указан с ошибкой. Повтор с тем же токеном не поможет, поэтому опрос останавливается.
+var ErrUnauthorized = errors.New("токен бота не принят Telegram")
+
+// PanicError — паника в обработчике обновления, перехваченная опросом. Опрос после неё продолжается
+// со следующего обновления.
+type PanicError struct {
+	UpdateID int64  // Обновление, при обработке которого случилась паника
+	Value    any    // Значение, переданное в panic
+	Stack    []byte // Стек горутины в момент паники
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("паника при обработке обновления %d: %v", e.UpdateID, e.Value)
+}
+
+// ConflictError — ответ 409 на getUpdates. errors.Is(err, ErrPollingConflict) для неё истинно.
+type ConflictError struct {
+	Description string // Описание от Telegram
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("%v: %s", ErrPollingConflict, e.Description)
+}
+
+// Is сопоставляет ошибку с ErrPollingConflict.
+func (e *ConflictError) Is(target error) bool {
+	return target == ErrPollingConflict
+}
+
+// PollingLock — распределённая блокировка (например, на Redis или в базе), с которой getUpdates
+// одного бота опрашивает только один экземпляр сервиса. Каждый экземпляр использует свой PollingLock,
+// отличающий его от остальных.
+type PollingLock interface {
+	// TryLock пытается захватить блокировку на ttl. Возвращает false, если она занята другим экземпляром.
+	TryLock(ctx context.Context, ttl time.Duration) (bool, error)
+	// Refresh продлевает захваченную блокировку на ttl. Ошибка означает, что блокировка потеряна.
+	Refresh(ctx context.Context, ttl time.Duration) error
+	// Unlock освобождает блокировку.
+	Unlock(ctx context.Context) error
+}
+
+// SetPollingLock включает выбор ведущего для StartPolling: опрашивает только экземпляр, захвативший lock,
+// а остальные ждут и перехватывают опрос, если ведущий остановится. ttl <= 0 заменяется DefaultPollingLockTTL.
+// Чтобы новый ведущий продолжил с того же места, экземплярам нужен общий OffsetStore.
+func (c *TgClient) SetPollingLock(lock PollingLock, ttl time.Duration) {
+	if ttl <= 0 {
+		ttl = DefaultPollingLockTTL
+	}
+	c.pollLock = lock
+	c.pollLockTTL = ttl
+}
+
+// SetConflictHandler задаёт обработчик ответов 409 на getUpdates. Он получает *ConflictError
+// и вызывается на каждый такой ответ; опрос при этом продолжается с нарастающей паузой до минуты.
+func (c *TgClient) SetConflictHandler(fn func(error)) {
+	c.onConflict = fn
+}
+
+// SetPollingErrorHandler задаёт обработчик ошибок опроса: ошибок getUpdates (кроме 409, см. SetConflictHandler),
+// ошибок обработчиков обновлений, перехваченных паник (*PanicError) и ErrUnauthorized, после которой опрос
+// останавливается. Вызывается в горутине опроса, поэтому долгий обработчик задерживает опрос.
+func (c *TgClient) SetPollingErrorHandler(fn func(error)) {
+	c.onPollError = fn
+}
+
+// pollingError передаёт ошибку опроса обработчику из SetPollingErrorHandler.
+func (c *TgClient) pollingError(err error) {
+	if c.onPollError != nil {
+		c.onPollError(err)
+	}
+}
+
+// leadPolling захватывает блокировку опроса и опрашивает, пока она удерживается, до завершения ctx.
+func (c *TgClient) leadPolling(ctx context.Context, bm *BindingManager, callback func(Binding)) {
+	for ctx.Err() == nil {
+		ok, err := c.pollLock.TryLock(ctx, c.pollLockTTL)
+		if err != nil && ctx.Err() == nil {
+			c.logger.Error("не удалось захватить блокировку опроса", "error", err)
+		}
+		if !ok || err != nil {
+			_ = sleep(ctx, c.pollLockTTL/4)
+			continue
+		}
+
+		c.logger.Info("блокировка опроса захвачена, экземпляр опрашивает getUpdates")
+		err = c.poll(ctx, bm, callback)
+		if err := c.pollLock.Unlock(context.WithoutCancel(ctx)); err != nil {
+			c.logger.Warn("не удалось освободить блокировку опроса", "error", err)
+		}
+		if errors.Is(err, ErrUnauthorized) {
+			return
+		}
+	}
+}
+
+// poll опрашивает getUpdates до завершения ctx или потери блокировки опроса. Возвращает ErrUnauthorized,
+// если опрос остановлен из-за токена.
+func (c *TgClient) poll(ctx context.Context, bm *BindingManager, callback func(Binding)) error {
+	// Offset загружается при каждом захвате блокировки: предыдущий ведущий мог его продвинуть
+	offset, err := c.offsets.Load(ctx)
+	if err != nil {
+		c.logger.Error("не удалось загрузить offset опроса, опрос начнётся с неподтверждённых обновлений", "error", err)
+	}
+
+	handle := c.updateChain(bm, callback)
+	conflicts, failures := 0, 0
+	defer c.polling.Store(false)
+	for ctx.Err() == nil {
+		if c.pollLock != nil {
+			if err := c.pollLock.Refresh(ctx, c.pollLockTTL); err != nil {
+				if ctx.Err() == nil {
+					c.logger.Warn("блокировка опроса потеряна, опрос приостановлен", "error", err)
+				}
+				return nil
+			}
+		}
+
+		updates, err := c.getUpdates(ctx, offset)
+		c.polling.Store(err == nil)
+		var conflict *ConflictError
+		switch {
+		case errors.As(err, &conflict):
+			if conflicts == 0 {
+				c.logger.Warn("Telegram отклонил getUpdates: бот уже опрашивается", "description", conflict.Description)
+			}
+			conflicts++
+			if c.onConflict != nil {
+				c.onConflict(conflict)
+			}
+			_ = sleep(ctx, conflictBackoff(conflicts))
+			continue
+		case errors.Is(err, ErrUnauthorized):
+			c.logger.Error("опрос getUpdates остановлен: Telegram не принял токен бота", "error", err)
+			alert.Alert(alert.Critical, "Опрос обновлений Telegram остановлен: токен бота отозван или неверен. Бот не получает сообщения, пока токен не будет заменён.")
+			c.pollingError(err)
+			return err
+		case err != nil:
+			if ctx.Err() == nil {
+				failures++
+				wait := pollBackoff(failures, c.pollBackoff)
+				// Первая ошибка — Error, повторы — Warn, чтобы долгий сбой не засорял лог ошибками
+				level := slog.LevelWarn
+				if failures == 1 {
+					level = slog.LevelError
+				}
+				c.logger.Log(ctx, level, "Ошибка при запросе getUpdates", "failures", failures, "retry_in", wait, "error", err)
+				c.pollingError(err)
+				_ = sleep(ctx, wait)
+			}
+			continue
+		}
+		if conflicts > 0 {
+			c.logger.Info("конфликт опроса getUpdates разрешён", "conflicts", conflicts)
+			conflicts = 0
+		}
+		if failures > 0 {
+			c.logger.Info("опрос getUpdates восстановлен", "failures", failures)
+			failures = 0
+		}
+
+		for _, upd := range updates {
+			// Обновление считается обработанным и после паники: иначе оно повторялось бы бесконечно
+			if err := c.handleRecover(ctx, handle, upd); err != nil {
+				var p *PanicError
+				if errors.As(err, &p) {
+					c.logger.Error("паника при обработке обновления", "update_id", upd.UpdateID, "panic", p.Value, "stack", string(p.Stack))
+				} else {
+					c.logger.Warn("ошибка обработки обновления", "update_id", upd.UpdateID, "error", err)
+				}
+				c.pollingError(err)
+			}
+
+			offset = upd.UpdateID + 1
+			if err := c.offsets.Save(ctx, offset); err != nil {
+				c.logger.Error("не удалось сохранить offset опроса", "offset", offset, "error", err)
+			}
+		}
+	}
+	return nil
+}
+
+// UpdatesOptions — параметры запроса getUpdates для StartPolling.
+type UpdatesOptions struct {
+	// AllowedUpdates — типы обновлений, которые присылает Telegram (UpdateMessage, UpdateCallbackQuery и т.д.).
+	// Остальные не передаются и не разбираются. Telegram запоминает список до следующего запроса с ним,
+	// поэтому nil оставляет прежний, а пустой срез (не nil) возвращает все типы, кроме chat_member и реакций.
+	// Привязке чатов через /start нужен UpdateMessage.
+	AllowedUpdates []string
+	// Limit — обновлений за запрос, от 1 до 100; 0 — 100.
+	Limit int
+	// Timeout — сколько запрос ждёт новых обновлений, с точностью до секунды; 0 — прежнее значение
+	// (по умолчанию DefaultPollTimeout, см. SetTimeouts).
+	Timeout time.Duration
+	// MaxBackoff — предел паузы между повторами после ошибок getUpdates: пауза растёт от секунды вдвое
+	// с каждой ошибкой подряд. 0 — прежнее значение (по умолчанию DefaultPollBackoff).
+	MaxBackoff time.Duration
+}
+
+// SetUpdatesOptions задаёт параметры запроса getUpdates. Вызывается при настройке, до StartPolling.
+func (c *TgClient) SetUpdatesOptions(o UpdatesOptions) error {
+	if o.Limit < 0 || o.Limit > 100 {
+		return fmt.Errorf("лимит обновлений за запрос должен быть от 1 до 100")
+	}
+	if o.Timeout < 0 || o.MaxBackoff < 0 {
+		return fmt.Errorf("время ожидания и паузы опроса не могут быть отрицательными")
+	}
+	if o.MaxBackoff > 0 && o.MaxBackoff < minPollBackoff {
+		return fmt.Errorf("предел паузы опроса должен быть не меньше %v", minPollBackoff)
+	}
+	if o.AllowedUpdates != nil {
+		c.allowedUpdates = append([]string{}, o.AllowedUpdates...)
+	}
+	c.pollLimit = o.Limit
+	if o.Timeout > 0 {
+		c.pollTimeout = o.Timeout
+	}
+	if o.MaxBackoff > 0 {
+		c.pollBackoff = o.MaxBackoff
+	}
+	return nil
+}
+
+// handleRecover обрабатывает обновление и превращает панику обработчика в *PanicError.
+func (c *TgClient) handleRecover(ctx context.Context, handle UpdateHandler, upd Update) (err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			err = &PanicError{UpdateID: upd.UpdateID, Value: v, Stack: debug.Stack()}
+		}
+	}()
+	return handle(ctx, upd)
+}
+
+// getUpdatesRequest — тело запроса getUpdates.
+type getUpdatesRequest struct {
+	Offset         int64     `json:"offset"`
+	Timeout        int       `json:"timeout"`
+	Limit          int       `json:"limit,omitempty"`
+	AllowedUpdates *[]string `json:"allowed_updates,omitempty"` // Пустой список отличается от отсутствующего
+}
+
+// getUpdates запрашивает обновления начиная с offset.
+func (c *TgClient) getUpdates(ctx context.Context, offset int64) ([]Update, error) {
+	// Запрос держится открытым до pollTimeout, поэтому предел запроса к нему добавляется, а не заменяет его
+	ctx, cancel := context.WithTimeout(ctx, c.pollTimeout+c.requestTimeout)
+	defer cancel()
+
+	params := getUpdatesRequest{Offset: offset, Timeout: int(c.pollTimeout / time.Second), Limit: c.pollLimit}
+	if c.allowedUpdates != nil {
+		params.AllowedUpdates = &c.allowedUpdates
+	}
+	body, err := json.Marshal(params)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.tg("/getUpdates"), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	var updates UpdatesResponse
+	if err := json.NewDecoder(resp.Body).Decode(&updates); err != nil && !unauthorized(resp.StatusCode) {
+		return nil, fmt.Errorf("ошибка декодирования ответа: %w", err)
+	}
+	if unauthorized(resp.StatusCode) || unauthorized(updates.ErrorCode) {
+		return nil, fmt.Errorf("%w: %s", ErrUnauthorized, updates.Description)
+	}
+	if resp.StatusCode == http.StatusConflict || updates.ErrorCode == http.StatusConflict {
+		return nil, &ConflictError{Description: updates.Description}
+	}
+	if !updates.OK {
+		return nil, fmt.Errorf("ошибка Telegram Bot Api: Код ошибки %d: %s", updates.ErrorCode, updates.Description)
+	}
+	return updates.Result, nil
+}
+
+// pollBackoff возвращает паузу после n-й подряд ошибки getUpdates: 1s, 2s, 4s… до limit, со случайным
+// разбросом в пределах второй половины паузы, чтобы экземпляры после общего сбоя не повторяли запросы разом.
+func pollBackoff(n int, limit time.Duration) time.Duration {
+	d := minPollBackoff
+	for i := 1; i < n && d < limit; i++ {
+		d *= 2
+	}
+	d = min(d, limit)
+	return d/2 + rand.N(d/2+1)
+}
+
+// Polling сообщает, опрашивает ли клиент getUpdates сейчас: StartPolling запущен, а последний запрос
+// getUpdates успешен. После ошибок сети или API, ответа 409 и остановки опроса — false; экземпляр,
+// ожидающий блокировку опроса (SetPollingLock), тоже не опрашивает. Подходит для проверок
+// состояния сервиса извне.
+func (c *TgClient) Polling() bool {
+	return c.polling.Load()
+}
+
+// unauthorized сообщает, что код ответа Bot API означает недействительный токен.
+func unauthorized(code int) bool {
+	return code == http.StatusUnauthorized || code == http.StatusNotFound
+}
+
+// conflictBackoff возвращает паузу после n-го подряд ответа 409: 2s, 4s, 8s… до maxConflictBackoff.
+func conflictBackoff(n int) time.Duration {
+	d := 2 * time.Second
+	for i := 1; i < n && d < maxConflictBackoff; i++ {
+		d *= 2
+	}
+	return min(d, maxConflictBackoff)
+}
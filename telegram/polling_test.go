@@ -0,0 +1,303 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPollingConflict(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusConflict)
+		_, _ = w.Write([]byte(`{"ok":false,"error_code":409,"description":"Conflict: terminated by other getUpdates request"}`))
+	})
+	bm := c.NewBindingManager(time.Minute, c.logger)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	got := make(chan error, 1)
+	c.SetConflictHandler(func(err error) {
+		select {
+		case got <- err:
+		default:
+		}
+		cancel()
+	})
+
+	done := make(chan struct{})
+	go func() {
+		c.StartPolling(ctx, bm, func(Binding) {})
+		close(done)
+	}()
+
+	select {
+	case err := <-got:
+		var conflict *ConflictError
+		if !errors.Is(err, ErrPollingConflict) || !errors.As(err, &conflict) || conflict.Description == "" {
+			t.Fatalf("ожидалась ConflictError, получено %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("обработчик конфликта не вызван")
+	}
+	<-done
+
+	if conflictBackoff(1) != 2*time.Second || conflictBackoff(3) != 8*time.Second || conflictBackoff(20) != time.Minute {
+		t.Fatal("неверная пауза после конфликтов")
+	}
+}
+
+// sharedLock — блокировка в памяти, общая для нескольких экземпляров; owner отличает экземпляр.
+type sharedLock struct {
+	mu     *sync.Mutex
+	holder *string
+	owner  string
+}
+
+func (l sharedLock) TryLock(context.Context, time.Duration) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if *l.holder != "" && *l.holder != l.owner {
+		return false, nil
+	}
+	*l.holder = l.owner
+	return true, nil
+}
+
+func (l sharedLock) Refresh(context.Context, time.Duration) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if *l.holder != l.owner {
+		return errors.New("блокировка занята")
+	}
+	return nil
+}
+
+func (l sharedLock) Unlock(context.Context) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if *l.holder == l.owner {
+		*l.holder = ""
+	}
+	return nil
+}
+
+func TestPollingLockSingleLeader(t *testing.T) {
+	var polls [2]atomic.Int32
+	var mu sync.Mutex
+	holder := ""
+
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	var wg sync.WaitGroup
+	for i := range 2 {
+		c := newTestClient(t, func(w http.ResponseWriter, _ *http.Request) {
+			polls[i].Add(1)
+			time.Sleep(10 * time.Millisecond)
+			_, _ = w.Write([]byte(`{"ok":true,"result":[]}`))
+		})
+		c.SetPollingLock(sharedLock{mu: &mu, holder: &holder, owner: string(rune('a' + i))}, 100*time.Millisecond)
+		bm := c.NewBindingManager(time.Minute, c.logger)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.StartPolling(ctx, bm, func(Binding) {})
+		}()
+	}
+	wg.Wait()
+
+	a, b := polls[0].Load(), polls[1].Load()
+	if (a == 0) == (b == 0) {
+		t.Fatalf("getUpdates должен опрашивать ровно один экземпляр: %d и %d запросов", a, b)
+	}
+	if holder != "" {
+		t.Fatalf("блокировка должна освобождаться при остановке, держит %q", holder)
+	}
+}
+
+func TestUpdatesOptions(t *testing.T) {
+	var bodies []string
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("getUpdates должен отправляться POST с JSON, получено %s %q", r.Method, r.Header.Get("Content-Type"))
+		}
+		body, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+		_, _ = w.Write([]byte(`{"ok":true,"result":[]}`))
+	})
+
+	if _, err := c.getUpdates(context.Background(), 5); err != nil {
+		t.Fatalf("Ошибка getUpdates: %v", err)
+	}
+	err := c.SetUpdatesOptions(UpdatesOptions{
+		AllowedUpdates: []string{UpdateMessage, UpdateCallbackQuery}, Limit: 20, Timeout: 10 * time.Second,
+	})
+	if err != nil {
+		t.Fatalf("Ошибка SetUpdatesOptions: %v", err)
+	}
+	if _, err := c.getUpdates(context.Background(), 6); err != nil {
+		t.Fatalf("Ошибка getUpdates: %v", err)
+	}
+	// Пустой список — все типы обновлений, он передаётся явно
+	_ = c.SetUpdatesOptions(UpdatesOptions{AllowedUpdates: []string{}})
+	if _, err := c.getUpdates(context.Background(), 7); err != nil {
+		t.Fatalf("Ошибка getUpdates: %v", err)
+	}
+
+	want := []string{
+		fmt.Sprintf(`{"offset":5,"timeout":%d}`, int(DefaultPollTimeout/time.Second)),
+		`{"offset":6,"timeout":10,"limit":20,"allowed_updates":["message","callback_query"]}`,
+		`{"offset":7,"timeout":10,"allowed_updates":[]}`,
+	}
+	for i := range want {
+		if strings.TrimSpace(bodies[i]) != want[i] {
+			t.Errorf("запрос %d: ожидалось %s, получено %s", i, want[i], bodies[i])
+		}
+	}
+
+	if err := c.SetUpdatesOptions(UpdatesOptions{Limit: 101}); err == nil {
+		t.Fatal("лимит больше 100 должен отклоняться")
+	}
+}
+
+func TestPollBackoff(t *testing.T) {
+	for n, want := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 4: 8 * time.Second, 20: time.Minute} {
+		for range 20 {
+			if got := pollBackoff(n, time.Minute); got < want/2 || got > want {
+				t.Fatalf("пауза после %d ошибок: ожидалось от %v до %v, получено %v", n, want/2, want, got)
+			}
+		}
+	}
+}
+
+func TestPollingHealth(t *testing.T) {
+	var requests atomic.Int32
+	second := make(chan bool, 1)
+	var c *TgClient
+	c = newTestClient(t, func(w http.ResponseWriter, _ *http.Request) {
+		// Первый запрос успешен, остальные — ошибка сервера
+		if requests.Add(1) == 1 {
+			_, _ = w.Write([]byte(`{"ok":true,"result":[]}`))
+			return
+		}
+		select {
+		case second <- c.Polling():
+		default:
+		}
+		w.WriteHeader(http.StatusBadGateway)
+		_, _ = w.Write([]byte(`{"ok":false,"error_code":502,"description":"Bad Gateway"}`))
+	})
+	bm := c.NewBindingManager(time.Minute, c.logger)
+	if c.Polling() {
+		t.Fatal("до запуска опроса Polling должен быть false")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		c.StartPolling(ctx, bm, nil)
+		close(done)
+	}()
+
+	select {
+	case polling := <-second:
+		if !polling {
+			t.Fatal("после успешного getUpdates Polling должен быть true")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("опрос не выполнил второй запрос")
+	}
+	// Пауза после первой ошибки — не меньше половины секунды, поэтому запросы не идут подряд
+	time.Sleep(200 * time.Millisecond)
+	if c.Polling() {
+		t.Fatal("после ошибки getUpdates Polling должен быть false")
+	}
+	if n := requests.Load(); n != 2 {
+		t.Fatalf("после ошибки опрос должен ждать, получено %d запросов", n)
+	}
+	cancel()
+	<-done
+}
+
+func TestPollingRecoversPanic(t *testing.T) {
+	var requests atomic.Int32
+	c := newTestClient(t, func(w http.ResponseWriter, _ *http.Request) {
+		if requests.Add(1) == 1 {
+			_, _ = w.Write([]byte(`{"ok":true,"result":[{"update_id":1,"message":{"text":"a","chat":{"id":1}}},{"update_id":2,"message":{"text":"b","chat":{"id":1}}}]}`))
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+		_, _ = w.Write([]byte(`{"ok":true,"result":[]}`))
+	})
+	bm := c.NewBindingManager(time.Minute, c.logger)
+
+	handled := make(chan int64, 2)
+	c.AddUpdateHandler(func(_ context.Context, upd Update) error {
+		if upd.UpdateID == 1 {
+			panic("сбой обработчика")
+		}
+		handled <- upd.UpdateID
+		return nil
+	})
+	errs := make(chan error, 1)
+	c.SetPollingErrorHandler(func(err error) { errs <- err })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		c.StartPolling(ctx, bm, nil)
+		close(done)
+	}()
+
+	select {
+	case err := <-errs:
+		var p *PanicError
+		if !errors.As(err, &p) || p.UpdateID != 1 || p.Value != "сбой обработчика" || len(p.Stack) == 0 {
+			t.Fatalf("ожидалась PanicError обновления 1, получено %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("обработчик ошибок не вызван")
+	}
+	select {
+	case id := <-handled:
+		if id != 2 {
+			t.Fatalf("ожидалось обновление 2, получено %d", id)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("после паники опрос должен продолжаться")
+	}
+	cancel()
+	<-done
+}
+
+func TestPollingStopsOnUnauthorized(t *testing.T) {
+	var requests atomic.Int32
+	c := newTestClient(t, func(w http.ResponseWriter, _ *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusUnauthorized)
+		_, _ = w.Write([]byte(`{"ok":false,"error_code":401,"description":"Unauthorized"}`))
+	})
+	bm := c.NewBindingManager(time.Minute, c.logger)
+	var got error
+	c.SetPollingErrorHandler(func(err error) { got = err })
+
+	done := make(chan struct{})
+	go func() {
+		c.StartPolling(context.Background(), bm, nil)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("опрос с отклонённым токеном должен остановиться")
+	}
+	if !errors.Is(got, ErrUnauthorized) || requests.Load() != 1 || c.Polling() {
+		t.Fatalf("ожидалась ErrUnauthorized после одного запроса, получено %v и %d запросов", got, requests.Load())
+	}
+}
@@ -0,0 +1,100 @@
+package qrcode
+
+import (
+	"bytes"
+	"fmt"
+
+	qr "github.com/skip2/go-qrcode"
+)
+
+// Format — формат изображения QR-кода.
+type Format string
+
+// Поддерживаемые форматы
+const (
+	PNG Format = "png"
+	SVG Format = "svg"
+)
+
+// Level — уровень коррекции ошибок QR-кода.
+//
+// Чем выше уровень, тем больше повреждений код переносит, но тем он плотнее.
+type Level int
+
+// Уровни коррекции ошибок
+const (
+	Low     Level = iota // ~7% восстанавливаемых данных
+	Medium               // ~15%
+	High                 // ~25%
+	Highest              // ~30%
+)
+
+// Options задаёт параметры отрисовки.
+type Options struct {
+	Format   Format // Формат изображения (по умолчанию PNG)
+	Size     int    // Ширина и высота изображения в пикселях (по умолчанию 256)
+	Level    Level  // Уровень коррекции ошибок (по умолчанию Low)
+	NoBorder bool   // Не добавлять белое поле вокруг кода
+}
+
+// Render рисует QR-код для ссылки-инвайта (или любой другой строки) и возвращает байты изображения.
+func Render(link string, opts Options) ([]byte, error) {
+	if link == "" {
+		return nil, fmt.Errorf("пустая ссылка")
+	}
+	if opts.Size <= 0 {
+		opts.Size = 256
+	}
+
+	code, err := qr.New(link, recovery(opts.Level))
+	if err != nil {
+		return nil, fmt.Errorf("не удалось построить QR-код: %w", err)
+	}
+	code.DisableBorder = opts.NoBorder
+
+	switch opts.Format {
+	case PNG, "":
+		return code.PNG(opts.Size)
+	case SVG:
+		return renderSVG(code.Bitmap(), opts.Size), nil
+	default:
+		return nil, fmt.Errorf("неподдерживаемый формат QR-кода: %s", opts.Format)
+	}
+}
+
+func recovery(l Level) qr.RecoveryLevel {
+	switch l {
+	case Medium:
+		return qr.Medium
+	case High:
+		return qr.High
+	case Highest:
+		return qr.Highest
+	default:
+		return qr.Low
+	}
+}
+
+// renderSVG рисует матрицу модулей одним path, масштабируя её до size пикселей через viewBox.
+func renderSVG(bitmap [][]bool, size int) []byte {
+	n := len(bitmap)
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`, size, size, n, n)
+	fmt.Fprintf(&buf, `<rect width="%d" height="%d" fill="#fff"/><path fill="#000" d="`, n, n)
+	for y, row := range bitmap {
+		for x := 0; x < len(row); x++ {
+			if !row[x] {
+				continue
+			}
+			// Склеиваем соседние тёмные модули строки в один прямоугольник
+			start := x
+			for x < len(row) && row[x] {
+				x++
+			}
+			fmt.Fprintf(&buf, "M%d %dh%dv1h-%dz", start, y, x-start, x-start)
+		}
+	}
+	buf.WriteString(`"/></svg>`)
+	return buf.Bytes()
+}
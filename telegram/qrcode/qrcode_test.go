@@ -0,0 +1,32 @@
+package qrcode_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/epheer/notephee/telegram/qrcode"
+)
+
+func TestRender(t *testing.T) {
+	link := "https://t.me/notephee_bot?start=0b5f1c3e-7a8d-4c2b-9e41-2f6d8a9b0c1d"
+
+	png, err := qrcode.Render(link, qrcode.Options{Size: 128, Level: qrcode.High})
+	if err != nil {
+		t.Fatalf("Ошибка Render PNG: %v", err)
+	}
+	if !bytes.HasPrefix(png, []byte("\x89PNG")) {
+		t.Fatal("результат не является PNG")
+	}
+
+	svg, err := qrcode.Render(link, qrcode.Options{Format: qrcode.SVG, Size: 128})
+	if err != nil {
+		t.Fatalf("Ошибка Render SVG: %v", err)
+	}
+	if !bytes.HasPrefix(svg, []byte("<svg")) || !bytes.Contains(svg, []byte(`width="128"`)) {
+		t.Fatalf("некорректный SVG: %.80s", svg)
+	}
+
+	if _, err := qrcode.Render(link, qrcode.Options{Format: "gif"}); err == nil {
+		t.Fatal("неподдерживаемый формат должен давать ошибку")
+	}
+}
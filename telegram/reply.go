@@ -0,0 +1,70 @@
+package telegram
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"text/template"
+)
+
+// BindingReplies — автоответы, которые StartPolling отправляет в чат при обработке /start.
+// Каждый ответ — шаблон text/template с данными ReplyData; обычный текст тоже подходит.
+// Пустой шаблон отключает ответ.
+type BindingReplies struct {
+	Bound   string // После успешной привязки, например «Уведомления подключены»
+	Expired string // Если инвайт истёк, уже использован или не существует
+	Start   string // На /start без кода инвайта
+}
+
+// ReplyData — данные для шаблонов BindingReplies.
+type ReplyData struct {
+	UserID    string // Внутренний ID пользователя; заполнен только в Bound
+	ChatID    int64  // Идентификатор чата
+	Username  string // Имя пользователя Telegram без @ (может быть пустым)
+	FirstName string // Имя пользователя Telegram
+}
+
+// bindingReplies — разобранные шаблоны автоответов.
+type bindingReplies struct {
+	bound, expired, start *template.Template
+}
+
+// SetReplies задаёт автоответы на /start. Возвращает ошибку, если какой-либо шаблон не разбирается;
+// в этом случае прежние ответы не меняются. Вызывается до StartPolling.
+func (bm *BindingManager) SetReplies(r BindingReplies) error {
+	var parsed bindingReplies
+	for _, t := range []struct {
+		name, text string
+		dst        **template.Template
+	}{
+		{"bound", r.Bound, &parsed.bound},
+		{"expired", r.Expired, &parsed.expired},
+		{"start", r.Start, &parsed.start},
+	} {
+		if t.text == "" {
+			continue
+		}
+		tmpl, err := template.New(t.name).Option("missingkey=error").Parse(t.text)
+		if err != nil {
+			return fmt.Errorf("автоответ %s: %w", t.name, err)
+		}
+		*t.dst = tmpl
+	}
+	bm.replies = parsed
+	return nil
+}
+
+// reply отправляет автоответ tmpl в чат data.ChatID. Ошибки пишутся в лог: ответ не должен мешать привязке.
+func (c *TgClient) reply(ctx context.Context, tmpl *template.Template, data ReplyData) {
+	if tmpl == nil {
+		return
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		c.logger.Warn("не удалось собрать автоответ", "reply", tmpl.Name(), "chatID", data.ChatID, "error", err)
+		return
+	}
+	if _, err := c.sendText(ctx, MessageOptions{ChatID: data.ChatID, Text: buf.String(), UserID: data.UserID}); err != nil {
+		c.logger.Warn("не удалось отправить автоответ", "reply", tmpl.Name(), "chatID", data.ChatID, "error", err)
+	}
+}
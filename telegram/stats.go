@@ -0,0 +1,124 @@
+package telegram
+
+import (
+	"sort"
+	"sync"
+)
+
+// InviteStats — воронка инвайтов одной партии.
+type InviteStats struct {
+	Batch     string // Имя партии инвайтов
+	Created   int    // Выпущено инвайтов
+	Clicked   int    // Перешли по ссылке (в бот пришёл /start с кодом)
+	Completed int    // Привязка завершена (вызван callback)
+	Expired   int    // Истекли без завершения привязки
+}
+
+// ClickRate возвращает долю инвайтов, по которым перешли.
+func (s InviteStats) ClickRate() float64 {
+	if s.Created == 0 {
+		return 0
+	}
+	return float64(s.Clicked) / float64(s.Created)
+}
+
+// Conversion возвращает долю инвайтов, завершившихся привязкой.
+func (s InviteStats) Conversion() float64 {
+	if s.Created == 0 {
+		return 0
+	}
+	return float64(s.Completed) / float64(s.Created)
+}
+
+// inviteState отслеживает этап воронки для одного кода.
+type inviteState struct {
+	batch   string
+	clicked bool
+}
+
+// inviteTracker считает переходы и привязки по партиям инвайтов.
+type inviteTracker struct {
+	mu      sync.Mutex
+	batches map[string]*InviteStats
+	codes   map[string]*inviteState // Незавершённые коды
+}
+
+func newInviteTracker() *inviteTracker {
+	return &inviteTracker{
+		batches: make(map[string]*InviteStats),
+		codes:   make(map[string]*inviteState),
+	}
+}
+
+func (t *inviteTracker) batch(name string) *InviteStats {
+	s, ok := t.batches[name]
+	if !ok {
+		s = &InviteStats{Batch: name}
+		t.batches[name] = s
+	}
+	return s
+}
+
+func (t *inviteTracker) created(code, batch string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.codes[code] = &inviteState{batch: batch}
+	t.batch(batch).Created++
+}
+
+// clicked учитывает только первый переход по коду.
+func (t *inviteTracker) clicked(code string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	st, ok := t.codes[code]
+	if !ok || st.clicked {
+		return
+	}
+	st.clicked = true
+	t.batch(st.batch).Clicked++
+}
+
+func (t *inviteTracker) completed(code string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	st, ok := t.codes[code]
+	if !ok {
+		return
+	}
+	delete(t.codes, code)
+	t.batch(st.batch).Completed++
+}
+
+func (t *inviteTracker) expired(code string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	st, ok := t.codes[code]
+	if !ok {
+		return
+	}
+	delete(t.codes, code)
+	t.batch(st.batch).Expired++
+}
+
+// Stats возвращает воронку партии инвайтов batch.
+func (bm *BindingManager) Stats(batch string) InviteStats {
+	bm.tracker.mu.Lock()
+	defer bm.tracker.mu.Unlock()
+	if s, ok := bm.tracker.batches[batch]; ok {
+		return *s
+	}
+	return InviteStats{Batch: batch}
+}
+
+// AllStats возвращает воронки всех партий, отсортированные по имени.
+func (bm *BindingManager) AllStats() []InviteStats {
+	bm.tracker.mu.Lock()
+	defer bm.tracker.mu.Unlock()
+
+	out := make([]InviteStats, 0, len(bm.tracker.batches))
+	for _, s := range bm.tracker.batches {
+		out = append(out, *s)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Batch < out[j].Batch })
+	return out
+}
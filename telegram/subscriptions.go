@@ -0,0 +1,90 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/epheer/notephee/preferences"
+)
+
+// Topic — тема уведомлений, на которую можно подписаться командами бота. Name совпадает
+// с notify.Message.Category сообщений этой темы.
+type Topic struct {
+	Name  string // Имя темы: billing, security и т.д.
+	Title string // Описание для списка /topics (пусто — выводится только имя)
+}
+
+// SubscriptionCommands возвращает обработчик команд подписки для AddUpdateHandler:
+//
+//	/topics — список тем и состояние подписки чата;
+//	/subscribe <тема> — подписать чат на тему;
+//	/unsubscribe <тема> — отписать чат от темы.
+//
+// Решения сохраняются в policy для получателя — ID чата и канала telegram, поэтому preferences.Wrap
+// учитывает их для сообщений в этот чат. Принимаются только темы из topics.
+func (c *TgClient) SubscriptionCommands(policy *preferences.Policy, topics ...Topic) UpdateHandler {
+	known := make(map[string]bool, len(topics))
+	for _, t := range topics {
+		known[t.Name] = true
+	}
+
+	return func(ctx context.Context, upd Update) error {
+		if upd.Message == nil {
+			return nil
+		}
+		fields := strings.Fields(upd.Message.Text)
+		if len(fields) == 0 {
+			return nil
+		}
+		// В группах команда приходит с именем бота: /subscribe@notephee_bot billing
+		cmd, _, _ := strings.Cut(fields[0], "@")
+		chatID := upd.Message.Chat.ID
+		subject := strconv.FormatInt(chatID, 10)
+
+		var text string
+		switch cmd {
+		case "/topics":
+			var b strings.Builder
+			b.WriteString("Темы уведомлений:\n")
+			for _, t := range topics {
+				ok, err := policy.Allowed(ctx, subject, Channel, t.Name)
+				if err != nil {
+					return fmt.Errorf("не удалось проверить подписку: %w", err)
+				}
+				mark := "✗"
+				if ok {
+					mark = "✓"
+				}
+				b.WriteString(mark + " " + t.Name)
+				if t.Title != "" {
+					b.WriteString(" — " + t.Title)
+				}
+				b.WriteString("\n")
+			}
+			b.WriteString("\n/subscribe <тема> — подписаться, /unsubscribe <тема> — отписаться")
+			text = b.String()
+		case "/subscribe", "/unsubscribe":
+			if len(fields) < 2 || !known[fields[1]] {
+				text = "Неизвестная тема. Список тем: /topics"
+				break
+			}
+			topic := fields[1]
+			set, done := policy.OptIn, "Подписка на тему «%s» включена"
+			if cmd == "/unsubscribe" {
+				set, done = policy.OptOut, "Вы отписались от темы «%s»"
+			}
+			if err := set(ctx, subject, Channel, topic); err != nil {
+				return fmt.Errorf("не удалось сохранить подписку: %w", err)
+			}
+			c.logger.Info("подписка чата изменена", "chatID", chatID, "command", cmd, "topic", topic)
+			text = fmt.Sprintf(done, topic)
+		default:
+			return nil
+		}
+
+		_, err := c.sendText(ctx, MessageOptions{ChatID: chatID, Text: text})
+		return err
+	}
+}
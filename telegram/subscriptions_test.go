@@ -0,0 +1,57 @@
+package telegram
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/epheer/notephee/preferences"
+)
+
+func TestSubscriptionCommands(t *testing.T) {
+	var last string
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Text string `json:"text"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		last = req.Text
+		_, _ = w.Write([]byte(`{"ok":true,"result":{}}`))
+	})
+	policy := preferences.NewPolicy(preferences.NewMemoryStore(), "news")
+	handle := c.SubscriptionCommands(policy, Topic{Name: "billing", Title: "Счета"}, Topic{Name: "news"})
+
+	ctx := context.Background()
+	send := func(text string) {
+		t.Helper()
+		upd := Update{Message: &IncomingMessage{Chat: Chat{ID: 42}, Text: text}}
+		if err := handle(ctx, upd); err != nil {
+			t.Fatalf("Ошибка обработчика: %v", err)
+		}
+	}
+
+	send("/topics")
+	if !strings.Contains(last, "✓ billing — Счета") || !strings.Contains(last, "✗ news") {
+		t.Fatalf("неожиданный список тем: %q", last)
+	}
+
+	send("/subscribe@test_bot news")
+	send("/unsubscribe billing")
+	for topic, want := range map[string]bool{"news": true, "billing": false} {
+		if ok, _ := policy.Allowed(ctx, "42", Channel, topic); ok != want {
+			t.Fatalf("подписка на %s: %v, ожидалось %v", topic, ok, want)
+		}
+	}
+
+	send("/subscribe weather")
+	if !strings.HasPrefix(last, "Неизвестная тема") {
+		t.Fatalf("неизвестная тема должна отклоняться, получено %q", last)
+	}
+	last = ""
+	send("привет")
+	if last != "" {
+		t.Fatalf("обычные сообщения не должны получать ответ, получено %q", last)
+	}
+}
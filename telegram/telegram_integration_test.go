@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"github.com/epheer/notephee/config"
+	"github.com/epheer/notephee/envclient"
 	"github.com/epheer/notephee/telegram"
 )
 
@@ -22,7 +23,7 @@ func TestTelegramIntegration(t *testing.T) {
 
 	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
 	cfg := config.Cfg
-	client := telegram.NewTgClient(cfg, logger)
+	client := envclient.Telegram(cfg, logger)
 	bm := client.NewBindingManager(10*time.Minute, logger)
 
 	userID := "notephee_test"
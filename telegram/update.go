@@ -0,0 +1,143 @@
+package telegram
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// User — пользователь или бот Telegram.
+type User struct {
+	ID           int64  `json:"id"`                      // Идентификатор пользователя
+	IsBot        bool   `json:"is_bot"`                  // Пользователь — бот
+	FirstName    string `json:"first_name"`              // Имя
+	LastName     string `json:"last_name,omitempty"`     // Фамилия
+	Username     string `json:"username,omitempty"`      // Имя пользователя без @
+	LanguageCode string `json:"language_code,omitempty"` // Язык интерфейса (IETF)
+}
+
+// Chat — чат, в котором пришло сообщение.
+type Chat struct {
+	ID       int64  `json:"id"`                 // Идентификатор чата
+	Type     string `json:"type"`               // private, group, supergroup или channel
+	Title    string `json:"title,omitempty"`    // Название группы или канала
+	Username string `json:"username,omitempty"` // Имя пользователя или канала без @
+}
+
+// IncomingMessage — входящее сообщение из обновления.
+type IncomingMessage struct {
+	MessageID      int64            `json:"message_id"`                 // Идентификатор сообщения в чате
+	From           *User            `json:"from,omitempty"`             // Отправитель; пуст для сообщений в каналах
+	Chat           Chat             `json:"chat"`                       // Чат
+	Date           int64            `json:"date"`                       // Время отправки, Unix-время
+	EditDate       int64            `json:"edit_date,omitempty"`        // Время последнего изменения
+	Text           string           `json:"text,omitempty"`             // Текст сообщения
+	Caption        string           `json:"caption,omitempty"`          // Подпись к медиа
+	ReplyToMessage *IncomingMessage `json:"reply_to_message,omitempty"` // Сообщение, на которое дан ответ
+}
+
+// CallbackQuery — нажатие inline-кнопки. На него нужно ответить методом answerCallbackQuery
+// (AnswerCallbackQuery или автоматически через HandleCallback), иначе клиент Telegram показывает
+// индикатор загрузки.
+type CallbackQuery struct {
+	ID              string           `json:"id"`                          // Идентификатор для answerCallbackQuery
+	From            User             `json:"from"`                        // Нажавший кнопку
+	Message         *IncomingMessage `json:"message,omitempty"`           // Сообщение с кнопкой
+	InlineMessageID string           `json:"inline_message_id,omitempty"` // Сообщение, отправленное в inline-режиме
+	Data            string           `json:"data,omitempty"`              // callback_data кнопки
+}
+
+// ChatMemberUpdated — изменение статуса бота в чате: пользователь заблокировал или разблокировал бота,
+// бота добавили в группу или исключили из неё.
+type ChatMemberUpdated struct {
+	Chat          Chat       `json:"chat"`            // Чат
+	From          User       `json:"from"`            // Кто изменил статус
+	Date          int64      `json:"date"`            // Время изменения, Unix-время
+	OldChatMember ChatMember `json:"old_chat_member"` // Прежний статус бота
+	NewChatMember ChatMember `json:"new_chat_member"` // Новый статус бота
+}
+
+// Типы обновлений для UpdatesOptions.AllowedUpdates — по полям Update.
+const (
+	UpdateMessage           = "message"
+	UpdateEditedMessage     = "edited_message"
+	UpdateChannelPost       = "channel_post"
+	UpdateEditedChannelPost = "edited_channel_post"
+	UpdateCallbackQuery     = "callback_query"
+	UpdateMyChatMember      = "my_chat_member"
+)
+
+// Update представляет одно обновление от Telegram API (например, входящее сообщение).
+//
+// Заполнено не больше одного из полей с содержимым. Типы обновлений, для которых полей нет,
+// доступны в Raw.
+type Update struct {
+	UpdateID          int64              `json:"update_id"`                     // ID обновления
+	Message           *IncomingMessage   `json:"message,omitempty"`             // Новое сообщение
+	EditedMessage     *IncomingMessage   `json:"edited_message,omitempty"`      // Изменённое сообщение
+	ChannelPost       *IncomingMessage   `json:"channel_post,omitempty"`        // Новая запись в канале
+	EditedChannelPost *IncomingMessage   `json:"edited_channel_post,omitempty"` // Изменённая запись в канале
+	CallbackQuery     *CallbackQuery     `json:"callback_query,omitempty"`      // Нажатие inline-кнопки
+	MyChatMember      *ChatMemberUpdated `json:"my_chat_member,omitempty"`      // Изменение статуса бота в чате
+
+	Raw json.RawMessage `json:"-"` // Обновление целиком, как его вернул Telegram
+}
+
+// UnmarshalJSON разбирает обновление и сохраняет исходный JSON в Raw.
+func (u *Update) UnmarshalJSON(data []byte) error {
+	type update Update
+	if err := json.Unmarshal(data, (*update)(u)); err != nil {
+		return err
+	}
+	u.Raw = append(json.RawMessage(nil), data...)
+	return nil
+}
+
+// UpdatesResponse — структура ответа Telegram API на метод getUpdates.
+type UpdatesResponse struct {
+	OK          bool     `json:"ok"`                    // Статус ответа API
+	Result      []Update `json:"result"`                // Список новых обновлений
+	ErrorCode   int      `json:"error_code,omitempty"`  // Код ошибки, если OK == false
+	Description string   `json:"description,omitempty"` // Описание ошибки
+}
+
+// UpdateHandler обрабатывает обновление, полученное StartPolling. Ошибка пишется в лог
+// и не останавливает опрос: обновление считается обработанным.
+type UpdateHandler func(ctx context.Context, upd Update) error
+
+// UpdateMiddleware оборачивает обработку обновления: может изменить его, пропустить
+// (не вызывая next) или выполнить действия до и после, например записать метрики.
+type UpdateMiddleware func(next UpdateHandler) UpdateHandler
+
+// AddUpdateHandler добавляет обработчик обновлений StartPolling. Обработчики вызываются по порядку
+// добавления после привязки по /start, каждый получает все обновления. Вызывается до StartPolling.
+func (c *TgClient) AddUpdateHandler(h UpdateHandler) {
+	c.updateHandlers = append(c.updateHandlers, h)
+}
+
+// AddUpdateMiddleware добавляет промежуточный обработчик, через который проходит каждое обновление
+// до привязки по /start и обработчиков AddUpdateHandler. Добавленный первым вызывается первым.
+// Вызывается до StartPolling.
+func (c *TgClient) AddUpdateMiddleware(mw UpdateMiddleware) {
+	c.updateMiddleware = append(c.updateMiddleware, mw)
+}
+
+// updateChain собирает обработку обновления: промежуточные обработчики вокруг привязки по /start
+// и обработчиков AddUpdateHandler.
+func (c *TgClient) updateChain(bm *BindingManager, callback func(Binding)) UpdateHandler {
+	handlers := append([]UpdateHandler(nil), c.updateHandlers...)
+	h := func(ctx context.Context, upd Update) error {
+		c.handleUpdate(ctx, upd, bm, callback)
+		for _, handler := range handlers {
+			if err := handler(ctx, upd); err != nil {
+				c.logger.Warn("ошибка обработчика обновления", "update_id", upd.UpdateID, "error", err)
+				c.pollingError(fmt.Errorf("обработчик обновления %d: %w", upd.UpdateID, err))
+			}
+		}
+		return nil
+	}
+	for i := len(c.updateMiddleware) - 1; i >= 0; i-- {
+		h = c.updateMiddleware[i](h)
+	}
+	return h
+}
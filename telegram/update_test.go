@@ -0,0 +1,196 @@
+package telegram
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/epheer/notephee/events"
+	"github.com/epheer/notephee/notify"
+	"github.com/epheer/notephee/suppression"
+)
+
+func TestUpdateHandlers(t *testing.T) {
+	var served atomic.Bool
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasSuffix(r.URL.Path, "/getUpdates") || served.Swap(true) {
+			time.Sleep(10 * time.Millisecond)
+			_, _ = w.Write([]byte(`{"ok":true,"result":[]}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"ok":true,"result":[
+			{"update_id":1,"edited_message":{"message_id":10,"from":{"id":5,"first_name":"Ivan","username":"ivan"},"chat":{"id":5,"type":"private"},"date":1,"text":"исправлено"}},
+			{"update_id":2,"callback_query":{"id":"q1","from":{"id":5,"first_name":"Ivan"},"data":"confirm","message":{"message_id":11,"chat":{"id":5,"type":"private"},"date":1}}},
+			{"update_id":3,"my_chat_member":{"chat":{"id":5}}}
+		]}`))
+	})
+	bm := c.NewBindingManager(time.Minute, c.logger)
+
+	var order []string
+	c.AddUpdateMiddleware(func(next UpdateHandler) UpdateHandler {
+		return func(ctx context.Context, upd Update) error {
+			order = append(order, "mw")
+			if upd.UpdateID == 3 {
+				// Промежуточный обработчик может остановить обработку обновления
+				return nil
+			}
+			return next(ctx, upd)
+		}
+	})
+	var updates []Update
+	ctx, cancel := context.WithCancel(context.Background())
+	c.AddUpdateHandler(func(_ context.Context, upd Update) error {
+		order = append(order, "first")
+		updates = append(updates, upd)
+		return errors.New("ошибка не останавливает опрос")
+	})
+	c.AddUpdateHandler(func(_ context.Context, upd Update) error {
+		order = append(order, "second")
+		if upd.UpdateID == 2 {
+			cancel()
+		}
+		return nil
+	})
+
+	done := make(chan struct{})
+	go func() {
+		c.StartPolling(ctx, bm, func(Binding) {})
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("polling не завершился")
+	}
+
+	if strings.Join(order, ",") != "mw,first,second,mw,first,second,mw" {
+		t.Fatalf("неверный порядок обработчиков: %v", order)
+	}
+	edited := updates[0].EditedMessage
+	if edited == nil || edited.MessageID != 10 || edited.From == nil || edited.From.Username != "ivan" {
+		t.Fatalf("изменённое сообщение разобрано неверно: %+v", updates[0])
+	}
+	cb := updates[1].CallbackQuery
+	if cb == nil || cb.ID != "q1" || cb.Data != "confirm" || cb.Message.MessageID != 11 {
+		t.Fatalf("нажатие кнопки разобрано неверно: %+v", updates[1])
+	}
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(updates[1].Raw, &raw); err != nil || raw["callback_query"] == nil {
+		t.Fatalf("Raw должен содержать обновление целиком: %s", updates[1].Raw)
+	}
+}
+
+func TestMemberUpdates(t *testing.T) {
+	c := newTestClient(t, okHandler)
+	bm := c.NewBindingManager(time.Minute, c.logger)
+	ctx := context.Background()
+	link := bm.CreateInvite("u1")
+	if _, err := bm.ResolveBinding(link[strings.Index(link, "=")+1:], 5); err != nil {
+		t.Fatalf("Ошибка ResolveBinding: %v", err)
+	}
+	store := suppression.NewMemoryStore()
+	c.SetSuppressionStore(store)
+	bus := events.NewBus()
+	var got []events.Event
+	bus.Subscribe(func(e events.Event) { got = append(got, e) })
+	c.SetEvents(bus)
+
+	member := func(old, new string) Update {
+		var upd Update
+		data := `{"update_id":1,"my_chat_member":{"chat":{"id":5,"type":"private"},"from":{"id":5,"first_name":"Ivan"},"date":1700000000,
+			"old_chat_member":{"user":{"id":1,"is_bot":true,"first_name":"bot"},"status":"` + old + `"},
+			"new_chat_member":{"user":{"id":1,"is_bot":true,"first_name":"bot"},"status":"` + new + `"}}}`
+		if err := json.Unmarshal([]byte(data), &upd); err != nil {
+			t.Fatalf("Ошибка разбора обновления: %v", err)
+		}
+		return upd
+	}
+
+	c.handleUpdate(ctx, member(MemberMember, MemberKicked), bm, nil)
+	if _, err := bm.BindingByChat(ctx, 5); !errors.Is(err, ErrBindingNotFound) {
+		t.Fatalf("привязка заблокировавшего бота чата должна быть удалена: %v", err)
+	}
+	if err := c.Send(ctx, notify.Message{To: "5", Text: "привет"}); !errors.Is(err, ErrSuppressed) {
+		t.Fatalf("ожидалась ErrSuppressed, получено %v", err)
+	}
+	if len(got) != 1 || got[0].Type != events.BotBlocked || got[0].Recipient != "5" || got[0].Data["user_id"] != "u1" {
+		t.Fatalf("неверное событие блокировки: %+v", got)
+	}
+
+	// Повтор того же статуса ничего не меняет
+	c.handleUpdate(ctx, member(MemberKicked, MemberKicked), bm, nil)
+	c.handleUpdate(ctx, member(MemberKicked, MemberMember), bm, nil)
+	if ok, _ := store.IsSuppressed(ctx, Channel, "5", ""); ok {
+		t.Fatal("разблокированный чат остался в списке подавления")
+	}
+	if len(got) != 2 || got[1].Type != events.BotUnblocked {
+		t.Fatalf("неверное событие разблокировки: %+v", got)
+	}
+	if err := c.Send(ctx, notify.Message{To: "5", Text: "привет"}); err != nil {
+		t.Fatalf("Ошибка Send после разблокировки: %v", err)
+	}
+}
+
+func TestCallbacks(t *testing.T) {
+	var mu sync.Mutex
+	var answers []map[string]any
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, AnswerCallbackQuery) {
+			var body map[string]any
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			mu.Lock()
+			answers = append(answers, body)
+			mu.Unlock()
+		}
+		_, _ = w.Write([]byte(`{"ok":true,"result":true}`))
+	})
+	bm := c.NewBindingManager(time.Minute, c.logger)
+	ctx := context.Background()
+	query := func(id, data string) Update {
+		return Update{CallbackQuery: &CallbackQuery{ID: id, From: User{ID: 5}, Data: data}}
+	}
+
+	// Без обработчиков на нажатия отвечает приложение
+	c.handleUpdate(ctx, query("q0", "topic:billing"), bm, nil)
+	if len(answers) != 0 {
+		t.Fatalf("без HandleCallback ответа быть не должно: %v", answers)
+	}
+
+	var handled []string
+	c.HandleCallback("topic:", func(_ context.Context, q *CallbackQuery) (CallbackAnswer, error) {
+		handled = append(handled, "topic "+q.Data)
+		return CallbackAnswer{Text: "готово"}, nil
+	})
+	c.HandleCallback("topic:security", func(_ context.Context, q *CallbackQuery) (CallbackAnswer, error) {
+		handled = append(handled, "security "+q.Data)
+		return CallbackAnswer{Text: "нельзя отписаться", ShowAlert: true}, errors.New("тема обязательна")
+	})
+	for i, data := range []string{"topic:billing", "topic:security", "other"} {
+		c.handleUpdate(ctx, query("q"+strconv.Itoa(i+1), data), bm, nil)
+	}
+
+	if strings.Join(handled, ",") != "topic topic:billing,security topic:security" {
+		t.Fatalf("неверная маршрутизация нажатий: %v", handled)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(answers) != 3 {
+		t.Fatalf("ожидалось 3 ответа, получено %d", len(answers))
+	}
+	if answers[0]["callback_query_id"] != "q1" || answers[0]["text"] != "готово" {
+		t.Fatalf("неверный ответ на q1: %v", answers[0])
+	}
+	if answers[1]["show_alert"] != true || answers[1]["text"] != "нельзя отписаться" {
+		t.Fatalf("ответ отправляется и при ошибке обработчика: %v", answers[1])
+	}
+	if answers[2]["callback_query_id"] != "q3" || answers[2]["text"] != nil {
+		t.Fatalf("без обработчика ответ должен быть пустым: %v", answers[2])
+	}
+}
@@ -0,0 +1,168 @@
+// Package templates хранит шаблоны сообщений с версиями.
+//
+// Каждое изменение шаблона публикуется новой версией, а старые версии остаются доступны:
+// кампания закрепляет версию при создании (campaign.Campaign.Pin), и правка шаблона
+// посреди рассылки не меняет текст для оставшихся получателей.
+package templates
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// ErrNotFound возвращается, если шаблона или его версии нет.
+var ErrNotFound = errors.New("шаблон не найден")
+
+// Version — одна опубликованная версия шаблона. Версии неизменяемы.
+type Version struct {
+	Name      string    `json:"name"`              // Имя шаблона
+	Version   int       `json:"version"`           // Номер версии, начиная с 1
+	Subject   string    `json:"subject,omitempty"` // Шаблон темы
+	Body      string    `json:"body"`              // Шаблон текста
+	CreatedAt time.Time `json:"created_at"`        // Время публикации
+}
+
+// String возвращает обозначение версии вида name@v3.
+func (v Version) String() string {
+	return fmt.Sprintf("%s@v%d", v.Name, v.Version)
+}
+
+// Validate проверяет, что тема и текст шаблона name разбираются text/template,
+// чтобы ошибка в шаблоне обнаружилась при публикации, а не при отправке.
+func Validate(name, subject, body string) error {
+	for _, text := range []string{subject, body} {
+		if _, err := template.New(name).Option("missingkey=error").Parse(text); err != nil {
+			return fmt.Errorf("шаблон %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// check проверяет шаблон перед публикацией: имя задано, тема и текст разбираются.
+func check(name, subject, body string) error {
+	if name == "" {
+		return fmt.Errorf("у шаблона не задано имя")
+	}
+	return Validate(name, subject, body)
+}
+
+// Draft — шаблон для публикации через Store.PublishAll.
+type Draft struct {
+	Name    string // Имя шаблона
+	Subject string // Шаблон темы
+	Body    string // Шаблон текста
+}
+
+// Store хранит версии шаблонов.
+type Store interface {
+	// Publish сохраняет новую версию шаблона name и возвращает её.
+	Publish(ctx context.Context, name, subject, body string) (Version, error)
+	// PublishAll сохраняет новые версии нескольких шаблонов атомарно: при ошибке не публикуется ни один.
+	PublishAll(ctx context.Context, drafts []Draft) ([]Version, error)
+	// Get возвращает версию version шаблона name или ErrNotFound.
+	Get(ctx context.Context, name string, version int) (*Version, error)
+	// Latest возвращает последнюю версию шаблона name или ErrNotFound.
+	Latest(ctx context.Context, name string) (*Version, error)
+	// Versions возвращает все версии шаблона name по возрастанию номера.
+	Versions(ctx context.Context, name string) ([]Version, error)
+}
+
+// MemoryStore — Store в памяти процесса.
+type MemoryStore struct {
+	mu        sync.RWMutex
+	templates map[string][]Version // Имя → версии по порядку
+}
+
+// NewMemoryStore создаёт пустое хранилище шаблонов в памяти.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{templates: make(map[string][]Version)}
+}
+
+// Publish сохраняет новую версию шаблона. Шаблон без имени или с синтаксической ошибкой не публикуется.
+func (s *MemoryStore) Publish(_ context.Context, name, subject, body string) (Version, error) {
+	if err := check(name, subject, body); err != nil {
+		return Version{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	v := Version{
+		Name:      name,
+		Version:   len(s.templates[name]) + 1,
+		Subject:   subject,
+		Body:      body,
+		CreatedAt: time.Now(),
+	}
+	s.templates[name] = append(s.templates[name], v)
+	return v, nil
+}
+
+// PublishAll сохраняет новые версии шаблонов одним шагом. Если хотя бы один шаблон без имени
+// или с синтаксической ошибкой, не публикуется ни один.
+func (s *MemoryStore) PublishAll(_ context.Context, drafts []Draft) ([]Version, error) {
+	for _, d := range drafts {
+		if err := check(d.Name, d.Subject, d.Body); err != nil {
+			return nil, err
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	out := make([]Version, len(drafts))
+	for i, d := range drafts {
+		out[i] = Version{
+			Name:      d.Name,
+			Version:   len(s.templates[d.Name]) + 1,
+			Subject:   d.Subject,
+			Body:      d.Body,
+			CreatedAt: now,
+		}
+		s.templates[d.Name] = append(s.templates[d.Name], out[i])
+	}
+	return out, nil
+}
+
+// Get возвращает версию шаблона.
+func (s *MemoryStore) Get(_ context.Context, name string, version int) (*Version, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	versions := s.templates[name]
+	if version < 1 || version > len(versions) {
+		return nil, fmt.Errorf("%s@v%d: %w", name, version, ErrNotFound)
+	}
+	v := versions[version-1]
+	return &v, nil
+}
+
+// Latest возвращает последнюю версию шаблона.
+func (s *MemoryStore) Latest(_ context.Context, name string) (*Version, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	versions := s.templates[name]
+	if len(versions) == 0 {
+		return nil, fmt.Errorf("%s: %w", name, ErrNotFound)
+	}
+	v := versions[len(versions)-1]
+	return &v, nil
+}
+
+// Versions возвращает все версии шаблона.
+func (s *MemoryStore) Versions(_ context.Context, name string) ([]Version, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	versions := s.templates[name]
+	if len(versions) == 0 {
+		return nil, fmt.Errorf("%s: %w", name, ErrNotFound)
+	}
+	return append([]Version(nil), versions...), nil
+}
@@ -0,0 +1,78 @@
+package templates_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/epheer/notephee/templates"
+)
+
+func TestPublishVersions(t *testing.T) {
+	ctx := context.Background()
+	store := templates.NewMemoryStore()
+
+	v1, err := store.Publish(ctx, "welcome", "Привет", "Здравствуйте, {{.Name}}!")
+	if err != nil {
+		t.Fatalf("Ошибка Publish: %v", err)
+	}
+	v2, _ := store.Publish(ctx, "welcome", "Привет", "Добрый день, {{.Name}}!")
+	if v1.Version != 1 || v2.Version != 2 || v2.String() != "welcome@v2" {
+		t.Fatalf("неверные номера версий: %s, %s", v1, v2)
+	}
+
+	if got, err := store.Get(ctx, "welcome", 1); err != nil || got.Body != "Здравствуйте, {{.Name}}!" {
+		t.Fatalf("первая версия должна остаться неизменной: %+v %v", got, err)
+	}
+	if got, err := store.Latest(ctx, "welcome"); err != nil || got.Version != 2 {
+		t.Fatalf("последней должна быть версия 2: %+v %v", got, err)
+	}
+	if list, err := store.Versions(ctx, "welcome"); err != nil || len(list) != 2 {
+		t.Fatalf("ожидались две версии: %+v %v", list, err)
+	}
+	if _, err := store.Get(ctx, "welcome", 3); !errors.Is(err, templates.ErrNotFound) {
+		t.Fatalf("ожидалась ErrNotFound, получено %v", err)
+	}
+	if _, err := store.Latest(ctx, "missing"); !errors.Is(err, templates.ErrNotFound) {
+		t.Fatalf("ожидалась ErrNotFound, получено %v", err)
+	}
+}
+
+func TestPublishValidates(t *testing.T) {
+	ctx := context.Background()
+	store := templates.NewMemoryStore()
+
+	if _, err := store.Publish(ctx, "", "Привет", "Текст"); err == nil {
+		t.Fatal("шаблон без имени не должен публиковаться")
+	}
+	if _, err := store.Publish(ctx, "broken", "Привет", "Здравствуйте, {{.Name"); err == nil {
+		t.Fatal("шаблон с синтаксической ошибкой не должен публиковаться")
+	}
+	if _, err := store.Latest(ctx, "broken"); !errors.Is(err, templates.ErrNotFound) {
+		t.Fatalf("некорректный шаблон сохранён: %v", err)
+	}
+}
+
+func TestPublishAllAtomic(t *testing.T) {
+	ctx := context.Background()
+	store := templates.NewMemoryStore()
+
+	_, err := store.PublishAll(ctx, []templates.Draft{
+		{Name: "welcome", Body: "Здравствуйте, {{.Name}}!"},
+		{Name: "broken", Subject: "{{if}}", Body: "Текст"},
+	})
+	if err == nil {
+		t.Fatal("ожидалась ошибка синтаксиса шаблона")
+	}
+	if _, err := store.Latest(ctx, "welcome"); !errors.Is(err, templates.ErrNotFound) {
+		t.Fatalf("при ошибке не должен публиковаться ни один шаблон: %v", err)
+	}
+
+	versions, err := store.PublishAll(ctx, []templates.Draft{
+		{Name: "welcome", Body: "Здравствуйте, {{.Name}}!"},
+		{Name: "bye", Body: "До свидания"},
+	})
+	if err != nil || len(versions) != 2 || versions[0].Version != 1 || versions[1].String() != "bye@v1" {
+		t.Fatalf("неверный результат PublishAll: %+v %v", versions, err)
+	}
+}
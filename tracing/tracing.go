@@ -0,0 +1,75 @@
+// Package tracing оборачивает отправку сообщений в спаны OpenTelemetry.
+//
+// Спаны создаются через глобальный TracerProvider (otel.SetTracerProvider), поэтому без
+// настроенного SDK инструментирование ничего не стоит. Родителем спана отправки становится
+// спан из контекста вызывающей стороны, и задержка уведомлений видна в той же трассе,
+// что и запрос, который их вызвал.
+package tracing
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/epheer/notephee/delivery"
+)
+
+// Instrumentation — имя библиотеки инструментирования для TracerProvider.
+const Instrumentation = "github.com/epheer/notephee"
+
+// Атрибуты спанов отправки.
+const (
+	AttrChannel       = attribute.Key("notephee.channel")          // Имя канала
+	AttrRecipientHash = attribute.Key("notephee.recipient.hash")   // SHA-256 адреса получателя: сам адрес в трассы не попадает
+	AttrRecipients    = attribute.Key("notephee.recipients")       // Число получателей в пакетной отправке
+	AttrRetryCount    = attribute.Key("notephee.retry_count")      // Номер повтора; 0 — первая попытка
+	AttrHTTPStatus    = attribute.Key("http.response.status_code") // Код HTTP-ответа провайдера
+)
+
+type retryKey struct{}
+
+// WithRetry помечает ctx номером повтора n. Спаны отправки в этом контексте получают AttrRetryCount.
+func WithRetry(ctx context.Context, n int) context.Context {
+	return context.WithValue(ctx, retryKey{}, n)
+}
+
+// RetryCount возвращает номер повтора из ctx, заданный WithRetry; 0 — первая попытка.
+func RetryCount(ctx context.Context) int {
+	n, _ := ctx.Value(retryKey{}).(int)
+	return n
+}
+
+// Start начинает спан отправки в канал channel получателю to (пустой to не записывается).
+// Возвращает контекст со спаном, который нужно передать дальше в HTTP-запрос.
+func Start(ctx context.Context, channel, to string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	attrs = append(attrs, AttrChannel.String(channel), AttrRetryCount.Int(RetryCount(ctx)))
+	if to != "" {
+		attrs = append(attrs, AttrRecipientHash.String(delivery.Hash(to)))
+	}
+	return otel.Tracer(Instrumentation).Start(ctx, "notephee.send "+channel,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attrs...),
+	)
+}
+
+// StartBatch начинает спан пакетной отправки в канал channel n получателям одним запросом.
+func StartBatch(ctx context.Context, channel string, n int) (context.Context, trace.Span) {
+	return Start(ctx, channel, "", AttrRecipients.Int(n))
+}
+
+// End завершает спан, отмечая ошибку err, если она есть.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// HTTPStatus записывает код HTTP-ответа провайдера в текущий спан ctx.
+func HTTPStatus(ctx context.Context, code int) {
+	trace.SpanFromContext(ctx).SetAttributes(AttrHTTPStatus.Int(code))
+}
@@ -0,0 +1,94 @@
+package tracing_test
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/epheer/notephee/config"
+	"github.com/epheer/notephee/delivery"
+	"github.com/epheer/notephee/notify"
+	"github.com/epheer/notephee/slack"
+	"github.com/epheer/notephee/tracing"
+)
+
+// record подключает глобальный TracerProvider, который запоминает завершённые спаны.
+func record(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+	rec := tracetest.NewSpanRecorder()
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec)))
+	t.Cleanup(func() { otel.SetTracerProvider(prev) })
+	return rec
+}
+
+func attrs(s sdktrace.ReadOnlySpan) map[attribute.Key]attribute.Value {
+	out := make(map[attribute.Key]attribute.Value)
+	for _, kv := range s.Attributes() {
+		out[kv.Key] = kv.Value
+	}
+	return out
+}
+
+func TestClientSpan(t *testing.T) {
+	rec := record(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	t.Cleanup(srv.Close)
+
+	c := slack.NewClient(&config.Config{SlackWebhookURL: srv.URL}, slog.Default())
+
+	// Спан отправки становится дочерним для спана вызывающей стороны
+	ctx, parent := otel.Tracer("test").Start(context.Background(), "handle request")
+	err := c.Send(tracing.WithRetry(ctx, 2), notify.Message{To: "C1", Text: "привет"})
+	parent.End()
+	if err == nil {
+		t.Fatal("ожидалась ошибка отправки")
+	}
+
+	spans := rec.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("ожидалось 2 спана, получено %d", len(spans))
+	}
+	send := spans[0]
+	if send.Name() != "notephee.send slack" || send.Parent().SpanID() != parent.SpanContext().SpanID() {
+		t.Fatalf("неожиданный спан отправки: %s, родитель %s", send.Name(), send.Parent().SpanID())
+	}
+	a := attrs(send)
+	if a[tracing.AttrChannel].AsString() != "slack" || a[tracing.AttrHTTPStatus].AsInt64() != http.StatusTooManyRequests ||
+		a[tracing.AttrRetryCount].AsInt64() != 2 || a[tracing.AttrRecipientHash].AsString() != delivery.Hash("C1") {
+		t.Fatalf("неожиданные атрибуты спана: %v", send.Attributes())
+	}
+	if send.Status().Code != codes.Error {
+		t.Fatalf("ошибка отправки должна отмечаться в спане, статус %v", send.Status())
+	}
+}
+
+func TestStartBatch(t *testing.T) {
+	rec := record(t)
+
+	_, span := tracing.StartBatch(context.Background(), "vk", 100)
+	tracing.End(span, errors.New("таймаут"))
+
+	spans := rec.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("ожидался 1 спан, получено %d", len(spans))
+	}
+	a := attrs(spans[0])
+	if a[tracing.AttrRecipients].AsInt64() != 100 || a[tracing.AttrRetryCount].AsInt64() != 0 {
+		t.Fatalf("неожиданные атрибуты спана: %v", spans[0].Attributes())
+	}
+	if _, ok := a[tracing.AttrRecipientHash]; ok {
+		t.Fatal("у пакетной отправки не должно быть хэша получателя")
+	}
+}
@@ -0,0 +1,343 @@
+// Package viber реализует канал Viber: отправку сообщений подписчикам бота через REST Bot API.
+package viber
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	"github.com/epheer/notephee/config"
+	"github.com/epheer/notephee/delivery"
+	"github.com/epheer/notephee/notify"
+	"github.com/epheer/notephee/tracing"
+)
+
+// Channel — имя канала Viber в notify.Registry и журнале доставки.
+const Channel = "viber"
+
+// Методы и ограничения Viber Bot API.
+const (
+	SendMessage      = "/send_message"      // Сообщение одному подписчику
+	BroadcastMessage = "/broadcast_message" // Сообщение нескольким подписчикам
+	MaxBroadcast     = 300                  // Предел получателей в одном broadcast_message
+	MaxSenderName    = 28                   // Предел длины имени отправителя
+	MaxText          = 7000                 // Предел длины текста сообщения в символах
+)
+
+// Sender — отправитель сообщения, как он показывается в Viber.
+type Sender struct {
+	Name   string `json:"name"`             // Имя (не длиннее MaxSenderName символов)
+	Avatar string `json:"avatar,omitempty"` // URL аватара (необязательно)
+}
+
+// MessageOptions содержит параметры одного сообщения.
+type MessageOptions struct {
+	Receiver string `json:"receiver"` // ID подписчика бота
+	Text     string `json:"text"`     // Текст сообщения
+	UserID   string `json:"-"`        // Внутренний ID пользователя для журнала доставки (необязательно)
+	ID       string `json:"-"`        // Идентификатор попытки в журнале доставки (генерируется, если пуст)
+}
+
+// SendingOptions используется для отправки одного сообщения нескольким подписчикам.
+type SendingOptions struct {
+	Receivers []string // ID подписчиков
+	Text      string   // Текст сообщения
+}
+
+// message — тело запроса send_message и broadcast_message.
+type message struct {
+	Receiver      string   `json:"receiver,omitempty"`
+	BroadcastList []string `json:"broadcast_list,omitempty"`
+	MinAPIVersion int      `json:"min_api_version"`
+	Sender        Sender   `json:"sender"`
+	Type          string   `json:"type"`
+	Text          string   `json:"text"`
+}
+
+// Response — ответ Viber Bot API.
+type Response struct {
+	Status        int    `json:"status"`                  // 0 — успех, иначе код ошибки
+	StatusMessage string `json:"status_message"`          // Описание статуса
+	MessageToken  int64  `json:"message_token,omitempty"` // Идентификатор сообщения
+	FailedList    []struct {
+		Receiver      string `json:"receiver"`
+		Status        int    `json:"status"`
+		StatusMessage string `json:"status_message"`
+	} `json:"failed_list,omitempty"` // Получатели broadcast_message, которым отправка не удалась
+}
+
+// SendResult представляет результат отправки одному подписчику.
+type SendResult struct {
+	Receiver string // ID подписчика
+	Index    int    // Номер подписчика в SendingOptions.Receivers
+	Error    error  // Ошибка, если произошла
+}
+
+// Client инкапсулирует клиента Viber Bot API.
+type Client struct {
+	token   string       // Токен бота
+	sender  Sender       // Отправитель сообщений
+	uri     string       // Базовый URL API
+	http    *http.Client // HTTP-клиент
+	logger  *slog.Logger // Логгер
+	Enabled bool         // Флаг доступности функционала
+
+	deliveryLog delivery.DeliveryLog // Журнал попыток отправки (необязательно)
+	inflight    notify.InFlight      // Начатые отправки, которых ждёт Close
+}
+
+// NewClient создаёт клиента Viber.
+//
+// cfg — конфигурация приложения с токеном бота и именем отправителя.
+// logger — логгер для ведения журнала.
+func NewClient(cfg *config.Config, logger *slog.Logger) *Client {
+	name := []rune(cfg.ViberSenderName)
+	if len(name) > MaxSenderName {
+		name = name[:MaxSenderName]
+	}
+	return &Client{
+		token:   cfg.ViberToken,
+		sender:  Sender{Name: string(name), Avatar: cfg.ViberSenderAvatar},
+		uri:     "https://chatapi.viber.com/pa",
+		http:    &http.Client{Timeout: 10 * time.Second},
+		logger:  logger,
+		Enabled: cfg.IsViberEnabled(),
+	}
+}
+
+// SetDeliveryLog подключает журнал, в который записывается каждая попытка отправки.
+func (c *Client) SetDeliveryLog(log delivery.DeliveryLog) {
+	c.deliveryLog = log
+}
+
+// Close перестаёт принимать новые отправки и ждёт завершения начатых до истечения ctx.
+// Отправки после Close возвращают notify.ErrClosed.
+func (c *Client) Close(ctx context.Context) error {
+	return c.inflight.Close(ctx)
+}
+
+// Channel возвращает имя канала для notify.Registry.
+func (c *Client) Channel() string {
+	return Channel
+}
+
+// Capabilities возвращает возможности канала: текст до MaxText символов.
+func (c *Client) Capabilities() notify.Capabilities {
+	return notify.Capabilities{Channel: Channel, MaxLength: MaxText}
+}
+
+// Send реализует notify.Sender: msg.To — ID подписчика бота. Тема, если есть, выводится первой строкой.
+func (c *Client) Send(ctx context.Context, msg notify.Message) error {
+	ctx = delivery.WithTemplate(ctx, msg.Template)
+	text := msg.Text
+	if msg.Subject != "" {
+		text = msg.Subject + "\n\n" + text
+	}
+
+	_, err := c.sendText(ctx, MessageOptions{Receiver: msg.To, Text: text, UserID: msg.UserID, ID: msg.ID})
+	return err
+}
+
+// SendText отправляет одно сообщение.
+func (c *Client) SendText(options MessageOptions) (Response, error) {
+	return c.sendText(context.Background(), options)
+}
+
+func (c *Client) sendText(ctx context.Context, options MessageOptions) (Response, error) {
+	if !c.Enabled {
+		return Response{}, fmt.Errorf("функционал Viber отключён: некорректная конфигурация")
+	}
+	if err := c.inflight.Acquire(); err != nil {
+		return Response{}, err
+	}
+	defer c.inflight.Release()
+
+	ctx, span := tracing.Start(ctx, Channel, options.Receiver)
+	started := time.Now()
+	res, err := c.post(ctx, SendMessage, message{
+		Receiver:      options.Receiver,
+		MinAPIVersion: 1,
+		Sender:        c.sender,
+		Type:          "text",
+		Text:          options.Text,
+	})
+	tracing.End(span, err)
+	c.logDelivery(ctx, options, started, err)
+	return res, err
+}
+
+// post вызывает метод API. Viber отвечает кодом 200 и при ошибке: признак ошибки — ненулевой status.
+func (c *Client) post(ctx context.Context, method string, body message) (Response, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return Response{}, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.uri+method, bytes.NewReader(data))
+	if err != nil {
+		return Response{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Viber-Auth-Token", c.token)
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return Response{}, err
+	}
+	tracing.HTTPStatus(ctx, resp.StatusCode)
+	defer func(Body io.ReadCloser) {
+		_ = Body.Close()
+	}(resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		return Response{}, fmt.Errorf("ошибка Viber API: код HTTP %d", resp.StatusCode)
+	}
+
+	var res Response
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return Response{}, fmt.Errorf("некорректный формат JSON: %w", err)
+	}
+	if res.Status != 0 {
+		return res, fmt.Errorf("ошибка Viber API: код %d: %s", res.Status, res.StatusMessage)
+	}
+	return res, nil
+}
+
+// logDelivery записывает попытку отправки в журнал доставки, если он подключён.
+func (c *Client) logDelivery(ctx context.Context, options MessageOptions, started time.Time, sendErr error) {
+	delivery.LogAttempt(ctx, c.deliveryLog, c.logger, delivery.Record{
+		ID:          options.ID,
+		Channel:     Channel,
+		UserID:      options.UserID,
+		Recipient:   options.Receiver,
+		MessageHash: delivery.Hash(options.Text),
+		CreatedAt:   started,
+	}, sendErr)
+}
+
+// SendMessaging отправляет одно и то же сообщение нескольким подписчикам с соблюдением rate limit.
+//
+// Возвращает срез результатов по каждому подписчику в порядке подписчиков.
+func (c *Client) SendMessaging(options SendingOptions) []SendResult {
+	return c.SendMessagingWithProgress(context.Background(), options, nil)
+}
+
+// SendMessagingWithProgress работает как SendMessaging, но после каждой отправки вызывает onProgress
+// с её результатом и текущими счётчиками. onProgress вызывается последовательно из одной горутины.
+func (c *Client) SendMessagingWithProgress(ctx context.Context, options SendingOptions, onProgress func(SendResult, notify.Progress)) []SendResult {
+	results := make([]SendResult, 0, len(options.Receivers))
+	progress := notify.Progress{Total: len(options.Receivers)}
+
+	for res := range c.SendMessagingStream(ctx, options) {
+		if res.Error != nil {
+			progress.Failed++
+		} else {
+			progress.Sent++
+		}
+		results = append(results, res)
+		if onProgress != nil {
+			onProgress(res, progress)
+		}
+	}
+	// Результаты приходят по мере завершения отправок, а возвращаются в порядке получателей
+	slices.SortFunc(results, func(a, b SendResult) int { return cmp.Compare(a.Index, b.Index) })
+	return results
+}
+
+// SendMessagingStream запускает рассылку и возвращает канал результатов, который закрывается
+// после обработки всех подписчиков.
+//
+// Подписчики отправляются пачками по MaxBroadcast через broadcast_message. Viber допускает
+// 500 таких запросов за 10 секунд, лимитер держит 40 запросов в секунду.
+func (c *Client) SendMessagingStream(ctx context.Context, options SendingOptions) <-chan SendResult {
+	out := make(chan SendResult, len(options.Receivers))
+
+	if !c.Enabled {
+		c.logger.Warn("отправка сообщений Viber отключена: возвращаем заглушку")
+		for i, r := range options.Receivers {
+			out <- SendResult{Receiver: r, Index: i, Error: fmt.Errorf("функционал Viber отключён")}
+		}
+		close(out)
+		return out
+	}
+
+	limiter := rate.NewLimiter(rate.Limit(40), 5)
+
+	var wg sync.WaitGroup
+	for start := 0; start < len(options.Receivers); start += MaxBroadcast {
+		batch := options.Receivers[start:min(start+MaxBroadcast, len(options.Receivers))]
+		wg.Add(1)
+
+		go func(start int, batch []string) {
+			defer wg.Done()
+
+			if err := limiter.Wait(ctx); err != nil {
+				c.logger.Error("лимитер не пропустил", "receivers", len(batch), "error", err)
+				for i, r := range batch {
+					out <- SendResult{Receiver: r, Index: start + i, Error: err}
+				}
+				return
+			}
+			// broadcast возвращает результаты в порядке batch
+			for i, res := range c.broadcast(ctx, batch, options.Text) {
+				res.Index = start + i
+				out <- res
+			}
+		}(start, batch)
+	}
+
+	go func() {
+		wg.Wait()
+		close(out)
+	}()
+	return out
+}
+
+// broadcast отправляет сообщение пачке подписчиков одним вызовом broadcast_message.
+func (c *Client) broadcast(ctx context.Context, receivers []string, text string) []SendResult {
+	if err := c.inflight.Acquire(); err != nil {
+		results := make([]SendResult, len(receivers))
+		for i, r := range receivers {
+			results[i] = SendResult{Receiver: r, Error: err}
+		}
+		return results
+	}
+	defer c.inflight.Release()
+
+	ctx, span := tracing.StartBatch(ctx, Channel, len(receivers))
+	started := time.Now()
+	res, err := c.post(ctx, BroadcastMessage, message{
+		BroadcastList: receivers,
+		MinAPIVersion: 1,
+		Sender:        c.sender,
+		Type:          "text",
+		Text:          text,
+	})
+	tracing.End(span, err)
+
+	failed := make(map[string]error, len(res.FailedList))
+	for _, f := range res.FailedList {
+		failed[f.Receiver] = fmt.Errorf("ошибка Viber API: код %d: %s", f.Status, f.StatusMessage)
+	}
+
+	results := make([]SendResult, 0, len(receivers))
+	for _, r := range receivers {
+		result := SendResult{Receiver: r, Error: err}
+		if err == nil {
+			result.Error = failed[r]
+		}
+		c.logDelivery(ctx, MessageOptions{Receiver: r, Text: text}, started, result.Error)
+		results = append(results, result)
+	}
+	return results
+}
@@ -0,0 +1,58 @@
+package viber
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/epheer/notephee/config"
+	"github.com/epheer/notephee/notify"
+)
+
+func TestSendAndBroadcast(t *testing.T) {
+	var got message
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Viber-Auth-Token") != "viber-test" {
+			t.Errorf("неожиданный токен: %s", r.Header.Get("X-Viber-Auth-Token"))
+		}
+		_ = json.NewDecoder(r.Body).Decode(&got)
+		switch r.URL.Path {
+		case SendMessage:
+			if got.Receiver == "blocked" {
+				_, _ = w.Write([]byte(`{"status":6,"status_message":"notSubscribed"}`))
+				return
+			}
+			_, _ = w.Write([]byte(`{"status":0,"status_message":"ok","message_token":5741311803571721087}`))
+		case BroadcastMessage:
+			_, _ = w.Write([]byte(`{"status":0,"status_message":"ok","failed_list":[{"receiver":"u2","status":6,"status_message":"Not subscribed"}]}`))
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	c := NewClient(&config.Config{ViberToken: "viber-test", ViberSenderName: "Notephee — сервис уведомлений для всех"}, slog.Default())
+	c.uri = srv.URL
+	ctx := context.Background()
+
+	if err := c.Send(ctx, notify.Message{To: "u1", Subject: "Заказ", Text: "доставлен"}); err != nil {
+		t.Fatalf("Ошибка Send: %v", err)
+	}
+	if got.Type != "text" || got.Text != "Заказ\n\nдоставлен" || len([]rune(got.Sender.Name)) != MaxSenderName {
+		t.Fatalf("неожиданное тело запроса: %+v", got)
+	}
+	if err := c.Send(ctx, notify.Message{To: "blocked", Text: "x"}); err == nil {
+		t.Fatal("ожидалась ошибка для неподписанного получателя")
+	}
+
+	results := c.SendMessaging(SendingOptions{Receivers: []string{"u1", "u2", "u3"}, Text: "новость"})
+	for _, res := range results {
+		if (res.Error != nil) != (res.Receiver == "u2") {
+			t.Fatalf("неожиданный результат для %s: %v", res.Receiver, res.Error)
+		}
+	}
+	if len(got.BroadcastList) != 3 {
+		t.Fatalf("ожидалась одна пачка из трёх получателей, получено %v", got.BroadcastList)
+	}
+}
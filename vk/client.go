@@ -0,0 +1,374 @@
+// Package vk реализует канал ВКонтакте: отправку сообщений от имени сообщества через метод messages.send.
+package vk
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/time/rate"
+
+	"github.com/epheer/notephee/config"
+	"github.com/epheer/notephee/delivery"
+	"github.com/epheer/notephee/notify"
+	"github.com/epheer/notephee/tracing"
+)
+
+// Channel — имя канала ВКонтакте в notify.Registry и журнале доставки.
+const Channel = "vk"
+
+// Параметры VK API.
+const (
+	SendMessage = "/messages.send" // Метод отправки сообщения
+	APIVersion  = "5.199"          // Версия API
+	MaxPeerIDs  = 100              // Предел получателей в одном вызове messages.send с peer_ids
+	MaxText     = 4096             // Предел длины текста сообщения в символах
+)
+
+// Коды ошибок VK API, связанные с частотой запросов.
+const (
+	ErrTooManyRequests = 6 // Слишком много запросов в секунду
+	ErrFloodControl    = 9 // Слишком много однотипных действий
+)
+
+// MessageOptions содержит параметры одного сообщения.
+type MessageOptions struct {
+	PeerID  int64  // ID получателя: пользователя или беседы (2000000000 + id беседы)
+	Message string // Текст сообщения
+	UserID  string // Внутренний ID пользователя для журнала доставки (необязательно)
+	ID      string // Идентификатор попытки; из него выводится random_id для идемпотентности
+}
+
+// SendingOptions используется для отправки одного сообщения нескольким получателям.
+type SendingOptions struct {
+	PeerIDs []int64 // ID получателей
+	Message string  // Текст сообщения
+}
+
+// Error — ошибка VK API.
+type Error struct {
+	Code    int    `json:"error_code"` // Код ошибки
+	Message string `json:"error_msg"`  // Описание
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("ошибка VK API: код %d: %s", e.Code, e.Message)
+}
+
+// peerResult — результат отправки одному получателю при вызове с peer_ids.
+type peerResult struct {
+	PeerID    int64 `json:"peer_id"`
+	MessageID int64 `json:"message_id"`
+	Error     *struct {
+		Code        int    `json:"code"`
+		Description string `json:"description"`
+	} `json:"error,omitempty"`
+}
+
+// SendResult представляет результат отправки одному получателю.
+type SendResult struct {
+	PeerID    int64 // ID получателя
+	Index     int   // Номер получателя в SendingOptions.PeerIDs
+	MessageID int64 // ID отправленного сообщения
+	Error     error // Ошибка, если произошла
+}
+
+// Client инкапсулирует клиента VK API с токеном сообщества.
+type Client struct {
+	token   string       // Ключ доступа сообщества
+	uri     string       // Базовый URL API
+	http    *http.Client // HTTP-клиент
+	logger  *slog.Logger // Логгер
+	Enabled bool         // Флаг доступности функционала
+
+	deliveryLog delivery.DeliveryLog // Журнал попыток отправки (необязательно)
+	inflight    notify.InFlight      // Начатые отправки, которых ждёт Close
+}
+
+// NewClient создаёт клиента ВКонтакте.
+//
+// cfg — конфигурация приложения с ключом доступа сообщества.
+// logger — логгер для ведения журнала.
+func NewClient(cfg *config.Config, logger *slog.Logger) *Client {
+	return &Client{
+		token:   cfg.VKToken,
+		uri:     "https://api.vk.com/method",
+		http:    &http.Client{Timeout: 10 * time.Second},
+		logger:  logger,
+		Enabled: cfg.IsVKEnabled(),
+	}
+}
+
+// SetDeliveryLog подключает журнал, в который записывается каждая попытка отправки.
+func (c *Client) SetDeliveryLog(log delivery.DeliveryLog) {
+	c.deliveryLog = log
+}
+
+// Close перестаёт принимать новые отправки и ждёт завершения начатых до истечения ctx.
+// Отправки после Close возвращают notify.ErrClosed.
+func (c *Client) Close(ctx context.Context) error {
+	return c.inflight.Close(ctx)
+}
+
+// Channel возвращает имя канала для notify.Registry.
+func (c *Client) Channel() string {
+	return Channel
+}
+
+// Capabilities возвращает возможности канала: текст до MaxText символов.
+func (c *Client) Capabilities() notify.Capabilities {
+	return notify.Capabilities{Channel: Channel, MaxLength: MaxText}
+}
+
+// Send реализует notify.Sender: msg.To — peer_id получателя. Тема, если есть, выводится первой строкой.
+func (c *Client) Send(ctx context.Context, msg notify.Message) error {
+	ctx = delivery.WithTemplate(ctx, msg.Template)
+	peerID, err := strconv.ParseInt(msg.To, 10, 64)
+	if err != nil {
+		return fmt.Errorf("некорректный peer_id %q: %w", msg.To, err)
+	}
+
+	text := msg.Text
+	if msg.Subject != "" {
+		text = msg.Subject + "\n\n" + text
+	}
+
+	_, err = c.sendText(ctx, MessageOptions{PeerID: peerID, Message: text, UserID: msg.UserID, ID: msg.ID})
+	return err
+}
+
+// SendText отправляет одно сообщение и возвращает ID сообщения.
+func (c *Client) SendText(options MessageOptions) (int64, error) {
+	return c.sendText(context.Background(), options)
+}
+
+func (c *Client) sendText(ctx context.Context, options MessageOptions) (int64, error) {
+	if !c.Enabled {
+		return 0, fmt.Errorf("функционал ВКонтакте отключён: некорректная конфигурация")
+	}
+	if err := c.inflight.Acquire(); err != nil {
+		return 0, err
+	}
+	defer c.inflight.Release()
+
+	if options.ID == "" {
+		options.ID = uuid.New().String()
+	}
+	form := url.Values{
+		"peer_id":   {strconv.FormatInt(options.PeerID, 10)},
+		"message":   {options.Message},
+		"random_id": {strconv.FormatInt(int64(randomID(options.ID)), 10)},
+	}
+
+	ctx, span := tracing.Start(ctx, Channel, strconv.FormatInt(options.PeerID, 10))
+	started := time.Now()
+	var messageID int64
+	err := c.call(ctx, SendMessage, form, &messageID)
+	tracing.End(span, err)
+	c.logDelivery(ctx, options, started, err)
+	return messageID, err
+}
+
+// randomID выводит random_id из идентификатора попытки: VK не создаёт второе сообщение
+// с тем же random_id от того же сообщества, поэтому повтор попытки не даёт дубля.
+func randomID(id string) int32 {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(id))
+	return int32(h.Sum32() & 0x7fffffff)
+}
+
+// call вызывает метод API и разбирает поле response в res.
+func (c *Client) call(ctx context.Context, method string, form url.Values, res any) error {
+	form.Set("access_token", c.token)
+	form.Set("v", APIVersion)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.uri+method, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	tracing.HTTPStatus(ctx, resp.StatusCode)
+	defer func(Body io.ReadCloser) {
+		_ = Body.Close()
+	}(resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("ошибка VK API: код HTTP %d", resp.StatusCode)
+	}
+
+	// VK отвечает кодом 200 и при ошибке: признак ошибки — поле error вместо response
+	var body struct {
+		Response json.RawMessage `json:"response"`
+		Error    *Error          `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return fmt.Errorf("некорректный формат JSON: %w", err)
+	}
+	if body.Error != nil {
+		return body.Error
+	}
+	if err := json.Unmarshal(body.Response, res); err != nil {
+		return fmt.Errorf("некорректный формат JSON: %w", err)
+	}
+	return nil
+}
+
+// logDelivery записывает попытку отправки в журнал доставки, если он подключён.
+func (c *Client) logDelivery(ctx context.Context, options MessageOptions, started time.Time, sendErr error) {
+	delivery.LogAttempt(ctx, c.deliveryLog, c.logger, delivery.Record{
+		ID:          options.ID,
+		Channel:     Channel,
+		UserID:      options.UserID,
+		Recipient:   strconv.FormatInt(options.PeerID, 10),
+		MessageHash: delivery.Hash(options.Message),
+		CreatedAt:   started,
+	}, sendErr)
+}
+
+// SendMessaging отправляет одно и то же сообщение нескольким получателям с соблюдением rate limit.
+//
+// Возвращает срез результатов по каждому получателю в порядке получателей.
+func (c *Client) SendMessaging(options SendingOptions) []SendResult {
+	return c.SendMessagingWithProgress(context.Background(), options, nil)
+}
+
+// SendMessagingWithProgress работает как SendMessaging, но после каждой отправки вызывает onProgress
+// с её результатом и текущими счётчиками. onProgress вызывается последовательно из одной горутины.
+func (c *Client) SendMessagingWithProgress(ctx context.Context, options SendingOptions, onProgress func(SendResult, notify.Progress)) []SendResult {
+	results := make([]SendResult, 0, len(options.PeerIDs))
+	progress := notify.Progress{Total: len(options.PeerIDs)}
+
+	for res := range c.SendMessagingStream(ctx, options) {
+		if res.Error != nil {
+			progress.Failed++
+		} else {
+			progress.Sent++
+		}
+		results = append(results, res)
+		if onProgress != nil {
+			onProgress(res, progress)
+		}
+	}
+	// Результаты приходят по мере завершения отправок, а возвращаются в порядке получателей
+	slices.SortFunc(results, func(a, b SendResult) int { return cmp.Compare(a.Index, b.Index) })
+	return results
+}
+
+// SendMessagingStream запускает рассылку и возвращает канал результатов, который закрывается
+// после обработки всех получателей.
+//
+// Получатели отправляются пачками по MaxPeerIDs одним вызовом messages.send с peer_ids.
+// Ключ сообщества допускает 20 вызовов в секунду, лимитер держит скорость с небольшим запасом.
+func (c *Client) SendMessagingStream(ctx context.Context, options SendingOptions) <-chan SendResult {
+	out := make(chan SendResult, len(options.PeerIDs))
+
+	if !c.Enabled {
+		c.logger.Warn("отправка сообщений ВКонтакте отключена: возвращаем заглушку")
+		for i, id := range options.PeerIDs {
+			out <- SendResult{PeerID: id, Index: i, Error: fmt.Errorf("функционал ВКонтакте отключён")}
+		}
+		close(out)
+		return out
+	}
+
+	limiter := rate.NewLimiter(rate.Limit(18), 1)
+
+	var wg sync.WaitGroup
+	for start := 0; start < len(options.PeerIDs); start += MaxPeerIDs {
+		batch := options.PeerIDs[start:min(start+MaxPeerIDs, len(options.PeerIDs))]
+		wg.Add(1)
+
+		go func(start int, batch []int64) {
+			defer wg.Done()
+
+			if err := limiter.Wait(ctx); err != nil {
+				c.logger.Error("лимитер не пропустил", "peers", len(batch), "error", err)
+				for i, id := range batch {
+					out <- SendResult{PeerID: id, Index: start + i, Error: err}
+				}
+				return
+			}
+			// sendBatch возвращает результаты в порядке batch
+			for i, res := range c.sendBatch(ctx, batch, options.Message) {
+				res.Index = start + i
+				out <- res
+			}
+		}(start, batch)
+	}
+
+	go func() {
+		wg.Wait()
+		close(out)
+	}()
+	return out
+}
+
+// sendBatch отправляет сообщение пачке получателей одним вызовом API.
+func (c *Client) sendBatch(ctx context.Context, peerIDs []int64, message string) []SendResult {
+	if err := c.inflight.Acquire(); err != nil {
+		results := make([]SendResult, len(peerIDs))
+		for i, id := range peerIDs {
+			results[i] = SendResult{PeerID: id, Error: err}
+		}
+		return results
+	}
+	defer c.inflight.Release()
+
+	ids := make([]string, len(peerIDs))
+	for i, id := range peerIDs {
+		ids[i] = strconv.FormatInt(id, 10)
+	}
+	form := url.Values{
+		"peer_ids":  {strings.Join(ids, ",")},
+		"message":   {message},
+		"random_id": {strconv.FormatInt(int64(rand.Int32()), 10)},
+	}
+
+	ctx, span := tracing.StartBatch(ctx, Channel, len(peerIDs))
+	started := time.Now()
+	var items []peerResult
+	err := c.call(ctx, SendMessage, form, &items)
+	tracing.End(span, err)
+
+	byPeer := make(map[int64]peerResult, len(items))
+	for _, item := range items {
+		byPeer[item.PeerID] = item
+	}
+
+	results := make([]SendResult, 0, len(peerIDs))
+	for _, id := range peerIDs {
+		res := SendResult{PeerID: id, Error: err}
+		if err == nil {
+			item, ok := byPeer[id]
+			switch {
+			case !ok:
+				res.Error = fmt.Errorf("ошибка VK API: нет результата для peer_id %d", id)
+			case item.Error != nil:
+				res.Error = &Error{Code: item.Error.Code, Message: item.Error.Description}
+			default:
+				res.MessageID = item.MessageID
+			}
+		}
+		c.logDelivery(ctx, MessageOptions{PeerID: id, Message: message, ID: uuid.New().String()}, started, res.Error)
+		results = append(results, res)
+	}
+	return results
+}
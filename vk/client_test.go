@@ -0,0 +1,86 @@
+package vk
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/epheer/notephee/config"
+	"github.com/epheer/notephee/notify"
+)
+
+func TestSendAndBatch(t *testing.T) {
+	var randomIDs []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		if r.URL.Path != SendMessage || r.PostForm.Get("access_token") != "vk1.test" || r.PostForm.Get("v") != APIVersion {
+			t.Errorf("неожиданный запрос: %s %v", r.URL.Path, r.PostForm)
+		}
+		if ids := r.PostForm.Get("peer_ids"); ids != "" {
+			if len(strings.Split(ids, ",")) > MaxPeerIDs {
+				t.Errorf("в пачке больше %d получателей", MaxPeerIDs)
+			}
+			var items []string
+			for _, id := range strings.Split(ids, ",") {
+				if id == "13" {
+					items = append(items, `{"peer_id":13,"error":{"code":901,"description":"Can't send messages for users without permission"}}`)
+					continue
+				}
+				items = append(items, `{"peer_id":`+id+`,"message_id":1}`)
+			}
+			_, _ = w.Write([]byte(`{"response":[` + strings.Join(items, ",") + `]}`))
+			return
+		}
+		randomIDs = append(randomIDs, r.PostForm.Get("random_id"))
+		if r.PostForm.Get("peer_id") == "6" {
+			_, _ = w.Write([]byte(`{"error":{"error_code":6,"error_msg":"Too many requests per second"}}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"response":42}`))
+	}))
+	t.Cleanup(srv.Close)
+
+	c := NewClient(&config.Config{VKToken: "vk1.test"}, slog.Default())
+	c.uri = srv.URL
+	ctx := context.Background()
+
+	msg := notify.Message{ID: "d1", To: "1", Subject: "Заказ", Text: "доставлен"}
+	for range 2 {
+		if err := c.Send(ctx, msg); err != nil {
+			t.Fatalf("Ошибка Send: %v", err)
+		}
+	}
+	if randomIDs[0] != randomIDs[1] {
+		t.Fatal("повтор попытки с тем же ID должен давать тот же random_id")
+	}
+
+	var apiErr *Error
+	if err := c.Send(ctx, notify.Message{To: "6", Text: "x"}); !errors.As(err, &apiErr) || apiErr.Code != ErrTooManyRequests {
+		t.Fatalf("ожидалась ошибка VK API с кодом 6, получено %v", err)
+	}
+
+	peers := make([]int64, 150)
+	for i := range peers {
+		peers[i] = int64(i + 1)
+	}
+	results := c.SendMessaging(SendingOptions{PeerIDs: peers, Message: "новость"})
+	failed := 0
+	for _, res := range results {
+		if res.Error != nil {
+			failed++
+		}
+	}
+	if len(results) != 150 || failed != 1 {
+		t.Fatalf("ожидалось 150 результатов с одной ошибкой, получено %d и %d", len(results), failed)
+	}
+	// Пачки отправляются параллельно, а результаты возвращаются в порядке получателей
+	for i, res := range results {
+		if res.PeerID != peers[i] || res.Index != i {
+			t.Fatalf("результат %d не по порядку: %+v", i, res)
+		}
+	}
+}